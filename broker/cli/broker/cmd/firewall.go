@@ -0,0 +1,256 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/urfave/cli"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/CS-SI/SafeScale/utils"
+	clitools "github.com/CS-SI/SafeScale/utils"
+)
+
+// hostFirewall groups the commands managing the firewall (iptables) of a host
+var hostFirewall = cli.Command{
+	Name:  "firewall",
+	Usage: "firewall COMMAND",
+	Subcommands: []cli.Command{
+		hostFirewallList,
+		hostFirewallAdd,
+		hostFirewallRemove,
+		hostFirewallApply,
+	},
+}
+
+// firewallRuleFlags are the flags defining a firewall rule
+var firewallRuleFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "table",
+		Value: "filter",
+		Usage: "Table of the rule",
+	},
+	cli.StringFlag{
+		Name:  "chain",
+		Value: "INPUT",
+		Usage: "Chain of the rule",
+	},
+	cli.StringFlag{
+		Name:  "action",
+		Usage: "Target of the rule: accept, drop, reject, queue, return, or the name of a chain or of a target extension (ex: MASQUERADE)",
+	},
+	cli.StringFlag{
+		Name:  "protocol",
+		Usage: "Protocol (tcp, udp, icmp...)",
+	},
+	cli.StringFlag{
+		Name:  "from",
+		Usage: "Source address or CIDR",
+	},
+	cli.StringFlag{
+		Name:  "from-port",
+		Usage: "Source port or range of ports (ex: 1024:65535), needs --protocol",
+	},
+	cli.StringFlag{
+		Name:  "to",
+		Usage: "Destination address or CIDR",
+	},
+	cli.StringFlag{
+		Name:  "to-port",
+		Usage: "Destination port or range of ports, needs --protocol",
+	},
+	cli.StringFlag{
+		Name:  "in",
+		Usage: "Input interface",
+	},
+	cli.StringFlag{
+		Name:  "out",
+		Usage: "Output interface",
+	},
+	cli.StringFlag{
+		Name:  "matches",
+		Usage: "Other match options, in iptables syntax (ex: \"-m conntrack --ctstate NEW\")",
+	},
+	cli.StringFlag{
+		Name:  "target-options",
+		Usage: "Options of the target, in iptables syntax (ex: \"--reject-with icmp-port-unreachable\")",
+	},
+}
+
+// firewallRule returns the rule defined by the flags, nil if none is set
+func firewallRule(c *cli.Context) *pb.FirewallRule {
+	set := false
+	for _, name := range []string{"action", "protocol", "from", "from-port", "to", "to-port", "in", "out", "matches", "target-options"} {
+		if c.String(name) != "" {
+			set = true
+		}
+	}
+	if !set {
+		return nil
+	}
+	return &pb.FirewallRule{
+		Target:        c.String("action"),
+		Protocol:      c.String("protocol"),
+		From:          c.String("from"),
+		FromPort:      c.String("from-port"),
+		To:            c.String("to"),
+		ToPort:        c.String("to-port"),
+		InInterface:   c.String("in"),
+		OutInterface:  c.String("out"),
+		Matches:       c.String("matches"),
+		TargetOptions: c.String("target-options"),
+	}
+}
+
+var hostFirewallList = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "List the firewall rules of a host",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "table",
+			Usage: "Lists only the rules of this table",
+		}},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		resp, err := client.New().Firewall.List(c.Args().First(), c.String("table"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "list of firewall rules", false).Error()))
+		}
+		out, _ := json.Marshal(resp)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var hostFirewallAdd = cli.Command{
+	Name:      "add",
+	Usage:     "Add a rule to the firewall of a host",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: append([]cli.Flag{
+		cli.IntFlag{
+			Name:  "position",
+			Usage: "Position of the rule in the chain, starting from 1 (default: at the end of the chain)",
+		}}, firewallRuleFlags...),
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		if c.String("action") == "" {
+			fmt.Println("Missing mandatory option --action")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		def := pb.FirewallRuleDefinition{
+			Host:     &pb.Reference{Name: c.Args().First()},
+			Table:    c.String("table"),
+			Chain:    c.String("chain"),
+			Position: int32(c.Int("position")),
+			Rule:     firewallRule(c),
+		}
+		err := client.New().Firewall.Add(def, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "addition of firewall rule", false).Error()))
+		}
+		fmt.Printf("Firewall rule added on host '%s'\n", c.Args().First())
+
+		return nil
+	},
+}
+
+var hostFirewallRemove = cli.Command{
+	Name:      "remove",
+	Aliases:   []string{"rm"},
+	Usage:     "Remove a rule from the firewall of a host, designated by its position or by its definition",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: append([]cli.Flag{
+		cli.IntFlag{
+			Name:  "position",
+			Usage: "Position of the rule in the chain, starting from 1",
+		}}, firewallRuleFlags...),
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		rule := firewallRule(c)
+		if c.Int("position") <= 0 && rule == nil {
+			fmt.Println("Missing --position or definition of the rule")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		def := pb.FirewallRuleDefinition{
+			Host:     &pb.Reference{Name: c.Args().First()},
+			Table:    c.String("table"),
+			Chain:    c.String("chain"),
+			Position: int32(c.Int("position")),
+			Rule:     rule,
+		}
+		err := client.New().Firewall.Remove(def, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "removal of firewall rule", false).Error()))
+		}
+		fmt.Printf("Firewall rule removed from host '%s'\n", c.Args().First())
+
+		return nil
+	},
+}
+
+var hostFirewallApply = cli.Command{
+	Name:      "apply",
+	Usage:     "Replace the rules of the tables defined in a file (in the format of 'broker host firewall list') on a host; the other tables are left untouched",
+	ArgsUsage: "<Host_name|Host_ID> <file>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			fmt.Println("Missing mandatory argument <Host_name> and/or <file>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		content, err := ioutil.ReadFile(c.Args().Get(1))
+		if err != nil {
+			fmt.Printf("Can't read file '%s': %v\n", c.Args().Get(1), err)
+			return clitools.ExitOnInvalidArgument()
+		}
+		fw := pb.Firewall{}
+		err = json.Unmarshal(content, &fw)
+		if err != nil {
+			fmt.Printf("Invalid content of file '%s': %v\n", c.Args().Get(1), err)
+			return clitools.ExitOnInvalidArgument()
+		}
+		fw.Host = &pb.Reference{Name: c.Args().First()}
+		err = client.New().Firewall.Apply(fw, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "application of firewall rules", false).Error()))
+		}
+		fmt.Printf("Firewall rules applied on host '%s'\n", c.Args().First())
+
+		return nil
+	},
+}
@@ -19,6 +19,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/urfave/cli"
 
@@ -42,6 +43,9 @@ var HostCmd = cli.Command{
 		hostReboot,
 		hostStart,
 		hostStop,
+		hostImport,
+		hostRekey,
+		hostFirewall,
 	},
 }
 
@@ -151,6 +155,69 @@ var hostInspect = cli.Command{
 	},
 }
 
+var hostImport = cli.Command{
+	Name:      "import",
+	Usage:     "Import a host created outside SafeScale",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "private-key",
+			Usage: "File containing the SSH private key allowing to connect to the host",
+		}},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		privateKey := ""
+		if file := c.String("private-key"); file != "" {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				fmt.Printf("Can't read private key file '%s': %v\n", file, err)
+				return clitools.ExitOnInvalidArgument()
+			}
+			privateKey = string(content)
+		}
+		resp, err := client.New().Host.Import(c.Args().First(), privateKey, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "import of host", false).Error()))
+		}
+
+		out, _ := json.Marshal(resp)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var hostRekey = cli.Command{
+	Name:      "rekey",
+	Usage:     "Accept the SSH host key currently presented by a host",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "fingerprint",
+			Usage: "Expected SHA256 fingerprint of the new host key (ex: SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8); the key is refused if it doesn't match",
+		}},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		resp, err := client.New().Host.Rekey(c.Args().First(), c.String("fingerprint"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "rekey of host", false).Error()))
+		}
+
+		out, _ := json.Marshal(resp)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
 var hostStatus = cli.Command{
 	Name:      "status",
 	Usage:     "status Host",
@@ -222,6 +289,14 @@ var hostCreate = cli.Command{
 			Name:  "f, force",
 			Usage: "Force creation even if the host doesn't meet the GPU and CPU freq requirements",
 		},
+		cli.BoolFlag{
+			Name:  "async",
+			Usage: "Return the operation creating the host without waiting for it to end",
+		},
+		cli.StringSliceFlag{
+			Name:  "user-data",
+			Usage: "File containing a cloud-init part run at first boot, cloud-config document ('#cloud-config') or shell script ('#!'); can be repeated",
+		},
 		// // TODO list available features
 		// cli.StringFlag{
 		// 	Name:  "features",
@@ -241,6 +316,16 @@ var hostCreate = cli.Command{
 		}
 		// hostName := c.Args().Get(1)
 
+		var userData []string
+		for _, file := range c.StringSlice("user-data") {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				fmt.Printf("Can't read user data file '%s': %v\n", file, err)
+				return clitools.ExitOnInvalidArgument()
+			}
+			userData = append(userData, string(content))
+		}
+
 		def := pb.HostDefinition{
 			Name:      c.Args().First(),
 			CPUNumber: int32(c.Int("cpu")),
@@ -252,8 +337,22 @@ var hostCreate = cli.Command{
 			GPUNumber: int32(c.Int("gpu")),
 			Freq:      float32(c.Float64("cpu-freq")),
 			Force:     c.Bool("force"),
+			UserData:  userData,
+		}
+		op, err := client.New().Host.CreateAsync(def, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "creation of host", true).Error()))
+		}
+		if c.Bool("async") {
+			out, _ := json.Marshal(op)
+			fmt.Println(string(out))
+			return nil
+		}
+		op, err = followOperation(op.GetID())
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "creation of host", true).Error()))
 		}
-		resp, err := client.New().Host.Create(def, client.DefaultExecutionTimeout)
+		resp, err := client.New().Host.Inspect(op.GetResultID(), client.DefaultExecutionTimeout)
 		if err != nil {
 			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "creation of host", true).Error()))
 		}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli"
+
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/CS-SI/SafeScale/utils"
+	clitools "github.com/CS-SI/SafeScale/utils"
+	"github.com/CS-SI/SafeScale/utils/enums/ExitCode"
+)
+
+// MetadataCmd command
+var MetadataCmd = cli.Command{
+	Name:  "metadata",
+	Usage: "metadata COMMAND",
+	Subcommands: []cli.Command{
+		metadataLock,
+		metadataHistory,
+		metadataRestore,
+		metadataRekey,
+		metadataFsck,
+	},
+}
+
+var metadataLock = cli.Command{
+	Name:  "lock",
+	Usage: "Inspect and break the locks of metadata",
+	Subcommands: []cli.Command{
+		metadataLockList,
+		metadataLockBreak,
+	},
+}
+
+var metadataLockList = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List the locks present in the metadata of the tenant",
+	Action: func(c *cli.Context) error {
+		locks, err := client.New().Metadata.ListLocks(client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "list of metadata locks", false).Error()))
+		}
+		out, _ := json.Marshal(locks.GetLocks())
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var metadataLockBreak = cli.Command{
+	Name:      "break",
+	Usage:     "Remove a lock left by a dead process",
+	ArgsUsage: "<Key>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "force",
+			Usage: "Remove the lock even if it has not expired",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Key>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		err := client.New().Metadata.BreakLock(c.Args().First(), c.Bool("force"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "break of metadata lock", false).Error()))
+		}
+		fmt.Printf("Lock on '%s' removed\n", c.Args().First())
+		return nil
+	},
+}
+
+var metadataHistory = cli.Command{
+	Name:      "history",
+	Usage:     "List the revisions kept of the metadata of a resource",
+	ArgsUsage: "<cluster|host|network|share|volume> <Name_or_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			fmt.Println("Missing mandatory argument <kind> and/or <Name_or_id>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		revisions, err := client.New().Metadata.History(c.Args().Get(0), c.Args().Get(1), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "history of metadata", false).Error()))
+		}
+		out, _ := json.Marshal(revisions.GetRevisions())
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var metadataRestore = cli.Command{
+	Name:      "restore",
+	Usage:     "Write back a previous revision of the metadata of a resource",
+	ArgsUsage: "<cluster|host|network|share|volume> <Name_or_id> <Revision_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 3 {
+			fmt.Println("Missing mandatory argument <kind>, <Name_or_id> and/or <Revision_id>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		err := client.New().Metadata.Restore(c.Args().Get(0), c.Args().Get(1), c.Args().Get(2), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "restoration of metadata", false).Error()))
+		}
+		fmt.Printf("Metadata of %s '%s' restored to revision '%s'\n", c.Args().Get(0), c.Args().Get(1), c.Args().Get(2))
+		return nil
+	},
+}
+
+var metadataRekey = cli.Command{
+	Name:  "rekey",
+	Usage: "Re-encrypt the metadata of the tenant with the current metadata key (setting 'CryptKey')",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "from-plaintext",
+			Usage: "Encrypt also the metadata not encrypted yet (when encryption is enabled on an existing tenant)",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Report what would be re-encrypted without writing anything",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		report, err := client.New().Metadata.Rekey(c.Bool("from-plaintext"), c.Bool("dry-run"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "rekey of metadata", false).Error()))
+		}
+		out, _ := json.Marshal(report)
+		fmt.Println(string(out))
+		if len(report.GetFailures()) > 0 {
+			return clitools.ExitOnErrorWithMessage(ExitCode.Run, fmt.Sprintf("%d metadata object(s) could not be re-encrypted", len(report.GetFailures())))
+		}
+		return nil
+	},
+}
+
+var metadataFsck = cli.Command{
+	Name:  "fsck",
+	Usage: "Check the metadata of hosts, networks, volumes and shares against the resources of the cloud",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "delete-dangling",
+			Usage: "Remove the metadata of resources not existing anymore, and the references to them",
+		},
+		cli.BoolFlag{
+			Name:  "import-untracked",
+			Usage: "Create metadata for the resources of the cloud unknown by SafeScale",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		drifts, err := client.New().Metadata.Fsck(c.Bool("delete-dangling"), c.Bool("import-untracked"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "check of metadata", false).Error()))
+		}
+		out, _ := json.Marshal(drifts.GetDrifts())
+		fmt.Println(string(out))
+		left := 0
+		for _, drift := range drifts.GetDrifts() {
+			if !drift.GetRepaired() {
+				left++
+			}
+		}
+		if left > 0 {
+			return clitools.ExitOnErrorWithMessage(ExitCode.Run, fmt.Sprintf("%d drift(s) between metadata and cloud left unrepaired", left))
+		}
+		return nil
+	},
+}
@@ -37,6 +37,7 @@ var NetworkCmd = cli.Command{
 		networkDelete,
 		networkInspect,
 		networkList,
+		networkImport,
 	},
 }
 
@@ -86,6 +87,32 @@ var networkDelete = cli.Command{
 	},
 }
 
+var networkImport = cli.Command{
+	Name:      "import",
+	Usage:     "Import a Network created outside SafeScale",
+	ArgsUsage: "<network_name|network_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "gateway",
+			Usage: "Host acting as gateway of the network (must be already managed by SafeScale, see 'host import')",
+		}},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <network_name>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		network, err := client.New().Network.Import(c.Args().First(), c.String("gateway"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "import of network", false).Error()))
+		}
+		out, _ := json.Marshal(network)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
 var networkInspect = cli.Command{
 	Name:      "inspect",
 	Aliases:   []string{"show"},
@@ -144,6 +171,10 @@ var networkCreate = cli.Command{
 			Value: "",
 			Usage: "Name for the gateway. Default to 'gw-<network_name>'",
 		},
+		cli.BoolFlag{
+			Name:  "async",
+			Usage: "Return the operation creating the network without waiting for it to end",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 1 {
@@ -163,7 +194,20 @@ var networkCreate = cli.Command{
 				Name:    c.String("gwname"),
 			},
 		}
-		network, err := client.New().Network.Create(netdef, client.DefaultExecutionTimeout)
+		op, err := client.New().Network.CreateAsync(netdef, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "creation of network", true).Error()))
+		}
+		if c.Bool("async") {
+			out, _ := json.Marshal(op)
+			fmt.Println(string(out))
+			return nil
+		}
+		op, err = followOperation(op.GetID())
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "creation of network", true).Error()))
+		}
+		network, err := client.New().Network.Inspect(op.GetResultID(), client.DefaultExecutionTimeout)
 		if err != nil {
 			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "creation of network", true).Error()))
 		}
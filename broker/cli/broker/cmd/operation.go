@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/CS-SI/SafeScale/utils"
+	clitools "github.com/CS-SI/SafeScale/utils"
+)
+
+// OperationCmd command
+var OperationCmd = cli.Command{
+	Name:    "operation",
+	Aliases: []string{"op"},
+	Usage:   "operation COMMAND",
+	Subcommands: []cli.Command{
+		operationList,
+		operationInspect,
+		operationWatch,
+		operationCancel,
+	},
+}
+
+var operationList = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List operations of the tenant",
+	Action: func(c *cli.Context) error {
+		operations, err := client.New().Operation.List(client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "list of operations", false).Error()))
+		}
+		out, _ := json.Marshal(operations.GetOperations())
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var operationInspect = cli.Command{
+	Name:      "inspect",
+	Aliases:   []string{"show"},
+	Usage:     "Inspect operation",
+	ArgsUsage: "<Operation_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Operation_id>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		op, err := client.New().Operation.Inspect(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "inspection of operation", false).Error()))
+		}
+		out, _ := json.Marshal(op)
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var operationWatch = cli.Command{
+	Name:      "watch",
+	Usage:     "Display the progress of an operation until it ends",
+	ArgsUsage: "<Operation_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Operation_id>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		op, err := followOperation(c.Args().First())
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "operation", false).Error()))
+		}
+		out, _ := json.Marshal(op)
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var operationCancel = cli.Command{
+	Name:      "cancel",
+	Usage:     "Cancel an operation, rolling back what it did",
+	ArgsUsage: "<Operation_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Operation_id>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		err := client.New().Operation.Cancel(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "cancellation of operation", false).Error()))
+		}
+		fmt.Printf("Cancellation of operation '%s' requested\n", c.Args().First())
+		return nil
+	},
+}
+
+// followOperation displays the progress of an operation on stderr until it ends, cancelling it on interruption
+func followOperation(id string) (*pb.Operation, error) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		if _, ok := <-sigs; ok {
+			fmt.Fprintf(os.Stderr, "Interrupted, cancelling operation '%s'...\n", id)
+			err := client.New().Operation.Cancel(id, client.DefaultExecutionTimeout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", client.DecorateError(err, "cancellation of operation", false))
+			}
+		}
+	}()
+
+	return client.New().Operation.Wait(id, func(event *pb.OperationEvent) {
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", time.Unix(event.GetTimestamp(), 0).Format("15:04:05"), event.GetMessage())
+	})
+}
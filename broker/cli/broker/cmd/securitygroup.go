@@ -0,0 +1,262 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/CS-SI/SafeScale/utils"
+	clitools "github.com/CS-SI/SafeScale/utils"
+)
+
+// SecurityGroupCmd security group command
+var SecurityGroupCmd = cli.Command{
+	Name:    "securitygroup",
+	Aliases: []string{"sg"},
+	Usage:   "securitygroup COMMAND",
+	Subcommands: []cli.Command{
+		securityGroupCreate,
+		securityGroupList,
+		securityGroupInspect,
+		securityGroupDelete,
+		securityGroupRule,
+		securityGroupBind,
+		securityGroupUnbind,
+	},
+}
+
+var securityGroupCreate = cli.Command{
+	Name:      "create",
+	Aliases:   []string{"new"},
+	Usage:     "Create a security group",
+	ArgsUsage: "<SecurityGroup_name>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "description",
+			Usage: "Description of the security group",
+		},
+		cli.StringFlag{
+			Name:  "network",
+			Usage: "Network in which the security group is used (mandatory with some providers, like AWS)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <SecurityGroup_name>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		def := pb.SecurityGroupDefinition{
+			Name:        c.Args().First(),
+			Description: c.String("description"),
+		}
+		if network := c.String("network"); network != "" {
+			def.Network = &pb.Reference{Name: network}
+		}
+		sg, err := client.New().SecurityGroup.Create(def, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "creation of security group", true).Error()))
+		}
+		out, _ := json.Marshal(sg)
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var securityGroupList = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List available security groups",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "all",
+			Usage: "List all security groups on tenant (not only those created by SafeScale)",
+		}},
+	Action: func(c *cli.Context) error {
+		groups, err := client.New().SecurityGroup.List(c.Bool("all"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "list of security groups", false).Error()))
+		}
+		var out []byte
+		if len(groups.SecurityGroups) == 0 {
+			out, _ = json.Marshal(nil)
+		} else {
+			out, _ = json.Marshal(groups.SecurityGroups)
+		}
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var securityGroupInspect = cli.Command{
+	Name:      "inspect",
+	Aliases:   []string{"show"},
+	Usage:     "Inspect security group",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <SecurityGroup_name|SecurityGroup_ID>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		sg, err := client.New().SecurityGroup.Inspect(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "inspection of security group", false).Error()))
+		}
+		out, _ := json.Marshal(sg)
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var securityGroupDelete = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"rm", "remove"},
+	Usage:     "Delete security group",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <SecurityGroup_name|SecurityGroup_ID>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		err := client.New().SecurityGroup.Delete(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "deletion of security group", false).Error()))
+		}
+		return nil
+	},
+}
+
+// securityGroupRule groups the commands managing the rules of a security group
+var securityGroupRule = cli.Command{
+	Name:  "rule",
+	Usage: "rule COMMAND",
+	Subcommands: []cli.Command{
+		securityGroupRuleAdd,
+		securityGroupRuleDelete,
+	},
+}
+
+var securityGroupRuleAdd = cli.Command{
+	Name:      "add",
+	Usage:     "Add a rule allowing incoming traffic to a security group",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "protocol",
+			Value: "tcp",
+			Usage: "Protocol allowed: tcp, udp or icmp",
+		},
+		cli.IntFlag{
+			Name:  "from-port",
+			Usage: "First port of the range of ports allowed (type of icmp messages for icmp, -1 for all)",
+		},
+		cli.IntFlag{
+			Name:  "to-port",
+			Usage: "Last port of the range of ports allowed (code of icmp messages for icmp, -1 for all); default: --from-port",
+		},
+		cli.StringFlag{
+			Name:  "cidr",
+			Value: "0.0.0.0/0",
+			Usage: "Source addresses allowed",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <SecurityGroup_name|SecurityGroup_ID>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		rule := pb.SecurityGroupRule{
+			Protocol: c.String("protocol"),
+			FromPort: int32(c.Int("from-port")),
+			ToPort:   int32(c.Int("to-port")),
+			CIDR:     c.String("cidr"),
+		}
+		if !c.IsSet("to-port") {
+			rule.ToPort = rule.FromPort
+		}
+		added, err := client.New().SecurityGroup.AddRule(c.Args().First(), rule, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "addition of security group rule", false).Error()))
+		}
+		out, _ := json.Marshal(added)
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var securityGroupRuleDelete = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"rm", "remove"},
+	Usage:     "Delete a rule of a security group",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID> <Rule_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <SecurityGroup_name|SecurityGroup_ID> and/or <Rule_ID>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		err := client.New().SecurityGroup.DeleteRule(c.Args().Get(0), c.Args().Get(1), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "deletion of security group rule", false).Error()))
+		}
+		return nil
+	},
+}
+
+var securityGroupBind = cli.Command{
+	Name:      "bind",
+	Usage:     "Bind a security group to a host",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID> <Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <SecurityGroup_name|SecurityGroup_ID> and/or <Host_name|Host_ID>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		err := client.New().SecurityGroup.Bind(c.Args().Get(0), c.Args().Get(1), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "binding of security group", false).Error()))
+		}
+		return nil
+	},
+}
+
+var securityGroupUnbind = cli.Command{
+	Name:      "unbind",
+	Usage:     "Unbind a security group from a host",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID> <Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <SecurityGroup_name|SecurityGroup_ID> and/or <Host_name|Host_ID>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		err := client.New().SecurityGroup.Unbind(c.Args().Get(0), c.Args().Get(1), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "unbinding of security group", false).Error()))
+		}
+		return nil
+	},
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 
 	pb "github.com/CS-SI/SafeScale/broker"
@@ -37,6 +38,7 @@ var ShareCmd = cli.Command{
 	Usage:   "share COMMAND",
 	Subcommands: []cli.Command{
 		shareCreate,
+		shareUpdate,
 		shareDelete,
 		shareMount,
 		shareUnmount,
@@ -56,6 +58,14 @@ var shareCreate = cli.Command{
 			Value: model.DefaultShareExportedPath,
 			Usage: "Path to be exported",
 		},
+		cli.StringSliceFlag{
+			Name:  "acl",
+			Usage: "Client allowed to mount the share, with its options, as in /etc/exports (ex: '10.0.0.0/24(rw,root_squash,sec=krb5p)'); can be repeated (default: everyone in read-write mode)",
+		},
+		cli.StringFlag{
+			Name:  "secondary",
+			Usage: "Second host serving the share, making it highly available: the share is replicated between the 2 hosts, and mounted from a VIP moving to the secondary host when the first one fails",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 2 {
@@ -68,6 +78,10 @@ var shareCreate = cli.Command{
 			Name: shareName,
 			Host: &pb.Reference{Name: c.Args().Get(1)},
 			Path: c.String("path"),
+			Acls: strings.Join(c.StringSlice("acl"), " "),
+		}
+		if secondary := c.String("secondary"); secondary != "" {
+			def.Secondary = &pb.Reference{Name: secondary}
 		}
 		err := client.New().Share.Create(def, client.DefaultExecutionTimeout)
 		if err != nil {
@@ -79,6 +93,43 @@ var shareCreate = cli.Command{
 	},
 }
 
+var shareUpdate = cli.Command{
+	Name:      "update",
+	Usage:     "Replace the ACLs of a share, the hosts still allowed keeping it mounted",
+	ArgsUsage: "<Share_name>",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "acl",
+			Usage: "Client allowed to mount the share, with its options, as in /etc/exports (ex: '10.0.0.0/24(ro,sec=krb5i)'); can be repeated",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <Share_name>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		acls := c.StringSlice("acl")
+		if len(acls) == 0 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory option --acl")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		shareName := c.Args().Get(0)
+		def := pb.ShareDefinition{
+			Name: shareName,
+			Acls: strings.Join(acls, " "),
+		}
+		share, err := client.New().Share.Update(def, client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(client.DecorateError(err, "update of share", true).Error())
+		}
+
+		fmt.Printf("Share '%s' successfully updated, exported to: %s\n", shareName, share.GetAcls())
+		return nil
+	},
+}
+
 var shareDelete = cli.Command{
 	Name:      "delete",
 	Aliases:   []string{"rm", "remove"},
@@ -138,13 +189,19 @@ var shareList = cli.Command{
 		} else {
 			var output []map[string]interface{}
 			for _, i := range list.ShareList {
-				output = append(output, map[string]interface{}{
+				share := map[string]interface{}{
 					"ID":   i.GetID(),
 					"Name": i.GetName(),
 					"Host": i.GetHost().GetName(),
 					"Path": i.GetPath(),
 					"Type": i.GetType(),
-				})
+					"Acls": i.GetAcls(),
+				}
+				if i.GetVIP() != "" {
+					share["Secondary"] = i.GetSecondary().GetName()
+					share["VIP"] = i.GetVIP()
+				}
+				output = append(output, share)
 			}
 			out, _ = json.Marshal(output)
 		}
@@ -239,6 +296,11 @@ var shareInspect = cli.Command{
 			"Host": list.GetShare().GetHost().GetName(),
 			"Path": list.GetShare().GetPath(),
 			"Type": list.GetShare().GetType(),
+			"Acls": list.GetShare().GetAcls(),
+		}
+		if list.GetShare().GetVIP() != "" {
+			output["Secondary"] = list.GetShare().GetSecondary().GetName()
+			output["VIP"] = list.GetShare().GetVIP()
 		}
 
 		mountsOutput := map[string]interface{}{}
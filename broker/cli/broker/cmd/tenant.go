@@ -22,6 +22,7 @@ import (
 
 	"github.com/urfave/cli"
 
+	pb "github.com/CS-SI/SafeScale/broker"
 	"github.com/CS-SI/SafeScale/broker/client"
 	"github.com/CS-SI/SafeScale/utils"
 	clitools "github.com/CS-SI/SafeScale/utils"
@@ -35,6 +36,7 @@ var TenantCmd = cli.Command{
 		tenantList,
 		tenantGet,
 		tenantSet,
+		tenantInspect,
 	},
 }
 
@@ -71,7 +73,7 @@ var tenantGet = cli.Command{
 
 var tenantSet = cli.Command{
 	Name:  "set",
-	Usage: "Set tenant to work with",
+	Usage: "Set tenant to work with (kept per user, overridden by --tenant or SAFESCALE_TENANT)",
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 1 {
 			fmt.Println("Missing mandatory argument <tenant_name>")
@@ -86,3 +88,58 @@ var tenantSet = cli.Command{
 		return nil
 	},
 }
+
+var tenantInspect = cli.Command{
+	Name:      "inspect",
+	Usage:     "Inspect the provider and the capabilities of a tenant (the current one by default)",
+	ArgsUsage: "[<tenant_name>]",
+	Action: func(c *cli.Context) error {
+		if c.NArg() > 1 {
+			fmt.Println("Too many arguments")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		tenant, err := client.New().Tenant.Inspect(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "inspection of tenant", false).Error()))
+		}
+		out, _ := json.Marshal(toDisplayableTenant(tenant))
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+type tenantCapabilitiesDisplayable struct {
+	VolumeSpeeds      []string
+	GPU               bool
+	FloatingIP        string
+	MaxVolumesPerHost int32
+	PrivateNetworks   bool
+	ObjectStorage     string
+}
+
+type tenantDisplayable struct {
+	Name         string
+	Provider     string
+	Capabilities tenantCapabilitiesDisplayable
+}
+
+func toDisplayableTenant(tenant *pb.TenantInspectResponse) *tenantDisplayable {
+	capabilities := tenant.GetCapabilities()
+	speeds := []string{}
+	for _, speed := range capabilities.GetVolumeSpeeds() {
+		speeds = append(speeds, pb.VolumeSpeed_name[int32(speed)])
+	}
+	return &tenantDisplayable{
+		tenant.GetName(),
+		tenant.GetProvider(),
+		tenantCapabilitiesDisplayable{
+			speeds,
+			capabilities.GetGPU(),
+			pb.FloatingIPModel_name[int32(capabilities.GetFloatingIP())],
+			capabilities.GetMaxVolumesPerHost(),
+			capabilities.GetPrivateNetworks(),
+			capabilities.GetObjectStorage(),
+		},
+	}
+}
@@ -42,6 +42,7 @@ var VolumeCmd = cli.Command{
 		volumeCreate,
 		volumeAttach,
 		volumeDetach,
+		volumeImport,
 	},
 }
 
@@ -92,6 +93,27 @@ var volumeInspect = cli.Command{
 	},
 }
 
+var volumeImport = cli.Command{
+	Name:      "import",
+	Usage:     "Import a volume created outside SafeScale",
+	ArgsUsage: "<Volume_name|Volume_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Missing mandatory argument <Volume_name|Volume_ID>")
+			_ = cli.ShowSubcommandHelp(c)
+			return clitools.ExitOnInvalidArgument()
+		}
+		volume, err := client.New().Volume.Import(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return clitools.ExitOnRPC(utils.TitleFirst(client.DecorateError(err, "import of volume", false).Error()))
+		}
+
+		out, _ := json.Marshal(toDisplaybleVolume(volume))
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
 var volumeDelete = cli.Command{
 	Name:      "delete",
 	Aliases:   []string{"rm", "remove"},
@@ -18,13 +18,16 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	cli "github.com/urfave/cli"
 
 	"github.com/CS-SI/SafeScale/broker/cli/broker/cmd"
+	"github.com/CS-SI/SafeScale/broker/client"
 	"github.com/CS-SI/SafeScale/broker/utils"
 )
 
@@ -57,6 +60,34 @@ func main() {
 			Name:  "debug, d",
 			Usage: "Show debug information",
 		},
+		cli.StringFlag{
+			Name:  "tenant, T",
+			Usage: "Use tenant `TENANT` instead of the one selected with 'tenant set'",
+		},
+		cli.StringFlag{
+			Name:   "brokerd",
+			Usage:  "Connect to brokerd at `ADDRESS` (host:port or unix:<path of socket>)",
+			EnvVar: client.BrokerdAddressEnvVar,
+		},
+		cli.StringFlag{
+			Name:   "tls-ca",
+			Usage:  "Verify brokerd with the CA certificate in `FILE` (enables TLS)",
+			EnvVar: client.BrokerdCAEnvVar,
+		},
+		cli.StringFlag{
+			Name:   "tls-cert",
+			Usage:  "Authenticate to brokerd with the client certificate in `FILE` (mutual TLS)",
+			EnvVar: client.BrokerdCertEnvVar,
+		},
+		cli.StringFlag{
+			Name:   "tls-key",
+			Usage:  "Use the client key in `FILE` (mutual TLS)",
+			EnvVar: client.BrokerdKeyEnvVar,
+		},
+		cli.StringFlag{
+			Name:  "token-file",
+			Usage: "Authenticate to brokerd with the token contained in `FILE` (or environment variable " + client.BrokerdTokenEnvVar + ")",
+		},
 		// cli.IntFlag{
 		// 	Name:  "port, p",
 		// 	Usage: "Bind to specified port `PORT`",
@@ -74,6 +105,28 @@ func main() {
 			log.SetLevel(log.DebugLevel)
 			utils.Debug = true
 		}
+		if tenant := c.GlobalString("tenant"); tenant != "" {
+			_ = os.Setenv(client.TenantEnvVar, tenant)
+		}
+
+		// The broker client reads its connection parameters from the environment
+		for flag, envVar := range map[string]string{
+			"brokerd":  client.BrokerdAddressEnvVar,
+			"tls-ca":   client.BrokerdCAEnvVar,
+			"tls-cert": client.BrokerdCertEnvVar,
+			"tls-key":  client.BrokerdKeyEnvVar,
+		} {
+			if value := c.GlobalString(flag); value != "" {
+				_ = os.Setenv(envVar, value)
+			}
+		}
+		if tokenFile := c.GlobalString("token-file"); tokenFile != "" {
+			content, err := ioutil.ReadFile(tokenFile)
+			if err != nil {
+				return fmt.Errorf("failed to read token file: %s", err.Error())
+			}
+			_ = os.Setenv(client.BrokerdTokenEnvVar, strings.TrimSpace(string(content)))
+		}
 		return nil
 	}
 
@@ -89,6 +142,9 @@ func main() {
 	app.Commands = append(app.Commands, cmd.VolumeCmd)
 	sort.Sort(cli.CommandsByName(cmd.VolumeCmd.Subcommands))
 
+	app.Commands = append(app.Commands, cmd.SecurityGroupCmd)
+	sort.Sort(cli.CommandsByName(cmd.SecurityGroupCmd.Subcommands))
+
 	app.Commands = append(app.Commands, cmd.SSHCmd)
 	sort.Sort(cli.CommandsByName(cmd.SSHCmd.Subcommands))
 
@@ -104,6 +160,12 @@ func main() {
 	app.Commands = append(app.Commands, cmd.TemplateCmd)
 	sort.Sort(cli.CommandsByName(cmd.TemplateCmd.Subcommands))
 
+	app.Commands = append(app.Commands, cmd.OperationCmd)
+	sort.Sort(cli.CommandsByName(cmd.OperationCmd.Subcommands))
+
+	app.Commands = append(app.Commands, cmd.MetadataCmd)
+	sort.Sort(cli.CommandsByName(cmd.MetadataCmd.Subcommands))
+
 	sort.Sort(cli.CommandsByName(app.Commands))
 	err := app.Run(os.Args)
 	if err != nil {
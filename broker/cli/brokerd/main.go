@@ -17,11 +17,14 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"net"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"path"
+	"path/filepath"
 	"strings"
 	"syscall"
 
@@ -32,6 +35,7 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/client"
 	"github.com/CS-SI/SafeScale/broker/server/listeners"
 	"github.com/CS-SI/SafeScale/broker/utils"
 	"github.com/CS-SI/SafeScale/providers"
@@ -84,12 +88,94 @@ broker share|nas inspect nas1
 
 */
 
+// inProcessDir is the private directory of the socket through which brokerd calls itself
+var inProcessDir string
+
 func cleanup() {
 	fmt.Println("cleanup")
+	if inProcessDir != "" {
+		_ = os.RemoveAll(inProcessDir)
+	}
+}
+
+// serverConfig builds the configuration of the grpc server from the command line
+func serverConfig(c *cli.Context) (utils.ServerConfig, error) {
+	config := utils.ServerConfig{
+		Address:      c.String("listen"),
+		CertFile:     c.String("tls-cert"),
+		KeyFile:      c.String("tls-key"),
+		ClientCAFile: c.String("tls-client-ca"),
+		Token:        os.Getenv("SAFESCALE_BROKERD_TOKEN"),
+	}
+	if tokenFile := c.String("token-file"); tokenFile != "" {
+		content, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return config, fmt.Errorf("failed to read token file: %s", err.Error())
+		}
+		config.Token = strings.TrimSpace(string(content))
+		if config.Token == "" {
+			return config, fmt.Errorf("token file '%s' is empty", tokenFile)
+		}
+	}
+	return config, nil
+}
+
+// registerServices registers the services of brokerd on the grpc server
+func registerServices(s *grpc.Server) {
+	pb.RegisterTenantServiceServer(s, &listeners.TenantServiceListener{})
+	pb.RegisterNetworkServiceServer(s, &listeners.NetworkServiceListener{})
+	pb.RegisterHostServiceServer(s, &listeners.HostServiceListener{})
+	pb.RegisterVolumeServiceServer(s, &listeners.VolumeServiceListener{})
+	pb.RegisterSshServiceServer(s, &listeners.SSHServiceListener{})
+	pb.RegisterBucketServiceServer(s, &listeners.BucketServiceListener{})
+	pb.RegisterShareServiceServer(s, &listeners.ShareServiceListener{})
+	pb.RegisterFirewallServiceServer(s, &listeners.FirewallServiceListener{})
+	pb.RegisterSecurityGroupServiceServer(s, &listeners.SecurityGroupServiceListener{})
+	pb.RegisterImageServiceServer(s, &listeners.ImageServiceListener{})
+	pb.RegisterTemplateServiceServer(s, &listeners.TemplateServiceListener{})
+	pb.RegisterOperationServiceServer(s, &listeners.OperationServiceListener{})
+	pb.RegisterMetadataServiceServer(s, &listeners.MetadataServiceListener{})
+	pb.RegisterClusterServiceServer(s, &listeners.ClusterServiceListener{})
+}
+
+// serveInProcess serves brokerd on a unix socket in a private directory, protected by a token generated at each
+// start; the cluster creations run by brokerd go through it to create their networks and hosts
+func serveInProcess() error {
+	dir, err := ioutil.TempDir("", "brokerd")
+	if err != nil {
+		return err
+	}
+	inProcessDir = dir
+	token := make([]byte, 32)
+	_, err = rand.Read(token)
+	if err != nil {
+		return err
+	}
+	config := utils.ServerConfig{
+		Address: "unix:" + filepath.Join(dir, "brokerd.sock"),
+		Token:   hex.EncodeToString(token),
+	}
+	opts, err := config.ServerOptions()
+	if err != nil {
+		return err
+	}
+	lis, err := config.Listen()
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer(opts...)
+	registerServices(s)
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			log.Errorf("Failed to serve in process: %v", err)
+		}
+	}()
+	client.UseBrokerd(utils.ConnectionConfig{Address: config.Address, Token: config.Token})
+	return nil
 }
 
 // *** MAIN ***
-func work() {
+func work(config utils.ServerConfig) {
 	c := make(chan os.Signal)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
@@ -105,23 +191,26 @@ func work() {
 	}
 
 	log.Infoln("Starting server")
-	//lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	lis, err := net.Listen("tcp", ":50051")
+	opts, err := config.ServerOptions()
+	if err != nil {
+		log.Fatalf("Invalid security configuration: %v", err)
+	}
+	if !config.IsLoopback() && (config.Token == "" || config.CertFile == "") {
+		log.Warnf("brokerd listens on '%s' without both TLS and token authentication; anyone able to reach it controls the tenants", config.Address)
+	}
+	lis, err := config.Listen()
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
+	s := grpc.NewServer(opts...)
 
 	log.Infoln("Registering services")
-	pb.RegisterTenantServiceServer(s, &listeners.TenantServiceListener{})
-	pb.RegisterNetworkServiceServer(s, &listeners.NetworkServiceListener{})
-	pb.RegisterHostServiceServer(s, &listeners.HostServiceListener{})
-	pb.RegisterVolumeServiceServer(s, &listeners.VolumeServiceListener{})
-	pb.RegisterSshServiceServer(s, &listeners.SSHServiceListener{})
-	pb.RegisterBucketServiceServer(s, &listeners.BucketServiceListener{})
-	pb.RegisterShareServiceServer(s, &listeners.ShareServiceListener{})
-	pb.RegisterImageServiceServer(s, &listeners.ImageServiceListener{})
-	pb.RegisterTemplateServiceServer(s, &listeners.TemplateServiceListener{})
+	registerServices(s)
+
+	err = serveInProcess()
+	if err != nil {
+		log.Fatalf("failed to listen in process: %v", err)
+	}
 
 	// log.Println("Initializing service factory")
 	// commands.InitServiceFactory()
@@ -153,11 +242,27 @@ func main() {
 			Name:  "debug, d",
 			Usage: "Show debug information",
 		},
-		// cli.IntFlag{
-		// 	Name:  "port, p",
-		// 	Usage: "Bind to specified port `PORT`",
-		// 	Value: 50051,
-		// },
+		cli.StringFlag{
+			Name:  "listen, l",
+			Usage: "Listen on `ADDRESS` ([host]:port or unix:<path of socket>)",
+			Value: utils.DefaultBrokerdAddress,
+		},
+		cli.StringFlag{
+			Name:  "tls-cert",
+			Usage: "Serve TLS with the certificate in `FILE`",
+		},
+		cli.StringFlag{
+			Name:  "tls-key",
+			Usage: "Serve TLS with the key in `FILE`",
+		},
+		cli.StringFlag{
+			Name:  "tls-client-ca",
+			Usage: "Require client certificates signed by the CA certificate in `FILE` (mutual TLS)",
+		},
+		cli.StringFlag{
+			Name:  "token-file",
+			Usage: "Require the token contained in `FILE` (or environment variable SAFESCALE_BROKERD_TOKEN) in each request",
+		},
 	}
 
 	app.Before = func(c *cli.Context) error {
@@ -180,7 +285,11 @@ func main() {
 	}
 
 	app.Action = func(c *cli.Context) error {
-		work()
+		config, err := serverConfig(c)
+		if err != nil {
+			return err
+		}
+		work(config)
 		return nil
 	}
 
@@ -17,7 +17,6 @@
 package client
 
 import (
-	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -38,7 +37,7 @@ func (c *bucket) List(timeout time.Duration) (*pb.BucketList, error) {
 	c.session.Connect()
 	defer c.session.Disconnect()
 	service := pb.NewBucketServiceClient(c.session.connection)
-	ctx := context.Background()
+	ctx := c.session.getContext()
 
 	return service.List(ctx, &google_protobuf.Empty{})
 }
@@ -48,7 +47,7 @@ func (c *bucket) Create(name string, timeout time.Duration) error {
 	c.session.Connect()
 	defer c.session.Disconnect()
 	service := pb.NewBucketServiceClient(c.session.connection)
-	ctx := context.Background()
+	ctx := c.session.getContext()
 
 	_, err := service.Create(ctx, &pb.Bucket{Name: name})
 	return err
@@ -59,7 +58,7 @@ func (c *bucket) Delete(names []string, timeout time.Duration) error {
 	c.session.Connect()
 	defer c.session.Disconnect()
 	service := pb.NewBucketServiceClient(c.session.connection)
-	ctx := context.Background()
+	ctx := c.session.getContext()
 
 	var (
 		wg   sync.WaitGroup
@@ -94,7 +93,7 @@ func (c *bucket) Inspect(name string, timeout time.Duration) (*pb.BucketMounting
 	c.session.Connect()
 	defer c.session.Disconnect()
 	service := pb.NewBucketServiceClient(c.session.connection)
-	ctx := context.Background()
+	ctx := c.session.getContext()
 
 	return service.Inspect(ctx, &pb.Bucket{Name: name})
 }
@@ -104,7 +103,7 @@ func (c *bucket) Mount(bucketName, hostName, mountPoint string, timeout time.Dur
 	c.session.Connect()
 	defer c.session.Disconnect()
 	service := pb.NewBucketServiceClient(c.session.connection)
-	ctx := context.Background()
+	ctx := c.session.getContext()
 
 	_, err := service.Mount(ctx, &pb.BucketMountingPoint{
 		Bucket: bucketName,
@@ -121,7 +120,7 @@ func (c *bucket) Unmount(bucketName, hostName string, timeout time.Duration) err
 	c.session.Connect()
 	defer c.session.Disconnect()
 	service := pb.NewBucketServiceClient(c.session.connection)
-	ctx := context.Background()
+	ctx := c.session.getContext()
 
 	_, err := service.Unmount(ctx, &pb.BucketMountingPoint{
 		Bucket: bucketName,
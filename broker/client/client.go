@@ -17,8 +17,11 @@
 package client
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -32,19 +35,23 @@ import (
 
 // Session units the different resources proposed by brokerd as broker client
 type Session struct {
-	Bucket   *bucket
-	Host     *host
-	Share    *share
-	Network  *network
-	Ssh      *ssh
-	Tenant   *tenant
-	Volume   *volume
-	Template *template
-	Image    *image
-
-	brokerdHost string
-	brokerdPort int
-	connection  *grpc.ClientConn
+	Bucket        *bucket
+	Cluster       *cluster
+	Host          *host
+	Share         *share
+	Firewall      *firewall
+	Network       *network
+	SecurityGroup *securityGroup
+	Ssh           *ssh
+	Tenant        *tenant
+	Volume        *volume
+	Template      *template
+	Image         *image
+	Operation     *operation
+	Metadata      *metadata
+
+	brokerd    utils.ConnectionConfig
+	connection *grpc.ClientConn
 
 	tenantName string
 }
@@ -58,29 +65,94 @@ const (
 	DefaultExecutionTimeout  = 5 * time.Minute
 )
 
+// Environment variables used to configure the connection to brokerd
+const (
+	// BrokerdAddressEnvVar contains the address of brokerd, either "host:port" or "unix:<path of socket>"
+	BrokerdAddressEnvVar = "SAFESCALE_BROKERD"
+	// BrokerdCAEnvVar contains the path of the CA certificate used to verify brokerd; enables TLS
+	BrokerdCAEnvVar = "SAFESCALE_BROKERD_CA"
+	// BrokerdCertEnvVar contains the path of the client certificate used for mutual TLS
+	BrokerdCertEnvVar = "SAFESCALE_BROKERD_CERT"
+	// BrokerdKeyEnvVar contains the path of the client key used for mutual TLS
+	BrokerdKeyEnvVar = "SAFESCALE_BROKERD_KEY"
+	// BrokerdTokenEnvVar contains the token used to authenticate to brokerd
+	BrokerdTokenEnvVar = "SAFESCALE_BROKERD_TOKEN"
+)
+
+// connectionConfig returns the parameters of connection to brokerd found in the environment
+func connectionConfig() utils.ConnectionConfig {
+	address := strings.TrimSpace(os.Getenv(BrokerdAddressEnvVar))
+	if address == "" {
+		address = utils.DefaultBrokerdAddress
+	}
+	return utils.ConnectionConfig{
+		Address:  address,
+		CAFile:   os.Getenv(BrokerdCAEnvVar),
+		CertFile: os.Getenv(BrokerdCertEnvVar),
+		KeyFile:  os.Getenv(BrokerdKeyEnvVar),
+		Token:    os.Getenv(BrokerdTokenEnvVar),
+	}
+}
+
+// inProcess holds the connection and the tenant set by UseBrokerd and UseTenant
+var inProcess struct {
+	sync.Mutex
+	brokerd    *utils.ConnectionConfig
+	tenantName string
+}
+
+// UseBrokerd sets the connection used by the sessions created afterwards in the process, in place of the one
+// of the environment; brokerd uses it to call itself while running a cluster creation
+func UseBrokerd(brokerd utils.ConnectionConfig) {
+	inProcess.Lock()
+	defer inProcess.Unlock()
+	inProcess.brokerd = &brokerd
+}
+
+// UseTenant sets the tenant used by the sessions created afterwards in the process, in place of the one of the
+// environment; "" restores the tenant of the environment
+func UseTenant(name string) {
+	inProcess.Lock()
+	defer inProcess.Unlock()
+	inProcess.tenantName = name
+}
+
 // New returns an instance of broker Client
 func New() Client {
 	s := &Session{
-		brokerdHost: "localhost",
-		brokerdPort: 50051,
+		brokerd:    connectionConfig(),
+		tenantName: currentTenantName(),
 	}
+	inProcess.Lock()
+	if inProcess.brokerd != nil {
+		s.brokerd = *inProcess.brokerd
+	}
+	if inProcess.tenantName != "" {
+		s.tenantName = inProcess.tenantName
+	}
+	inProcess.Unlock()
 
 	s.Bucket = &bucket{session: s}
+	s.Cluster = &cluster{session: s}
 	s.Host = &host{session: s}
 	s.Share = &share{session: s}
+	s.Firewall = &firewall{session: s}
 	s.Network = &network{session: s}
+	s.SecurityGroup = &securityGroup{session: s}
 	s.Ssh = &ssh{session: s}
 	s.Tenant = &tenant{session: s}
 	s.Volume = &volume{session: s}
 	s.Template = &template{session: s}
 	s.Image = &image{session: s}
+	s.Operation = &operation{session: s}
+	s.Metadata = &metadata{session: s}
 	return s
 }
 
 // Connect establishes connection with brokerd
 func (s *Session) Connect() {
 	if s.connection == nil {
-		s.connection = utils.GetConnection(s.brokerdHost, s.brokerdPort)
+		s.connection = utils.GetConnection(s.brokerd)
 	}
 }
 
@@ -92,6 +164,11 @@ func (s *Session) Disconnect() {
 	}
 }
 
+// getContext returns a context carrying the tenant the session works with
+func (s *Session) getContext() context.Context {
+	return utils.WithTenant(context.Background(), s.tenantName)
+}
+
 // DecorateError changes the error to something more comprehensible when
 // timeout occured
 func DecorateError(err error, action string, maySucceed bool) error {
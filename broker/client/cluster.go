@@ -0,0 +1,39 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+)
+
+// cluster is the part of broker client handling clusters
+type cluster struct {
+	// session is not used currently
+	session *Session
+}
+
+// CreateAsync starts the creation of a cluster and returns the operation tracking it
+func (c *cluster) CreateAsync(def pb.ClusterDefinition, timeout time.Duration) (*pb.Operation, error) {
+	c.session.Connect()
+	defer c.session.Disconnect()
+	service := pb.NewClusterServiceClient(c.session.connection)
+	ctx := c.session.getContext()
+
+	return service.CreateAsync(ctx, &def)
+}
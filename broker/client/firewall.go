@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+)
+
+// firewall is the part of the broker client handling the firewalls of the hosts
+type firewall struct {
+	session *Session
+}
+
+// List returns the firewall rules of a host, of all its tables if table is empty
+func (f *firewall) List(host string, table string, timeout time.Duration) (*pb.Firewall, error) {
+	f.session.Connect()
+	defer f.session.Disconnect()
+	service := pb.NewFirewallServiceClient(f.session.connection)
+	ctx := f.session.getContext()
+
+	return service.List(ctx, &pb.FirewallListRequest{Host: &pb.Reference{Name: host}, Table: table})
+}
+
+// Add adds a rule to the firewall of a host
+func (f *firewall) Add(def pb.FirewallRuleDefinition, timeout time.Duration) error {
+	f.session.Connect()
+	defer f.session.Disconnect()
+	service := pb.NewFirewallServiceClient(f.session.connection)
+	ctx := f.session.getContext()
+
+	_, err := service.Add(ctx, &def)
+	return err
+}
+
+// Remove removes a rule from the firewall of a host
+func (f *firewall) Remove(def pb.FirewallRuleDefinition, timeout time.Duration) error {
+	f.session.Connect()
+	defer f.session.Disconnect()
+	service := pb.NewFirewallServiceClient(f.session.connection)
+	ctx := f.session.getContext()
+
+	_, err := service.Remove(ctx, &def)
+	return err
+}
+
+// Apply replaces the rules of the tables of fw on a host
+func (f *firewall) Apply(fw pb.Firewall, timeout time.Duration) error {
+	f.session.Connect()
+	defer f.session.Disconnect()
+	service := pb.NewFirewallServiceClient(f.session.connection)
+	ctx := f.session.getContext()
+
+	_, err := service.Apply(ctx, &fw)
+	return err
+}
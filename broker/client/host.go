@@ -17,7 +17,6 @@
 package client
 
 import (
-	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -42,7 +41,7 @@ func (h *host) List(all bool, timeout time.Duration) (*pb.HostList, error) {
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	return service.List(ctx, &pb.HostListRequest{All: all})
 }
@@ -52,18 +51,39 @@ func (h *host) Inspect(name string, timeout time.Duration) (*pb.Host, error) {
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	return service.Inspect(ctx, &pb.Reference{Name: name})
 
 }
 
+// Import creates the metadata of a host created outside SafeScale
+func (h *host) Import(name string, privateKey string, timeout time.Duration) (*pb.Host, error) {
+	h.session.Connect()
+	defer h.session.Disconnect()
+	service := pb.NewHostServiceClient(h.session.connection)
+	ctx := h.session.getContext()
+
+	return service.Import(ctx, &pb.HostImportRequest{Name: name, PrivateKey: privateKey})
+}
+
+// Rekey records the SSH host key currently presented by the host; if fingerprint is not empty, the key is
+// accepted only if its fingerprint matches
+func (h *host) Rekey(name string, fingerprint string, timeout time.Duration) (*pb.HostKey, error) {
+	h.session.Connect()
+	defer h.session.Disconnect()
+	service := pb.NewHostServiceClient(h.session.connection)
+	ctx := h.session.getContext()
+
+	return service.Rekey(ctx, &pb.HostRekeyRequest{Name: name, Fingerprint: fingerprint})
+}
+
 // Get host status
 func (h *host) Status(name string, timeout time.Duration) (*pb.HostStatus, error) {
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	return service.Status(ctx, &pb.Reference{Name: name})
 }
@@ -73,7 +93,7 @@ func (h *host) Reboot(name string, timeout time.Duration) error {
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	_, err := service.Reboot(ctx, &pb.Reference{Name: name})
 	return err
@@ -84,7 +104,7 @@ func (h *host) Start(name string, timeout time.Duration) error {
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	_, err := service.Start(ctx, &pb.Reference{Name: name})
 	return err
@@ -94,7 +114,7 @@ func (h *host) Stop(name string, timeout time.Duration) error {
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	_, err := service.Stop(ctx, &pb.Reference{Name: name})
 	return err
@@ -105,17 +125,27 @@ func (h *host) Create(def pb.HostDefinition, timeout time.Duration) (*pb.Host, e
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	return service.Create(ctx, &def)
 }
 
+// CreateAsync starts the creation of a host and returns the operation tracking it
+func (h *host) CreateAsync(def pb.HostDefinition, timeout time.Duration) (*pb.Operation, error) {
+	h.session.Connect()
+	defer h.session.Disconnect()
+	service := pb.NewHostServiceClient(h.session.connection)
+	ctx := h.session.getContext()
+
+	return service.CreateAsync(ctx, &def)
+}
+
 // Delete deletes several hosts at the same time in goroutines
 func (h *host) Delete(names []string, timeout time.Duration) error {
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	var (
 		wg   sync.WaitGroup
@@ -154,7 +184,7 @@ func (h *host) SSHConfig(name string) (*system.SSHConfig, error) {
 	h.session.Connect()
 	defer h.session.Disconnect()
 	service := pb.NewHostServiceClient(h.session.connection)
-	ctx := context.Background()
+	ctx := h.session.getContext()
 
 	pbSSHCfg, err := service.SSH(ctx, &pb.Reference{Name: name})
 	sshCfg := conv.ToSystemSshConfig(pbSSHCfg)
@@ -17,7 +17,6 @@
 package client
 
 import (
-	"context"
 	"time"
 
 	pb "github.com/CS-SI/SafeScale/broker"
@@ -34,7 +33,7 @@ func (img *image) List(all bool, timeout time.Duration) (*pb.ImageList, error) {
 	img.session.Connect()
 	defer img.session.Disconnect()
 	service := pb.NewImageServiceClient(img.session.connection)
-	ctx := context.Background()
+	ctx := img.session.getContext()
 
 	return service.List(ctx, &pb.ImageListRequest{All: all})
 }
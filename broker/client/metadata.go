@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+)
+
+// metadata is the part of broker client handling the maintenance of metadata
+type metadata struct {
+	// session is not used currently
+	session *Session
+}
+
+// ListLocks ...
+func (m *metadata) ListLocks(timeout time.Duration) (*pb.MetadataLockList, error) {
+	m.session.Connect()
+	defer m.session.Disconnect()
+	service := pb.NewMetadataServiceClient(m.session.connection)
+	ctx := m.session.getContext()
+
+	return service.ListLocks(ctx, &google_protobuf.Empty{})
+}
+
+// BreakLock ...
+func (m *metadata) BreakLock(key string, force bool, timeout time.Duration) error {
+	m.session.Connect()
+	defer m.session.Disconnect()
+	service := pb.NewMetadataServiceClient(m.session.connection)
+	ctx := m.session.getContext()
+
+	_, err := service.BreakLock(ctx, &pb.MetadataLockBreakRequest{Key: key, Force: force})
+	return err
+}
+
+// History ...
+func (m *metadata) History(kind string, name string, timeout time.Duration) (*pb.MetadataRevisionList, error) {
+	m.session.Connect()
+	defer m.session.Disconnect()
+	service := pb.NewMetadataServiceClient(m.session.connection)
+	ctx := m.session.getContext()
+
+	return service.History(ctx, &pb.MetadataHistoryRequest{Kind: kind, Name: name})
+}
+
+// Restore ...
+func (m *metadata) Restore(kind string, name string, revision string, timeout time.Duration) error {
+	m.session.Connect()
+	defer m.session.Disconnect()
+	service := pb.NewMetadataServiceClient(m.session.connection)
+	ctx := m.session.getContext()
+
+	_, err := service.Restore(ctx, &pb.MetadataRestoreRequest{Kind: kind, Name: name, RevisionID: revision})
+	return err
+}
+
+// Rekey ...
+func (m *metadata) Rekey(fromPlaintext bool, dryRun bool, timeout time.Duration) (*pb.MetadataRekeyReport, error) {
+	m.session.Connect()
+	defer m.session.Disconnect()
+	service := pb.NewMetadataServiceClient(m.session.connection)
+	ctx := m.session.getContext()
+
+	return service.Rekey(ctx, &pb.MetadataRekeyRequest{FromPlaintext: fromPlaintext, DryRun: dryRun})
+}
+
+// Fsck ...
+func (m *metadata) Fsck(deleteDangling bool, importUntracked bool, timeout time.Duration) (*pb.MetadataDriftList, error) {
+	m.session.Connect()
+	defer m.session.Disconnect()
+	service := pb.NewMetadataServiceClient(m.session.connection)
+	ctx := m.session.getContext()
+
+	return service.Fsck(ctx, &pb.MetadataFsckRequest{DeleteDangling: deleteDangling, ImportUntracked: importUntracked})
+}
@@ -17,7 +17,6 @@
 package client
 
 import (
-	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -37,7 +36,7 @@ func (n *network) List(all bool, timeout time.Duration) (*pb.NetworkList, error)
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewNetworkServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	return service.List(ctx, &pb.NWListRequest{
 		All: all,
@@ -49,7 +48,7 @@ func (n *network) Delete(names []string, timeout time.Duration) error {
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewNetworkServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	var (
 		wg   sync.WaitGroup
@@ -86,19 +85,39 @@ func (n *network) Inspect(name string, timeout time.Duration) (*pb.Network, erro
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewNetworkServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	return service.Inspect(ctx, &pb.Reference{Name: name})
 
 }
 
+// Import creates the metadata of a network created outside SafeScale
+func (n *network) Import(name string, gateway string, timeout time.Duration) (*pb.Network, error) {
+	n.session.Connect()
+	defer n.session.Disconnect()
+	service := pb.NewNetworkServiceClient(n.session.connection)
+	ctx := n.session.getContext()
+
+	return service.Import(ctx, &pb.NetworkImportRequest{Name: name, Gateway: gateway})
+}
+
 // Create ...
 func (n *network) Create(def pb.NetworkDefinition, timeout time.Duration) (*pb.Network, error) {
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewNetworkServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	return service.Create(ctx, &def)
 
 }
+
+// CreateAsync starts the creation of a network and returns the operation tracking it
+func (n *network) CreateAsync(def pb.NetworkDefinition, timeout time.Duration) (*pb.Operation, error) {
+	n.session.Connect()
+	defer n.session.Disconnect()
+	service := pb.NewNetworkServiceClient(n.session.connection)
+	ctx := n.session.getContext()
+
+	return service.CreateAsync(ctx, &def)
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+)
+
+// operation is the part of broker client handling long running operations
+type operation struct {
+	// session is not used currently
+	session *Session
+}
+
+// List ...
+func (o *operation) List(timeout time.Duration) (*pb.OperationList, error) {
+	o.session.Connect()
+	defer o.session.Disconnect()
+	service := pb.NewOperationServiceClient(o.session.connection)
+	ctx := o.session.getContext()
+
+	return service.List(ctx, &google_protobuf.Empty{})
+}
+
+// Inspect ...
+func (o *operation) Inspect(id string, timeout time.Duration) (*pb.Operation, error) {
+	o.session.Connect()
+	defer o.session.Disconnect()
+	service := pb.NewOperationServiceClient(o.session.connection)
+	ctx := o.session.getContext()
+
+	return service.Inspect(ctx, &pb.Reference{ID: id})
+}
+
+// Cancel requests the operation to stop and roll back
+func (o *operation) Cancel(id string, timeout time.Duration) error {
+	o.session.Connect()
+	defer o.session.Disconnect()
+	service := pb.NewOperationServiceClient(o.session.connection)
+	ctx := o.session.getContext()
+
+	_, err := service.Cancel(ctx, &pb.Reference{ID: id})
+	return err
+}
+
+// Watch calls callback on each event of the operation until it ends, then returns its final state
+func (o *operation) Watch(id string, callback func(*pb.OperationEvent)) (*pb.Operation, error) {
+	o.session.Connect()
+	defer o.session.Disconnect()
+	service := pb.NewOperationServiceClient(o.session.connection)
+	ctx := o.session.getContext()
+
+	stream, err := service.Watch(ctx, &pb.Reference{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if callback != nil {
+			callback(event)
+		}
+	}
+	return service.Inspect(ctx, &pb.Reference{ID: id})
+}
+
+// Wait watches the operation until it ends and returns an error if it did not succeed
+func (o *operation) Wait(id string, callback func(*pb.OperationEvent)) (*pb.Operation, error) {
+	op, err := o.Watch(id, callback)
+	if err != nil {
+		return nil, err
+	}
+	switch op.GetState() {
+	case pb.OperationState_SUCCEEDED:
+		return op, nil
+	case pb.OperationState_CANCELLED:
+		return op, fmt.Errorf("%s '%s' cancelled", op.GetKind(), op.GetTarget())
+	default:
+		return op, fmt.Errorf("%s '%s' failed: %s", op.GetKind(), op.GetTarget(), op.GetError())
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package client
+
+import (
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+)
+
+// securityGroup is the part of the broker client handling security groups
+type securityGroup struct {
+	session *Session
+}
+
+// Create creates a security group, dedicated to a network if network isn't empty
+func (sg *securityGroup) Create(def pb.SecurityGroupDefinition, timeout time.Duration) (*pb.SecurityGroup, error) {
+	sg.session.Connect()
+	defer sg.session.Disconnect()
+	service := pb.NewSecurityGroupServiceClient(sg.session.connection)
+	ctx := sg.session.getContext()
+
+	return service.Create(ctx, &def)
+}
+
+// List returns the security groups managed by SafeScale, or all the security groups of the tenant if all is true
+func (sg *securityGroup) List(all bool, timeout time.Duration) (*pb.SecurityGroupList, error) {
+	sg.session.Connect()
+	defer sg.session.Disconnect()
+	service := pb.NewSecurityGroupServiceClient(sg.session.connection)
+	ctx := sg.session.getContext()
+
+	return service.List(ctx, &pb.SecurityGroupListRequest{All: all})
+}
+
+// Inspect returns the security group referenced by ref, with its rules
+func (sg *securityGroup) Inspect(ref string, timeout time.Duration) (*pb.SecurityGroup, error) {
+	sg.session.Connect()
+	defer sg.session.Disconnect()
+	service := pb.NewSecurityGroupServiceClient(sg.session.connection)
+	ctx := sg.session.getContext()
+
+	return service.Inspect(ctx, &pb.Reference{Name: ref})
+}
+
+// Delete deletes the security group referenced by ref
+func (sg *securityGroup) Delete(ref string, timeout time.Duration) error {
+	sg.session.Connect()
+	defer sg.session.Disconnect()
+	service := pb.NewSecurityGroupServiceClient(sg.session.connection)
+	ctx := sg.session.getContext()
+
+	_, err := service.Delete(ctx, &pb.Reference{Name: ref})
+	return err
+}
+
+// AddRule adds a rule to the security group referenced by ref
+func (sg *securityGroup) AddRule(ref string, rule pb.SecurityGroupRule, timeout time.Duration) (*pb.SecurityGroupRule, error) {
+	sg.session.Connect()
+	defer sg.session.Disconnect()
+	service := pb.NewSecurityGroupServiceClient(sg.session.connection)
+	ctx := sg.session.getContext()
+
+	return service.AddRule(ctx, &pb.SecurityGroupRuleDefinition{
+		SecurityGroup: &pb.Reference{Name: ref},
+		Rule:          &rule,
+	})
+}
+
+// DeleteRule deletes the rule identified by ruleID from the security group referenced by ref
+func (sg *securityGroup) DeleteRule(ref string, ruleID string, timeout time.Duration) error {
+	sg.session.Connect()
+	defer sg.session.Disconnect()
+	service := pb.NewSecurityGroupServiceClient(sg.session.connection)
+	ctx := sg.session.getContext()
+
+	_, err := service.DeleteRule(ctx, &pb.SecurityGroupRuleDefinition{
+		SecurityGroup: &pb.Reference{Name: ref},
+		Rule:          &pb.SecurityGroupRule{ID: ruleID},
+	})
+	return err
+}
+
+// Bind binds the security group referenced by ref to a host
+func (sg *securityGroup) Bind(ref string, host string, timeout time.Duration) error {
+	sg.session.Connect()
+	defer sg.session.Disconnect()
+	service := pb.NewSecurityGroupServiceClient(sg.session.connection)
+	ctx := sg.session.getContext()
+
+	_, err := service.Bind(ctx, &pb.SecurityGroupBinding{
+		SecurityGroup: &pb.Reference{Name: ref},
+		Host:          &pb.Reference{Name: host},
+	})
+	return err
+}
+
+// Unbind unbinds the security group referenced by ref from a host
+func (sg *securityGroup) Unbind(ref string, host string, timeout time.Duration) error {
+	sg.session.Connect()
+	defer sg.session.Disconnect()
+	service := pb.NewSecurityGroupServiceClient(sg.session.connection)
+	ctx := sg.session.getContext()
+
+	_, err := service.Unbind(ctx, &pb.SecurityGroupBinding{
+		SecurityGroup: &pb.Reference{Name: ref},
+		Host:          &pb.Reference{Name: host},
+	})
+	return err
+}
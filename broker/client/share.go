@@ -17,7 +17,6 @@
 package client
 
 import (
-	"context"
 	"time"
 
 	pb "github.com/CS-SI/SafeScale/broker"
@@ -35,7 +34,7 @@ func (n *share) Create(def pb.ShareDefinition, timeout time.Duration) error {
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewShareServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	_, err := service.Create(ctx, &def)
 	if err != nil {
@@ -44,12 +43,26 @@ func (n *share) Create(def pb.ShareDefinition, timeout time.Duration) error {
 	return nil
 }
 
+// Update replaces the ACLs of a share
+func (n *share) Update(def pb.ShareDefinition, timeout time.Duration) (*pb.ShareDefinition, error) {
+	n.session.Connect()
+	defer n.session.Disconnect()
+	service := pb.NewShareServiceClient(n.session.connection)
+	ctx := n.session.getContext()
+
+	share, err := service.Update(ctx, &def)
+	if err != nil {
+		return nil, DecorateError(err, "update of share", true)
+	}
+	return share, nil
+}
+
 // Delete deletes a share
 func (n *share) Delete(name string, timeout time.Duration) error {
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewShareServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	_, err := service.Delete(ctx, &pb.Reference{Name: name})
 	if err != nil {
@@ -63,7 +76,7 @@ func (n *share) List(timeout time.Duration) (*pb.ShareList, error) {
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewShareServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	list, err := service.List(ctx, &google_protobuf.Empty{})
 	if err != nil {
@@ -77,7 +90,7 @@ func (n *share) Mount(def pb.ShareMountDefinition, timeout time.Duration) error
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewShareServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	_, err := service.Mount(ctx, &def)
 	if err != nil {
@@ -91,7 +104,7 @@ func (n *share) Unmount(def pb.ShareMountDefinition, timeout time.Duration) erro
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewShareServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	_, err := service.Unmount(ctx, &def)
 	if err != nil {
@@ -105,7 +118,7 @@ func (n *share) Inspect(name string, timeout time.Duration) (*pb.ShareMountList,
 	n.session.Connect()
 	defer n.session.Disconnect()
 	service := pb.NewShareServiceClient(n.session.connection)
-	ctx := context.Background()
+	ctx := n.session.getContext()
 
 	list, err := service.Inspect(ctx, &pb.Reference{Name: name})
 	if err != nil {
@@ -17,7 +17,6 @@
 package client
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"os/exec"
@@ -247,7 +246,7 @@ func (s *ssh) getSSHConfigFromName(name string, timeout time.Duration) (*system.
 	// defer conn.Close()
 	s.session.Connect()
 	defer s.session.Disconnect()
-	ctx := context.Background()
+	ctx := s.session.getContext()
 	service := pb.NewHostServiceClient(s.session.connection)
 
 	sshConfig, err := service.SSH(ctx, &pb.Reference{Name: name})
@@ -17,7 +17,6 @@
 package client
 
 import (
-	"context"
 	"time"
 
 	pb "github.com/CS-SI/SafeScale/broker"
@@ -34,7 +33,7 @@ func (t *template) List(all bool, timeout time.Duration) (*pb.TemplateList, erro
 	t.session.Connect()
 	defer t.session.Disconnect()
 	service := pb.NewTemplateServiceClient(t.session.connection)
-	ctx := context.Background()
+	ctx := t.session.getContext()
 	return service.List(ctx, &pb.TemplateListRequest{All: all})
 
 }
@@ -17,14 +17,48 @@
 package client
 
 import (
-	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/utils"
 
 	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 )
 
+const (
+	// TenantEnvVar is the environment variable overriding the tenant selected with 'broker tenant set'
+	TenantEnvVar = "SAFESCALE_TENANT"
+
+	tenantFile = "$HOME/.safescale/current-tenant"
+)
+
+// currentTenantName returns the tenant to send with each request: the content of the environment variable
+// SAFESCALE_TENANT if set, the tenant previously selected with 'broker tenant set' otherwise
+func currentTenantName() string {
+	if name := strings.TrimSpace(os.Getenv(TenantEnvVar)); name != "" {
+		return name
+	}
+	content, err := ioutil.ReadFile(utils.AbsPathify(tenantFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// saveTenantName records the tenant selected for the next sessions
+func saveTenantName(name string) error {
+	path := utils.AbsPathify(tenantFile)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(name+"\n"), 0600)
+}
+
 // tenant is the part of broker client handling tenants
 type tenant struct {
 	// session is not used currently
@@ -36,7 +70,7 @@ func (t *tenant) List(timeout time.Duration) (*pb.TenantList, error) {
 	t.session.Connect()
 	defer t.session.Disconnect()
 	service := pb.NewTenantServiceClient(t.session.connection)
-	ctx := context.Background()
+	ctx := t.session.getContext()
 
 	return service.List(ctx, &google_protobuf.Empty{})
 
@@ -47,18 +81,32 @@ func (t *tenant) Get(timeout time.Duration) (*pb.TenantName, error) {
 	t.session.Connect()
 	defer t.session.Disconnect()
 	service := pb.NewTenantServiceClient(t.session.connection)
-	ctx := context.Background()
+	ctx := t.session.getContext()
 
 	return service.Get(ctx, &google_protobuf.Empty{})
 }
 
-// Set ...
+// Inspect returns the provider and the capabilities of the tenant named 'name', of the current tenant if name is empty
+func (t *tenant) Inspect(name string, timeout time.Duration) (*pb.TenantInspectResponse, error) {
+	t.session.Connect()
+	defer t.session.Disconnect()
+	service := pb.NewTenantServiceClient(t.session.connection)
+	ctx := t.session.getContext()
+
+	return service.Inspect(ctx, &pb.TenantName{Name: name})
+}
+
+// Set checks the tenant is usable by brokerd and selects it for the next sessions of the current user
 func (t *tenant) Set(name string, timeout time.Duration) error {
 	t.session.Connect()
 	defer t.session.Disconnect()
 	service := pb.NewTenantServiceClient(t.session.connection)
-	ctx := context.Background()
+	ctx := t.session.getContext()
 
 	_, err := service.Set(ctx, &pb.TenantName{Name: name})
-	return err
+	if err != nil {
+		return err
+	}
+	t.session.tenantName = name
+	return saveTenantName(name)
 }
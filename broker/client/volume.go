@@ -17,7 +17,6 @@
 package client
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"sync"
@@ -38,7 +37,7 @@ func (v *volume) List(all bool, timeout time.Duration) (*pb.VolumeList, error) {
 	v.session.Connect()
 	defer v.session.Disconnect()
 	service := pb.NewVolumeServiceClient(v.session.connection)
-	ctx := context.Background()
+	ctx := v.session.getContext()
 
 	return service.List(ctx, &pb.VolumeListRequest{All: all})
 
@@ -49,18 +48,28 @@ func (v *volume) Inspect(name string, timeout time.Duration) (*pb.VolumeInfo, er
 	v.session.Connect()
 	defer v.session.Disconnect()
 	service := pb.NewVolumeServiceClient(v.session.connection)
-	ctx := context.Background()
+	ctx := v.session.getContext()
 
 	return service.Inspect(ctx, &pb.Reference{Name: name})
 
 }
 
+// Import creates the metadata of a volume created outside SafeScale
+func (v *volume) Import(name string, timeout time.Duration) (*pb.Volume, error) {
+	v.session.Connect()
+	defer v.session.Disconnect()
+	service := pb.NewVolumeServiceClient(v.session.connection)
+	ctx := v.session.getContext()
+
+	return service.Import(ctx, &pb.Reference{Name: name})
+}
+
 // Delete ...
 func (v *volume) Delete(names []string, timeout time.Duration) error {
 	v.session.Connect()
 	defer v.session.Disconnect()
 	service := pb.NewVolumeServiceClient(v.session.connection)
-	ctx := context.Background()
+	ctx := v.session.getContext()
 
 	var (
 		wg   sync.WaitGroup
@@ -97,7 +106,7 @@ func (v *volume) Create(def pb.VolumeDefinition, timeout time.Duration) (*pb.Vol
 	v.session.Connect()
 	defer v.session.Disconnect()
 	service := pb.NewVolumeServiceClient(v.session.connection)
-	ctx := context.Background()
+	ctx := v.session.getContext()
 
 	return service.Create(ctx, &def)
 
@@ -108,7 +117,7 @@ func (v *volume) Attach(def pb.VolumeAttachment, timeout time.Duration) error {
 	v.session.Connect()
 	defer v.session.Disconnect()
 	service := pb.NewVolumeServiceClient(v.session.connection)
-	ctx := context.Background()
+	ctx := v.session.getContext()
 
 	_, err := service.Attach(ctx, &def)
 	return err
@@ -120,7 +129,7 @@ func (v *volume) Detach(volumeName string, hostName string, timeout time.Duratio
 	v.session.Connect()
 	defer v.session.Disconnect()
 	service := pb.NewVolumeServiceClient(v.session.connection)
-	ctx := context.Background()
+	ctx := v.session.getContext()
 
 	_, err := service.Detach(ctx, &pb.VolumeDetachment{
 		Volume: &pb.Reference{Name: volumeName},
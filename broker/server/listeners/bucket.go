@@ -47,10 +47,11 @@ func (s *BucketServiceListener) List(ctx context.Context, in *google_protobuf.Em
 	log.Infof("%s list called", logListenerBase)
 	defer log.Debugf("%s list done", logListenerBase)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Cannot list buckets: No tenant set")
 	}
-	service := services.NewBucketService(currentTenant.Service)
+	service := services.NewBucketService(tenant.Service)
 	buckets, err := service.List()
 	if err != nil {
 		tbr := errors.Wrap(err, "Cannot list buckets")
@@ -65,11 +66,12 @@ func (s *BucketServiceListener) Create(ctx context.Context, in *pb.Bucket) (*goo
 	log.Infof("%s create '%s' called", logListenerBase, in.Name)
 	defer log.Debugf("%s create '%s' done", logListenerBase, in.Name)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("can't create bucket: no tenant set")
 	}
 
-	service := services.NewBucketService(currentTenant.Service)
+	service := services.NewBucketService(tenant.Service)
 	err := service.Create(in.GetName())
 	if err != nil {
 		tbr := errors.Wrap(err, "can't create bucket")
@@ -84,11 +86,12 @@ func (s *BucketServiceListener) Delete(ctx context.Context, in *pb.Bucket) (*goo
 	log.Infof("%s delete '%s' called", logListenerBase, in.Name)
 	defer log.Debugf("%s delete '%s' done", logListenerBase, in.Name)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("can't delete bucket: no tenant set")
 	}
 
-	service := services.NewBucketService(currentTenant.Service)
+	service := services.NewBucketService(tenant.Service)
 	err := service.Delete(in.GetName())
 	if err != nil {
 		tbr := errors.Wrap(err, "can't delete bucket")
@@ -103,11 +106,12 @@ func (s *BucketServiceListener) Inspect(ctx context.Context, in *pb.Bucket) (*pb
 	log.Infof("%s inspect '%s' called", logListenerBase, in.Name)
 	defer log.Debugf("%s inspect '%s' called", logListenerBase, in.Name)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("can't inspect bucket: no tenant set")
 	}
 
-	service := services.NewBucketService(currentTenant.Service)
+	service := services.NewBucketService(tenant.Service)
 	resp, err := service.Inspect(in.GetName())
 	if err != nil {
 		tbr := errors.Wrap(err, "can't inspect bucket")
@@ -122,11 +126,12 @@ func (s *BucketServiceListener) Mount(ctx context.Context, in *pb.BucketMounting
 	log.Infof("%s mount '%v' called", logListenerBase, in)
 	defer log.Debugf("%s mount '%v' called", logListenerBase, in)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("can't mount bucket: no tenant set")
 	}
 
-	service := services.NewBucketService(currentTenant.Service)
+	service := services.NewBucketService(tenant.Service)
 	err := service.Mount(in.GetBucket(), in.GetHost().GetName(), in.GetPath())
 	return &google_protobuf.Empty{}, err
 }
@@ -136,11 +141,12 @@ func (s *BucketServiceListener) Unmount(ctx context.Context, in *pb.BucketMounti
 	log.Infof("%s umount '%v' called", logListenerBase, in)
 	defer log.Debugf("%s umount '%v' done", logListenerBase, in)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't unmount bucket: no tenant set")
 	}
 
-	service := services.NewBucketService(currentTenant.Service)
+	service := services.NewBucketService(tenant.Service)
 	err := service.Unmount(in.GetBucket(), in.GetHost().GetName())
 
 	return &google_protobuf.Empty{}, err
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	brokerclient "github.com/CS-SI/SafeScale/broker/client"
+	"github.com/CS-SI/SafeScale/broker/server/operations"
+	"github.com/CS-SI/SafeScale/broker/server/services"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+	"github.com/CS-SI/SafeScale/deploy/cluster"
+	clusterapi "github.com/CS-SI/SafeScale/deploy/cluster/api"
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/Complexity"
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/Flavor"
+)
+
+// deploy cluster create <name>
+
+// ClusterServiceListener cluster service server grpc
+type ClusterServiceListener struct{}
+
+// leaseRetryDelay is the delay between two attempts to take a tenantLease
+var leaseRetryDelay = 5 * time.Second
+
+// tenantLease is held by the actions of a single tenant at a time
+type tenantLease struct {
+	mu    sync.Mutex
+	name  string
+	count int
+}
+
+// clusterTenants is the lease of the cluster creations: they create their networks and hosts through a broker
+// client, whose tenant is common to the process
+var clusterTenants = &tenantLease{}
+
+// acquire waits until the lease is free or held for the tenant 'name', then takes it; the wait stops with the
+// error of the tracker if it asks to abort
+func (l *tenantLease) acquire(name string, tracker services.Tracker) error {
+	waiting := false
+	for {
+		l.mu.Lock()
+		if l.count == 0 || l.name == name {
+			l.name = name
+			l.count++
+			brokerclient.UseTenant(name)
+			l.mu.Unlock()
+			return nil
+		}
+		other := l.name
+		l.mu.Unlock()
+
+		if !waiting {
+			tracker.Step("waiting for the end of the cluster creations of tenant '%s'", other)
+			waiting = true
+		}
+		err := tracker.Aborted()
+		if err != nil {
+			return err
+		}
+		time.Sleep(leaseRetryDelay)
+	}
+}
+
+// release gives back the lease taken by acquire
+func (l *tenantLease) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count--
+	if l.count == 0 {
+		l.name = ""
+		brokerclient.UseTenant("")
+	}
+}
+
+// CreateAsync starts the creation of a new cluster and returns the operation tracking it
+func (s *ClusterServiceListener) CreateAsync(ctx context.Context, in *pb.ClusterDefinition) (*pb.Operation, error) {
+	log.Infof("Create async cluster called '%s'", in.GetName())
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't create cluster: no tenant set")
+	}
+	if in.GetName() == "" {
+		return nil, fmt.Errorf("Can't create cluster: no name given")
+	}
+	if in.GetCIDR() == "" {
+		return nil, fmt.Errorf("Can't create cluster: no CIDR given")
+	}
+
+	disabled := map[string]struct{}{}
+	for _, name := range in.GetDisabledFeatures() {
+		disabled[name] = struct{}{}
+	}
+	req := clusterapi.Request{
+		Name:                    in.GetName(),
+		CIDR:                    in.GetCIDR(),
+		Complexity:              Complexity.Enum(in.GetComplexity()),
+		Flavor:                  Flavor.Enum(in.GetFlavor()),
+		KeepOnFailure:           in.GetKeepOnFailure(),
+		NodesDef:                in.GetNodesDef(),
+		DisabledDefaultFeatures: disabled,
+	}
+
+	op, err := operations.Start("Creation of cluster", in.GetName(), tenant.name, func(op *operations.Operation) (string, error) {
+		err := clusterTenants.acquire(tenant.name, op)
+		if err != nil {
+			return "", err
+		}
+		defer clusterTenants.release()
+
+		req.Tracker = op
+		instance, err := cluster.Create(req)
+		if err != nil {
+			return "", err
+		}
+		return instance.GetName(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conv.ToPBOperation(op), nil
+}
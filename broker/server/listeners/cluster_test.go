@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTracker struct {
+	steps   []string
+	aborted error
+}
+
+func (t *testTracker) Step(format string, a ...interface{}) {
+	t.steps = append(t.steps, fmt.Sprintf(format, a...))
+}
+
+func (t *testTracker) Aborted() error {
+	return t.aborted
+}
+
+func TestTenantLease(t *testing.T) {
+	oldDelay := leaseRetryDelay
+	defer func() { leaseRetryDelay = oldDelay }()
+	leaseRetryDelay = 10 * time.Millisecond
+
+	l := &tenantLease{}
+	require.NoError(t, l.acquire("tenant1", &testTracker{}))
+	// The creations of the same tenant share the lease
+	require.NoError(t, l.acquire("tenant1", &testTracker{}))
+
+	// Those of another tenant wait until the lease is free
+	waiting := &testTracker{}
+	acquired := make(chan error)
+	go func() {
+		acquired <- l.acquire("tenant2", waiting)
+	}()
+	l.release()
+	select {
+	case <-acquired:
+		t.Fatal("lease taken by tenant2 while held by tenant1")
+	case <-time.After(100 * time.Millisecond):
+	}
+	l.release()
+	require.NoError(t, <-acquired)
+	assert.Equal(t, []string{"waiting for the end of the cluster creations of tenant 'tenant1'"}, waiting.steps)
+
+	// The wait stops if the creation is cancelled
+	err := l.acquire("tenant3", &testTracker{aborted: errors.New("operation cancelled on request")})
+	assert.EqualError(t, err, "operation cancelled on request")
+	l.release()
+	require.NoError(t, l.acquire("tenant3", &testTracker{}))
+	l.release()
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/server/services"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+	"github.com/CS-SI/SafeScale/system/firewall"
+)
+
+// broker host firewall list host1 --table=filter
+// broker host firewall add host1 --chain=INPUT --action=accept --protocol=tcp --to-port=80
+// broker host firewall remove host1 --chain=INPUT --position=3
+// broker host firewall apply host1 firewall.json
+
+// FirewallServiceListener firewall service server grpc
+type FirewallServiceListener struct{}
+
+// List returns the firewall rules of a host, of all its tables or of the table requested
+func (s *FirewallServiceListener) List(ctx context.Context, in *pb.FirewallListRequest) (*pb.Firewall, error) {
+	ref := in.GetHost().GetName()
+	log.Printf("List firewall of host '%s' called", ref)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't list firewall of host '%s': no tenant set", ref)
+	}
+
+	fw, err := services.NewFirewallService(tenant.Service).Get(ref)
+	if err != nil {
+		return nil, err
+	}
+	if table := in.GetTable(); table != "" {
+		t, err := fw.Table(table)
+		if err != nil {
+			return nil, fmt.Errorf("Can't list firewall of host '%s': %s", ref, err.Error())
+		}
+		fw.Tables = map[string]*firewall.Table{t.Name: t}
+	}
+	return conv.ToPBFirewall(ref, fw), nil
+}
+
+// Add adds a rule to the firewall of a host
+func (s *FirewallServiceListener) Add(ctx context.Context, in *pb.FirewallRuleDefinition) (*google_protobuf.Empty, error) {
+	ref := in.GetHost().GetName()
+	log.Printf("Add firewall rule on host '%s' called", ref)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't add firewall rule on host '%s': no tenant set", ref)
+	}
+	if in.GetRule() == nil {
+		return nil, fmt.Errorf("Can't add firewall rule on host '%s': no rule given", ref)
+	}
+	if in.GetPosition() < 0 {
+		return nil, fmt.Errorf("Can't add firewall rule on host '%s': invalid position %d", ref, in.GetPosition())
+	}
+	rule, err := conv.ToSystemFirewallRule(in.GetRule())
+	if err != nil {
+		return nil, fmt.Errorf("Can't add firewall rule on host '%s': %s", ref, err.Error())
+	}
+
+	err = services.NewFirewallService(tenant.Service).AddRule(ref, in.GetTable(), in.GetChain(), uint(in.GetPosition()), *rule)
+	if err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// Remove removes a rule from the firewall of a host, designated by its position or its definition
+func (s *FirewallServiceListener) Remove(ctx context.Context, in *pb.FirewallRuleDefinition) (*google_protobuf.Empty, error) {
+	ref := in.GetHost().GetName()
+	log.Printf("Remove firewall rule from host '%s' called", ref)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't remove firewall rule from host '%s': no tenant set", ref)
+	}
+	if in.GetPosition() < 0 {
+		return nil, fmt.Errorf("Can't remove firewall rule from host '%s': invalid position %d", ref, in.GetPosition())
+	}
+	var rule *firewall.Rule
+	if in.GetPosition() == 0 && in.GetRule() != nil {
+		var err error
+		rule, err = conv.ToSystemFirewallRule(in.GetRule())
+		if err != nil {
+			return nil, fmt.Errorf("Can't remove firewall rule from host '%s': %s", ref, err.Error())
+		}
+	}
+
+	err := services.NewFirewallService(tenant.Service).RemoveRule(ref, in.GetTable(), in.GetChain(), uint(in.GetPosition()), rule)
+	if err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// Apply replaces the rules of the tables given on a host
+func (s *FirewallServiceListener) Apply(ctx context.Context, in *pb.Firewall) (*google_protobuf.Empty, error) {
+	ref := in.GetHost().GetName()
+	log.Printf("Apply firewall of host '%s' called", ref)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't apply firewall of host '%s': no tenant set", ref)
+	}
+	fw, err := conv.ToSystemFirewall(in)
+	if err != nil {
+		return nil, fmt.Errorf("Can't apply firewall of host '%s': %s", ref, err.Error())
+	}
+
+	err = services.NewFirewallService(tenant.Service).Apply(ref, fw)
+	if err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/server/operations"
 	"github.com/CS-SI/SafeScale/broker/server/services"
 	"github.com/CS-SI/SafeScale/broker/utils"
 	conv "github.com/CS-SI/SafeScale/broker/utils"
@@ -63,12 +64,13 @@ type StoredCPUInfo struct {
 func (s *HostServiceListener) Start(ctx context.Context, in *pb.Reference) (*google_protobuf.Empty, error) {
 	log.Printf("Start host called '%s'", in.Name)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't start host: no tenant set")
 	}
 
 	ref := utils.GetReference(in)
-	hostAPI := services.NewHostService(currentTenant.Service)
+	hostAPI := services.NewHostService(tenant.Service)
 
 	err := hostAPI.Start(ref)
 	if err != nil {
@@ -83,12 +85,13 @@ func (s *HostServiceListener) Start(ctx context.Context, in *pb.Reference) (*goo
 func (s *HostServiceListener) Stop(ctx context.Context, in *pb.Reference) (*google_protobuf.Empty, error) {
 	log.Printf("Stop host called '%s'", in.Name)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't stop host: no tenant set")
 	}
 
 	ref := utils.GetReference(in)
-	hostAPI := services.NewHostService(currentTenant.Service)
+	hostAPI := services.NewHostService(tenant.Service)
 
 	err := hostAPI.Stop(ref)
 	if err != nil {
@@ -103,12 +106,13 @@ func (s *HostServiceListener) Stop(ctx context.Context, in *pb.Reference) (*goog
 func (s *HostServiceListener) Reboot(ctx context.Context, in *pb.Reference) (*google_protobuf.Empty, error) {
 	log.Printf("Reboot host called, '%s'", in.Name)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't reboot host: no tenant set")
 	}
 
 	ref := utils.GetReference(in)
-	hostAPI := services.NewHostService(currentTenant.Service)
+	hostAPI := services.NewHostService(tenant.Service)
 
 	err := hostAPI.Reboot(ref)
 	if err != nil {
@@ -123,11 +127,12 @@ func (s *HostServiceListener) Reboot(ctx context.Context, in *pb.Reference) (*go
 func (s *HostServiceListener) List(ctx context.Context, in *pb.HostListRequest) (*pb.HostList, error) {
 	log.Printf("List hosts called")
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't list hosts: no tenant set")
 	}
 
-	hostAPI := services.NewHostService(currentTenant.Service)
+	hostAPI := services.NewHostService(tenant.Service)
 
 	hosts, err := hostAPI.List(in.GetAll())
 	if err != nil {
@@ -147,17 +152,18 @@ func (s *HostServiceListener) List(ctx context.Context, in *pb.HostListRequest)
 // Create a new host
 func (s *HostServiceListener) Create(ctx context.Context, in *pb.HostDefinition) (*pb.Host, error) {
 	log.Infof("Create host called '%s'", in.Name)
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't create host: no tenant set")
 	}
 
-	hostService := services.NewHostService(currentTenant.Service)
+	hostService := services.NewHostService(tenant.Service)
 
 	// TODO https://github.com/CS-SI/SafeScale/issues/30
 	// TODO GITHUB If we have to ask for GPU requirements and FREQ requirements, pb.HostDefinition has to change and the invocation of hostService.Create too...
 
 	host, err := hostService.Create(in.GetName(), in.GetNetwork(),
-		int(in.GetCPUNumber()), in.GetRAM(), int(in.GetDisk()), in.GetImageID(), in.GetPublic(), int(in.GetGPUNumber()), float32(in.GetFreq()), in.Force)
+		int(in.GetCPUNumber()), in.GetRAM(), int(in.GetDisk()), in.GetImageID(), in.GetPublic(), int(in.GetGPUNumber()), float32(in.GetFreq()), in.Force, in.GetUserData())
 	if err != nil {
 		return nil, err
 	}
@@ -165,6 +171,29 @@ func (s *HostServiceListener) Create(ctx context.Context, in *pb.HostDefinition)
 	return conv.ToPBHost(host), nil
 }
 
+// CreateAsync starts the creation of a new host and returns the operation tracking it
+func (s *HostServiceListener) CreateAsync(ctx context.Context, in *pb.HostDefinition) (*pb.Operation, error) {
+	log.Infof("Create async host called '%s'", in.Name)
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't create host: no tenant set")
+	}
+
+	op, err := operations.Start("Creation of host", in.GetName(), tenant.name, func(op *operations.Operation) (string, error) {
+		hostService := services.NewTrackedHostService(tenant.Service, op)
+		host, err := hostService.Create(in.GetName(), in.GetNetwork(),
+			int(in.GetCPUNumber()), in.GetRAM(), int(in.GetDisk()), in.GetImageID(), in.GetPublic(), int(in.GetGPUNumber()), float32(in.GetFreq()), in.Force, in.GetUserData())
+		if err != nil {
+			return "", err
+		}
+		return host.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conv.ToPBOperation(op), nil
+}
+
 // Status of a host
 func (s *HostServiceListener) Status(ctx context.Context, in *pb.Reference) (*pb.HostStatus, error) {
 	log.Infof("Host Status '%s' called", in.Name)
@@ -174,11 +203,12 @@ func (s *HostServiceListener) Status(ctx context.Context, in *pb.Reference) (*pb
 		return nil, fmt.Errorf("Can't get host status: neither name nor id given as reference")
 	}
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't get host status: no tenant set")
 	}
 
-	hostService := services.NewHostService(currentTenant.Service)
+	hostService := services.NewHostService(tenant.Service)
 	host, err := hostService.Get(ref)
 	if err != nil {
 		return nil, err
@@ -199,11 +229,12 @@ func (s *HostServiceListener) Inspect(ctx context.Context, in *pb.Reference) (*p
 		return nil, fmt.Errorf("Can't inspect host: neither name nor id given as reference")
 	}
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't inspect host: no tenant set")
 	}
 
-	hostService := services.NewHostService(currentTenant.Service)
+	hostService := services.NewHostService(tenant.Service)
 	host, err := hostService.Get(ref)
 	if err != nil {
 		return nil, err
@@ -224,10 +255,11 @@ func (s *HostServiceListener) Delete(ctx context.Context, in *pb.Reference) (*go
 		return nil, fmt.Errorf("Can't delete host: neither name nor id given as reference")
 	}
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't delete host: no tenant set")
 	}
-	hostService := services.NewHostService(currentTenant.Service)
+	hostService := services.NewHostService(tenant.Service)
 	err := hostService.Delete(ref)
 	if err != nil {
 		return nil, err
@@ -245,10 +277,11 @@ func (s *HostServiceListener) SSH(ctx context.Context, in *pb.Reference) (*pb.Ss
 		return nil, fmt.Errorf("Can't ssh to host: neither name nor id given as reference")
 	}
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't ssh host: no tenant set")
 	}
-	hostService := services.NewHostService(currentTenant.Service)
+	hostService := services.NewHostService(tenant.Service)
 	sshConfig, err := hostService.SSH(ref)
 	if err != nil {
 		return nil, err
@@ -256,3 +289,51 @@ func (s *HostServiceListener) SSH(ctx context.Context, in *pb.Reference) (*pb.Ss
 	log.Printf("Got Ssh config for host '%s'", ref)
 	return conv.ToPBSshConfig(sshConfig), nil
 }
+
+// Import creates the metadata of a host created outside SafeScale
+func (s *HostServiceListener) Import(ctx context.Context, in *pb.HostImportRequest) (*pb.Host, error) {
+	log.Printf("Import Host called '%s'", in.GetName())
+
+	if in.GetName() == "" {
+		return nil, fmt.Errorf("Can't import host: neither name nor id given as reference")
+	}
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't import host: no tenant set")
+	}
+
+	hostService := services.NewHostService(tenant.Service)
+	host, err := hostService.Import(in.GetName(), in.GetPrivateKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return conv.ToPBHost(host), nil
+}
+
+// Rekey records the SSH host key currently presented by a host
+func (s *HostServiceListener) Rekey(ctx context.Context, in *pb.HostRekeyRequest) (*pb.HostKey, error) {
+	log.Printf("Rekey Host called '%s'", in.GetName())
+
+	if in.GetName() == "" {
+		return nil, fmt.Errorf("Can't rekey host: neither name nor id given as reference")
+	}
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't rekey host: no tenant set")
+	}
+
+	hostService := services.NewHostService(tenant.Service)
+	previous, fingerprint, err := hostService.Rekey(in.GetName(), in.GetFingerprint())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.HostKey{
+		Name:                in.GetName(),
+		Fingerprint:         fingerprint,
+		PreviousFingerprint: previous,
+	}, nil
+}
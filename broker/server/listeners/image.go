@@ -35,11 +35,12 @@ type ImageServiceListener struct{}
 func (s *ImageServiceListener) List(ctx context.Context, in *pb.ImageListRequest) (*pb.ImageList, error) {
 	log.Printf("List images called")
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Cannot list images : No tenant set")
 	}
 
-	service := services.NewImageService(currentTenant.Service)
+	service := services.NewImageService(tenant.Service)
 
 	images, err := service.List(in.GetAll())
 	if err != nil {
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/server/services"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+)
+
+// broker metadata lock list
+// broker metadata lock break <key> [--force]
+// broker metadata history <kind> <name>
+// broker metadata restore <kind> <name> <revision>
+// broker metadata rekey [--from-plaintext] [--dry-run]
+// broker metadata fsck [--delete-dangling] [--import-untracked]
+
+// MetadataServiceListener metadata service server grpc
+type MetadataServiceListener struct{}
+
+// ListLocks returns the locks present in the metadata of the tenant
+func (s *MetadataServiceListener) ListLocks(ctx context.Context, in *google_protobuf.Empty) (*pb.MetadataLockList, error) {
+	log.Printf("List metadata locks called")
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't list metadata locks: no tenant set")
+	}
+
+	locks, err := services.NewMetadataService(tenant.Service).ListLocks()
+	if err != nil {
+		return nil, fmt.Errorf("Can't list metadata locks: %v", err)
+	}
+	var pblocks []*pb.MetadataLock
+	for _, lock := range locks {
+		pblocks = append(pblocks, conv.ToPBMetadataLock(lock))
+	}
+	return &pb.MetadataLockList{Locks: pblocks}, nil
+}
+
+// BreakLock removes a lock from the metadata of the tenant
+func (s *MetadataServiceListener) BreakLock(ctx context.Context, in *pb.MetadataLockBreakRequest) (*google_protobuf.Empty, error) {
+	log.Printf("Break metadata lock called '%s'", in.GetKey())
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't break metadata lock: no tenant set")
+	}
+
+	err := services.NewMetadataService(tenant.Service).BreakLock(in.GetKey(), in.GetForce())
+	if err != nil {
+		return nil, fmt.Errorf("Can't break metadata lock: %v", err)
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// History returns the revisions of the metadata of a resource
+func (s *MetadataServiceListener) History(ctx context.Context, in *pb.MetadataHistoryRequest) (*pb.MetadataRevisionList, error) {
+	log.Printf("History of metadata called '%s %s'", in.GetKind(), in.GetName())
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't get history of metadata: no tenant set")
+	}
+
+	revisions, err := services.NewMetadataService(tenant.Service).History(in.GetKind(), in.GetName())
+	if err != nil {
+		return nil, fmt.Errorf("Can't get history of metadata: %v", err)
+	}
+	var pbrevisions []*pb.MetadataRevision
+	for _, rev := range revisions {
+		pbrevisions = append(pbrevisions, conv.ToPBMetadataRevision(rev))
+	}
+	return &pb.MetadataRevisionList{Revisions: pbrevisions}, nil
+}
+
+// Restore writes back a previous revision of the metadata of a resource
+func (s *MetadataServiceListener) Restore(ctx context.Context, in *pb.MetadataRestoreRequest) (*google_protobuf.Empty, error) {
+	log.Printf("Restore of metadata called '%s %s' to revision '%s'", in.GetKind(), in.GetName(), in.GetRevisionID())
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't restore metadata: no tenant set")
+	}
+
+	err := services.NewMetadataService(tenant.Service).Restore(in.GetKind(), in.GetName(), in.GetRevisionID())
+	if err != nil {
+		return nil, fmt.Errorf("Can't restore metadata: %v", err)
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// Rekey re-encrypts the metadata of the tenant with the current metadata key
+func (s *MetadataServiceListener) Rekey(ctx context.Context, in *pb.MetadataRekeyRequest) (*pb.MetadataRekeyReport, error) {
+	log.Printf("Rekey of metadata called (from plaintext: %t, dry run: %t)", in.GetFromPlaintext(), in.GetDryRun())
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't rekey metadata: no tenant set")
+	}
+
+	report, err := services.NewMetadataService(tenant.Service).Rekey(in.GetFromPlaintext(), in.GetDryRun())
+	if err != nil {
+		return nil, fmt.Errorf("Can't rekey metadata: %v", err)
+	}
+	return conv.ToPBMetadataRekeyReport(report), nil
+}
+
+// Fsck cross-checks the metadata of the tenant with its resources in the cloud
+func (s *MetadataServiceListener) Fsck(ctx context.Context, in *pb.MetadataFsckRequest) (*pb.MetadataDriftList, error) {
+	log.Printf("Fsck of metadata called (delete dangling: %t, import untracked: %t)", in.GetDeleteDangling(), in.GetImportUntracked())
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't check metadata: no tenant set")
+	}
+
+	drifts, err := services.NewMetadataService(tenant.Service).Fsck(in.GetDeleteDangling(), in.GetImportUntracked())
+	if err != nil {
+		return nil, fmt.Errorf("Can't check metadata: %v", err)
+	}
+	var pbdrifts []*pb.MetadataDrift
+	for _, drift := range drifts {
+		pbdrifts = append(pbdrifts, conv.ToPBMetadataDrift(drift))
+	}
+	return &pb.MetadataDriftList{Drifts: pbdrifts}, nil
+}
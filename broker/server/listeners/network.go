@@ -25,6 +25,7 @@ import (
 	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/server/operations"
 	"github.com/CS-SI/SafeScale/broker/server/services"
 	"github.com/CS-SI/SafeScale/broker/utils"
 	conv "github.com/CS-SI/SafeScale/broker/utils"
@@ -43,11 +44,12 @@ type NetworkServiceListener struct{}
 func (s *NetworkServiceListener) Create(ctx context.Context, in *pb.NetworkDefinition) (*pb.Network, error) {
 	log.Printf("Create Network called '%s'", in.Name)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Cannot create network : No tenant set")
 	}
 
-	networkAPI := services.NewNetworkService(currentTenant.Service)
+	networkAPI := services.NewNetworkService(tenant.Service)
 	network, err := networkAPI.Create(in.GetName(), in.GetCIDR(), IPVersion.IPv4,
 		int(in.Gateway.GetCPU()), in.GetGateway().GetRAM(), int(in.GetGateway().GetDisk()), in.GetGateway().GetImageID(), in.GetGateway().GetName())
 
@@ -59,15 +61,40 @@ func (s *NetworkServiceListener) Create(ctx context.Context, in *pb.NetworkDefin
 	return conv.ToPBNetwork(network), nil
 }
 
+// CreateAsync starts the creation of a new network and returns the operation tracking it
+func (s *NetworkServiceListener) CreateAsync(ctx context.Context, in *pb.NetworkDefinition) (*pb.Operation, error) {
+	log.Printf("Create async Network called '%s'", in.Name)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Cannot create network : No tenant set")
+	}
+
+	op, err := operations.Start("Creation of network", in.GetName(), tenant.name, func(op *operations.Operation) (string, error) {
+		networkAPI := services.NewTrackedNetworkService(tenant.Service, op)
+		network, err := networkAPI.Create(in.GetName(), in.GetCIDR(), IPVersion.IPv4,
+			int(in.Gateway.GetCPU()), in.GetGateway().GetRAM(), int(in.GetGateway().GetDisk()), in.GetGateway().GetImageID(), in.GetGateway().GetName())
+		if err != nil {
+			return "", err
+		}
+		return network.ID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conv.ToPBOperation(op), nil
+}
+
 // List existing networks
 func (s *NetworkServiceListener) List(ctx context.Context, in *pb.NWListRequest) (*pb.NetworkList, error) {
 	log.Printf("List Network called")
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Cannot list networks : No tenant set")
 	}
 
-	networkAPI := services.NewNetworkService(currentTenant.Service)
+	networkAPI := services.NewNetworkService(tenant.Service)
 
 	networks, err := networkAPI.List(in.GetAll())
 	if err != nil {
@@ -94,11 +121,12 @@ func (s *NetworkServiceListener) Inspect(ctx context.Context, in *pb.Reference)
 		return nil, fmt.Errorf("Can't inspect network : Neither name nor id given as reference")
 	}
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't inspect network : No tenant set")
 	}
 
-	networkAPI := services.NewNetworkService(currentTenant.Service)
+	networkAPI := services.NewNetworkService(tenant.Service)
 	network, err := networkAPI.Get(ref)
 	if err != nil {
 		return nil, err
@@ -119,11 +147,12 @@ func (s *NetworkServiceListener) Delete(ctx context.Context, in *pb.Reference) (
 		return nil, fmt.Errorf("Can't delete network: neither name nor id given as reference")
 	}
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't delete network: no tenant set")
 	}
 
-	networkAPI := services.NewNetworkService(currentTenant.Service)
+	networkAPI := services.NewNetworkService(tenant.Service)
 	err := networkAPI.Delete(ref)
 	if err != nil {
 		return nil, err
@@ -132,3 +161,26 @@ func (s *NetworkServiceListener) Delete(ctx context.Context, in *pb.Reference) (
 	log.Printf("Network '%s' deleted", ref)
 	return &google_protobuf.Empty{}, nil
 }
+
+// Import creates the metadata of a network created outside SafeScale
+func (s *NetworkServiceListener) Import(ctx context.Context, in *pb.NetworkImportRequest) (*pb.Network, error) {
+	log.Debugf("broker.server.listeners.NetworkServiceListener.Import(%s) called'", in.GetName())
+	defer log.Debugf("broker.server.listeners.NetworkServiceListener.Import(%s) done'", in.GetName())
+
+	if in.GetName() == "" {
+		return nil, fmt.Errorf("Can't import network : Neither name nor id given as reference")
+	}
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't import network : No tenant set")
+	}
+
+	networkAPI := services.NewNetworkService(tenant.Service)
+	network, err := networkAPI.Import(in.GetName(), in.GetGateway())
+	if err != nil {
+		return nil, err
+	}
+
+	return conv.ToPBNetwork(network), nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/server/operations"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+)
+
+// broker operation list
+// broker operation inspect <id>
+// broker operation watch <id>
+// broker operation cancel <id>
+
+// OperationServiceListener operation service server grpc
+type OperationServiceListener struct{}
+
+// getOperation returns the operation referenced by in, if it belongs to the tenant of the request
+func getOperation(ctx context.Context, in *pb.Reference) (*operations.Operation, error) {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("no tenant set")
+	}
+	op := operations.Get(in.GetID())
+	if op == nil || op.Tenant != tenant.name {
+		return nil, fmt.Errorf("operation '%s' not found", in.GetID())
+	}
+	return op, nil
+}
+
+// List returns the operations of the tenant
+func (s *OperationServiceListener) List(ctx context.Context, in *google_protobuf.Empty) (*pb.OperationList, error) {
+	log.Printf("List operations called")
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't list operations: no tenant set")
+	}
+
+	var pbops []*pb.Operation
+	for _, op := range operations.List(tenant.name) {
+		pbops = append(pbops, conv.ToPBOperation(op))
+	}
+	return &pb.OperationList{Operations: pbops}, nil
+}
+
+// Inspect returns the state of an operation
+func (s *OperationServiceListener) Inspect(ctx context.Context, in *pb.Reference) (*pb.Operation, error) {
+	log.Printf("Inspect operation called '%s'", in.GetID())
+
+	op, err := getOperation(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("Can't inspect operation: %v", err)
+	}
+	return conv.ToPBOperation(op), nil
+}
+
+// Watch streams the events of an operation until it ends
+func (s *OperationServiceListener) Watch(in *pb.Reference, stream pb.OperationService_WatchServer) error {
+	log.Printf("Watch operation called '%s'", in.GetID())
+
+	op, err := getOperation(stream.Context(), in)
+	if err != nil {
+		return fmt.Errorf("Can't watch operation: %v", err)
+	}
+
+	past, next, stop := op.Watch()
+	defer stop()
+
+	for _, event := range past {
+		if err := stream.Send(conv.ToPBOperationEvent(op.ID, event)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case event, ok := <-next:
+			if !ok {
+				if state, _, _ := op.Status(); state == operations.Running {
+					return fmt.Errorf("Watch of operation '%s' interrupted: too many events behind, watch it again", op.ID)
+				}
+				return nil
+			}
+			if err := stream.Send(conv.ToPBOperationEvent(op.ID, event)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// Cancel requests an operation to stop and roll back
+func (s *OperationServiceListener) Cancel(ctx context.Context, in *pb.Reference) (*google_protobuf.Empty, error) {
+	log.Printf("Cancel operation called '%s'", in.GetID())
+
+	op, err := getOperation(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("Can't cancel operation: %v", err)
+	}
+	err = op.Cancel()
+	if err != nil {
+		return nil, fmt.Errorf("Can't cancel operation: %v", err)
+	}
+	return &google_protobuf.Empty{}, nil
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package listeners
+
+import (
+	"context"
+	"fmt"
+
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/server/services"
+	"github.com/CS-SI/SafeScale/broker/utils"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+)
+
+// broker securitygroup create sg1 --network net1 --description "Web servers"
+// broker securitygroup list --all
+// broker securitygroup inspect sg1
+// broker securitygroup delete sg1
+// broker securitygroup rule add sg1 --protocol=tcp --from-port=80 --to-port=443 --cidr=0.0.0.0/0
+// broker securitygroup rule delete sg1 <rule id>
+// broker securitygroup bind sg1 host1
+// broker securitygroup unbind sg1 host1
+
+// SecurityGroupServiceListener security group service server grpc
+type SecurityGroupServiceListener struct{}
+
+// Create creates a security group
+func (s *SecurityGroupServiceListener) Create(ctx context.Context, in *pb.SecurityGroupDefinition) (*pb.SecurityGroup, error) {
+	log.Printf("Create security group '%s' called", in.GetName())
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't create security group: no tenant set")
+	}
+
+	sg, err := services.NewSecurityGroupService(tenant.Service).Create(in.GetName(), in.GetDescription(), utils.GetReference(in.GetNetwork()))
+	if err != nil {
+		return nil, err
+	}
+	return conv.ToPBSecurityGroup(sg), nil
+}
+
+// List lists the security groups
+func (s *SecurityGroupServiceListener) List(ctx context.Context, in *pb.SecurityGroupListRequest) (*pb.SecurityGroupList, error) {
+	log.Printf("List security groups called")
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't list security groups: no tenant set")
+	}
+
+	groups, err := services.NewSecurityGroupService(tenant.Service).List(in.GetAll())
+	if err != nil {
+		return nil, err
+	}
+	var pbGroups []*pb.SecurityGroup
+	for i := range groups {
+		pbGroups = append(pbGroups, conv.ToPBSecurityGroup(&groups[i]))
+	}
+	return &pb.SecurityGroupList{SecurityGroups: pbGroups}, nil
+}
+
+// Inspect returns a security group, with its rules and the hosts bound to it
+func (s *SecurityGroupServiceListener) Inspect(ctx context.Context, in *pb.Reference) (*pb.SecurityGroup, error) {
+	ref := utils.GetReference(in)
+	log.Printf("Inspect security group '%s' called", ref)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't inspect security group '%s': no tenant set", ref)
+	}
+
+	sg, err := services.NewSecurityGroupService(tenant.Service).Inspect(ref)
+	if err != nil {
+		return nil, err
+	}
+	return conv.ToPBSecurityGroup(sg), nil
+}
+
+// Delete deletes a security group
+func (s *SecurityGroupServiceListener) Delete(ctx context.Context, in *pb.Reference) (*google_protobuf.Empty, error) {
+	ref := utils.GetReference(in)
+	log.Printf("Delete security group '%s' called", ref)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't delete security group '%s': no tenant set", ref)
+	}
+
+	err := services.NewSecurityGroupService(tenant.Service).Delete(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// AddRule adds a rule to a security group
+func (s *SecurityGroupServiceListener) AddRule(ctx context.Context, in *pb.SecurityGroupRuleDefinition) (*pb.SecurityGroupRule, error) {
+	ref := utils.GetReference(in.GetSecurityGroup())
+	log.Printf("Add rule to security group '%s' called", ref)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't add rule to security group '%s': no tenant set", ref)
+	}
+	if in.GetRule() == nil {
+		return nil, fmt.Errorf("Can't add rule to security group '%s': no rule given", ref)
+	}
+
+	rule, err := services.NewSecurityGroupService(tenant.Service).AddRule(ref, conv.ToModelSecurityGroupRule(in.GetRule()))
+	if err != nil {
+		return nil, err
+	}
+	return conv.ToPBSecurityGroupRule(rule), nil
+}
+
+// DeleteRule deletes a rule, identified by its ID, from a security group
+func (s *SecurityGroupServiceListener) DeleteRule(ctx context.Context, in *pb.SecurityGroupRuleDefinition) (*google_protobuf.Empty, error) {
+	ref := utils.GetReference(in.GetSecurityGroup())
+	log.Printf("Delete rule from security group '%s' called", ref)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't delete rule from security group '%s': no tenant set", ref)
+	}
+	ruleID := in.GetRule().GetID()
+	if ruleID == "" {
+		return nil, fmt.Errorf("Can't delete rule from security group '%s': no rule ID given", ref)
+	}
+
+	err := services.NewSecurityGroupService(tenant.Service).DeleteRule(ref, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// Bind binds a security group to a host
+func (s *SecurityGroupServiceListener) Bind(ctx context.Context, in *pb.SecurityGroupBinding) (*google_protobuf.Empty, error) {
+	ref := utils.GetReference(in.GetSecurityGroup())
+	host := utils.GetReference(in.GetHost())
+	log.Printf("Bind security group '%s' to host '%s' called", ref, host)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't bind security group '%s' to host '%s': no tenant set", ref, host)
+	}
+
+	err := services.NewSecurityGroupService(tenant.Service).Bind(ref, host)
+	if err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// Unbind unbinds a security group from a host
+func (s *SecurityGroupServiceListener) Unbind(ctx context.Context, in *pb.SecurityGroupBinding) (*google_protobuf.Empty, error) {
+	ref := utils.GetReference(in.GetSecurityGroup())
+	host := utils.GetReference(in.GetHost())
+	log.Printf("Unbind security group '%s' from host '%s' called", ref, host)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("Can't unbind security group '%s' from host '%s': no tenant set", ref, host)
+	}
+
+	err := services.NewSecurityGroupService(tenant.Service).Unbind(ref, host)
+	if err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
@@ -22,6 +22,8 @@ import (
 
 	"github.com/CS-SI/SafeScale/broker/server/services"
 	"github.com/CS-SI/SafeScale/providers/model"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+	"github.com/CS-SI/SafeScale/system/nfs"
 	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -30,7 +32,9 @@ import (
 	convert "github.com/CS-SI/SafeScale/broker/utils"
 )
 
-// broker nas|share create share1 host1 --path="/shared/data"
+// broker nas|share create share1 host1 --path="/shared/data" --acl="10.0.0.0/24(rw,sec=krb5p)"
+// broker nas|share create share1 host1 --secondary=host3 --path="/shared/data"
+// broker nas|share update share1 --acl="10.0.0.0/24(ro,root_squash)"
 // broker nas|share delete share1
 // broker nas|share mount share1 host2 --path="/data"
 // broker nas|share umount share1 host2
@@ -45,17 +49,53 @@ func (s *ShareServiceListener) Create(ctx context.Context, in *pb.ShareDefinitio
 	log.Infof("Listeners: share create '%v'", in)
 	defer log.Debugf("Listeners: share create '%v' done", in)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("can't create share: no tenant set")
 	}
-	shareService := services.NewShareService(currentTenant.Service)
 	shareName := in.GetName()
-	share, err := shareService.Create(shareName, in.GetHost().GetName(), in.GetPath())
+	acls, err := nfs.ParseExportAcls(in.GetAcls())
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("can't create share '%s'", shareName))
+	}
+	shareService := services.NewShareService(tenant.Service)
+	var share *propsv1.HostShare
+	if secondary := in.GetSecondary().GetName(); secondary != "" {
+		share, err = shareService.CreateHA(shareName, in.GetHost().GetName(), secondary, in.GetPath(), acls)
+	} else {
+		share, err = shareService.Create(shareName, in.GetHost().GetName(), in.GetPath(), acls)
+	}
 	if err != nil {
 		tbr := errors.Wrap(err, fmt.Sprintf("can't create share '%s'", shareName))
 		return nil, tbr
 	}
-	return convert.ToPBShare(in.GetName(), share), err
+	return convert.ToPBShare(in.GetHost().GetName(), share), err
+}
+
+// Update calls share service ACLs update
+func (s *ShareServiceListener) Update(ctx context.Context, in *pb.ShareDefinition) (*pb.ShareDefinition, error) {
+	log.Infof("Listeners: share update '%v'", in)
+	defer log.Debugf("Listeners: share update '%v' done", in)
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("can't update share: no tenant set")
+	}
+	shareName := in.GetName()
+	acls, err := nfs.ParseExportAcls(in.GetAcls())
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("can't update share '%s'", shareName))
+	}
+	shareService := services.NewShareService(tenant.Service)
+	share, err := shareService.Update(shareName, acls)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("can't update share '%s'", shareName))
+	}
+	host, _, _, err := shareService.Inspect(shareName)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("can't update share '%s'", shareName))
+	}
+	return convert.ToPBShare(host.Name, share), nil
 }
 
 // Delete call share service deletion
@@ -64,11 +104,12 @@ func (s *ShareServiceListener) Delete(ctx context.Context, in *pb.Reference) (*g
 	log.Infof("Listeners: share delete '%s' called", shareName)
 	defer log.Debugf("Listeners: share delete '%s' done", shareName)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return &google_protobuf.Empty{}, fmt.Errorf("can't delete share '%s': no tenant set", shareName)
 	}
 
-	shareService := services.NewShareService(currentTenant.Service)
+	shareService := services.NewShareService(tenant.Service)
 	_, _, _, err := shareService.Inspect(shareName)
 	if err != nil {
 		switch err.(type) {
@@ -91,11 +132,12 @@ func (s *ShareServiceListener) List(ctx context.Context, in *google_protobuf.Emp
 	log.Infof("Listeners: share list '%v' called", in)
 	defer log.Debugf("Listeners: share list '%v' done", in)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't list Shares: no tenant set")
 	}
 
-	shareService := services.NewShareService(currentTenant.Service)
+	shareService := services.NewShareService(tenant.Service)
 	shares, err := shareService.List()
 	if err != nil {
 		tbr := errors.Wrap(err, "Can't list Shares")
@@ -117,11 +159,12 @@ func (s *ShareServiceListener) Mount(ctx context.Context, in *pb.ShareMountDefin
 	log.Infof("Listeners: share mount '%v' called", in)
 	defer log.Debugf("Listeners: share mount '%v' called", in)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Can't mount share: no tenant set")
 	}
 
-	shareService := services.NewShareService(currentTenant.Service)
+	shareService := services.NewShareService(tenant.Service)
 	shareName := in.GetShare().GetName()
 	mount, err := shareService.Mount(shareName, in.GetHost().GetName(), in.GetPath())
 	if err != nil {
@@ -137,12 +180,13 @@ func (s *ShareServiceListener) Unmount(ctx context.Context, in *pb.ShareMountDef
 	defer log.Debugf("Listeners: share unmount '%v' called", in)
 
 	shareName := in.GetShare().GetName()
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		err := fmt.Errorf("Can't unmount share '%s': no tenant set", shareName)
 		return &google_protobuf.Empty{}, err
 	}
 
-	shareService := services.NewShareService(currentTenant.Service)
+	shareService := services.NewShareService(tenant.Service)
 	hostName := in.GetHost().GetName()
 	err := shareService.Unmount(shareName, hostName)
 	if err != nil {
@@ -157,11 +201,12 @@ func (s *ShareServiceListener) Inspect(ctx context.Context, in *pb.Reference) (*
 	log.Infof("Listeners: share inspect '%s' called", shareName)
 	defer log.Debugf("Listeners: share inspect '%s' done", shareName)
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("can't inspect share '%s': no tenant set", shareName)
 	}
 
-	shareService := services.NewShareService(currentTenant.Service)
+	shareService := services.NewShareService(tenant.Service)
 	host, share, mounts, err := shareService.Inspect(shareName)
 	if err != nil {
 		err := errors.Wrap(err, fmt.Sprintf("can't inspect share '%s'", shareName))
@@ -38,11 +38,12 @@ type SSHServiceListener struct{}
 // Run executes an ssh command an an host
 func (s *SSHServiceListener) Run(ctx context.Context, in *pb.SshCommand) (*pb.SshResponse, error) {
 	log.Printf("Ssh run called '%s', '%s'", in.Host, in.Command)
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Cannot execute ssh command : No tenant set")
 	}
 
-	service := services.NewSSHService(currentTenant.Service)
+	service := services.NewSSHService(tenant.Service)
 	retcode, stdout, stderr, err := service.Run(in.GetHost().GetName(), in.GetCommand())
 
 	return &pb.SshResponse{
@@ -55,11 +56,12 @@ func (s *SSHServiceListener) Run(ctx context.Context, in *pb.SshCommand) (*pb.Ss
 // Copy copy file from/to an host
 func (s *SSHServiceListener) Copy(ctx context.Context, in *pb.SshCopyCommand) (*pb.SshResponse, error) {
 	log.Printf("Ssh copy called '%s', '%s'", in.Source, in.Destination)
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Cannot copy ssh : No tenant set")
 	}
 
-	service := services.NewSSHService(currentTenant.Service)
+	service := services.NewSSHService(tenant.Service)
 	retcode, stdout, stderr, err := service.Copy(in.GetSource(), in.GetDestination())
 	if err != nil {
 		return nil, err
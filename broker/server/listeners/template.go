@@ -35,11 +35,12 @@ type TemplateServiceListener struct{}
 func (s *TemplateServiceListener) List(ctx context.Context, in *pb.TemplateListRequest) (*pb.TemplateList, error) {
 	log.Printf("Template List called")
 
-	if GetCurrentTenant() == nil {
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
 		return nil, fmt.Errorf("Cannot list templates : No tenant set")
 	}
 
-	service := services.NewTemplateService(currentTenant.Service)
+	service := services.NewTemplateService(tenant.Service)
 	templates, err := service.List(in.GetAll())
 	if err != nil {
 		return nil, err
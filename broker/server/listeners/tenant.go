@@ -19,9 +19,10 @@ package listeners
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	pb "github.com/CS-SI/SafeScale/broker"
-	_ "github.com/CS-SI/SafeScale/broker/utils" // Imported to initialise tenants
+	"github.com/CS-SI/SafeScale/broker/utils"
 	"github.com/CS-SI/SafeScale/providers"
 	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 	log "github.com/sirupsen/logrus"
@@ -34,18 +35,11 @@ type Tenant struct {
 }
 
 var (
-	currentTenant *Tenant
-	// serviceFactory *providers.ServiceFactory
+	// tenants caches the services already built, indexed by tenant name
+	tenants      = map[string]*Tenant{}
+	tenantsMutex sync.Mutex
 )
 
-// //InitServiceFactory initialise the service factory
-// func InitServiceFactory() {
-// 	// serviceFactory = providers.NewFactory()
-// 	// serviceFactory.RegisterClient("ovh", &ovh.Client{})
-// 	// serviceFactory.Load()
-
-// }
-
 // TenantServiceListener server is used to implement SafeScale.broker.
 type TenantServiceListener struct{}
 
@@ -69,53 +63,107 @@ func (s *TenantServiceListener) List(ctx context.Context, in *google_protobuf.Em
 	return &pb.TenantList{Tenants: tl}, nil
 }
 
-// Get returns the name of the current tenant used
+// Get returns the name of the tenant used by the request
 func (s *TenantServiceListener) Get(ctx context.Context, in *google_protobuf.Empty) (*pb.TenantName, error) {
 	log.Println("Tenant Get called")
-	tenant := GetCurrentTenant()
+	tenant := GetCurrentTenant(ctx)
 	if tenant == nil {
 		return nil, fmt.Errorf("Cannot get tenant : No tenant set")
 	}
 	return &pb.TenantName{Name: tenant.name}, nil
 }
 
-// GetCurrentTenant contains the current tenant
+// GetCurrentTenant returns the tenant targeted by the request
 var GetCurrentTenant = getCurrentTenant
 
-// getCurrentTenant returns the tenant used for commands or, if not set, set the tenant to use if it is the only one registerd
-func getCurrentTenant() *Tenant {
-	if currentTenant == nil {
+// getCurrentTenant returns the tenant named in the metadata of the request or, if none is named,
+// the only one registered
+func getCurrentTenant(ctx context.Context) *Tenant {
+	name := utils.TenantFromContext(ctx)
+	if name == "" {
 		tenants, err := providers.Tenants()
 		if err != nil || len(tenants) != 1 {
 			return nil
 		}
-		// Set unqiue tenant as selected
-		log.Println("Unique tenant set")
-		for name := range tenants {
-			service, err := providers.GetService(name)
-			if err != nil {
-				return nil
-			}
-			currentTenant = &Tenant{name: name, Service: service}
+		for n := range tenants {
+			name = n
 		}
 	}
-	return currentTenant
+	tenant, err := getTenant(name)
+	if err != nil {
+		log.Errorf("Failed to get tenant '%s': %s", name, err.Error())
+		return nil
+	}
+	return tenant
 }
 
-// Set the the tenant tu use for each command
-func (s *TenantServiceListener) Set(ctx context.Context, in *pb.TenantName) (*google_protobuf.Empty, error) {
-	log.Printf("Tenant Set called '%s'", in.Name)
+// getTenant returns the tenant named 'name', building and caching its service on first use
+func getTenant(name string) (*Tenant, error) {
+	tenantsMutex.Lock()
+	defer tenantsMutex.Unlock()
 
-	if currentTenant != nil && currentTenant.name == in.GetName() {
-		log.Printf("Tenant '%s' is already selected", in.GetName())
-		return &google_protobuf.Empty{}, nil
+	if tenant, ok := tenants[name]; ok {
+		return tenant, nil
 	}
+	service, err := providers.GetService(name)
+	if err != nil {
+		return nil, err
+	}
+	tenant := &Tenant{name: name, Service: service}
+	tenants[name] = tenant
+	log.Printf("Service of tenant '%s' initialized", name)
+	return tenant, nil
+}
+
+// Set checks the tenant can be used; the selection itself is kept by the client and sent with each request
+func (s *TenantServiceListener) Set(ctx context.Context, in *pb.TenantName) (*google_protobuf.Empty, error) {
+	log.Printf("Tenant Set called '%s'", in.Name)
 
-	service, err := providers.GetService(in.GetName())
+	_, err := getTenant(in.GetName())
 	if err != nil {
 		return &google_protobuf.Empty{}, fmt.Errorf("Unable to set tenant '%s': %s", in.GetName(), err.Error())
 	}
-	currentTenant = &Tenant{name: in.GetName(), Service: service}
-	log.Printf("Current tenant is now '%s'", in.GetName())
 	return &google_protobuf.Empty{}, nil
 }
+
+// Inspect returns the provider and the capabilities of the tenant named in the request, or of the tenant used by
+// the request if none is named
+func (s *TenantServiceListener) Inspect(ctx context.Context, in *pb.TenantName) (*pb.TenantInspectResponse, error) {
+	log.Printf("Tenant Inspect called '%s'", in.GetName())
+
+	var tenant *Tenant
+	if in.GetName() == "" {
+		tenant = GetCurrentTenant(ctx)
+		if tenant == nil {
+			return nil, fmt.Errorf("Cannot inspect tenant : No tenant set")
+		}
+	} else {
+		var err error
+		tenant, err = getTenant(in.GetName())
+		if err != nil {
+			return nil, fmt.Errorf("Unable to inspect tenant '%s': %s", in.GetName(), err.Error())
+		}
+	}
+	tenants, err := providers.Tenants()
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := tenant.Service.GetCapabilities()
+	speeds := []pb.VolumeSpeed{}
+	for _, speed := range capabilities.VolumeSpeeds {
+		speeds = append(speeds, pb.VolumeSpeed(speed))
+	}
+	return &pb.TenantInspectResponse{
+		Name:     tenant.name,
+		Provider: tenants[tenant.name],
+		Capabilities: &pb.TenantCapabilities{
+			VolumeSpeeds:      speeds,
+			GPU:               capabilities.GPU,
+			FloatingIP:        pb.FloatingIPModel(capabilities.FloatingIP),
+			MaxVolumesPerHost: int32(capabilities.MaxVolumesPerHost),
+			PrivateNetworks:   capabilities.PrivateNetworks,
+			ObjectStorage:     capabilities.ObjectStorage,
+		},
+	}, nil
+}
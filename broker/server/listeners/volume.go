@@ -46,7 +46,7 @@ type VolumeServiceListener struct{}
 // List the available volumes
 func (s *VolumeServiceListener) List(ctx context.Context, in *pb.VolumeListRequest) (*pb.VolumeList, error) {
 	log.Printf("Volume List called")
-	tenant := GetCurrentTenant()
+	tenant := GetCurrentTenant(ctx)
 	if tenant == nil {
 		return nil, fmt.Errorf("Cannot list volumes : No tenant set")
 	}
@@ -70,7 +70,7 @@ func (s *VolumeServiceListener) Create(ctx context.Context, in *pb.VolumeDefinit
 	log.Debugf("broker.server.listeners.VolumeServiceListener.Create(%v) called", in)
 	defer log.Debugf("broker.server.listeners.VolumeServiceListener.Create(%v) done", in)
 
-	tenant := GetCurrentTenant()
+	tenant := GetCurrentTenant(ctx)
 	if tenant == nil {
 		return nil, fmt.Errorf("Can't create volume: no tenant set")
 	}
@@ -89,7 +89,7 @@ func (s *VolumeServiceListener) Create(ctx context.Context, in *pb.VolumeDefinit
 func (s *VolumeServiceListener) Attach(ctx context.Context, in *pb.VolumeAttachment) (*google_protobuf.Empty, error) {
 	log.Printf("Attach volume called '%s', '%s'", in.Host.Name, in.MountPath)
 
-	tenant := GetCurrentTenant()
+	tenant := GetCurrentTenant(ctx)
 	if tenant == nil {
 		return nil, fmt.Errorf("Cannot attach volume : No tenant set")
 	}
@@ -110,7 +110,7 @@ func (s *VolumeServiceListener) Detach(ctx context.Context, in *pb.VolumeDetachm
 	defer log.Debugf("broker.server.listeners.VolumeServiceListener.Detach(%v) done", in)
 
 	volumeName := in.GetVolume().GetName()
-	tenant := GetCurrentTenant()
+	tenant := GetCurrentTenant(ctx)
 	if tenant == nil {
 		return nil, fmt.Errorf("Can't detach volume '%s': no tenant set", volumeName)
 	}
@@ -135,11 +135,11 @@ func (s *VolumeServiceListener) Delete(ctx context.Context, in *pb.Reference) (*
 		return nil, fmt.Errorf("Can't delete volume: invalid name or id")
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetCurrentTenant(ctx)
 	if tenant == nil {
 		return nil, fmt.Errorf("can't delete volume '%s': no tenant set", ref)
 	}
-	service := NewVolumeService(currentTenant.Service)
+	service := NewVolumeService(tenant.Service)
 	err := service.Delete(ref)
 	if err != nil {
 		return &google_protobuf.Empty{}, fmt.Errorf("Can't delete volume '%s': %s", ref, err.Error())
@@ -157,7 +157,7 @@ func (s *VolumeServiceListener) Inspect(ctx context.Context, in *pb.Reference) (
 		return nil, fmt.Errorf("cannot inspect volume: neither name nor id given as reference")
 	}
 
-	tenant := GetCurrentTenant()
+	tenant := GetCurrentTenant(ctx)
 	if tenant == nil {
 		return nil, fmt.Errorf("cannot inspect volume: No tenant set")
 	}
@@ -173,3 +173,26 @@ func (s *VolumeServiceListener) Inspect(ctx context.Context, in *pb.Reference) (
 
 	return conv.ToPBVolumeInfo(volume, mounts), nil
 }
+
+// Import creates the metadata of a volume created outside SafeScale
+func (s *VolumeServiceListener) Import(ctx context.Context, in *pb.Reference) (*pb.Volume, error) {
+	log.Printf("Import Volume called '%s'", in.Name)
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return nil, fmt.Errorf("cannot import volume: neither name nor id given as reference")
+	}
+
+	tenant := GetCurrentTenant(ctx)
+	if tenant == nil {
+		return nil, fmt.Errorf("cannot import volume: No tenant set")
+	}
+
+	service := NewVolumeService(tenant.Service)
+	volume, err := service.Import(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return conv.ToPBVolume(volume), nil
+}
@@ -16,6 +16,7 @@
 package listeners_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -57,6 +58,9 @@ func (m *MyMockedVolService) Detach(volume string, host string) error {
 func (m *MyMockedVolService) Get(ref string) (*model.Volume, error) {
 	return &model.Volume{}, nil
 }
+func (m *MyMockedVolService) Import(ref string) (*model.Volume, error) {
+	return &model.Volume{}, nil
+}
 
 func TestCreate(t *testing.T) {
 	// ARRANGE
@@ -75,7 +79,7 @@ func TestCreate(t *testing.T) {
 	// Mock GetCurrentTenant
 	oldGetCurrentTeant := listeners.GetCurrentTenant
 	defer func() { listeners.GetCurrentTenant = oldGetCurrentTeant }()
-	listeners.GetCurrentTenant = func() *listeners.Tenant {
+	listeners.GetCurrentTenant = func(ctx context.Context) *listeners.Tenant {
 		return &listeners.Tenant{Service: &providers.Service{}}
 	}
 
@@ -115,7 +119,7 @@ func TestCreate_Err(t *testing.T) {
 	// Mock GetCurrentTenant
 	oldGetCurrentTeant := listeners.GetCurrentTenant
 	defer func() { listeners.GetCurrentTenant = oldGetCurrentTeant }()
-	listeners.GetCurrentTenant = func() *listeners.Tenant {
+	listeners.GetCurrentTenant = func(ctx context.Context) *listeners.Tenant {
 		return &listeners.Tenant{Service: &providers.Service{}}
 	}
 
@@ -135,7 +139,7 @@ func TestCreate_Err_NoTenantSet(t *testing.T) {
 	// Mock GetCurrentTenant
 	oldGetCurrentTeant := listeners.GetCurrentTenant
 	defer func() { listeners.GetCurrentTenant = oldGetCurrentTeant }()
-	listeners.GetCurrentTenant = func() *listeners.Tenant {
+	listeners.GetCurrentTenant = func(ctx context.Context) *listeners.Tenant {
 		return nil
 	}
 	myMockedVolService := &MyMockedVolService{err: errors.New("plop")}
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operations
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// State tells where an operation is in its lifecycle
+type State int
+
+const (
+	// Running tells the operation is in progress
+	Running State = iota
+	// Succeeded tells the operation ended successfully
+	Succeeded
+	// Failed tells the operation ended with an error
+	Failed
+	// Cancelled tells the operation has been aborted on request
+	Cancelled
+)
+
+// retention is the time a finished operation is kept available for inspection
+const retention = 1 * time.Hour
+
+// watchBuffer is the number of events a watcher may lag behind before being dropped
+const watchBuffer = 64
+
+// ErrCancelled is returned by Aborted() when the cancellation of the operation has been requested
+var ErrCancelled = fmt.Errorf("operation cancelled on request")
+
+// Event is a step in the progress of an operation
+type Event struct {
+	Timestamp time.Time
+	Message   string
+	State     State
+}
+
+// Operation is a long running action executed in background by brokerd
+// Kind describes the action applied to Target, ex: "Creation of host"
+type Operation struct {
+	ID     string
+	Kind   string
+	Target string
+	Tenant string
+
+	mu          sync.Mutex
+	state       State
+	err         error
+	resultID    string
+	started     time.Time
+	ended       time.Time
+	cancelled   bool
+	events      []Event
+	subscribers map[chan Event]struct{}
+}
+
+// Func is the action run by an operation; it returns the ID of the resource created
+type Func func(op *Operation) (string, error)
+
+var (
+	registry      = map[string]*Operation{}
+	registryMutex sync.Mutex
+)
+
+// Start registers a new operation and runs fn in background
+func Start(kind, target, tenant string, fn Func) (*Operation, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate operation ID: %v", err)
+	}
+	op := &Operation{
+		ID:          id.String(),
+		Kind:        kind,
+		Target:      target,
+		Tenant:      tenant,
+		state:       Running,
+		started:     time.Now(),
+		subscribers: map[chan Event]struct{}{},
+	}
+
+	registryMutex.Lock()
+	purge()
+	registry[op.ID] = op
+	registryMutex.Unlock()
+
+	op.Step("%s '%s' started", kind, target)
+	go func() {
+		resultID, err := fn(op)
+		op.finish(resultID, err)
+	}()
+	return op, nil
+}
+
+// purge forgets the operations finished for longer than the retention time
+// registryMutex must be locked by the caller
+func purge() {
+	for id, op := range registry {
+		op.mu.Lock()
+		expired := op.state != Running && time.Since(op.ended) > retention
+		op.mu.Unlock()
+		if expired {
+			delete(registry, id)
+		}
+	}
+}
+
+// Get returns the operation identified by id, or nil if not found
+func Get(id string) *Operation {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	return registry[id]
+}
+
+// List returns the operations known for a tenant, sorted by start date
+func List(tenant string) []*Operation {
+	registryMutex.Lock()
+	purge()
+	list := []*Operation{}
+	for _, op := range registry {
+		if op.Tenant == tenant {
+			list = append(list, op)
+		}
+	}
+	registryMutex.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].started.Before(list[j].started)
+	})
+	return list
+}
+
+// Step reports the beginning of a new step of the operation to its watchers
+func (op *Operation) Step(format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	log.Infof("Operation %s: %s", op.ID, msg)
+
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.publish(Event{Timestamp: time.Now(), Message: msg, State: op.state})
+}
+
+// publish records an event and sends it to the watchers
+// op.mu must be locked by the caller
+func (op *Operation) publish(event Event) {
+	op.events = append(op.events, event)
+	for ch := range op.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow watcher; rather than blocking the operation or silently losing events, closes its stream
+			log.Warnf("Operation %s: watcher too slow to follow events, closing its stream", op.ID)
+			delete(op.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Aborted returns ErrCancelled if the cancellation of the operation has been requested, nil otherwise
+func (op *Operation) Aborted() error {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if op.cancelled {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// Cancel requests the operation to stop; the action rolls back what it did at its next step
+func (op *Operation) Cancel() error {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if op.state != Running {
+		return fmt.Errorf("operation '%s' is already finished", op.ID)
+	}
+	if !op.cancelled {
+		op.cancelled = true
+		op.publish(Event{Timestamp: time.Now(), Message: "cancellation requested", State: op.state})
+	}
+	return nil
+}
+
+// finish records the result of the operation and closes the streams of the watchers
+func (op *Operation) finish(resultID string, err error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	op.ended = time.Now()
+	op.resultID = resultID
+	var msg string
+	switch {
+	case err == nil:
+		op.state = Succeeded
+		msg = fmt.Sprintf("%s '%s' succeeded", op.Kind, op.Target)
+	case op.cancelled:
+		op.state = Cancelled
+		op.err = err
+		msg = fmt.Sprintf("%s '%s' cancelled", op.Kind, op.Target)
+	default:
+		op.state = Failed
+		op.err = err
+		msg = fmt.Sprintf("%s '%s' failed: %v", op.Kind, op.Target, err)
+	}
+	log.Infof("Operation %s: %s", op.ID, msg)
+	op.publish(Event{Timestamp: op.ended, Message: msg, State: op.state})
+	for ch := range op.subscribers {
+		close(ch)
+	}
+	op.subscribers = map[chan Event]struct{}{}
+}
+
+// Watch returns the events already emitted and a channel receiving the next ones, closed when the operation ends
+// or when the watcher lags more than watchBuffer events behind (the operation is then still Running);
+// stop must be called when the watcher leaves
+func (op *Operation) Watch() (past []Event, next <-chan Event, stop func()) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	past = make([]Event, len(op.events))
+	copy(past, op.events)
+	ch := make(chan Event, watchBuffer)
+	if op.state != Running {
+		close(ch)
+		return past, ch, func() {}
+	}
+	op.subscribers[ch] = struct{}{}
+	stop = func() {
+		op.mu.Lock()
+		defer op.mu.Unlock()
+		if _, ok := op.subscribers[ch]; ok {
+			delete(op.subscribers, ch)
+			close(ch)
+		}
+	}
+	return past, ch, stop
+}
+
+// Status returns the state of the operation, the ID of the resource created and the error if it failed
+func (op *Operation) Status() (State, string, error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.state, op.resultID, op.err
+}
+
+// Started returns the date the operation started
+func (op *Operation) Started() time.Time {
+	return op.started
+}
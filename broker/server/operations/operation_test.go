@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package operations
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitEnd waits until the operation is no longer running
+func waitEnd(t *testing.T, op *Operation) State {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if state, _, _ := op.Status(); state != Running {
+			return state
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("operation %s still running", op.ID)
+	return Running
+}
+
+func TestStartCompletion(t *testing.T) {
+	registryMutex.Lock()
+	registry = map[string]*Operation{}
+	registryMutex.Unlock()
+
+	op, err := Start("Creation of host", "host1", "tenant-ok", func(op *Operation) (string, error) {
+		op.Step("doing something")
+		return "host-id", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, op, Get(op.ID))
+
+	assert.Equal(t, Succeeded, waitEnd(t, op))
+	_, resultID, err := op.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, "host-id", resultID)
+	assert.Error(t, op.Cancel())
+
+	op, err = Start("Creation of host", "host2", "tenant-ok", func(op *Operation) (string, error) {
+		return "", fmt.Errorf("no more quota")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Failed, waitEnd(t, op))
+	_, _, err = op.Status()
+	assert.EqualError(t, err, "no more quota")
+
+	list := List("tenant-ok")
+	require.Len(t, list, 2)
+	assert.Equal(t, "host1", list[0].Target)
+	assert.Equal(t, "host2", list[1].Target)
+	assert.Empty(t, List("tenant-other"))
+}
+
+func TestCancel(t *testing.T) {
+	started := make(chan struct{})
+	op, err := Start("Creation of network", "net", "tenant-cancel", func(op *Operation) (string, error) {
+		close(started)
+		for {
+			if err := op.Aborted(); err != nil {
+				return "", err
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	require.NoError(t, err)
+	<-started
+	assert.NoError(t, op.Aborted())
+
+	require.NoError(t, op.Cancel())
+	assert.Equal(t, Cancelled, waitEnd(t, op))
+	_, _, err = op.Status()
+	assert.Equal(t, ErrCancelled, err)
+}
+
+func TestWatch(t *testing.T) {
+	proceed := make(chan struct{})
+	op, err := Start("Creation of host", "host", "tenant-watch", func(op *Operation) (string, error) {
+		<-proceed
+		op.Step("step 1")
+		op.Step("step 2")
+		return "id", nil
+	})
+	require.NoError(t, err)
+
+	past, next, stop := op.Watch()
+	defer stop()
+	require.Len(t, past, 1)
+	assert.Equal(t, "Creation of host 'host' started", past[0].Message)
+
+	close(proceed)
+	messages := []string{}
+	for event := range next {
+		messages = append(messages, event.Message)
+	}
+	assert.Equal(t, []string{"step 1", "step 2", "Creation of host 'host' succeeded"}, messages)
+
+	// Once finished, the watch returns the whole history and an already closed channel
+	past, next, _ = op.Watch()
+	assert.Len(t, past, 4)
+	_, ok := <-next
+	assert.False(t, ok)
+}
+
+func TestWatchLagging(t *testing.T) {
+	proceed := make(chan struct{})
+	published := make(chan struct{})
+	release := make(chan struct{})
+	op, err := Start("Creation of host", "host", "tenant-lag", func(op *Operation) (string, error) {
+		<-proceed
+		for i := 0; i <= watchBuffer; i++ {
+			op.Step("step %d", i)
+		}
+		close(published)
+		<-release
+		return "id", nil
+	})
+	require.NoError(t, err)
+
+	_, next, stop := op.Watch()
+	defer stop()
+	close(proceed)
+	<-published
+
+	// The watcher did not read in time: it gets dropped once the buffer is full, while the operation goes on
+	count := 0
+	for range next {
+		count++
+	}
+	assert.Equal(t, watchBuffer, count)
+	state, _, _ := op.Status()
+	assert.Equal(t, Running, state)
+
+	close(release)
+	assert.Equal(t, Succeeded, waitEnd(t, op))
+}
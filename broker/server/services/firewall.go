@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/system"
+	"github.com/CS-SI/SafeScale/system/firewall"
+)
+
+//go:generate mockgen -destination=../mocks/mock_firewallapi.go -package=mocks github.com/CS-SI/SafeScale/broker/server/services FirewallAPI
+
+// FirewallAPI defines API to manipulate the firewalls of the hosts
+type FirewallAPI interface {
+	Get(ref string) (*firewall.Firewall, error)
+	AddRule(ref string, table string, chain string, position uint, rule firewall.Rule) error
+	RemoveRule(ref string, table string, chain string, position uint, rule *firewall.Rule) error
+	Apply(ref string, fw *firewall.Firewall) error
+}
+
+// FirewallService firewall service
+type FirewallService struct {
+	provider *providers.Service
+}
+
+// NewFirewallService creates a firewall service
+func NewFirewallService(api *providers.Service) FirewallAPI {
+	return &FirewallService{
+		provider: api,
+	}
+}
+
+// Get returns the current firewall rules of the host referenced by ref
+func (svc *FirewallService) Get(ref string) (*firewall.Firewall, error) {
+	sshConfig, err := NewSSHService(svc.provider).GetConfig(ref)
+	if err != nil {
+		return nil, infraErrf(err, "can't read firewall of host '%s'", ref)
+	}
+	fw := firewall.NewFirewall(sshConfig)
+	err = fw.FromSystem()
+	if err != nil {
+		return nil, infraErrf(err, "can't read firewall of host '%s'", ref)
+	}
+	return fw, nil
+}
+
+// AddRule adds a rule in a chain of the firewall of the host referenced by ref, at the position 'position'
+// (starting from 1, 0 to append the rule to the chain)
+func (svc *FirewallService) AddRule(ref string, table string, chain string, position uint, rule firewall.Rule) error {
+	log.Debugf("server.services.FirewallService.AddRule(%s, %s, %s) called", ref, table, chain)
+	defer log.Debugf("server.services.FirewallService.AddRule(%s, %s, %s) done", ref, table, chain)
+
+	err := rule.Validate()
+	if err != nil {
+		return logicErrf(err, "invalid rule")
+	}
+	return svc.update(ref, table, func(t *firewall.Table) error {
+		c, err := t.Chain(chain)
+		if err != nil {
+			return err
+		}
+		if position == 0 {
+			c.Add(rule)
+		} else {
+			c.InsertIndex(position-1, rule)
+		}
+		return nil
+	})
+}
+
+// RemoveRule removes a rule from a chain of the firewall of the host referenced by ref, either the rule at the
+// position 'position' (starting from 1) or, if position is 0, the rules identical to 'rule'
+func (svc *FirewallService) RemoveRule(ref string, table string, chain string, position uint, rule *firewall.Rule) error {
+	log.Debugf("server.services.FirewallService.RemoveRule(%s, %s, %s) called", ref, table, chain)
+	defer log.Debugf("server.services.FirewallService.RemoveRule(%s, %s, %s) done", ref, table, chain)
+
+	if position == 0 && rule == nil {
+		return logicErr(fmt.Errorf("neither position nor rule given"))
+	}
+	return svc.update(ref, table, func(t *firewall.Table) error {
+		c, err := t.Chain(chain)
+		if err != nil {
+			return err
+		}
+		count := len(c.Rules)
+		if position != 0 {
+			if position > uint(count) {
+				return fmt.Errorf("chain '%s' has no rule at position %d", chain, position)
+			}
+			c.RemoveIndex(position - 1)
+			return nil
+		}
+		c.Remove(*rule)
+		if len(c.Rules) == count {
+			return fmt.Errorf("rule not found in chain '%s'", chain)
+		}
+		return nil
+	})
+}
+
+// Apply replaces the rules of the tables of fw on the host referenced by ref; the other tables are left untouched
+func (svc *FirewallService) Apply(ref string, fw *firewall.Firewall) error {
+	log.Debugf("server.services.FirewallService.Apply(%s) called", ref)
+	defer log.Debugf("server.services.FirewallService.Apply(%s) done", ref)
+
+	if len(fw.Tables) == 0 {
+		return logicErr(fmt.Errorf("no firewall table to apply"))
+	}
+	mh, sshConfig, err := svc.lock(ref)
+	if err != nil {
+		return infraErrf(err, "can't apply firewall of host '%s'", ref)
+	}
+	defer mh.Release()
+
+	applied := firewall.NewFirewall(sshConfig)
+	applied.Tables = fw.Tables
+	err = applied.ToSystem()
+	if err != nil {
+		return infraErrf(err, "can't apply firewall of host '%s'", ref)
+	}
+	return nil
+}
+
+// update changes a table of the firewall of the host referenced by ref with 'change', then applies it
+// The table "filter" is changed if table is empty.
+func (svc *FirewallService) update(ref string, table string, change func(*firewall.Table) error) error {
+	if table == "" {
+		table = "filter"
+	}
+	mh, sshConfig, err := svc.lock(ref)
+	if err != nil {
+		return infraErrf(err, "can't update firewall of host '%s'", ref)
+	}
+	defer mh.Release()
+
+	fw := firewall.NewFirewall(sshConfig)
+	err = fw.FromSystem()
+	if err != nil {
+		return infraErrf(err, "can't update firewall of host '%s'", ref)
+	}
+	t, err := fw.Table(table)
+	if err != nil {
+		return logicErrf(err, "can't update firewall of host '%s'", ref)
+	}
+	err = change(t)
+	if err != nil {
+		return logicErrf(err, "can't update firewall of host '%s'", ref)
+	}
+
+	// Only the changed table is applied
+	fw.Tables = map[string]*firewall.Table{t.Name: t}
+	err = fw.ToSystem()
+	if err != nil {
+		return infraErrf(err, "can't update firewall of host '%s'", ref)
+	}
+	return nil
+}
+
+// lock locks the metadata of the host referenced by ref, serializing the changes of its firewall, and returns
+// the SSH configuration to reach it
+func (svc *FirewallService) lock(ref string) (*metadata.Host, *system.SSHConfig, error) {
+	mh, err := metadata.LoadHost(svc.provider, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mh == nil {
+		return nil, nil, fmt.Errorf("host '%s' not found", ref)
+	}
+	err = mh.Acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+	sshConfig, err := NewSSHService(svc.provider).GetConfig(mh.Get())
+	if err != nil {
+		mh.Release()
+		return nil, nil, err
+	}
+	return mh, sshConfig, nil
+}
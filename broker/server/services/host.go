@@ -38,6 +38,7 @@ import (
 	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
 	"github.com/CS-SI/SafeScale/providers/model/enums/IPVersion"
 	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+	"github.com/CS-SI/SafeScale/providers/userdata"
 	"github.com/CS-SI/SafeScale/system"
 	"github.com/CS-SI/SafeScale/utils"
 	"github.com/CS-SI/SafeScale/utils/retry"
@@ -49,7 +50,7 @@ import (
 
 // HostAPI defines API to manipulate hosts
 type HostAPI interface {
-	Create(name string, net string, cpu int, ram float32, disk int, os string, public bool, gpuNumber int, freq float32, force bool) (*model.Host, error)
+	Create(name string, net string, cpu int, ram float32, disk int, os string, public bool, gpuNumber int, freq float32, force bool, userData []string) (*model.Host, error)
 	List(all bool) ([]*model.Host, error)
 	Get(ref string) (*model.Host, error)
 	Delete(ref string) error
@@ -57,17 +58,26 @@ type HostAPI interface {
 	Reboot(ref string) error
 	Start(ref string) error
 	Stop(ref string) error
+	Import(ref string, privateKey string) (*model.Host, error)
+	Rekey(ref string, fingerprint string) (string, string, error)
 }
 
 // HostService host service
 type HostService struct {
 	provider *providers.Service
+	tracker  Tracker
 }
 
 // NewHostService ...
 func NewHostService(api *providers.Service) HostAPI {
+	return NewTrackedHostService(api, nopTracker{})
+}
+
+// NewTrackedHostService returns a host service reporting the progress of creations to tracker
+func NewTrackedHostService(api *providers.Service, tracker Tracker) HostAPI {
 	return &HostService{
 		provider: api,
+		tracker:  tracker,
 	}
 }
 
@@ -145,11 +155,21 @@ func (svc *HostService) Reboot(ref string) error {
 // Create creates a host
 func (svc *HostService) Create(
 	name string, net string, cpu int, ram float32, disk int, los string, public bool, gpuNumber int, freq float32, force bool,
+	userData []string,
 ) (*model.Host, error) {
 
 	log.Debugf("broker.server.services.HostService.Create('%s') called", name)
 	defer log.Debugf("broker.server.services.HostService.Create('%s') done", name)
 
+	err := userdata.CheckParts(userData)
+	if err != nil {
+		return nil, logicErr(fmt.Errorf("failed to create host '%s': %v", name, err))
+	}
+	err = svc.provider.GetCapabilities().CheckHost(gpuNumber, public)
+	if err != nil {
+		return nil, logicErr(fmt.Errorf("failed to create host '%s': %v", name, err))
+	}
+
 	host, err := svc.provider.GetHostByName(name)
 	if err != nil {
 		switch err.(type) {
@@ -161,6 +181,7 @@ func (svc *HostService) Create(
 		return nil, logicErr(fmt.Errorf("failed to create host '%s': name is already used", name))
 	}
 
+	svc.tracker.Step("Checking network")
 	networks := []*model.Network{}
 	var gw *model.Host
 	if len(net) != 0 {
@@ -194,6 +215,7 @@ func (svc *HostService) Create(
 		networks = append(networks, net)
 	}
 
+	svc.tracker.Step("Selecting template and image")
 	templates, err := svc.provider.SelectTemplatesBySize(
 		model.SizingRequirements{
 			MinCores:    cpu,
@@ -222,8 +244,13 @@ func (svc *HostService) Create(
 		PublicIP:       public,
 		Networks:       networks,
 		DefaultGateway: gw,
+		UserData:       userData,
 	}
 
+	if err = svc.tracker.Aborted(); err != nil {
+		return nil, err
+	}
+	svc.tracker.Step("Creating compute resource '%s' with template '%s' and image '%s'", name, template.Name, img.Name)
 	host, err = svc.provider.CreateHost(hostRequest)
 	if err != nil {
 		switch err.(type) {
@@ -315,13 +342,29 @@ func (svc *HostService) Create(
 		hostNetworkV1.NetworksByName[network.Name] = network.ID
 	}
 
+	if err = svc.tracker.Aborted(); err != nil {
+		return nil, err
+	}
+
 	// Updates metadata
-	err = metadata.NewHost(svc.provider).Carry(host).Write()
+	svc.tracker.Step("Writing metadata of host '%s'", host.Name)
+	mh := metadata.NewHost(svc.provider)
+	err = mh.Carry(host).Write()
 	if err != nil {
 		return nil, infraErrf(err, "Metadata creation failed")
 	}
 	log.Infof("Compute resource created: '%s'", host.Name)
 
+	// Starting from here, removes metadata if exiting with error
+	defer func() {
+		if err != nil {
+			derr := mh.Delete()
+			if derr != nil {
+				log.Errorf("Failed to remove metadata of host '%s': %v", host.Name, derr)
+			}
+		}
+	}()
+
 	networkHostsV1 := propsv1.NewNetworkHosts()
 	for _, i := range networks {
 		err = i.Properties.Get(NetworkProperty.HostsV1, networkHostsV1)
@@ -342,23 +385,53 @@ func (svc *HostService) Create(
 		}
 	}
 
+	// Starting from here, removes the host from its networks if exiting with error
+	defer func() {
+		if err != nil {
+			for _, i := range networks {
+				networkHostsV1 := propsv1.NewNetworkHosts()
+				derr := i.Properties.Get(NetworkProperty.HostsV1, networkHostsV1)
+				if derr != nil {
+					log.Errorf("Failed to remove host '%s' from network '%s': %v", host.Name, i.Name, derr)
+					continue
+				}
+				delete(networkHostsV1.ByID, host.ID)
+				delete(networkHostsV1.ByName, host.Name)
+				derr = i.Properties.Set(NetworkProperty.HostsV1, networkHostsV1)
+				if derr == nil {
+					derr = metadata.SaveNetwork(svc.provider, i)
+				}
+				if derr != nil {
+					log.Errorf("Failed to remove host '%s' from network '%s': %v", host.Name, i.Name, derr)
+				}
+			}
+		}
+	}()
+
 	// A host claimed ready by a Cloud provider is not necessarily ready
 	// to be used until ssh service is up and running. So we wait for it before
 	// claiming host is created
 	log.Infof("Waiting start of SSH service on remote host '%s' ...", host.Name)
+	svc.tracker.Step("Waiting for SSH service on host '%s'", host.Name)
 	sshSvc := NewSSHService(svc.provider)
 	sshCfg, err := sshSvc.GetConfig(host.ID)
 	if err != nil {
 		return nil, infraErr(err)
 	}
-	err = sshCfg.WaitServerReady(brokerutils.TimeoutCtxHost)
+	err = sshCfg.WaitServerReadyUnless(brokerutils.TimeoutCtxHost, svc.tracker.Aborted)
 	if err != nil {
+		if abortErr := svc.tracker.Aborted(); abortErr != nil {
+			return nil, abortErr
+		}
 		return nil, infraErr(err)
 	}
 	if client.IsTimeout(err) {
 		return nil, infraErrf(err, "Timeout creating a host")
 	}
 	log.Infof("SSH service started on host '%s'.", host.Name)
+	if err = svc.tracker.Aborted(); err != nil {
+		return nil, err
+	}
 
 	return host, nil
 }
@@ -427,6 +500,68 @@ func (svc *HostService) Get(ref string) (*model.Host, error) {
 	return svc.provider.GetHost(host)
 }
 
+// Import creates the metadata of a host created outside SafeScale, ref is the id or the name of the host in the cloud
+func (svc *HostService) Import(ref string, privateKey string) (*model.Host, error) {
+	log.Debugf("server.services.HostService.Import(%s) called", ref)
+	defer log.Debugf("server.services.HostService.Import(%s) done", ref)
+
+	host, err := metadata.ImportHost(svc.provider, ref, privateKey)
+	if err != nil {
+		return nil, infraErrf(err, "can't import host '%s'", ref)
+	}
+	return host, nil
+}
+
+// Rekey records the SSH host key currently presented by the host referenced by ref, to accept a legitimately changed
+// key (or the key of a host without one). If fingerprint is not empty, the key is accepted only if its fingerprint
+// matches. Returns the fingerprints of the previous key (empty if none) and of the new one
+func (svc *HostService) Rekey(ref string, fingerprint string) (string, string, error) {
+	log.Debugf("server.services.HostService.Rekey(%s) called", ref)
+	defer log.Debugf("server.services.HostService.Rekey(%s) done", ref)
+
+	mh, err := metadata.LoadHost(svc.provider, ref)
+	if err != nil {
+		return "", "", infraErrf(err, "can't rekey host '%s'", ref)
+	}
+	if mh == nil {
+		return "", "", logicErr(fmt.Errorf("host '%s' not found", ref))
+	}
+	err = mh.Acquire()
+	if err != nil {
+		return "", "", infraErrf(err, "can't rekey host '%s'", ref)
+	}
+	defer mh.Release()
+
+	host := mh.Get()
+	sshConfig, err := NewSSHService(svc.provider).GetConfig(host)
+	if err != nil {
+		return "", "", infraErrf(err, "can't rekey host '%s'", ref)
+	}
+	hostKey, err := sshConfig.ScanHostKey()
+	if err != nil {
+		return "", "", infraErrf(err, "can't get SSH host key of host '%s'", ref)
+	}
+	newFingerprint, err := system.HostKeyFingerprint(hostKey)
+	if err != nil {
+		return "", "", infraErrf(err, "can't rekey host '%s'", ref)
+	}
+	if fingerprint != "" && fingerprint != newFingerprint {
+		return "", "", logicErr(fmt.Errorf("SSH host key of host '%s' has fingerprint '%s', not '%s'", ref, newFingerprint, fingerprint))
+	}
+	oldFingerprint := ""
+	if host.HostKey != "" {
+		oldFingerprint, _ = system.HostKeyFingerprint(host.HostKey)
+	}
+
+	host.HostKey = hostKey
+	err = mh.Write()
+	if err != nil {
+		return "", "", infraErrf(err, "can't rekey host '%s'", ref)
+	}
+	log.Infof("SSH host key of host '%s' changed from '%s' to '%s'", host.Name, oldFingerprint, newFingerprint)
+	return oldFingerprint, newFingerprint, nil
+}
+
 // Delete deletes host referenced by ref
 func (svc *HostService) Delete(ref string) error {
 	mh := metadata.NewHost(svc.provider)
@@ -531,6 +666,12 @@ func (svc *HostService) Delete(ref string) error {
 			}
 		}
 
+		// Update the security groups bound to the host
+		err = (&SecurityGroupService{provider: svc.provider}).forgetHost(host.ID)
+		if err != nil {
+			log.Errorf(err.Error())
+		}
+
 		// Finally, delete metadata of host
 		trydelete := mh.Delete()
 		return infraErr(trydelete)
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"github.com/CS-SI/SafeScale/providers"
+	providermetadata "github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+//go:generate mockgen -destination=../mocks/mock_metadataapi.go -package=mocks github.com/CS-SI/SafeScale/broker/server/services MetadataAPI
+
+// MetadataAPI defines API to maintain the metadata of a tenant
+type MetadataAPI interface {
+	ListLocks() ([]*metadata.LeaseInfo, error)
+	BreakLock(key string, force bool) error
+	History(kind string, ref string) ([]*metadata.Revision, error)
+	Restore(kind string, ref string, revision string) error
+	Rekey(fromPlaintext bool, dryRun bool) (*metadata.RekeyReport, error)
+	Fsck(deleteDangling bool, importUntracked bool) ([]*providermetadata.Drift, error)
+}
+
+// NewMetadataService creates a metadata service
+func NewMetadataService(api *providers.Service) MetadataAPI {
+	return &MetadataService{
+		provider: api,
+	}
+}
+
+// MetadataService metadata service
+type MetadataService struct {
+	provider *providers.Service
+}
+
+// ListLocks returns the locks present in the metadata bucket, expired or not
+func (svc *MetadataService) ListLocks() ([]*metadata.LeaseInfo, error) {
+	list, err := metadata.ListLeases(svc.provider)
+	if err != nil {
+		return nil, infraErr(err)
+	}
+	return list, nil
+}
+
+// BreakLock removes the lock on the metadata entry 'key'; a lock still valid is removed only if 'force' is set
+func (svc *MetadataService) BreakLock(key string, force bool) error {
+	return logicErr(metadata.BreakLease(svc.provider, key, force))
+}
+
+// History returns the revisions of the metadata of a resource, oldest first
+func (svc *MetadataService) History(kind string, ref string) ([]*metadata.Revision, error) {
+	list, err := providermetadata.History(svc.provider, kind, ref)
+	if err != nil {
+		return nil, infraErr(err)
+	}
+	return list, nil
+}
+
+// Restore writes back a previous revision of the metadata of a resource
+func (svc *MetadataService) Restore(kind string, ref string, revision string) error {
+	return infraErr(providermetadata.Restore(svc.provider, kind, ref, revision))
+}
+
+// Rekey re-encrypts the whole metadata bucket with the current metadata key
+func (svc *MetadataService) Rekey(fromPlaintext bool, dryRun bool) (*metadata.RekeyReport, error) {
+	report, err := metadata.Rekey(svc.provider, fromPlaintext, dryRun)
+	if err != nil {
+		return nil, logicErr(err)
+	}
+	return report, nil
+}
+
+// Fsck cross-checks the metadata with the resources of the cloud, and repairs the drifts if asked to
+func (svc *MetadataService) Fsck(deleteDangling bool, importUntracked bool) ([]*providermetadata.Drift, error) {
+	drifts, err := providermetadata.Fsck(svc.provider, deleteDangling, importUntracked)
+	if err != nil {
+		return nil, infraErr(err)
+	}
+	return drifts, nil
+}
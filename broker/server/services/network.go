@@ -43,18 +43,26 @@ type NetworkAPI interface {
 	List(all bool) ([]*model.Network, error)
 	Get(ref string) (*model.Network, error)
 	Delete(ref string) error
+	Import(ref string, gateway string) (*model.Network, error)
 }
 
 // NetworkService an implementation of NetworkAPI
 type NetworkService struct {
 	provider  *providers.Service
 	ipVersion IPVersion.Enum
+	tracker   Tracker
 }
 
 // NewNetworkService Creates new Network service
 func NewNetworkService(api *providers.Service) NetworkAPI {
+	return NewTrackedNetworkService(api, nopTracker{})
+}
+
+// NewTrackedNetworkService returns a network service reporting the progress of creations to tracker
+func NewTrackedNetworkService(api *providers.Service, tracker Tracker) NetworkAPI {
 	return &NetworkService{
 		provider: api,
+		tracker:  tracker,
 	}
 }
 
@@ -75,7 +83,14 @@ func (svc *NetworkService) Create(
 		return nil, logicErr(fmt.Errorf("network '%s' already exists", name))
 	}
 
+	// Check the provider can create the network and its gateway
+	err = svc.provider.GetCapabilities().CheckNetwork()
+	if err != nil {
+		return nil, logicErr(fmt.Errorf("failed to create network '%s': %v", name, err))
+	}
+
 	// Create the network
+	svc.tracker.Step("Creating network resource '%s'", name)
 	network, err := svc.provider.CreateNetwork(model.NetworkRequest{
 		Name:      name,
 		IPVersion: ipVersion,
@@ -118,11 +133,22 @@ func (svc *NetworkService) Create(
 		return nil, infraErr(err)
 	}
 
+	// Starting from here, removes network metadata if exiting with err
+	defer func() {
+		if err != nil {
+			derr := metadata.RemoveNetwork(svc.provider, network)
+			if derr != nil {
+				log.Errorf("Failed to remove metadata of network '%s': %v", network.Name, derr)
+			}
+		}
+	}()
+
 	if gwname == "" {
 		gwname = "gw-" + network.Name
 	}
 
 	log.Debugf("Creating compute resource '%s' ...", gwname)
+	svc.tracker.Step("Selecting template and image of gateway '%s'", gwname)
 
 	// Create a gateway
 	tpls, err := svc.provider.SelectTemplatesBySize(model.SizingRequirements{
@@ -157,7 +183,11 @@ func (svc *NetworkService) Create(
 		CIDR:       network.CIDR,
 	}
 
+	if err = svc.tracker.Aborted(); err != nil {
+		return nil, err
+	}
 	log.Infof("Requesting the creation of a gateway '%s' with image '%s'", gwname, img.Name)
+	svc.tracker.Step("Creating gateway '%s' with template '%s' and image '%s'", gwname, tpls[0].Name, img.Name)
 	gw, err := svc.provider.CreateGateway(gwRequest)
 	if err != nil {
 		//defer svc.provider.DeleteNetwork(network.ID)
@@ -197,17 +227,36 @@ func (svc *NetworkService) Create(
 		return nil, infraErrf(err, "Error creating network")
 	}
 
+	if err = svc.tracker.Aborted(); err != nil {
+		return nil, err
+	}
+
 	// Writes Gateway metadata
+	svc.tracker.Step("Writing metadata of gateway '%s'", gw.Name)
 	err = metadata.SaveGateway(svc.provider, gw, network.ID)
 	if err != nil {
 		return nil, infraErrf(err, "failed to create gateway: failed to save metadata: %s", err.Error())
 	}
 
+	// Starting from here, removes gateway metadata if exiting with err
+	defer func() {
+		if err != nil {
+			mgw, derr := metadata.NewGateway(svc.provider, network.ID)
+			if derr == nil {
+				derr = mgw.Carry(gw).Delete()
+			}
+			if derr != nil {
+				log.Errorf("Failed to remove metadata of gateway '%s': %v", gw.Name, derr)
+			}
+		}
+	}()
+
 	log.Debugf("Waiting until gateway '%s' is available through SSH ...", gwname)
 
 	// A host claimed ready by a Cloud provider is not necessarily ready
 	// to be used until ssh service is up and running. So we wait for it before
 	// claiming host is created
+	svc.tracker.Step("Waiting for SSH service on gateway '%s'", gw.Name)
 	sshSvc := NewSSHService(svc.provider)
 	ssh, err := sshSvc.GetConfig(gw.ID)
 	if err != nil {
@@ -216,12 +265,18 @@ func (svc *NetworkService) Create(
 	}
 
 	// TODO Test for failure with 15s !!!
-	err = ssh.WaitServerReady(brokerutils.TimeoutCtxHost)
+	err = ssh.WaitServerReadyUnless(brokerutils.TimeoutCtxHost, svc.tracker.Aborted)
 	// err = ssh.WaitServerReady(time.Second * 3)
 	if err != nil {
+		if abortErr := svc.tracker.Aborted(); abortErr != nil {
+			return nil, abortErr
+		}
 		return nil, logicErrf(err, "Error creating network: Failure waiting for gateway '%s' to finish provisioning and being accessible through SSH", gw.Name)
 	}
 	log.Infof("SSH service of gateway '%s' started.", gw.Name)
+	if err = svc.tracker.Aborted(); err != nil {
+		return nil, err
+	}
 
 	network.GatewayID = gw.ID
 
@@ -268,6 +323,16 @@ func (svc *NetworkService) Get(ref string) (*model.Network, error) {
 	return mn.Get(), infraErr(err)
 }
 
+// Import creates the metadata of a network created outside SafeScale, ref is the id or the name of the network in the cloud
+// gateway, if not empty, references the host (already managed by SafeScale) acting as gateway of the network
+func (svc *NetworkService) Import(ref string, gateway string) (*model.Network, error) {
+	network, err := metadata.ImportNetwork(svc.provider, ref, gateway)
+	if err != nil {
+		return nil, infraErrf(err, "can't import network '%s'", ref)
+	}
+	return network, nil
+}
+
 // Delete deletes network referenced by ref
 func (svc *NetworkService) Delete(ref string) error {
 	mn, err := metadata.LoadNetwork(svc.provider, ref)
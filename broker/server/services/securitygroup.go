@@ -0,0 +1,334 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/utils"
+)
+
+//go:generate mockgen -destination=../mocks/mock_securitygroupapi.go -package=mocks github.com/CS-SI/SafeScale/broker/server/services SecurityGroupAPI
+
+// SecurityGroupAPI defines API to manipulate the security groups
+type SecurityGroupAPI interface {
+	Create(name string, description string, network string) (*model.SecurityGroup, error)
+	List(all bool) ([]model.SecurityGroup, error)
+	Inspect(ref string) (*model.SecurityGroup, error)
+	Delete(ref string) error
+	AddRule(ref string, rule model.SecurityGroupRule) (*model.SecurityGroupRule, error)
+	DeleteRule(ref string, ruleID string) error
+	Bind(ref string, host string) error
+	Unbind(ref string, host string) error
+}
+
+// SecurityGroupService security group service
+type SecurityGroupService struct {
+	provider *providers.Service
+}
+
+// NewSecurityGroupService creates a security group service
+func NewSecurityGroupService(api *providers.Service) SecurityGroupAPI {
+	return &SecurityGroupService{
+		provider: api,
+	}
+}
+
+// Create creates a security group, optionally dedicated to the network referenced by network
+func (svc *SecurityGroupService) Create(name string, description string, network string) (*model.SecurityGroup, error) {
+	log.Debugf("server.services.SecurityGroupService.Create(%s) called", name)
+	defer log.Debugf("server.services.SecurityGroupService.Create(%s) done", name)
+
+	_, err := metadata.LoadSecurityGroup(svc.provider, name)
+	if err == nil {
+		return nil, logicErr(fmt.Errorf("security group '%s' already exists", name))
+	}
+	if _, ok := err.(model.ErrResourceNotFound); !ok {
+		return nil, infraErrf(err, "can't create security group '%s'", name)
+	}
+
+	request := model.SecurityGroupRequest{
+		Name:        name,
+		Description: description,
+	}
+	if network != "" {
+		mn, err := metadata.LoadNetwork(svc.provider, network)
+		if err != nil {
+			return nil, infraErrf(err, "can't create security group '%s'", name)
+		}
+		if mn == nil {
+			return nil, logicErr(model.ResourceNotFoundError("network", network))
+		}
+		request.NetworkID = mn.Get().ID
+	}
+
+	sg, err := svc.provider.CreateSecurityGroup(request)
+	if err != nil {
+		return nil, infraErrf(err, "can't create security group '%s'", name)
+	}
+	err = metadata.SaveSecurityGroup(svc.provider, sg)
+	if err != nil {
+		derr := svc.provider.DeleteSecurityGroup(sg.ID)
+		if derr != nil {
+			log.Errorf("Failed to delete security group '%s' after metadata failure: %v", name, derr)
+		}
+		return nil, infraErrf(err, "can't create security group '%s'", name)
+	}
+	return sg, nil
+}
+
+// List returns the security groups managed by SafeScale, or all the security groups of the tenant if all is true
+func (svc *SecurityGroupService) List(all bool) ([]model.SecurityGroup, error) {
+	if all {
+		groups, err := svc.provider.ListSecurityGroups()
+		return groups, infraErr(err)
+	}
+
+	var groups []model.SecurityGroup
+	err := metadata.NewSecurityGroup(svc.provider).Browse(func(sg *model.SecurityGroup) error {
+		groups = append(groups, *sg)
+		return nil
+	})
+	if err != nil {
+		return nil, infraErrf(err, "Error listing security groups")
+	}
+	return groups, nil
+}
+
+// Inspect returns the security group referenced by ref, with its rules as currently defined in the provider
+func (svc *SecurityGroupService) Inspect(ref string) (*model.SecurityGroup, error) {
+	msg, err := svc.load(ref)
+	if err != nil {
+		return nil, err
+	}
+	sg := msg.Get()
+	current, err := svc.provider.GetSecurityGroup(sg.ID)
+	if err != nil {
+		return nil, infraErrf(err, "can't inspect security group '%s'", ref)
+	}
+	if current == nil {
+		return nil, logicErr(fmt.Errorf("security group '%s' doesn't exist anymore in the provider", ref))
+	}
+	sg.Rules = current.Rules
+	return sg, nil
+}
+
+// Delete deletes the security group referenced by ref, if it's bound to no host
+func (svc *SecurityGroupService) Delete(ref string) error {
+	msg, err := svc.load(ref)
+	if err != nil {
+		return err
+	}
+	err = msg.Acquire()
+	if err != nil {
+		return infraErrf(err, "can't delete security group '%s'", ref)
+	}
+	defer msg.Release()
+
+	sg := msg.Get()
+	nHosts := len(sg.Hosts)
+	if nHosts > 0 {
+		return logicErr(fmt.Errorf("security group '%s' is still bound to %d host%s: %s", ref, nHosts, utils.Plural(nHosts), strings.Join(sg.Hosts, ", ")))
+	}
+	err = svc.provider.DeleteSecurityGroup(sg.ID)
+	if err != nil {
+		return infraErrf(err, "can't delete security group '%s'", ref)
+	}
+	return infraErr(msg.Delete())
+}
+
+// AddRule adds a rule allowing incoming traffic to the security group referenced by ref
+func (svc *SecurityGroupService) AddRule(ref string, rule model.SecurityGroupRule) (*model.SecurityGroupRule, error) {
+	log.Debugf("server.services.SecurityGroupService.AddRule(%s) called", ref)
+	defer log.Debugf("server.services.SecurityGroupService.AddRule(%s) done", ref)
+
+	err := rule.Validate()
+	if err != nil {
+		return nil, logicErrf(err, "invalid rule")
+	}
+	var added *model.SecurityGroupRule
+	err = svc.update(ref, func(sg *model.SecurityGroup) error {
+		var err error
+		added, err = svc.provider.AddRule(sg.ID, rule)
+		if err != nil {
+			return err
+		}
+		sg.Rules = append(sg.Rules, *added)
+		return nil
+	})
+	if err != nil {
+		return nil, infraErrf(err, "can't add rule to security group '%s'", ref)
+	}
+	return added, nil
+}
+
+// DeleteRule deletes the rule identified by ruleID from the security group referenced by ref
+func (svc *SecurityGroupService) DeleteRule(ref string, ruleID string) error {
+	log.Debugf("server.services.SecurityGroupService.DeleteRule(%s, %s) called", ref, ruleID)
+	defer log.Debugf("server.services.SecurityGroupService.DeleteRule(%s, %s) done", ref, ruleID)
+
+	err := svc.update(ref, func(sg *model.SecurityGroup) error {
+		err := svc.provider.DeleteRule(sg.ID, ruleID)
+		if err != nil {
+			return err
+		}
+		for i, r := range sg.Rules {
+			if r.ID == ruleID {
+				sg.Rules = append(sg.Rules[:i], sg.Rules[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+	return infraErrf(err, "can't delete rule '%s' of security group '%s'", ruleID, ref)
+}
+
+// Bind binds the security group referenced by ref to the host referenced by host
+func (svc *SecurityGroupService) Bind(ref string, host string) error {
+	log.Debugf("server.services.SecurityGroupService.Bind(%s, %s) called", ref, host)
+	defer log.Debugf("server.services.SecurityGroupService.Bind(%s, %s) done", ref, host)
+
+	hostID, err := svc.hostID(host)
+	if err != nil {
+		return err
+	}
+	err = svc.update(ref, func(sg *model.SecurityGroup) error {
+		if sg.IsBoundTo(hostID) {
+			return fmt.Errorf("already bound to host '%s'", host)
+		}
+		err := svc.provider.BindToHost(sg.ID, hostID)
+		if err != nil {
+			return err
+		}
+		sg.Hosts = append(sg.Hosts, hostID)
+		return nil
+	})
+	return infraErrf(err, "can't bind security group '%s' to host '%s'", ref, host)
+}
+
+// Unbind unbinds the security group referenced by ref from the host referenced by host
+func (svc *SecurityGroupService) Unbind(ref string, host string) error {
+	log.Debugf("server.services.SecurityGroupService.Unbind(%s, %s) called", ref, host)
+	defer log.Debugf("server.services.SecurityGroupService.Unbind(%s, %s) done", ref, host)
+
+	hostID, err := svc.hostID(host)
+	if err != nil {
+		return err
+	}
+	err = svc.update(ref, func(sg *model.SecurityGroup) error {
+		if !sg.IsBoundTo(hostID) {
+			return fmt.Errorf("not bound to host '%s'", host)
+		}
+		err := svc.provider.UnbindFromHost(sg.ID, hostID)
+		if err != nil {
+			return err
+		}
+		sg.Hosts = removeString(sg.Hosts, hostID)
+		return nil
+	})
+	return infraErrf(err, "can't unbind security group '%s' from host '%s'", ref, host)
+}
+
+// forgetHost removes the host identified by hostID from the hosts bound to the security groups, once the host
+// has been deleted
+func (svc *SecurityGroupService) forgetHost(hostID string) error {
+	var bound []string
+	err := metadata.NewSecurityGroup(svc.provider).Browse(func(sg *model.SecurityGroup) error {
+		if sg.IsBoundTo(hostID) {
+			bound = append(bound, sg.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range bound {
+		err = svc.update(id, func(sg *model.SecurityGroup) error {
+			sg.Hosts = removeString(sg.Hosts, hostID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load returns the metadata of the security group referenced by ref
+func (svc *SecurityGroupService) load(ref string) (*metadata.SecurityGroup, error) {
+	msg, err := metadata.LoadSecurityGroup(svc.provider, ref)
+	if err != nil {
+		if _, ok := err.(model.ErrResourceNotFound); ok {
+			return nil, logicErr(err)
+		}
+		return nil, infraErrf(err, "can't load security group '%s'", ref)
+	}
+	return msg, nil
+}
+
+// update locks the metadata of the security group referenced by ref, applies 'change' to it then saves it
+func (svc *SecurityGroupService) update(ref string, change func(*model.SecurityGroup) error) error {
+	msg, err := metadata.LoadSecurityGroup(svc.provider, ref)
+	if err != nil {
+		return err
+	}
+	err = msg.Acquire()
+	if err != nil {
+		return err
+	}
+	defer msg.Release()
+
+	// Reloads the metadata, which may have changed while waiting for the lock
+	err = msg.Reload()
+	if err != nil {
+		return err
+	}
+	err = change(msg.Get())
+	if err != nil {
+		return err
+	}
+	return msg.Write()
+}
+
+// hostID returns the ID of the host referenced by ref
+func (svc *SecurityGroupService) hostID(ref string) (string, error) {
+	mh, err := metadata.LoadHost(svc.provider, ref)
+	if err != nil {
+		return "", infraErrf(err, "can't load host '%s'", ref)
+	}
+	if mh == nil {
+		return "", logicErr(model.ResourceNotFoundError("host", ref))
+	}
+	return mh.Get().ID, nil
+}
+
+// removeString returns list without the occurrences of s
+func removeString(list []string, s string) []string {
+	result := []string{}
+	for _, e := range list {
+		if e != s {
+			result = append(result, e)
+		}
+	}
+	return result
+}
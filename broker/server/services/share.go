@@ -41,7 +41,9 @@ import (
 
 // ShareAPI defines API to manipulate Shares
 type ShareAPI interface {
-	Create(name, host, path string) (*propsv1.HostShare, error)
+	Create(name, host, path string, acls []nfs.ExportAcl) (*propsv1.HostShare, error)
+	CreateHA(name, primary, secondary, path string, acls []nfs.ExportAcl) (*propsv1.HostShare, error)
+	Update(name string, acls []nfs.ExportAcl) (*propsv1.HostShare, error)
 	Delete(name string) error
 	List() (map[string]map[string]*propsv1.HostShare, error)
 	Mount(name, host, path string) (*propsv1.HostRemoteMount, error)
@@ -69,8 +71,36 @@ func sanitize(in string) (string, error) {
 	return sanitized, nil
 }
 
+// checkExportPath checks the path to share on server isn't a remote mount or contains a remote mount
+func checkExportPath(server *model.Host, sharePath string) error {
+	serverMountsV1 := propsv1.NewHostMounts()
+	err := server.Properties.Get(HostProperty.MountsV1, serverMountsV1)
+	if err != nil {
+		return infraErr(err)
+	}
+	if _, found := serverMountsV1.RemoteMountsByPath[sharePath]; found {
+		return logicErr(fmt.Errorf("path to export '%s' is a mounted share", sharePath))
+	}
+	for k := range serverMountsV1.RemoteMountsByPath {
+		if strings.Index(sharePath, k) == 0 {
+			return logicErr(fmt.Errorf("export path '%s' contains a share mounted in '%s'", sharePath, k))
+		}
+	}
+	return nil
+}
+
+// exportHost returns the address the clients mount the share from: the VIP for a highly available share,
+// the access IP of server otherwise
+func exportHost(server *model.Host, share *propsv1.HostShare) string {
+	if share.HA != nil {
+		return share.HA.VIP
+	}
+	return server.GetAccessIP()
+}
+
 // Create a share on host
-func (svc *ShareService) Create(shareName, hostName, path string) (*propsv1.HostShare, error) {
+// If acls is empty, every client is allowed to mount the share in read-write mode
+func (svc *ShareService) Create(shareName, hostName, path string, acls []nfs.ExportAcl) (*propsv1.HostShare, error) {
 	// Check if a share already exists with the same name
 	server, _, _, err := svc.Inspect(shareName)
 	if err != nil {
@@ -101,19 +131,9 @@ func (svc *ShareService) Create(shareName, hostName, path string) (*propsv1.Host
 		}
 	}
 
-	// Check if the path to share isn't a remote mount or contains a remote mount
-	serverMountsV1 := propsv1.NewHostMounts()
-	err = server.Properties.Get(HostProperty.MountsV1, serverMountsV1)
+	err = checkExportPath(server, sharePath)
 	if err != nil {
-		return nil, infraErr(err)
-	}
-	if _, found := serverMountsV1.RemoteMountsByPath[path]; found {
-		return nil, logicErr(fmt.Errorf("path to export '%s' is a mounted share", sharePath))
-	}
-	for k := range serverMountsV1.RemoteMountsByPath {
-		if strings.Index(sharePath, k) == 0 {
-			return nil, logicErr(fmt.Errorf("export path '%s' contains a share mounted in '%s'", sharePath, k))
-		}
+		return nil, err
 	}
 
 	// Installs NFS Server software if needed
@@ -138,7 +158,8 @@ func (svc *ShareService) Create(shareName, hostName, path string) (*propsv1.Host
 			return nil, infraErr(err)
 		}
 	}
-	err = nfsServer.AddShare(sharePath, "")
+	shareAcls := nfs.RenderExportAcls(acls)
+	err = nfsServer.AddShare(sharePath, shareAcls)
 	if err != nil {
 		return nil, infraErr(err)
 	}
@@ -153,6 +174,7 @@ func (svc *ShareService) Create(shareName, hostName, path string) (*propsv1.Host
 	share.ID = shareID.String()
 	share.Path = sharePath
 	share.Type = "nfs"
+	share.ShareAcls = shareAcls
 
 	serverSharesV1.ByID[share.ID] = share
 	serverSharesV1.ByName[share.Name] = share.ID
@@ -175,6 +197,343 @@ func (svc *ShareService) Create(shareName, hostName, path string) (*propsv1.Host
 	return share, nil
 }
 
+// haServer gathers what is needed to configure a server of a highly available share
+type haServer struct {
+	host   *model.Host
+	nfs    *nfs.Server
+	ip     string
+	shares *propsv1.HostShares
+}
+
+// CreateHA creates a highly available share, replicated between the hosts primary and secondary, the clients
+// mounting it from a VIP held by primary while its NFS service runs, by secondary otherwise
+// If acls is empty, every client is allowed to mount the share in read-write mode
+func (svc *ShareService) CreateHA(shareName, primary, secondary, path string, acls []nfs.ExportAcl) (*propsv1.HostShare, error) {
+	// Check if a share already exists with the same name
+	server, _, _, err := svc.Inspect(shareName)
+	if err != nil {
+		switch err.(type) {
+		case model.ErrResourceNotFound:
+		default:
+			return nil, infraErr(err)
+		}
+	}
+	if server != nil {
+		return nil, logicErr(model.ResourceAlreadyExistsError("share", shareName))
+	}
+
+	// Sanitize path
+	sharePath, err := sanitize(path)
+	if err != nil {
+		return nil, infraErr(err)
+	}
+
+	// Checks the servers, which must be in the same network
+	hostSvc := NewHostService(svc.provider)
+	sshSvc := NewSSHService(svc.provider)
+	var (
+		servers   []*haServer
+		networkID string
+	)
+	for _, ref := range []string{primary, secondary} {
+		host, err := hostSvc.Get(ref)
+		if err != nil {
+			switch err.(type) {
+			case model.ErrResourceNotFound:
+				return nil, err
+			default:
+				return nil, infraErr(err)
+			}
+		}
+		if len(servers) > 0 && host.ID == servers[0].host.ID {
+			return nil, logicErr(fmt.Errorf("the servers of a highly available share must be different hosts"))
+		}
+		err = checkExportPath(host, sharePath)
+		if err != nil {
+			return nil, err
+		}
+		hostNetworkV1 := propsv1.NewHostNetwork()
+		err = host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+		if networkID == "" {
+			networkID = hostNetworkV1.DefaultNetworkID
+		}
+		ip, found := hostNetworkV1.IPv4Addresses[networkID]
+		if !found {
+			return nil, logicErr(fmt.Errorf("host '%s' has no IP address in network '%s'", host.Name, networkID))
+		}
+		sshConfig, err := sshSvc.GetConfig(host)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+		nfsServer, err := nfs.NewServer(sshConfig)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+		hostSharesV1 := propsv1.NewHostShares()
+		err = host.Properties.Get(HostProperty.SharesV1, hostSharesV1)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+		servers = append(servers, &haServer{host: host, nfs: nfsServer, ip: ip, shares: hostSharesV1})
+	}
+
+	// Installs NFS Server, GlusterFS and keepalived software if needed
+	for _, s := range servers {
+		if len(s.shares.ByID) == 0 {
+			err = s.nfs.Install()
+			if err != nil {
+				return nil, infraErr(err)
+			}
+		}
+		err = s.nfs.InstallHA()
+		if err != nil {
+			return nil, infraErr(err)
+		}
+	}
+
+	shareID, err := uuid.NewV4()
+	if err != nil {
+		return nil, logicErrf(err, "Error creating UUID for share")
+	}
+	ha := propsv1.NewHostShareHA()
+	ha.Volume = "share-" + shareID.String()
+	ha.PrimaryID = servers[0].host.ID
+	for _, s := range servers {
+		ha.ServersByID[s.host.ID] = s.host.Name
+		ha.ServersIP[s.host.ID] = s.ip
+	}
+
+	// Creates the VIP the clients mount the share from, and allows the servers to hold it
+	vip, err := svc.provider.CreateVIP(networkID, ha.Volume)
+	if err != nil {
+		return nil, infraErrf(err, "can't create VIP of share '%s'", shareName)
+	}
+
+	// Starting from here, delete VIP if exit with error
+	defer func() {
+		if err != nil {
+			derr := svc.provider.DeleteVIP(vip)
+			if derr != nil {
+				log.Errorf("failed to delete VIP of share '%s': %v", shareName, derr)
+			}
+		}
+	}()
+
+	for _, s := range servers {
+		err = svc.provider.BindHostToVIP(vip, s.host.ID)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+		vip.Hosts = append(vip.Hosts, s.host.ID)
+	}
+	ha.VIPID = vip.ID
+	ha.VIPNetworkID = vip.NetworkID
+	ha.VIP = vip.PrivateIP
+
+	// Replicates the share between the servers
+	err = servers[0].nfs.CreateReplicatedVolume(ha.Volume, servers[0].ip, []string{servers[1].ip})
+	if err != nil {
+		return nil, infraErr(err)
+	}
+
+	// Starting from here, unconfigure the share on the servers if exit with error; the primary server deletes
+	// the GlusterFS volume
+	defer func() {
+		if err != nil {
+			for i, s := range servers {
+				derr := s.nfs.RemoveReplicatedShare(ha.Volume, ha.Volume, sharePath, i == 0)
+				if derr != nil {
+					log.Errorf("failed to remove share '%s' from host '%s': %v", shareName, s.host.Name, derr)
+				}
+			}
+		}
+	}()
+
+	// Exports the share on every server (with the same FSID, for the clients to be unaware of the failover) and
+	// configures the move of the VIP
+	shareAcls := nfs.RenderExportAcls(acls)
+	for i, s := range servers {
+		err = s.nfs.AddReplicatedShare(ha.Volume, sharePath, shareAcls, shareID.String())
+		if err != nil {
+			return nil, infraErr(err)
+		}
+		peers := []string{}
+		for _, p := range servers {
+			if p != s {
+				peers = append(peers, p.ip)
+			}
+		}
+		err = s.nfs.SetVIP(ha.Volume, ha.VIP, s.ip, peers, i == 0)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+	}
+
+	// Create share struct
+	share := propsv1.NewHostShare()
+	share.ID = shareID.String()
+	share.Name = shareName
+	share.Path = sharePath
+	share.Type = "nfs"
+	share.ShareAcls = shareAcls
+	share.HA = ha
+
+	// Updates Host Property propsv1.HostShares of every server; if exit with error, removes the share from the
+	// metadata already written
+	written := []*model.Host{}
+	defer func() {
+		if err != nil {
+			for _, host := range written {
+				derr := svc.updateHostShares(host, func(hostSharesV1 *propsv1.HostShares) error {
+					delete(hostSharesV1.ByID, share.ID)
+					delete(hostSharesV1.ByName, share.Name)
+					return nil
+				})
+				if derr != nil {
+					log.Errorf("failed to remove share '%s' from metadata of host '%s': %v", shareName, host.Name, derr)
+				}
+			}
+		}
+	}()
+	for _, s := range servers {
+		err = svc.updateHostShares(s.host, func(hostSharesV1 *propsv1.HostShares) error {
+			hostSharesV1.ByID[share.ID] = share
+			hostSharesV1.ByName[share.Name] = share.ID
+			return nil
+		})
+		if err != nil {
+			return nil, logicErrf(err, "Error saving server metadata")
+		}
+		written = append(written, s.host)
+	}
+	// The primary server owns the share
+	err = metadata.SaveShare(svc.provider, servers[0].host.ID, servers[0].host.Name, share.ID, share.Name)
+	if err != nil {
+		return nil, infraErr(err)
+	}
+
+	return share, nil
+}
+
+// shareServers returns the hosts serving the share exported by server, server first
+func (svc *ShareService) shareServers(server *model.Host, share *propsv1.HostShare) ([]*model.Host, error) {
+	servers := []*model.Host{server}
+	if share.HA == nil {
+		return servers, nil
+	}
+	hostSvc := NewHostService(svc.provider)
+	for id := range share.HA.ServersByID {
+		if id == server.ID {
+			continue
+		}
+		host, err := hostSvc.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, host)
+	}
+	return servers, nil
+}
+
+// Update replaces the ACLs of a share, without unmounting it from the clients still allowed
+// If acls is empty, every client is allowed to mount the share in read-write mode
+func (svc *ShareService) Update(name string, acls []nfs.ExportAcl) (*propsv1.HostShare, error) {
+	server, share, _, err := svc.Inspect(name)
+	if err != nil {
+		return nil, infraErr(err)
+	}
+	if server == nil {
+		return nil, logicErr(model.ResourceNotFoundError("share", name))
+	}
+	servers, err := svc.shareServers(server, share)
+	if err != nil {
+		return nil, infraErr(err)
+	}
+
+	shareAcls := nfs.RenderExportAcls(acls)
+	sshSvc := NewSSHService(svc.provider)
+	var updated *propsv1.HostShare
+	for _, host := range servers {
+		sshConfig, err := sshSvc.GetConfig(host)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+		nfsServer, err := nfs.NewServer(sshConfig)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+		err = nfsServer.UpdateShare(share.Path, shareAcls)
+		if err != nil {
+			return nil, infraErr(err)
+		}
+
+		hostShare, err := svc.updateShareAcls(host, share, shareAcls)
+		if err != nil {
+			return nil, err
+		}
+		if updated == nil {
+			updated = hostShare
+		}
+	}
+	return updated, nil
+}
+
+// updateShareAcls records the ACLs of a share in the metadata of a server, locked while updated
+func (svc *ShareService) updateShareAcls(server *model.Host, share *propsv1.HostShare, shareAcls string) (*propsv1.HostShare, error) {
+	var hostShare *propsv1.HostShare
+	err := svc.updateHostShares(server, func(hostSharesV1 *propsv1.HostShares) error {
+		var found bool
+		hostShare, found = hostSharesV1.ByID[share.ID]
+		if !found {
+			return logicErr(fmt.Errorf("failed to find metadata about share '%s' in host '%s'", share.Name, server.Name))
+		}
+		hostShare.ShareAcls = shareAcls
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hostShare, nil
+}
+
+// updateHostShares locks the metadata of a server, reloads it, applies 'update' on its shares, then writes it
+func (svc *ShareService) updateHostShares(server *model.Host, update func(*propsv1.HostShares) error) error {
+	mh := metadata.NewHost(svc.provider).Carry(server)
+	err := mh.Acquire()
+	if err != nil {
+		return infraErr(err)
+	}
+	defer mh.Release()
+
+	// Reloads the metadata, which may have changed since the server was read
+	err = mh.Reload()
+	if err != nil {
+		return infraErr(err)
+	}
+	host := mh.Get()
+	hostSharesV1 := propsv1.NewHostShares()
+	err = host.Properties.Get(HostProperty.SharesV1, hostSharesV1)
+	if err != nil {
+		return infraErr(err)
+	}
+	err = update(hostSharesV1)
+	if err != nil {
+		return err
+	}
+	err = host.Properties.Set(HostProperty.SharesV1, hostSharesV1)
+	if err != nil {
+		return infraErr(err)
+	}
+	err = mh.Write()
+	if err != nil {
+		return infraErr(err)
+	}
+	return nil
+}
+
 // Delete a share from host
 func (svc *ShareService) Delete(name string) error {
 	// Retrieve info about the share
@@ -198,6 +557,9 @@ func (svc *ShareService) Delete(name string) error {
 		}
 		return logicErr(fmt.Errorf("host%s still using it: %s", utils.Plural(len(list)), strings.Join(list, ",")))
 	}
+	if share.HA != nil {
+		return svc.deleteHA(server, share)
+	}
 
 	sshSvc := NewSSHService(svc.provider)
 	sshConfig, err := sshSvc.GetConfig(server.ID)
@@ -232,6 +594,61 @@ func (svc *ShareService) Delete(name string) error {
 	return infraErr(remErr)
 }
 
+// deleteHA deletes the highly available share exported by server
+func (svc *ShareService) deleteHA(server *model.Host, share *propsv1.HostShare) error {
+	servers, err := svc.shareServers(server, share)
+	if err != nil {
+		return infraErr(err)
+	}
+
+	// server, first of the list, deletes the GlusterFS volume
+	sshSvc := NewSSHService(svc.provider)
+	for i, host := range servers {
+		sshConfig, err := sshSvc.GetConfig(host)
+		if err != nil {
+			return infraErr(err)
+		}
+		nfsServer, err := nfs.NewServer(sshConfig)
+		if err != nil {
+			return infraErr(err)
+		}
+		err = nfsServer.RemoveReplicatedShare(share.HA.Volume, share.HA.Volume, share.Path, i == 0)
+		if err != nil {
+			return infraErr(err)
+		}
+	}
+
+	vip := &model.VIP{
+		ID:        share.HA.VIPID,
+		Name:      share.HA.Volume,
+		NetworkID: share.HA.VIPNetworkID,
+		PrivateIP: share.HA.VIP,
+	}
+	for id := range share.HA.ServersByID {
+		vip.Hosts = append(vip.Hosts, id)
+	}
+	err = svc.provider.DeleteVIP(vip)
+	if err != nil {
+		return infraErr(err)
+	}
+
+	// Save servers metadata
+	for _, host := range servers {
+		err = svc.updateHostShares(host, func(hostSharesV1 *propsv1.HostShares) error {
+			delete(hostSharesV1.ByID, share.ID)
+			delete(hostSharesV1.ByName, share.Name)
+			return nil
+		})
+		if err != nil {
+			return infraErr(err)
+		}
+	}
+
+	// Remove share metadata
+	remErr := metadata.RemoveShare(svc.provider, server.ID, server.Name, share.ID, share.Name)
+	return infraErr(remErr)
+}
+
 // List return the list of all shares from all servers
 func (svc *ShareService) List() (map[string]map[string]*propsv1.HostShare, error) {
 	shares := map[string]map[string]*propsv1.HostShare{}
@@ -264,7 +681,14 @@ func (svc *ShareService) List() (map[string]map[string]*propsv1.HostShare, error
 			return nil, infraErr(err)
 		}
 
-		shares[serverID] = hostSharesV1.ByID
+		// A highly available share is listed only with its primary server
+		hostShares := map[string]*propsv1.HostShare{}
+		for id, share := range hostSharesV1.ByID {
+			if share.HA == nil || share.HA.PrimaryID == host.ID {
+				hostShares[id] = share
+			}
+		}
+		shares[serverID] = hostShares
 	}
 	return shares, nil
 }
@@ -352,7 +776,7 @@ func (svc *ShareService) Mount(shareName, hostName, path string) (*propsv1.HostR
 		return nil, infraErr(err)
 	}
 
-	err = nfsClient.Mount(server.GetAccessIP(), share.Path, mountPath)
+	err = nfsClient.Mount(exportHost(server, share), share.Path, mountPath)
 	if err != nil {
 		return nil, infraErr(err)
 	}
@@ -366,7 +790,7 @@ func (svc *ShareService) Mount(shareName, hostName, path string) (*propsv1.HostR
 
 	mount := propsv1.NewHostRemoteMount()
 	mount.ShareID = share.ID
-	mount.Export = server.GetAccessIP() + ":" + share.Path
+	mount.Export = exportHost(server, share) + ":" + share.Path
 	mount.Path = mountPath
 	mount.FileSystem = "nfs"
 	targetMountsV1.RemoteMountsByPath[mount.Path] = mount
@@ -445,7 +869,11 @@ func (svc *ShareService) Unmount(shareName, hostName string) error {
 	if err != nil {
 		return infraErr(err)
 	}
-	err = nfsClient.Unmount(server.GetAccessIP(), mount.Path)
+	export := strings.SplitN(mount.Export, ":", 2)
+	if len(export) != 2 {
+		return logicErr(fmt.Errorf("invalid export '%s' of share mounted in '%s:%s'", mount.Export, target.Name, mount.Path))
+	}
+	err = nfsClient.Unmount(export[0], export[1])
 	if err != nil {
 		return infraErr(err)
 	}
@@ -453,6 +881,7 @@ func (svc *ShareService) Unmount(shareName, hostName string) error {
 	// Remove mount from mount list
 	delete(targetMountsV1.RemoteMountsByShareID, mount.ShareID)
 	delete(targetMountsV1.RemoteMountsByPath, mount.Path)
+	delete(targetMountsV1.RemoteMountsByExport, mount.Export)
 	err = target.Properties.Set(HostProperty.MountsV1, targetMountsV1)
 	if err != nil {
 		return infraErr(err)
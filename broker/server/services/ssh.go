@@ -85,6 +85,7 @@ func (svc *SSHService) GetConfig(hostParam interface{}) (*system.SSHConfig, erro
 		Port:       22,
 		Host:       host.GetAccessIP(),
 		User:       model.DefaultUser,
+		HostKey:    host.HostKey,
 	}
 	hostNetworkV1 := propsv1.NewHostNetwork()
 	err := host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
@@ -104,6 +105,7 @@ func (svc *SSHService) GetConfig(hostParam interface{}) (*system.SSHConfig, erro
 			Port:       22,
 			Host:       gw.GetAccessIP(),
 			User:       model.DefaultUser,
+			HostKey:    gw.HostKey,
 		}
 		sshConfig.GatewayConfig = &GatewayConfig
 	}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+// Tracker receives the progress of a long running action and tells if it has to be aborted
+type Tracker interface {
+	// Step reports the beginning of a new step of the action
+	Step(format string, a ...interface{})
+	// Aborted returns an error if the action has to stop (and roll back what it did)
+	Aborted() error
+}
+
+// nopTracker is the Tracker used when nobody watches the action
+type nopTracker struct{}
+
+// Step does nothing
+func (nopTracker) Step(format string, a ...interface{}) {}
+
+// Aborted never asks to stop
+func (nopTracker) Aborted() error {
+	return nil
+}
@@ -48,6 +48,7 @@ type VolumeAPI interface {
 	Create(name string, size int, speed VolumeSpeed.Enum) (*model.Volume, error)
 	Attach(volume string, host string, path string, format string) error
 	Detach(volume string, host string) error
+	Import(ref string) (*model.Volume, error)
 }
 
 // VolumeService volume service
@@ -136,6 +137,15 @@ func (svc *VolumeService) Get(ref string) (*model.Volume, error) {
 	return mv.Get(), nil
 }
 
+// Import creates the metadata of a volume created outside SafeScale, ref is the id or the name of the volume in the cloud
+func (svc *VolumeService) Import(ref string) (*model.Volume, error) {
+	volume, err := metadata.ImportVolume(svc.provider, ref)
+	if err != nil {
+		return nil, infraErrf(err, "can't import volume '%s'", ref)
+	}
+	return volume, nil
+}
+
 // Inspect returns the volume identified by ref and its attachment (if any)
 func (svc *VolumeService) Inspect(ref string) (*model.Volume, map[string]*propsv1.HostLocalMount, error) {
 	volume, err := svc.Get(ref)
@@ -183,6 +193,11 @@ func (svc *VolumeService) Inspect(ref string) (*model.Volume, map[string]*propsv
 
 // Create a volume
 func (svc *VolumeService) Create(name string, size int, speed VolumeSpeed.Enum) (*model.Volume, error) {
+	err := svc.provider.GetCapabilities().CheckVolume(speed)
+	if err != nil {
+		return nil, logicErr(fmt.Errorf("failed to create volume '%s': %v", name, err))
+	}
+
 	volume, err := svc.provider.CreateVolume(model.VolumeRequest{
 		Name:  name,
 		Size:  size,
@@ -274,6 +289,12 @@ func (svc *VolumeService) Attach(volumeName, hostName, path, format string) erro
 		return nil
 	}
 
+	// Check the provider allows another volume on the host
+	err = svc.provider.GetCapabilities().CheckVolumeAttachment(len(hostVolumesV1.VolumesByID))
+	if err != nil {
+		return logicErr(fmt.Errorf("Can't attach volume '%s' to '%s': %v", volume.Name, host.Name, err))
+	}
+
 	// Check if there is no other device mounted in the path (or in subpath)
 	for _, i := range hostMountsV1.LocalMountsByPath {
 		if strings.Index(i.Path, mountPoint) == 0 {
@@ -17,11 +17,17 @@
 package utils
 
 import (
+	"fmt"
+
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/server/operations"
+	providermetadata "github.com/CS-SI/SafeScale/providers/metadata"
 	"github.com/CS-SI/SafeScale/providers/model"
 	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
 	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
 	"github.com/CS-SI/SafeScale/system"
+	"github.com/CS-SI/SafeScale/system/firewall"
+	"github.com/CS-SI/SafeScale/utils/metadata"
 )
 
 // ToPBSshConfig converts a system.SSHConfig into a SshConfig
@@ -36,6 +42,7 @@ func ToPBSshConfig(from *system.SSHConfig) *pb.SshConfig {
 		Port:       int32(from.Port),
 		PrivateKey: from.PrivateKey,
 		User:       from.User,
+		HostKey:    from.HostKey,
 	}
 }
 
@@ -51,6 +58,7 @@ func ToSystemSshConfig(from *pb.SshConfig) *system.SSHConfig {
 		PrivateKey:    from.PrivateKey,
 		Port:          int(from.Port),
 		GatewayConfig: gw,
+		HostKey:       from.HostKey,
 	}
 }
 
@@ -117,13 +125,23 @@ func ToPBBucketMountPoint(in *model.Bucket) *pb.BucketMountingPoint {
 
 // ToPBShare convert a share from model to protocolbuffer format
 func ToPBShare(hostName string, share *propsv1.HostShare) *pb.ShareDefinition {
-	return &pb.ShareDefinition{
+	pbShare := &pb.ShareDefinition{
 		ID:   share.ID,
 		Name: share.Name,
 		Host: &pb.Reference{Name: hostName},
 		Path: share.Path,
 		Type: "nfs",
+		Acls: share.ShareAcls,
+	}
+	if share.HA != nil {
+		pbShare.VIP = share.HA.VIP
+		for id, name := range share.HA.ServersByID {
+			if id != share.HA.PrimaryID {
+				pbShare.Secondary = &pb.Reference{ID: id, Name: name}
+			}
+		}
 	}
+	return pbShare
 }
 
 // ToPBShareMount convert share mount on host to protocolbuffer format
@@ -217,3 +235,206 @@ func ToPBNetwork(in *model.Network) *pb.Network {
 		GatewayID: in.GatewayID,
 	}
 }
+
+// ToPBOperation converts an operation of brokerd to protocolbuffer format
+func ToPBOperation(in *operations.Operation) *pb.Operation {
+	state, resultID, err := in.Status()
+	pbop := &pb.Operation{
+		ID:       in.ID,
+		Kind:     in.Kind,
+		Target:   in.Target,
+		State:    pb.OperationState(state),
+		ResultID: resultID,
+		Started:  in.Started().Unix(),
+	}
+	if err != nil {
+		pbop.Error = err.Error()
+	}
+	return pbop
+}
+
+// ToPBOperationEvent converts an event of an operation to protocolbuffer format
+func ToPBOperationEvent(operationID string, in operations.Event) *pb.OperationEvent {
+	return &pb.OperationEvent{
+		OperationID: operationID,
+		Timestamp:   in.Timestamp.Unix(),
+		Message:     in.Message,
+		State:       pb.OperationState(in.State),
+	}
+}
+
+// ToPBMetadataLock converts a lock of the metadata to protocolbuffer format
+func ToPBMetadataLock(in *metadata.LeaseInfo) *pb.MetadataLock {
+	return &pb.MetadataLock{
+		Key:        in.Key,
+		Owner:      in.Owner,
+		Hostname:   in.Hostname,
+		PID:        int32(in.PID),
+		AcquiredAt: in.AcquiredAt.Unix(),
+		ExpiresAt:  in.ExpiresAt.Unix(),
+		Expired:    in.Expired(),
+	}
+}
+
+// ToPBMetadataRevision converts a revision of metadata to protocolbuffer format
+func ToPBMetadataRevision(in *metadata.Revision) *pb.MetadataRevision {
+	return &pb.MetadataRevision{
+		ID:        in.ID,
+		Timestamp: in.Timestamp.Unix(),
+		Author:    in.Author,
+		Deleted:   in.Deleted,
+		Size:      int32(in.Size),
+	}
+}
+
+// ToPBMetadataDrift converts a drift between metadata and cloud to protocolbuffer format
+func ToPBMetadataDrift(in *providermetadata.Drift) *pb.MetadataDrift {
+	return &pb.MetadataDrift{
+		Kind:     in.Kind,
+		Type:     in.Type,
+		ID:       in.ID,
+		Name:     in.Name,
+		Details:  in.Details,
+		Repaired: in.Repaired,
+		Error:    in.Error,
+	}
+}
+
+// ToPBMetadataRekeyReport converts a report of metadata re-encryption to protocolbuffer format
+func ToPBMetadataRekeyReport(in *metadata.RekeyReport) *pb.MetadataRekeyReport {
+	return &pb.MetadataRekeyReport{
+		KeyID:    in.KeyID,
+		Total:    int32(in.Total),
+		Rekeyed:  int32(in.Rekeyed),
+		Skipped:  int32(in.Skipped),
+		Failures: in.Failures,
+	}
+}
+
+// ToPBFirewallRule converts a firewall rule to protocolbuffer format
+func ToPBFirewallRule(in *firewall.Rule) *pb.FirewallRule {
+	return &pb.FirewallRule{
+		Target:        in.TargetName(),
+		Protocol:      in.Protocol,
+		From:          in.From,
+		FromPort:      in.FromPort,
+		To:            in.To,
+		ToPort:        in.ToPort,
+		InInterface:   in.InInterface,
+		OutInterface:  in.OutInterface,
+		Matches:       in.Matches,
+		TargetOptions: in.TargetOptions,
+	}
+}
+
+// ToSystemFirewallRule converts a firewall rule in protocolbuffer format to firewall.Rule
+func ToSystemFirewallRule(in *pb.FirewallRule) (*firewall.Rule, error) {
+	rule := &firewall.Rule{
+		Protocol:      in.GetProtocol(),
+		From:          in.GetFrom(),
+		FromPort:      in.GetFromPort(),
+		To:            in.GetTo(),
+		ToPort:        in.GetToPort(),
+		InInterface:   in.GetInInterface(),
+		OutInterface:  in.GetOutInterface(),
+		Matches:       in.GetMatches(),
+		TargetOptions: in.GetTargetOptions(),
+	}
+	if action, ok := firewall.ParseAction(in.GetTarget()); ok {
+		rule.Action = action
+	} else {
+		rule.Target = in.GetTarget()
+	}
+	err := rule.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// ToPBFirewall converts the tables of a firewall to protocolbuffer format
+func ToPBFirewall(host string, in *firewall.Firewall) *pb.Firewall {
+	out := &pb.Firewall{Host: &pb.Reference{Name: host}}
+	for _, tableName := range in.TableNames() {
+		table := in.Tables[tableName]
+		pbTable := &pb.FirewallTable{Name: table.Name}
+		for _, chainName := range table.ChainNames() {
+			chain, ok := table.Chains[chainName]
+			if !ok {
+				continue
+			}
+			pbChain := &pb.FirewallChain{Name: chain.Name, Policy: chain.PolicyName()}
+			for i := range chain.Rules {
+				pbChain.Rules = append(pbChain.Rules, ToPBFirewallRule(&chain.Rules[i]))
+			}
+			pbTable.Chains = append(pbTable.Chains, pbChain)
+		}
+		out.Tables = append(out.Tables, pbTable)
+	}
+	return out
+}
+
+// ToSystemFirewall converts firewall tables in protocolbuffer format to a firewall.Firewall
+func ToSystemFirewall(in *pb.Firewall) (*firewall.Firewall, error) {
+	out := firewall.NewFirewall(nil)
+	out.Tables = map[string]*firewall.Table{}
+	for _, pbTable := range in.GetTables() {
+		table := &firewall.Table{Name: pbTable.GetName()}
+		for _, pbChain := range pbTable.GetChains() {
+			policy, ok := firewall.ParsePolicy(pbChain.GetPolicy())
+			if pbChain.GetPolicy() == "" {
+				policy, ok = 0, true
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid policy '%s' of chain '%s'", pbChain.GetPolicy(), pbChain.GetName())
+			}
+			chain := table.NewChain(pbChain.GetName(), policy)
+			for i, pbRule := range pbChain.GetRules() {
+				rule, err := ToSystemFirewallRule(pbRule)
+				if err != nil {
+					return nil, fmt.Errorf("invalid rule #%d of chain '%s': %s", i+1, pbChain.GetName(), err.Error())
+				}
+				chain.Add(*rule)
+			}
+		}
+		out.Tables[table.Name] = table
+	}
+	return out, nil
+}
+
+// ToPBSecurityGroupRule converts a model.SecurityGroupRule to protocolbuffer format
+func ToPBSecurityGroupRule(in *model.SecurityGroupRule) *pb.SecurityGroupRule {
+	return &pb.SecurityGroupRule{
+		ID:       in.ID,
+		Protocol: in.Protocol,
+		FromPort: int32(in.FromPort),
+		ToPort:   int32(in.ToPort),
+		CIDR:     in.CIDR,
+	}
+}
+
+// ToModelSecurityGroupRule converts a security group rule in protocolbuffer format to a model.SecurityGroupRule
+func ToModelSecurityGroupRule(in *pb.SecurityGroupRule) model.SecurityGroupRule {
+	return model.SecurityGroupRule{
+		ID:       in.GetID(),
+		Protocol: in.GetProtocol(),
+		FromPort: int(in.GetFromPort()),
+		ToPort:   int(in.GetToPort()),
+		CIDR:     in.GetCIDR(),
+	}
+}
+
+// ToPBSecurityGroup converts a model.SecurityGroup to protocolbuffer format
+func ToPBSecurityGroup(in *model.SecurityGroup) *pb.SecurityGroup {
+	out := &pb.SecurityGroup{
+		ID:          in.ID,
+		Name:        in.Name,
+		Description: in.Description,
+		NetworkID:   in.NetworkID,
+		HostIDs:     in.Hosts,
+	}
+	for i := range in.Rules {
+		out.Rules = append(out.Rules, ToPBSecurityGroupRule(&in.Rules[i]))
+	}
+	return out
+}
@@ -18,13 +18,13 @@ package utils
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"strings"
 	"time"
 
 	pb "github.com/CS-SI/SafeScale/broker"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -32,16 +32,22 @@ const (
 	TimeoutCtxDefault = 1 * time.Minute
 	// TimeoutCtxHost timeout for grpc command relative to host creation
 	TimeoutCtxHost = 5 * time.Minute
+
+	// TenantMetadataKey is the key of the grpc metadata carrying the name of the tenant targeted by a request
+	TenantMetadataKey = "safescale-tenant"
 )
 
 // GetConnection returns a connection to GRPC server
-func GetConnection(host string, port int) *grpc.ClientConn {
-	address := fmt.Sprintf("%s:%d", host, port)
+func GetConnection(config ConnectionConfig) *grpc.ClientConn {
+	opts, err := config.DialOptions()
+	if err != nil {
+		log.Fatalf("Failed to configure connection to brokerd (%s): %v", config.Address, err)
+	}
 
 	// Set up a connection to the server.
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := grpc.Dial(config.Address, opts...)
 	if err != nil {
-		log.Fatalf("Failed to connect to brokerd (%s:%d): %v", host, port, err)
+		log.Fatalf("Failed to connect to brokerd (%s): %v", config.Address, err)
 	}
 	return conn
 }
@@ -65,3 +71,27 @@ func GetReference(in *pb.Reference) string {
 	}
 	return ref
 }
+
+// WithTenant returns a copy of ctx carrying the name of the tenant to use in the outgoing grpc metadata
+func WithTenant(ctx context.Context, tenantName string) context.Context {
+	if tenantName == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, TenantMetadataKey, tenantName)
+}
+
+// TenantFromContext returns the name of the tenant carried by the incoming grpc metadata, or "" if there is none
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(TenantMetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
@@ -0,0 +1,282 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultBrokerdAddress is the address brokerd listens on and broker connects to by default
+	DefaultBrokerdAddress = "localhost:50051"
+
+	// unixSocketPrefix is the prefix of an address designating a unix socket
+	unixSocketPrefix = "unix:"
+
+	authorizationMetadataKey = "authorization"
+	bearerPrefix             = "Bearer "
+)
+
+// ConnectionConfig contains the parameters used by the broker client to reach brokerd
+type ConnectionConfig struct {
+	// Address is either "host:port" or "unix:<path of socket>"
+	Address string
+	// CAFile is the certificate of the authority which signed the certificate of brokerd; TLS is used if set
+	CAFile string
+	// CertFile and KeyFile are the certificate and the key of the client, used for mutual TLS
+	CertFile string
+	KeyFile  string
+	// Token is the bearer token sent with each request
+	Token string
+}
+
+// ServerConfig contains the parameters used by brokerd to listen to clients
+type ServerConfig struct {
+	// Address is either "[host]:port" or "unix:<path of socket>"
+	Address string
+	// CertFile and KeyFile are the certificate and the key of brokerd; TLS is used if set
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the certificate of the authority which signed the certificates of the clients;
+	// if set, clients must present a valid certificate (mutual TLS)
+	ClientCAFile string
+	// Token is the bearer token clients must send with each request; no authentication if empty
+	Token string
+}
+
+// IsUnixSocket tells if the address designates a unix socket
+func IsUnixSocket(address string) bool {
+	return strings.HasPrefix(address, unixSocketPrefix)
+}
+
+// unixSocketPath returns the path of the socket designated by the address
+func unixSocketPath(address string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(address, unixSocketPrefix), "//")
+}
+
+// Listen opens the listener corresponding to the address of the configuration
+func (c ServerConfig) Listen() (net.Listener, error) {
+	if IsUnixSocket(c.Address) {
+		path := unixSocketPath(c.Address)
+		// Removes the socket left by a previous run, if any
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", c.Address)
+}
+
+// IsLoopback tells if the server is only reachable from the local host
+func (c ServerConfig) IsLoopback() bool {
+	if IsUnixSocket(c.Address) {
+		return true
+	}
+	host, _, err := net.SplitHostPort(c.Address)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ServerOptions returns the grpc options enforcing TLS and token authentication as configured
+func (c ServerConfig) ServerOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		creds, err := serverTLSCredentials(c.CertFile, c.KeyFile, c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	} else if c.ClientCAFile != "" {
+		return nil, fmt.Errorf("client CA certificate given without server certificate and key")
+	}
+
+	if c.Token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(TokenUnaryInterceptor(c.Token)),
+			grpc.StreamInterceptor(TokenStreamInterceptor(c.Token)),
+		)
+	}
+	return opts, nil
+}
+
+// serverTLSCredentials builds the TLS credentials of brokerd, requiring client certificates if clientCAFile is set
+func serverTLSCredentials(certFile, keyFile, clientCAFile string) (credentials.TransportCredentials, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("both certificate and key are needed to enable TLS")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %s", err.Error())
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// clientTLSCredentials builds the TLS credentials of the client, presenting a certificate if certFile is set
+func clientTLSCredentials(caFile, certFile, keyFile, serverName string) (credentials.TransportCredentials, error) {
+	pool, err := loadCertPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("both certificate and key are needed to authenticate the client")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// loadCertPool returns a certificate pool containing the certificates of the PEM file
+func loadCertPool(file string) (*x509.CertPool, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %s", err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("no valid certificate found in '%s'", file)
+	}
+	return pool, nil
+}
+
+// DialOptions returns the grpc options to use to connect to brokerd as configured
+func (c ConnectionConfig) DialOptions() ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+
+	if IsUnixSocket(c.Address) {
+		opts = append(opts, grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", unixSocketPath(addr), timeout)
+		}))
+	}
+
+	secure := c.CAFile != ""
+	if secure {
+		serverName := ""
+		if !IsUnixSocket(c.Address) {
+			serverName, _, _ = net.SplitHostPort(c.Address)
+		}
+		creds, err := clientTLSCredentials(c.CAFile, c.CertFile, c.KeyFile, serverName)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	if c.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{token: c.Token, secure: secure}))
+	}
+	return opts, nil
+}
+
+// tokenCredentials implements credentials.PerRPCCredentials to send a bearer token with each request
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+// GetRequestMetadata returns the metadata carrying the token
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationMetadataKey: bearerPrefix + t.token}, nil
+}
+
+// RequireTransportSecurity tells if the token can only be sent over TLS; the token is allowed without TLS
+// to keep unix sockets and loopback connections usable
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// checkToken verifies the bearer token carried by the incoming metadata of the request
+func checkToken(ctx context.Context, token string) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing authentication token")
+	}
+	values := md.Get(authorizationMetadataKey)
+	if len(values) == 0 || !strings.HasPrefix(values[0], bearerPrefix) {
+		return status.Error(codes.Unauthenticated, "missing authentication token")
+	}
+	received := strings.TrimPrefix(values[0], bearerPrefix)
+	if subtle.ConstantTimeCompare([]byte(received), []byte(token)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid authentication token")
+	}
+	return nil
+}
+
+// TokenUnaryInterceptor returns a grpc interceptor rejecting unary calls not carrying the expected token
+func TokenUnaryInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkToken(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TokenStreamInterceptor returns a grpc interceptor rejecting streams not carrying the expected token
+func TokenStreamInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkToken(ss.Context(), token); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsLoopback(t *testing.T) {
+	assert.True(t, ServerConfig{Address: "localhost:50051"}.IsLoopback())
+	assert.True(t, ServerConfig{Address: "127.0.0.1:50051"}.IsLoopback())
+	assert.True(t, ServerConfig{Address: "unix:/var/run/brokerd.sock"}.IsLoopback())
+	assert.False(t, ServerConfig{Address: ":50051"}.IsLoopback())
+	assert.False(t, ServerConfig{Address: "10.0.0.1:50051"}.IsLoopback())
+}
+
+func TestTokenUnaryInterceptor(t *testing.T) {
+	interceptor := TokenUnaryInterceptor("secret")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "done", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/HostService/List"}
+
+	_, err := interceptor(context.Background(), nil, info, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(authorizationMetadataKey, bearerPrefix+"wrong"))
+	_, err = interceptor(ctx, nil, info, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(authorizationMetadataKey, bearerPrefix+"secret"))
+	result, err := interceptor(ctx, nil, info, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "done", result)
+}
+
+func TestTenantFromContext(t *testing.T) {
+	assert.Equal(t, "", TenantFromContext(nil))
+	assert.Equal(t, "", TenantFromContext(context.Background()))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TenantMetadataKey, "TestOvh"))
+	assert.Equal(t, "TestOvh", TenantFromContext(ctx))
+}
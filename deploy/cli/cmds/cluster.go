@@ -19,8 +19,11 @@ package cmds
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -141,6 +144,19 @@ var clusterListCommand = cli.Command{
 	},
 }
 
+// readUserData reads the cloud-init parts from the files given with the option flag
+func readUserData(c *cli.Context, flag string) ([]string, error) {
+	var parts []string
+	for _, file := range c.StringSlice(flag) {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("can't read user data file '%s': %v", file, err)
+		}
+		parts = append(parts, string(content))
+	}
+	return parts, nil
+}
+
 // formatClusterConfig...
 func formatClusterConfig(value interface{}) map[string]interface{} {
 	core := value.(map[string]interface{})
@@ -248,6 +264,27 @@ func convertStructToMap(src interface{}) (map[string]interface{}, error) {
 	return toFormat, nil
 }
 
+// followOperation waits for the end of the brokerd operation 'id', displaying its progress on the standard error;
+// an interruption of the command cancels the operation
+func followOperation(id string) (*pb.Operation, error) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		if _, ok := <-sigs; ok {
+			fmt.Fprintf(os.Stderr, "Interrupted, cancelling operation '%s'...\n", id)
+			err := brokerclient.New().Operation.Cancel(id, brokerclient.DefaultExecutionTimeout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", brokerclient.DecorateError(err, "cancellation of operation", false))
+			}
+		}
+	}()
+
+	return brokerclient.New().Operation.Wait(id, func(event *pb.OperationEvent) {
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", time.Unix(event.GetTimestamp(), 0).Format("15:04:05"), event.GetMessage())
+	})
+}
+
 // clusterCreateCmd handles 'deploy cluster <clustername> create'
 var clusterCreateCommand = cli.Command{
 	Name:      "create",
@@ -292,6 +329,14 @@ var clusterCreateCommand = cli.Command{
 			Name:  "disk",
 			Usage: "Defines the size of system disk of masters and nodes (in GB)",
 		},
+		cli.StringSliceFlag{
+			Name:  "node-user-data",
+			Usage: "File containing a cloud-init part run at first boot of the nodes, cloud-config document ('#cloud-config') or shell script ('#!'); can be repeated",
+		},
+		cli.BoolFlag{
+			Name:  "async",
+			Usage: "Return the operation creating the cluster without waiting for it to end",
+		},
 	},
 
 	Action: func(c *cli.Context) error {
@@ -328,10 +373,6 @@ var clusterCreateCommand = cli.Command{
 		}
 
 		disable := c.StringSlice("disable")
-		disableFeatures := map[string]struct{}{}
-		for _, v := range disable {
-			disableFeatures[v] = struct{}{}
-		}
 
 		los := c.String("os")
 		if flavor == Flavor.DCOS {
@@ -342,32 +383,54 @@ var clusterCreateCommand = cli.Command{
 		cpu := int32(c.Uint("cpu"))
 		ram := float32(c.Float64("ram"))
 		disk := int32(c.Uint("disk"))
+		userData, err := readUserData(c, "node-user-data")
+		if err != nil {
+			return clitools.ExitOnInvalidOption(fmt.Sprintf("Invalid option --node-user-data: %s\n", err.Error()))
+		}
 
 		var nodesDef *pb.HostDefinition
-		if cpu > 0 || ram > 0.0 || disk > 0 || los != "" {
+		if cpu > 0 || ram > 0.0 || disk > 0 || los != "" || len(userData) > 0 {
 			nodesDef = &pb.HostDefinition{
 				CPUNumber: cpu,
 				RAM:       ram,
 				Disk:      disk,
 				ImageID:   los,
+				UserData:  userData,
 			}
 		}
-		clusterInstance, err = cluster.Create(api.Request{
-			Name:                    clusterName,
-			Complexity:              complexity,
-			CIDR:                    cidr,
-			Flavor:                  flavor,
-			KeepOnFailure:           keep,
-			NodesDef:                nodesDef,
-			DisabledDefaultFeatures: disableFeatures,
-		})
+		op, err := brokerclient.New().Cluster.CreateAsync(pb.ClusterDefinition{
+			Name:             clusterName,
+			Complexity:       int32(complexity),
+			CIDR:             cidr,
+			Flavor:           int32(flavor),
+			KeepOnFailure:    keep,
+			NodesDef:         nodesDef,
+			DisabledFeatures: disable,
+		}, brokerclient.DefaultExecutionTimeout)
 		if err != nil {
-			if clusterInstance != nil {
-				clusterInstance.Delete()
-			}
+			err = brokerclient.DecorateError(err, "creation of cluster", true)
 			msg := fmt.Sprintf("failed to create cluster: %s\n", err.Error())
 			return clitools.ExitOnErrorWithMessage(ExitCode.Run, msg)
 		}
+		if c.Bool("async") {
+			out, _ := json.Marshal(op)
+			fmt.Println(string(out))
+			return nil
+		}
+		_, err = followOperation(op.GetID())
+		if err != nil {
+			err = brokerclient.DecorateError(err, "creation of cluster", true)
+			msg := fmt.Sprintf("failed to create cluster: %s\n", err.Error())
+			return clitools.ExitOnErrorWithMessage(ExitCode.Run, msg)
+		}
+		clusterInstance, err = cluster.Get(clusterName)
+		if err != nil {
+			return clitools.ExitOnErrorWithMessage(ExitCode.Run, err.Error())
+		}
+		if clusterInstance == nil {
+			msg := fmt.Sprintf("cluster '%s' not found after its creation\n", clusterName)
+			return clitools.ExitOnErrorWithMessage(ExitCode.Run, msg)
+		}
 
 		toFormat, err := convertStructToMap(clusterInstance.GetConfig())
 		if err != nil {
@@ -591,6 +654,10 @@ var clusterExpandCommand = cli.Command{
 			Usage:  "Ask for gpu capable host; default: no",
 			Hidden: true,
 		},
+		cli.StringSliceFlag{
+			Name:  "user-data",
+			Usage: "File containing a cloud-init part run at first boot of the new node(s), cloud-config document ('#cloud-config') or shell script ('#!'); can be repeated; default: parts used at cluster creation",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		err := extractClusterArgument(c)
@@ -609,15 +676,20 @@ var clusterExpandCommand = cli.Command{
 		disk := int32(c.Uint("disk"))
 		//gpu := c.Bool("gpu")
 		_ = c.Bool("gpu")
+		userData, err := readUserData(c, "user-data")
+		if err != nil {
+			return clitools.ExitOnInvalidOption(fmt.Sprintf("Invalid option --user-data: %s\n", err.Error()))
+		}
 
 		// err := createNodes(clusterName, public, count, los, cpu, ram, disk)
 		var nodeRequest *pb.HostDefinition
-		if los != "" || cpu > 0 || ram > 0.0 || disk > 0 {
+		if los != "" || cpu > 0 || ram > 0.0 || disk > 0 || len(userData) > 0 {
 			nodeRequest = &pb.HostDefinition{
 				CPUNumber: cpu,
 				RAM:       ram,
 				Disk:      disk,
 				ImageID:   los,
+				UserData:  userData,
 			}
 		}
 		hosts, err := clusterInstance.AddNodes(count, public, nodeRequest)
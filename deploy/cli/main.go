@@ -27,9 +27,12 @@ import (
 
 	"github.com/CS-SI/SafeScale/deploy/cli/cmds"
 
+	_ "github.com/CS-SI/SafeScale/providers/aws"            // Imported to initialise provider aws
 	_ "github.com/CS-SI/SafeScale/providers/cloudferro"     // Imported to initialise provider cloudferro
 	_ "github.com/CS-SI/SafeScale/providers/cloudwatt"      // Imported to initialise provider cloudwatt
+	_ "github.com/CS-SI/SafeScale/providers/fake"           // Imported to initialise provider fake
 	_ "github.com/CS-SI/SafeScale/providers/flexibleengine" // Imported to initialise provider flexibleengine
+	_ "github.com/CS-SI/SafeScale/providers/openstack"      // Imported to initialise provider openstack
 	_ "github.com/CS-SI/SafeScale/providers/opentelekom"    // Imported to initialise provider opentelekom
 	_ "github.com/CS-SI/SafeScale/providers/ovh"            // Imported to initialise provider ovh
 )
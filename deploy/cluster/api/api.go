@@ -47,6 +47,16 @@ type Request struct {
 	NodesDef *pb.HostDefinition
 	// DisabledDefaultFeatures contains the list of features that should be installed by default but we don't want actually
 	DisabledDefaultFeatures map[string]struct{}
+	// Tracker, if set, receives the progress of the creation and tells if it has to be aborted
+	Tracker Tracker
+}
+
+// Tracker receives the progress of a cluster creation and tells if it has to be aborted
+type Tracker interface {
+	// Step reports the beginning of a new step of the creation
+	Step(format string, a ...interface{})
+	// Aborted returns an error if the creation has to stop (and roll back what it did)
+	Aborted() error
 }
 
 //go:generate mockgen -destination=../mocks/mock_cluster.go -package=mocks github.com/CS-SI/SafeScale/deploy/cluster/api Cluster
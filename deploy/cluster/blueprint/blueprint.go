@@ -0,0 +1,1040 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blueprint
+
+/*
+ * Implements the generic engine building and managing clusters; a flavor only
+ * describes what is specific to it by filling a Makers struct
+ */
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	txttmpl "text/template"
+	"time"
+
+	rice "github.com/GeertJohan/go.rice"
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	brokerclient "github.com/CS-SI/SafeScale/broker/client"
+	clusterapi "github.com/CS-SI/SafeScale/deploy/cluster/api"
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/ClusterState"
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/Extension"
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/NodeType"
+	"github.com/CS-SI/SafeScale/deploy/cluster/metadata"
+	"github.com/CS-SI/SafeScale/providers"
+	providermetadata "github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+	"github.com/CS-SI/SafeScale/utils"
+	"github.com/CS-SI/SafeScale/utils/provideruse"
+	"github.com/CS-SI/SafeScale/utils/retry"
+)
+
+const (
+	// adminUser is the account created on each host of a cluster to manage it
+	adminUser = "cladm"
+)
+
+// operationPollDelay is the delay between two checks of the cancellation of a cluster creation waiting a brokerd
+// operation
+var operationPollDelay = 5 * time.Second
+
+// Makers contains what a flavor declares to the engine to build and manage its clusters
+// Every hook is optional; the engine skips the step if the hook is not set
+type Makers struct {
+	// MinimumRequiredServers returns the number of masters and private nodes to create, depending on complexity
+	MinimumRequiredServers func(b *BluePrint) (int, int)
+	// DefaultGatewaySizing returns the sizing of the gateway of the network of the cluster
+	DefaultGatewaySizing func(b *BluePrint) pb.GatewayDefinition
+	// DefaultMasterSizing returns the sizing of the masters
+	DefaultMasterSizing func(b *BluePrint) pb.HostDefinition
+	// DefaultNodeSizing returns the minimal sizing of the nodes; bigger values requested are kept
+	DefaultNodeSizing func(b *BluePrint) pb.HostDefinition
+	// DefaultImage returns the image used by the hosts of the cluster
+	DefaultImage func(b *BluePrint) string
+	// EnforceDefaultImage tells if the image requested is ignored in favor of DefaultImage
+	EnforceDefaultImage bool
+
+	// GetTemplateBox returns the rice box containing the scripts of the flavor
+	GetTemplateBox func() (*rice.Box, error)
+	// FuncMap contains the custom functions usable in the scripts of the flavor
+	FuncMap txttmpl.FuncMap
+	// RequirementsScript is the name of the script installing what is common to all the hosts;
+	// it's realized once, then passed to the other scripts as 'InstallCommonRequirements'
+	RequirementsScript string
+	// GetNodeInstallationScript returns the name of the script installing a new host of type 'nodeType' and
+	// the additional data of its template; the gateway is of type NodeType.Bootstrap.
+	// No script is run if the name is empty
+	GetNodeInstallationScript func(b *BluePrint, nodeType NodeType.Enum) (string, map[string]interface{})
+	// ScriptErrorMessage returns the meaning of the exit code of a script of the flavor, or "" if unknown
+	ScriptErrorMessage func(retcode int) string
+
+	// Features contains, by type of host, the features added by default on new hosts
+	Features map[NodeType.Enum][]string
+	// ClusterFeatures contains the features added by default on the cluster, and added again when nodes are added
+	ClusterFeatures []string
+
+	// ConfigureGateway configures the gateway once all the hosts are created
+	ConfigureGateway func(b *BluePrint, host *pb.Host) error
+	// ConfigureMaster configures a master once all the hosts are created; master #1 is configured alone
+	// first, so it can initialize what the other masters join
+	ConfigureMaster func(b *BluePrint, index int, host *pb.Host) error
+	// ConfigureNode configures a node once the masters are configured
+	ConfigureNode func(b *BluePrint, index int, host *pb.Host, nodeType NodeType.Enum) error
+	// UnconfigureNode detaches a node from the cluster before its deletion
+	UnconfigureNode func(b *BluePrint, host *pb.Host) error
+	// ConfigureCluster ends the configuration of the cluster, after the addition of the cluster features
+	ConfigureCluster func(b *BluePrint) error
+	// GetState returns the state of the cluster as seen by the software managing it
+	GetState func(b *BluePrint) (ClusterState.Enum, error)
+}
+
+// managerData defines the data used by the engine we want to keep in Object Storage
+type managerData struct {
+	// GatewayID contains the ID of the gateway of the network
+	GatewayID string
+	// MasterIDs contains the ID of the masters
+	MasterIDs []string
+	// MasterIPs contains the IP of the masters
+	MasterIPs []string
+	// PublicNodeIPs contains a list of IP of the public nodes
+	PublicNodeIPs []string
+	// PrivateNodeIPs contains a list of IP of the private nodes
+	PrivateNodeIPs []string
+	// StateCollectInterval in seconds
+	StateCollectInterval time.Duration
+	// MasterLastIndex
+	MasterLastIndex int
+	// PrivateLastIndex
+	PrivateLastIndex int
+	// PublicLastIndex
+	PublicLastIndex int
+}
+
+// BluePrint is a cluster built and managed by the engine, following the makers of its flavor
+type BluePrint struct {
+	// Core cluster data
+	Core *clusterapi.ClusterCore
+
+	// makers contains the hooks of the flavor
+	makers Makers
+
+	// manager contains data specific to the cluster management
+	manager *managerData
+
+	// lastStateCollection contains the date of the last state collection
+	lastStateCollection time.Time
+
+	// requirements contains the realized script of common requirements
+	requirements     *string
+	requirementsLock sync.Mutex
+
+	// metadata of cluster
+	metadata *metadata.Cluster
+
+	// provider is a pointer to current provider service instance
+	provider *providers.Service
+
+	// tracker receives the progress of the creation of the cluster, if it's followed
+	tracker clusterapi.Tracker
+}
+
+// Load loads the internals of an existing cluster from metadata
+func Load(data *metadata.Cluster, makers Makers) (*BluePrint, error) {
+	svc, err := provideruse.GetProviderService()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BluePrint{
+		Core:     data.Get(),
+		makers:   makers,
+		metadata: data,
+		provider: svc,
+	}
+	err = b.resetManager()
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// legacyManagerData contains the fields of the data of the flavors written before the engine existed
+type legacyManagerData struct {
+	// BootstrapID contains the ID of the gateway, used by DCOS as bootstrap server
+	BootstrapID string
+}
+
+// loadGatewayID returns the ID of the gateway of the network of the cluster, read from the metadata of the provider
+var loadGatewayID = func(b *BluePrint) (string, error) {
+	mgw, err := providermetadata.LoadGateway(b.provider, b.Core.NetworkID)
+	if err != nil {
+		return "", err
+	}
+	if mgw == nil {
+		return "", fmt.Errorf("failed to find gateway of network '%s'", b.Core.NetworkID)
+	}
+	return mgw.Get().ID, nil
+}
+
+// resetManager extracts the data of the engine from the extensions of the cluster
+func (b *BluePrint) resetManager() error {
+	manager := &managerData{}
+	legacy := legacyManagerData{}
+	switch anon := b.Core.GetExtension(Extension.FlavorV1).(type) {
+	case nil:
+	case *managerData:
+		manager = anon
+	case managerData:
+		*manager = anon
+	default:
+		// Extension read from Object Storage is a generic map, converts it
+		jsoned, err := json.Marshal(anon)
+		if err != nil {
+			return fmt.Errorf("invalid content of metadata of cluster '%s': %s", b.Core.Name, err.Error())
+		}
+		err = json.Unmarshal(jsoned, manager)
+		if err == nil {
+			err = json.Unmarshal(jsoned, &legacy)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid content of metadata of cluster '%s': %s", b.Core.Name, err.Error())
+		}
+	}
+	b.migrateManager(manager, legacy)
+	b.manager = manager
+	b.Core.SetExtension(Extension.FlavorV1, manager)
+	return nil
+}
+
+// migrateManager fills the data of the engine missing from the metadata of the clusters created before the engine
+// existed; the result is saved by the next update of the metadata.
+// A gateway not found doesn't prevent the loading, so the cluster can still be inspected or deleted
+func (b *BluePrint) migrateManager(manager *managerData, legacy legacyManagerData) {
+	if manager.GatewayID != "" || b.Core.NetworkID == "" {
+		return
+	}
+	if legacy.BootstrapID != "" {
+		manager.GatewayID = legacy.BootstrapID
+		return
+	}
+	gatewayID, err := loadGatewayID(b)
+	if err != nil {
+		log.Errorf("failed to find the gateway of cluster '%s' while migrating its metadata: %s", b.Core.Name, err.Error())
+		return
+	}
+	manager.GatewayID = gatewayID
+}
+
+// Reload reloads metadata of Cluster from ObjectStorage
+func (b *BluePrint) Reload() error {
+	err := b.metadata.Reload()
+	if err != nil {
+		return err
+	}
+	return b.resetManager()
+}
+
+// updateMetadata writes cluster config in Object Storage
+func (b *BluePrint) updateMetadata(updatefn func() error) error {
+	if b.metadata == nil {
+		m, err := metadata.NewCluster()
+		if err != nil {
+			return err
+		}
+		m.Carry(b.Core)
+		err = m.Acquire()
+		if err != nil {
+			return err
+		}
+		b.metadata = m
+	} else {
+		err := b.metadata.Acquire()
+		if err != nil {
+			return err
+		}
+		err = b.Reload()
+		if err != nil {
+			b.metadata.Release()
+			return err
+		}
+	}
+	defer b.metadata.Release()
+
+	if updatefn != nil {
+		err := updatefn()
+		if err != nil {
+			return err
+		}
+	}
+	return b.metadata.Write()
+}
+
+// step reports the progress of the creation of the cluster to its tracker, or logs it if there is none
+func (b *BluePrint) step(format string, a ...interface{}) {
+	if b.tracker != nil {
+		b.tracker.Step(format, a...)
+		return
+	}
+	log.Printf(format, a...)
+}
+
+// aborted returns an error if the creation of the cluster has to stop
+func (b *BluePrint) aborted() error {
+	if b.tracker != nil {
+		return b.tracker.Aborted()
+	}
+	return nil
+}
+
+// waitOperation waits the end of a brokerd operation run for the cluster, reporting its events prefixed by
+// 'logPrefix'; the operation is cancelled if the creation of the cluster is aborted
+func (b *BluePrint) waitOperation(op *pb.Operation, logPrefix string) (*pb.Operation, error) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(operationPollDelay)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if b.aborted() != nil {
+					err := brokerclient.New().Operation.Cancel(op.GetID(), brokerclient.DefaultExecutionTimeout)
+					if err != nil {
+						log.Warnf("%s failed to cancel operation '%s': %s", logPrefix, op.GetID(), err.Error())
+					}
+					return
+				}
+			}
+		}
+	}()
+	return brokerclient.New().Operation.Wait(op.GetID(), func(event *pb.OperationEvent) {
+		b.step("%s %s", logPrefix, event.GetMessage())
+	})
+}
+
+// Create creates the necessary infrastructure of a cluster of the flavor described by makers; if the request has a
+// tracker, the progress is reported to it and the creation stops and rolls back when it's aborted
+func Create(req clusterapi.Request, makers Makers) (_ *BluePrint, err error) {
+	// Generate needed password for account cladm
+	cladmPassword, err := utils.GeneratePassword(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password for user %s: %s", adminUser, err.Error())
+	}
+
+	svc, err := provideruse.GetProviderService()
+	if err != nil {
+		return nil, err
+	}
+	// Fails before creating anything if the provider can't host the network of the cluster
+	err = svc.GetCapabilities().CheckNetwork()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster '%s': %s", req.Name, err.Error())
+	}
+
+	req.Name = strings.ToLower(req.Name)
+	if req.DisabledDefaultFeatures == nil {
+		req.DisabledDefaultFeatures = map[string]struct{}{}
+	}
+	b := &BluePrint{
+		Core: &clusterapi.ClusterCore{
+			Name:             req.Name,
+			CIDR:             req.CIDR,
+			Flavor:           req.Flavor,
+			State:            ClusterState.Creating,
+			Complexity:       req.Complexity,
+			Tenant:           req.Tenant,
+			AdminPassword:    cladmPassword,
+			DisabledFeatures: req.DisabledDefaultFeatures,
+		},
+		makers:   makers,
+		manager:  &managerData{},
+		provider: svc,
+		tracker:  req.Tracker,
+	}
+	b.Core.SetExtension(Extension.FlavorV1, b.manager)
+	b.Core.NodesDef = b.nodeDefinition(b.defaultNodeDefinition(), req.NodesDef, true)
+
+	// Creates network
+	broker := brokerclient.New()
+	networkName := "net-" + req.Name
+	b.step("Creating Network '%s'", networkName)
+	gatewayDef := b.gatewayDefinition()
+	op, err := broker.Network.CreateAsync(pb.NetworkDefinition{
+		Name:    networkName,
+		CIDR:    req.CIDR,
+		Gateway: &gatewayDef,
+	}, brokerclient.DefaultExecutionTimeout)
+	if err == nil {
+		op, err = b.waitOperation(op, "[network]")
+	}
+	if err != nil {
+		err = brokerclient.DecorateError(err, "creation of network", true)
+		return nil, fmt.Errorf("failed to create Network '%s': %s", networkName, err.Error())
+	}
+	network, err := broker.Network.Inspect(op.GetResultID(), brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		derr := broker.Network.Delete([]string{op.GetResultID()}, brokerclient.DefaultExecutionTimeout)
+		if derr != nil {
+			log.Errorf("failed to delete Network '%s': %s", networkName, derr.Error())
+		}
+		err = brokerclient.DecorateError(err, "inspection of network", false)
+		return nil, fmt.Errorf("failed to get Network '%s': %s", networkName, err.Error())
+	}
+	b.step("Network '%s' created successfully", networkName)
+	b.Core.NetworkID = network.ID
+	b.manager.GatewayID = network.GatewayID
+
+	// Everything created from now on is removed if the creation fails, or always if it's cancelled
+	defer func() {
+		if err != nil && (!req.KeepOnFailure || b.aborted() != nil) {
+			b.step("Removing what has been created for cluster '%s'", req.Name)
+			b.cleanup()
+		}
+	}()
+
+	err = b.aborted()
+	if err != nil {
+		return nil, err
+	}
+	gw, err := broker.Host.Inspect(network.GatewayID, brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		err = brokerclient.DecorateError(err, "inspection of gateway", false)
+		return nil, err
+	}
+	b.Core.GatewayIP = gw.PrivateIP
+	b.Core.PublicIP = gw.PublicIP
+
+	err = broker.Ssh.WaitReady(gw.ID, brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		err = brokerclient.DecorateError(err, "wait for remote ssh service to be ready", false)
+		return nil, err
+	}
+
+	// Create a KeyPair for the user cladm
+	kpName := "cluster_" + req.Name + "_" + adminUser + "_key"
+	b.Core.Keypair, err = svc.CreateKeyPair(kpName)
+	if err != nil {
+		err = fmt.Errorf("failed to create Key Pair: %s", err.Error())
+		return nil, err
+	}
+
+	// Saving cluster metadata, with status 'Creating'
+	err = b.updateMetadata(nil)
+	if err != nil {
+		err = fmt.Errorf("failed to create cluster '%s': %s", req.Name, err.Error())
+		return nil, err
+	}
+
+	masterCount, privateNodeCount := 1, 1
+	if makers.MinimumRequiredServers != nil {
+		masterCount, privateNodeCount = makers.MinimumRequiredServers(b)
+	}
+
+	// Step 1: installs gateway, creates masters and nodes in parallel
+	err = b.aborted()
+	if err != nil {
+		return nil, err
+	}
+	gatewayChannel := make(chan error)
+	go func() {
+		gatewayChannel <- b.installGateway()
+	}()
+	mastersChannel := make(chan error)
+	go func() {
+		mastersChannel <- b.createMasters(masterCount)
+	}()
+	nodesChannel := make(chan error)
+	go func() {
+		_, err := b.createNodes(privateNodeCount, false, b.Core.NodesDef)
+		nodesChannel <- err
+	}()
+	gatewayStatus := <-gatewayChannel
+	mastersStatus := <-mastersChannel
+	nodesStatus := <-nodesChannel
+	err = b.aborted()
+	if err != nil {
+		return nil, err
+	}
+	if gatewayStatus != nil {
+		err = gatewayStatus
+		return nil, err
+	}
+	if mastersStatus != nil {
+		err = mastersStatus
+		return nil, err
+	}
+	if nodesStatus != nil {
+		err = nodesStatus
+		return nil, err
+	}
+
+	// Step 2: configures gateway, then masters, then nodes
+	err = b.configureGateway()
+	if err != nil {
+		return nil, err
+	}
+	err = b.configureMasters()
+	if err != nil {
+		return nil, err
+	}
+	err = b.configureNodes(b.Core.PrivateNodeIDs, NodeType.PrivateNode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 3: configures the cluster as a whole
+	err = b.configureCluster()
+	if err != nil {
+		return nil, err
+	}
+
+	// Cluster created and configured successfully, saving again to Object Storage
+	err = b.aborted()
+	if err != nil {
+		return nil, err
+	}
+	err = b.updateMetadata(func() error {
+		b.Core.State = ClusterState.Created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the state of the cluster until successful
+	b.step("Waiting for cluster '%s' to be ready", req.Name)
+	err = retry.Action(
+		func() error {
+			status, err := b.ForceGetState()
+			if err != nil {
+				return err
+			}
+			if status != ClusterState.Nominal {
+				return fmt.Errorf("cluster is not ready for duty")
+			}
+			return nil
+		},
+		retry.PrevailDone(retry.Unsuccessful(), retry.Timeout(5*time.Minute), retry.Abortable(b.aborted)),
+		retry.Constant(5*time.Second), nil, nil, nil,
+	)
+	if err != nil {
+		log.Println("failed to wait ready state of the cluster")
+		return nil, err
+	}
+	b.tracker = nil
+	return b, nil
+}
+
+// cleanup removes what has been created for a cluster whose creation failed
+func (b *BluePrint) cleanup() {
+	broker := brokerclient.New()
+
+	hosts := append([]string{}, b.Core.PublicNodeIDs...)
+	hosts = append(hosts, b.Core.PrivateNodeIDs...)
+	hosts = append(hosts, b.manager.MasterIDs...)
+	if len(hosts) > 0 {
+		err := broker.Host.Delete(hosts, brokerclient.DefaultExecutionTimeout)
+		if err != nil {
+			log.Errorf("failed to delete hosts of cluster '%s': %s", b.Core.Name, err.Error())
+		}
+	}
+	err := broker.Network.Delete([]string{b.Core.NetworkID}, brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		log.Errorf("failed to delete network of cluster '%s': %s", b.Core.Name, err.Error())
+	}
+	if b.Core.Keypair != nil {
+		err = b.provider.DeleteKeyPair(b.Core.Keypair.ID)
+		if err != nil {
+			log.Errorf("failed to delete key pair of cluster '%s': %s", b.Core.Name, err.Error())
+		}
+	}
+	if b.metadata != nil {
+		err = b.metadata.Delete()
+		if err != nil {
+			log.Errorf("failed to delete metadata of cluster '%s': %s", b.Core.Name, err.Error())
+		}
+	}
+}
+
+// Sanitize rebuilds the data of the engine of an existing cluster from the hosts found in the metadata of the provider
+func Sanitize(data *metadata.Cluster, makers Makers) error {
+	b, err := Load(data, makers)
+	if err != nil {
+		return err
+	}
+
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	ipOf := func(h *model.Host) (string, error) {
+		err := h.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+		if err != nil {
+			return "", fmt.Errorf("failed to update metadata of cluster '%s': %s", b.Core.Name, err.Error())
+		}
+		return hostNetworkV1.IPv4Addresses[hostNetworkV1.DefaultNetworkID], nil
+	}
+
+	gatewayID, err := loadGatewayID(b)
+	if err != nil {
+		return fmt.Errorf("failed to find gateway of cluster '%s': %s", b.Core.Name, err.Error())
+	}
+	newManager := &managerData{
+		GatewayID:            gatewayID,
+		StateCollectInterval: b.manager.StateCollectInterval,
+	}
+	var privateNodeIDs, publicNodeIDs []string
+	err = providermetadata.NewHost(b.provider).Browse(func(h *model.Host) error {
+		if !strings.HasPrefix(h.Name, b.Core.Name+"-") {
+			return nil
+		}
+		ip, err := ipOf(h)
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(h.Name, b.Core.Name+"-master-"):
+			newManager.MasterIDs = append(newManager.MasterIDs, h.ID)
+			newManager.MasterIPs = append(newManager.MasterIPs, ip)
+		case strings.HasPrefix(h.Name, b.Core.Name+"-node-"):
+			privateNodeIDs = append(privateNodeIDs, h.ID)
+			newManager.PrivateNodeIPs = append(newManager.PrivateNodeIPs, ip)
+		case strings.HasPrefix(h.Name, b.Core.Name+"-pubnode-"):
+			publicNodeIDs = append(publicNodeIDs, h.ID)
+			newManager.PublicNodeIPs = append(newManager.PublicNodeIPs, ip)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(newManager.MasterIDs)+len(privateNodeIDs)+len(publicNodeIDs) == 0 {
+		return fmt.Errorf("failed to find hosts belonging to cluster")
+	}
+	// Keeps the indexes greater than or equal to the previous ones, to avoid to reuse a hostname
+	newManager.MasterLastIndex = maxInt(b.manager.MasterLastIndex, len(newManager.MasterIDs))
+	newManager.PrivateLastIndex = maxInt(b.manager.PrivateLastIndex, len(privateNodeIDs))
+	newManager.PublicLastIndex = maxInt(b.manager.PublicLastIndex, len(publicNodeIDs))
+
+	log.Printf("updating metadata...\n")
+	err = b.updateMetadata(func() error {
+		b.manager = newManager
+		b.Core.SetExtension(Extension.FlavorV1, newManager)
+		b.Core.PrivateNodeIDs = privateNodeIDs
+		b.Core.PublicNodeIDs = publicNodeIDs
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update metadata of cluster '%s': %s", b.Core.Name, err.Error())
+	}
+	return nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// GetName returns the name of the cluster
+func (b *BluePrint) GetName() string {
+	return b.Core.Name
+}
+
+// GetNetworkID returns the ID of the network used by the cluster
+func (b *BluePrint) GetNetworkID() string {
+	return b.Core.GetNetworkID()
+}
+
+// inspectGateway returns the gateway of the network used by the cluster
+func (b *BluePrint) inspectGateway() (*pb.Host, error) {
+	if b.manager.GatewayID == "" {
+		return nil, fmt.Errorf("gateway of cluster '%s' is unknown", b.Core.Name)
+	}
+	return brokerclient.New().Host.Inspect(b.manager.GatewayID, brokerclient.DefaultExecutionTimeout)
+}
+
+// GetGatewayID returns the ID of the gateway of the network used by the cluster
+func (b *BluePrint) GetGatewayID() string {
+	return b.manager.GatewayID
+}
+
+// CountNodes returns the number of public or private nodes in the cluster
+func (b *BluePrint) CountNodes(public bool) uint {
+	return b.Core.CountNodes(public)
+}
+
+// GetExtension returns additional info of the cluster
+func (b *BluePrint) GetExtension(ctx Extension.Enum) interface{} {
+	return b.Core.GetExtension(ctx)
+}
+
+// SetExtension sets additional info of the cluster
+func (b *BluePrint) SetExtension(ctx Extension.Enum, info interface{}) {
+	b.Core.SetExtension(ctx, info)
+}
+
+// GetConfig returns the public properties of the cluster
+func (b *BluePrint) GetConfig() clusterapi.ClusterCore {
+	return *b.Core
+}
+
+// Start starts the hosts of the cluster, masters first
+func (b *BluePrint) Start() error {
+	state, err := b.GetState()
+	if err != nil {
+		return err
+	}
+	if state == ClusterState.Stopped {
+		broker := brokerclient.New().Host
+		hosts := append([]string{}, b.manager.MasterIDs...)
+		hosts = append(hosts, b.Core.PrivateNodeIDs...)
+		hosts = append(hosts, b.Core.PublicNodeIDs...)
+		for _, hostID := range hosts {
+			err := broker.Start(hostID, brokerclient.DefaultExecutionTimeout)
+			if err != nil {
+				return fmt.Errorf("failed to start host '%s': %s", hostID, err.Error())
+			}
+		}
+		return b.updateMetadata(func() error {
+			b.Core.State = ClusterState.Nominal
+			return nil
+		})
+	}
+	if state != ClusterState.Nominal && state != ClusterState.Degraded {
+		return fmt.Errorf("failed to start cluster because of it's current state: %s", state.String())
+	}
+	return nil
+}
+
+// Stop stops the hosts of the cluster, masters last
+func (b *BluePrint) Stop() error {
+	state, _ := b.GetState()
+	if state == ClusterState.Nominal || state == ClusterState.Degraded {
+		broker := brokerclient.New().Host
+		hosts := append([]string{}, b.Core.PublicNodeIDs...)
+		hosts = append(hosts, b.Core.PrivateNodeIDs...)
+		hosts = append(hosts, b.manager.MasterIDs...)
+		for _, hostID := range hosts {
+			err := broker.Stop(hostID, brokerclient.DefaultExecutionTimeout)
+			if err != nil {
+				return fmt.Errorf("failed to stop host '%s': %s", hostID, err.Error())
+			}
+		}
+		return b.updateMetadata(func() error {
+			b.Core.State = ClusterState.Stopped
+			return nil
+		})
+	}
+	if state != ClusterState.Stopped {
+		return fmt.Errorf("failed to stop cluster because of it's current state: %s", state.String())
+	}
+	return nil
+}
+
+// GetState returns the current state of the cluster
+func (b *BluePrint) GetState() (ClusterState.Enum, error) {
+	now := time.Now()
+	if now.After(b.lastStateCollection.Add(b.manager.StateCollectInterval)) {
+		return b.ForceGetState()
+	}
+	return b.Core.State, nil
+}
+
+// ForceGetState returns the current state of the cluster
+// This method will trigger an effective state collection at each call
+func (b *BluePrint) ForceGetState() (ClusterState.Enum, error) {
+	// A stopped cluster stays stopped until explicitly started
+	if b.Core.State == ClusterState.Stopped {
+		return b.Core.State, nil
+	}
+
+	var (
+		state    = ClusterState.Nominal
+		stateErr error
+	)
+	if b.makers.GetState != nil {
+		state, stateErr = b.makers.GetState(b)
+	}
+	err := b.updateMetadata(func() error {
+		b.lastStateCollection = time.Now()
+		b.Core.State = state
+		return nil
+	})
+	if stateErr != nil {
+		return state, stateErr
+	}
+	return state, err
+}
+
+// StateFromCommand returns the state of the cluster deduced from the exit code of 'cmd' run on a master:
+// Nominal if the command succeeds, Error otherwise; the current state is kept if no master is reachable
+func (b *BluePrint) StateFromCommand(cmd string) (ClusterState.Enum, error) {
+	masterID, err := b.FindAvailableMaster()
+	if err != nil {
+		return b.Core.State, nil
+	}
+	retcode, _, stderr, err := brokerclient.New().Ssh.Run(masterID, cmd, brokerclient.DefaultConnectionTimeout, brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		return b.Core.State, nil
+	}
+	if retcode != 0 {
+		return ClusterState.Error, fmt.Errorf(stderr)
+	}
+	return ClusterState.Nominal, nil
+}
+
+// AddNode adds one node
+func (b *BluePrint) AddNode(public bool, req *pb.HostDefinition) (string, error) {
+	hosts, err := b.AddNodes(1, public, req)
+	if err != nil {
+		return "", err
+	}
+	return hosts[0], nil
+}
+
+// AddNodes adds <count> nodes
+func (b *BluePrint) AddNodes(count int, public bool, req *pb.HostDefinition) ([]string, error) {
+	if b.Core.State != ClusterState.Created && b.Core.State != ClusterState.Nominal {
+		return nil, fmt.Errorf("cluster '%s' needs to be at least in state 'Created' to allow node addition", b.Core.Name)
+	}
+
+	nodeType := NodeType.PrivateNode
+	if public {
+		nodeType = NodeType.PublicNode
+	}
+	def := b.nodeDefinition(b.Core.NodesDef, req, false)
+	err := b.provider.GetCapabilities().CheckHost(int(def.GPUNumber), public)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add nodes to cluster '%s': %s", b.Core.Name, err.Error())
+	}
+
+	hostIDs, err := b.createNodes(count, public, def)
+	if err == nil {
+		err = b.configureNodes(hostIDs, nodeType)
+	}
+	if err == nil {
+		err = b.addClusterFeatures()
+	}
+	if err != nil {
+		for _, hostID := range hostIDs {
+			if hostID != "" {
+				_ = b.removeNode(hostID)
+			}
+		}
+		return nil, fmt.Errorf("errors occured on node addition: %s", err.Error())
+	}
+	return hostIDs, nil
+}
+
+// DeleteLastNode deletes the last node added
+func (b *BluePrint) DeleteLastNode(public bool) error {
+	hostIDs := b.ListNodeIDs(public)
+	if len(hostIDs) == 0 {
+		return fmt.Errorf("no node to delete in cluster '%s'", b.Core.Name)
+	}
+	return b.DeleteSpecificNode(hostIDs[len(hostIDs)-1])
+}
+
+// DeleteSpecificNode deletes the node specified by its ID
+func (b *BluePrint) DeleteSpecificNode(hostID string) error {
+	if !b.SearchNode(hostID, true) && !b.SearchNode(hostID, false) {
+		return fmt.Errorf("host '%s' isn't a registered Node of the Cluster '%s'", hostID, b.Core.Name)
+	}
+
+	if b.makers.UnconfigureNode != nil {
+		host, err := brokerclient.New().Host.Inspect(hostID, brokerclient.DefaultExecutionTimeout)
+		if err != nil {
+			return err
+		}
+		err = b.makers.UnconfigureNode(b, host)
+		if err != nil {
+			return fmt.Errorf("failed to remove node '%s' from cluster '%s': %s", host.Name, b.Core.Name, err.Error())
+		}
+	}
+	return b.removeNode(hostID)
+}
+
+// removeNode deletes a node and unregisters it from the cluster
+func (b *BluePrint) removeNode(hostID string) error {
+	err := brokerclient.New().Host.Delete([]string{hostID}, brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		return err
+	}
+	return b.updateMetadata(func() error {
+		if found, idx := contains(b.Core.PublicNodeIDs, hostID); found {
+			b.Core.PublicNodeIDs = append(b.Core.PublicNodeIDs[:idx], b.Core.PublicNodeIDs[idx+1:]...)
+			if idx < len(b.manager.PublicNodeIPs) {
+				b.manager.PublicNodeIPs = append(b.manager.PublicNodeIPs[:idx], b.manager.PublicNodeIPs[idx+1:]...)
+			}
+		}
+		if found, idx := contains(b.Core.PrivateNodeIDs, hostID); found {
+			b.Core.PrivateNodeIDs = append(b.Core.PrivateNodeIDs[:idx], b.Core.PrivateNodeIDs[idx+1:]...)
+			if idx < len(b.manager.PrivateNodeIPs) {
+				b.manager.PrivateNodeIPs = append(b.manager.PrivateNodeIPs[:idx], b.manager.PrivateNodeIPs[idx+1:]...)
+			}
+		}
+		return nil
+	})
+}
+
+// ListMasterIDs lists the master nodes in the cluster
+func (b *BluePrint) ListMasterIDs() []string {
+	return b.manager.MasterIDs
+}
+
+// ListMasterIPs lists the IPs of the master nodes in the cluster
+func (b *BluePrint) ListMasterIPs() []string {
+	return b.manager.MasterIPs
+}
+
+// ListNodeIDs lists the IDs of the nodes in the cluster
+func (b *BluePrint) ListNodeIDs(public bool) []string {
+	if public {
+		return b.Core.PublicNodeIDs
+	}
+	return b.Core.PrivateNodeIDs
+}
+
+// ListNodeIPs lists the IPs of the nodes in the cluster
+func (b *BluePrint) ListNodeIPs(public bool) []string {
+	if public {
+		return b.manager.PublicNodeIPs
+	}
+	return b.manager.PrivateNodeIPs
+}
+
+// GetNode returns a node based on its ID
+func (b *BluePrint) GetNode(hostID string) (*pb.Host, error) {
+	if !b.SearchNode(hostID, true) && !b.SearchNode(hostID, false) {
+		return nil, fmt.Errorf("failed to find node '%s' in cluster '%s'", hostID, b.Core.Name)
+	}
+	return brokerclient.New().Host.Inspect(hostID, brokerclient.DefaultExecutionTimeout)
+}
+
+// SearchNode tells if an host ID corresponds to a public or private node of the cluster
+func (b *BluePrint) SearchNode(hostID string, public bool) bool {
+	found, _ := contains(b.ListNodeIDs(public), hostID)
+	return found
+}
+
+func contains(list []string, hostID string) (bool, int) {
+	for i, v := range list {
+		if v == hostID {
+			return true, i
+		}
+	}
+	return false, 0
+}
+
+// FindAvailableMaster returns the ID of the first master available for execution
+func (b *BluePrint) FindAvailableMaster() (string, error) {
+	hostID, found := findAvailableHost(b.manager.MasterIDs)
+	if !found {
+		return "", fmt.Errorf("failed to find available master")
+	}
+	return hostID, nil
+}
+
+// FindAvailableNode returns the ID of a node available
+func (b *BluePrint) FindAvailableNode(public bool) (string, error) {
+	hostID, found := findAvailableHost(b.ListNodeIDs(public))
+	if !found {
+		return "", fmt.Errorf("failed to find available node")
+	}
+	return hostID, nil
+}
+
+// findAvailableHost returns the first host of the list reachable by ssh
+func findAvailableHost(hostIDs []string) (string, bool) {
+	brokerCltHost := brokerclient.New().Host
+	for _, hostID := range hostIDs {
+		sshCfg, err := brokerCltHost.SSHConfig(hostID)
+		if err != nil {
+			log.Errorf("failed to get ssh config of host '%s': %s", hostID, err.Error())
+			continue
+		}
+		err = sshCfg.WaitServerReady(2 * time.Minute)
+		if err != nil {
+			continue
+		}
+		return hostID, true
+	}
+	return "", false
+}
+
+// Delete destroys everything related to the infrastructure built for the cluster
+func (b *BluePrint) Delete() error {
+	if b.metadata == nil {
+		return fmt.Errorf("no metadata found for this cluster")
+	}
+
+	// Updates metadata
+	err := b.updateMetadata(func() error {
+		b.Core.State = ClusterState.Removed
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	broker := brokerclient.New()
+
+	// Deletes the nodes and the masters
+	hosts := append([]string{}, b.Core.PublicNodeIDs...)
+	hosts = append(hosts, b.Core.PrivateNodeIDs...)
+	hosts = append(hosts, b.manager.MasterIDs...)
+	if len(hosts) > 0 {
+		err = broker.Host.Delete(hosts, brokerclient.DefaultExecutionTimeout)
+		if err != nil {
+			log.Errorf("failed to delete hosts of cluster '%s': %s", b.Core.Name, err.Error())
+		}
+	}
+
+	// Deletes the network and gateway
+	err = broker.Network.Delete([]string{b.Core.NetworkID}, brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		return err
+	}
+
+	// Deletes the key pair of the user cladm
+	if b.Core.Keypair != nil {
+		err = b.provider.DeleteKeyPair(b.Core.Keypair.ID)
+		if err != nil {
+			log.Errorf("failed to delete key pair of cluster '%s': %s", b.Core.Name, err.Error())
+		}
+	}
+
+	// Deletes the metadata
+	err = b.metadata.Delete()
+	if err != nil {
+		return err
+	}
+	b.metadata = nil
+	return nil
+}
+
+func init() {
+	gob.Register(managerData{})
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blueprint
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	clusterapi "github.com/CS-SI/SafeScale/deploy/cluster/api"
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/Extension"
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/Flavor"
+)
+
+func newTestBluePrint(makers Makers) *BluePrint {
+	return &BluePrint{
+		Core: &clusterapi.ClusterCore{
+			Name:      "mycluster",
+			Flavor:    Flavor.BOH,
+			NetworkID: "net-id",
+			NodesDef: pb.HostDefinition{
+				CPUNumber: 4,
+				RAM:       15.0,
+				Disk:      100,
+				ImageID:   "Ubuntu 16.04",
+				UserData:  []string{"#cloud-config\n"},
+			},
+		},
+		makers:  makers,
+		manager: &managerData{},
+	}
+}
+
+// withGatewayLoader replaces the lookup of the gateway in the metadata of the provider; the returned function
+// restores it
+func withGatewayLoader(fn func(b *BluePrint) (string, error)) func() {
+	previous := loadGatewayID
+	loadGatewayID = fn
+	return func() { loadGatewayID = previous }
+}
+
+func TestNodeDefinition(t *testing.T) {
+	b := newTestBluePrint(Makers{})
+	base := pb.HostDefinition{CPUNumber: 4, RAM: 15.0, Disk: 100, ImageID: "Ubuntu 16.04"}
+
+	assert.Equal(t, base, b.nodeDefinition(base, nil, true))
+
+	// With atLeast, the values of base are minimums
+	def := b.nodeDefinition(base, &pb.HostDefinition{CPUNumber: 2, RAM: 32.0, Disk: 50}, true)
+	assert.Equal(t, int32(4), def.CPUNumber)
+	assert.Equal(t, float32(32.0), def.RAM)
+	assert.Equal(t, int32(100), def.Disk)
+
+	// Without atLeast, any value set replaces the one of base
+	def = b.nodeDefinition(base, &pb.HostDefinition{CPUNumber: 2, Disk: 50}, false)
+	assert.Equal(t, int32(2), def.CPUNumber)
+	assert.Equal(t, float32(15.0), def.RAM)
+	assert.Equal(t, int32(50), def.Disk)
+
+	def = b.nodeDefinition(base, &pb.HostDefinition{ImageID: "CentOS 7.3", UserData: []string{"#!/bin/sh\n"}}, true)
+	assert.Equal(t, "CentOS 7.3", def.ImageID)
+	assert.Equal(t, []string{"#!/bin/sh\n"}, def.UserData)
+
+	// A flavor enforcing its image ignores the one requested
+	b = newTestBluePrint(Makers{EnforceDefaultImage: true})
+	def = b.nodeDefinition(base, &pb.HostDefinition{ImageID: "CentOS 7.3"}, true)
+	assert.Equal(t, "Ubuntu 16.04", def.ImageID)
+}
+
+func TestMasterDefinition(t *testing.T) {
+	// Without sizing of the flavor, masters are sized like the nodes, without their cloud-init parts
+	b := newTestBluePrint(Makers{})
+	def := b.masterDefinition()
+	assert.Equal(t, int32(4), def.CPUNumber)
+	assert.Equal(t, "Ubuntu 16.04", def.ImageID)
+	assert.Empty(t, def.UserData)
+	assert.NotEmpty(t, b.Core.NodesDef.UserData)
+
+	b = newTestBluePrint(Makers{
+		DefaultMasterSizing: func(b *BluePrint) pb.HostDefinition {
+			return pb.HostDefinition{CPUNumber: 2, RAM: 8.0, Disk: 60}
+		},
+	})
+	def = b.masterDefinition()
+	assert.Equal(t, int32(2), def.CPUNumber)
+	assert.Equal(t, "Ubuntu 16.04", def.ImageID)
+
+	b.makers.DefaultMasterSizing = func(b *BluePrint) pb.HostDefinition {
+		return pb.HostDefinition{CPUNumber: 2, RAM: 8.0, Disk: 60, ImageID: "CentOS 7.3"}
+	}
+	assert.Equal(t, "CentOS 7.3", b.masterDefinition().ImageID)
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	defer withGatewayLoader(func(b *BluePrint) (string, error) {
+		return "", fmt.Errorf("unexpected lookup of gateway")
+	})()
+
+	b := newTestBluePrint(Makers{})
+	b.manager = &managerData{
+		GatewayID:            "gw-id",
+		MasterIDs:            []string{"master-1"},
+		MasterIPs:            []string{"192.168.0.10"},
+		PrivateNodeIPs:       []string{"192.168.0.20", "192.168.0.21"},
+		StateCollectInterval: 5 * time.Minute,
+		MasterLastIndex:      1,
+		PrivateLastIndex:     2,
+	}
+	b.Core.SetExtension(Extension.FlavorV1, b.manager)
+	b.Core.PrivateNodeIDs = []string{"node-1", "node-2"}
+
+	buf, err := b.Core.Serialize()
+	require.NoError(t, err)
+	core := &clusterapi.ClusterCore{}
+	require.NoError(t, core.Deserialize(buf))
+
+	loaded := &BluePrint{Core: core}
+	require.NoError(t, loaded.resetManager())
+	assert.Equal(t, b.manager, loaded.manager)
+	assert.Equal(t, b.Core.NodesDef, loaded.Core.NodesDef)
+	assert.Equal(t, b.Core.PrivateNodeIDs, loaded.Core.PrivateNodeIDs)
+	assert.Equal(t, "gw-id", loaded.GetGatewayID())
+}
+
+func TestMigrateManager(t *testing.T) {
+	// Clusters DCOS created before the engine kept the gateway as bootstrap server
+	restore := withGatewayLoader(func(b *BluePrint) (string, error) {
+		return "", fmt.Errorf("unexpected lookup of gateway")
+	})
+	defer restore()
+	b := newTestBluePrint(Makers{})
+	b.Core.SetExtension(Extension.FlavorV1, map[string]interface{}{
+		"BootstrapID": "gw-id",
+		"BootstrapIP": "192.168.0.1",
+		"MasterIDs":   []interface{}{"master-1"},
+	})
+	require.NoError(t, b.resetManager())
+	assert.Equal(t, "gw-id", b.GetGatewayID())
+	assert.Equal(t, []string{"master-1"}, b.ListMasterIDs())
+
+	// The other flavors didn't keep the gateway; it's found from the network of the cluster
+	withGatewayLoader(func(b *BluePrint) (string, error) {
+		assert.Equal(t, "net-id", b.Core.NetworkID)
+		return "gw-id", nil
+	})
+	b = newTestBluePrint(Makers{})
+	b.Core.SetExtension(Extension.FlavorV1, map[string]interface{}{
+		"MasterIDs": []interface{}{"master-1"},
+	})
+	require.NoError(t, b.resetManager())
+	assert.Equal(t, "gw-id", b.GetGatewayID())
+
+	// A gateway not found doesn't prevent the loading of the cluster, but its use fails
+	withGatewayLoader(func(b *BluePrint) (string, error) {
+		return "", fmt.Errorf("not found")
+	})
+	b = newTestBluePrint(Makers{})
+	b.Core.SetExtension(Extension.FlavorV1, map[string]interface{}{})
+	require.NoError(t, b.resetManager())
+	assert.Empty(t, b.GetGatewayID())
+	_, err := b.inspectGateway()
+	assert.Error(t, err)
+}
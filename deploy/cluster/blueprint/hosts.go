@@ -0,0 +1,626 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blueprint
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	txttmpl "text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	brokerclient "github.com/CS-SI/SafeScale/broker/client"
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/NodeType"
+	flavortools "github.com/CS-SI/SafeScale/deploy/cluster/flavors/utils"
+	"github.com/CS-SI/SafeScale/deploy/install"
+	"github.com/CS-SI/SafeScale/utils/template"
+)
+
+// nodeTypeString returns the string used in logs and hostnames for a type of host
+func nodeTypeString(nodeType NodeType.Enum) string {
+	switch nodeType {
+	case NodeType.Bootstrap:
+		return "gateway"
+	case NodeType.Master:
+		return "master"
+	case NodeType.PublicNode:
+		return "public node"
+	default:
+		return "private node"
+	}
+}
+
+// runInParallel calls fn for each index from 1 to count in goroutines, and returns the errors collected
+func runInParallel(count int, fn func(index int) error) error {
+	var dones []chan error
+	for i := 1; i <= count; i++ {
+		d := make(chan error)
+		dones = append(dones, d)
+		go func(index int, done chan error) {
+			done <- fn(index)
+		}(i, d)
+	}
+	var errors []string
+	for _, d := range dones {
+		err := <-d
+		if err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	if len(errors) > 0 {
+		return fmt.Errorf(strings.Join(errors, "\n"))
+	}
+	return nil
+}
+
+// defaultImage returns the image to use for the hosts of the cluster
+func (b *BluePrint) defaultImage() string {
+	if b.makers.DefaultImage != nil {
+		return b.makers.DefaultImage(b)
+	}
+	return "Ubuntu 16.04"
+}
+
+// defaultNodeDefinition returns the minimal sizing of a node of the flavor
+func (b *BluePrint) defaultNodeDefinition() pb.HostDefinition {
+	def := pb.HostDefinition{
+		CPUNumber: 4,
+		RAM:       15.0,
+		Disk:      100,
+	}
+	if b.makers.DefaultNodeSizing != nil {
+		def = b.makers.DefaultNodeSizing(b)
+	}
+	if def.ImageID == "" {
+		def.ImageID = b.defaultImage()
+	}
+	return def
+}
+
+// nodeDefinition merges the request with base; if atLeast is true, the values of base are minimums,
+// otherwise any value set in the request replaces the one of base; the cloud-init parts of the request,
+// if any, replace those of base
+func (b *BluePrint) nodeDefinition(base pb.HostDefinition, req *pb.HostDefinition, atLeast bool) pb.HostDefinition {
+	def := base
+	if req == nil {
+		return def
+	}
+	if req.CPUNumber > def.CPUNumber || (!atLeast && req.CPUNumber > 0) {
+		def.CPUNumber = req.CPUNumber
+	}
+	if req.RAM > def.RAM || (!atLeast && req.RAM > 0.0) {
+		def.RAM = req.RAM
+	}
+	if req.Disk > def.Disk || (!atLeast && req.Disk > 0) {
+		def.Disk = req.Disk
+	}
+	if req.ImageID != "" && req.ImageID != def.ImageID {
+		if b.makers.EnforceDefaultImage {
+			log.Printf("Cluster flavor '%s' enforces image '%s', image '%s' ignored\n", b.Core.Flavor.String(), def.ImageID, req.ImageID)
+		} else {
+			def.ImageID = req.ImageID
+		}
+	}
+	if len(req.UserData) > 0 {
+		def.UserData = req.UserData
+	}
+	return def
+}
+
+// gatewayDefinition returns the sizing of the gateway
+func (b *BluePrint) gatewayDefinition() pb.GatewayDefinition {
+	def := pb.GatewayDefinition{
+		CPU:  2,
+		RAM:  15.0,
+		Disk: 60,
+	}
+	if b.makers.DefaultGatewaySizing != nil {
+		def = b.makers.DefaultGatewaySizing(b)
+	}
+	if def.ImageID == "" {
+		def.ImageID = b.defaultImage()
+	}
+	return def
+}
+
+// masterDefinition returns the sizing of the masters; the cloud-init parts of the nodes aren't used by the masters
+func (b *BluePrint) masterDefinition() pb.HostDefinition {
+	if b.makers.DefaultMasterSizing == nil {
+		def := b.Core.NodesDef
+		def.UserData = nil
+		return def
+	}
+	def := b.makers.DefaultMasterSizing(b)
+	if def.ImageID == "" {
+		def.ImageID = b.Core.NodesDef.ImageID
+	}
+	return def
+}
+
+// buildHostname builds a unique hostname in the cluster
+func (b *BluePrint) buildHostname(nodeType NodeType.Enum) (string, error) {
+	var (
+		index    int
+		coreName string
+	)
+
+	switch nodeType {
+	case NodeType.PublicNode:
+		coreName = "pubnode"
+	case NodeType.PrivateNode:
+		coreName = "node"
+	case NodeType.Master:
+		coreName = "master"
+	default:
+		return "", fmt.Errorf("Invalid Node Type '%v'", nodeType)
+	}
+
+	err := b.updateMetadata(func() error {
+		switch nodeType {
+		case NodeType.PublicNode:
+			b.manager.PublicLastIndex++
+			index = b.manager.PublicLastIndex
+		case NodeType.PrivateNode:
+			b.manager.PrivateLastIndex++
+			index = b.manager.PrivateLastIndex
+		case NodeType.Master:
+			b.manager.MasterLastIndex++
+			index = b.manager.MasterLastIndex
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return b.Core.Name + "-" + coreName + "-" + strconv.Itoa(index), nil
+}
+
+// getRequirements returns the realized script installing the requirements common to all the hosts
+func (b *BluePrint) getRequirements() (string, error) {
+	if b.makers.RequirementsScript == "" || b.makers.GetTemplateBox == nil {
+		return "", nil
+	}
+	b.requirementsLock.Lock()
+	defer b.requirementsLock.Unlock()
+	if b.requirements == nil {
+		box, err := b.makers.GetTemplateBox()
+		if err != nil {
+			return "", err
+		}
+		tmplString, err := box.String(b.makers.RequirementsScript)
+		if err != nil {
+			return "", fmt.Errorf("error loading script template: %s", err.Error())
+		}
+		tmplPrepared, err := txttmpl.New("install_requirements").Funcs(template.MergeFuncs(b.makers.FuncMap, false)).Parse(tmplString)
+		if err != nil {
+			return "", fmt.Errorf("error parsing script template: %s", err.Error())
+		}
+		dataBuffer := bytes.NewBufferString("")
+		err = tmplPrepared.Execute(dataBuffer, map[string]interface{}{
+			"CIDR":          b.Core.CIDR,
+			"Username":      adminUser,
+			"CladmPassword": b.Core.AdminPassword,
+			"SSHPublicKey":  b.Core.Keypair.PublicKey,
+			"SSHPrivateKey": b.Core.Keypair.PrivateKey,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error realizing script template: %s", err.Error())
+		}
+		result := dataBuffer.String()
+		b.requirements = &result
+	}
+	return *b.requirements, nil
+}
+
+// ExecuteScript realizes the script template of the flavor with data, and runs it on the host
+func (b *BluePrint) ExecuteScript(tmplName string, data map[string]interface{}, host *pb.Host) error {
+	if b.makers.GetTemplateBox == nil {
+		return fmt.Errorf("no script available for flavor '%s'", b.Core.Flavor.String())
+	}
+	box, err := b.makers.GetTemplateBox()
+	if err != nil {
+		return err
+	}
+
+	var dnsServers []string
+	cfg, err := b.provider.GetCfgOpts()
+	if err == nil {
+		dnsServers = cfg.GetSliceOfStrings("DNSList")
+	}
+	requirements, err := b.getRequirements()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve installation script: %s", err.Error())
+	}
+	values := map[string]interface{}{
+		"ClusterName":               b.Core.Name,
+		"CIDR":                      b.Core.CIDR,
+		"GatewayIP":                 b.Core.GatewayIP,
+		"MasterIPs":                 b.manager.MasterIPs,
+		"Username":                  adminUser,
+		"CladmPassword":             b.Core.AdminPassword,
+		"DNSServerIPs":              dnsServers,
+		"InstallCommonRequirements": requirements,
+	}
+	for k, v := range data {
+		values[k] = v
+	}
+
+	retcode, _, _, err := flavortools.ExecuteScript(box, b.makers.FuncMap, tmplName, values, host.ID)
+	if err != nil {
+		return fmt.Errorf("failed to remotely run script '%s' on host '%s': %s", tmplName, host.Name, err.Error())
+	}
+	if retcode != 0 {
+		if retcode == 255 {
+			return fmt.Errorf("failed to run script '%s' on host '%s': remote connection failed", tmplName, host.Name)
+		}
+		if b.makers.ScriptErrorMessage != nil {
+			if msg := b.makers.ScriptErrorMessage(retcode); msg != "" {
+				return fmt.Errorf("script '%s' failed on host '%s': %s", tmplName, host.Name, msg)
+			}
+		}
+		return fmt.Errorf("script '%s' failed on host '%s' with error code %d", tmplName, host.Name, retcode)
+	}
+	return nil
+}
+
+// installHost runs the installation script of the flavor on a new host, then adds the default features
+func (b *BluePrint) installHost(host *pb.Host, nodeType NodeType.Enum, logPrefix string) error {
+	if b.makers.GetNodeInstallationScript != nil {
+		script, data := b.makers.GetNodeInstallationScript(b, nodeType)
+		if script != "" {
+			err := b.aborted()
+			if err != nil {
+				return err
+			}
+			b.step("%s installing requirements...", logPrefix)
+			err = b.ExecuteScript(script, data, host)
+			if err != nil {
+				log.Printf("%s installation failed: %s\n", logPrefix, err.Error())
+				return err
+			}
+			b.step("%s requirements installed successfully", logPrefix)
+		}
+	}
+
+	target := install.NewHostTarget(host)
+	values := install.Variables{
+		"Username": adminUser,
+		"Password": b.Core.AdminPassword,
+	}
+	for _, name := range b.makers.Features[nodeType] {
+		err := b.addFeature(name, target, values, logPrefix)
+		if err != nil {
+			return fmt.Errorf("failed to add feature '%s' on host '%s': %s", name, host.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// AddHostFeature adds the feature on the host, unless it has been disabled for the cluster;
+// intended to be used by the hooks of the flavors
+func (b *BluePrint) AddHostFeature(name string, host *pb.Host) error {
+	values := install.Variables{
+		"Username": adminUser,
+		"Password": b.Core.AdminPassword,
+	}
+	err := b.addFeature(name, install.NewHostTarget(host), values, fmt.Sprintf("[%s]", host.Name))
+	if err != nil {
+		return fmt.Errorf("failed to add feature '%s' on host '%s': %s", name, host.Name, err.Error())
+	}
+	return nil
+}
+
+// addFeature adds the feature on the target, unless it has been disabled for the cluster
+func (b *BluePrint) addFeature(name string, target install.Target, values install.Variables, logPrefix string) error {
+	if _, ok := b.Core.DisabledFeatures[name]; ok {
+		return nil
+	}
+	err := b.aborted()
+	if err != nil {
+		return err
+	}
+
+	b.step("%s adding feature '%s'...", logPrefix, name)
+	feature, err := install.NewFeature(name)
+	if err != nil {
+		return err
+	}
+	results, err := feature.Add(target, values, install.Settings{})
+	if err != nil {
+		return err
+	}
+	if !results.Successful() {
+		return fmt.Errorf(results.AllErrorMessages())
+	}
+	b.step("%s feature '%s' added successfully", logPrefix, name)
+	return nil
+}
+
+// installGateway prepares the gateway
+func (b *BluePrint) installGateway() error {
+	b.step("[gateway] starting installation...")
+
+	gw, err := b.inspectGateway()
+	if err != nil {
+		return err
+	}
+	sshCfg, err := brokerclient.New().Host.SSHConfig(gw.ID)
+	if err != nil {
+		return err
+	}
+	err = sshCfg.WaitServerReadyUnless(5*time.Minute, b.aborted)
+	if err != nil {
+		return err
+	}
+
+	err = b.installHost(gw, NodeType.Bootstrap, "[gateway]")
+	if err != nil {
+		return err
+	}
+	b.step("[gateway] preparation successful")
+	return nil
+}
+
+// createMasters creates <count> masters in parallel
+func (b *BluePrint) createMasters(count int) error {
+	if count <= 0 {
+		return nil
+	}
+	b.step("Creating %d master%s...", count, plural(count))
+
+	def := b.masterDefinition()
+	return runInParallel(count, func(index int) error {
+		_, err := b.createHost(index, NodeType.Master, def)
+		return err
+	})
+}
+
+// createNodes creates <count> nodes in parallel and returns the IDs of those created, even on failure
+func (b *BluePrint) createNodes(count int, public bool, def pb.HostDefinition) ([]string, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+	nodeType := NodeType.PrivateNode
+	if public {
+		nodeType = NodeType.PublicNode
+	}
+	b.step("Creating %d %s%s...", count, nodeTypeString(nodeType), plural(count))
+
+	hostIDs := make([]string, count)
+	err := runInParallel(count, func(index int) error {
+		hostID, err := b.createHost(index, nodeType, def)
+		hostIDs[index-1] = hostID
+		return err
+	})
+	return hostIDs, err
+}
+
+func plural(count int) string {
+	if count > 1 {
+		return "s"
+	}
+	return ""
+}
+
+// createHost creates a host of type nodeType with a brokerd operation, registers it in the cluster and installs it;
+// returns the ID of the host if it has been registered, even if its installation failed
+func (b *BluePrint) createHost(index int, nodeType NodeType.Enum, def pb.HostDefinition) (string, error) {
+	nodeTypeStr := nodeTypeString(nodeType)
+	logPrefix := fmt.Sprintf("[%s #%d]", nodeTypeStr, index)
+	err := b.aborted()
+	if err != nil {
+		return "", err
+	}
+	b.step("%s starting creation...", logPrefix)
+
+	name, err := b.buildHostname(nodeType)
+	if err != nil {
+		log.Printf("%s creation failed: %s\n", logPrefix, err.Error())
+		return "", fmt.Errorf("failed to create %s #%d: %s", nodeTypeStr, index, err.Error())
+	}
+	def.Name = name
+	def.Network = b.Core.NetworkID
+	def.Public = nodeType == NodeType.PublicNode
+	broker := brokerclient.New().Host
+	op, err := broker.CreateAsync(def, brokerclient.DefaultExecutionTimeout)
+	if err == nil {
+		op, err = b.waitOperation(op, logPrefix)
+	}
+	if err != nil {
+		err = brokerclient.DecorateError(err, "creation of host resource", true)
+		log.Printf("%s host resource creation failed: %s\n", logPrefix, err.Error())
+		return "", fmt.Errorf("failed to create %s #%d: %s", nodeTypeStr, index, err.Error())
+	}
+	host, err := broker.Inspect(op.GetResultID(), brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		derr := broker.Delete([]string{op.GetResultID()}, brokerclient.DefaultExecutionTimeout)
+		if derr != nil {
+			log.Errorf("%s failed to delete host: %s", logPrefix, derr.Error())
+		}
+		err = brokerclient.DecorateError(err, "inspection of host", false)
+		return "", fmt.Errorf("failed to create %s #%d: %s", nodeTypeStr, index, err.Error())
+	}
+	logPrefix = fmt.Sprintf("[%s #%d (%s)]", nodeTypeStr, index, host.Name)
+
+	// Registers the new host in the cluster
+	err = b.updateMetadata(func() error {
+		switch nodeType {
+		case NodeType.Master:
+			b.manager.MasterIDs = append(b.manager.MasterIDs, host.ID)
+			b.manager.MasterIPs = append(b.manager.MasterIPs, host.PrivateIP)
+		case NodeType.PublicNode:
+			b.Core.PublicNodeIDs = append(b.Core.PublicNodeIDs, host.ID)
+			b.manager.PublicNodeIPs = append(b.manager.PublicNodeIPs, host.PrivateIP)
+		default:
+			b.Core.PrivateNodeIDs = append(b.Core.PrivateNodeIDs, host.ID)
+			b.manager.PrivateNodeIPs = append(b.manager.PrivateNodeIPs, host.PrivateIP)
+		}
+		return nil
+	})
+	if err != nil {
+		derr := broker.Delete([]string{host.ID}, brokerclient.DefaultExecutionTimeout)
+		if derr != nil {
+			log.Errorf("%s failed to delete host: %s", logPrefix, derr.Error())
+		}
+		log.Printf("%s creation failed: %s\n", logPrefix, err.Error())
+		return "", fmt.Errorf("failed to update Cluster metadata: %s", err.Error())
+	}
+	b.step("%s host resource created successfully", logPrefix)
+
+	err = b.installHost(host, nodeType, logPrefix)
+	if err != nil {
+		return host.ID, err
+	}
+	b.step("%s creation successful", logPrefix)
+	return host.ID, nil
+}
+
+// configureGateway calls the flavor hook configuring the gateway
+func (b *BluePrint) configureGateway() error {
+	if b.makers.ConfigureGateway == nil {
+		return nil
+	}
+	err := b.aborted()
+	if err != nil {
+		return err
+	}
+	b.step("[gateway] starting configuration...")
+	gw, err := b.inspectGateway()
+	if err != nil {
+		return err
+	}
+	err = b.makers.ConfigureGateway(b, gw)
+	if err != nil {
+		log.Printf("[gateway] configuration failed: %s", err.Error())
+		return err
+	}
+	b.step("[gateway] configuration successful")
+	return nil
+}
+
+// configureMasters calls the flavor hook configuring the masters; the first master is configured
+// alone, the others in parallel
+func (b *BluePrint) configureMasters() error {
+	if b.makers.ConfigureMaster == nil || len(b.manager.MasterIDs) == 0 {
+		return nil
+	}
+	b.step("Configuring masters...")
+
+	masterIDs := b.manager.MasterIDs
+	configure := func(index int) error {
+		err := b.aborted()
+		if err != nil {
+			return err
+		}
+		host, err := brokerclient.New().Host.Inspect(masterIDs[index-1], brokerclient.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to get metadata of host: %s", err.Error())
+		}
+		err = b.makers.ConfigureMaster(b, index, host)
+		if err != nil {
+			return fmt.Errorf("failed to configure master '%s': %s", host.Name, err.Error())
+		}
+		return nil
+	}
+	err := configure(1)
+	if err != nil {
+		return err
+	}
+	err = runInParallel(len(masterIDs)-1, func(index int) error {
+		return configure(index + 1)
+	})
+	if err != nil {
+		return err
+	}
+	b.step("Masters configured successfully")
+	return nil
+}
+
+// configureNodes calls the flavor hook configuring the nodes in parallel
+func (b *BluePrint) configureNodes(hostIDs []string, nodeType NodeType.Enum) error {
+	if b.makers.ConfigureNode == nil || len(hostIDs) == 0 {
+		return nil
+	}
+	b.step("Configuring %ss...", nodeTypeString(nodeType))
+
+	err := runInParallel(len(hostIDs), func(index int) error {
+		err := b.aborted()
+		if err != nil {
+			return err
+		}
+		host, err := brokerclient.New().Host.Inspect(hostIDs[index-1], brokerclient.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to get metadata of host: %s", err.Error())
+		}
+		err = b.makers.ConfigureNode(b, index, host, nodeType)
+		if err != nil {
+			return fmt.Errorf("failed to configure %s '%s': %s", nodeTypeString(nodeType), host.Name, err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.step("%ss configured successfully", strings.Title(nodeTypeString(nodeType)))
+	return nil
+}
+
+// configureCluster adds the cluster features, then calls the flavor hook ending the configuration
+func (b *BluePrint) configureCluster() error {
+	b.step("Configuring cluster '%s'...", b.Core.Name)
+	err := b.addClusterFeatures()
+	if err != nil {
+		return err
+	}
+	if b.makers.ConfigureCluster != nil {
+		return b.makers.ConfigureCluster(b)
+	}
+	return nil
+}
+
+// addClusterFeatures adds the default features of the cluster; done again when nodes are added, to
+// configure them
+func (b *BluePrint) addClusterFeatures() error {
+	if len(b.makers.ClusterFeatures) == 0 {
+		return nil
+	}
+
+	values := install.Variables{
+		"Username":          adminUser,
+		"Password":          b.Core.AdminPassword,
+		"PrimaryMasterIP":   "",
+		"SecondaryMasterIP": "",
+	}
+	if len(b.manager.MasterIPs) > 0 {
+		values["PrimaryMasterIP"] = b.manager.MasterIPs[0]
+	}
+	if len(b.manager.MasterIPs) > 1 {
+		values["SecondaryMasterIP"] = b.manager.MasterIPs[1]
+	}
+	target := install.NewClusterTarget(b)
+	for _, name := range b.makers.ClusterFeatures {
+		err := b.addFeature(name, target, values, "[cluster]")
+		if err != nil {
+			return fmt.Errorf("failed to add feature '%s' on cluster '%s': %s", name, b.Core.Name, err.Error())
+		}
+	}
+	return nil
+}
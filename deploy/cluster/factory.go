@@ -81,6 +81,7 @@ func Get(name string) (clusterapi.Cluster, error) {
 }
 
 // Create creates a cluster following the parameters of the request
+// If req.Tracker is set, the progress is reported to it and the creation is rolled back when it's aborted
 func Create(req clusterapi.Request) (clusterapi.Cluster, error) {
 	// Validates parameters
 	if req.Name == "" {
@@ -210,6 +211,14 @@ func Sanitize(name string) error {
 	switch clusterCore.Flavor {
 	case Flavor.DCOS:
 		return dcos.Sanitize(m)
+	case Flavor.BOH:
+		return boh.Sanitize(m)
+	case Flavor.K8S:
+		return k8s.Sanitize(m)
+	case Flavor.OHPC:
+		return ohpc.Sanitize(m)
+	case Flavor.SWARM:
+		return swarm.Sanitize(m)
 	default:
 		return fmt.Errorf("Sanitization of cluster Flavor '%s' not available", clusterCore.Flavor.String())
 	}
@@ -110,6 +110,32 @@ type ClientAPI interface {
 	// DeleteVolumeAttachment deletes the volume attachment identifed by id
 	DeleteVolumeAttachment(serverID, id string) error
 
+	// CreateSecurityGroup creates a security group, without rule
+	CreateSecurityGroup(request model.SecurityGroupRequest) (*model.SecurityGroup, error)
+	// GetSecurityGroup returns the security group identified by id, with its rules
+	GetSecurityGroup(id string) (*model.SecurityGroup, error)
+	// ListSecurityGroups lists available security groups
+	ListSecurityGroups() ([]model.SecurityGroup, error)
+	// DeleteSecurityGroup deletes the security group identified by id
+	DeleteSecurityGroup(id string) error
+	// AddRule adds a rule allowing incoming traffic to the security group identified by groupID
+	AddRule(groupID string, rule model.SecurityGroupRule) (*model.SecurityGroupRule, error)
+	// DeleteRule deletes the rule identified by ruleID from the security group identified by groupID
+	DeleteRule(groupID string, ruleID string) error
+	// BindToHost binds the security group identified by groupID to the host identified by hostID
+	BindToHost(groupID string, hostID string) error
+	// UnbindFromHost unbinds the security group identified by groupID from the host identified by hostID
+	UnbindFromHost(groupID string, hostID string) error
+
+	// CreateVIP creates a private virtual IP in the network identified by networkID
+	CreateVIP(networkID string, name string) (*model.VIP, error)
+	// BindHostToVIP allows the host identified by hostID to hold the VIP
+	BindHostToVIP(vip *model.VIP, hostID string) error
+	// UnbindHostFromVIP forbids the host identified by hostID to hold the VIP
+	UnbindHostFromVIP(vip *model.VIP, hostID string) error
+	// DeleteVIP deletes the VIP
+	DeleteVIP(vip *model.VIP) error
+
 	// // CreateBucket creates an object container
 	// CreateBucket(bucketName string) error
 	// // DeleteBucket deletes an object container
@@ -137,4 +163,6 @@ type ClientAPI interface {
 	GetAuthOpts() (model.Config, error)
 	// GetCfgOpts returns configuration options as a Config
 	GetCfgOpts() (model.Config, error)
+	// GetCapabilities returns what the provider supports
+	GetCapabilities() model.Capabilities
 }
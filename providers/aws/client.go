@@ -16,68 +16,68 @@
 
 package aws
 
-//go:generate rice embed-go
 import (
-	"bytes"
-	"encoding/base64"
-	"encoding/gob"
-	"encoding/json"
 	"fmt"
-	"regexp"
-	"strconv"
-	"strings"
-	"text/template"
-	"time"
+	"net"
+	"sort"
 
-	rice "github.com/GeertJohan/go.rice"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/pricing"
-	awss3 "github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/CS-SI/SafeScale/providers"
 	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/metadata"
 	"github.com/CS-SI/SafeScale/providers/model"
-	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
-	"github.com/CS-SI/SafeScale/providers/model/enums/HostState"
+	"github.com/CS-SI/SafeScale/providers/model/enums/FloatingIPModel"
 	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeSpeed"
-	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeState"
-	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
-	"github.com/CS-SI/SafeScale/system"
-	"github.com/CS-SI/SafeScale/utils/metadata"
 )
 
-// //Config AWS configurations
-// type Config struct {
-// 	ImageOwners    []string
-// 	DefaultNetwork string
-// }
+// The resources of a tenant live in a VPC (Virtual Private Cloud) created on first use, with an internet gateway
+// as default route. A SafeScale network is a subnet of this VPC, and the hosts without public IP reach the
+// internet through the gateway host of their network, as with the OpenStack drivers.
+// The resources are named by their "Name" tag.
+
+const (
+	// defaultVPCName is the name of the VPC when the tenant doesn't set one
+	defaultVPCName = "safescale"
+	// defaultVPCCIDR is the CIDR of the VPC when the tenant doesn't set one
+	defaultVPCCIDR = "192.168.0.0/16"
+	// nameTag is the tag containing the name of the resources
+	nameTag = "Name"
+	// networkTag is the tag containing the ID of the network of a security group
+	networkTag = "Network"
+)
 
-//AuthOpts AWS credentials
-type AuthOpts struct {
+// AuthOptions AWS credentials and location of the resources
+type AuthOptions struct {
 	// AWS Access key ID
 	AccessKeyID string
-
 	// AWS Secret Access Key
 	SecretAccessKey string
-	// The region to send requests to. This parameter is required and must
-	// be configured globally or on a per-client basis unless otherwise
-	// noted. A full list of regions is found in the "Regions and Endpoints"
+	// The region to send requests to. A full list of regions is found in the "Regions and Endpoints"
 	// document.
 	//
 	// @see http://docs.aws.amazon.com/general/latest/gr/rande.html
 	//   AWS Regions and Endpoints
 	Region string
-	//Config *Config
+	// AvailabilityZone is the zone of the subnets, hosts and volumes (the first zone of the region if empty)
+	AvailabilityZone string
+	// Endpoint is the URL of the EC2 service, to use an EC2-compatible service instead of AWS
+	Endpoint string
+	// Name of the VPC (Virtual Private Cloud)
+	VPCName string
+	// CIDR of the VPC
+	VPCCIDR string
 }
 
 // Retrieve returns nil if it successfully retrieved the value.
 // Error is returned if the value were not obtainable, or empty.
-func (o AuthOpts) Retrieve() (credentials.Value, error) {
+func (o AuthOptions) Retrieve() (credentials.Value, error) {
 	return credentials.Value{
 		AccessKeyID:     o.AccessKeyID,
 		SecretAccessKey: o.SecretAccessKey,
@@ -87,49 +87,102 @@ func (o AuthOpts) Retrieve() (credentials.Value, error) {
 
 // IsExpired returns if the credentials are no longer valid, and need
 // to be retrieved.
-func (o AuthOpts) IsExpired() bool {
+func (o AuthOptions) IsExpired() bool {
 	return false
 }
 
+// CfgOptions configuration options
+type CfgOptions struct {
+	// DNSList list of DNS servers of the hosts
+	DNSList []string
+	// AutoHostNetworkInterfaces indicates if network interfaces are configured automatically by the provider or
+	// need a post configuration
+	AutoHostNetworkInterfaces bool
+	// ImageOwners are the IDs of the accounts publishing the images to use (all the images if empty)
+	ImageOwners []string
+	// MetadataBucket contains the name of the bucket storing metadata
+	MetadataBucket string
+	// DefaultImage names the image to use by default
+	DefaultImage string
+}
+
+// defaultImageOwners are the accounts of the publishers of Ubuntu, Fedora, Debian, CentOS, CoreOS and Gentoo
+var defaultImageOwners = []string{
+	"099720109477",
+	"013116697141",
+	"379101102735",
+	"057448758665",
+	"595879546273",
+	"902460189751",
+}
+
+// Client is the implementation of the aws driver regarding to the api.ClientAPI
+type Client struct {
+	// Opts contains authentication options
+	Opts *AuthOptions
+	// Cfg contains configuration options
+	Cfg *CfgOptions
+	// Session is the AWS session of the client
+	Session *session.Session
+	// EC2 is the client of the EC2 service
+	EC2 *ec2.EC2
+	// VPCID is the ID of the VPC of the tenant
+	VPCID string
+	// SecurityGroupID is the ID of the default security group of the VPC
+	SecurityGroupID string
+}
+
 // AuthenticatedClient returns an authenticated client
-func AuthenticatedClient(opts AuthOpts) (*Client, error) {
-	s, err := session.NewSession(&aws.Config{
+func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
+	if opts.VPCName == "" {
+		opts.VPCName = defaultVPCName
+	}
+	if opts.VPCCIDR == "" {
+		opts.VPCCIDR = defaultVPCCIDR
+	}
+	if _, _, err := net.ParseCIDR(opts.VPCCIDR); err != nil {
+		return nil, fmt.Errorf("invalid VPC CIDR '%s': %s", opts.VPCCIDR, err.Error())
+	}
+
+	config := &aws.Config{
 		Region:      aws.String(opts.Region),
 		Credentials: credentials.NewCredentials(opts),
-	})
-	if err != nil {
-		return nil, err
 	}
-	sPricing, err := session.NewSession(&aws.Config{
-		Region:      aws.String("us-east-1"),
-		Credentials: credentials.NewCredentials(opts),
-	})
-	if err != nil {
-		return nil, err
+	if opts.Endpoint != "" {
+		config.Endpoint = aws.String(opts.Endpoint)
 	}
-	box, err := rice.FindBox("scripts")
+	s, err := session.NewSession(config)
 	if err != nil {
 		return nil, err
 	}
-	userDataStr, err := box.String("userdata.sh")
+	if cfg.MetadataBucket == "" {
+		cfg.MetadataBucket = metadata.BuildMetadataBucketName(opts.AccessKeyID)
+	}
+	c := Client{
+		Opts:    &opts,
+		Cfg:     &cfg,
+		Session: s,
+		EC2:     ec2.New(s),
+	}
+
+	if c.Opts.AvailabilityZone == "" {
+		c.Opts.AvailabilityZone, err = c.selectAvailabilityZone()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Initializes the VPC
+	err = c.initVPC()
 	if err != nil {
 		return nil, err
 	}
-	tpl, err := template.New("user_data").Parse(userDataStr)
+
+	// Initializes the default security group
+	err = c.initDefaultSecurityGroup()
 	if err != nil {
 		return nil, err
 	}
-	c := Client{
-		Session:     s,
-		EC2:         ec2.New(s),
-		Pricing:     pricing.New(sPricing),
-		AuthOpts:    opts,
-		UserDataTpl: tpl,
-	}
-	//providers.InitializeBucket(&c)
-	//c.CreateContainer("gpac.aws.networks")
-	//c.CreateContainer("gpac.aws.wms")
-	//c.CreateContainer("gpac.aws.volumes")
 
 	return &c, nil
 }
@@ -144,1520 +197,335 @@ func wrapError(msg string, err error) error {
 	return err
 }
 
-// Build build a new Client from configuration parameter
-func (c *Client) Build(params map[string]interface{}) (api.ClientAPI, error) {
-	tenantName, _ := params["name"].(string)
-
-	identity, _ := params["identity"].(map[string]interface{})
-	compute, _ := params["compute"].(map[string]interface{})
-	network, _ := params["network"].(map[string]interface{})
-
-	accessKeyID, _ := identity["AccessKeyID"].(string)
-	secretAccessKey, _ := identity["SecretAccessKey"].(string)
-	identityEndpoint, _ := identity["EndPoint"].(string)
-
-	region, _ := compute["Region"].(string)
-	defaultImage, _ := compute["DefaultImage"]
-
-	return AuthenticatedClient(
-		AuthOpts{
-			AccessKeyID:     accessKeyID,
-			SecretAccessKey: secretAccessKey,
-			Region:          region,
-		}, CfgOpts{
-			DefaultImage: defaultImage,
-		},
-	)
-
-}
-
-// Client a AWS provider client
-type Client struct {
-	Session     *session.Session
-	EC2         *ec2.EC2
-	Pricing     *pricing.Pricing
-	AuthOpts    AuthOpts
-	UserDataTpl *template.Template
-	//ImageOwners []string
-}
-
-func (c *Client) ListNetworks(all bool) ([]api.Network, error) {
-	panic("implement me")
-}
-
-func (c *Client) ListHosts(all bool) ([]api.Host, error) {
-	panic("implement me")
-}
-
-func (c *Client) RebootHost(id string) error {
-	panic("implement me")
-}
-
-func (c *Client) ListVolumes(all bool) ([]api.Volume, error) {
-	panic("implement me")
-}
-
-func (c *Client) GetContainer(name string) (*api.ContainerInfo, error) {
-	panic("implement me")
-}
-
-func (c *Client) GetAuthOpts() (api.Config, error) {
-	panic("implement me")
+// isNotFound tells if err is an EC2 error of code <resource>.NotFound or <resource>.Malformed
+func isNotFound(err error, resource string) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == resource+".NotFound" || aerr.Code() == resource+".Malformed"
+	}
+	return false
 }
 
-func createFilters() []*ec2.Filter {
-	filters := []*ec2.Filter{
-		&ec2.Filter{
-			Name:   aws.String("state"),
-			Values: []*string{aws.String("available")},
-		},
-		&ec2.Filter{
-			Name:   aws.String("architecture"),
-			Values: []*string{aws.String("x86_64")},
-		},
-		&ec2.Filter{
-			Name:   aws.String("virtualization-type"),
-			Values: []*string{aws.String("hvm")},
-		},
-		&ec2.Filter{
-			Name:   aws.String("root-device-type"),
-			Values: []*string{aws.String("ebs")},
-		},
-	}
-	// Ubuntu 099720109477
-	// Fedora 013116697141
-	// Debian 379101102735
-	// CentOS 057448758665
-	// CoreOS 595879546273
-	// Gentoo 341857463381
-	owners := []*string{
-		aws.String("099720109477"),
-		aws.String("013116697141"),
-		aws.String("379101102735"),
-		aws.String("057448758665"),
-		aws.String("595879546273"),
-		aws.String("902460189751"),
+func pStr(s *string) string {
+	if s == nil {
+		var s string
+		return s
 	}
-	filters = append(filters, &ec2.Filter{
-		Name:   aws.String("owner-id"),
-		Values: owners,
-	})
-	return filters
+	return *s
 }
 
-// ListImages lists available OS images
-func (c *Client) ListImages(all bool) ([]model.Image, error) {
-
-	images, err := c.EC2.DescribeImages(&ec2.DescribeImagesInput{
-		//Owners: []*string{aws.String("aws-marketplace"), aws.String("self")},
-		Filters: createFilters(),
-	})
-	if err != nil {
-		return nil, err
-	}
-	var list []model.Image
-	for _, img := range images.Images {
-		if img.Description == nil || strings.Contains(strings.ToUpper(*img.Name), "TEST") {
-			continue
+// getTag returns the value of the tag key, or "" if the tag doesn't exist
+func getTag(tags []*ec2.Tag, key string) string {
+	for _, t := range tags {
+		if pStr(t.Key) == key {
+			return pStr(t.Value)
 		}
-		list = append(list, model.Image{
-			ID:   *img.ImageId,
-			Name: *img.Name,
-		})
 	}
-
-	return list, nil
-}
-
-// Attributes attributes of a compute instance
-type Attributes struct {
-	ClockSpeed                  string `json:"clockSpeed,omitempty"`
-	CurrentGeneration           string `json:"currentGeneration,omitempty"`
-	DedicatedEbsThroughput      string `json:"dedicatedEbsThroughput,omitempty"`
-	Ecu                         string `json:"ecu,omitempty"`
-	EnhancedNetworkingSupported string `json:"enhancedNetworkingSupported,omitempty"`
-	InstanceFamily              string `json:"instanceFamily,omitempty"`
-	InstanceType                string `json:"instanceType,omitempty"`
-	LicenseModel                string `json:"licenseModel,omitempty"`
-	Location                    string `json:"location,omitempty"`
-	LocationType                string `json:"locationType,omitempty"`
-	Memory                      string `json:"memory,omitempty"`
-	MetworkPerformance          string `json:"metworkPerformance,omitempty"`
-	NormalizationSizeFactor     string `json:"normalizationSizeFactor,omitempty"`
-	OperatingSystem             string `json:"operatingSystem,omitempty"`
-	Operation                   string `json:"operation,omitempty"`
-	PhysicalProcessor           string `json:"physicalProcessor,omitempty"`
-	PreInstalledSw              string `json:"preInstalled_sw,omitempty"`
-	ProcessorArchitecture       string `json:"processorArchitecture,omitempty"`
-	ProcessorFeatures           string `json:"processorFeatures,omitempty"`
-	Servicecode                 string `json:"servicecode,omitempty"`
-	Servicename                 string `json:"servicename,omitempty"`
-	Storage                     string `json:"storage,omitempty"`
-	Tenancy                     string `json:"tenancy,omitempty"`
-	Usagetype                   string `json:"usagetype,omitempty"`
-	Vcpu                        string `json:"vcpu,omitempty"`
-}
-
-// Product compute instance product
-type Product struct {
-	Attributes    Attributes `json:"attributes,omitempty"`
-	ProductFamily string     `json:"productFamily,omitempty"`
-	Sku           string     `json:"sku,omitempty"`
-}
-
-// PriceDimension compute instance price related to term condition
-type PriceDimension struct {
-	AppliesTo    []string           `json:"appliesTo,omitempty"`
-	BeginRange   string             `json:"beginRange,omitempty"`
-	Description  string             `json:"description,omitempty"`
-	EndRange     string             `json:"endRange,omitempty"`
-	PricePerUnit map[string]float32 `json:"pricePerUnit,omitempty"`
-	RateCode     string             `json:"RateCode,omitempty"`
-	Unit         string             `json:"Unit,omitempty"`
-}
-
-// PriceDimensions compute instance price dimensions
-type PriceDimensions struct {
-	PriceDimensionMap map[string]PriceDimension `json:"price_dimension_map,omitempty"`
-}
-
-// TermAttributes compute instance terms
-type TermAttributes struct {
-	LeaseContractLength string `json:"leaseContractLength,omitempty"`
-	OfferingClass       string `json:"offeringClass,omitempty"`
-	PurchaseOption      string `json:"purchaseOption,omitempty"`
-}
-
-// Card compute instance price card
-type Card struct {
-	EffectiveDate   string          `json:"effectiveDate,omitempty"`
-	OfferTermCode   string          `json:"offerTermCode,omitempty"`
-	PriceDimensions PriceDimensions `json:"priceDimensions,omitempty"`
-	Sku             string          `json:"sku,omitempty"`
-	TermAttributes  TermAttributes  `json:"termAttributes,omitempty"`
-}
-
-// OnDemand on demand compute instance cards
-type OnDemand struct {
-	Cards map[string]Card
-}
-
-// Reserved reserved compute instance cards
-type Reserved struct {
-	Cards map[string]Card `json:"cards,omitempty"`
-}
-
-// Terms compute instance prices terms
-type Terms struct {
-	OnDemand OnDemand `json:"onDemand,omitempty"`
-	Reserved Reserved `json:"reserved,omitempty"`
+	return ""
 }
 
-// Price Compute instance price information
-type Price struct {
-	Product         Product `json:"product,omitempty"`
-	PublicationDate string  `json:"publicationDate,omitempty"`
-	ServiceCode     string  `json:"serviceCode,omitempty"`
-	Terms           Terms   `json:"terms,omitempty"`
+// setTags sets tags on the resource identified by id
+func (c *Client) setTags(id string, tags map[string]string) error {
+	input := ec2.CreateTagsInput{
+		Resources: []*string{aws.String(id)},
+	}
+	for k, v := range tags {
+		input.Tags = append(input.Tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	_, err := c.EC2.CreateTags(&input)
+	return err
 }
 
-// GetImage returns the Image referenced by id
-func (c *Client) GetImage(id string) (*model.Image, error) {
-	images, err := c.EC2.DescribeImages(&ec2.DescribeImagesInput{
-		ImageIds: []*string{aws.String(id)},
-	})
-	if err != nil {
-		return nil, err
-	}
-	if len(images.Images) == 0 {
-		return nil, fmt.Errorf("Image %s does not exist", id)
+// filter returns an EC2 filter on name matching one of the values
+func filter(name string, values ...string) *ec2.Filter {
+	return &ec2.Filter{
+		Name:   aws.String(name),
+		Values: aws.StringSlice(values),
 	}
-	img := images.Images[0]
-	return &model.Image{
-		ID:   *img.ImageId,
-		Name: *img.Name,
-	}, nil
 }
 
-// GetTemplate returns the Template referenced by id
-func (c *Client) GetTemplate(id string) (*model.HostTemplate, error) {
-	input := pricing.GetProductsInput{
-		Filters: []*pricing.Filter{
-			{
-				Field: aws.String("ServiceCode"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("AmazonEC2"),
-			},
-			{
-				Field: aws.String("location"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("US East (Ohio)"),
-			},
+// vpcFilter returns the filter selecting the resources of the VPC of the tenant
+func (c *Client) vpcFilter() *ec2.Filter {
+	return filter("vpc-id", c.VPCID)
+}
 
-			{
-				Field: aws.String("preInstalledSw"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("NA"),
-			},
-			{
-				Field: aws.String("operatingSystem"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("Linux"),
-			},
+// Build build a new Client from configuration parameter
+func (c *Client) Build(params map[string]interface{}) (api.ClientAPI, error) {
+	identity, _ := params["identity"].(map[string]interface{})
+	compute, _ := params["compute"].(map[string]interface{})
+	network, _ := params["network"].(map[string]interface{})
 
-			{
-				Field: aws.String("instanceType"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String(id),
-			},
-		},
-		FormatVersion: aws.String("aws_v1"),
-		MaxResults:    aws.Int64(100),
-		ServiceCode:   aws.String("AmazonEC2"),
-	}
+	accessKeyID, _ := identity["AccessKeyID"].(string)
+	secretAccessKey, _ := identity["SecretAccessKey"].(string)
+	endpoint, _ := identity["Endpoint"].(string)
 
-	p, err := c.Pricing.GetProducts(&input)
-	if err != nil {
-		return nil, err
-	}
-	for _, price := range p.PriceList {
-		jsonPrice, err := json.Marshal(price)
-		if err != nil {
-			continue
-		}
-		price := Price{}
-		err = json.Unmarshal(jsonPrice, &price)
-		if err != nil {
-			continue
-		}
-		if strings.Contains(price.Product.Attributes.Usagetype, "USE2-BoxUsage:") {
-			cores, err := strconv.Atoi(price.Product.Attributes.Vcpu)
-			if err != nil {
-				continue
+	region, _ := compute["Region"].(string)
+	zone, _ := compute["AvailabilityZone"].(string)
+	defaultImage, _ := compute["DefaultImage"].(string)
+	imageOwners := defaultImageOwners
+	if list, ok := compute["ImageOwners"].([]interface{}); ok {
+		imageOwners = []string{}
+		for _, o := range list {
+			if s, ok := o.(string); ok {
+				imageOwners = append(imageOwners, s)
 			}
+		}
+	}
 
-			tpl := model.HostTemplate{
-				HostTemplate: &propsv1.HostTemplate{
-					ID:   price.Product.Attributes.InstanceType,
-					Name: price.Product.Attributes.InstanceType,
-					HostSize: &propsv1.HostSize{
-						Cores:    cores,
-						DiskSize: int(parseStorage(price.Product.Attributes.Storage)),
-						RAMSize:  float32(parseMemory(price.Product.Attributes.Memory)),
-					},
-				},
+	vpcName, _ := network["VPCName"].(string)
+	vpcCIDR, _ := network["VPCCIDR"].(string)
+	// By default, uses the DNS server of the VPC
+	dnsList := []string{"169.254.169.253"}
+	if list, ok := network["DNSList"].([]interface{}); ok {
+		dnsList = []string{}
+		for _, d := range list {
+			if s, ok := d.(string); ok {
+				dnsList = append(dnsList, s)
 			}
-			return &tpl, nil
 		}
 	}
-	return nil, fmt.Errorf("Unable to find template %s", id)
 
+	return AuthenticatedClient(
+		AuthOptions{
+			AccessKeyID:      accessKeyID,
+			SecretAccessKey:  secretAccessKey,
+			Region:           region,
+			AvailabilityZone: zone,
+			Endpoint:         endpoint,
+			VPCName:          vpcName,
+			VPCCIDR:          vpcCIDR,
+		},
+		CfgOptions{
+			DNSList:                   dnsList,
+			AutoHostNetworkInterfaces: true,
+			ImageOwners:               imageOwners,
+			DefaultImage:              defaultImage,
+		},
+	)
 }
 
-func parseStorage(str string) float64 {
-	r, _ := regexp.Compile("([0-9]*) x ([0-9]*(\\.|,)?[0-9]*) ?([a-z A-Z]*)?")
-	b := bytes.Buffer{}
-	b.WriteString(str)
-	tokens := r.FindAllStringSubmatch(str, -1)
-	if len(tokens) <= 0 || len(tokens[0]) <= 1 {
-		return 0.0
-	}
-	factor, err := strconv.ParseFloat(tokens[0][1], 64)
+// selectAvailabilityZone returns the first available zone of the region, in alphabetical order
+func (c *Client) selectAvailabilityZone() (string, error) {
+	zones, err := c.ListAvailabilityZones(false)
 	if err != nil {
-		return 0.0
+		return "", err
 	}
-	sizeStr := strings.Replace(tokens[0][2], ",", "", -1)
-	size, err := strconv.ParseFloat(sizeStr, 64)
-	if err != nil {
-		return 0.0
+	var names []string
+	for z := range zones {
+		names = append(names, z)
 	}
-	if size < 10 {
-		size = size * 1000
+	if len(names) == 0 {
+		return "", fmt.Errorf("no availability zone available in region '%s'", c.Opts.Region)
 	}
-	//	fmt.Println((factor * size))
-	return factor * size
+	sort.Strings(names)
+	return names[0], nil
 }
 
-func parseMemory(str string) float64 {
-	r, err := regexp.Compile("([0-9]*(\\.|,)?[0-9]*) ?([a-z A-Z]*)?")
+// initVPC initializes the VPC if it doesn't exist
+func (c *Client) initVPC() error {
+	// Tries to get VPC information
+	out, err := c.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{filter("tag:"+nameTag, c.Opts.VPCName)},
+	})
 	if err != nil {
-		return 0.0
+		return wrapError(fmt.Sprintf("Failed to initialize VPC '%s'", c.Opts.VPCName), err)
 	}
-	b := bytes.Buffer{}
-	b.WriteString(str)
-	tokens := r.FindAllStringSubmatch(str, -1)
-	sizeStr := strings.Replace(tokens[0][1], ",", "", -1)
-	size, err := strconv.ParseFloat(sizeStr, 64)
-	if err != nil {
-		return 0.0
+	if len(out.Vpcs) > 1 {
+		return fmt.Errorf("Configuration error: multiple VPCs named '%s' exist", c.Opts.VPCName)
 	}
-
-	//	fmt.Println((factor * size))
-	return size
-}
-
-// ListTemplates lists available host templates
-// Host templates are sorted using Dominant Resource Fairness Algorithm
-func (c *Client) ListTemplates(all bool) ([]model.HostTemplate, error) {
-	input := pricing.GetProductsInput{
-		Filters: []*pricing.Filter{
-			{
-				Field: aws.String("ServiceCode"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("AmazonEC2"),
-			},
-			{
-				Field: aws.String("location"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("US East (Ohio)"),
-			},
-			{
-				Field: aws.String("preInstalledSw"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("NA"),
-			},
-			{
-				Field: aws.String("operatingSystem"),
-				Type:  aws.String("TERM_MATCH"),
-				Value: aws.String("Linux"),
-			},
-		},
-		FormatVersion: aws.String("aws_v1"),
-		MaxResults:    aws.Int64(100),
-		ServiceCode:   aws.String("AmazonEC2"),
+	if len(out.Vpcs) == 1 {
+		c.VPCID = pStr(out.Vpcs[0].VpcId)
+		return nil
 	}
-	tpls := []model.HostTemplate{}
-	//prices := map[string]interface{}{}
-	err := c.Pricing.GetProductsPages(&input,
-		func(p *pricing.GetProductsOutput, lastPage bool) bool {
 
-			for _, price := range p.PriceList {
-				jsonPrice, err := json.Marshal(price)
-				if err != nil {
-					continue
-				}
-				price := Price{}
-				err = json.Unmarshal(jsonPrice, &price)
-				if err != nil {
-					continue
-				}
-				if strings.Contains(price.Product.Attributes.Usagetype, "USE2-BoxUsage:") {
-					cores, err := strconv.Atoi(price.Product.Attributes.Vcpu)
-					if err != nil {
-						continue
-					}
-
-					tpl := model.HostTemplate{
-						HostTemplate: &propsv1.HostTemplate{
-							ID:   price.Product.Attributes.InstanceType,
-							Name: price.Product.Attributes.InstanceType,
-							HostSize: &propsv1.HostSize{
-								Cores:    cores,
-								DiskSize: int(parseStorage(price.Product.Attributes.Storage)),
-								RAMSize:  float32(parseMemory(price.Product.Attributes.Memory)),
-							},
-						},
-					}
-					tpls = append(tpls, tpl)
-				}
-			}
-			return lastPage
-		})
+	vpc, err := c.createVPC(c.Opts.VPCName, c.Opts.VPCCIDR)
 	if err != nil {
-		return nil, err
+		return wrapError(fmt.Sprintf("Failed to initialize VPC '%s'", c.Opts.VPCName), err)
 	}
-	return tpls, nil
+	c.VPCID = vpc
+	return nil
 }
 
-// CreateKeyPair creates and import a key pair
-func (c *Client) CreateKeyPair(name string) (*model.KeyPair, error) {
-	publicKey, privateKey, err := system.CreateKeyPair()
-	if err != nil {
-		return nil, err
-	}
-	c.EC2.ImportKeyPair(&ec2.ImportKeyPairInput{
-		KeyName:           aws.String(name),
-		PublicKeyMaterial: publicKey,
+// createVPC creates the VPC named name, with an internet gateway as default route, and returns its ID
+func (c *Client) createVPC(name string, cidr string) (id string, err error) {
+	out, err := c.EC2.CreateVpc(&ec2.CreateVpcInput{
+		CidrBlock: aws.String(cidr),
 	})
-	// out, err := c.EC2.CreateKeyPair(&ec2.CreateKeyPairInput{
-	// 	KeyName: aws.String(name),
-	// })
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return &model.KeyPair{
-		ID:         name,
-		Name:       name,
-		PrivateKey: string(privateKey),
-		PublicKey:  string(publicKey),
-	}, nil
-}
+	id = pStr(out.Vpc.VpcId)
 
-func pStr(s *string) string {
-	if s == nil {
-		var s string
-		return s
-	}
-	return *s
-}
+	// Starting from here, delete VPC if exiting with error
+	defer func() {
+		if err != nil {
+			_, derr := c.EC2.DeleteVpc(&ec2.DeleteVpcInput{VpcId: aws.String(id)})
+			if derr != nil {
+				log.Errorf("Failed to delete VPC '%s': %v", name, derr)
+			}
+		}
+	}()
 
-// GetKeyPair returns the key pair identified by id
-func (c *Client) GetKeyPair(id string) (*model.KeyPair, error) {
-	out, err := c.EC2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
-		KeyNames: []*string{aws.String(id)},
-	})
+	err = c.setTags(id, map[string]string{nameTag: name})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	kp := out.KeyPairs[0]
-	return &model.KeyPair{
-		ID:         pStr(kp.KeyName),
-		Name:       pStr(kp.KeyName),
-		PrivateKey: "",
-		PublicKey:  pStr(kp.KeyFingerprint),
-	}, nil
-}
 
-// ListKeyPairs lists available key pairs
-func (c *Client) ListKeyPairs() ([]model.KeyPair, error) {
-	out, err := c.EC2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{})
+	gw, err := c.EC2.CreateInternetGateway(&ec2.CreateInternetGatewayInput{})
 	if err != nil {
-		return nil, err
-	}
-	keys := []model.KeyPair{}
-	for _, kp := range out.KeyPairs {
-		keys = append(keys, model.KeyPair{
-			ID:         pStr(kp.KeyName),
-			Name:       pStr(kp.KeyName),
-			PrivateKey: "",
-			PublicKey:  pStr(kp.KeyFingerprint),
-		})
-
+		return "", err
 	}
-	return keys, nil
-}
+	gwID := gw.InternetGateway.InternetGatewayId
 
-// DeleteKeyPair deletes the key pair identified by id
-func (c *Client) DeleteKeyPair(id string) error {
-	_, err := c.EC2.DeleteKeyPair(&ec2.DeleteKeyPairInput{
-		KeyName: aws.String(id),
-	})
-	return err
-}
+	// Starting from here, delete internet gateway if exiting with error
+	defer func() {
+		if err != nil {
+			_, derr := c.EC2.DeleteInternetGateway(&ec2.DeleteInternetGatewayInput{InternetGatewayId: gwID})
+			if derr != nil {
+				log.Errorf("Failed to delete internet gateway of VPC '%s': %v", name, derr)
+			}
+		}
+	}()
 
-func (c *Client) saveNetwork(n model.Network) error {
-	b, err := json.Marshal(n)
-	if err != nil {
-		return err
-	}
-	buffer := bytes.NewReader(b)
-	return c.PutObject("gpac.aws.networks", model.Object{
-		Name:    n.ID,
-		Content: buffer,
+	_, err = c.EC2.AttachInternetGateway(&ec2.AttachInternetGatewayInput{
+		VpcId:             aws.String(id),
+		InternetGatewayId: gwID,
 	})
-}
-
-func (c *Client) getNetwork(netID string) (*model.Network, error) {
-	o, err := c.GetObject("gpac.aws.networks", netID, nil)
 	if err != nil {
-		return nil, err
-	}
-	var buffer bytes.Buffer
-	buffer.ReadFrom(o.Content)
-	net := model.Network{}
-	err = json.Unmarshal(buffer.Bytes(), &net)
-	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return &net, err
-}
-func (c *Client) removeNetwork(netID string) error {
-	return c.DeleteObject("gpac.aws.networks", netID)
-}
 
-// CreateNetwork creates a network
-func (c *Client) CreateNetwork(req model.NetworkRequest) (*model.Network, error) {
-	// m, err := metadata.LoadNetwork(c, req.Name)
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// if m != nil {
-	// 	return nil, fmt.Errorf("A network already exists with name '%s'", req.Name)
-	// }
+	// Starting from here, detach internet gateway if exiting with error
+	defer func() {
+		if err != nil {
+			_, derr := c.EC2.DetachInternetGateway(&ec2.DetachInternetGatewayInput{
+				VpcId:             aws.String(id),
+				InternetGatewayId: gwID,
+			})
+			if derr != nil {
+				log.Errorf("Failed to detach internet gateway of VPC '%s': %v", name, derr)
+			}
+		}
+	}()
 
-	vpcOut, err := c.EC2.CreateVpc(&ec2.CreateVpcInput{
-		CidrBlock: aws.String(req.CIDR),
-	})
-	if err != nil {
-		return nil, err
-	}
-	sn, err := c.EC2.CreateSubnet(&ec2.CreateSubnetInput{
-		CidrBlock: aws.String(req.CIDR),
-		VpcId:     vpcOut.Vpc.VpcId,
+	tables, err := c.EC2.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			filter("vpc-id", id),
+			filter("association.main", "true"),
+		},
 	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		return "", err
 	}
-	gw, err := c.EC2.CreateInternetGateway(&ec2.CreateInternetGatewayInput{})
-	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+	if len(tables.RouteTables) == 0 {
+		err = fmt.Errorf("no route table found")
+		return "", err
 	}
-	_, err = c.EC2.AttachInternetGateway(&ec2.AttachInternetGatewayInput{
-		VpcId:             vpcOut.Vpc.VpcId,
-		InternetGatewayId: gw.InternetGateway.InternetGatewayId,
+	_, err = c.EC2.CreateRoute(&ec2.CreateRouteInput{
+		DestinationCidrBlock: aws.String("0.0.0.0/0"),
+		GatewayId:            gwID,
+		RouteTableId:         tables.RouteTables[0].RouteTableId,
 	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		return "", err
 	}
-	table, err := c.EC2.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+	return id, nil
+}
+
+// initDefaultSecurityGroup create an open Security Group
+// The default security group opens all TCP, UDP, ICMP ports
+// Security is managed individually on each host using a linux firewall
+func (c *Client) initDefaultSecurityGroup() error {
+	name := "sg-" + c.Opts.VPCName
+
+	out, err := c.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
 		Filters: []*ec2.Filter{
-			&ec2.Filter{
-				Name: aws.String("vpc-id"),
-				Values: []*string{
-					vpcOut.Vpc.VpcId,
-				},
-			},
+			c.vpcFilter(),
+			filter("group-name", name),
 		},
 	})
-	if err != nil || len(table.RouteTables) < 1 {
-		return nil, err
-	}
-
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		return wrapError("Error listing security groups", err)
 	}
-	_, err = c.EC2.CreateRoute(&ec2.CreateRouteInput{
-		DestinationCidrBlock: aws.String("0.0.0.0/0"),
-		GatewayId:            gw.InternetGateway.InternetGatewayId,
-		RouteTableId:         table.RouteTables[0].RouteTableId,
+	if len(out.SecurityGroups) > 0 {
+		c.SecurityGroupID = pStr(out.SecurityGroups[0].GroupId)
+		return nil
+	}
+
+	group, err := c.EC2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(name),
+		Description: aws.String("Default security group for VPC " + c.Opts.VPCName),
+		VpcId:       aws.String(c.VPCID),
 	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		return wrapError(fmt.Sprintf("Failed to create Security Group '%s'", name), err)
 	}
-	_, err = c.EC2.AssociateRouteTable(&ec2.AssociateRouteTableInput{
-		RouteTableId: table.RouteTables[0].RouteTableId,
-		SubnetId:     sn.Subnet.SubnetId,
+	ranges := []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}
+	ipv6Ranges := []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0")}}
+	_, err = c.EC2.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId: group.GroupId,
+		IpPermissions: []*ec2.IpPermission{
+			{
+				IpProtocol: aws.String("tcp"),
+				FromPort:   aws.Int64(1),
+				ToPort:     aws.Int64(65535),
+				IpRanges:   ranges,
+				Ipv6Ranges: ipv6Ranges,
+			},
+			{
+				IpProtocol: aws.String("udp"),
+				FromPort:   aws.Int64(1),
+				ToPort:     aws.Int64(65535),
+				IpRanges:   ranges,
+				Ipv6Ranges: ipv6Ranges,
+			},
+			{
+				IpProtocol: aws.String("icmp"),
+				FromPort:   aws.Int64(-1),
+				ToPort:     aws.Int64(-1),
+				IpRanges:   ranges,
+				Ipv6Ranges: ipv6Ranges,
+			},
+		},
 	})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
+		// Error occured...
+		_, derr := c.EC2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{GroupId: group.GroupId})
+		if derr != nil {
+			log.Errorf("Failed to delete Security Group '%s': %v", name, derr)
+		}
+		return wrapError(fmt.Sprintf("Failed to create Security Group '%s'", name), err)
 	}
+	// The egress traffic is allowed by default in a VPC
+	c.SecurityGroupID = pStr(group.GroupId)
+	return nil
+}
 
-	req.GWRequest.PublicIP = true
-	req.GWRequest.IsGateway = true
-	req.GWRequest.NetworkIDs = append(req.GWRequest.NetworkIDs, *vpcOut.Vpc.VpcId)
-	host, err := c.CreateHost(req.GWRequest)
+// ListAvailabilityZones lists the usable AvailabilityZones
+func (c *Client) ListAvailabilityZones(all bool) (map[string]bool, error) {
+	out, err := c.EC2.DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesInput{})
 	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, wrapError("Error creating network", err)
+		return nil, wrapError("Error listing availability zones", err)
 	}
-	net := model.Network{
-		CIDR:      pStr(vpcOut.Vpc.CidrBlock),
-		ID:        pStr(vpcOut.Vpc.VpcId),
-		Name:      req.Name,
-		IPVersion: req.IPVersion,
-		GatewayID: host.ID,
+	zones := map[string]bool{}
+	for _, z := range out.AvailabilityZones {
+		available := pStr(z.State) == ec2.AvailabilityZoneStateAvailable
+		if all || available {
+			zones[pStr(z.ZoneName)] = available
+		}
 	}
-	err = c.saveNetwork(net)
-	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, err
-	}
-	return &net, nil
-}
-
-// GetNetwork returns the network identified by id
-func (c *Client) GetNetwork(id string) (*model.Network, error) {
-	net, err := c.getNetwork(id)
-	if err != nil {
-		return nil, err
-	}
-	out, err := c.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{
-		VpcIds: []*string{aws.String(id)},
-	})
-	if err != nil {
-		return nil, err
-	}
-	net.CIDR = *out.Vpcs[0].CidrBlock
-	net.ID = *out.Vpcs[0].VpcId
-	return net, nil
-}
-
-// ListNetworks lists available networks
-func (c *Client) ListNetworks() ([]model.Network, error) {
-	out, err := c.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{})
-	if err != nil {
-		return nil, err
-	}
-	nets := []model.Network{}
-	for _, vpc := range out.Vpcs {
-		net, err := c.getNetwork(*vpc.VpcId)
-		if err != nil {
-			return nil, err
-		}
-		net.CIDR = *vpc.CidrBlock
-		net.CIDR = *vpc.VpcId
-		nets = append(nets, *net)
-	}
-	return nets, nil
-
-}
-
-// DeleteNetwork deletes the network identified by id
-func (c *Client) DeleteNetwork(id string) error {
-	net, err := c.getNetwork(id)
-	if err == nil {
-		c.DeleteHost(net.GatewayID)
-		addrs, _ := c.EC2.DescribeAddresses(&ec2.DescribeAddressesInput{
-			Filters: []*ec2.Filter{
-				{
-					Name: aws.String("domain"),
-					Values: []*string{
-						aws.String("vpc"),
-					},
-				},
-				{
-					Name: aws.String("instance-id"),
-					Values: []*string{
-						aws.String(net.GatewayID),
-					},
-				},
-			},
-		})
-		for _, addr := range addrs.Addresses {
-			c.EC2.DisassociateAddress(&ec2.DisassociateAddressInput{
-				AssociationId: addr.AssociationId,
-			})
-			c.EC2.ReleaseAddress(&ec2.ReleaseAddressInput{
-				AllocationId: addr.AllocationId,
-			})
-		}
-	}
-
-	_, err = c.EC2.DeleteVpc(&ec2.DeleteVpcInput{
-		VpcId: aws.String(id),
-	})
-	return err
-}
-
-// CreateGateway exists only to comply with api.ClientAPI interface
-func (c *Client) CreateGateway(req model.GWRequest) (*model.Host, error) {
-	return nil, fmt.Errorf("aws.CreateGateway() isn't available by design")
-}
-
-// DeleteGateway exists only to comply with api.ClientAPI interface
-func (c *Client) DeleteGateway(networkID string) error {
-	return fmt.Errorf("aws.DeleteGateway() isn't available by design")
-}
-
-func (c *Client) getSubnets(vpcIDs []string) ([]*ec2.Subnet, error) {
-	filters := []*ec2.Filter{}
-	for _, id := range vpcIDs {
-		filters = append(filters, &ec2.Filter{
-			Name:   aws.String("vpc-id"),
-			Values: []*string{&id},
-		})
-	}
-	out, err := c.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
-		Filters: filters,
-	})
-	if err != nil {
-		return nil, err
-	}
-	return out.Subnets, nil
-
-}
-func getState(state *ec2.InstanceState) (HostState.Enum, error) {
-	// The low byte represents the state. The high byte is an opaque internal value
-	// and should be ignored.
-	//
-	//    * 0 : pending
-	//
-	//    * 16 : running
-	//
-	//    * 32 : shutting-down
-	//
-	//    * 48 : terminated
-	//
-	//    * 64 : stopping
-	//
-	//    * 80 : stopped
-	fmt.Println("State", state.Code)
-	if state == nil {
-		return HostState.ERROR, fmt.Errorf("unexpected host state")
-	}
-	if *state.Code == 0 {
-		return HostState.STARTING, nil
-	}
-	if *state.Code == 16 {
-		return HostState.STARTED, nil
-	}
-	if *state.Code == 32 {
-		return HostState.STOPPING, nil
-	}
-	if *state.Code == 48 {
-		return HostState.STOPPED, nil
-	}
-	if *state.Code == 64 {
-		return HostState.STOPPING, nil
-	}
-	if *state.Code == 80 {
-		return HostState.STOPPED, nil
-	}
-	return HostState.ERROR, fmt.Errorf("unexpected host state")
-}
-
-// Data structure to apply to userdata.sh template
-type userData struct {
-	//Name of the default user (api.DefaultUser)
-	User string
-	//Private key used to create the host
-	Key string
-	//If true activate IP frowarding
-	IsGateway bool
-	//If true configure default gateway
-	AddGateway bool
-	//Content of the /etc/resolv.conf of the Gateway
-	//Used only if IsGateway is true
-	ResolvConf string
-	//IP of the gateway
-	GatewayIP string
-}
-
-func (c *Client) prepareUserData(request model.HostRequest, kp *model.KeyPair, gw *model.Host) (string, error) {
-	dataBuffer := bytes.NewBufferString("")
-	var ResolvConf string
-	var err error
-	// if !request.PublicIP {
-	// 	var buffer bytes.Buffer
-	// 	for _, dns := range client.Cfg.DNSList {
-	// 		buffer.WriteString(fmt.Sprintf("nameserver %s\n", dns))
-	// 	}
-	// 	ResoleConf = buffer.String()
-	// }
-	ip := ""
-	if gw != nil {
-		hpNetworkV1 := propsv1.NewHostNetwork()
-		err := gw.Properties.Get(HostProperty.NetworkV1, hpNetworkV1)
-		if err != nil {
-			return "", err
-		}
-		ip = hpNetworkV1.IPv4Addresses[hpNetworkV1.DefaultNetworkID]
-		if ip == "" {
-			ip = hpNetworkV1.IPv6Addresses[hpNetworkV1.DefaultNetworkID]
-		}
-	}
-	data := userData{
-		User:       model.DefaultUser,
-		Key:        strings.Trim(kp.PublicKey, "\n"),
-		IsGateway:  request.IsGateway,
-		AddGateway: !request.PublicIP,
-		ResolvConf: ResolvConf,
-		GatewayIP:  ip,
-	}
-	err = c.UserDataTpl.Execute(dataBuffer, data)
-	if err != nil {
-		return "", err
-	}
-	encBuffer := bytes.Buffer{}
-	enc := base64.NewEncoder(base64.StdEncoding, &encBuffer)
-	enc.Write(dataBuffer.Bytes())
-	return encBuffer.String(), nil
-}
-
-func (c *Client) saveHost(host model.Host) error {
-	var buffer bytes.Buffer
-	enc := gob.NewEncoder(&buffer)
-	err := enc.Encode(host)
-	if err != nil {
-		return err
-	}
-	return c.PutObject("gpac.aws.wms", model.Object{
-		Name:    host.ID,
-		Content: bytes.NewReader(buffer.Bytes()),
-	})
-}
-func (c *Client) removeHost(hostID string) error {
-	return c.DeleteObject("gpac.aws.wms", hostID)
-}
-func (c *Client) readHost(hostID string) (*model.Host, error) {
-	o, err := c.GetObject("gpac.aws.wms", hostID, nil)
-	if err != nil {
-		return nil, err
-	}
-	var buffer bytes.Buffer
-	buffer.ReadFrom(o.Content)
-	enc := gob.NewDecoder(&buffer)
-	var host model.Host
-	err = enc.Decode(&host)
-	if err != nil {
-		return nil, err
-	}
-	return &host, nil
+	return zones, nil
 }
 
-func (c *Client) createSecurityGroup(vpcID string, name string) (string, error) {
-	out, err := c.EC2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
-		GroupName: aws.String(name),
-		VpcId:     aws.String(vpcID),
-	})
-	if err != nil {
-		return "", err
-	}
-	_, err = c.EC2.AuthorizeSecurityGroupEgress(&ec2.AuthorizeSecurityGroupEgressInput{
-		IpPermissions: []*ec2.IpPermission{
-			&ec2.IpPermission{
-				IpProtocol: aws.String("-1"),
-			},
-		},
-	})
-	if err != nil {
-		return "", err
-	}
-
-	_, err = c.EC2.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
-		IpPermissions: []*ec2.IpPermission{
-			&ec2.IpPermission{
-				IpProtocol: aws.String("-1"),
-			},
-		},
-	})
-	if err != nil {
-		return "", err
-	}
-	return *out.GroupId, nil
-}
-
-// CreateHost creates an host that fulfils the request
-func (c *Client) CreateHost(request model.HostRequest) (*model.Host, error) {
-	// If no KeyPair is supplied a temporay one is created
-	kp := request.KeyPair
-	if kp == nil {
-		kpTmp, err := c.CreateKeyPair(request.ResourceName)
-		if err != nil {
-			return nil, err
-		}
-		kp = kpTmp
-	}
-	// If the host is not a Gateway, get gateway of the first network
-	gwID := ""
-	var gw *model.Host
-	if !request.isGateway {
-		net, err := c.getNetwork(request.NetworkIDs[0])
-		if err != nil {
-			return nil, err
-		}
-		gwID = net.GatewayID
-		gw, err = c.GetHost(gwID)
-		if err != nil {
-			return nil, err
-		}
-
-	}
-	//get subnet of each network
-	sns, err := c.getSubnets(request.NetworkIDs)
-
-	//Prepare user data
-	userData, err := c.prepareUserData(request, kp, gw)
-	if err != nil {
-		return nil, err
-	}
-
-	//Create networks interfaces
-	networkInterfaces := []*ec2.InstanceNetworkInterfaceSpecification{}
-
-	vpcs := map[string][]*ec2.Subnet{}
-	for _, sn := range sns {
-		vpcs[*sn.VpcId] = append(vpcs[*sn.VpcId], sn)
-
-	}
-
-	i := 0
-	for _, netID := range request.NetworkIDs {
-		if len(vpcs[netID]) < 1 {
-			continue
-		}
-		sn := vpcs[netID][0]
-		networkInterfaces = append(networkInterfaces, &ec2.InstanceNetworkInterfaceSpecification{
-			SubnetId:                 sn.SubnetId,
-			AssociatePublicIpAddress: aws.Bool(false),
-			DeleteOnTermination:      aws.Bool(true),
-			DeviceIndex:              aws.Int64(int64(i)),
-		})
-		i++
-	}
-
-	//Run instance
-	out, err := c.EC2.RunInstances(&ec2.RunInstancesInput{
-		ImageId:           aws.String(request.ImageID),
-		KeyName:           aws.String(kp.Name),
-		InstanceType:      aws.String(request.TemplateID),
-		NetworkInterfaces: networkInterfaces,
-		MaxCount:          aws.Int64(1),
-		MinCount:          aws.Int64(1),
-		UserData:          aws.String(userData),
-		// TagSpecifications: []*ec2.TagSpecification{
-		// 	{
-		// 		Tags: []*ec2.Tag{
-		// 			{
-		// 				Key:   aws.String("Name"),
-		// 				Value: aws.String(request.Name),
-		// 			},
-		// 		},
-		// 	},
-		// },
-	})
-	if err != nil {
-		return nil, err
-	}
-	instance := out.Instances[0]
-
-	netIFs, err := c.EC2.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
-		Filters: []*ec2.Filter{
-			&ec2.Filter{
-				Name:   aws.String("attachment.instance-id"),
-				Values: []*string{instance.InstanceId},
-			},
-			&ec2.Filter{
-				Name:   aws.String("attachment.device-index"),
-				Values: []*string{aws.String(fmt.Sprintf("%d", 0))},
-			},
-		},
-	})
-	if err != nil {
-		c.DeleteHost(*instance.InstanceId)
-		return nil, err
-	}
-
-	addr, err := c.EC2.AllocateAddress(&ec2.AllocateAddressInput{
-		Domain: aws.String("vpc"),
-	})
-	if err != nil {
-		c.DeleteHost(*instance.InstanceId)
-		return nil, err
-	}
-	//Wait that host is started
-	service := providers.Service{
-		ClientAPI: c,
-	}
-	err = service.WaitHostState(*instance.InstanceId, HostState.STARTED, 120*time.Second)
-	if err != nil {
-		return nil, err
-	}
-	_, err = c.EC2.AssociateAddress(&ec2.AssociateAddressInput{
-		NetworkInterfaceId: netIFs.NetworkInterfaces[0].NetworkInterfaceId,
-		AllocationId:       addr.AllocationId,
-	})
-	if err != nil {
-		c.DeleteHost(*instance.InstanceId)
-		return nil, err
-	}
-	//Create api.Host
-
-	tpl, err := c.GetTemplate(*instance.InstanceType)
-	if err != nil {
-		c.DeleteHost(*instance.InstanceId)
-		return nil, err
-	}
-	v4IPs := []string{}
-	for _, nif := range instance.NetworkInterfaces {
-		v4IPs = append(v4IPs, *nif.PrivateIpAddress)
-	}
-	accessAddr := ""
-	if instance.PublicIpAddress != nil {
-		accessAddr = *instance.PublicIpAddress
-	}
-	state, err := getState(instance.State)
-	if err != nil {
-		c.DeleteHost(*instance.InstanceId)
-		return nil, err
-	}
-
-	host := api.Host{
-		ID:           pStr(instance.InstanceId),
-		Name:         request.Name,
-		Size:         tpl.HostSize,
-		PrivateIPsV4: v4IPs,
-		AccessIPv4:   accessAddr,
-		PrivateKey:   kp.PrivateKey,
-		State:        state,
-		GatewayID:    gwID,
-	}
-	c.saveHost(host)
-	return &host, nil
-}
-
-// GetHost returns the host identified by id
-func (c *Client) GetHost(hostParam interface{}) (*model.Host, error) {
-	var (
-		host *model.Host
-	)
-
-	switch hostParam.(type) {
-	case string:
-		host := model.NewHost()
-		host.ID = hostParam.(string)
-	case *model.Host:
-		host = hostParam.(*model.Host)
-	default:
-		panic("hostParam must be a string or a *model.Host!")
-	}
-
-	out, err := c.EC2.DescribeInstances(&ec2.DescribeInstancesInput{
-		InstanceIds: []*string{aws.String(id)},
-	})
-	if err != nil {
-		return nil, err
-	}
-	instance := out.Reservations[0].Instances[0]
-	host, err := c.readHost(id)
-	if err != nil {
-		host = &api.Host{
-			ID: *instance.InstanceId,
-		}
-	}
-
-	host.State, err = getState(instance.State)
-	if err != nil {
-		return nil, err
-	}
-	tpl, err := c.GetTemplate(*instance.InstanceType)
-	if err != nil {
-		return nil, err
-	}
-	host.Size = tpl.HostSize
-	v4IPs := []string{}
-	for _, nif := range instance.NetworkInterfaces {
-		v4IPs = append(v4IPs, *nif.PrivateIpAddress)
-	}
-	accessAddr := ""
-	if instance.PublicIpAddress != nil {
-		accessAddr = *instance.PublicIpAddress
-	}
-	host.PrivateIPsV4 = v4IPs
-	host.AccessIPv4 = accessAddr
-
-	return host, nil
-}
-
-// ListHosts lists available hosts
-func (c *Client) ListHosts() ([]model.Host, error) {
-	panic("Not Implemented")
-}
-
-// DeleteHost deletes the host identified by id
-func (c *Client) DeleteHost(id string) error {
-	c.removeHost(id)
-	ips, err := c.EC2.DescribeAddresses(&ec2.DescribeAddressesInput{
-		Filters: []*ec2.Filter{
-			&ec2.Filter{
-				Name:   aws.String("instance-id"),
-				Values: []*string{aws.String(id)},
-			},
-		},
-	})
-	if err != nil {
-		for _, ip := range ips.Addresses {
-			c.EC2.ReleaseAddress(&ec2.ReleaseAddressInput{
-				AllocationId: ip.AllocationId,
-			})
-		}
-	}
-	_, err = c.EC2.TerminateInstances(&ec2.TerminateInstancesInput{
-		InstanceIds: []*string{aws.String(id)},
-	})
-	return err
-
-}
-
-// StopHost stops the host identified by id
-func (c *Client) StopHost(id string) error {
-	_, err := c.EC2.StopInstances(&ec2.StopInstancesInput{
-		Force:       aws.Bool(true),
-		InstanceIds: []*string{aws.String(id)},
-	})
-	return err
-}
-
-// StartHost starts the host identified by id
-func (c *Client) StartHost(id string) error {
-	_, err := c.EC2.StartInstances(&ec2.StartInstancesInput{
-		InstanceIds: []*string{aws.String(id)},
-	})
-	return err
-}
-
-// //GetSSHConfig creates SSHConfig from host
-// func (c *Client) GetSSHConfig(param interface{}) (*system.SSHConfig, error) {
-// 	var host *model.Host
-// 	switch param.(type) {
-// 	case string:
-// 		mh, err := metadata.LoadHost(c, param.(string))
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		host := mh.Get()
-// 	case *model.Host:
-// 		host := param.(*model.Host)
-// 	}
-
-// 	ip := host.GetAccessIP()
-// 	sshConfig := system.SSHConfig{
-// 		PrivateKey: host.PrivateKey,
-// 		Port:       22,
-// 		Host:       ip,
-// 		User:       api.DefaultUser,
-// 	}
-// 	if host.GatewayID != "" {
-// 		gw, err := c.GetHost(host.GatewayID)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		ip := gw.GetAccessIP()
-// 		GatewayConfig := system.SSHConfig{
-// 			PrivateKey: gw.PrivateKey,
-// 			Port:       22,
-// 			User:       api.DefaultUser,
-// 			Host:       ip,
-// 		}
-// 		sshConfig.GatewayConfig = &GatewayConfig
-// 	}
-
-// 	return &sshConfig, nil
-// }
-
-func toVolumeType(speed VolumeSpeed.Enum) string {
-	switch speed {
-	case VolumeSpeed.COLD:
-		return "sc1"
-	case VolumeSpeed.HDD:
-		return "st1"
-	case VolumeSpeed.SSD:
-		return "gp2"
-	}
-	return "st1"
-}
-
-func toVolumeSpeed(t *string) VolumeSpeed.Enum {
-	if t == nil {
-		return VolumeSpeed.HDD
-	}
-	if *t == "sc1" {
-		return VolumeSpeed.COLD
-	}
-	if *t == "st1" {
-		return VolumeSpeed.HDD
-	}
-	if *t == "gp2" {
-		return VolumeSpeed.SSD
-	}
-	return VolumeSpeed.HDD
-}
-
-func toVolumeState(s *string) VolumeState.Enum {
-	// VolumeStateCreating = "creating"
-	// VolumeStateAvailable = "available"
-	// VolumeStateInUse = "in-use"
-	// VolumeStateDeleting = "deleting"
-	// VolumeStateDeleted = "deleted"
-	// VolumeStateError = "error"
-	if s == nil {
-		return VolumeState.ERROR
-	}
-	if *s == "creating" {
-		return VolumeState.CREATING
-	}
-	if *s == "available" {
-		return VolumeState.AVAILABLE
-	}
-	if *s == "in-use" {
-		return VolumeState.USED
-	}
-	if *s == "deleting" {
-		return VolumeState.DELETING
-	}
-	if *s == "deleted" {
-		return VolumeState.DELETING
-	}
-	if *s == "error" {
-		return VolumeState.ERROR
-	}
-	return VolumeState.OTHER
-}
-
-func (c *Client) saveVolumeName(id, name string) error {
-	return c.PutObject("gpac.aws.volumes", api.Object{
-		Name:    id,
-		Content: strings.NewReader(name),
-	})
-}
-
-func (c *Client) getVolumeName(id string) (string, error) {
-	obj, err := c.GetObject("gpac.aws.volumes", id, nil)
-	if err != nil {
-		return "", err
-	}
-	buffer := bytes.Buffer{}
-	buffer.ReadFrom(obj.Content)
-	return buffer.String(), nil
-}
-
-func (c *Client) removeVolumeName(id string) error {
-	return c.DeleteObject("gpac.aws.volumes", id)
-}
-
-// CreateVolume creates a block volume
-//- name is the name of the volume
-//- size is the size of the volume in GB
-//- volumeType is the type of volume to create, if volumeType is empty the driver use a default type
-func (c *Client) CreateVolume(request model.VolumeRequest) (*model.Volume, error) {
-	// Check if a volume already exists with the same name
-	_volume, err := metadata.LoadVolume(client, request.Name)
-	if err != nil {
-		return nil, err
-	}
-	if _volume != nil {
-		return nil, fmt.Errorf("Volume '%s' already exists", request.Name)
-	}
-
-	v, err := c.EC2.CreateVolume(&ec2.CreateVolumeInput{
-		Size:       aws.Int64(int64(request.Size)),
-		VolumeType: aws.String(toVolumeType(request.Speed)),
-	})
-	if err != nil {
-		return nil, err
-	}
-	err = c.saveVolumeName(*v.VolumeId, request.Name)
-	if err != nil {
-		c.DeleteVolume(*v.VolumeId)
-	}
-	volume := api.Volume{
-		ID:    pStr(v.VolumeId),
-		Name:  request.Name,
-		Size:  int(pInt64(v.Size)),
-		Speed: toVolumeSpeed(v.VolumeType),
-		State: toVolumeState(v.State),
-	}
-	return &volume, nil
-}
-
-// GetVolume returns the volume identified by id
-func (c *Client) GetVolume(id string) (*model.Volume, error) {
-	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
-		VolumeIds: []*string{aws.String(id)},
-	})
-	if err != nil {
-		return nil, err
-	}
-	v := out.Volumes[0]
-	name, err := c.getVolumeName(id)
-	if err != nil {
-		return nil, err
-	}
-	volume := api.Volume{
-		ID:    pStr(v.VolumeId),
-		Name:  name,
-		Size:  int(pInt64(v.Size)),
-		Speed: toVolumeSpeed(v.VolumeType),
-		State: toVolumeState(v.State),
-	}
-	return &volume, nil
-}
-
-// ListVolumes list available volumes
-func (c *Client) ListVolumes() ([]model.Volume, error) {
-	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{})
-	if err != nil {
-		return nil, err
-	}
-	volumes := []api.Volume{}
-	for _, v := range out.Volumes {
-		name, err := c.getVolumeName(*v.VolumeId)
-		if err != nil {
-			return nil, err
-		}
-		volume := api.Volume{
-			ID:    pStr(v.VolumeId),
-			Name:  name,
-			Size:  int(pInt64(v.Size)),
-			Speed: toVolumeSpeed(v.VolumeType),
-			State: toVolumeState(v.State),
-		}
-		volumes = append(volumes, volume)
-	}
-
-	return volumes, nil
-}
-
-// DeleteVolume deletes the volume identified by id
-func (c *Client) DeleteVolume(id string) error {
-	_, err := c.EC2.DeleteVolume(&ec2.DeleteVolumeInput{
-		VolumeId: aws.String(id),
-	})
-	return err
-}
-
-// func (c *Client) saveVolumeAttachmentName(id, name string) error {
-// 	return c.PutObject("__volume_atachements__", api.Object{
-// 		Name:    id,
-// 		Content: strings.NewReader(name),
-// 	})
-// }
-
-// func (c *Client) getVolumeAttachmentName(id string) (string, error) {
-// 	obj, err := c.GetObject("__volume_atachements__", id, nil)
-// 	if err != nil {
-// 		return "", err
-// 	}
-// 	buffer := bytes.Buffer{}
-// 	buffer.ReadFrom(obj.Content)
-// 	return buffer.String(), nil
-// }
-
-// func (c *Client) removeVolumeAttachmentName(id string) error {
-// 	return c.DeleteObject("__volume_atachements__", id)
-// }
-
-// func vaID(hostID string, volumeID string) string {
-// 	return fmt.Sprintf("%s###%s", hostID, volumeID)
-// }
-
-// CreateVolumeAttachment attaches a volume to an host
-//- name the name of the volume attachment
-//- volume the volume to attach
-//- host on which the volume is attached
-func (c *Client) CreateVolumeAttachment(request model.VolumeAttachmentRequest) (*model.VolumeAttachment, error) {
-	va, err := c.EC2.AttachVolume(&ec2.AttachVolumeInput{
-		InstanceId: aws.String(request.ServerID),
-		VolumeId:   aws.String(request.VolumeID),
-	})
-	if err != nil {
-		return nil, err
-	}
-	return &api.VolumeAttachment{
-		Device:   pStr(va.Device),
-		ID:       pStr(va.VolumeId),
-		Name:     request.Name,
-		ServerID: pStr(va.InstanceId),
-		VolumeID: pStr(va.VolumeId),
-	}, nil
-}
-
-// GetVolumeAttachment returns the volume attachment identified by id
-func (c *Client) GetVolumeAttachment(serverID, id string) (*model.VolumeAttachment, error) {
-	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
-		VolumeIds: []*string{aws.String(id)},
-	})
-	if err != nil {
-		return nil, err
-	}
-	v := out.Volumes[0]
-	for _, va := range v.Attachments {
-		if *va.InstanceId == serverID {
-			return &api.VolumeAttachment{
-				Device:   pStr(va.Device),
-				ServerID: pStr(va.InstanceId),
-				VolumeID: pStr(va.VolumeId),
-			}, nil
-		}
-	}
-	return nil, fmt.Errorf("Volume attachment of volume %s on server %s does not exist", serverID, id)
-}
-
-// ListVolumeAttachments lists available volume attachment
-func (c *Client) ListVolumeAttachments(serverID string) ([]model.VolumeAttachment, error) {
-	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
-		Filters: []*ec2.Filter{
-			&ec2.Filter{
-				Name:   aws.String("attachment.instance-id"),
-				Values: []*string{aws.String(serverID)},
-			},
-		},
-	})
-	if err != nil {
-		return nil, err
-	}
-	vas := []api.VolumeAttachment{}
-	for _, v := range out.Volumes {
-		for _, va := range v.Attachments {
-			vas = append(vas, api.VolumeAttachment{
-				Device:   pStr(va.Device),
-				ServerID: pStr(va.InstanceId),
-				VolumeID: pStr(va.VolumeId),
-			})
-		}
-	}
-	return vas, nil
-
-}
-
-// DeleteVolumeAttachment deletes the volume attachment identifed by id
-func (c *Client) DeleteVolumeAttachment(serverID, id string) error {
-	_, err := c.EC2.DetachVolume(&ec2.DetachVolumeInput{
-		InstanceId: aws.String(serverID),
-		VolumeId:   aws.String(id),
-	})
-	return err
-}
-
-// CreateContainer creates an object container
-func (c *Client) CreateContainer(name string) error {
-	return s3.CreateContainer(awss3.New(c.Session), name)
-}
-
-// DeleteContainer deletes an object container
-func (c *Client) DeleteContainer(name string) error {
-	return s3.DeleteContainer(awss3.New(c.Session), name)
-}
-
-// ListContainers list object containers
-func (c *Client) ListContainers() ([]string, error) {
-	return s3.ListContainers(awss3.New(c.Session))
-}
-
-// PutObject put an object into an object container
-func (c *Client) PutObject(container string, obj model.Object) error {
-	return s3.PutObject(awss3.New(c.Session), container, obj)
-}
-
-// UpdateObjectMetadata update an object into  object container
-func (c *Client) UpdateObjectMetadata(container string, obj model.Object) error {
-	return s3.UpdateObjectMetadata(awss3.New(c.Session), container, obj)
-}
-
-// GetObject get  object content from an object container
-func (c *Client) GetObject(container string, name string, ranges []model.Range) (*model.Object, error) {
-	return s3.GetObject(awss3.New(c.Session), container, name, ranges)
-}
-
-// GetObjectMetadata get  object metadata from an object container
-func (c *Client) GetObjectMetadata(container string, name string) (*model.Object, error) {
-	return s3.GetObjectMetadata(awss3.New(c.Session), container, name)
-}
-
-// ListObjects list objects of a container
-func (c *Client) ListObjects(container string, filter model.ObjectFilter) ([]string, error) {
-	return s3.ListObjects(awss3.New(c.Session), container, filter)
-}
-
-// CopyObject copies an object
-func (c *Client) CopyObject(containerSrc, objectSrc, objectDst string) error {
-	return s3.CopyObject(awss3.New(c.Session), containerSrc, objectSrc, objectDst)
-}
-
-// DeleteObject deleta an object from a container
-func (c *Client) DeleteObject(container, object string) error {
-	return s3.DeleteObject(awss3.New(c.Session), container, object)
-}
-
-// GetAuthOpts
+// GetAuthOpts returns the auth options
 func (c *Client) GetAuthOpts() (model.Config, error) {
 	cfg := model.ConfigMap{}
+
+	cfg.Set("AccessKeyID", c.Opts.AccessKeyID)
+	cfg.Set("Region", c.Opts.Region)
+	cfg.Set("AvailabilityZone", c.Opts.AvailabilityZone)
+	cfg.Set("Endpoint", c.Opts.Endpoint)
+	cfg.Set("VPCName", c.Opts.VPCName)
+
 	return cfg, nil
 }
 
@@ -1666,9 +534,27 @@ func (c *Client) GetCfgOpts() (model.Config, error) {
 	cfg := model.ConfigMap{}
 
 	cfg.Set("DNSList", c.Cfg.DNSList)
-	cfg.Set("S3Protocol", c.Cfg.S3Protocol)
 	cfg.Set("AutoHostNetworkInterfaces", c.Cfg.AutoHostNetworkInterfaces)
-	cfg.Set("UseLayer3Networking", c.Cfg.UseLayer3Networking)
+	// The hosts without public IP reach the internet through the gateway of their network
+	cfg.Set("UseLayer3Networking", false)
+	cfg.Set("MetadataBucket", c.Cfg.MetadataBucket)
 
 	return cfg, nil
 }
+
+// GetCapabilities returns what the provider supports
+func (c *Client) GetCapabilities() model.Capabilities {
+	return model.Capabilities{
+		VolumeSpeeds:      []VolumeSpeed.Enum{VolumeSpeed.COLD, VolumeSpeed.HDD, VolumeSpeed.SSD},
+		GPU:               true,
+		FloatingIP:        FloatingIPModel.FLOATING,
+		MaxVolumesPerHost: lastDevice - firstDevice + 1,
+		PrivateNetworks:   true,
+		ObjectStorage:     "s3",
+	}
+}
+
+// init registers the aws provider
+func init() {
+	providers.Register("aws", &Client{})
+}
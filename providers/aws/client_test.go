@@ -0,0 +1,156 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/tests"
+)
+
+var tester *tests.ClientTester
+
+func getClient() (*tests.ClientTester, error) {
+	tenantName := "aws"
+	if tenantOverride := os.Getenv("TEST_AWS"); tenantOverride != "" {
+		tenantName = tenantOverride
+	}
+	if tester == nil {
+		service, err := providers.GetService(tenantName)
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, fmt.Errorf("You must provide a VALID tenant [%v], check your environment variables and your Safescale configuration files", tenantName)
+		}
+		tester = &tests.ClientTester{
+			Service: *service,
+		}
+	}
+	return tester, nil
+}
+
+func Test_ListImages(t *testing.T) {
+	tt, err := getClient()
+	require.Nil(t, err)
+	tt.ListImages(t)
+}
+
+func Test_ListHostTemplates(t *testing.T) {
+	tt, err := getClient()
+	require.Nil(t, err)
+	tt.ListHostTemplates(t)
+}
+
+func Test_CreateKeyPair(t *testing.T) {
+	tt, err := getClient()
+	require.Nil(t, err)
+	tt.CreateKeyPair(t)
+}
+
+// The networks of a tenant are subnets of the same VPC, so they can't share a CIDR as in ClientTester.Networks
+func Test_Networks(t *testing.T) {
+	tt, err := getClient()
+	require.Nil(t, err)
+
+	nets, err := tt.Service.ListNetworks()
+	require.Nil(t, err)
+	nbNetworks := len(nets)
+
+	network1, err := tt.Service.CreateNetwork(model.NetworkRequest{
+		Name:      "unit_test_network_1",
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.10.0/24",
+	})
+	require.Nil(t, err)
+	defer tt.Service.DeleteNetwork(network1.ID)
+	network2, err := tt.Service.CreateNetwork(model.NetworkRequest{
+		Name:      "unit_test_network_2",
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.11.0/24",
+	})
+	require.Nil(t, err)
+	defer tt.Service.DeleteNetwork(network2.ID)
+
+	_, err = tt.Service.CreateNetwork(model.NetworkRequest{
+		Name:      "unit_test_network_1",
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.12.0/24",
+	})
+	assert.Error(t, err)
+	_, err = tt.Service.CreateNetwork(model.NetworkRequest{
+		Name:      "unit_test_network_3",
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "10.0.0.0/24",
+	})
+	assert.Error(t, err)
+
+	nets, err = tt.Service.ListNetworks()
+	assert.Nil(t, err)
+	assert.Equal(t, nbNetworks+2, len(nets))
+
+	n1, err := tt.Service.GetNetwork(network1.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, network1.ID, n1.ID)
+	assert.Equal(t, network1.Name, n1.Name)
+	assert.Equal(t, network1.CIDR, n1.CIDR)
+	n2, err := tt.Service.GetNetworkByName(network2.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, network2.ID, n2.ID)
+}
+
+func Test_VIP(t *testing.T) {
+	tt, err := getClient()
+	require.Nil(t, err)
+
+	network, err := tt.Service.CreateNetwork(model.NetworkRequest{
+		Name:      "unit_test_network_vip",
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.20.0/24",
+	})
+	require.Nil(t, err)
+	defer tt.Service.DeleteNetwork(network.ID)
+
+	vip, err := tt.Service.CreateVIP(network.ID, "unit_test_vip")
+	require.Nil(t, err)
+	assert.Equal(t, "unit_test_vip", vip.Name)
+	assert.Equal(t, network.ID, vip.NetworkID)
+	_, cidr, _ := net.ParseCIDR(network.CIDR)
+	assert.True(t, cidr.Contains(net.ParseIP(vip.PrivateIP)))
+
+	err = tt.Service.DeleteVIP(vip)
+	assert.Nil(t, err)
+}
+
+func Test_Volume(t *testing.T) {
+	tt, err := getClient()
+	require.Nil(t, err)
+	tt.Volume(t)
+}
+
+func Test_SecurityGroups(t *testing.T) {
+	tt, err := getClient()
+	require.Nil(t, err)
+	tt.SecurityGroups(t)
+}
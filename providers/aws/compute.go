@@ -0,0 +1,758 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostState"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+	"github.com/CS-SI/SafeScale/providers/userdata"
+	"github.com/CS-SI/SafeScale/system"
+	"github.com/CS-SI/SafeScale/utils"
+	"github.com/CS-SI/SafeScale/utils/retry"
+)
+
+// imageFilters returns the filters selecting the images usable by SafeScale
+func (c *Client) imageFilters() []*ec2.Filter {
+	filters := []*ec2.Filter{
+		filter("state", "available"),
+		filter("architecture", "x86_64"),
+		filter("virtualization-type", "hvm"),
+		filter("root-device-type", "ebs"),
+	}
+	if len(c.Cfg.ImageOwners) > 0 {
+		filters = append(filters, filter("owner-id", c.Cfg.ImageOwners...))
+	}
+	return filters
+}
+
+// ListImages lists available OS images
+func (c *Client) ListImages(all bool) ([]model.Image, error) {
+	images, err := c.EC2.DescribeImages(&ec2.DescribeImagesInput{
+		Filters: c.imageFilters(),
+	})
+	if err != nil {
+		return nil, wrapError("Error listing images", err)
+	}
+	var list []model.Image
+	for _, img := range images.Images {
+		if img.Name == nil || strings.Contains(strings.ToUpper(*img.Name), "TEST") {
+			continue
+		}
+		list = append(list, model.Image{
+			ID:   *img.ImageId,
+			Name: *img.Name,
+		})
+	}
+	return list, nil
+}
+
+// GetImage returns the Image referenced by id
+func (c *Client) GetImage(id string) (*model.Image, error) {
+	images, err := c.EC2.DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		if isNotFound(err, "InvalidAMIID") {
+			return nil, model.ResourceNotFoundError("image", id)
+		}
+		return nil, wrapError(fmt.Sprintf("Error getting image '%s'", id), err)
+	}
+	if len(images.Images) == 0 {
+		return nil, model.ResourceNotFoundError("image", id)
+	}
+	img := images.Images[0]
+	return &model.Image{
+		ID:   pStr(img.ImageId),
+		Name: pStr(img.Name),
+	}, nil
+}
+
+// GetTemplate returns the Template referenced by id
+func (c *Client) GetTemplate(id string) (*model.HostTemplate, error) {
+	for _, t := range instanceTypes {
+		if t.ID == id {
+			tpl := t.toHostTemplate()
+			return &tpl, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("template", id)
+}
+
+// ListTemplates lists available host templates
+// Host templates are sorted using Dominant Resource Fairness Algorithm
+func (c *Client) ListTemplates(all bool) ([]model.HostTemplate, error) {
+	var list []model.HostTemplate
+	for _, t := range instanceTypes {
+		list = append(list, t.toHostTemplate())
+	}
+	return list, nil
+}
+
+// CreateKeyPair creates and import a key pair
+func (c *Client) CreateKeyPair(name string) (*model.KeyPair, error) {
+	publicKey, privateKey, err := system.CreateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.EC2.ImportKeyPair(&ec2.ImportKeyPairInput{
+		KeyName:           aws.String(name),
+		PublicKeyMaterial: publicKey,
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error creating key pair '%s'", name), err)
+	}
+	return &model.KeyPair{
+		ID:         name,
+		Name:       name,
+		PrivateKey: string(privateKey),
+		PublicKey:  string(publicKey),
+	}, nil
+}
+
+// GetKeyPair returns the key pair identified by id
+// EC2 doesn't return the public key, so PublicKey contains the fingerprint of the key
+func (c *Client) GetKeyPair(id string) (*model.KeyPair, error) {
+	out, err := c.EC2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
+		KeyNames: []*string{aws.String(id)},
+	})
+	if err != nil {
+		if isNotFound(err, "InvalidKeyPair") {
+			return nil, model.ResourceNotFoundError("keypair", id)
+		}
+		return nil, wrapError(fmt.Sprintf("Error getting key pair '%s'", id), err)
+	}
+	if len(out.KeyPairs) == 0 {
+		return nil, model.ResourceNotFoundError("keypair", id)
+	}
+	kp := out.KeyPairs[0]
+	return &model.KeyPair{
+		ID:         pStr(kp.KeyName),
+		Name:       pStr(kp.KeyName),
+		PrivateKey: "",
+		PublicKey:  pStr(kp.KeyFingerprint),
+	}, nil
+}
+
+// ListKeyPairs lists available key pairs
+func (c *Client) ListKeyPairs() ([]model.KeyPair, error) {
+	out, err := c.EC2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{})
+	if err != nil {
+		return nil, wrapError("Error listing key pairs", err)
+	}
+	keys := []model.KeyPair{}
+	for _, kp := range out.KeyPairs {
+		keys = append(keys, model.KeyPair{
+			ID:         pStr(kp.KeyName),
+			Name:       pStr(kp.KeyName),
+			PrivateKey: "",
+			PublicKey:  pStr(kp.KeyFingerprint),
+		})
+	}
+	return keys, nil
+}
+
+// DeleteKeyPair deletes the key pair identified by id
+func (c *Client) DeleteKeyPair(id string) error {
+	_, err := c.EC2.DeleteKeyPair(&ec2.DeleteKeyPairInput{
+		KeyName: aws.String(id),
+	})
+	return wrapError(fmt.Sprintf("Error deleting key pair '%s'", id), err)
+}
+
+// toHostState converts the name of the state of an instance into HostState
+func toHostState(state *ec2.InstanceState) HostState.Enum {
+	if state == nil {
+		return HostState.ERROR
+	}
+	switch pStr(state.Name) {
+	case ec2.InstanceStateNamePending:
+		return HostState.STARTING
+	case ec2.InstanceStateNameRunning:
+		return HostState.STARTED
+	case ec2.InstanceStateNameShuttingDown, ec2.InstanceStateNameStopping:
+		return HostState.STOPPING
+	case ec2.InstanceStateNameStopped:
+		return HostState.STOPPED
+	default:
+		return HostState.ERROR
+	}
+}
+
+// getInstance returns the instance identified by id; a terminated instance is considered as not found
+func (c *Client) getInstance(id string) (*ec2.Instance, error) {
+	out, err := c.EC2.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		if isNotFound(err, "InvalidInstanceID") {
+			return nil, model.ResourceNotFoundError("host", id)
+		}
+		return nil, wrapError(fmt.Sprintf("Error getting host '%s'", id), err)
+	}
+	for _, r := range out.Reservations {
+		for _, i := range r.Instances {
+			if i.State != nil && pStr(i.State.Name) == ec2.InstanceStateNameTerminated {
+				continue
+			}
+			return i, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("host", id)
+}
+
+// complementHost complements Host data with content of instance parameter
+func (c *Client) complementHost(host *model.Host, instance *ec2.Instance) error {
+	// Updates intrinsic data of host if needed
+	if host.ID == "" {
+		host.ID = pStr(instance.InstanceId)
+	}
+	if host.Name == "" {
+		host.Name = getTag(instance.Tags, nameTag)
+	}
+
+	host.LastState = toHostState(instance.State)
+
+	// Updates Host Property propsv1.HostDescription
+	hpDescriptionV1 := propsv1.NewHostDescription()
+	err := host.Properties.Get(HostProperty.DescriptionV1, hpDescriptionV1)
+	if err != nil {
+		return err
+	}
+	if instance.LaunchTime != nil {
+		hpDescriptionV1.Created = *instance.LaunchTime
+		hpDescriptionV1.Updated = *instance.LaunchTime
+	}
+	err = host.Properties.Set(HostProperty.DescriptionV1, hpDescriptionV1)
+	if err != nil {
+		return err
+	}
+
+	// Updates Host Property propsv1.HostSizing
+	hpSizingV1 := propsv1.NewHostSizing()
+	err = host.Properties.Get(HostProperty.SizingV1, hpSizingV1)
+	if err != nil {
+		return err
+	}
+	if tpl, err := c.GetTemplate(pStr(instance.InstanceType)); err == nil {
+		hpSizingV1.AllocatedSize = tpl.HostSize
+	}
+	err = host.Properties.Set(HostProperty.SizingV1, hpSizingV1)
+	if err != nil {
+		return err
+	}
+
+	// Updates Host Property propsv1.HostNetwork
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	err = host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+	if err != nil {
+		return err
+	}
+	hostNetworkV1.PublicIPv4 = pStr(instance.PublicIpAddress)
+	ipv4Addresses := map[string]string{}
+	for _, ni := range instance.NetworkInterfaces {
+		netID := pStr(ni.SubnetId)
+		ipv4Addresses[netID] = pStr(ni.PrivateIpAddress)
+		if hostNetworkV1.NetworksByID[netID] == "" {
+			net, err := c.GetNetwork(netID)
+			if err != nil {
+				log.Errorf("failed to get network '%s': %v", netID, err)
+				continue
+			}
+			hostNetworkV1.NetworksByID[netID] = net.Name
+			hostNetworkV1.NetworksByName[net.Name] = netID
+		}
+	}
+	hostNetworkV1.IPv4Addresses = ipv4Addresses
+
+	return host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+}
+
+// GetHost updates the data inside host with the data from provider
+func (c *Client) GetHost(hostParam interface{}) (*model.Host, error) {
+	var host *model.Host
+
+	switch hostParam.(type) {
+	case string:
+		host = model.NewHost()
+		host.ID = hostParam.(string)
+	case *model.Host:
+		host = hostParam.(*model.Host)
+	default:
+		panic("hostParam must be a string or a *model.Host!")
+	}
+
+	instance, err := c.getInstance(host.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = c.complementHost(host, instance)
+	if err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// GetHostByName returns the host using the name passed as parameter
+func (c *Client) GetHostByName(name string) (*model.Host, error) {
+	if name == "" {
+		panic("name is empty!")
+	}
+	out, err := c.EC2.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			c.vpcFilter(),
+			filter("tag:"+nameTag, name),
+			filter("instance-state-name",
+				ec2.InstanceStateNamePending,
+				ec2.InstanceStateNameRunning,
+				ec2.InstanceStateNameStopping,
+				ec2.InstanceStateNameStopped,
+			),
+		},
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error getting host '%s'", name), err)
+	}
+	for _, r := range out.Reservations {
+		for _, i := range r.Instances {
+			host := model.NewHost()
+			err = c.complementHost(host, i)
+			if err != nil {
+				return nil, err
+			}
+			return host, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("host", name)
+}
+
+// GetHostState returns the current state of the host
+func (c *Client) GetHostState(hostParam interface{}) (HostState.Enum, error) {
+	host, err := c.GetHost(hostParam)
+	if err != nil {
+		return HostState.ERROR, err
+	}
+	return host.LastState, nil
+}
+
+// ListHosts lists the hosts of the VPC
+func (c *Client) ListHosts() ([]*model.Host, error) {
+	var hosts []*model.Host
+	err := c.EC2.DescribeInstancesPages(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{c.vpcFilter()},
+	}, func(out *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, r := range out.Reservations {
+			for _, i := range r.Instances {
+				if i.State != nil && pStr(i.State.Name) == ec2.InstanceStateNameTerminated {
+					continue
+				}
+				host := model.NewHost()
+				err := c.complementHost(host, i)
+				if err != nil {
+					log.Warnf("failed to complement host '%s': %v", pStr(i.InstanceId), err)
+				}
+				hosts = append(hosts, host)
+			}
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, wrapError("Error listing hosts", err)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts, nil
+}
+
+// CreateHost creates an host satisfying request
+func (c *Client) CreateHost(request model.HostRequest) (*model.Host, error) {
+	msgFail := "Failed to create Host resource: %s"
+	msgSuccess := fmt.Sprintf("Host resource '%s' created successfully", request.ResourceName)
+
+	if request.DefaultGateway == nil && !request.PublicIP {
+		return nil, model.ResourceInvalidRequestError("host creation", "can't create a gateway without public IP")
+	}
+
+	// The Default Network is the first of the provided list, by convention
+	defaultNetwork := request.Networks[0]
+	defaultNetworkID := defaultNetwork.ID
+	defaultGateway := request.DefaultGateway
+	isGateway := (defaultGateway == nil && defaultNetwork.Name != model.SingleHostNetworkName)
+	defaultGatewayID := ""
+	defaultGatewayPrivateIP := ""
+	if defaultGateway != nil {
+		hostNetworkV1 := propsv1.NewHostNetwork()
+		err := defaultGateway.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+		if err != nil {
+			return nil, errors.Wrap(err, "")
+		}
+		defaultGatewayPrivateIP = hostNetworkV1.IPv4Addresses[defaultNetworkID]
+		defaultGatewayID = defaultGateway.ID
+	}
+
+	_, err := c.GetHostByName(request.ResourceName)
+	if err == nil {
+		return nil, model.ResourceAlreadyExistsError("host", request.ResourceName)
+	}
+	if _, ok := err.(model.ErrResourceNotFound); !ok {
+		return nil, err
+	}
+
+	// If no key pair is supplied create one
+	if request.KeyPair == nil {
+		id, err := uuid.NewV4()
+		if err != nil {
+			msg := fmt.Sprintf("failed to create host UUID: %+v", err)
+			log.Debugf(utils.TitleFirst(msg))
+			return nil, fmt.Errorf(msg)
+		}
+
+		name := fmt.Sprintf("%s_%s", request.ResourceName, id)
+		request.KeyPair, err = c.CreateKeyPair(name)
+		if err != nil {
+			msg := fmt.Sprintf("failed to create host key pair: %+v", err)
+			log.Debugf(utils.TitleFirst(msg))
+			return nil, fmt.Errorf(msg)
+		}
+	}
+
+	// --- prepares data structures for Provider usage ---
+
+	// Constructs userdata content
+	userData, err := userdata.Prepare(c, request, request.KeyPair, defaultNetwork.CIDR)
+	if err != nil {
+		msg := fmt.Sprintf("failed to prepare user data content: %+v", err)
+		log.Debugf(utils.TitleFirst(msg))
+		return nil, fmt.Errorf(msg)
+	}
+
+	template, err := c.GetTemplate(request.TemplateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %s", err.Error())
+	}
+
+	// The root volume is sized by the template, its device depending on the image
+	images, err := c.EC2.DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(request.ImageID)},
+	})
+	if err != nil {
+		return nil, wrapError("failed to get image", err)
+	}
+	if len(images.Images) == 0 {
+		return nil, model.ResourceNotFoundError("image", request.ImageID)
+	}
+	rootVolume := &ec2.BlockDeviceMapping{
+		DeviceName: images.Images[0].RootDeviceName,
+		Ebs: &ec2.EbsBlockDevice{
+			VolumeSize:          aws.Int64(int64(template.DiskSize)),
+			VolumeType:          aws.String(ec2.VolumeTypeGp2),
+			DeleteOnTermination: aws.Bool(true),
+		},
+	}
+
+	// One network interface per network, the first one in the default network; the public IP, if requested,
+	// is an Elastic IP associated after creation, the instance being able to have several network interfaces
+	var interfaces []*ec2.InstanceNetworkInterfaceSpecification
+	for i, n := range request.Networks {
+		interfaces = append(interfaces, &ec2.InstanceNetworkInterfaceSpecification{
+			DeviceIndex:         aws.Int64(int64(i)),
+			SubnetId:            aws.String(n.ID),
+			Groups:              []*string{aws.String(c.SecurityGroupID)},
+			DeleteOnTermination: aws.Bool(true),
+		})
+	}
+
+	// --- Initializes model.Host ---
+
+	host := model.NewHost()
+	host.Name = request.ResourceName
+	host.PrivateKey = request.KeyPair.PrivateKey // Add PrivateKey to host definition
+
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	hostNetworkV1.DefaultNetworkID = defaultNetworkID
+	hostNetworkV1.DefaultGatewayID = defaultGatewayID
+	hostNetworkV1.DefaultGatewayPrivateIP = defaultGatewayPrivateIP
+	hostNetworkV1.IsGateway = isGateway
+	for _, n := range request.Networks {
+		hostNetworkV1.NetworksByID[n.ID] = n.Name
+		hostNetworkV1.NetworksByName[n.Name] = n.ID
+	}
+
+	// Updates Host property NetworkV1
+	err = host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Adds Host property SizingV1
+	err = host.Properties.Set(HostProperty.SizingV1, &propsv1.HostSizing{
+		// Note: from there, no idea what was the RequestedSize; caller will have to complement this information
+		Template:      request.TemplateID,
+		AllocatedSize: template.HostSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// --- query provider for host creation ---
+
+	out, err := c.EC2.RunInstances(&ec2.RunInstancesInput{
+		ImageId:             aws.String(request.ImageID),
+		KeyName:             aws.String(request.KeyPair.Name),
+		InstanceType:        aws.String(request.TemplateID),
+		NetworkInterfaces:   interfaces,
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{rootVolume},
+		MaxCount:            aws.Int64(1),
+		MinCount:            aws.Int64(1),
+		UserData:            aws.String(base64.StdEncoding.EncodeToString(userData)),
+	})
+	if err != nil {
+		log.Debugf("Error creating host: %+v", err)
+		return nil, wrapError(fmt.Sprintf("Error creating host '%s'", request.ResourceName), err)
+	}
+	if len(out.Instances) == 0 {
+		return nil, errors.New("unexpected problem creating host")
+	}
+	host.ID = pStr(out.Instances[0].InstanceId)
+
+	// Starting from here, delete host if exiting with error
+	defer func() {
+		if err != nil {
+			derr := c.DeleteHost(host.ID)
+			if derr != nil {
+				log.Warnf("Error deleting host: %v", derr)
+			}
+		}
+	}()
+
+	err = c.setTags(host.ID, map[string]string{nameTag: request.ResourceName})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf(msgFail, "failed to name host"), err)
+	}
+
+	// A gateway routes the traffic of the other hosts of its network
+	if isGateway {
+		_, err = c.EC2.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+			InstanceId:      aws.String(host.ID),
+			SourceDestCheck: &ec2.AttributeBooleanValue{Value: aws.Bool(false)},
+		})
+		if err != nil {
+			return nil, wrapError(fmt.Sprintf(msgFail, "failed to enable routing"), err)
+		}
+	}
+
+	// Wait that Host is ready, not just that the build is started
+	host, err = c.WaitHostReady(host, 5*time.Minute)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf(msgFail, "timeout"))
+	}
+
+	if request.PublicIP {
+		var ip string
+		ip, err = c.associatePublicIP(host.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf(msgFail, "failed to associate public IP"))
+		}
+		err = host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+		if err != nil {
+			return nil, err
+		}
+		hostNetworkV1.PublicIPv4 = ip
+		// Updates Host Extension NetworkV1 in host instance
+		err = host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The host generates its SSH host key and prints it on its console, where it is read to authenticate the host
+	host.HostKey, err = userdata.WaitHostKey(func() (string, error) {
+		return c.consoleOutput(host.ID)
+	}, 10*time.Minute)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf(msgFail, "failed to read SSH host key"))
+	}
+
+	log.Infoln(msgSuccess)
+	return host, nil
+}
+
+// consoleOutput returns the console output of the host identified by hostID
+func (c *Client) consoleOutput(hostID string) (string, error) {
+	out, err := c.EC2.GetConsoleOutput(&ec2.GetConsoleOutputInput{
+		InstanceId: aws.String(hostID),
+	})
+	if err != nil {
+		return "", err
+	}
+	// The output is base64 encoded, and empty until the instance has written on its console
+	output, err := base64.StdEncoding.DecodeString(pStr(out.Output))
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// associatePublicIP allocates an Elastic IP and associates it to the first network interface of the host
+// identified by hostID, and returns it
+func (c *Client) associatePublicIP(hostID string) (string, error) {
+	addr, err := c.EC2.AllocateAddress(&ec2.AllocateAddressInput{
+		Domain: aws.String(ec2.DomainTypeVpc),
+	})
+	if err != nil {
+		return "", err
+	}
+	instance, err := c.getInstance(hostID)
+	if err == nil {
+		for _, ni := range instance.NetworkInterfaces {
+			if ni.Attachment != nil && aws.Int64Value(ni.Attachment.DeviceIndex) == 0 {
+				_, err = c.EC2.AssociateAddress(&ec2.AssociateAddressInput{
+					AllocationId:       addr.AllocationId,
+					NetworkInterfaceId: ni.NetworkInterfaceId,
+				})
+				if err == nil {
+					return pStr(addr.PublicIp), nil
+				}
+				break
+			}
+		}
+		if err == nil {
+			err = fmt.Errorf("no network interface found")
+		}
+	}
+	_, derr := c.EC2.ReleaseAddress(&ec2.ReleaseAddressInput{AllocationId: addr.AllocationId})
+	if derr != nil {
+		log.Errorf("Error releasing Elastic IP: %v", derr)
+	}
+	return "", err
+}
+
+// WaitHostReady waits an host achieve ready state
+// hostParam can be an ID of host, or an instance of *model.Host; any other type will panic
+func (c *Client) WaitHostReady(hostParam interface{}, timeout time.Duration) (*model.Host, error) {
+	var (
+		host *model.Host
+		err  error
+	)
+	switch hostParam.(type) {
+	case string:
+		host = model.NewHost()
+		host.ID = hostParam.(string)
+	case *model.Host:
+		host = hostParam.(*model.Host)
+	default:
+		panic("hostParam must be a string or a *model.Host!")
+	}
+
+	retryErr := retry.WhileUnsuccessful(
+		func() error {
+			_, err = c.GetHost(host)
+			if err != nil {
+				return err
+			}
+			if host.LastState != HostState.STARTED {
+				return fmt.Errorf("not in ready state (current state: %s)", host.LastState.String())
+			}
+			return nil
+		},
+		2*time.Second,
+		timeout,
+	)
+	if retryErr != nil {
+		switch retryErr.(type) {
+		case retry.ErrTimeout:
+			return nil, fmt.Errorf("timeout waiting to get host '%s' information after %v", host.Name, timeout)
+		}
+		return nil, retryErr
+	}
+	return host, nil
+}
+
+// DeleteHost deletes the host identified by id, and releases its Elastic IPs
+func (c *Client) DeleteHost(id string) error {
+	addrs, err := c.EC2.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{filter("instance-id", id)},
+	})
+	if err != nil {
+		return wrapError(fmt.Sprintf("Error deleting host '%s'", id), err)
+	}
+	for _, addr := range addrs.Addresses {
+		_, err = c.EC2.DisassociateAddress(&ec2.DisassociateAddressInput{
+			AssociationId: addr.AssociationId,
+		})
+		if err != nil {
+			log.Warnf("Error disassociating Elastic IP '%s': %v", pStr(addr.PublicIp), err)
+		}
+		_, err = c.EC2.ReleaseAddress(&ec2.ReleaseAddressInput{
+			AllocationId: addr.AllocationId,
+		})
+		if err != nil {
+			log.Warnf("Error releasing Elastic IP '%s': %v", pStr(addr.PublicIp), err)
+		}
+	}
+
+	input := &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(id)}}
+	_, err = c.EC2.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: input.InstanceIds,
+	})
+	if err != nil {
+		if isNotFound(err, "InvalidInstanceID") {
+			return model.ResourceNotFoundError("host", id)
+		}
+		return wrapError(fmt.Sprintf("Error deleting host '%s'", id), err)
+	}
+	// Waits the termination, the network of the host can't be deleted before
+	err = c.EC2.WaitUntilInstanceTerminated(input)
+	return wrapError(fmt.Sprintf("Error deleting host '%s'", id), err)
+}
+
+// StopHost stops the host identified by id
+func (c *Client) StopHost(id string) error {
+	_, err := c.EC2.StopInstances(&ec2.StopInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	return wrapError(fmt.Sprintf("Error stopping host '%s'", id), err)
+}
+
+// StartHost starts the host identified by id
+func (c *Client) StartHost(id string) error {
+	_, err := c.EC2.StartInstances(&ec2.StartInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	return wrapError(fmt.Sprintf("Error starting host '%s'", id), err)
+}
+
+// RebootHost reboots the host identified by id
+func (c *Client) RebootHost(id string) error {
+	_, err := c.EC2.RebootInstances(&ec2.RebootInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	return wrapError(fmt.Sprintf("Error rebooting host '%s'", id), err)
+}
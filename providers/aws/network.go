@@ -0,0 +1,193 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"fmt"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/IPVersion"
+)
+
+// toModelNetwork converts a subnet into model.Network
+func toModelNetwork(subnet *ec2.Subnet) *model.Network {
+	network := model.NewNetwork()
+	network.ID = pStr(subnet.SubnetId)
+	network.Name = getTag(subnet.Tags, nameTag)
+	network.CIDR = pStr(subnet.CidrBlock)
+	network.IPVersion = IPVersion.IPv4
+	return network
+}
+
+// CreateNetwork creates a network, as a subnet of the VPC
+func (c *Client) CreateNetwork(req model.NetworkRequest) (*model.Network, error) {
+	log.Debugf("providers.aws.CreateNetwork(%s) called\n", req.Name)
+	_, err := c.GetNetworkByName(req.Name)
+	if err == nil {
+		return nil, model.ResourceAlreadyExistsError("network", req.Name)
+	}
+	if _, ok := err.(model.ErrResourceNotFound); !ok {
+		return nil, err
+	}
+
+	// Checks if CIDR is valid...
+	_, vpcDesc, _ := net.ParseCIDR(c.Opts.VPCCIDR)
+	_, networkDesc, err := net.ParseCIDR(req.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subnet '%s (%s)': %s", req.Name, req.CIDR, err.Error())
+	}
+	// .. and if CIDR is inside VPC's one
+	if !vpcDesc.Contains(networkDesc.IP) {
+		return nil, fmt.Errorf("can't create subnet with CIDR '%s': not inside VPC CIDR '%s'", req.CIDR, c.Opts.VPCCIDR)
+	}
+
+	// Creates the subnet
+	out, err := c.EC2.CreateSubnet(&ec2.CreateSubnetInput{
+		CidrBlock:        aws.String(req.CIDR),
+		VpcId:            aws.String(c.VPCID),
+		AvailabilityZone: aws.String(c.Opts.AvailabilityZone),
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error creating network '%s'", req.Name), err)
+	}
+	id := pStr(out.Subnet.SubnetId)
+
+	err = c.setTags(id, map[string]string{nameTag: req.Name})
+	if err != nil {
+		derr := c.DeleteNetwork(id)
+		if derr != nil {
+			log.Errorf("failed to delete subnet '%s': %v", req.Name, derr)
+		}
+		return nil, wrapError(fmt.Sprintf("Error creating network '%s'", req.Name), err)
+	}
+
+	network := toModelNetwork(out.Subnet)
+	network.Name = req.Name
+	return network, nil
+}
+
+// GetNetwork returns the network identified by id
+func (c *Client) GetNetwork(id string) (*model.Network, error) {
+	out, err := c.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: []*string{aws.String(id)},
+		Filters:   []*ec2.Filter{c.vpcFilter()},
+	})
+	if err != nil {
+		if isNotFound(err, "InvalidSubnetID") {
+			return nil, model.ResourceNotFoundError("network", id)
+		}
+		return nil, wrapError(fmt.Sprintf("Error getting network '%s'", id), err)
+	}
+	if len(out.Subnets) == 0 {
+		return nil, model.ResourceNotFoundError("network", id)
+	}
+	return toModelNetwork(out.Subnets[0]), nil
+}
+
+// GetNetworkByName returns the network named name
+func (c *Client) GetNetworkByName(name string) (*model.Network, error) {
+	if name == "" {
+		panic("name is empty!")
+	}
+	out, err := c.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			c.vpcFilter(),
+			filter("tag:"+nameTag, name),
+		},
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error getting network '%s'", name), err)
+	}
+	if len(out.Subnets) == 0 {
+		return nil, model.ResourceNotFoundError("network", name)
+	}
+	return toModelNetwork(out.Subnets[0]), nil
+}
+
+// ListNetworks lists the networks of the VPC
+func (c *Client) ListNetworks() ([]*model.Network, error) {
+	out, err := c.EC2.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{c.vpcFilter()},
+	})
+	if err != nil {
+		return nil, wrapError("Failed to get networks list", err)
+	}
+	var list []*model.Network
+	for _, subnet := range out.Subnets {
+		list = append(list, toModelNetwork(subnet))
+	}
+	return list, nil
+}
+
+// DeleteNetwork deletes the network identified by id
+func (c *Client) DeleteNetwork(id string) error {
+	_, err := c.EC2.DeleteSubnet(&ec2.DeleteSubnetInput{
+		SubnetId: aws.String(id),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "DependencyViolation" {
+			return model.ResourceNotAvailableError("network", id)
+		}
+		if isNotFound(err, "InvalidSubnetID") {
+			return model.ResourceNotFoundError("network", id)
+		}
+		return wrapError(fmt.Sprintf("Error deleting network '%s'", id), err)
+	}
+	return nil
+}
+
+// CreateGateway creates a public Gateway for a private network
+func (c *Client) CreateGateway(req model.GatewayRequest) (*model.Host, error) {
+	if req.Network == nil {
+		panic("req.Network is nil!")
+	}
+	gwname := req.Name
+	if gwname == "" {
+		gwname = "gw-" + req.Network.Name
+	}
+	hostReq := model.HostRequest{
+		ImageID:      req.ImageID,
+		KeyPair:      req.KeyPair,
+		ResourceName: gwname,
+		TemplateID:   req.TemplateID,
+		Networks:     []*model.Network{req.Network},
+		PublicIP:     true,
+		UserData:     req.UserData,
+	}
+	host, err := c.CreateHost(hostReq)
+	if err != nil {
+		switch err.(type) {
+		case model.ErrResourceInvalidRequest:
+			return nil, err
+		default:
+			return nil, wrapError("Error creating gateway", err)
+		}
+	}
+	return host, err
+}
+
+// DeleteGateway deletes the gateway associated with network identified by ID
+func (c *Client) DeleteGateway(id string) error {
+	return c.DeleteHost(id)
+}
@@ -0,0 +1,276 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// EC2 doesn't identify the rules of the security groups, so the ID of a rule is built from its content,
+// as "<protocol>:<from port>:<to port>:<cidr>"
+
+// ruleID returns the ID of rule
+func ruleID(rule model.SecurityGroupRule) string {
+	return fmt.Sprintf("%s:%d:%d:%s", rule.Protocol, rule.FromPort, rule.ToPort, rule.CIDR)
+}
+
+// parseRuleID returns the rule corresponding to an ID built by ruleID
+func parseRuleID(id string) (*model.SecurityGroupRule, error) {
+	// The CIDR may contain ':' (IPv6)
+	parts := strings.SplitN(id, ":", 4)
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid rule ID '%s'", id)
+	}
+	from, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule ID '%s'", id)
+	}
+	to, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule ID '%s'", id)
+	}
+	return &model.SecurityGroupRule{
+		ID:       id,
+		Protocol: parts[0],
+		FromPort: from,
+		ToPort:   to,
+		CIDR:     parts[3],
+	}, nil
+}
+
+// toIPPermission converts a rule into an EC2 IP permission
+func toIPPermission(rule model.SecurityGroupRule) *ec2.IpPermission {
+	perm := &ec2.IpPermission{
+		IpProtocol: aws.String(rule.Protocol),
+		FromPort:   aws.Int64(int64(rule.FromPort)),
+		ToPort:     aws.Int64(int64(rule.ToPort)),
+	}
+	if strings.Contains(rule.CIDR, ":") {
+		perm.Ipv6Ranges = []*ec2.Ipv6Range{{CidrIpv6: aws.String(rule.CIDR)}}
+	} else {
+		perm.IpRanges = []*ec2.IpRange{{CidrIp: aws.String(rule.CIDR)}}
+	}
+	return perm
+}
+
+// toModelSecurityGroup converts an EC2 security group into model.SecurityGroup, with a rule per
+// protocol, port range and CIDR
+func toModelSecurityGroup(sg *ec2.SecurityGroup) *model.SecurityGroup {
+	group := model.SecurityGroup{
+		ID:          pStr(sg.GroupId),
+		Name:        pStr(sg.GroupName),
+		Description: pStr(sg.Description),
+		NetworkID:   getTag(sg.Tags, networkTag),
+	}
+	for _, perm := range sg.IpPermissions {
+		rule := model.SecurityGroupRule{
+			Protocol: pStr(perm.IpProtocol),
+			FromPort: int(aws.Int64Value(perm.FromPort)),
+			ToPort:   int(aws.Int64Value(perm.ToPort)),
+		}
+		var cidrs []string
+		for _, r := range perm.IpRanges {
+			cidrs = append(cidrs, pStr(r.CidrIp))
+		}
+		for _, r := range perm.Ipv6Ranges {
+			cidrs = append(cidrs, pStr(r.CidrIpv6))
+		}
+		for _, cidr := range cidrs {
+			rule.CIDR = cidr
+			rule.ID = ruleID(rule)
+			group.Rules = append(group.Rules, rule)
+		}
+	}
+	return &group
+}
+
+// CreateSecurityGroup creates a security group, without rule
+// In EC2, a security group belongs to a VPC: the group is created in the VPC of the tenant, request.NetworkID
+// being only recorded in a tag
+func (c *Client) CreateSecurityGroup(request model.SecurityGroupRequest) (*model.SecurityGroup, error) {
+	description := request.Description
+	if description == "" {
+		description = fmt.Sprintf("Security group %s", request.Name)
+	}
+	out, err := c.EC2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(request.Name),
+		Description: aws.String(description),
+		VpcId:       aws.String(c.VPCID),
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error creating security group '%s'", request.Name), err)
+	}
+	if request.NetworkID != "" {
+		err = c.setTags(pStr(out.GroupId), map[string]string{networkTag: request.NetworkID})
+		if err != nil {
+			_, derr := c.EC2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{GroupId: out.GroupId})
+			if derr != nil {
+				log.Errorf("Failed to delete security group '%s': %v", request.Name, derr)
+			}
+			return nil, wrapError(fmt.Sprintf("Error creating security group '%s'", request.Name), err)
+		}
+	}
+	return &model.SecurityGroup{
+		ID:          pStr(out.GroupId),
+		Name:        request.Name,
+		Description: description,
+		NetworkID:   request.NetworkID,
+	}, nil
+}
+
+// GetSecurityGroup returns the security group identified by id, with its rules
+func (c *Client) GetSecurityGroup(id string) (*model.SecurityGroup, error) {
+	out, err := c.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "InvalidGroup.NotFound" {
+			return nil, nil
+		}
+		return nil, wrapError("Error getting security group", err)
+	}
+	if len(out.SecurityGroups) == 0 {
+		return nil, nil
+	}
+	return toModelSecurityGroup(out.SecurityGroups[0]), nil
+}
+
+// ListSecurityGroups lists available security groups
+func (c *Client) ListSecurityGroups() ([]model.SecurityGroup, error) {
+	out, err := c.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{c.vpcFilter()},
+	})
+	if err != nil {
+		return nil, wrapError("Error listing security groups", err)
+	}
+	groups := []model.SecurityGroup{}
+	for _, sg := range out.SecurityGroups {
+		groups = append(groups, *toModelSecurityGroup(sg))
+	}
+	return groups, nil
+}
+
+// DeleteSecurityGroup deletes the security group identified by id
+func (c *Client) DeleteSecurityGroup(id string) error {
+	_, err := c.EC2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+		GroupId: aws.String(id),
+	})
+	return wrapError("Error deleting security group", err)
+}
+
+// AddRule adds a rule allowing incoming traffic to the security group identified by groupID
+func (c *Client) AddRule(groupID string, rule model.SecurityGroupRule) (*model.SecurityGroupRule, error) {
+	err := rule.Validate()
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.EC2.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: []*ec2.IpPermission{toIPPermission(rule)},
+	})
+	if err != nil {
+		return nil, wrapError("Error adding rule to security group", err)
+	}
+	rule.ID = ruleID(rule)
+	return &rule, nil
+}
+
+// DeleteRule deletes the rule identified by ruleID from the security group identified by groupID
+func (c *Client) DeleteRule(groupID string, ruleID string) error {
+	rule, err := parseRuleID(ruleID)
+	if err != nil {
+		return err
+	}
+	_, err = c.EC2.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+		GroupId:       aws.String(groupID),
+		IpPermissions: []*ec2.IpPermission{toIPPermission(*rule)},
+	})
+	return wrapError(fmt.Sprintf("Error deleting rule '%s' of security group '%s'", ruleID, groupID), err)
+}
+
+// getInstanceGroups returns the IDs of the security groups of the instance identified by hostID
+func (c *Client) getInstanceGroups(hostID string) ([]string, error) {
+	out, err := c.EC2.DescribeInstanceAttribute(&ec2.DescribeInstanceAttributeInput{
+		Attribute:  aws.String(ec2.InstanceAttributeNameGroupSet),
+		InstanceId: aws.String(hostID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	groups := []string{}
+	for _, g := range out.Groups {
+		groups = append(groups, pStr(g.GroupId))
+	}
+	return groups, nil
+}
+
+// setInstanceGroups replaces the security groups of the instance identified by hostID
+func (c *Client) setInstanceGroups(hostID string, groups []string) error {
+	_, err := c.EC2.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId: aws.String(hostID),
+		Groups:     aws.StringSlice(groups),
+	})
+	return err
+}
+
+// BindToHost binds the security group identified by groupID to the host identified by hostID
+func (c *Client) BindToHost(groupID string, hostID string) error {
+	groups, err := c.getInstanceGroups(hostID)
+	if err != nil {
+		return wrapError("Error binding security group to host", err)
+	}
+	for _, g := range groups {
+		if g == groupID {
+			return nil
+		}
+	}
+	err = c.setInstanceGroups(hostID, append(groups, groupID))
+	return wrapError(fmt.Sprintf("Error binding security group '%s' to host '%s'", groupID, hostID), err)
+}
+
+// UnbindFromHost unbinds the security group identified by groupID from the host identified by hostID
+func (c *Client) UnbindFromHost(groupID string, hostID string) error {
+	groups, err := c.getInstanceGroups(hostID)
+	if err != nil {
+		return wrapError("Error unbinding security group from host", err)
+	}
+	remaining := []string{}
+	for _, g := range groups {
+		if g != groupID {
+			remaining = append(remaining, g)
+		}
+	}
+	if len(remaining) == len(groups) {
+		return nil
+	}
+	// An instance must have at least one security group
+	if len(remaining) == 0 {
+		return fmt.Errorf("Error unbinding security group '%s' from host '%s': it's the last security group of the host", groupID, hostID)
+	}
+	err = c.setInstanceGroups(hostID, remaining)
+	return wrapError(fmt.Sprintf("Error unbinding security group '%s' from host '%s'", groupID, hostID), err)
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+func TestRuleID(t *testing.T) {
+	rules := []model.SecurityGroupRule{
+		{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: "0.0.0.0/0"},
+		{Protocol: "icmp", FromPort: -1, ToPort: -1, CIDR: "10.0.0.0/8"},
+		{Protocol: "udp", FromPort: 53, ToPort: 53, CIDR: "2001:db8::/32"},
+	}
+	for _, rule := range rules {
+		rule.ID = ruleID(rule)
+		parsed, err := parseRuleID(rule.ID)
+		require.Nil(t, err)
+		assert.Equal(t, rule, *parsed)
+	}
+
+	for _, id := range []string{"", "tcp:22:22", "tcp:a:22:0.0.0.0/0", "tcp:22:b:0.0.0.0/0"} {
+		_, err := parseRuleID(id)
+		assert.NotNil(t, err, id)
+	}
+}
+
+func TestToIPPermission(t *testing.T) {
+	perm := toIPPermission(model.SecurityGroupRule{Protocol: "tcp", FromPort: 80, ToPort: 443, CIDR: "192.168.0.0/24"})
+	assert.Equal(t, "tcp", aws.StringValue(perm.IpProtocol))
+	assert.Equal(t, int64(80), aws.Int64Value(perm.FromPort))
+	assert.Equal(t, int64(443), aws.Int64Value(perm.ToPort))
+	require.Len(t, perm.IpRanges, 1)
+	assert.Equal(t, "192.168.0.0/24", aws.StringValue(perm.IpRanges[0].CidrIp))
+	assert.Empty(t, perm.Ipv6Ranges)
+
+	perm = toIPPermission(model.SecurityGroupRule{Protocol: "icmp", FromPort: -1, ToPort: -1, CIDR: "::/0"})
+	assert.Equal(t, int64(-1), aws.Int64Value(perm.FromPort))
+	assert.Empty(t, perm.IpRanges)
+	require.Len(t, perm.Ipv6Ranges, 1)
+	assert.Equal(t, "::/0", aws.StringValue(perm.Ipv6Ranges[0].CidrIpv6))
+}
+
+func TestToModelSecurityGroup(t *testing.T) {
+	sg := &ec2.SecurityGroup{
+		GroupId:     aws.String("sg-1"),
+		GroupName:   aws.String("web"),
+		Description: aws.String("web servers"),
+		Tags:        []*ec2.Tag{{Key: aws.String(networkTag), Value: aws.String("net-id")}},
+		IpPermissions: []*ec2.IpPermission{
+			{
+				IpProtocol: aws.String("tcp"),
+				FromPort:   aws.Int64(80),
+				ToPort:     aws.Int64(80),
+				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("10.0.0.0/8")}, {CidrIp: aws.String("192.168.0.0/16")}},
+				Ipv6Ranges: []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0")}},
+			},
+			// Permission granted to another group, without CIDR: no rule
+			{IpProtocol: aws.String("tcp"), FromPort: aws.Int64(22), ToPort: aws.Int64(22)},
+		},
+	}
+	group := toModelSecurityGroup(sg)
+	assert.Equal(t, "sg-1", group.ID)
+	assert.Equal(t, "web", group.Name)
+	assert.Equal(t, "web servers", group.Description)
+	assert.Equal(t, "net-id", group.NetworkID)
+	require.Len(t, group.Rules, 3)
+	for i, cidr := range []string{"10.0.0.0/8", "192.168.0.0/16", "::/0"} {
+		rule := group.Rules[i]
+		assert.Equal(t, cidr, rule.CIDR)
+		assert.Equal(t, "tcp", rule.Protocol)
+		assert.Equal(t, 80, rule.FromPort)
+		assert.Equal(t, 80, rule.ToPort)
+
+		// The rule is deleted with its ID
+		parsed, err := parseRuleID(rule.ID)
+		require.Nil(t, err)
+		assert.Equal(t, rule, *parsed)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"github.com/CS-SI/SafeScale/providers/model"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+)
+
+// EC2 doesn't describe the instance types (the Pricing API is only available in some regions, and not by the
+// EC2-compatible services), so the templates are a list of current generation instance types; as the instances
+// have no local disk, DiskSize is the size of the root volume created with the host
+
+// instanceType describes an EC2 instance type
+type instanceType struct {
+	ID       string
+	Cores    int
+	RAMSize  float32
+	DiskSize int
+	GPU      int
+	GPUType  string
+}
+
+// toHostTemplate converts an instance type into model.HostTemplate
+func (t instanceType) toHostTemplate() model.HostTemplate {
+	return model.HostTemplate{
+		HostTemplate: &propsv1.HostTemplate{
+			ID:   t.ID,
+			Name: t.ID,
+			HostSize: &propsv1.HostSize{
+				Cores:     t.Cores,
+				RAMSize:   t.RAMSize,
+				DiskSize:  t.DiskSize,
+				GPUNumber: t.GPU,
+				GPUType:   t.GPUType,
+			},
+		},
+	}
+}
+
+// instanceTypes are the instance types usable as templates
+var instanceTypes = []instanceType{
+	{ID: "t2.micro", Cores: 1, RAMSize: 1, DiskSize: 10},
+	{ID: "t2.small", Cores: 1, RAMSize: 2, DiskSize: 20},
+	{ID: "t2.medium", Cores: 2, RAMSize: 4, DiskSize: 20},
+	{ID: "t2.large", Cores: 2, RAMSize: 8, DiskSize: 40},
+	{ID: "t2.xlarge", Cores: 4, RAMSize: 16, DiskSize: 40},
+	{ID: "t2.2xlarge", Cores: 8, RAMSize: 32, DiskSize: 80},
+	{ID: "m5.large", Cores: 2, RAMSize: 8, DiskSize: 40},
+	{ID: "m5.xlarge", Cores: 4, RAMSize: 16, DiskSize: 80},
+	{ID: "m5.2xlarge", Cores: 8, RAMSize: 32, DiskSize: 100},
+	{ID: "m5.4xlarge", Cores: 16, RAMSize: 64, DiskSize: 160},
+	{ID: "m5.12xlarge", Cores: 48, RAMSize: 192, DiskSize: 160},
+	{ID: "c5.large", Cores: 2, RAMSize: 4, DiskSize: 40},
+	{ID: "c5.xlarge", Cores: 4, RAMSize: 8, DiskSize: 80},
+	{ID: "c5.2xlarge", Cores: 8, RAMSize: 16, DiskSize: 100},
+	{ID: "c5.4xlarge", Cores: 16, RAMSize: 32, DiskSize: 160},
+	{ID: "c5.9xlarge", Cores: 36, RAMSize: 72, DiskSize: 160},
+	{ID: "r5.large", Cores: 2, RAMSize: 16, DiskSize: 40},
+	{ID: "r5.xlarge", Cores: 4, RAMSize: 32, DiskSize: 80},
+	{ID: "r5.2xlarge", Cores: 8, RAMSize: 64, DiskSize: 100},
+	{ID: "r5.4xlarge", Cores: 16, RAMSize: 128, DiskSize: 160},
+	{ID: "p3.2xlarge", Cores: 8, RAMSize: 61, DiskSize: 200, GPU: 1, GPUType: "Tesla V100"},
+	{ID: "p3.8xlarge", Cores: 32, RAMSize: 244, DiskSize: 200, GPU: 4, GPUType: "Tesla V100"},
+	{ID: "g3.4xlarge", Cores: 16, RAMSize: 122, DiskSize: 200, GPU: 1, GPUType: "Tesla M60"},
+	{ID: "g3.8xlarge", Cores: 32, RAMSize: 244, DiskSize: 200, GPU: 2, GPUType: "Tesla M60"},
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// A VIP is a secondary private address of a network interface without instance, reserving the address in the
+// subnet. EC2 doesn't allow an address on several interfaces: binding a host moves the address to the interface
+// of the host in the network, unbinding it gives the address back to the interface of the VIP. So the VIP is
+// held by the last bound host, and moving it to another host is done by binding this host again.
+
+// CreateVIP creates a private virtual IP in the network identified by networkID
+func (c *Client) CreateVIP(networkID string, name string) (*model.VIP, error) {
+	out, err := c.EC2.CreateNetworkInterface(&ec2.CreateNetworkInterfaceInput{
+		SubnetId:                       aws.String(networkID),
+		Description:                    aws.String("VIP " + name),
+		Groups:                         []*string{aws.String(c.SecurityGroupID)},
+		SecondaryPrivateIpAddressCount: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error creating VIP '%s'", name), err)
+	}
+	ni := out.NetworkInterface
+	ip := ""
+	for _, a := range ni.PrivateIpAddresses {
+		if !aws.BoolValue(a.Primary) {
+			ip = pStr(a.PrivateIpAddress)
+			break
+		}
+	}
+	if ip == "" {
+		_, derr := c.EC2.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
+			NetworkInterfaceId: ni.NetworkInterfaceId,
+		})
+		if derr != nil {
+			log.Errorf("Failed to delete network interface of VIP '%s': %v", name, derr)
+		}
+		return nil, fmt.Errorf("Error creating VIP '%s': no IP address allocated", name)
+	}
+	err = c.setTags(pStr(ni.NetworkInterfaceId), map[string]string{nameTag: name})
+	if err != nil {
+		log.Warnf("Failed to name network interface of VIP '%s': %v", name, err)
+	}
+	return &model.VIP{
+		ID:        pStr(ni.NetworkInterfaceId),
+		Name:      name,
+		NetworkID: networkID,
+		PrivateIP: ip,
+	}, nil
+}
+
+// getHostInterface returns the network interface of the host identified by hostID in the network
+// identified by networkID
+func (c *Client) getHostInterface(hostID string, networkID string) (*ec2.InstanceNetworkInterface, error) {
+	instance, err := c.getInstance(hostID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ni := range instance.NetworkInterfaces {
+		if pStr(ni.SubnetId) == networkID {
+			return ni, nil
+		}
+	}
+	return nil, fmt.Errorf("host '%s' isn't connected to network '%s'", hostID, networkID)
+}
+
+// assignVIP moves the address of the VIP to the network interface identified by interfaceID
+func (c *Client) assignVIP(vip *model.VIP, interfaceID *string) error {
+	_, err := c.EC2.AssignPrivateIpAddresses(&ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: interfaceID,
+		PrivateIpAddresses: []*string{aws.String(vip.PrivateIP)},
+		AllowReassignment:  aws.Bool(true),
+	})
+	return err
+}
+
+// BindHostToVIP moves the VIP to the host identified by hostID
+func (c *Client) BindHostToVIP(vip *model.VIP, hostID string) error {
+	ni, err := c.getHostInterface(hostID, vip.NetworkID)
+	if err != nil {
+		return wrapError(fmt.Sprintf("Error binding host '%s' to VIP '%s'", hostID, vip.Name), err)
+	}
+	for _, a := range ni.PrivateIpAddresses {
+		if pStr(a.PrivateIpAddress) == vip.PrivateIP {
+			return nil
+		}
+	}
+	err = c.assignVIP(vip, ni.NetworkInterfaceId)
+	return wrapError(fmt.Sprintf("Error binding host '%s' to VIP '%s'", hostID, vip.Name), err)
+}
+
+// UnbindHostFromVIP gives back the VIP to its network interface if the host identified by hostID holds it
+func (c *Client) UnbindHostFromVIP(vip *model.VIP, hostID string) error {
+	ni, err := c.getHostInterface(hostID, vip.NetworkID)
+	if err != nil {
+		return wrapError(fmt.Sprintf("Error unbinding host '%s' from VIP '%s'", hostID, vip.Name), err)
+	}
+	for _, a := range ni.PrivateIpAddresses {
+		if pStr(a.PrivateIpAddress) == vip.PrivateIP {
+			err = c.assignVIP(vip, aws.String(vip.ID))
+			return wrapError(fmt.Sprintf("Error unbinding host '%s' from VIP '%s'", hostID, vip.Name), err)
+		}
+	}
+	return nil
+}
+
+// DeleteVIP deletes the VIP
+func (c *Client) DeleteVIP(vip *model.VIP) error {
+	for _, h := range vip.Hosts {
+		err := c.UnbindHostFromVIP(vip, h)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := c.EC2.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
+		NetworkInterfaceId: aws.String(vip.ID),
+	})
+	return wrapError(fmt.Sprintf("Error deleting VIP '%s'", vip.Name), err)
+}
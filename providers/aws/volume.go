@@ -0,0 +1,269 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeState"
+)
+
+// A volume attachment is identified by the ID of the volume, an EBS volume being attached to one host at most
+
+// toVolumeType returns the EBS volume type corresponding to speed
+func toVolumeType(speed VolumeSpeed.Enum) string {
+	switch speed {
+	case VolumeSpeed.COLD:
+		return ec2.VolumeTypeSc1
+	case VolumeSpeed.SSD:
+		return ec2.VolumeTypeGp2
+	}
+	return ec2.VolumeTypeStandard
+}
+
+// toVolumeSpeed returns the speed of the EBS volume type t
+func toVolumeSpeed(t *string) VolumeSpeed.Enum {
+	switch pStr(t) {
+	case ec2.VolumeTypeSc1:
+		return VolumeSpeed.COLD
+	case ec2.VolumeTypeGp2, ec2.VolumeTypeIo1:
+		return VolumeSpeed.SSD
+	}
+	return VolumeSpeed.HDD
+}
+
+// toVolumeState converts the state of an EBS volume into VolumeState
+func toVolumeState(s *string) VolumeState.Enum {
+	switch pStr(s) {
+	case ec2.VolumeStateCreating:
+		return VolumeState.CREATING
+	case ec2.VolumeStateAvailable:
+		return VolumeState.AVAILABLE
+	case ec2.VolumeStateInUse:
+		return VolumeState.USED
+	case ec2.VolumeStateDeleting, ec2.VolumeStateDeleted:
+		return VolumeState.DELETING
+	case ec2.VolumeStateError:
+		return VolumeState.ERROR
+	}
+	return VolumeState.OTHER
+}
+
+// toModelVolume converts an EBS volume into model.Volume
+func toModelVolume(v *ec2.Volume) *model.Volume {
+	volume := model.NewVolume()
+	volume.ID = pStr(v.VolumeId)
+	volume.Name = getTag(v.Tags, nameTag)
+	volume.Size = int(aws.Int64Value(v.Size))
+	volume.Speed = toVolumeSpeed(v.VolumeType)
+	volume.State = toVolumeState(v.State)
+	return volume
+}
+
+// CreateVolume creates a block volume
+// - name is the name of the volume
+// - size is the size of the volume in GB
+// - volumeType is the type of volume to create, if volumeType is empty the driver use a default type
+func (c *Client) CreateVolume(request model.VolumeRequest) (*model.Volume, error) {
+	// Check if a volume already exists with the same name
+	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{filter("tag:"+nameTag, request.Name)},
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error creating volume '%s'", request.Name), err)
+	}
+	if len(out.Volumes) > 0 {
+		return nil, model.ResourceAlreadyExistsError("volume", request.Name)
+	}
+
+	v, err := c.EC2.CreateVolume(&ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String(c.Opts.AvailabilityZone),
+		Size:             aws.Int64(int64(request.Size)),
+		VolumeType:       aws.String(toVolumeType(request.Speed)),
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error creating volume '%s'", request.Name), err)
+	}
+	err = c.setTags(pStr(v.VolumeId), map[string]string{nameTag: request.Name})
+	if err != nil {
+		derr := c.DeleteVolume(pStr(v.VolumeId))
+		if derr != nil {
+			log.Errorf("Failed to delete volume '%s': %v", request.Name, derr)
+		}
+		return nil, wrapError(fmt.Sprintf("Error creating volume '%s'", request.Name), err)
+	}
+	volume := toModelVolume(v)
+	volume.Name = request.Name
+	return volume, nil
+}
+
+// GetVolume returns the volume identified by id
+func (c *Client) GetVolume(id string) (*model.Volume, error) {
+	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
+		VolumeIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		if isNotFound(err, "InvalidVolume") {
+			return nil, model.ResourceNotFoundError("volume", id)
+		}
+		return nil, wrapError(fmt.Sprintf("Error getting volume '%s'", id), err)
+	}
+	if len(out.Volumes) == 0 {
+		return nil, model.ResourceNotFoundError("volume", id)
+	}
+	return toModelVolume(out.Volumes[0]), nil
+}
+
+// ListVolumes list available volumes, the ones created by SafeScale being named
+func (c *Client) ListVolumes() ([]model.Volume, error) {
+	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{
+			filter("availability-zone", c.Opts.AvailabilityZone),
+			filter("tag-key", nameTag),
+		},
+	})
+	if err != nil {
+		return nil, wrapError("Error listing volumes", err)
+	}
+	volumes := []model.Volume{}
+	for _, v := range out.Volumes {
+		volumes = append(volumes, *toModelVolume(v))
+	}
+	return volumes, nil
+}
+
+// DeleteVolume deletes the volume identified by id
+func (c *Client) DeleteVolume(id string) error {
+	_, err := c.EC2.DeleteVolume(&ec2.DeleteVolumeInput{
+		VolumeId: aws.String(id),
+	})
+	if err != nil && isNotFound(err, "InvalidVolume") {
+		return model.ResourceNotFoundError("volume", id)
+	}
+	return wrapError(fmt.Sprintf("Error deleting volume '%s'", id), err)
+}
+
+const (
+	// firstDevice and lastDevice are the letters of the devices of the volumes, /dev/xvdf to /dev/xvdz as advised by AWS
+	firstDevice = 'f'
+	lastDevice  = 'z'
+)
+
+// selectDevice returns the first device name not used by the instance, in /dev/xvdf to /dev/xvdz as advised by AWS
+func selectDevice(instance *ec2.Instance) (string, error) {
+	used := map[string]bool{}
+	for _, bdm := range instance.BlockDeviceMappings {
+		used[pStr(bdm.DeviceName)] = true
+	}
+	for l := firstDevice; l <= lastDevice; l++ {
+		device := fmt.Sprintf("/dev/xvd%c", l)
+		if !used[device] && !used[fmt.Sprintf("/dev/sd%c", l)] {
+			return device, nil
+		}
+	}
+	return "", fmt.Errorf("no more device available")
+}
+
+// CreateVolumeAttachment attaches a volume to an host
+// - 'name' of the volume attachment
+// - 'volume' to attach
+// - 'host' on which the volume is attached
+func (c *Client) CreateVolumeAttachment(request model.VolumeAttachmentRequest) (string, error) {
+	instance, err := c.getInstance(request.HostID)
+	if err != nil {
+		return "", err
+	}
+	device, err := selectDevice(instance)
+	if err != nil {
+		return "", fmt.Errorf("Error attaching volume '%s' to host '%s': %s", request.VolumeID, request.HostID, err.Error())
+	}
+	va, err := c.EC2.AttachVolume(&ec2.AttachVolumeInput{
+		Device:     aws.String(device),
+		InstanceId: aws.String(request.HostID),
+		VolumeId:   aws.String(request.VolumeID),
+	})
+	if err != nil {
+		return "", wrapError(fmt.Sprintf("Error attaching volume '%s' to host '%s'", request.VolumeID, request.HostID), err)
+	}
+	return pStr(va.VolumeId), nil
+}
+
+// toModelVolumeAttachment converts an EBS volume attachment into model.VolumeAttachment
+func toModelVolumeAttachment(va *ec2.VolumeAttachment) *model.VolumeAttachment {
+	return &model.VolumeAttachment{
+		ID:       pStr(va.VolumeId),
+		Device:   pStr(va.Device),
+		ServerID: pStr(va.InstanceId),
+		VolumeID: pStr(va.VolumeId),
+	}
+}
+
+// GetVolumeAttachment returns the volume attachment identified by id
+func (c *Client) GetVolumeAttachment(serverID, id string) (*model.VolumeAttachment, error) {
+	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
+		VolumeIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		if isNotFound(err, "InvalidVolume") {
+			return nil, model.ResourceNotFoundError("volume", id)
+		}
+		return nil, wrapError(fmt.Sprintf("Error getting volume attachment '%s'", id), err)
+	}
+	for _, v := range out.Volumes {
+		for _, va := range v.Attachments {
+			if pStr(va.InstanceId) == serverID {
+				return toModelVolumeAttachment(va), nil
+			}
+		}
+	}
+	return nil, model.ResourceNotFoundError("volume attachment", id)
+}
+
+// ListVolumeAttachments lists available volume attachment
+func (c *Client) ListVolumeAttachments(serverID string) ([]model.VolumeAttachment, error) {
+	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{filter("attachment.instance-id", serverID)},
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error listing volume attachments of host '%s'", serverID), err)
+	}
+	vas := []model.VolumeAttachment{}
+	for _, v := range out.Volumes {
+		for _, va := range v.Attachments {
+			if pStr(va.InstanceId) == serverID {
+				vas = append(vas, *toModelVolumeAttachment(va))
+			}
+		}
+	}
+	return vas, nil
+}
+
+// DeleteVolumeAttachment deletes the volume attachment identifed by id
+func (c *Client) DeleteVolumeAttachment(serverID, id string) error {
+	_, err := c.EC2.DetachVolume(&ec2.DetachVolumeInput{
+		InstanceId: aws.String(serverID),
+		VolumeId:   aws.String(id),
+	})
+	return wrapError(fmt.Sprintf("Error detaching volume '%s' from host '%s'", id, serverID), err)
+}
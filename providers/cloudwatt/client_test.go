@@ -106,6 +106,12 @@ func Test_VolumeAttachment(t *testing.T) {
 	tt.VolumeAttachment(t)
 }
 
+func Test_SecurityGroups(t *testing.T) {
+	tt, err := getClient()
+	require.Nil(t, err)
+	tt.SecurityGroups(t)
+}
+
 func Test_Containers(t *testing.T) {
 	tt, err := getClient()
 	require.Nil(t, err)
@@ -25,6 +25,7 @@ import (
 	"github.com/CS-SI/SafeScale/providers/api"
 	"github.com/CS-SI/SafeScale/providers/model"
 	"github.com/CS-SI/SafeScale/providers/objectstorage"
+	"github.com/CS-SI/SafeScale/providers/userdata"
 )
 
 var (
@@ -103,6 +104,11 @@ func GetService(tenantName string) (*Service, error) {
 		_, tenantObjectStorageFound := tenant["objectstorage"]
 		_, tenantMetadataFound := tenant["metadata"]
 
+		userData, err := getUserData(tenantCompute)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating tenant '%s' on provider '%s': %s", tenantName, provider, err.Error())
+		}
+
 		// Initializes Provider
 		clientAPI, err := client.Build(tenantClient)
 		if err != nil {
@@ -129,12 +135,19 @@ func GetService(tenantName string) (*Service, error) {
 		}
 
 		// Initializes Metadata Object Storage (may be different than the Object Storage)
-		var metadataBucket objectstorage.Bucket
+		var (
+			metadataBucket objectstorage.Bucket
+			metadataKeys   []string
+		)
 		if tenantMetadataFound || tenantObjectStorageFound {
 			metadataLocationConfig, err := fillMetadataObjectStorageConfig(tenant)
 			if err != nil {
 				return nil, err
 			}
+			metadataKeys, err = getMetadataKeys(tenant)
+			if err != nil {
+				return nil, err
+			}
 			metadataLocation, err := objectstorage.NewLocation(metadataLocationConfig)
 			if err != nil {
 				return nil, fmt.Errorf("Error connecting to Object Storage Location to store metadata: %s", err.Error())
@@ -165,6 +178,8 @@ func GetService(tenantName string) (*Service, error) {
 			ClientAPI:      clientAPI,
 			ObjectStorage:  objectStorageLocation,
 			MetadataBucket: metadataBucket,
+			MetadataKeys:   metadataKeys,
+			UserData:       userData,
 		}, nil
 	}
 
@@ -174,6 +189,71 @@ func GetService(tenantName string) (*Service, error) {
 	return nil, model.ResourceNotFoundError("Client builder", clientProvider)
 }
 
+// getMetadataKeys returns the keys used to encrypt metadata, read from 'metadata' section: 'CryptKey' first, then
+// the keys of 'PreviousCryptKeys' (still accepted to decrypt during a key rotation)
+func getMetadataKeys(tenant map[string]interface{}) ([]string, error) {
+	metadata, _ := tenant["metadata"].(map[string]interface{})
+	cryptKey, _ := metadata["CryptKey"].(string)
+	anon, found := metadata["PreviousCryptKeys"]
+	if cryptKey == "" {
+		if found {
+			return nil, fmt.Errorf("setting 'PreviousCryptKeys' in 'metadata' section needs setting 'CryptKey'")
+		}
+		return nil, nil
+	}
+	keys := []string{cryptKey}
+	if found {
+		var previous []interface{}
+		switch list := anon.(type) {
+		case []interface{}:
+			previous = list
+		case []string:
+			for _, item := range list {
+				previous = append(previous, item)
+			}
+		default:
+			return nil, fmt.Errorf("setting 'PreviousCryptKeys' in 'metadata' section must be a list of strings")
+		}
+		for _, item := range previous {
+			key, ok := item.(string)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("setting 'PreviousCryptKeys' in 'metadata' section must be a list of non-empty strings")
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// getUserData returns the default cloud-init parts of the tenant, read from 'UserData' in 'compute' section:
+// a cloud-config document or a shell script, or a list of them
+func getUserData(compute map[string]interface{}) ([]string, error) {
+	var parts []string
+	switch anon := compute["UserData"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		parts = []string{anon}
+	case []string:
+		parts = anon
+	case []interface{}:
+		for _, item := range anon {
+			part, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("setting 'UserData' in 'compute' section must be a string or a list of strings")
+			}
+			parts = append(parts, part)
+		}
+	default:
+		return nil, fmt.Errorf("setting 'UserData' in 'compute' section must be a string or a list of strings")
+	}
+	err := userdata.CheckParts(parts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid setting 'UserData' in 'compute' section: %s", err.Error())
+	}
+	return parts, nil
+}
+
 // fillObjectStorageConfig initializes objectstorage.Config struct with map
 func fillObjectStorageConfig(tenant map[string]interface{}) (objectstorage.Config, error) {
 	var (
@@ -233,6 +313,8 @@ func fillObjectStorageConfig(tenant map[string]interface{}) (objectstorage.Confi
 		config.Region, _ = compute["Region"].(string)
 	}
 
+	config.Path, _ = objectstorage["Path"].(string)
+
 	return config, nil
 }
 
@@ -332,6 +414,10 @@ func fillMetadataObjectStorageConfig(tenant map[string]interface{}) (objectstora
 		}
 	}
 
+	if config.Path, ok = metadata["Path"].(string); !ok {
+		config.Path, _ = objectstorage["Path"].(string)
+	}
+
 	return config, nil
 }
 
@@ -0,0 +1,316 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/FloatingIPModel"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/objectstorage"
+)
+
+const (
+	// ShellHostMode runs the hosts as no-op shells: each host is reachable with SSH on a loopback address, the
+	// commands succeed without doing anything
+	ShellHostMode = "shell"
+	// ContainerHostMode runs the hosts as local docker containers
+	ContainerHostMode = "container"
+
+	defaultRegion  = "local"
+	defaultSSHPort = 22
+)
+
+// AuthOptions contains the identity of the fake tenant
+type AuthOptions struct {
+	// TenantName identifies the fake tenant; the clients built with the same name in a process share the same
+	// resources
+	TenantName string
+}
+
+// CfgOptions configures the behavior of the fake tenant
+type CfgOptions struct {
+	// Region is the name of the only availability zone
+	Region string
+	// HostMode is ShellHostMode (default) or ContainerHostMode
+	HostMode string
+	// ContainerImage is the docker image running the hosts in ContainerHostMode; it must start sshd and provide sudo
+	ContainerImage string
+	// SSHPort is the port of the SSH servers of the hosts in ShellHostMode
+	SSHPort int
+	// StateFile is the file keeping the resources of the tenant, to share them between processes (brokerd,
+	// deploy, perform...); the resources are only kept in memory if empty
+	StateFile string
+	// Latency is the time spent by each call to the provider
+	Latency time.Duration
+	// Failures contains the failure rates (from 0 to 1) of the calls, indexed by method name ("*" for any method)
+	Failures map[string]float64
+	// DNSList contains the DNS servers of the networks
+	DNSList []string
+	// MetadataBucket contains the name of the bucket storing metadata
+	MetadataBucket string
+}
+
+// ErrInjectedFailure is the error returned by a call failing because of the failure rates configured
+type ErrInjectedFailure struct {
+	Method string
+}
+
+func (e ErrInjectedFailure) Error() string {
+	return fmt.Sprintf("fake failure of %s", e.Method)
+}
+
+// injector applies the latency and the failures configured to the calls
+type injector struct {
+	lock     sync.RWMutex
+	latency  time.Duration
+	failures map[string]float64
+}
+
+// call waits the latency and returns an ErrInjectedFailure if method has to fail
+func (i *injector) call(method string) error {
+	i.lock.RLock()
+	latency := i.latency
+	rate, ok := i.failures[method]
+	if !ok {
+		rate = i.failures["*"]
+	}
+	i.lock.RUnlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if rate > 0 && rand.Float64() < rate {
+		return ErrInjectedFailure{Method: method}
+	}
+	return nil
+}
+
+// Client is the implementation of the fake driver regarding to the api.ClientAPI
+// The resources are kept in memory (and in CfgOptions.StateFile if set), the hosts are run as no-op shells or
+// local containers, allowing to exercise SafeScale without any cloud
+type Client struct {
+	Opts AuthOptions
+	Cfg  CfgOptions
+
+	cloud    *cloud
+	injector *injector
+}
+
+// AuthenticatedClient returns a client of the fake tenant
+func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
+	if opts.TenantName == "" {
+		opts.TenantName = "fake"
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	switch cfg.HostMode {
+	case "":
+		cfg.HostMode = ShellHostMode
+	case ShellHostMode:
+	case ContainerHostMode:
+		if cfg.ContainerImage == "" {
+			return nil, fmt.Errorf("missing 'ContainerImage' with host mode '%s'", ContainerHostMode)
+		}
+	default:
+		return nil, fmt.Errorf("invalid host mode '%s', must be '%s' or '%s'", cfg.HostMode, ShellHostMode, ContainerHostMode)
+	}
+	if cfg.SSHPort == 0 {
+		cfg.SSHPort = defaultSSHPort
+	}
+	if len(cfg.DNSList) == 0 {
+		cfg.DNSList = []string{"1.1.1.1"}
+	}
+	if cfg.MetadataBucket == "" {
+		cfg.MetadataBucket = metadata.BuildMetadataBucketName(opts.TenantName)
+	}
+	failures := map[string]float64{}
+	for method, rate := range cfg.Failures {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid failure rate %v of '%s', must be between 0 and 1", rate, method)
+		}
+		failures[method] = rate
+	}
+
+	c, err := getCloud(opts.TenantName, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Opts:     opts,
+		Cfg:      cfg,
+		cloud:    c,
+		injector: &injector{latency: cfg.Latency, failures: failures},
+	}, nil
+}
+
+// Build build a new Client from configuration parameter
+func (client *Client) Build(params map[string]interface{}) (api.ClientAPI, error) {
+	identity, _ := params["identity"].(map[string]interface{})
+	compute, _ := params["compute"].(map[string]interface{})
+	network, _ := params["network"].(map[string]interface{})
+
+	tenantName, _ := identity["TenantName"].(string)
+
+	region, _ := compute["Region"].(string)
+	hostMode, _ := compute["HostMode"].(string)
+	containerImage, _ := compute["ContainerImage"].(string)
+	stateFile, _ := compute["StateFile"].(string)
+	sshPort, err := toInt(compute["SSHPort"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'SSHPort': %s", err.Error())
+	}
+	var latency time.Duration
+	if anon, ok := compute["Latency"]; ok {
+		value, _ := anon.(string)
+		latency, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'Latency' '%v', must be a duration like '100ms'", anon)
+		}
+	}
+	failures := map[string]float64{}
+	if anon, ok := compute["Failures"]; ok {
+		rates, ok := anon.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid 'Failures', must be a table of failure rates indexed by method")
+		}
+		for method, rate := range rates {
+			failures[method], err = toFloat(rate)
+			if err != nil {
+				return nil, fmt.Errorf("invalid failure rate of '%s': %s", method, err.Error())
+			}
+		}
+	}
+
+	var dnsList []string
+	if list, ok := network["DNSList"].([]interface{}); ok {
+		for _, v := range list {
+			dnsList = append(dnsList, fmt.Sprint(v))
+		}
+	}
+
+	return AuthenticatedClient(
+		AuthOptions{
+			TenantName: tenantName,
+		},
+		CfgOptions{
+			Region:         region,
+			HostMode:       hostMode,
+			ContainerImage: containerImage,
+			SSHPort:        sshPort,
+			StateFile:      stateFile,
+			Latency:        latency,
+			Failures:       failures,
+			DNSList:        dnsList,
+		},
+	)
+}
+
+// toInt converts a value read from configuration to int, 0 if missing
+func toInt(anon interface{}) (int, error) {
+	switch v := anon.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("'%v' is not a number", anon)
+	}
+}
+
+// toFloat converts a value read from configuration to float64
+func toFloat(anon interface{}) (float64, error) {
+	switch v := anon.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("'%v' is not a number", anon)
+	}
+}
+
+// SetLatency changes the time spent by each call to the provider
+func (client *Client) SetLatency(latency time.Duration) {
+	client.injector.lock.Lock()
+	defer client.injector.lock.Unlock()
+	client.injector.latency = latency
+}
+
+// SetFailure changes the failure rate (from 0 to 1) of the calls to method ("*" for any method without rate of
+// its own)
+func (client *Client) SetFailure(method string, rate float64) {
+	client.injector.lock.Lock()
+	defer client.injector.lock.Unlock()
+	if rate <= 0 {
+		delete(client.injector.failures, method)
+		return
+	}
+	client.injector.failures[method] = rate
+}
+
+// GetAuthOpts returns the auth options
+func (client *Client) GetAuthOpts() (model.Config, error) {
+	cfg := model.ConfigMap{}
+
+	cfg.Set("TenantName", client.Opts.TenantName)
+	cfg.Set("Region", client.Cfg.Region)
+	return cfg, nil
+}
+
+// GetCfgOpts return configuration parameters
+func (client *Client) GetCfgOpts() (model.Config, error) {
+	cfg := model.ConfigMap{}
+
+	cfg.Set("DNSList", client.Cfg.DNSList)
+	cfg.Set("AutoHostNetworkInterfaces", true)
+	cfg.Set("UseLayer3Networking", true)
+	cfg.Set("ProviderNetwork", "")
+	cfg.Set("MetadataBucket", client.Cfg.MetadataBucket)
+	cfg.Set("HostMode", client.Cfg.HostMode)
+
+	return cfg, nil
+}
+
+// GetCapabilities returns what the provider supports
+func (client *Client) GetCapabilities() model.Capabilities {
+	return model.Capabilities{
+		VolumeSpeeds:    []VolumeSpeed.Enum{VolumeSpeed.COLD, VolumeSpeed.HDD, VolumeSpeed.SSD},
+		GPU:             true,
+		FloatingIP:      FloatingIPModel.FLOATING,
+		PrivateNetworks: true,
+		ObjectStorage:   objectstorage.LocalType,
+	}
+}
+
+func init() {
+	providers.Register("fake", &Client{})
+}
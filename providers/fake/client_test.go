@@ -0,0 +1,424 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/fake"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/FloatingIPModel"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostState"
+	"github.com/CS-SI/SafeScale/providers/model/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeState"
+	"github.com/CS-SI/SafeScale/providers/objectstorage"
+	"github.com/CS-SI/SafeScale/providers/tests"
+	"github.com/CS-SI/SafeScale/system"
+)
+
+// getClient returns a client of a tenant of its own for the test, so the tests don't share resources
+func getClient(t *testing.T, cfg fake.CfgOptions) *fake.Client {
+	client, err := fake.AuthenticatedClient(fake.AuthOptions{TenantName: t.Name()}, cfg)
+	require.Nil(t, err)
+	return client
+}
+
+func getTester(t *testing.T) *tests.ClientTester {
+	return &tests.ClientTester{
+		Service: providers.Service{ClientAPI: getClient(t, fake.CfgOptions{})},
+	}
+}
+
+// freePort returns a local port not used at the time of the call
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// createNetwork creates a network with its gateway
+func createNetwork(t *testing.T, service *providers.Service, name string) (*model.Network, *model.Host, *model.KeyPair) {
+	network, err := service.CreateNetwork(model.NetworkRequest{
+		Name:      name,
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.10.0/24",
+	})
+	require.Nil(t, err)
+	tpls, err := service.SelectTemplatesBySize(model.SizingRequirements{MinCores: 1, MinRAMSize: 2}, false)
+	require.Nil(t, err)
+	img, err := service.SearchImage("Ubuntu 16.04")
+	require.Nil(t, err)
+	kp, err := service.CreateKeyPair("kp_" + name)
+	require.Nil(t, err)
+	gw, err := service.CreateGateway(model.GatewayRequest{
+		ImageID:    img.ID,
+		TemplateID: tpls[0].ID,
+		KeyPair:    kp,
+		Network:    network,
+	})
+	require.Nil(t, err)
+	return network, gw, kp
+}
+
+// createHost creates a private host in network, behind its gateway
+func createHost(t *testing.T, service *providers.Service, name string, network *model.Network, gw *model.Host, kp *model.KeyPair) *model.Host {
+	img, err := service.SearchImage("Ubuntu 16.04")
+	require.Nil(t, err)
+	host, err := service.CreateHost(model.HostRequest{
+		ResourceName:   name,
+		ImageID:        img.ID,
+		TemplateID:     "fake-tpl-s1-2",
+		KeyPair:        kp,
+		Networks:       []*model.Network{network},
+		DefaultGateway: gw,
+	})
+	require.Nil(t, err)
+	return host
+}
+
+func Test_ListImages(t *testing.T) {
+	getTester(t).ListImages(t)
+}
+
+func Test_ListHostTemplates(t *testing.T) {
+	getTester(t).ListHostTemplates(t)
+}
+
+func Test_CreateKeyPair(t *testing.T) {
+	getTester(t).CreateKeyPair(t)
+}
+
+func Test_GetKeyPair(t *testing.T) {
+	getTester(t).GetKeyPair(t)
+}
+
+func Test_ListKeyPairs(t *testing.T) {
+	getTester(t).ListKeyPairs(t)
+}
+
+func Test_Networks(t *testing.T) {
+	getTester(t).Networks(t)
+}
+
+func Test_Volume(t *testing.T) {
+	getTester(t).Volume(t)
+}
+
+func Test_SecurityGroups(t *testing.T) {
+	getTester(t).SecurityGroups(t)
+}
+
+func Test_Hosts(t *testing.T) {
+	service := &providers.Service{ClientAPI: getClient(t, fake.CfgOptions{SSHPort: freePort(t)})}
+	network, gw, kp := createNetwork(t, service, "net")
+
+	network, err := service.GetNetwork(network.ID)
+	require.Nil(t, err)
+	assert.Equal(t, gw.ID, network.GatewayID)
+	assert.Equal(t, "gw-net", gw.Name)
+	assert.NotEmpty(t, gw.GetPublicIP())
+	assert.Equal(t, "192.168.10.2", gw.GetPrivateIP())
+
+	host := createHost(t, service, "host", network, gw, kp)
+	assert.Equal(t, "192.168.10.3", host.GetPrivateIP())
+	assert.Empty(t, host.GetPublicIP())
+	assert.NotEmpty(t, host.PrivateKey)
+
+	_, err = service.CreateHost(model.HostRequest{
+		ResourceName:   "host",
+		ImageID:        "fake-image-ubuntu-1604",
+		TemplateID:     "fake-tpl-s1-2",
+		Networks:       []*model.Network{network},
+		DefaultGateway: gw,
+	})
+	assert.NotNil(t, err)
+
+	hosts, err := service.ListHosts()
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(hosts))
+
+	h, err := service.GetHostByName("host")
+	require.Nil(t, err)
+	assert.Equal(t, host.ID, h.ID)
+	assert.Equal(t, HostState.STARTED, h.LastState)
+
+	err = service.StopHost(host.ID)
+	require.Nil(t, err)
+	err = service.WaitHostState(host.ID, HostState.STOPPED, 5*time.Second)
+	require.Nil(t, err)
+	err = service.StartHost(host.ID)
+	require.Nil(t, err)
+	err = service.WaitHostState(host.ID, HostState.STARTED, 5*time.Second)
+	require.Nil(t, err)
+
+	err = service.DeleteNetwork(network.ID)
+	assert.NotNil(t, err)
+	err = service.DeleteHost(host.ID)
+	require.Nil(t, err)
+	_, err = service.GetHost(host.ID)
+	assert.NotNil(t, err)
+	err = service.DeleteGateway(gw.ID)
+	require.Nil(t, err)
+	err = service.DeleteNetwork(network.ID)
+	require.Nil(t, err)
+}
+
+func Test_VolumeAttachment(t *testing.T) {
+	service := &providers.Service{ClientAPI: getClient(t, fake.CfgOptions{SSHPort: freePort(t)})}
+	network, gw, kp := createNetwork(t, service, "net")
+	host := createHost(t, service, "host", network, gw, kp)
+
+	v1, err := service.CreateVolume(model.VolumeRequest{Name: "v1", Size: 10})
+	require.Nil(t, err)
+	v2, err := service.CreateVolume(model.VolumeRequest{Name: "v2", Size: 20})
+	require.Nil(t, err)
+
+	id1, err := service.CreateVolumeAttachment(model.VolumeAttachmentRequest{Name: "a1", HostID: host.ID, VolumeID: v1.ID})
+	require.Nil(t, err)
+	assert.Equal(t, v1.ID, id1)
+	id2, err := service.CreateVolumeAttachment(model.VolumeAttachmentRequest{Name: "a2", HostID: host.ID, VolumeID: v2.ID})
+	require.Nil(t, err)
+	_, err = service.CreateVolumeAttachment(model.VolumeAttachmentRequest{Name: "a3", HostID: gw.ID, VolumeID: v2.ID})
+	assert.NotNil(t, err)
+
+	va, err := service.GetVolumeAttachment(host.ID, id1)
+	require.Nil(t, err)
+	assert.Equal(t, "/dev/vdb", va.Device)
+	vas, err := service.ListVolumeAttachments(host.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(vas))
+	assert.Equal(t, "/dev/vdc", vas[1].Device)
+
+	v, err := service.GetVolume(v1.ID)
+	require.Nil(t, err)
+	assert.Equal(t, VolumeState.USED, v.State)
+	err = service.DeleteVolume(v1.ID)
+	assert.NotNil(t, err)
+
+	err = service.DeleteVolumeAttachment(host.ID, id1)
+	require.Nil(t, err)
+	v, err = service.GetVolume(v1.ID)
+	require.Nil(t, err)
+	assert.Equal(t, VolumeState.AVAILABLE, v.State)
+	err = service.DeleteVolume(v1.ID)
+	require.Nil(t, err)
+
+	// Deleting the host detaches its volumes
+	err = service.DeleteHost(host.ID)
+	require.Nil(t, err)
+	v, err = service.GetVolume(v2.ID)
+	require.Nil(t, err)
+	assert.Equal(t, VolumeState.AVAILABLE, v.State)
+	_, err = service.GetVolumeAttachment(host.ID, id2)
+	assert.NotNil(t, err)
+}
+
+func Test_VIP(t *testing.T) {
+	service := &providers.Service{ClientAPI: getClient(t, fake.CfgOptions{SSHPort: freePort(t)})}
+	network, gw, kp := createNetwork(t, service, "net")
+	host := createHost(t, service, "host", network, gw, kp)
+
+	vip, err := service.CreateVIP(network.ID, "vip")
+	require.Nil(t, err)
+	assert.Equal(t, "192.168.10.4", vip.PrivateIP)
+
+	err = service.BindHostToVIP(vip, host.ID)
+	require.Nil(t, err)
+	err = service.BindHostToVIP(vip, "unknown")
+	assert.NotNil(t, err)
+	err = service.UnbindHostFromVIP(vip, host.ID)
+	require.Nil(t, err)
+	err = service.DeleteVIP(vip)
+	require.Nil(t, err)
+
+	// The address of the VIP is available again
+	other := createHost(t, service, "other", network, gw, kp)
+	assert.Equal(t, "192.168.10.4", other.GetPrivateIP())
+}
+
+func Test_Capabilities(t *testing.T) {
+	client := getClient(t, fake.CfgOptions{})
+	capabilities := client.GetCapabilities()
+	assert.Equal(t, []VolumeSpeed.Enum{VolumeSpeed.COLD, VolumeSpeed.HDD, VolumeSpeed.SSD}, capabilities.VolumeSpeeds)
+	assert.True(t, capabilities.GPU)
+	assert.Equal(t, FloatingIPModel.FLOATING, capabilities.FloatingIP)
+	assert.Equal(t, 0, capabilities.MaxVolumesPerHost)
+	assert.True(t, capabilities.PrivateNetworks)
+	assert.Equal(t, objectstorage.LocalType, capabilities.ObjectStorage)
+
+	// The Object Storage is the one of the tenant
+	service := &providers.Service{ClientAPI: client}
+	assert.Equal(t, "", service.GetCapabilities().ObjectStorage)
+	location, err := objectstorage.NewLocation(objectstorage.Config{Type: objectstorage.MemoryType, Path: t.Name()})
+	require.Nil(t, err)
+	service.ObjectStorage = location
+	assert.Equal(t, objectstorage.MemoryType, service.GetCapabilities().ObjectStorage)
+}
+
+func Test_Failures(t *testing.T) {
+	client := getClient(t, fake.CfgOptions{Failures: map[string]float64{"CreateVolume": 1}})
+
+	_, err := client.CreateVolume(model.VolumeRequest{Name: "v", Size: 10})
+	require.NotNil(t, err)
+	assert.Equal(t, fake.ErrInjectedFailure{Method: "CreateVolume"}, err)
+	_, err = client.ListVolumes()
+	assert.Nil(t, err)
+
+	client.SetFailure("CreateVolume", 0)
+	_, err = client.CreateVolume(model.VolumeRequest{Name: "v", Size: 10})
+	assert.Nil(t, err)
+
+	client.SetFailure("*", 1)
+	_, err = client.ListVolumes()
+	assert.Equal(t, fake.ErrInjectedFailure{Method: "ListVolumes"}, err)
+
+	_, err = fake.AuthenticatedClient(fake.AuthOptions{}, fake.CfgOptions{Failures: map[string]float64{"*": 2}})
+	assert.NotNil(t, err)
+}
+
+func Test_Latency(t *testing.T) {
+	client := getClient(t, fake.CfgOptions{Latency: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := client.ListNetworks()
+	require.Nil(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	client.SetLatency(0)
+	start = time.Now()
+	_, err = client.ListNetworks()
+	require.Nil(t, err)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+}
+
+func Test_Build(t *testing.T) {
+	client, err := (&fake.Client{}).Build(map[string]interface{}{
+		"identity": map[string]interface{}{
+			"TenantName": t.Name(),
+		},
+		"compute": map[string]interface{}{
+			"Region":   "here",
+			"SSHPort":  int64(2222),
+			"Latency":  "10ms",
+			"Failures": map[string]interface{}{"CreateHost": 0.5},
+		},
+		"network": map[string]interface{}{
+			"DNSList": []interface{}{"8.8.8.8"},
+		},
+	})
+	require.Nil(t, err)
+	c := client.(*fake.Client)
+	assert.Equal(t, "here", c.Cfg.Region)
+	assert.Equal(t, fake.ShellHostMode, c.Cfg.HostMode)
+	assert.Equal(t, 2222, c.Cfg.SSHPort)
+	assert.Equal(t, 10*time.Millisecond, c.Cfg.Latency)
+	assert.Equal(t, 0.5, c.Cfg.Failures["CreateHost"])
+	assert.Equal(t, []string{"8.8.8.8"}, c.Cfg.DNSList)
+
+	cfg, err := client.GetCfgOpts()
+	require.Nil(t, err)
+	bucket, ok := cfg.Get("MetadataBucket")
+	assert.True(t, ok)
+	assert.NotEmpty(t, bucket)
+
+	_, err = (&fake.Client{}).Build(map[string]interface{}{
+		"compute": map[string]interface{}{"HostMode": "container"},
+	})
+	assert.NotNil(t, err)
+	_, err = (&fake.Client{}).Build(map[string]interface{}{
+		"compute": map[string]interface{}{"Latency": "soon"},
+	})
+	assert.NotNil(t, err)
+}
+
+func Test_StateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safescale-fake")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+
+	c1 := getClient(t, fake.CfgOptions{StateFile: stateFile})
+	_, err = c1.CreateVolume(model.VolumeRequest{Name: "v", Size: 10})
+	require.Nil(t, err)
+	_, err = os.Stat(stateFile)
+	require.Nil(t, err)
+
+	c2, err := fake.AuthenticatedClient(fake.AuthOptions{TenantName: "other"}, fake.CfgOptions{StateFile: stateFile})
+	require.Nil(t, err)
+	volumes, err := c2.ListVolumes()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(volumes))
+	assert.Equal(t, "v", volumes[0].Name)
+
+	// The state file is read again on each call, to see the changes of the other processes
+	err = ioutil.WriteFile(stateFile, []byte("{}"), 0600)
+	require.Nil(t, err)
+	volumes, err = c1.ListVolumes()
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(volumes))
+}
+
+func Test_Shell(t *testing.T) {
+	port := freePort(t)
+	service := &providers.Service{ClientAPI: getClient(t, fake.CfgOptions{SSHPort: port})}
+	network, gw, kp := createNetwork(t, service, "net")
+	host := createHost(t, service, "host", network, gw, kp)
+
+	gwConfig := &system.SSHConfig{
+		User:       model.DefaultUser,
+		Host:       gw.GetPublicIP(),
+		Port:       port,
+		PrivateKey: gw.PrivateKey,
+	}
+	err := gwConfig.WaitServerReady(10 * time.Second)
+	require.Nil(t, err)
+
+	// The private host is reached through the gateway
+	hostConfig := &system.SSHConfig{
+		User:          model.DefaultUser,
+		Host:          host.GetPrivateIP(),
+		Port:          port,
+		PrivateKey:    host.PrivateKey,
+		GatewayConfig: gwConfig,
+	}
+	cmd, err := hostConfig.SudoCommand("apt-get install -y nfs-common")
+	require.Nil(t, err)
+	retcode, _, _, err := cmd.Run()
+	require.Nil(t, err)
+	assert.Equal(t, 0, retcode)
+
+	f, err := ioutil.TempFile("", "safescale-fake")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("#!/bin/bash\n")
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+	retcode, _, _, err = hostConfig.Copy("/opt/safescale/script.sh", f.Name(), true)
+	require.Nil(t, err)
+	assert.Equal(t, 0, retcode)
+}
@@ -0,0 +1,532 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostState"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeState"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+	"github.com/CS-SI/SafeScale/system"
+)
+
+// images are the images of the fake tenant
+var images = []model.Image{
+	{ID: "fake-image-ubuntu-1604", Name: "Ubuntu 16.04"},
+	{ID: "fake-image-ubuntu-1804", Name: "Ubuntu 18.04"},
+	{ID: "fake-image-centos-7", Name: "CentOS 7"},
+	{ID: "fake-image-debian-9", Name: "Debian 9"},
+}
+
+// templates are the host templates of the fake tenant
+var templates = []propsv1.HostTemplate{
+	{ID: "fake-tpl-s1-2", Name: "s1-2", HostSize: &propsv1.HostSize{Cores: 1, RAMSize: 2, DiskSize: 10}},
+	{ID: "fake-tpl-s1-4", Name: "s1-4", HostSize: &propsv1.HostSize{Cores: 1, RAMSize: 4, DiskSize: 20}},
+	{ID: "fake-tpl-s1-8", Name: "s1-8", HostSize: &propsv1.HostSize{Cores: 2, RAMSize: 8, DiskSize: 40}},
+	{ID: "fake-tpl-b2-15", Name: "b2-15", HostSize: &propsv1.HostSize{Cores: 4, RAMSize: 15, DiskSize: 100}},
+	{ID: "fake-tpl-b2-30", Name: "b2-30", HostSize: &propsv1.HostSize{Cores: 8, RAMSize: 30, DiskSize: 200}},
+	{ID: "fake-tpl-g1-30", Name: "g1-30", HostSize: &propsv1.HostSize{Cores: 8, RAMSize: 30, DiskSize: 200, GPUNumber: 1, GPUType: "Fake GPU"}},
+}
+
+// ListAvailabilityZones lists the usable AvailabilityZones
+func (client *Client) ListAvailabilityZones(all bool) (map[string]bool, error) {
+	if err := client.injector.call("ListAvailabilityZones"); err != nil {
+		return nil, err
+	}
+	return map[string]bool{client.Cfg.Region: true}, nil
+}
+
+// ListImages lists available OS images
+func (client *Client) ListImages(all bool) ([]model.Image, error) {
+	if err := client.injector.call("ListImages"); err != nil {
+		return nil, err
+	}
+	return append([]model.Image{}, images...), nil
+}
+
+// GetImage returns the Image referenced by id
+func (client *Client) GetImage(id string) (*model.Image, error) {
+	if err := client.injector.call("GetImage"); err != nil {
+		return nil, err
+	}
+	return getImage(id)
+}
+
+func getImage(id string) (*model.Image, error) {
+	for _, img := range images {
+		if img.ID == id {
+			result := img
+			return &result, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("image", id)
+}
+
+// GetTemplate returns the Template referenced by id
+func (client *Client) GetTemplate(id string) (*model.HostTemplate, error) {
+	if err := client.injector.call("GetTemplate"); err != nil {
+		return nil, err
+	}
+	return getTemplate(id)
+}
+
+func getTemplate(id string) (*model.HostTemplate, error) {
+	for _, tpl := range templates {
+		if tpl.ID == id {
+			result := model.HostTemplate{HostTemplate: &propsv1.HostTemplate{}}
+			clone(&tpl, result.HostTemplate)
+			return &result, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("template", id)
+}
+
+// ListTemplates lists available host templates
+// Host templates are sorted using Dominant Resource Fairness Algorithm
+func (client *Client) ListTemplates(all bool) ([]model.HostTemplate, error) {
+	if err := client.injector.call("ListTemplates"); err != nil {
+		return nil, err
+	}
+	var list []model.HostTemplate
+	for _, tpl := range templates {
+		t, _ := getTemplate(tpl.ID)
+		list = append(list, *t)
+	}
+	return list, nil
+}
+
+// CreateKeyPair creates and import a key pair
+func (client *Client) CreateKeyPair(name string) (*model.KeyPair, error) {
+	if err := client.injector.call("CreateKeyPair"); err != nil {
+		return nil, err
+	}
+	publicKey, privateKey, err := system.CreateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key pair: %s", err.Error())
+	}
+	kp := &model.KeyPair{
+		ID:         name,
+		Name:       name,
+		PublicKey:  string(publicKey),
+		PrivateKey: string(privateKey),
+	}
+	err = client.cloud.write(func(s *state) error {
+		if _, found := s.KeyPairs[name]; found {
+			return model.ResourceAlreadyExistsError("key pair", name)
+		}
+		// The private key is only returned at creation
+		s.KeyPairs[name] = &model.KeyPair{ID: kp.ID, Name: kp.Name, PublicKey: kp.PublicKey}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// GetKeyPair returns the key pair identified by id
+func (client *Client) GetKeyPair(id string) (*model.KeyPair, error) {
+	if err := client.injector.call("GetKeyPair"); err != nil {
+		return nil, err
+	}
+	var kp *model.KeyPair
+	err := client.cloud.read(func(s *state) error {
+		found, ok := s.KeyPairs[id]
+		if !ok {
+			return model.ResourceNotFoundError("key pair", id)
+		}
+		kp = &model.KeyPair{}
+		clone(found, kp)
+		return nil
+	})
+	return kp, err
+}
+
+// ListKeyPairs lists available key pairs
+func (client *Client) ListKeyPairs() ([]model.KeyPair, error) {
+	if err := client.injector.call("ListKeyPairs"); err != nil {
+		return nil, err
+	}
+	var list []model.KeyPair
+	err := client.cloud.read(func(s *state) error {
+		for _, kp := range s.KeyPairs {
+			list = append(list, *kp)
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+// DeleteKeyPair deletes the key pair identified by id
+func (client *Client) DeleteKeyPair(id string) error {
+	if err := client.injector.call("DeleteKeyPair"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		if _, found := s.KeyPairs[id]; !found {
+			return model.ResourceNotFoundError("key pair", id)
+		}
+		delete(s.KeyPairs, id)
+		return nil
+	})
+}
+
+// CreateHost creates an host that fulfils the request
+func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error) {
+	if err := client.injector.call("CreateHost"); err != nil {
+		return nil, err
+	}
+	if request.DefaultGateway == nil && !request.PublicIP {
+		return nil, model.ResourceInvalidRequestError("host creation", "can't create a gateway without public IP")
+	}
+	if len(request.Networks) == 0 {
+		return nil, model.ResourceInvalidRequestError("host creation", "no network")
+	}
+	template, err := getTemplate(request.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	image, err := getImage(request.ImageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// If no key pair is supplied create one
+	if request.KeyPair == nil {
+		publicKey, privateKey, err := system.CreateKeyPair()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create host key pair: %s", err.Error())
+		}
+		request.KeyPair = &model.KeyPair{PublicKey: string(publicKey), PrivateKey: string(privateKey)}
+	}
+	// The SSH host key is generated here, as a real host generates it at first boot
+	publicHostKey, privateHostKey, err := system.CreateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host SSH key: %s", err.Error())
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	defaultNetworkID := request.Networks[0].ID
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	hostNetworkV1.DefaultNetworkID = defaultNetworkID
+	hostNetworkV1.IsGateway = request.DefaultGateway == nil && request.Networks[0].Name != model.SingleHostNetworkName
+	if request.DefaultGateway != nil {
+		hostNetworkV1.DefaultGatewayID = request.DefaultGateway.ID
+		hostNetworkV1.DefaultGatewayPrivateIP = request.DefaultGateway.GetPrivateIP()
+	}
+
+	var result *model.Host
+	err = client.cloud.write(func(s *state) (err error) {
+		for _, h := range s.Hosts {
+			if h.Host.Name == request.ResourceName {
+				return model.ResourceAlreadyExistsError("host", request.ResourceName)
+			}
+		}
+		var nets []*network
+		for _, n := range request.Networks {
+			net, found := s.Networks[n.ID]
+			if !found {
+				return model.ResourceNotFoundError("network", n.ID)
+			}
+			nets = append(nets, net)
+		}
+
+		// Releases the addresses allocated if the creation fails
+		defer func() {
+			if err != nil {
+				for _, net := range nets {
+					net.releaseAddresses(id)
+				}
+			}
+		}()
+		for _, net := range nets {
+			ip, err := net.allocateAddress(id)
+			if err != nil {
+				return err
+			}
+			hostNetworkV1.NetworksByID[net.Network.ID] = net.Network.Name
+			hostNetworkV1.NetworksByName[net.Network.Name] = net.Network.ID
+			hostNetworkV1.IPv4Addresses[net.Network.ID] = ip
+		}
+
+		h := &host{
+			Host:          model.NewHost(),
+			HostKey:       string(privateHostKey),
+			AuthorizedKey: request.KeyPair.PublicKey,
+		}
+		h.Host.ID = id
+		h.Host.Name = request.ResourceName
+		h.Host.LastState = HostState.STARTED
+		h.Host.PrivateKey = request.KeyPair.PrivateKey
+		h.Host.HostKey = string(publicHostKey)
+
+		switch client.Cfg.HostMode {
+		case ShellHostMode:
+			s.Counter++
+			h.Address = client.cloud.shellAddress(s.Counter)
+			if request.PublicIP {
+				hostNetworkV1.PublicIPv4 = h.Address
+			}
+		case ContainerHostMode:
+			err = client.runContainer(h, request.HostName, nets, hostNetworkV1.IPv4Addresses)
+			if err != nil {
+				return err
+			}
+			if request.PublicIP {
+				// The addresses of the containers are reachable from the local host
+				hostNetworkV1.PublicIPv4 = hostNetworkV1.IPv4Addresses[defaultNetworkID]
+			}
+		}
+
+		now := time.Now()
+		err = h.Host.Properties.Set(HostProperty.DescriptionV1, &propsv1.HostDescription{
+			Created: now,
+			Updated: now,
+			Purpose: "fake host running " + image.Name,
+		})
+		if err != nil {
+			return err
+		}
+		err = h.Host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+		if err != nil {
+			return err
+		}
+		err = h.Host.Properties.Set(HostProperty.SizingV1, &propsv1.HostSizing{
+			// Note: from there, no idea what was the RequestedSize; caller will have to complement this information
+			Template:      request.TemplateID,
+			AllocatedSize: template.HostSize,
+		})
+		if err != nil {
+			return err
+		}
+		s.Hosts[id] = h
+
+		result = model.NewHost()
+		clone(h.Host, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Host resource '%s' created successfully", request.ResourceName)
+	return result, nil
+}
+
+// GetHost returns the host identified by id or updates content of a *model.Host
+func (client *Client) GetHost(hostParam interface{}) (*model.Host, error) {
+	if err := client.injector.call("GetHost"); err != nil {
+		return nil, err
+	}
+
+	var host *model.Host
+	switch hostParam.(type) {
+	case string:
+		host = model.NewHost()
+		host.ID = hostParam.(string)
+	case *model.Host:
+		host = hostParam.(*model.Host)
+	default:
+		panic("hostParam must be a string or a *model.Host!")
+	}
+
+	err := client.cloud.read(func(s *state) error {
+		h, found := s.Hosts[host.ID]
+		if !found {
+			return model.ResourceNotFoundError("host", host.ID)
+		}
+		if host.Name == "" {
+			host.Name = h.Host.Name
+		}
+		host.LastState = h.Host.LastState
+		host.PrivateKey = h.Host.PrivateKey
+		host.HostKey = h.Host.HostKey
+		if host.Properties == nil {
+			host.Properties = model.NewExtensions()
+		}
+		// Keeps the properties already complemented by the caller
+		for _, key := range []string{HostProperty.DescriptionV1, HostProperty.NetworkV1, HostProperty.SizingV1} {
+			if !host.Properties.Lookup(key) {
+				var value interface{}
+				err := h.Host.Properties.Get(key, &value)
+				if err != nil {
+					return err
+				}
+				err = host.Properties.Set(key, value)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// GetHostByName returns the host identified by name
+func (client *Client) GetHostByName(name string) (*model.Host, error) {
+	if err := client.injector.call("GetHostByName"); err != nil {
+		return nil, err
+	}
+	var host *model.Host
+	err := client.cloud.read(func(s *state) error {
+		for _, h := range s.Hosts {
+			if h.Host.Name == name {
+				host = model.NewHost()
+				clone(h.Host, host)
+				return nil
+			}
+		}
+		return model.ResourceNotFoundError("host", name)
+	})
+	return host, err
+}
+
+// GetHostState returns the current state of the host identified by id
+func (client *Client) GetHostState(hostParam interface{}) (HostState.Enum, error) {
+	host, err := client.GetHost(hostParam)
+	if err != nil {
+		return HostState.ERROR, err
+	}
+	return host.LastState, nil
+}
+
+// ListHosts lists all hosts
+func (client *Client) ListHosts() ([]*model.Host, error) {
+	if err := client.injector.call("ListHosts"); err != nil {
+		return nil, err
+	}
+	var list []*model.Host
+	err := client.cloud.read(func(s *state) error {
+		for _, h := range s.Hosts {
+			host := model.NewHost()
+			clone(h.Host, host)
+			list = append(list, host)
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+// DeleteHost deletes the host identified by id
+// The volumes attached to the host are detached, the host is unbound from its security groups and VIPs
+func (client *Client) DeleteHost(id string) error {
+	if err := client.injector.call("DeleteHost"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		h, found := s.Hosts[id]
+		if !found {
+			return model.ResourceNotFoundError("host", id)
+		}
+		if h.Container != "" {
+			err := removeContainer(h.Container)
+			if err != nil {
+				return err
+			}
+		}
+		for volumeID, va := range s.Attachments {
+			if va.ServerID == id {
+				delete(s.Attachments, volumeID)
+				if v, ok := s.Volumes[volumeID]; ok {
+					v.State = VolumeState.AVAILABLE
+				}
+			}
+		}
+		for _, sg := range s.SecurityGroups {
+			sg.Hosts = removeID(sg.Hosts, id)
+		}
+		for _, vip := range s.VIPs {
+			vip.Hosts = removeID(vip.Hosts, id)
+		}
+		for _, n := range s.Networks {
+			n.releaseAddresses(id)
+			if n.Network.GatewayID == id {
+				n.Network.GatewayID = ""
+			}
+		}
+		delete(s.Hosts, id)
+		return nil
+	})
+}
+
+// removeID returns ids without id
+func removeID(ids []string, id string) []string {
+	result := []string{}
+	for _, i := range ids {
+		if i != id {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// StopHost stops the host identified by id
+func (client *Client) StopHost(id string) error {
+	if err := client.injector.call("StopHost"); err != nil {
+		return err
+	}
+	return client.setHostState(id, HostState.STOPPED, "stop")
+}
+
+// StartHost starts the host identified by id
+func (client *Client) StartHost(id string) error {
+	if err := client.injector.call("StartHost"); err != nil {
+		return err
+	}
+	return client.setHostState(id, HostState.STARTED, "start")
+}
+
+// RebootHost reboots the host identified by id
+func (client *Client) RebootHost(id string) error {
+	if err := client.injector.call("RebootHost"); err != nil {
+		return err
+	}
+	return client.setHostState(id, HostState.STARTED, "restart")
+}
+
+// setHostState changes the state of the host identified by id, running the docker action on its container
+func (client *Client) setHostState(id string, hostState HostState.Enum, action string) error {
+	return client.cloud.write(func(s *state) error {
+		h, found := s.Hosts[id]
+		if !found {
+			return model.ResourceNotFoundError("host", id)
+		}
+		if h.Container != "" {
+			_, err := docker(action, h.Container)
+			if err != nil {
+				return err
+			}
+		}
+		h.Host.LastState = hostState
+		return nil
+	})
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// In ContainerHostMode, each network is a docker network using the CIDR of the network, and each host is a
+// docker container connected to the docker networks of its networks with the addresses allocated to it.
+// The image of the containers must start sshd; the container is prepared by bootstrapScript, which needs
+// useradd, sudo and ssh-keygen.
+
+// bootstrapScript creates the user of SafeScale, installs the SSH host key and marks the host as ready
+var bootstrapScript = template.Must(template.New("bootstrap").Parse(`set -e
+id {{ .User }} >/dev/null 2>&1 || useradd -m -s /bin/bash {{ .User }}
+mkdir -p /home/{{ .User }}/.ssh
+cat >/home/{{ .User }}/.ssh/authorized_keys <<'EOF'
+{{ .PublicKey }}
+EOF
+chown -R {{ .User }} /home/{{ .User }}/.ssh
+chmod 0700 /home/{{ .User }}/.ssh
+chmod 0600 /home/{{ .User }}/.ssh/authorized_keys
+mkdir -p /etc/sudoers.d
+echo "{{ .User }} ALL=(ALL) NOPASSWD:ALL" >/etc/sudoers.d/{{ .User }}
+chmod 0440 /etc/sudoers.d/{{ .User }}
+rm -f /etc/ssh/ssh_host_*key /etc/ssh/ssh_host_*key.pub
+cat >/etc/ssh/ssh_host_rsa_key <<'EOF'
+{{ .HostKey }}
+EOF
+chmod 0600 /etc/ssh/ssh_host_rsa_key
+ssh-keygen -y -f /etc/ssh/ssh_host_rsa_key >/etc/ssh/ssh_host_rsa_key.pub
+pkill -HUP -x sshd || true
+mkdir -p /var/tmp
+date >/var/tmp/user_data.done
+`))
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// containerName returns the docker name of the resource named name
+func containerName(name string, id string) string {
+	return "safescale-" + invalidNameChars.ReplaceAllString(name, "-") + "-" + id[:8]
+}
+
+// docker runs the docker command with args and returns its output
+func docker(args ...string) (string, error) {
+	return dockerWithInput("", args...)
+}
+
+// dockerWithInput runs the docker command with args, input on its standard input, and returns its output
+func dockerWithInput(input string, args ...string) (string, error) {
+	cmd := exec.Command("docker", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("docker %s failed: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// createContainerNetwork creates the docker network of the network named name, and returns its docker name
+func createContainerNetwork(name string, id string, ipnet *net.IPNet) (string, error) {
+	dockerName := containerName(name, id)
+	_, err := docker("network", "create", "--driver", "bridge", "--subnet", ipnet.String(), dockerName)
+	if err != nil {
+		return "", err
+	}
+	return dockerName, nil
+}
+
+// runContainer runs the container of the host h, connected to nets with addresses (indexed by network ID)
+func (client *Client) runContainer(h *host, hostName string, nets []*network, addresses map[string]string) (err error) {
+	if hostName == "" {
+		hostName = h.Host.Name
+	}
+	name := containerName(h.Host.Name, h.Host.ID)
+	_, err = docker(
+		"run", "--detach", "--name", name, "--hostname", invalidNameChars.ReplaceAllString(hostName, "-"),
+		"--network", nets[0].Container, "--ip", addresses[nets[0].Network.ID],
+		client.Cfg.ContainerImage,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Starting from here, removes the container if exiting with error
+	defer func() {
+		if err != nil {
+			derr := removeContainer(name)
+			if derr != nil {
+				log.Warnf("Failed to remove container '%s': %v", name, derr)
+			}
+		}
+	}()
+
+	for _, n := range nets[1:] {
+		_, err = docker("network", "connect", "--ip", addresses[n.Network.ID], n.Container, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	var script bytes.Buffer
+	err = bootstrapScript.Execute(&script, map[string]string{
+		"User":      model.DefaultUser,
+		"PublicKey": strings.TrimSpace(h.AuthorizedKey),
+		"HostKey":   strings.TrimSpace(h.HostKey),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = dockerWithInput(script.String(), "exec", "--interactive", name, "sh", "-s")
+	if err != nil {
+		return fmt.Errorf("failed to prepare container of host '%s': %s", h.Host.Name, err.Error())
+	}
+	h.Container = name
+	return nil
+}
+
+// removeContainer removes the container named name, running or not
+func removeContainer(name string) error {
+	_, err := docker("rm", "--force", name)
+	return err
+}
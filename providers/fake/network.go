@@ -0,0 +1,222 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/IPVersion"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+)
+
+// CreateNetwork creates a network named name
+func (client *Client) CreateNetwork(req model.NetworkRequest) (*model.Network, error) {
+	if err := client.injector.call("CreateNetwork"); err != nil {
+		return nil, err
+	}
+	if req.IPVersion == IPVersion.IPv6 {
+		return nil, model.ResourceInvalidRequestError("network creation", "IPv6 networks are not supported")
+	}
+	_, ipnet, err := net.ParseCIDR(req.CIDR)
+	if err != nil || ipnet.IP.To4() == nil {
+		return nil, model.ResourceInvalidRequestError("network creation", fmt.Sprintf("invalid CIDR '%s'", req.CIDR))
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	var result *model.Network
+	err = client.cloud.write(func(s *state) error {
+		for _, n := range s.Networks {
+			if n.Network.Name == req.Name {
+				return model.ResourceAlreadyExistsError("network", req.Name)
+			}
+		}
+		n := &network{
+			Network:   model.NewNetwork(),
+			Addresses: map[string]string{},
+		}
+		n.Network.ID = id
+		n.Network.Name = req.Name
+		n.Network.CIDR = ipnet.String()
+		n.Network.IPVersion = IPVersion.IPv4
+		if client.Cfg.HostMode == ContainerHostMode {
+			name, err := createContainerNetwork(req.Name, id, ipnet)
+			if err != nil {
+				return err
+			}
+			n.Container = name
+		}
+		s.Networks[id] = n
+
+		result = model.NewNetwork()
+		clone(n.Network, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetNetwork returns the network identified by id
+func (client *Client) GetNetwork(id string) (*model.Network, error) {
+	if err := client.injector.call("GetNetwork"); err != nil {
+		return nil, err
+	}
+	var result *model.Network
+	err := client.cloud.read(func(s *state) error {
+		n, found := s.Networks[id]
+		if !found {
+			return model.ResourceNotFoundError("network", id)
+		}
+		result = model.NewNetwork()
+		clone(n.Network, result)
+		return nil
+	})
+	return result, err
+}
+
+// GetNetworkByName returns the network identified by name
+func (client *Client) GetNetworkByName(name string) (*model.Network, error) {
+	if err := client.injector.call("GetNetworkByName"); err != nil {
+		return nil, err
+	}
+	var result *model.Network
+	err := client.cloud.read(func(s *state) error {
+		for _, n := range s.Networks {
+			if n.Network.Name == name {
+				result = model.NewNetwork()
+				clone(n.Network, result)
+				return nil
+			}
+		}
+		return model.ResourceNotFoundError("network", name)
+	})
+	return result, err
+}
+
+// ListNetworks lists all networks
+func (client *Client) ListNetworks() ([]*model.Network, error) {
+	if err := client.injector.call("ListNetworks"); err != nil {
+		return nil, err
+	}
+	var list []*model.Network
+	err := client.cloud.read(func(s *state) error {
+		for _, n := range s.Networks {
+			network := model.NewNetwork()
+			clone(n.Network, network)
+			list = append(list, network)
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+// DeleteNetwork deletes the network identified by id
+// The network can't be deleted while hosts or VIPs use addresses in it
+func (client *Client) DeleteNetwork(id string) error {
+	if err := client.injector.call("DeleteNetwork"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		n, found := s.Networks[id]
+		if !found {
+			return model.ResourceNotFoundError("network", id)
+		}
+		if len(n.Addresses) > 0 {
+			return fmt.Errorf("network '%s' still has %d addresses in use", n.Network.Name, len(n.Addresses))
+		}
+		if n.Container != "" {
+			_, err := docker("network", "rm", n.Container)
+			if err != nil {
+				return err
+			}
+		}
+		delete(s.Networks, id)
+		return nil
+	})
+}
+
+// CreateGateway creates a public Gateway for a private network
+func (client *Client) CreateGateway(req model.GatewayRequest) (*model.Host, error) {
+	if err := client.injector.call("CreateGateway"); err != nil {
+		return nil, err
+	}
+	if req.Network == nil {
+		panic("req.Network is nil!")
+	}
+	gwname := req.Name
+	if gwname == "" {
+		gwname = "gw-" + req.Network.Name
+	}
+	host, err := client.CreateHost(model.HostRequest{
+		ImageID:      req.ImageID,
+		KeyPair:      req.KeyPair,
+		ResourceName: gwname,
+		TemplateID:   req.TemplateID,
+		Networks:     []*model.Network{req.Network},
+		PublicIP:     true,
+		UserData:     req.UserData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating gateway: %s", err.Error())
+	}
+
+	err = client.cloud.write(func(s *state) error {
+		n, found := s.Networks[req.Network.ID]
+		if !found {
+			return model.ResourceNotFoundError("network", req.Network.ID)
+		}
+		n.Network.GatewayID = host.ID
+		return nil
+	})
+	if err != nil {
+		_ = client.DeleteHost(host.ID)
+		return nil, fmt.Errorf("Error creating gateway: %s", err.Error())
+	}
+	return host, nil
+}
+
+// DeleteGateway deletes the public gateway of a private network
+func (client *Client) DeleteGateway(id string) error {
+	return client.DeleteHost(id)
+}
+
+// getHostAddress returns the address of the host identified by hostID in the network identified by networkID
+func getHostAddress(s *state, hostID string, networkID string) (string, error) {
+	h, found := s.Hosts[hostID]
+	if !found {
+		return "", model.ResourceNotFoundError("host", hostID)
+	}
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	err := h.Host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+	if err != nil {
+		return "", err
+	}
+	ip, found := hostNetworkV1.IPv4Addresses[networkID]
+	if !found || ip == "" {
+		return "", fmt.Errorf("host '%s' isn't connected to network '%s'", h.Host.Name, networkID)
+	}
+	return ip, nil
+}
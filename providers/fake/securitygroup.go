@@ -0,0 +1,199 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// The security groups are only recorded, the traffic of the hosts is never filtered
+
+// CreateSecurityGroup creates a security group, without rule
+func (client *Client) CreateSecurityGroup(request model.SecurityGroupRequest) (*model.SecurityGroup, error) {
+	if err := client.injector.call("CreateSecurityGroup"); err != nil {
+		return nil, err
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	sg := &model.SecurityGroup{
+		ID:          id,
+		Name:        request.Name,
+		Description: request.Description,
+		NetworkID:   request.NetworkID,
+	}
+	err = client.cloud.write(func(s *state) error {
+		for _, g := range s.SecurityGroups {
+			if g.Name == request.Name {
+				return model.ResourceAlreadyExistsError("security group", request.Name)
+			}
+		}
+		if request.NetworkID != "" {
+			if _, found := s.Networks[request.NetworkID]; !found {
+				return model.ResourceNotFoundError("network", request.NetworkID)
+			}
+		}
+		stored := &model.SecurityGroup{}
+		clone(sg, stored)
+		s.SecurityGroups[id] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sg, nil
+}
+
+// GetSecurityGroup returns the security group identified by id, with its rules
+func (client *Client) GetSecurityGroup(id string) (*model.SecurityGroup, error) {
+	if err := client.injector.call("GetSecurityGroup"); err != nil {
+		return nil, err
+	}
+	var result *model.SecurityGroup
+	err := client.cloud.read(func(s *state) error {
+		sg, found := s.SecurityGroups[id]
+		if !found {
+			return model.ResourceNotFoundError("security group", id)
+		}
+		result = &model.SecurityGroup{}
+		clone(sg, result)
+		return nil
+	})
+	return result, err
+}
+
+// ListSecurityGroups lists available security groups
+func (client *Client) ListSecurityGroups() ([]model.SecurityGroup, error) {
+	if err := client.injector.call("ListSecurityGroups"); err != nil {
+		return nil, err
+	}
+	var list []model.SecurityGroup
+	err := client.cloud.read(func(s *state) error {
+		for _, sg := range s.SecurityGroups {
+			group := model.SecurityGroup{}
+			clone(sg, &group)
+			list = append(list, group)
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+// DeleteSecurityGroup deletes the security group identified by id
+func (client *Client) DeleteSecurityGroup(id string) error {
+	if err := client.injector.call("DeleteSecurityGroup"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		sg, found := s.SecurityGroups[id]
+		if !found {
+			return model.ResourceNotFoundError("security group", id)
+		}
+		if len(sg.Hosts) > 0 {
+			return fmt.Errorf("security group '%s' is bound to %d hosts", sg.Name, len(sg.Hosts))
+		}
+		delete(s.SecurityGroups, id)
+		return nil
+	})
+}
+
+// AddRule adds a rule allowing incoming traffic to the security group identified by groupID
+func (client *Client) AddRule(groupID string, rule model.SecurityGroupRule) (*model.SecurityGroupRule, error) {
+	if err := client.injector.call("AddRule"); err != nil {
+		return nil, err
+	}
+	err := rule.Validate()
+	if err != nil {
+		return nil, err
+	}
+	rule.ID, err = newID()
+	if err != nil {
+		return nil, err
+	}
+	err = client.cloud.write(func(s *state) error {
+		sg, found := s.SecurityGroups[groupID]
+		if !found {
+			return model.ResourceNotFoundError("security group", groupID)
+		}
+		sg.Rules = append(sg.Rules, rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteRule deletes the rule identified by ruleID from the security group identified by groupID
+func (client *Client) DeleteRule(groupID string, ruleID string) error {
+	if err := client.injector.call("DeleteRule"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		sg, found := s.SecurityGroups[groupID]
+		if !found {
+			return model.ResourceNotFoundError("security group", groupID)
+		}
+		for i, r := range sg.Rules {
+			if r.ID == ruleID {
+				sg.Rules = append(sg.Rules[:i], sg.Rules[i+1:]...)
+				return nil
+			}
+		}
+		return model.ResourceNotFoundError("security group rule", ruleID)
+	})
+}
+
+// BindToHost binds the security group identified by groupID to the host identified by hostID
+func (client *Client) BindToHost(groupID string, hostID string) error {
+	if err := client.injector.call("BindToHost"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		sg, found := s.SecurityGroups[groupID]
+		if !found {
+			return model.ResourceNotFoundError("security group", groupID)
+		}
+		if _, found = s.Hosts[hostID]; !found {
+			return model.ResourceNotFoundError("host", hostID)
+		}
+		if !sg.IsBoundTo(hostID) {
+			sg.Hosts = append(sg.Hosts, hostID)
+		}
+		return nil
+	})
+}
+
+// UnbindFromHost unbinds the security group identified by groupID from the host identified by hostID
+func (client *Client) UnbindFromHost(groupID string, hostID string) error {
+	if err := client.injector.call("UnbindFromHost"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		sg, found := s.SecurityGroups[groupID]
+		if !found {
+			return model.ResourceNotFoundError("security group", groupID)
+		}
+		sg.Hosts = removeID(sg.Hosts, hostID)
+		return nil
+	})
+}
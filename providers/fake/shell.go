@@ -0,0 +1,258 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostState"
+)
+
+// shell is the SSH server of a host in ShellHostMode, listening on the loopback address of the host
+// The commands succeed without doing anything, the files copied to the host are discarded, and the connections
+// forwarded to the private address of another host of the tenant reach the shell of this host
+type shell struct {
+	cloud    *cloud
+	listener net.Listener
+	config   *ssh.ServerConfig
+}
+
+// syncShells starts the shells of the started hosts and stops the others; must be called with c.lock held
+// A shell failing to start (its address being used by the process which created the host for example) is not
+// started again until the host is stopped
+func (c *cloud) syncShells() {
+	for id, h := range c.state.Hosts {
+		s, running := c.shells[id]
+		started := h.Host.LastState == HostState.STARTED && h.Address != ""
+		switch {
+		case started && !running:
+			s, err := c.startShell(h)
+			if err != nil {
+				if strings.Contains(err.Error(), "address already in use") {
+					log.Debugf("Shell of host '%s' not started: %v", h.Host.Name, err)
+				} else {
+					log.Warnf("Failed to start shell of host '%s': %v", h.Host.Name, err)
+				}
+			}
+			c.shells[id] = s
+		case !started && running:
+			if s != nil {
+				_ = s.listener.Close()
+			}
+			delete(c.shells, id)
+		}
+	}
+	for id, s := range c.shells {
+		if _, found := c.state.Hosts[id]; !found {
+			if s != nil {
+				_ = s.listener.Close()
+			}
+			delete(c.shells, id)
+		}
+	}
+}
+
+// startShell starts the shell of the host h
+func (c *cloud) startShell(h *host) (*shell, error) {
+	hostSigner, err := ssh.ParsePrivateKey([]byte(h.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key: %s", err.Error())
+	}
+	authorizedKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(h.AuthorizedKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorized key: %s", err.Error())
+	}
+	authorized := string(authorizedKey.Marshal())
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == authorized {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(h.Address, strconv.Itoa(c.cfg.SSHPort)))
+	if err != nil {
+		return nil, err
+	}
+	s := &shell{cloud: c, listener: listener, config: config}
+	go s.serve()
+	return s, nil
+}
+
+func (s *shell) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			for newChannel := range chans {
+				switch newChannel.ChannelType() {
+				case "session":
+					go s.session(newChannel)
+				case "direct-tcpip":
+					go s.forward(newChannel)
+				default:
+					_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+				}
+			}
+		}()
+	}
+}
+
+// session serves a session: the command succeeds without doing anything, except scp which behaves as if the
+// file was copied
+func (s *shell) session(newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	for req := range reqs {
+		switch req.Type {
+		case "exec", "shell":
+		case "env", "pty-req":
+			_ = req.Reply(true, nil)
+			continue
+		default:
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		_ = ssh.Unmarshal(req.Payload, &payload)
+		_ = req.Reply(true, nil)
+
+		status := uint32(0)
+		switch {
+		case strings.HasPrefix(payload.Command, "scp -t "):
+			status = scpSink(channel)
+		case strings.HasPrefix(payload.Command, "scp -f "):
+			path := strings.TrimPrefix(payload.Command, "scp -f ")
+			_, _ = fmt.Fprintf(channel, "\x01scp: %s: No such file or directory\n", path)
+			status = 1
+		default:
+			go func() {
+				_, _ = io.Copy(ioutil.Discard, channel)
+			}()
+		}
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+// scpSink receives the files sent by scp and discards them
+func scpSink(channel ssh.Channel) uint32 {
+	ack := func() error {
+		_, err := channel.Write([]byte{0})
+		return err
+	}
+	r := bufio.NewReader(channel)
+	if ack() != nil {
+		return 1
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			// End of the copy
+			return 0
+		}
+		if strings.HasPrefix(line, "C") {
+			var (
+				mode string
+				size int64
+				name string
+			)
+			_, err = fmt.Sscanf(line, "C%s %d %s", &mode, &size, &name)
+			if err != nil || ack() != nil {
+				return 1
+			}
+			// Content of the file followed by a 0 byte
+			_, err = io.CopyN(ioutil.Discard, r, size+1)
+			if err != nil {
+				return 1
+			}
+		}
+		if ack() != nil {
+			return 1
+		}
+	}
+}
+
+// forward serves a connection forwarded through the host, to the shell of the host owning the target address
+// in the tenant, or to the target itself
+func (s *shell) forward(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	_ = ssh.Unmarshal(newChannel.ExtraData(), &payload)
+	target, err := net.Dial("tcp", s.cloud.resolve(payload.Host, int(payload.Port)))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		_ = target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(target, channel)
+		_ = target.Close()
+	}()
+	_, _ = io.Copy(channel, target)
+	_ = channel.Close()
+}
+
+// resolve returns the address of the shell of the host having the private address ip if port is the SSH port,
+// ip:port otherwise
+func (c *cloud) resolve(ip string, port int) string {
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	if port != c.cfg.SSHPort {
+		return address
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, n := range c.state.Networks {
+		if id, found := n.Addresses[ip]; found {
+			if h, found := c.state.Hosts[id]; found && h.Address != "" {
+				return net.JoinHostPort(h.Address, strconv.Itoa(port))
+			}
+		}
+	}
+	return address
+}
@@ -0,0 +1,263 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+var (
+	// clouds contains the clouds already used, indexed by state file or by tenant name, so that every Client of
+	// the same tenant in the same process shares the same resources
+	clouds     = map[string]*cloud{}
+	cloudsLock sync.Mutex
+)
+
+// state contains the resources of a fake tenant
+type state struct {
+	// Counter is incremented each time a shell address is allocated
+	Counter        int                                `json:"counter"`
+	KeyPairs       map[string]*model.KeyPair          `json:"keypairs"`
+	Networks       map[string]*network                `json:"networks"`
+	Hosts          map[string]*host                   `json:"hosts"`
+	Volumes        map[string]*model.Volume           `json:"volumes"`
+	Attachments    map[string]*model.VolumeAttachment `json:"attachments"` // indexed by volume ID
+	SecurityGroups map[string]*model.SecurityGroup    `json:"security_groups"`
+	VIPs           map[string]*model.VIP              `json:"vips"`
+}
+
+func newState() *state {
+	return &state{
+		KeyPairs:       map[string]*model.KeyPair{},
+		Networks:       map[string]*network{},
+		Hosts:          map[string]*host{},
+		Volumes:        map[string]*model.Volume{},
+		Attachments:    map[string]*model.VolumeAttachment{},
+		SecurityGroups: map[string]*model.SecurityGroup{},
+		VIPs:           map[string]*model.VIP{},
+	}
+}
+
+// network is a network of the fake tenant
+type network struct {
+	Network *model.Network `json:"network"`
+	// Addresses contains the IDs of the hosts and VIPs using the addresses allocated in the network, indexed by address
+	Addresses map[string]string `json:"addresses"`
+	// Container is the name of the docker network (ContainerHostMode)
+	Container string `json:"container,omitempty"`
+}
+
+// allocateAddress allocates to owner the first free address of the network
+func (n *network) allocateAddress(owner string) (string, error) {
+	_, ipnet, err := net.ParseCIDR(n.Network.CIDR)
+	if err != nil {
+		return "", err
+	}
+	base := ipnet.IP.To4()
+	if base == nil {
+		return "", fmt.Errorf("network '%s' isn't an IPv4 network", n.Network.Name)
+	}
+	ones, bits := ipnet.Mask.Size()
+	size := uint32(1) << uint(bits-ones)
+	first := binary.BigEndian.Uint32(base)
+	// The network address, the first address (router) and the broadcast address are not allocated
+	for i := uint32(2); i < size-1; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, first+i)
+		if _, used := n.Addresses[ip.String()]; !used {
+			n.Addresses[ip.String()] = owner
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no address available in network '%s'", n.Network.Name)
+}
+
+// releaseAddresses releases the addresses allocated to owner
+func (n *network) releaseAddresses(owner string) {
+	for ip, id := range n.Addresses {
+		if id == owner {
+			delete(n.Addresses, ip)
+		}
+	}
+}
+
+// host is a host of the fake tenant
+type host struct {
+	Host *model.Host `json:"host"`
+	// HostKey is the private SSH host key of the host
+	HostKey string `json:"host_key"`
+	// AuthorizedKey is the public key allowed to connect to the host
+	AuthorizedKey string `json:"authorized_key"`
+	// Address is the loopback address of the SSH server of the host (ShellHostMode)
+	Address string `json:"address,omitempty"`
+	// Container is the name of the docker container of the host (ContainerHostMode)
+	Container string `json:"container,omitempty"`
+}
+
+// cloud contains the resources of a fake tenant, kept in memory and in a state file if configured
+type cloud struct {
+	lock   sync.Mutex
+	name   string
+	cfg    CfgOptions
+	state  *state
+	shells map[string]*shell // running shells, indexed by host ID
+}
+
+// getCloud returns the cloud of the tenant named name, created on first use
+func getCloud(name string, cfg CfgOptions) (*cloud, error) {
+	key := "tenant:" + name
+	if cfg.StateFile != "" {
+		path, err := filepath.Abs(cfg.StateFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.StateFile = path
+		key = "file:" + path
+	}
+
+	cloudsLock.Lock()
+	defer cloudsLock.Unlock()
+
+	c, found := clouds[key]
+	if !found {
+		c = &cloud{
+			name:   name,
+			cfg:    cfg,
+			state:  newState(),
+			shells: map[string]*shell{},
+		}
+		clouds[key] = c
+	}
+	return c, nil
+}
+
+// read calls fn with the current state, which must not be modified
+func (c *cloud) read(fn func(s *state) error) error {
+	return c.do(false, fn)
+}
+
+// write calls fn to modify the state, saved if fn succeeds
+// fn must check everything before modifying the state, the state being kept even if fn fails
+func (c *cloud) write(fn func(s *state) error) error {
+	return c.do(true, fn)
+}
+
+func (c *cloud) do(write bool, fn func(s *state) error) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.cfg.StateFile != "" {
+		unlock, err := lockFile(c.cfg.StateFile + ".lock")
+		if err != nil {
+			return fmt.Errorf("failed to lock fake state file: %s", err.Error())
+		}
+		defer unlock()
+		err = c.load()
+		if err != nil {
+			return fmt.Errorf("failed to load fake state file: %s", err.Error())
+		}
+	}
+
+	err := fn(c.state)
+	if err != nil {
+		return err
+	}
+	if write && c.cfg.StateFile != "" {
+		err = c.save()
+		if err != nil {
+			return fmt.Errorf("failed to save fake state file: %s", err.Error())
+		}
+	}
+	if c.cfg.HostMode == ShellHostMode {
+		c.syncShells()
+	}
+	return nil
+}
+
+// load reads the state file, the state is empty if the file doesn't exist
+func (c *cloud) load() error {
+	content, err := ioutil.ReadFile(c.cfg.StateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.state = newState()
+			return nil
+		}
+		return err
+	}
+	s := newState()
+	err = json.Unmarshal(content, s)
+	if err != nil {
+		return err
+	}
+	c.state = s
+	return nil
+}
+
+// save writes the state file, replaced at once
+func (c *cloud) save() error {
+	content, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.cfg.StateFile + ".tmp"
+	err = ioutil.WriteFile(tmp, content, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.cfg.StateFile)
+}
+
+// shellAddress returns the n-th loopback address of the shells of the tenant
+// The second byte of the address is derived from the tenant name, to keep the tenants of a process apart
+func (c *cloud) shellAddress(n int) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(c.name))
+	return fmt.Sprintf("127.%d.%d.%d", 16+h.Sum32()%224, (n/254)%256, n%254+1)
+}
+
+// newID returns a new resource ID
+func newID() (string, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", fmt.Errorf("failed to create ID: %s", err.Error())
+	}
+	return id.String(), nil
+}
+
+// clone copies src in dst, so that the resources returned can't modify the state
+func clone(src interface{}, dst interface{}) {
+	content, err := json.Marshal(src)
+	if err != nil {
+		panic(fmt.Sprintf("failed to copy fake resource: %s", err.Error()))
+	}
+	err = json.Unmarshal(content, dst)
+	if err != nil {
+		panic(fmt.Sprintf("failed to copy fake resource: %s", err.Error()))
+	}
+}
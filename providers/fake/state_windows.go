@@ -0,0 +1,54 @@
+//go:build windows
+// +build windows
+
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"syscall"
+	"time"
+)
+
+// errSharingViolation is returned when the file is already opened by another process without sharing
+const errSharingViolation = syscall.Errno(32)
+
+// lockRetryDelay is the delay between two attempts to lock a file held by another process
+const lockRetryDelay = 50 * time.Millisecond
+
+// lockFile locks exclusively the file at path between processes, and returns the function unlocking it
+// Windows has no flock: the file is opened without sharing, the other processes waiting until it is closed; the lock
+// is released by the system if the process dies
+func lockFile(path string) (func(), error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_ALWAYS,
+			syscall.FILE_ATTRIBUTE_NORMAL, 0)
+		if err == nil {
+			return func() {
+				_ = syscall.CloseHandle(h)
+			}, nil
+		}
+		if err != errSharingViolation {
+			return nil, err
+		}
+		time.Sleep(lockRetryDelay)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// A VIP reserves an address in the network; the hosts allowed to hold it are only recorded
+
+// CreateVIP creates a private virtual IP in the network identified by networkID
+func (client *Client) CreateVIP(networkID string, name string) (*model.VIP, error) {
+	if err := client.injector.call("CreateVIP"); err != nil {
+		return nil, err
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	vip := &model.VIP{
+		ID:        id,
+		Name:      name,
+		NetworkID: networkID,
+	}
+	err = client.cloud.write(func(s *state) error {
+		n, found := s.Networks[networkID]
+		if !found {
+			return model.ResourceNotFoundError("network", networkID)
+		}
+		ip, err := n.allocateAddress(id)
+		if err != nil {
+			return err
+		}
+		vip.PrivateIP = ip
+		stored := *vip
+		s.VIPs[id] = &stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vip, nil
+}
+
+// BindHostToVIP allows the host identified by hostID to hold the VIP
+func (client *Client) BindHostToVIP(vip *model.VIP, hostID string) error {
+	if err := client.injector.call("BindHostToVIP"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		stored, found := s.VIPs[vip.ID]
+		if !found {
+			return model.ResourceNotFoundError("VIP", vip.ID)
+		}
+		_, err := getHostAddress(s, hostID, stored.NetworkID)
+		if err != nil {
+			return err
+		}
+		for _, id := range stored.Hosts {
+			if id == hostID {
+				return nil
+			}
+		}
+		stored.Hosts = append(stored.Hosts, hostID)
+		return nil
+	})
+}
+
+// UnbindHostFromVIP forbids the host identified by hostID to hold the VIP
+func (client *Client) UnbindHostFromVIP(vip *model.VIP, hostID string) error {
+	if err := client.injector.call("UnbindHostFromVIP"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		stored, found := s.VIPs[vip.ID]
+		if !found {
+			return model.ResourceNotFoundError("VIP", vip.ID)
+		}
+		stored.Hosts = removeID(stored.Hosts, hostID)
+		return nil
+	})
+}
+
+// DeleteVIP deletes the VIP
+func (client *Client) DeleteVIP(vip *model.VIP) error {
+	if err := client.injector.call("DeleteVIP"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		stored, found := s.VIPs[vip.ID]
+		if !found {
+			return model.ResourceNotFoundError("VIP", vip.ID)
+		}
+		if n, found := s.Networks[stored.NetworkID]; found {
+			n.releaseAddresses(vip.ID)
+		}
+		delete(s.VIPs, vip.ID)
+		return nil
+	})
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fake
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeState"
+)
+
+// CreateVolume creates a block volume
+// The volumes are not backed by any storage, even in ContainerHostMode
+func (client *Client) CreateVolume(request model.VolumeRequest) (*model.Volume, error) {
+	if err := client.injector.call("CreateVolume"); err != nil {
+		return nil, err
+	}
+	if request.Size <= 0 {
+		return nil, model.ResourceInvalidRequestError("volume creation", fmt.Sprintf("invalid size %d", request.Size))
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	v := model.NewVolume()
+	v.ID = id
+	v.Name = request.Name
+	v.Size = request.Size
+	v.Speed = request.Speed
+	v.State = VolumeState.AVAILABLE
+	err = client.cloud.write(func(s *state) error {
+		stored := model.NewVolume()
+		clone(v, stored)
+		s.Volumes[id] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetVolume returns the volume identified by id
+func (client *Client) GetVolume(id string) (*model.Volume, error) {
+	if err := client.injector.call("GetVolume"); err != nil {
+		return nil, err
+	}
+	var result *model.Volume
+	err := client.cloud.read(func(s *state) error {
+		v, found := s.Volumes[id]
+		if !found {
+			return model.ResourceNotFoundError("volume", id)
+		}
+		result = model.NewVolume()
+		clone(v, result)
+		return nil
+	})
+	return result, err
+}
+
+// ListVolumes list available volumes
+func (client *Client) ListVolumes() ([]model.Volume, error) {
+	if err := client.injector.call("ListVolumes"); err != nil {
+		return nil, err
+	}
+	var list []model.Volume
+	err := client.cloud.read(func(s *state) error {
+		for _, v := range s.Volumes {
+			volume := model.NewVolume()
+			clone(v, volume)
+			list = append(list, *volume)
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+// DeleteVolume deletes the volume identified by id
+func (client *Client) DeleteVolume(id string) error {
+	if err := client.injector.call("DeleteVolume"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		v, found := s.Volumes[id]
+		if !found {
+			return model.ResourceNotFoundError("volume", id)
+		}
+		if _, attached := s.Attachments[id]; attached {
+			return fmt.Errorf("volume '%s' is attached", v.Name)
+		}
+		delete(s.Volumes, id)
+		return nil
+	})
+}
+
+// CreateVolumeAttachment attaches a volume to an host
+// The ID of the attachment is the ID of the volume
+func (client *Client) CreateVolumeAttachment(request model.VolumeAttachmentRequest) (string, error) {
+	if err := client.injector.call("CreateVolumeAttachment"); err != nil {
+		return "", err
+	}
+	err := client.cloud.write(func(s *state) error {
+		v, found := s.Volumes[request.VolumeID]
+		if !found {
+			return model.ResourceNotFoundError("volume", request.VolumeID)
+		}
+		if _, found = s.Hosts[request.HostID]; !found {
+			return model.ResourceNotFoundError("host", request.HostID)
+		}
+		if va, attached := s.Attachments[request.VolumeID]; attached {
+			return fmt.Errorf("volume '%s' is already attached to host '%s'", v.Name, va.ServerID)
+		}
+
+		// Devices are given in order, from /dev/vdb (/dev/vda being the system disk)
+		used := map[string]bool{}
+		for _, va := range s.Attachments {
+			if va.ServerID == request.HostID {
+				used[va.Device] = true
+			}
+		}
+		device := ""
+		for c := 'b'; c <= 'z'; c++ {
+			if !used["/dev/vd"+string(c)] {
+				device = "/dev/vd" + string(c)
+				break
+			}
+		}
+		if device == "" {
+			return fmt.Errorf("no device available on host '%s'", request.HostID)
+		}
+
+		s.Attachments[request.VolumeID] = &model.VolumeAttachment{
+			ID:       request.VolumeID,
+			Name:     request.Name,
+			VolumeID: request.VolumeID,
+			ServerID: request.HostID,
+			Device:   device,
+		}
+		v.State = VolumeState.USED
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return request.VolumeID, nil
+}
+
+// GetVolumeAttachment returns the volume attachment identified by id
+func (client *Client) GetVolumeAttachment(serverID, id string) (*model.VolumeAttachment, error) {
+	if err := client.injector.call("GetVolumeAttachment"); err != nil {
+		return nil, err
+	}
+	var result *model.VolumeAttachment
+	err := client.cloud.read(func(s *state) error {
+		va, found := s.Attachments[id]
+		if !found || va.ServerID != serverID {
+			return model.ResourceNotFoundError("volume attachment", id)
+		}
+		result = &model.VolumeAttachment{}
+		*result = *va
+		return nil
+	})
+	return result, err
+}
+
+// ListVolumeAttachments lists available volume attachment
+func (client *Client) ListVolumeAttachments(serverID string) ([]model.VolumeAttachment, error) {
+	if err := client.injector.call("ListVolumeAttachments"); err != nil {
+		return nil, err
+	}
+	var list []model.VolumeAttachment
+	err := client.cloud.read(func(s *state) error {
+		for _, va := range s.Attachments {
+			if va.ServerID == serverID {
+				list = append(list, *va)
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Device < list[j].Device })
+	return list, err
+}
+
+// DeleteVolumeAttachment deletes the volume attachment identifed by id
+func (client *Client) DeleteVolumeAttachment(serverID, id string) error {
+	if err := client.injector.call("DeleteVolumeAttachment"); err != nil {
+		return err
+	}
+	return client.cloud.write(func(s *state) error {
+		va, found := s.Attachments[id]
+		if !found || va.ServerID != serverID {
+			return model.ResourceNotFoundError("volume attachment", id)
+		}
+		delete(s.Attachments, id)
+		if v, found := s.Volumes[va.VolumeID]; found {
+			v.State = VolumeState.AVAILABLE
+		}
+		return nil
+	})
+}
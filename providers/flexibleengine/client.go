@@ -607,6 +607,14 @@ func (client *Client) GetCfgOpts() (model.Config, error) {
 	return client.osclt.GetCfgOpts()
 }
 
+// GetCapabilities returns what the provider supports, GPU being offered by the templates of gpuMap
+func (client *Client) GetCapabilities() model.Capabilities {
+	capabilities := client.osclt.GetCapabilities()
+	capabilities.GPU = true
+	capabilities.ObjectStorage = "s3"
+	return capabilities
+}
+
 // init registers the flexibleengine provider
 func init() {
 	providers.Register("flexibleengine", &Client{})
@@ -531,6 +531,14 @@ func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error)
 		}
 	}
 
+	// The host generates its SSH host key and prints it on its console, where it is read to authenticate the host
+	host.HostKey, err = userdata.WaitHostKey(func() (string, error) {
+		return servers.ShowConsoleOutput(client.osclt.Compute, host.ID, servers.ShowConsoleOutputOpts{}).Extract()
+	}, 10*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SSH host key of host '%s': %s", request.ResourceName, err.Error())
+	}
+
 	log.Infoln(msgSuccess)
 	return host, nil
 }
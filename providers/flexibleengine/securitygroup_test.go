@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flexibleengine
+
+import (
+	"testing"
+
+	secgroups "github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	secrules "github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+func TestToModelSecurityGroup(t *testing.T) {
+	sg := &secgroups.SecGroup{
+		ID:          "sg-1",
+		Name:        "web",
+		Description: "web servers",
+		Rules: []secrules.SecGroupRule{
+			{ID: "rule-1", Direction: "ingress", Protocol: "tcp", PortRangeMin: 80, PortRangeMax: 443, RemoteIPPrefix: "0.0.0.0/0"},
+			// icmp without range means all types and codes
+			{ID: "rule-2", Direction: "ingress", Protocol: "icmp", RemoteIPPrefix: "10.0.0.0/8"},
+			// Egress rules, and rules without protocol (default rules of the group), are not handled by SafeScale
+			{ID: "rule-4", Direction: "egress", Protocol: "tcp", PortRangeMin: 1, PortRangeMax: 65535},
+			{ID: "rule-5", Direction: "ingress", RemoteGroupID: "sg-1"},
+		},
+	}
+	group := toModelSecurityGroup(sg)
+	assert.Equal(t, "sg-1", group.ID)
+	assert.Equal(t, "web", group.Name)
+	assert.Equal(t, "web servers", group.Description)
+	assert.Equal(t, []model.SecurityGroupRule{
+		{ID: "rule-1", Protocol: "tcp", FromPort: 80, ToPort: 443, CIDR: "0.0.0.0/0"},
+		{ID: "rule-2", Protocol: "icmp", FromPort: -1, ToPort: -1, CIDR: "10.0.0.0/8"},
+	}, group.Rules)
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flexibleengine
+
+import (
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// The VIPs are Neutron ports, the ID of a FlexibleEngine network being the one of its Neutron network
+
+// CreateVIP creates a private virtual IP in the network identified by networkID
+func (client *Client) CreateVIP(networkID string, name string) (*model.VIP, error) {
+	return client.osclt.CreateVIP(networkID, name)
+}
+
+// BindHostToVIP allows the host identified by hostID to hold the VIP
+func (client *Client) BindHostToVIP(vip *model.VIP, hostID string) error {
+	return client.osclt.BindHostToVIP(vip, hostID)
+}
+
+// UnbindHostFromVIP forbids the host identified by hostID to hold the VIP
+func (client *Client) UnbindHostFromVIP(vip *model.VIP, hostID string) error {
+	return client.osclt.UnbindHostFromVIP(vip, hostID)
+}
+
+// DeleteVIP deletes the VIP
+func (client *Client) DeleteVIP(vip *model.VIP) error {
+	return client.osclt.DeleteVIP(vip)
+}
@@ -0,0 +1,473 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/NetworkProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeProperty"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+)
+
+const (
+	// DriftDangling tells the metadata describes a resource which doesn't exist anymore in the cloud
+	DriftDangling = "dangling"
+	// DriftUntracked tells a resource exists in the cloud without metadata
+	DriftUntracked = "untracked"
+	// DriftBroken tells the metadata of a resource refers to another resource which has no metadata or doesn't exist anymore
+	DriftBroken = "broken"
+)
+
+// Drift describes a difference found between the metadata and the cloud
+type Drift struct {
+	// Kind is the kind of the resource concerned (host, network, share or volume)
+	Kind string `json:"kind"`
+	// Type is the type of the drift (dangling, untracked or broken)
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Details string `json:"details,omitempty"`
+	// Repaired tells the drift has been fixed
+	Repaired bool `json:"repaired,omitempty"`
+	// Error contains the reason of the failure of the repair
+	Error string `json:"error,omitempty"`
+}
+
+// fsck contains the state of a consistency check of the metadata
+type fsck struct {
+	svc             *providers.Service
+	deleteDangling  bool
+	importUntracked bool
+	drifts          []*Drift
+	// hosts contains the hosts existing in the cloud and in metadata (after repair), indexed by ID
+	hosts map[string]*model.Host
+}
+
+// Fsck cross-checks the metadata of hosts, networks, volumes and shares against the resources of the cloud,
+// and returns the drifts found.
+// If 'deleteDangling' is set, the metadata of resources not existing anymore, and the references to them, are removed.
+// If 'importUntracked' is set, metadata are created for the resources of the cloud unknown by SafeScale.
+func Fsck(svc *providers.Service, deleteDangling bool, importUntracked bool) ([]*Drift, error) {
+	c := &fsck{
+		svc:             svc,
+		deleteDangling:  deleteDangling,
+		importUntracked: importUntracked,
+		drifts:          []*Drift{},
+		hosts:           map[string]*model.Host{},
+	}
+	err := c.checkHosts()
+	if err != nil {
+		return nil, err
+	}
+	err = c.checkNetworks()
+	if err != nil {
+		return nil, err
+	}
+	err = c.checkVolumes()
+	if err != nil {
+		return nil, err
+	}
+	err = c.checkShares()
+	if err != nil {
+		return nil, err
+	}
+	return c.drifts, nil
+}
+
+// report records a drift, and repairs it with 'repair' if not nil
+func (c *fsck) report(drift *Drift, repair func() error) {
+	c.drifts = append(c.drifts, drift)
+	if repair == nil {
+		log.Warnf("metadata fsck: %s %s '%s' (%s): %s", drift.Type, drift.Kind, drift.Name, drift.ID, drift.Details)
+		return
+	}
+	err := repair()
+	if err != nil {
+		drift.Error = err.Error()
+		log.Errorf("metadata fsck: failed to repair %s %s '%s' (%s): %s", drift.Type, drift.Kind, drift.Name, drift.ID, err.Error())
+		return
+	}
+	drift.Repaired = true
+	log.Infof("metadata fsck: repaired %s %s '%s' (%s)", drift.Type, drift.Kind, drift.Name, drift.ID)
+}
+
+// checkHosts compares the metadata of the hosts with the hosts of the cloud
+func (c *fsck) checkHosts() error {
+	list, err := c.svc.ListHosts()
+	if err != nil {
+		return fmt.Errorf("failed to list hosts: %s", err.Error())
+	}
+	cloud := map[string]*model.Host{}
+	for _, host := range list {
+		cloud[host.ID] = host
+	}
+
+	tracked := map[string]bool{}
+	var dangling []*model.Host
+	err = NewHost(c.svc).Browse(func(host *model.Host) error {
+		tracked[host.ID] = true
+		if _, found := cloud[host.ID]; found {
+			c.hosts[host.ID] = host
+		} else {
+			dangling = append(dangling, host)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to browse metadata of hosts: %s", err.Error())
+	}
+
+	for _, host := range dangling {
+		host := host
+		drift := &Drift{Kind: "host", Type: DriftDangling, ID: host.ID, Name: host.Name, Details: "host doesn't exist anymore"}
+		var repair func() error
+		if c.deleteDangling {
+			repair = func() error {
+				mh := NewHost(c.svc).Carry(host)
+				err := mh.Acquire()
+				if err != nil {
+					return err
+				}
+				defer mh.Release()
+				return RemoveHost(c.svc, host)
+			}
+		}
+		c.report(drift, repair)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	for _, host := range list {
+		if tracked[host.ID] {
+			continue
+		}
+		host := host
+		drift := &Drift{Kind: "host", Type: DriftUntracked, ID: host.ID, Name: host.Name, Details: "host has no metadata"}
+		var repair func() error
+		if c.importUntracked {
+			repair = func() error {
+				imported, err := ImportHost(c.svc, host.ID, "")
+				if err != nil {
+					return err
+				}
+				c.hosts[imported.ID] = imported
+				return nil
+			}
+		}
+		c.report(drift, repair)
+	}
+	return nil
+}
+
+// checkNetworks compares the metadata of the networks with the networks of the cloud, and checks
+// the hosts referenced by the networks
+func (c *fsck) checkNetworks() error {
+	list, err := c.svc.ListNetworks()
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %s", err.Error())
+	}
+	cloud := map[string]*model.Network{}
+	for _, network := range list {
+		cloud[network.ID] = network
+	}
+
+	tracked := map[string]bool{}
+	var networks []*model.Network
+	err = NewNetwork(c.svc).Browse(func(network *model.Network) error {
+		tracked[network.ID] = true
+		networks = append(networks, network)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to browse metadata of networks: %s", err.Error())
+	}
+
+	for _, network := range networks {
+		network := network
+		if _, found := cloud[network.ID]; !found {
+			drift := &Drift{Kind: "network", Type: DriftDangling, ID: network.ID, Name: network.Name, Details: "network doesn't exist anymore"}
+			var repair func() error
+			if c.deleteDangling {
+				repair = func() error {
+					mn := NewNetwork(c.svc).Carry(network)
+					err := mn.Acquire()
+					if err != nil {
+						return err
+					}
+					defer mn.Release()
+					return mn.Delete()
+				}
+			}
+			c.report(drift, repair)
+			// The metadata of a gateway not existing anymore has been handled with the hosts; a gateway still
+			// existing in the cloud is left to the operator
+			if _, found := c.hosts[network.GatewayID]; found {
+				c.report(&Drift{
+					Kind:    "host",
+					Type:    DriftBroken,
+					ID:      network.GatewayID,
+					Name:    c.hosts[network.GatewayID].Name,
+					Details: fmt.Sprintf("gateway of network '%s' which doesn't exist anymore", network.Name),
+				}, nil)
+			}
+			continue
+		}
+
+		if network.GatewayID != "" {
+			if _, found := c.hosts[network.GatewayID]; !found {
+				c.report(&Drift{
+					Kind:    "network",
+					Type:    DriftBroken,
+					ID:      network.ID,
+					Name:    network.Name,
+					Details: fmt.Sprintf("gateway '%s' doesn't exist or has no metadata", network.GatewayID),
+				}, nil)
+			}
+		}
+
+		networkHostsV1 := propsv1.NewNetworkHosts()
+		err = network.Properties.Get(NetworkProperty.HostsV1, networkHostsV1)
+		if err != nil {
+			return err
+		}
+		var missing []string
+		for id := range networkHostsV1.ByID {
+			if _, found := c.hosts[id]; !found {
+				missing = append(missing, id)
+			}
+		}
+		sort.Strings(missing)
+		for _, id := range missing {
+			id := id
+			drift := &Drift{
+				Kind:    "network",
+				Type:    DriftBroken,
+				ID:      network.ID,
+				Name:    network.Name,
+				Details: fmt.Sprintf("attached host '%s' (%s) doesn't exist or has no metadata", networkHostsV1.ByID[id], id),
+			}
+			var repair func() error
+			if c.deleteDangling {
+				repair = func() error {
+					mn := NewNetwork(c.svc).Carry(network)
+					err := mn.Acquire()
+					if err != nil {
+						return err
+					}
+					defer mn.Release()
+					err = mn.Reload()
+					if err != nil {
+						return err
+					}
+					err = mn.DetachHost(id)
+					if err != nil {
+						return err
+					}
+					return mn.Write()
+				}
+			}
+			c.report(drift, repair)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	for _, network := range list {
+		if tracked[network.ID] {
+			continue
+		}
+		network := network
+		drift := &Drift{Kind: "network", Type: DriftUntracked, ID: network.ID, Name: network.Name, Details: "network has no metadata"}
+		var repair func() error
+		if c.importUntracked {
+			repair = func() error {
+				_, err := ImportNetwork(c.svc, network.ID, "")
+				return err
+			}
+		}
+		c.report(drift, repair)
+	}
+	return nil
+}
+
+// checkVolumes compares the metadata of the volumes with the volumes of the cloud, and checks
+// the hosts attaching the volumes
+func (c *fsck) checkVolumes() error {
+	list, err := c.svc.ListVolumes()
+	if err != nil {
+		return fmt.Errorf("failed to list volumes: %s", err.Error())
+	}
+	cloud := map[string]bool{}
+	for _, volume := range list {
+		cloud[volume.ID] = true
+	}
+
+	tracked := map[string]bool{}
+	var volumes []*model.Volume
+	err = NewVolume(c.svc).Browse(func(volume *model.Volume) error {
+		tracked[volume.ID] = true
+		volumes = append(volumes, volume)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to browse metadata of volumes: %s", err.Error())
+	}
+
+	for _, volume := range volumes {
+		volume := volume
+		if !cloud[volume.ID] {
+			drift := &Drift{Kind: "volume", Type: DriftDangling, ID: volume.ID, Name: volume.Name, Details: "volume doesn't exist anymore"}
+			var repair func() error
+			if c.deleteDangling {
+				repair = func() error {
+					mv := NewVolume(c.svc).Carry(volume)
+					err := mv.Acquire()
+					if err != nil {
+						return err
+					}
+					defer mv.Release()
+					return mv.Delete()
+				}
+			}
+			c.report(drift, repair)
+			continue
+		}
+
+		volumeAttachmentsV1 := propsv1.NewVolumeAttachments()
+		err = volume.Properties.Get(VolumeProperty.AttachedV1, volumeAttachmentsV1)
+		if err != nil {
+			return err
+		}
+		var missing []string
+		for id := range volumeAttachmentsV1.Hosts {
+			if _, found := c.hosts[id]; !found {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		sort.Strings(missing)
+		drift := &Drift{
+			Kind:    "volume",
+			Type:    DriftBroken,
+			ID:      volume.ID,
+			Name:    volume.Name,
+			Details: fmt.Sprintf("attached to host(s) %v which don't exist or have no metadata", missing),
+		}
+		var repair func() error
+		if c.deleteDangling {
+			repair = func() error {
+				mv := NewVolume(c.svc).Carry(volume)
+				err := mv.Acquire()
+				if err != nil {
+					return err
+				}
+				defer mv.Release()
+				err = mv.Reload()
+				if err != nil {
+					return err
+				}
+				current := mv.Get()
+				volumeAttachmentsV1 := propsv1.NewVolumeAttachments()
+				err = current.Properties.Get(VolumeProperty.AttachedV1, volumeAttachmentsV1)
+				if err != nil {
+					return err
+				}
+				for _, id := range missing {
+					delete(volumeAttachmentsV1.Hosts, id)
+				}
+				err = current.Properties.Set(VolumeProperty.AttachedV1, volumeAttachmentsV1)
+				if err != nil {
+					return err
+				}
+				return mv.Write()
+			}
+		}
+		c.report(drift, repair)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	for _, volume := range list {
+		if tracked[volume.ID] {
+			continue
+		}
+		volume := volume
+		drift := &Drift{Kind: "volume", Type: DriftUntracked, ID: volume.ID, Name: volume.Name, Details: "volume has no metadata"}
+		var repair func() error
+		if c.importUntracked {
+			repair = func() error {
+				_, err := ImportVolume(c.svc, volume.ID)
+				return err
+			}
+		}
+		c.report(drift, repair)
+	}
+	return nil
+}
+
+// checkShares checks the hosts serving the shares exist and still export them
+func (c *fsck) checkShares() error {
+	var shares []shareItem
+	err := NewShare(c.svc).item.BrowseInto(ByIDFolderName, func(buf []byte) error {
+		si := shareItem{}
+		err := (&si).Deserialize(buf)
+		if err != nil {
+			return err
+		}
+		shares = append(shares, si)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to browse metadata of shares: %s", err.Error())
+	}
+
+	for _, si := range shares {
+		si := si
+		var details string
+		host, found := c.hosts[si.HostID]
+		if !found {
+			details = fmt.Sprintf("server '%s' (%s) doesn't exist or has no metadata", si.HostName, si.HostID)
+		} else {
+			hostSharesV1 := propsv1.NewHostShares()
+			err = host.Properties.Get(HostProperty.SharesV1, hostSharesV1)
+			if err != nil {
+				return err
+			}
+			if _, found := hostSharesV1.ByID[si.ShareID]; !found {
+				details = fmt.Sprintf("server '%s' doesn't export the share", si.HostName)
+			}
+		}
+		if details == "" {
+			continue
+		}
+		drift := &Drift{Kind: "share", Type: DriftBroken, ID: si.ShareID, Name: si.ShareName, Details: details}
+		var repair func() error
+		if c.deleteDangling {
+			repair = func() error {
+				return RemoveShare(c.svc, si.HostID, si.HostName, si.ShareID, si.ShareName)
+			}
+		}
+		c.report(drift, repair)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/NetworkProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeProperty"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+	"github.com/CS-SI/SafeScale/providers/objectstorage"
+)
+
+// testClient is a cloud containing only the resources listed
+type testClient struct {
+	api.ClientAPI
+	hosts    []*model.Host
+	networks []*model.Network
+	volumes  []model.Volume
+	// attachments contains the volumes attached to the hosts, indexed by host ID
+	attachments map[string][]model.VolumeAttachment
+}
+
+// GetCfgOpts returns empty config options (metadata not encrypted)
+func (c *testClient) GetCfgOpts() (model.Config, error) {
+	return model.ConfigMap{}, nil
+}
+
+// ListHosts lists the hosts of the cloud
+func (c *testClient) ListHosts() ([]*model.Host, error) {
+	return c.hosts, nil
+}
+
+// ListNetworks lists the networks of the cloud
+func (c *testClient) ListNetworks() ([]*model.Network, error) {
+	return c.networks, nil
+}
+
+// ListVolumes lists the volumes of the cloud
+func (c *testClient) ListVolumes() ([]model.Volume, error) {
+	return c.volumes, nil
+}
+
+// GetHost returns the host of the cloud identified by ID
+func (c *testClient) GetHost(ref interface{}) (*model.Host, error) {
+	id, _ := ref.(string)
+	for _, host := range c.hosts {
+		if host.ID == id {
+			return host, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("host", id)
+}
+
+// GetHostByName returns the host of the cloud identified by name
+func (c *testClient) GetHostByName(name string) (*model.Host, error) {
+	for _, host := range c.hosts {
+		if host.Name == name {
+			return host, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("host", name)
+}
+
+// GetNetwork returns the network of the cloud identified by ID
+func (c *testClient) GetNetwork(id string) (*model.Network, error) {
+	for _, network := range c.networks {
+		if network.ID == id {
+			return network, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("network", id)
+}
+
+// GetNetworkByName returns the network of the cloud identified by name
+func (c *testClient) GetNetworkByName(name string) (*model.Network, error) {
+	for _, network := range c.networks {
+		if network.Name == name {
+			return network, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("network", name)
+}
+
+// GetVolume returns the volume of the cloud identified by ID
+func (c *testClient) GetVolume(id string) (*model.Volume, error) {
+	for _, volume := range c.volumes {
+		if volume.ID == id {
+			volume := volume
+			return &volume, nil
+		}
+	}
+	return nil, model.ResourceNotFoundError("volume", id)
+}
+
+// ListVolumeAttachments lists the volumes attached to a host of the cloud
+func (c *testClient) ListVolumeAttachments(hostID string) ([]model.VolumeAttachment, error) {
+	return c.attachments[hostID], nil
+}
+
+// newTestService returns a service on the cloud 'client', storing its metadata in memory
+func newTestService(t *testing.T, client *testClient) *providers.Service {
+	name := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	location, err := objectstorage.NewLocation(objectstorage.Config{Type: objectstorage.MemoryType, Path: name})
+	require.Nil(t, err)
+	bucket, err := location.CreateBucket("metadata")
+	require.Nil(t, err)
+	return &providers.Service{
+		ClientAPI:      client,
+		ObjectStorage:  location,
+		MetadataBucket: bucket,
+	}
+}
+
+func newTestHost(id string) *model.Host {
+	host := model.NewHost()
+	host.ID = id
+	host.Name = "name-" + id
+	return host
+}
+
+func newTestNetwork(id string, gatewayID string, hosts ...*model.Host) *model.Network {
+	network := model.NewNetwork()
+	network.ID = id
+	network.Name = "name-" + id
+	network.GatewayID = gatewayID
+	networkHostsV1 := propsv1.NewNetworkHosts()
+	for _, host := range hosts {
+		networkHostsV1.ByID[host.ID] = host.Name
+		networkHostsV1.ByName[host.Name] = host.ID
+	}
+	_ = network.Properties.Set(NetworkProperty.HostsV1, networkHostsV1)
+	return network
+}
+
+func newTestVolume(id string, hosts ...*model.Host) *model.Volume {
+	volume := model.NewVolume()
+	volume.ID = id
+	volume.Name = "name-" + id
+	volumeAttachmentsV1 := propsv1.NewVolumeAttachments()
+	for _, host := range hosts {
+		volumeAttachmentsV1.Hosts[host.ID] = host.Name
+	}
+	_ = volume.Properties.Set(VolumeProperty.AttachedV1, volumeAttachmentsV1)
+	return volume
+}
+
+// findDrift returns the drift of a resource, or nil if not reported
+func findDrift(drifts []*Drift, kind, typ, id string) *Drift {
+	for _, drift := range drifts {
+		if drift.Kind == kind && drift.Type == typ && drift.ID == id {
+			return drift
+		}
+	}
+	return nil
+}
+
+// seedInconsistencies fills the metadata with resources disagreeing with the cloud
+func seedInconsistencies(t *testing.T) *providers.Service {
+	host := newTestHost("host")
+	gone := newTestHost("gone")
+	gateway := newTestHost("gw")
+	orphan := newTestHost("orphan-gw")
+	goneGateway := newTestHost("gone-gw")
+	network := newTestNetwork("net", "gw", host, gone)
+	volume := newTestVolume("vol", host, gone)
+
+	client := &testClient{
+		hosts:    []*model.Host{host, gateway, orphan},
+		networks: []*model.Network{network},
+		volumes:  []model.Volume{*volume},
+	}
+	svc := newTestService(t, client)
+	for _, h := range []*model.Host{host, gone, gateway, orphan, goneGateway} {
+		require.Nil(t, NewHost(svc).Carry(h).Write())
+	}
+	require.Nil(t, SaveNetwork(svc, network))
+	require.Nil(t, SaveNetwork(svc, newTestNetwork("net-gone", "orphan-gw")))
+	require.Nil(t, SaveNetwork(svc, newTestNetwork("net-gone-gw", "gone-gw")))
+	require.Nil(t, SaveVolume(svc, volume))
+	require.Nil(t, SaveVolume(svc, newTestVolume("vol-gone", host)))
+
+	// Untracked resources
+	client.hosts = append(client.hosts, newTestHost("untracked"))
+	client.volumes = append(client.volumes, *newTestVolume("vol-untracked"))
+	return svc
+}
+
+func TestFsckReport(t *testing.T) {
+	svc := seedInconsistencies(t)
+
+	drifts, err := Fsck(svc, false, false)
+	require.Nil(t, err)
+	for _, drift := range drifts {
+		assert.False(t, drift.Repaired)
+		assert.Empty(t, drift.Error)
+	}
+	assert.NotNil(t, findDrift(drifts, "host", DriftDangling, "gone"))
+	assert.NotNil(t, findDrift(drifts, "host", DriftUntracked, "untracked"))
+	assert.NotNil(t, findDrift(drifts, "network", DriftDangling, "net-gone"))
+	assert.NotNil(t, findDrift(drifts, "network", DriftDangling, "net-gone-gw"))
+	assert.NotNil(t, findDrift(drifts, "host", DriftDangling, "gone-gw"))
+	assert.NotNil(t, findDrift(drifts, "network", DriftBroken, "net"))
+	assert.NotNil(t, findDrift(drifts, "host", DriftBroken, "orphan-gw"))
+	assert.NotNil(t, findDrift(drifts, "volume", DriftDangling, "vol-gone"))
+	assert.NotNil(t, findDrift(drifts, "volume", DriftBroken, "vol"))
+	assert.NotNil(t, findDrift(drifts, "volume", DriftUntracked, "vol-untracked"))
+	assert.Len(t, drifts, 10)
+
+	// Nothing is changed without repair
+	mh, err := LoadHost(svc, "gone")
+	require.Nil(t, err)
+	assert.NotNil(t, mh)
+	mv, err := LoadVolume(svc, "vol-gone")
+	require.Nil(t, err)
+	assert.NotNil(t, mv)
+}
+
+func TestFsckRepair(t *testing.T) {
+	svc := seedInconsistencies(t)
+
+	drifts, err := Fsck(svc, true, false)
+	require.Nil(t, err)
+
+	// Dangling host
+	drift := findDrift(drifts, "host", DriftDangling, "gone")
+	require.NotNil(t, drift)
+	assert.True(t, drift.Repaired)
+	mh, err := LoadHost(svc, "gone")
+	require.Nil(t, err)
+	assert.Nil(t, mh)
+
+	// Dangling network; its gateway still existing is reported, not removed
+	drift = findDrift(drifts, "network", DriftDangling, "net-gone")
+	require.NotNil(t, drift)
+	assert.True(t, drift.Repaired)
+	mn, err := LoadNetwork(svc, "net-gone")
+	require.Nil(t, err)
+	assert.Nil(t, mn)
+	drift = findDrift(drifts, "host", DriftBroken, "orphan-gw")
+	require.NotNil(t, drift)
+	assert.False(t, drift.Repaired)
+	mh, err = LoadHost(svc, "orphan-gw")
+	require.Nil(t, err)
+	assert.NotNil(t, mh)
+
+	// Dangling network with a gateway not existing anymore: no metadata left behind
+	drift = findDrift(drifts, "network", DriftDangling, "net-gone-gw")
+	require.NotNil(t, drift)
+	assert.True(t, drift.Repaired)
+	drift = findDrift(drifts, "host", DriftDangling, "gone-gw")
+	require.NotNil(t, drift)
+	assert.True(t, drift.Repaired)
+	mh, err = LoadHost(svc, "gone-gw")
+	require.Nil(t, err)
+	assert.Nil(t, mh)
+
+	// Network referencing a host not existing anymore
+	mn, err = LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	require.NotNil(t, mn)
+	networkHostsV1 := propsv1.NewNetworkHosts()
+	require.Nil(t, mn.Get().Properties.Get(NetworkProperty.HostsV1, networkHostsV1))
+	assert.Equal(t, map[string]string{"host": "name-host"}, networkHostsV1.ByID)
+
+	// Dangling volume
+	drift = findDrift(drifts, "volume", DriftDangling, "vol-gone")
+	require.NotNil(t, drift)
+	assert.True(t, drift.Repaired)
+	_, err = LoadVolume(svc, "vol-gone")
+	assert.NotNil(t, err)
+
+	// Volume attached to a host not existing anymore
+	drift = findDrift(drifts, "volume", DriftBroken, "vol")
+	require.NotNil(t, drift)
+	assert.True(t, drift.Repaired)
+	mv, err := LoadVolume(svc, "vol")
+	require.Nil(t, err)
+	require.NotNil(t, mv)
+	volumeAttachmentsV1 := propsv1.NewVolumeAttachments()
+	require.Nil(t, mv.Get().Properties.Get(VolumeProperty.AttachedV1, volumeAttachmentsV1))
+	assert.Equal(t, map[string]string{"host": "name-host"}, volumeAttachmentsV1.Hosts)
+
+	// Only the untracked resources remain
+	drifts, err = Fsck(svc, true, false)
+	require.Nil(t, err)
+	assert.Len(t, drifts, 2)
+	assert.NotNil(t, findDrift(drifts, "host", DriftUntracked, "untracked"))
+	assert.NotNil(t, findDrift(drifts, "volume", DriftUntracked, "vol-untracked"))
+}
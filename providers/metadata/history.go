@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+const (
+	// clustersFolderName is the folder containing the metadata of the clusters (maintained by deploy)
+	clustersFolderName = "clusters"
+)
+
+// HistoryKinds lists the kinds of resources whose metadata history can be consulted
+var HistoryKinds = []string{"cluster", "host", "network", "share", "volume"}
+
+// locateHistory returns the item and the subfolder where the history of the resource 'ref' (name or ID) is stored
+func locateHistory(svc *providers.Service, kind string, ref string) (*metadata.Item, string, error) {
+	var item *metadata.Item
+	switch kind {
+	case "cluster":
+		return metadata.NewItem(svc, clustersFolderName), ".", nil
+	case "host":
+		item = NewHost(svc).item
+	case "network":
+		item = NewNetwork(svc).item
+	case "share":
+		item = NewShare(svc).item
+	case "volume":
+		item = NewVolume(svc).item
+	default:
+		return nil, "", fmt.Errorf("unknown kind of metadata '%s'", kind)
+	}
+	for _, path := range []string{ByNameFolderName, ByIDFolderName} {
+		list, err := item.HistoryFrom(path, ref)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(list) > 0 {
+			return item, path, nil
+		}
+	}
+	return nil, "", model.ResourceNotFoundError(kind+" history", ref)
+}
+
+// History returns the revisions of the metadata of the resource of 'kind' referenced by 'ref' (name or ID), oldest first
+func History(svc *providers.Service, kind string, ref string) ([]*metadata.Revision, error) {
+	item, path, err := locateHistory(svc, kind, ref)
+	if err != nil {
+		return nil, err
+	}
+	return item.HistoryFrom(path, ref)
+}
+
+// Restore writes back the revision 'revision' of the metadata of the resource of 'kind' referenced by 'ref' (name or ID)
+// For resources stored by ID and by name, both entries are rewritten
+func Restore(svc *providers.Service, kind string, ref string, revision string) error {
+	item, path, err := locateHistory(svc, kind, ref)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case "host":
+		mh := &Host{item: item}
+		err = item.ReadRevisionFrom(path, ref, revision, func(buf []byte) (model.Serializable, error) {
+			host := model.NewHost()
+			return host, host.Deserialize(buf)
+		})
+		if err != nil {
+			return err
+		}
+		return mh.Carry(item.Get().(*model.Host)).Write()
+	case "network":
+		mn := &Network{item: item}
+		err = item.ReadRevisionFrom(path, ref, revision, func(buf []byte) (model.Serializable, error) {
+			network := model.NewNetwork()
+			return network, network.Deserialize(buf)
+		})
+		if err != nil {
+			return err
+		}
+		return mn.Carry(item.Get().(*model.Network)).Write()
+	case "share":
+		ms := &Share{item: item}
+		err = item.ReadRevisionFrom(path, ref, revision, func(buf []byte) (model.Serializable, error) {
+			si := &shareItem{}
+			return si, si.Deserialize(buf)
+		})
+		if err != nil {
+			return err
+		}
+		si := item.Get().(*shareItem)
+		return ms.Carry(si.HostID, si.HostName, si.ShareID, si.ShareName).Write()
+	case "volume":
+		mv := &Volume{item: item}
+		err = item.ReadRevisionFrom(path, ref, revision, func(buf []byte) (model.Serializable, error) {
+			volume := model.NewVolume()
+			return volume, volume.Deserialize(buf)
+		})
+		if err != nil {
+			return err
+		}
+		return mv.Carry(item.Get().(*model.Volume)).Write()
+	default:
+		return item.RestoreFrom(path, ref, revision)
+	}
+}
@@ -17,6 +17,7 @@
 package metadata
 
 import (
+	"fmt"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -82,6 +83,21 @@ func (mh *Host) Write() error {
 	return mh.item.WriteInto(ByIDFolderName, *mh.id)
 }
 
+// Reload reloads the content of the metadata from Object Storage, overriding what is in the object
+func (mh *Host) Reload() error {
+	if mh.item == nil {
+		panic("mh.item is nil!")
+	}
+	found, err := mh.ReadByID(*mh.id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("metadata of host '%s' vanished", *mh.name)
+	}
+	return nil
+}
+
 // ReadByID reads the metadata of a network identified by ID from Object Storage
 func (mh *Host) ReadByID(id string) (bool, error) {
 	if mh.item == nil {
@@ -265,8 +281,8 @@ func LoadHost(svc *providers.Service, ref string) (*Host, error) {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (mh *Host) Acquire() {
-	mh.item.Acquire()
+func (mh *Host) Acquire() error {
+	return mh.item.AcquireFrom(ByIDFolderName, *mh.id)
 }
 
 // Release unlocks the metadata
@@ -0,0 +1,514 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/NetworkProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeProperty"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+// importCreator is the creator set in the description of imported resources
+func importCreator() string {
+	return metadata.Author + " (import)"
+}
+
+// ImportHost creates the metadata of a host created outside SafeScale, identified by ID or name in the cloud.
+// The networks and volumes of the host already managed by SafeScale are linked to it.
+// 'privateKey' is the SSH private key allowing to connect to the host (can be empty, SSH will not work then).
+func ImportHost(svc *providers.Service, ref string, privateKey string) (*model.Host, error) {
+	mh, err := LoadHost(svc, ref)
+	if err != nil {
+		return nil, err
+	}
+	if mh != nil {
+		return nil, fmt.Errorf("host '%s' is already managed by SafeScale", ref)
+	}
+	host, err := svc.GetHost(ref)
+	if err != nil {
+		host, err = svc.GetHostByName(ref)
+		if err != nil {
+			return nil, fmt.Errorf("host '%s' not found in the cloud", ref)
+		}
+	}
+	for _, key := range []string{host.ID, host.Name} {
+		mh, err = LoadHost(svc, key)
+		if err != nil {
+			return nil, err
+		}
+		if mh != nil {
+			return nil, fmt.Errorf("host '%s' is already managed by SafeScale", key)
+		}
+	}
+	host.PrivateKey = privateKey
+
+	// Updates property HostDescription
+	hostDescriptionV1 := propsv1.NewHostDescription()
+	err = host.Properties.Get(HostProperty.DescriptionV1, hostDescriptionV1)
+	if err != nil {
+		return nil, err
+	}
+	hostDescriptionV1.Creator = importCreator()
+	err = host.Properties.Set(HostProperty.DescriptionV1, hostDescriptionV1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Updates property HostNetwork with the names of the networks, the default network and its gateway
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	err = host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		networkIDs []string
+		managed    []*Network
+	)
+	for id := range hostNetworkV1.NetworksByID {
+		networkIDs = append(networkIDs, id)
+	}
+	sort.Strings(networkIDs)
+	for _, id := range networkIDs {
+		mn, err := LoadNetworkByID(svc, id)
+		if err != nil {
+			return nil, err
+		}
+		var network *model.Network
+		if mn != nil {
+			network = mn.Get()
+			managed = append(managed, mn)
+		} else {
+			network, err = svc.GetNetwork(id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get network '%s' of host '%s': %s", id, host.Name, err.Error())
+			}
+		}
+		hostNetworkV1.NetworksByID[network.ID] = network.Name
+		hostNetworkV1.NetworksByName[network.Name] = network.ID
+		if network.GatewayID == host.ID {
+			hostNetworkV1.IsGateway = true
+		}
+	}
+	if hostNetworkV1.DefaultNetworkID == "" {
+		// Prefers a network managed by SafeScale
+		if len(managed) > 0 {
+			hostNetworkV1.DefaultNetworkID = managed[0].Get().ID
+		} else if len(networkIDs) > 0 {
+			hostNetworkV1.DefaultNetworkID = networkIDs[0]
+		}
+	}
+	if !hostNetworkV1.IsGateway && hostNetworkV1.PublicIPv4 == "" && hostNetworkV1.PublicIPv6 == "" && hostNetworkV1.DefaultNetworkID != "" {
+		mgw, err := LoadGateway(svc, hostNetworkV1.DefaultNetworkID)
+		if err == nil && mgw != nil {
+			gw := mgw.Get()
+			hostNetworkV1.DefaultGatewayID = gw.ID
+			hostNetworkV1.DefaultGatewayPrivateIP = gw.GetPrivateIP()
+		}
+	}
+	err = host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Updates property HostVolumes with the attached volumes managed by SafeScale
+	attachments, err := svc.ListVolumeAttachments(host.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes attached to host '%s': %s", host.Name, err.Error())
+	}
+	hostVolumesV1 := propsv1.NewHostVolumes()
+	err = host.Properties.Get(HostProperty.VolumesV1, hostVolumesV1)
+	if err != nil {
+		return nil, err
+	}
+	var volumes []*Volume
+	for _, va := range attachments {
+		mv := NewVolume(svc)
+		found, err := mv.ReadByID(va.VolumeID)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			log.Warnf("volume '%s' attached to host '%s' is not managed by SafeScale, ignored", va.VolumeID, host.Name)
+			continue
+		}
+		volume := mv.Get()
+		hostVolumesV1.VolumesByID[volume.ID] = &propsv1.HostVolume{
+			AttachID: va.ID,
+			Device:   va.Device,
+		}
+		hostVolumesV1.VolumesByName[volume.Name] = volume.ID
+		if va.Device != "" {
+			hostVolumesV1.VolumesByDevice[va.Device] = volume.ID
+			hostVolumesV1.DevicesByID[volume.ID] = va.Device
+		}
+		volumes = append(volumes, mv)
+	}
+	err = host.Properties.Set(HostProperty.VolumesV1, hostVolumesV1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Writes the metadata of the host, then links the networks and the volumes to it
+	err = NewHost(svc).Carry(host).Write()
+	if err != nil {
+		return nil, err
+	}
+	for _, mn := range managed {
+		if mn.Get().GatewayID == host.ID {
+			// A gateway is not listed in the hosts of its network
+			continue
+		}
+		err = updateNetwork(mn, func(network *model.Network) error {
+			networkHostsV1 := propsv1.NewNetworkHosts()
+			err := network.Properties.Get(NetworkProperty.HostsV1, networkHostsV1)
+			if err != nil {
+				return err
+			}
+			networkHostsV1.ByID[host.ID] = host.Name
+			networkHostsV1.ByName[host.Name] = host.ID
+			return network.Properties.Set(NetworkProperty.HostsV1, networkHostsV1)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, mv := range volumes {
+		err = updateVolume(mv, func(volume *model.Volume) error {
+			volumeAttachedV1 := propsv1.NewVolumeAttachments()
+			err := volume.Properties.Get(VolumeProperty.AttachedV1, volumeAttachedV1)
+			if err != nil {
+				return err
+			}
+			volumeAttachedV1.Hosts[host.ID] = host.Name
+			return volume.Properties.Set(VolumeProperty.AttachedV1, volumeAttachedV1)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return host, nil
+}
+
+// ImportNetwork creates the metadata of a network created outside SafeScale, identified by ID or name in the cloud.
+// The hosts already managed by SafeScale and connected to the network are listed in its metadata.
+// 'gatewayRef', if not empty, designates a host managed by SafeScale acting as gateway of the network.
+func ImportNetwork(svc *providers.Service, ref string, gatewayRef string) (*model.Network, error) {
+	mn, err := LoadNetwork(svc, ref)
+	if err != nil {
+		return nil, err
+	}
+	if mn != nil {
+		return nil, fmt.Errorf("network '%s' is already managed by SafeScale", ref)
+	}
+	network, err := svc.GetNetwork(ref)
+	if err != nil {
+		network, err = svc.GetNetworkByName(ref)
+		if err != nil {
+			return nil, fmt.Errorf("network '%s' not found in the cloud", ref)
+		}
+	}
+	for _, key := range []string{network.ID, network.Name} {
+		mn, err = LoadNetwork(svc, key)
+		if err != nil {
+			return nil, err
+		}
+		if mn != nil {
+			return nil, fmt.Errorf("network '%s' is already managed by SafeScale", key)
+		}
+	}
+	if network.Properties == nil {
+		network.Properties = model.NewExtensions()
+	}
+
+	var gateway *Host
+	if gatewayRef != "" {
+		gateway, err = LoadHost(svc, gatewayRef)
+		if err != nil {
+			return nil, err
+		}
+		if gateway == nil {
+			return nil, fmt.Errorf("gateway '%s' is not managed by SafeScale, import it first", gatewayRef)
+		}
+		network.GatewayID = gateway.Get().ID
+	}
+
+	// Sets property NetworkDescription
+	err = network.Properties.Set(NetworkProperty.DescriptionV1, &propsv1.NetworkDescription{
+		Purpose: "imported by " + importCreator(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sets property NetworkHosts with the hosts connected to the network
+	networkHostsV1 := propsv1.NewNetworkHosts()
+	var hosts []*model.Host
+	err = NewHost(svc).Browse(func(host *model.Host) error {
+		hostNetworkV1 := propsv1.NewHostNetwork()
+		err := host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+		if err != nil {
+			return err
+		}
+		if _, found := hostNetworkV1.NetworksByID[network.ID]; !found || host.ID == network.GatewayID {
+			return nil
+		}
+		networkHostsV1.ByID[host.ID] = host.Name
+		networkHostsV1.ByName[host.Name] = host.ID
+		hosts = append(hosts, host)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = network.Properties.Set(NetworkProperty.HostsV1, networkHostsV1)
+	if err != nil {
+		return nil, err
+	}
+
+	err = NewNetwork(svc).Carry(network).Write()
+	if err != nil {
+		return nil, err
+	}
+
+	// Marks the gateway, and makes the hosts without public IP of the network use it
+	if gateway != nil {
+		gw := gateway.Get()
+		err = updateHost(svc, gw, func(host *model.Host) error {
+			hostNetworkV1 := propsv1.NewHostNetwork()
+			err := host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+			if err != nil {
+				return err
+			}
+			hostNetworkV1.IsGateway = true
+			return host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			err = updateHost(svc, host, func(host *model.Host) error {
+				hostNetworkV1 := propsv1.NewHostNetwork()
+				err := host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+				if err != nil {
+					return err
+				}
+				if hostNetworkV1.DefaultNetworkID != network.ID || hostNetworkV1.PublicIPv4 != "" || hostNetworkV1.PublicIPv6 != "" {
+					return nil
+				}
+				hostNetworkV1.DefaultGatewayID = gw.ID
+				hostNetworkV1.DefaultGatewayPrivateIP = gw.GetPrivateIP()
+				return host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return network, nil
+}
+
+// ImportVolume creates the metadata of a volume created outside SafeScale, identified by ID or name in the cloud.
+// The attachments of the volume to hosts already managed by SafeScale are recorded.
+func ImportVolume(svc *providers.Service, ref string) (*model.Volume, error) {
+	mv := NewVolume(svc)
+	found, err := mv.ReadByID(ref)
+	if err == nil && !found {
+		found, err = mv.ReadByName(ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, fmt.Errorf("volume '%s' is already managed by SafeScale", ref)
+	}
+	volume, err := svc.GetVolume(ref)
+	if err != nil {
+		// No lookup by name in ClientAPI, searches in the list
+		list, lerr := svc.ListVolumes()
+		if lerr != nil {
+			return nil, lerr
+		}
+		var matches []string
+		for _, v := range list {
+			if v.Name == ref {
+				matches = append(matches, v.ID)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("volume '%s' not found in the cloud", ref)
+		case 1:
+			volume, err = svc.GetVolume(matches[0])
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("several volumes are named '%s', use the ID", ref)
+		}
+	}
+	for _, key := range []string{volume.ID, volume.Name} {
+		found, err = NewVolume(svc).ReadByID(key)
+		if err == nil && !found {
+			found, err = NewVolume(svc).ReadByName(key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return nil, fmt.Errorf("volume '%s' is already managed by SafeScale", key)
+		}
+	}
+	if volume.Properties == nil {
+		volume.Properties = model.NewExtensions()
+	}
+
+	err = volume.Properties.Set(VolumeProperty.DescriptionV1, &propsv1.VolumeDescription{
+		Purpose: "imported by " + importCreator(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Looks for the hosts managed by SafeScale attaching the volume
+	volumeAttachedV1 := propsv1.NewVolumeAttachments()
+	attached := map[string]*model.VolumeAttachment{}
+	var hosts []*model.Host
+	err = NewHost(svc).Browse(func(host *model.Host) error {
+		attachments, err := svc.ListVolumeAttachments(host.ID)
+		if err != nil {
+			log.Warnf("failed to list volumes attached to host '%s': %s", host.Name, err.Error())
+			return nil
+		}
+		for _, va := range attachments {
+			if va.VolumeID == volume.ID {
+				va := va
+				attached[host.ID] = &va
+				volumeAttachedV1.Hosts[host.ID] = host.Name
+				hosts = append(hosts, host)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = volume.Properties.Set(VolumeProperty.AttachedV1, volumeAttachedV1)
+	if err != nil {
+		return nil, err
+	}
+
+	err = NewVolume(svc).Carry(volume).Write()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, host := range hosts {
+		va := attached[host.ID]
+		err = updateHost(svc, host, func(host *model.Host) error {
+			hostVolumesV1 := propsv1.NewHostVolumes()
+			err := host.Properties.Get(HostProperty.VolumesV1, hostVolumesV1)
+			if err != nil {
+				return err
+			}
+			hostVolumesV1.VolumesByID[volume.ID] = &propsv1.HostVolume{
+				AttachID: va.ID,
+				Device:   va.Device,
+			}
+			hostVolumesV1.VolumesByName[volume.Name] = volume.ID
+			if va.Device != "" {
+				hostVolumesV1.VolumesByDevice[va.Device] = volume.ID
+				hostVolumesV1.DevicesByID[volume.ID] = va.Device
+			}
+			return host.Properties.Set(HostProperty.VolumesV1, hostVolumesV1)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return volume, nil
+}
+
+// updateNetwork locks the metadata of the network, reloads it, applies 'update' on it, then writes it
+func updateNetwork(mn *Network, update func(*model.Network) error) error {
+	err := mn.Acquire()
+	if err != nil {
+		return err
+	}
+	defer mn.Release()
+
+	err = mn.Reload()
+	if err != nil {
+		return err
+	}
+	err = update(mn.Get())
+	if err != nil {
+		return err
+	}
+	return mn.Write()
+}
+
+// updateVolume locks the metadata of the volume, reloads it, applies 'update' on it, then writes it
+func updateVolume(mv *Volume, update func(*model.Volume) error) error {
+	err := mv.Acquire()
+	if err != nil {
+		return err
+	}
+	defer mv.Release()
+
+	err = mv.Reload()
+	if err != nil {
+		return err
+	}
+	err = update(mv.Get())
+	if err != nil {
+		return err
+	}
+	return mv.Write()
+}
+
+// updateHost locks the metadata of the host, reloads it, applies 'update' on it, then writes it
+func updateHost(svc *providers.Service, host *model.Host, update func(*model.Host) error) error {
+	mh := NewHost(svc).Carry(host)
+	err := mh.Acquire()
+	if err != nil {
+		return err
+	}
+	defer mh.Release()
+
+	found, err := mh.ReadByID(host.ID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("metadata of host '%s' vanished", host.Name)
+	}
+	err = update(mh.Get())
+	if err != nil {
+		return err
+	}
+	return mh.Write()
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/NetworkProperty"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeProperty"
+	propsv1 "github.com/CS-SI/SafeScale/providers/model/properties/v1"
+)
+
+// connect sets the networks of a host, the first one being its default network
+func connect(host *model.Host, networks ...*model.Network) *model.Host {
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	for _, network := range networks {
+		hostNetworkV1.NetworksByID[network.ID] = ""
+	}
+	if len(networks) > 0 {
+		hostNetworkV1.DefaultNetworkID = networks[0].ID
+	}
+	_ = host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+	return host
+}
+
+func loadHostNetwork(t *testing.T, svc *providers.Service, ref string) *propsv1.HostNetwork {
+	mh, err := LoadHost(svc, ref)
+	require.Nil(t, err)
+	require.NotNil(t, mh)
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	require.Nil(t, mh.Get().Properties.Get(HostProperty.NetworkV1, hostNetworkV1))
+	return hostNetworkV1
+}
+
+func TestImportHost(t *testing.T) {
+	network := newTestNetwork("net", "")
+	external := newTestNetwork("ext", "")
+	gateway := newTestHost("gw")
+	volume := newTestVolume("vol")
+	host := connect(newTestHost("host"), network, external)
+	client := &testClient{
+		hosts:    []*model.Host{gateway, host},
+		networks: []*model.Network{network, external},
+		volumes:  []model.Volume{*volume, *newTestVolume("unmanaged")},
+		attachments: map[string][]model.VolumeAttachment{
+			"host": {
+				{ID: "att-1", VolumeID: "vol", ServerID: "host", Device: "/dev/vdb"},
+				{ID: "att-2", VolumeID: "unmanaged", ServerID: "host", Device: "/dev/vdc"},
+			},
+		},
+	}
+	svc := newTestService(t, client)
+	require.Nil(t, SaveNetwork(svc, network))
+	require.Nil(t, SaveGateway(svc, gateway, "net"))
+	require.Nil(t, SaveVolume(svc, volume))
+
+	// The volume is being updated by someone else during the import
+	mv, err := LoadVolume(svc, "vol")
+	require.Nil(t, err)
+	require.Nil(t, mv.Acquire())
+	done := make(chan error)
+	go func() {
+		_, err := ImportHost(svc, "name-host", "private key")
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	mv.Get().Size = 42
+	require.Nil(t, mv.Write())
+	mv.Release()
+	require.Nil(t, <-done)
+
+	mh, err := LoadHost(svc, "host")
+	require.Nil(t, err)
+	require.NotNil(t, mh)
+	assert.Equal(t, "private key", mh.Get().PrivateKey)
+	hostDescriptionV1 := propsv1.NewHostDescription()
+	require.Nil(t, mh.Get().Properties.Get(HostProperty.DescriptionV1, hostDescriptionV1))
+	assert.Equal(t, importCreator(), hostDescriptionV1.Creator)
+
+	hostNetworkV1 := loadHostNetwork(t, svc, "host")
+	assert.Equal(t, map[string]string{"net": "name-net", "ext": "name-ext"}, hostNetworkV1.NetworksByID)
+	assert.Equal(t, "net", hostNetworkV1.DefaultNetworkID)
+	assert.Equal(t, "gw", hostNetworkV1.DefaultGatewayID)
+	assert.False(t, hostNetworkV1.IsGateway)
+
+	// Only the volumes managed by SafeScale are linked
+	hostVolumesV1 := propsv1.NewHostVolumes()
+	require.Nil(t, mh.Get().Properties.Get(HostProperty.VolumesV1, hostVolumesV1))
+	require.Len(t, hostVolumesV1.VolumesByID, 1)
+	assert.Equal(t, "/dev/vdb", hostVolumesV1.VolumesByID["vol"].Device)
+	assert.Equal(t, "vol", hostVolumesV1.VolumesByDevice["/dev/vdb"])
+
+	// The managed network and volume list the host, without losing the concurrent update
+	mn, err := LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	networkHostsV1 := propsv1.NewNetworkHosts()
+	require.Nil(t, mn.Get().Properties.Get(NetworkProperty.HostsV1, networkHostsV1))
+	assert.Equal(t, map[string]string{"host": "name-host"}, networkHostsV1.ByID)
+	mv, err = LoadVolume(svc, "vol")
+	require.Nil(t, err)
+	assert.Equal(t, 42, mv.Get().Size)
+	volumeAttachmentsV1 := propsv1.NewVolumeAttachments()
+	require.Nil(t, mv.Get().Properties.Get(VolumeProperty.AttachedV1, volumeAttachmentsV1))
+	assert.Equal(t, map[string]string{"host": "name-host"}, volumeAttachmentsV1.Hosts)
+
+	_, err = ImportHost(svc, "host", "")
+	assert.NotNil(t, err)
+	_, err = ImportHost(svc, "unknown", "")
+	assert.NotNil(t, err)
+}
+
+func TestImportNetwork(t *testing.T) {
+	network := newTestNetwork("net", "")
+	gateway := connect(newTestHost("gw"), network)
+	host := connect(newTestHost("host"), network)
+	other := connect(newTestHost("other"))
+	client := &testClient{
+		hosts:    []*model.Host{gateway, host, other},
+		networks: []*model.Network{network},
+	}
+	svc := newTestService(t, client)
+	for _, h := range []*model.Host{gateway, host, other} {
+		require.Nil(t, NewHost(svc).Carry(h).Write())
+	}
+
+	_, err := ImportNetwork(svc, "net", "unknown")
+	assert.NotNil(t, err)
+	imported, err := ImportNetwork(svc, "name-net", "name-gw")
+	require.Nil(t, err)
+	assert.Equal(t, "gw", imported.GatewayID)
+
+	// The gateway is not listed in the hosts of the network
+	mn, err := LoadNetwork(svc, "net")
+	require.Nil(t, err)
+	require.NotNil(t, mn)
+	networkHostsV1 := propsv1.NewNetworkHosts()
+	require.Nil(t, mn.Get().Properties.Get(NetworkProperty.HostsV1, networkHostsV1))
+	assert.Equal(t, map[string]string{"host": "name-host"}, networkHostsV1.ByID)
+	networkDescriptionV1 := propsv1.NewNetworkDescription()
+	require.Nil(t, mn.Get().Properties.Get(NetworkProperty.DescriptionV1, networkDescriptionV1))
+	assert.Equal(t, "imported by "+importCreator(), networkDescriptionV1.Purpose)
+
+	assert.True(t, loadHostNetwork(t, svc, "gw").IsGateway)
+	assert.Equal(t, "gw", loadHostNetwork(t, svc, "host").DefaultGatewayID)
+	assert.Empty(t, loadHostNetwork(t, svc, "other").DefaultGatewayID)
+
+	_, err = ImportNetwork(svc, "net", "")
+	assert.NotNil(t, err)
+}
+
+func TestImportVolume(t *testing.T) {
+	host := newTestHost("host")
+	client := &testClient{
+		hosts:   []*model.Host{host},
+		volumes: []model.Volume{*newTestVolume("vol"), *newTestVolume("twin"), *newTestVolume("twin")},
+		attachments: map[string][]model.VolumeAttachment{
+			"host": {{ID: "att-1", VolumeID: "vol", ServerID: "host", Device: "/dev/vdb"}},
+		},
+	}
+	client.volumes[2].ID = "twin-2"
+	svc := newTestService(t, client)
+	require.Nil(t, NewHost(svc).Carry(host).Write())
+
+	// Volumes are found by name in the list of the cloud
+	_, err := ImportVolume(svc, "name-twin")
+	assert.NotNil(t, err)
+	_, err = ImportVolume(svc, "unknown")
+	assert.NotNil(t, err)
+	_, err = ImportVolume(svc, "name-vol")
+	require.Nil(t, err)
+
+	mv, err := LoadVolume(svc, "vol")
+	require.Nil(t, err)
+	volumeAttachmentsV1 := propsv1.NewVolumeAttachments()
+	require.Nil(t, mv.Get().Properties.Get(VolumeProperty.AttachedV1, volumeAttachmentsV1))
+	assert.Equal(t, map[string]string{"host": "name-host"}, volumeAttachmentsV1.Hosts)
+
+	mh, err := LoadHost(svc, "host")
+	require.Nil(t, err)
+	hostVolumesV1 := propsv1.NewHostVolumes()
+	require.Nil(t, mh.Get().Properties.Get(HostProperty.VolumesV1, hostVolumesV1))
+	require.NotNil(t, hostVolumesV1.VolumesByID["vol"])
+	assert.Equal(t, "att-1", hostVolumesV1.VolumesByID["vol"].AttachID)
+	assert.Equal(t, "vol", hostVolumesV1.VolumesByName["name-vol"])
+	assert.Equal(t, "/dev/vdb", hostVolumesV1.DevicesByID["vol"])
+
+	_, err = ImportVolume(svc, "vol")
+	assert.NotNil(t, err)
+}
@@ -287,8 +287,8 @@ func (m *Network) ListHosts() ([]*model.Host, error) {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (m *Network) Acquire() {
-	m.item.Acquire()
+func (m *Network) Acquire() error {
+	return m.item.AcquireFrom(ByIDFolderName, *m.id)
 }
 
 // Release unlocks the metadata
@@ -452,8 +452,8 @@ func (mg *Gateway) Delete() error {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (mg *Gateway) Acquire() {
-	mg.host.Acquire()
+func (mg *Gateway) Acquire() error {
+	return mg.host.Acquire()
 }
 
 // Release unlocks the metadata
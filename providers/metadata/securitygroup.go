@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+const (
+	// securityGroupsFolderName is the technical name of the container used to store security group info
+	securityGroupsFolderName = "securitygroups"
+)
+
+// SecurityGroup links Object Storage folder and SecurityGroups
+type SecurityGroup struct {
+	item *metadata.Item
+	name *string
+	id   *string
+}
+
+// NewSecurityGroup creates an instance of metadata.SecurityGroup
+func NewSecurityGroup(svc *providers.Service) *SecurityGroup {
+	return &SecurityGroup{
+		item: metadata.NewItem(svc, securityGroupsFolderName),
+		name: nil,
+		id:   nil,
+	}
+}
+
+// Carry links a SecurityGroup instance to the Metadata instance
+func (msg *SecurityGroup) Carry(sg *model.SecurityGroup) *SecurityGroup {
+	if sg == nil {
+		panic("security group is nil!")
+	}
+	msg.item.Carry(sg)
+	msg.name = &sg.Name
+	msg.id = &sg.ID
+	return msg
+}
+
+// Get returns the SecurityGroup instance linked to metadata
+func (msg *SecurityGroup) Get() *model.SecurityGroup {
+	if msg.item == nil {
+		panic("msg.item is nil!")
+	}
+	if sg, ok := msg.item.Get().(*model.SecurityGroup); ok {
+		return sg
+	}
+	panic("invalid content in security group metadata")
+}
+
+// Write updates the metadata corresponding to the security group in the Object Storage
+func (msg *SecurityGroup) Write() error {
+	if msg.item == nil {
+		panic("msg.item is nil!")
+	}
+
+	err := msg.item.WriteInto(ByIDFolderName, *msg.id)
+	if err != nil {
+		return err
+	}
+	return msg.item.WriteInto(ByNameFolderName, *msg.name)
+}
+
+// Reload reloads the content of the Object Storage, overriding what is in the metadata instance
+func (msg *SecurityGroup) Reload() error {
+	if msg.item == nil {
+		panic("msg.item is nil!")
+	}
+	found, err := msg.ReadByID(*msg.id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("metadata of security group '%s' vanished", *msg.name)
+	}
+	return nil
+}
+
+// ReadByID reads the metadata of a security group identified by ID from Object Storage
+func (msg *SecurityGroup) ReadByID(id string) (bool, error) {
+	return msg.readFrom(ByIDFolderName, id)
+}
+
+// ReadByName reads the metadata of a security group identified by name
+func (msg *SecurityGroup) ReadByName(name string) (bool, error) {
+	return msg.readFrom(ByNameFolderName, name)
+}
+
+// readFrom reads the metadata of a security group from a folder of the Object Storage
+func (msg *SecurityGroup) readFrom(folder string, ref string) (bool, error) {
+	var sg model.SecurityGroup
+	found, err := msg.item.ReadFrom(folder, ref, func(buf []byte) (model.Serializable, error) {
+		err := (&sg).Deserialize(buf)
+		if err != nil {
+			return nil, err
+		}
+		return &sg, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, nil
+	}
+
+	msg.Carry(&sg)
+	return true, nil
+}
+
+// Delete delete the metadata corresponding to the security group
+func (msg *SecurityGroup) Delete() error {
+	err := msg.item.DeleteFrom(ByIDFolderName, *msg.id)
+	if err != nil {
+		return err
+	}
+	err = msg.item.DeleteFrom(ByNameFolderName, *msg.name)
+	if err != nil {
+		return err
+	}
+	msg.item.Reset()
+	msg.name = nil
+	msg.id = nil
+	return nil
+}
+
+// Browse walks through security group folder and executes a callback for each entries
+func (msg *SecurityGroup) Browse(callback func(*model.SecurityGroup) error) error {
+	return msg.item.BrowseInto(ByIDFolderName, func(buf []byte) error {
+		sg := model.SecurityGroup{}
+		err := (&sg).Deserialize(buf)
+		if err != nil {
+			return err
+		}
+		return callback(&sg)
+	})
+}
+
+// Acquire waits until the write lock is available, then locks the metadata
+func (msg *SecurityGroup) Acquire() error {
+	return msg.item.AcquireFrom(ByIDFolderName, *msg.id)
+}
+
+// Release unlocks the metadata
+func (msg *SecurityGroup) Release() {
+	msg.item.Release()
+}
+
+// SaveSecurityGroup saves the SecurityGroup definition in Object Storage
+func SaveSecurityGroup(svc *providers.Service, sg *model.SecurityGroup) error {
+	return NewSecurityGroup(svc).Carry(sg).Write()
+}
+
+// RemoveSecurityGroup removes the SecurityGroup definition from Object Storage
+func RemoveSecurityGroup(svc *providers.Service, ref string) error {
+	m, err := LoadSecurityGroup(svc, ref)
+	if err != nil {
+		return err
+	}
+	return m.Delete()
+}
+
+// LoadSecurityGroup gets the SecurityGroup definition from Object Storage
+func LoadSecurityGroup(svc *providers.Service, ref string) (*SecurityGroup, error) {
+	m := NewSecurityGroup(svc)
+	found, err := m.ReadByID(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		found, err = m.ReadByName(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, model.ResourceNotFoundError("security group", ref)
+	}
+	return m, nil
+}
@@ -228,8 +228,8 @@ func (ms *Share) Browse(callback func(string, string) error) error {
 // }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (ms *Share) Acquire() {
-	ms.item.Acquire()
+func (ms *Share) Acquire() error {
+	return ms.item.AcquireFrom(ByIDFolderName, *ms.id)
 }
 
 // Release unlocks the metadata
@@ -156,6 +156,16 @@ func (mv *Volume) Delete() error {
 	return nil
 }
 
+// Acquire waits until the write lock is available, then locks the metadata
+func (mv *Volume) Acquire() error {
+	return mv.item.AcquireFrom(ByIDFolderName, *mv.id)
+}
+
+// Release unlocks the metadata
+func (mv *Volume) Release() {
+	mv.item.Release()
+}
+
 // Browse walks through volume folder and executes a callback for each entries
 func (mv *Volume) Browse(callback func(*model.Volume) error) error {
 	return mv.item.BrowseInto(ByIDFolderName, func(buf []byte) error {
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers/model/enums/FloatingIPModel"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeSpeed"
+)
+
+// Capabilities describes what a provider supports, allowing to reject a request it can't fulfil before
+// creating anything
+type Capabilities struct {
+	// VolumeSpeeds lists the speeds of the volumes offered by the provider
+	VolumeSpeeds []VolumeSpeed.Enum `json:"volume_speeds,omitempty"`
+	// GPU tells if host templates with GPU are available
+	GPU bool `json:"gpu,omitempty"`
+	// FloatingIP tells how hosts get a public IP
+	FloatingIP FloatingIPModel.Enum `json:"floating_ip,omitempty"`
+	// MaxVolumesPerHost is the maximum number of volumes attached to a host, 0 if not limited
+	MaxVolumesPerHost int `json:"max_volumes_per_host,omitempty"`
+	// PrivateNetworks tells if private networks, reaching Internet through a gateway, can be created
+	PrivateNetworks bool `json:"private_networks,omitempty"`
+	// ObjectStorage is the type of Object Storage (swift, s3, local...), empty if there is none
+	ObjectStorage string `json:"object_storage,omitempty"`
+}
+
+// CheckHost returns an error if a host with gpu GPUs, and a public IP if public is true, can't be created
+func (c Capabilities) CheckHost(gpu int, public bool) error {
+	if gpu > 0 && !c.GPU {
+		return ResourceInvalidRequestError("host", "the provider doesn't offer GPU")
+	}
+	if public && c.FloatingIP == FloatingIPModel.NONE {
+		return ResourceInvalidRequestError("host", "the provider doesn't allow public IP on hosts")
+	}
+	return nil
+}
+
+// CheckNetwork returns an error if a private network with its gateway can't be created
+func (c Capabilities) CheckNetwork() error {
+	if !c.PrivateNetworks {
+		return ResourceInvalidRequestError("network", "the provider doesn't allow private networks")
+	}
+	if c.FloatingIP == FloatingIPModel.NONE {
+		return ResourceInvalidRequestError("network", "the provider doesn't allow public IP on the gateway")
+	}
+	return nil
+}
+
+// CheckVolume returns an error if a volume of the given speed can't be created, a speed not offered being
+// served by a slower one as the drivers do
+func (c Capabilities) CheckVolume(speed VolumeSpeed.Enum) error {
+	for _, s := range c.VolumeSpeeds {
+		if s <= speed {
+			return nil
+		}
+	}
+	return ResourceInvalidRequestError("volume", fmt.Sprintf("the provider doesn't offer volumes of speed '%s' or slower", speed.String()))
+}
+
+// CheckVolumeAttachment returns an error if a volume can't be attached to a host having already count volumes
+func (c Capabilities) CheckVolumeAttachment(count int) error {
+	if c.MaxVolumesPerHost > 0 && count >= c.MaxVolumesPerHost {
+		return ResourceInvalidRequestError("volume attachment", fmt.Sprintf("the provider allows %d volumes per host at most", c.MaxVolumesPerHost))
+	}
+	return nil
+}
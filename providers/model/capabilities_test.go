@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/providers/model/enums/FloatingIPModel"
+	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeSpeed"
+)
+
+func TestCapabilities_Full(t *testing.T) {
+	full := Capabilities{
+		VolumeSpeeds:    []VolumeSpeed.Enum{VolumeSpeed.COLD, VolumeSpeed.HDD, VolumeSpeed.SSD},
+		GPU:             true,
+		FloatingIP:      FloatingIPModel.FLOATING,
+		PrivateNetworks: true,
+	}
+	assert.Nil(t, full.CheckHost(1, true))
+	assert.Nil(t, full.CheckNetwork())
+	assert.Nil(t, full.CheckVolume(VolumeSpeed.SSD))
+	assert.Nil(t, full.CheckVolumeAttachment(100))
+}
+
+func TestCapabilities_Limited(t *testing.T) {
+	limited := Capabilities{VolumeSpeeds: []VolumeSpeed.Enum{VolumeSpeed.HDD}, MaxVolumesPerHost: 2}
+	assert.IsType(t, ErrResourceInvalidRequest{}, limited.CheckHost(1, false))
+	assert.IsType(t, ErrResourceInvalidRequest{}, limited.CheckHost(0, true))
+	assert.Nil(t, limited.CheckHost(0, false))
+	assert.IsType(t, ErrResourceInvalidRequest{}, limited.CheckNetwork())
+	// A speed not offered is served by a slower one
+	assert.IsType(t, ErrResourceInvalidRequest{}, limited.CheckVolume(VolumeSpeed.COLD))
+	assert.Nil(t, limited.CheckVolume(VolumeSpeed.HDD))
+	assert.Nil(t, limited.CheckVolume(VolumeSpeed.SSD))
+	assert.Nil(t, limited.CheckVolumeAttachment(1))
+	assert.IsType(t, ErrResourceInvalidRequest{}, limited.CheckVolumeAttachment(2))
+
+	// Private networks need a public IP on their gateway
+	noPublicIP := Capabilities{PrivateNetworks: true, FloatingIP: FloatingIPModel.NONE}
+	assert.IsType(t, ErrResourceInvalidRequest{}, noPublicIP.CheckNetwork())
+	assert.IsType(t, ErrResourceInvalidRequest{}, Capabilities{}.CheckVolume(VolumeSpeed.SSD))
+}
@@ -0,0 +1,32 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+//Package FloatingIPModel defines an enum to represent the way hosts get a public IP
+package FloatingIPModel
+
+//go:generate stringer -type=Enum
+
+//Enum represents the way hosts get a public IP
+type Enum int
+
+const (
+	// NONE when hosts can't have a public IP
+	NONE Enum = iota
+	// FLOATING when a public IP is associated to the host after its creation
+	FLOATING
+	// DIRECT when the host is connected to the public network of the provider at its creation
+	DIRECT
+)
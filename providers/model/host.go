@@ -91,6 +91,9 @@ type HostRequest struct {
 	ImageID string
 	// KeyPair is the (optional) specific KeyPair to use (if not provided, a new KeyPair will be generated)
 	KeyPair *KeyPair
+	// UserData contains (optional) cloud-init parts, cloud-config documents or shell scripts, run at first boot
+	// besides the SafeScale initialization script
+	UserData []string
 }
 
 // HostSize ...
@@ -109,6 +112,7 @@ type Host struct {
 	Name       string         `json:"name,omitempty"`
 	LastState  HostState.Enum `json:"state,omitempty"`
 	PrivateKey string         `json:"private_key,omitempty"`
+	HostKey    string         `json:"host_key,omitempty"`
 	Properties *Extensions    `json:"properties,omitempty"`
 }
 
@@ -31,6 +31,8 @@ type GatewayRequest struct {
 	KeyPair *KeyPair
 	// Name is the name to give to the gateway
 	Name string
+	// UserData contains (optional) cloud-init parts run at first boot of the gateway (see HostRequest)
+	UserData []string
 }
 
 // NetworkRequest represents network requirements to create a subnet where Mask is defined in CIDR notation
@@ -78,3 +80,12 @@ func (n *Network) Deserialize(buf []byte) error {
 	}
 	return nil
 }
+
+// VIP is a private virtual IP address, which can be held in turn by the hosts bound to it
+type VIP struct {
+	ID        string   `json:"id,omitempty"`         // ID of the VIP (from provider)
+	Name      string   `json:"name,omitempty"`       // Name of the VIP
+	NetworkID string   `json:"network_id,omitempty"` // contains the ID of the network of the VIP
+	PrivateIP string   `json:"private_ip,omitempty"` // contains the IP address of the VIP in the network
+	Hosts     []string `json:"hosts,omitempty"`      // contains the IDs of the hosts allowed to hold the VIP
+}
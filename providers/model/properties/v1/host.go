@@ -237,6 +237,29 @@ type HostShare struct {
 	ShareOptions  string            `json:"share_options,omitempty"`    // the options (other than acls) to set on the share
 	ClientsByID   map[string]string `json:"clients_by_id,omit_empty"`   // contains the name of the hosts mounting the export, indexed by ID
 	ClientsByName map[string]string `json:"clients_by_name,omit_empty"` // contains the ID of the hosts mounting the export, indexed by Name
+	HA            *HostShareHA      `json:"ha,omitempty"`               // contains the high availability information, if the share is highly available
+}
+
+// HostShareHA describes how a highly available share is served in turn by several hosts
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental/overriding fields
+type HostShareHA struct {
+	Volume       string            `json:"volume"`         // the name of the GlusterFS volume replicating the share between the servers
+	VIPID        string            `json:"vip_id"`         // the ID of the VIP the clients mount the share from
+	VIPNetworkID string            `json:"vip_network_id"` // the ID of the network of the VIP
+	VIP          string            `json:"vip"`            // the IP address of the VIP
+	PrimaryID    string            `json:"primary_id"`     // the ID of the host holding the VIP while it's running
+	ServersByID  map[string]string `json:"servers_by_id"`  // contains the name of the hosts serving the share, indexed by ID
+	ServersIP    map[string]string `json:"servers_ip"`     // contains the IP address in the network of the VIP of the hosts serving the share, indexed by ID
+}
+
+// NewHostShareHA ...
+func NewHostShareHA() *HostShareHA {
+	return &HostShareHA{
+		ServersByID: map[string]string{},
+		ServersIP:   map[string]string{},
+	}
 }
 
 // NewHostShare ...
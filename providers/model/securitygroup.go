@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SecurityGroupRequest represents a security group request
+type SecurityGroupRequest struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// NetworkID is the ID of the network in which the security group is used, needed by the providers scoping
+	// the security groups to a network (like the VPCs of AWS)
+	NetworkID string `json:"network_id,omitempty"`
+}
+
+// SecurityGroupRule represents a rule of a security group, allowing incoming traffic
+type SecurityGroupRule struct {
+	ID string `json:"id,omitempty"`
+	// Protocol is "tcp", "udp" or "icmp"
+	Protocol string `json:"protocol,omitempty"`
+	// FromPort and ToPort define the range of ports allowed (type and code for icmp, -1 for all)
+	FromPort int `json:"from_port,omitempty"`
+	ToPort   int `json:"to_port,omitempty"`
+	// CIDR is the range of source addresses allowed
+	CIDR string `json:"cidr,omitempty"`
+}
+
+// Validate checks the rule is valid, and sets its default values (all addresses, and all icmp types)
+func (r *SecurityGroupRule) Validate() error {
+	r.Protocol = strings.ToLower(r.Protocol)
+	switch r.Protocol {
+	case "tcp", "udp":
+		if r.FromPort < 1 || r.FromPort > 65535 || r.ToPort < r.FromPort || r.ToPort > 65535 {
+			return fmt.Errorf("invalid port range %d-%d", r.FromPort, r.ToPort)
+		}
+	case "icmp":
+		if r.FromPort == 0 && r.ToPort == 0 {
+			r.FromPort, r.ToPort = -1, -1
+		}
+	default:
+		return fmt.Errorf("invalid protocol '%s', must be tcp, udp or icmp", r.Protocol)
+	}
+	if r.CIDR == "" {
+		r.CIDR = "0.0.0.0/0"
+	}
+	if _, _, err := net.ParseCIDR(r.CIDR); err != nil {
+		return fmt.Errorf("invalid CIDR '%s'", r.CIDR)
+	}
+	return nil
+}
+
+// SecurityGroup represents a security group, set of rules allowing incoming traffic to the hosts bound to it
+type SecurityGroup struct {
+	ID          string              `json:"id,omitempty"`
+	Name        string              `json:"name,omitempty"`
+	Description string              `json:"description,omitempty"`
+	NetworkID   string              `json:"network_id,omitempty"`
+	Rules       []SecurityGroupRule `json:"rules,omitempty"`
+	// Hosts contains the IDs of the hosts bound to the security group
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// Serialize serializes SecurityGroup instance into bytes (output json code)
+func (sg *SecurityGroup) Serialize() ([]byte, error) {
+	return SerializeToJSON(sg)
+}
+
+// Deserialize reads json code and restores a SecurityGroup
+func (sg *SecurityGroup) Deserialize(buf []byte) error {
+	return DeserializeFromJSON(buf, sg)
+}
+
+// IsBoundTo tells if the security group is bound to the host identified by hostID
+func (sg *SecurityGroup) IsBoundTo(hostID string) bool {
+	for _, id := range sg.Hosts {
+		if id == hostID {
+			return true
+		}
+	}
+	return false
+}
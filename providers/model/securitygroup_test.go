@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityGroupRuleValidate(t *testing.T) {
+	valid := []struct {
+		rule     SecurityGroupRule
+		expected SecurityGroupRule
+	}{
+		{
+			SecurityGroupRule{Protocol: "TCP", FromPort: 22, ToPort: 22},
+			SecurityGroupRule{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: "0.0.0.0/0"},
+		},
+		{
+			SecurityGroupRule{Protocol: "udp", FromPort: 1, ToPort: 65535, CIDR: "192.168.0.0/24"},
+			SecurityGroupRule{Protocol: "udp", FromPort: 1, ToPort: 65535, CIDR: "192.168.0.0/24"},
+		},
+		{
+			SecurityGroupRule{Protocol: "tcp", FromPort: 8080, ToPort: 8090, CIDR: "2001:db8::/32"},
+			SecurityGroupRule{Protocol: "tcp", FromPort: 8080, ToPort: 8090, CIDR: "2001:db8::/32"},
+		},
+		{
+			SecurityGroupRule{Protocol: "icmp"},
+			SecurityGroupRule{Protocol: "icmp", FromPort: -1, ToPort: -1, CIDR: "0.0.0.0/0"},
+		},
+		{
+			SecurityGroupRule{Protocol: "icmp", FromPort: 8, ToPort: 0, CIDR: "10.0.0.1/32"},
+			SecurityGroupRule{Protocol: "icmp", FromPort: 8, ToPort: 0, CIDR: "10.0.0.1/32"},
+		},
+	}
+	for _, c := range valid {
+		rule := c.rule
+		assert.Nil(t, rule.Validate(), "%+v", c.rule)
+		assert.Equal(t, c.expected, rule)
+	}
+
+	invalid := []SecurityGroupRule{
+		{Protocol: "sctp", FromPort: 1, ToPort: 1},
+		{Protocol: "", FromPort: 1, ToPort: 1},
+		{Protocol: "tcp", FromPort: 0, ToPort: 22},
+		{Protocol: "tcp", FromPort: 22, ToPort: 21},
+		{Protocol: "udp", FromPort: 1, ToPort: 65536},
+		{Protocol: "tcp", FromPort: 22},
+		{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: "10.0.0.0"},
+		{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: "10.0.0.0/33"},
+		{Protocol: "icmp", CIDR: "any"},
+	}
+	for _, rule := range invalid {
+		assert.NotNil(t, rule.Validate(), "%+v", rule)
+	}
+}
+
+func TestSecurityGroupSerialization(t *testing.T) {
+	sg := &SecurityGroup{
+		ID:          "sg-id",
+		Name:        "web",
+		Description: "web servers",
+		NetworkID:   "net-id",
+		Rules: []SecurityGroupRule{
+			{ID: "rule-1", Protocol: "tcp", FromPort: 80, ToPort: 80, CIDR: "0.0.0.0/0"},
+			{ID: "rule-2", Protocol: "icmp", FromPort: -1, ToPort: -1, CIDR: "10.0.0.0/8"},
+		},
+		Hosts: []string{"host-1", "host-2"},
+	}
+	buf, err := sg.Serialize()
+	require.Nil(t, err)
+	loaded := &SecurityGroup{}
+	require.Nil(t, loaded.Deserialize(buf))
+	assert.Equal(t, sg, loaded)
+
+	assert.True(t, loaded.IsBoundTo("host-2"))
+	assert.False(t, loaded.IsBoundTo("host-3"))
+	assert.False(t, (&SecurityGroup{}).IsBoundTo(""))
+}
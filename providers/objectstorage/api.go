@@ -157,4 +157,6 @@ type Config struct {
 	Key          string
 	SecretKey    string
 	Region       string
+	// Path is the root folder of a Location of type 'local', or the name of the store of a Location of type 'memory'
+	Path string
 }
@@ -0,0 +1,390 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objectstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// LocalType is the Type of the Location storing objects in a directory tree on local disk
+	LocalType = "local"
+
+	// localMetadataFolder is the folder, at the root of the Location, containing the metadata of the objects
+	// (its name cannot be used as bucket name)
+	localMetadataFolder = ".metadata"
+	// localTempFolder is the folder, at the root of the Location, used to write objects atomically
+	localTempFolder = ".tmp"
+)
+
+// localStore implements store interface with a directory tree on disk:
+// - <Path>/<bucket>/<object name> contains the content of the object
+// - <Path>/.metadata/<bucket>/<object name> contains the metadata of the object, in JSON
+type localStore struct {
+	root string
+	lock sync.RWMutex
+}
+
+// newLocalLocation returns a Location storing its objects under the folder conf.Path
+func newLocalLocation(conf Config) (Location, error) {
+	if conf.Path == "" {
+		return nil, fmt.Errorf("missing setting 'Path' for Object Storage of type '%s'", LocalType)
+	}
+	root, err := filepath.Abs(conf.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, folder := range []string{root, filepath.Join(root, localMetadataFolder), filepath.Join(root, localTempFolder)} {
+		err = os.MkdirAll(folder, 0700)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create folder '%s': %s", folder, err.Error())
+		}
+	}
+	return newStoreLocation(conf, &localStore{root: root}), nil
+}
+
+// checkBucketName verifies the bucket name can safely be used as folder name
+func checkBucketName(bucketName string) error {
+	if bucketName == "" || strings.HasPrefix(bucketName, ".") || strings.ContainsAny(bucketName, "/\\") {
+		return fmt.Errorf("invalid bucket name '%s'", bucketName)
+	}
+	return nil
+}
+
+// checkObjectName verifies the object name doesn't escape from the bucket folder
+func checkObjectName(objectName string) error {
+	if objectName == "" {
+		return fmt.Errorf("invalid empty object name")
+	}
+	for _, part := range strings.Split(objectName, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid object name '%s'", objectName)
+		}
+	}
+	return nil
+}
+
+// bucketPath returns the folder containing the objects of the bucket
+func (s *localStore) bucketPath(bucketName string) string {
+	return filepath.Join(s.root, bucketName)
+}
+
+// contentPath returns the path of the file containing the content of the object
+func (s *localStore) contentPath(bucketName, objectName string) string {
+	return filepath.Join(s.root, bucketName, filepath.FromSlash(objectName))
+}
+
+// metadataPath returns the path of the file containing the metadata of the object
+func (s *localStore) metadataPath(bucketName, objectName string) string {
+	return filepath.Join(s.root, localMetadataFolder, bucketName, filepath.FromSlash(objectName))
+}
+
+// listBuckets ...
+func (s *localStore) listBuckets() ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	entries, err := ioutil.ReadDir(s.root)
+	if err != nil {
+		return nil, err
+	}
+	list := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			list = append(list, entry.Name())
+		}
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// hasBucket ...
+func (s *localStore) hasBucket(bucketName string) (bool, error) {
+	if err := checkBucketName(bucketName); err != nil {
+		return false, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.bucketExists(bucketName)
+}
+
+// bucketExists tells if the folder of the bucket exists; lock must be held by caller
+func (s *localStore) bucketExists(bucketName string) (bool, error) {
+	info, err := os.Stat(s.bucketPath(bucketName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// createBucket ...
+func (s *localStore) createBucket(bucketName string) error {
+	if err := checkBucketName(bucketName); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	found, err := s.bucketExists(bucketName)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("bucket '%s' already exists", bucketName)
+	}
+	err = os.Mkdir(s.bucketPath(bucketName), 0700)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(filepath.Join(s.root, localMetadataFolder, bucketName), 0700)
+}
+
+// removeBucket ...
+func (s *localStore) removeBucket(bucketName string) error {
+	if err := checkBucketName(bucketName); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	list, err := s.walk(bucketName)
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		return fmt.Errorf("bucket '%s' is not empty", bucketName)
+	}
+	err = os.RemoveAll(filepath.Join(s.root, localMetadataFolder, bucketName))
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(s.bucketPath(bucketName))
+}
+
+// listObjects ...
+func (s *localStore) listObjects(bucketName string) ([]string, error) {
+	if err := checkBucketName(bucketName); err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.walk(bucketName)
+}
+
+// walk returns the names of the objects in the bucket, sorted; lock must be held by caller
+func (s *localStore) walk(bucketName string) ([]string, error) {
+	found, err := s.bucketExists(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("bucket '%s' not found", bucketName)
+	}
+
+	root := s.bucketPath(bucketName)
+	list := []string{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		list = append(list, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// readObject ...
+func (s *localStore) readObject(bucketName, objectName string) (*storedObject, error) {
+	if err := checkBucketName(bucketName); err != nil {
+		return nil, err
+	}
+	if err := checkObjectName(objectName); err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	path := s.contentPath(bucketName, objectName)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errObjectNotFound
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errObjectNotFound
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	metadata := ObjectMetadata{}
+	data, err := ioutil.ReadFile(s.metadataPath(bucketName, objectName))
+	if err == nil {
+		err = json.Unmarshal(data, &metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode metadata of object '%s:%s': %s", bucketName, objectName, err.Error())
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &storedObject{
+		content:  content,
+		metadata: metadata,
+		lastMod:  info.ModTime(),
+	}, nil
+}
+
+// writeObject ...
+func (s *localStore) writeObject(bucketName, objectName string, content []byte, metadata ObjectMetadata) (*storedObject, error) {
+	if err := checkBucketName(bucketName); err != nil {
+		return nil, err
+	}
+	if err := checkObjectName(objectName); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	found, err := s.bucketExists(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("bucket '%s' not found", bucketName)
+	}
+
+	if metadata == nil {
+		metadata = ObjectMetadata{}
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of object '%s:%s': %s", bucketName, objectName, err.Error())
+	}
+	err = s.writeFile(s.metadataPath(bucketName, objectName), data)
+	if err != nil {
+		return nil, err
+	}
+	path := s.contentPath(bucketName, objectName)
+	err = s.writeFile(path, content)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &storedObject{
+		content:  content,
+		metadata: metadata.Clone(),
+		lastMod:  info.ModTime(),
+	}, nil
+}
+
+// writeFile writes data in a temporary file then renames it, to never leave a partially written file
+func (s *localStore) writeFile(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Join(s.root, localTempFolder), "object")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// removeObject ...
+func (s *localStore) removeObject(bucketName, objectName string) error {
+	if err := checkBucketName(bucketName); err != nil {
+		return err
+	}
+	if err := checkObjectName(objectName); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err := os.Remove(s.contentPath(bucketName, objectName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errObjectNotFound
+		}
+		return err
+	}
+	err = os.Remove(s.metadataPath(bucketName, objectName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.pruneFolders(s.bucketPath(bucketName), filepath.Dir(s.contentPath(bucketName, objectName)))
+	metadataRoot := filepath.Join(s.root, localMetadataFolder, bucketName)
+	s.pruneFolders(metadataRoot, filepath.Dir(s.metadataPath(bucketName, objectName)))
+	return nil
+}
+
+// pruneFolders removes the empty folders from 'folder' up to 'root' (excluded)
+func (s *localStore) pruneFolders(root, folder string) {
+	for folder != root && strings.HasPrefix(folder, root) {
+		if os.Remove(folder) != nil {
+			// Not empty (or not removable), stops here
+			return
+		}
+		folder = filepath.Dir(folder)
+	}
+}
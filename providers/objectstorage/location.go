@@ -47,6 +47,13 @@ type location struct {
 
 // NewLocation creates an Object Storage Location based on config
 func NewLocation(conf Config) (Location, error) {
+	switch conf.Type {
+	case LocalType:
+		return newLocalLocation(conf)
+	case MemoryType:
+		return newMemoryLocation(conf)
+	}
+
 	location := &location{
 		config: conf,
 	}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objectstorage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// MemoryType is the Type of the Location keeping objects in memory
+	MemoryType = "memory"
+)
+
+var (
+	// memoryStores contains the memory stores already created, indexed by name, so that
+	// every Location using the same name in the same process shares the same content
+	memoryStores     = map[string]*memoryStore{}
+	memoryStoresLock sync.Mutex
+)
+
+// memoryStore implements store interface in memory
+type memoryStore struct {
+	lock    sync.RWMutex
+	buckets map[string]map[string]*storedObject
+}
+
+// newMemoryLocation returns a Location keeping its objects in memory
+// conf.Path is used as name of the memory store; Locations with the same name share their content
+func newMemoryLocation(conf Config) (Location, error) {
+	memoryStoresLock.Lock()
+	defer memoryStoresLock.Unlock()
+
+	s, found := memoryStores[conf.Path]
+	if !found {
+		s = &memoryStore{
+			buckets: map[string]map[string]*storedObject{},
+		}
+		memoryStores[conf.Path] = s
+	}
+	return newStoreLocation(conf, s), nil
+}
+
+// listBuckets ...
+func (s *memoryStore) listBuckets() ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	list := make([]string, 0, len(s.buckets))
+	for name := range s.buckets {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// hasBucket ...
+func (s *memoryStore) hasBucket(bucketName string) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, found := s.buckets[bucketName]
+	return found, nil
+}
+
+// createBucket ...
+func (s *memoryStore) createBucket(bucketName string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.buckets[bucketName]; found {
+		return fmt.Errorf("bucket '%s' already exists", bucketName)
+	}
+	s.buckets[bucketName] = map[string]*storedObject{}
+	return nil
+}
+
+// removeBucket ...
+func (s *memoryStore) removeBucket(bucketName string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	objects, found := s.buckets[bucketName]
+	if !found {
+		return fmt.Errorf("bucket '%s' not found", bucketName)
+	}
+	if len(objects) > 0 {
+		return fmt.Errorf("bucket '%s' is not empty", bucketName)
+	}
+	delete(s.buckets, bucketName)
+	return nil
+}
+
+// listObjects ...
+func (s *memoryStore) listObjects(bucketName string) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	objects, found := s.buckets[bucketName]
+	if !found {
+		return nil, fmt.Errorf("bucket '%s' not found", bucketName)
+	}
+	list := make([]string, 0, len(objects))
+	for name := range objects {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// readObject ...
+func (s *memoryStore) readObject(bucketName, objectName string) (*storedObject, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	objects, found := s.buckets[bucketName]
+	if !found {
+		return nil, fmt.Errorf("bucket '%s' not found", bucketName)
+	}
+	so, found := objects[objectName]
+	if !found {
+		return nil, errObjectNotFound
+	}
+	// returns a copy, the caller must not be able to alter the store
+	return &storedObject{
+		content:  append([]byte{}, so.content...),
+		metadata: so.metadata.Clone(),
+		lastMod:  so.lastMod,
+	}, nil
+}
+
+// writeObject ...
+func (s *memoryStore) writeObject(bucketName, objectName string, content []byte, metadata ObjectMetadata) (*storedObject, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	objects, found := s.buckets[bucketName]
+	if !found {
+		return nil, fmt.Errorf("bucket '%s' not found", bucketName)
+	}
+	so := &storedObject{
+		content:  append([]byte{}, content...),
+		metadata: metadata.Clone(),
+		lastMod:  time.Now(),
+	}
+	objects[objectName] = so
+	return &storedObject{
+		content:  content,
+		metadata: metadata.Clone(),
+		lastMod:  so.lastMod,
+	}, nil
+}
+
+// removeObject ...
+func (s *memoryStore) removeObject(bucketName, objectName string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	objects, found := s.buckets[bucketName]
+	if !found {
+		return fmt.Errorf("bucket '%s' not found", bucketName)
+	}
+	if _, found := objects[objectName]; !found {
+		return errObjectNotFound
+	}
+	delete(objects, objectName)
+	return nil
+}
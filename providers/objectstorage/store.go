@@ -0,0 +1,570 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objectstorage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// store is the set of primitives a backend must provide to be used as Location without stow
+// (used by the 'local' and 'memory' types)
+type store interface {
+	// listBuckets returns the names of the buckets, sorted
+	listBuckets() ([]string, error)
+	// hasBucket tells if the bucket exists
+	hasBucket(string) (bool, error)
+	// createBucket creates a bucket; fails if it already exists
+	createBucket(string) error
+	// removeBucket removes an empty bucket
+	removeBucket(string) error
+	// listObjects returns the names of the objects in a bucket, sorted
+	listObjects(string) ([]string, error)
+	// readObject returns the content of an object; returns errObjectNotFound if it doesn't exist
+	readObject(string, string) (*storedObject, error)
+	// writeObject creates or replaces an object
+	writeObject(string, string, []byte, ObjectMetadata) (*storedObject, error)
+	// removeObject deletes an object
+	removeObject(string, string) error
+}
+
+// storedObject contains what a store knows about an object
+type storedObject struct {
+	content  []byte
+	metadata ObjectMetadata
+	lastMod  time.Time
+}
+
+// etag returns the md5sum of the content, like most Object Storage do
+func (so *storedObject) etag() string {
+	sum := md5.Sum(so.content)
+	return hex.EncodeToString(sum[:])
+}
+
+var errObjectNotFound = errors.New("not found")
+
+// storeLocation implements Location interface on top of a store
+type storeLocation struct {
+	config Config
+	store  store
+}
+
+// newStoreLocation ...
+func newStoreLocation(conf Config, s store) *storeLocation {
+	return &storeLocation{
+		config: conf,
+		store:  s,
+	}
+}
+
+// GetType returns the type of ObjectStorage
+func (l *storeLocation) GetType() string {
+	return l.config.Type
+}
+
+// ListBuckets ...
+func (l *storeLocation) ListBuckets(prefix string) ([]string, error) {
+	names, err := l.store.listBuckets()
+	if err != nil {
+		return nil, err
+	}
+	list := []string{}
+	for _, name := range names {
+		if strings.Index(name, prefix) == 0 {
+			list = append(list, name)
+		}
+	}
+	return list, nil
+}
+
+// FindBucket returns true if a bucket with the name exists in location
+func (l *storeLocation) FindBucket(bucketName string) (bool, error) {
+	return l.store.hasBucket(bucketName)
+}
+
+// GetBucket ...
+func (l *storeLocation) GetBucket(bucketName string) (Bucket, error) {
+	return l.getBucket(bucketName)
+}
+
+// getBucket returns a *storeBucket if the bucket exists
+func (l *storeLocation) getBucket(bucketName string) (*storeBucket, error) {
+	found, err := l.store.hasBucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("bucket '%s' not found", bucketName)
+	}
+	return &storeBucket{
+		store: l.store,
+		Name:  bucketName,
+	}, nil
+}
+
+// CreateBucket ...
+func (l *storeLocation) CreateBucket(bucketName string) (Bucket, error) {
+	err := l.store.createBucket(bucketName)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to create bucket '%s'", bucketName))
+	}
+	return &storeBucket{
+		store: l.store,
+		Name:  bucketName,
+	}, nil
+}
+
+// DeleteBucket removes a bucket from Object Storage
+func (l *storeLocation) DeleteBucket(bucketName string) error {
+	err := l.store.removeBucket(bucketName)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to delete bucket '%s'", bucketName))
+	}
+	return nil
+}
+
+// ClearBucket ...
+func (l *storeLocation) ClearBucket(bucketName string, path, prefix string) error {
+	b, err := l.getBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	return b.Clear(path, prefix)
+}
+
+// ListObjects lists the objects in a Bucket
+func (l *storeLocation) ListObjects(bucketName string, path, prefix string) ([]string, error) {
+	b, err := l.getBucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return b.List(path, prefix)
+}
+
+// GetObject ...
+func (l *storeLocation) GetObject(bucketName string, objectName string) (Object, error) {
+	b, err := l.getBucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetObject(objectName)
+}
+
+// ReadObject reads the content of an object and put it in an io.Writer
+func (l *storeLocation) ReadObject(bucketName, objectName string, writer io.Writer, from, to int64) error {
+	b, err := l.getBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	_, err = b.ReadObject(objectName, writer, from, to)
+	return err
+}
+
+// WriteObject writes the content of reader in the Object
+func (l *storeLocation) WriteObject(
+	bucketName string, objectName string,
+	source io.Reader, size int64,
+	metadata ObjectMetadata,
+) (Object, error) {
+
+	b, err := l.getBucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return b.WriteObject(objectName, source, size, metadata)
+}
+
+// WriteMultiPartObject writes data from 'source' to an object in Object Storage, splitting data in parts of 'chunkSize' bytes
+func (l *storeLocation) WriteMultiPartObject(
+	bucketName string, objectName string,
+	source io.Reader, sourceSize int64,
+	chunkSize int,
+	metadata ObjectMetadata,
+) (Object, error) {
+
+	b, err := l.getBucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return b.WriteMultiPartObject(objectName, source, sourceSize, chunkSize, metadata)
+}
+
+// DeleteObject ...
+func (l *storeLocation) DeleteObject(bucketName, objectName string) error {
+	b, err := l.getBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	return b.DeleteObject(objectName)
+}
+
+// storeBucket implements Bucket interface on top of a store
+type storeBucket struct {
+	store store
+
+	Name string `json:"name,omitempty"`
+}
+
+// filter returns the names of the objects matching path and prefix, in the same way than stow.Walk
+func (b *storeBucket) filter(path, prefix string) ([]string, error) {
+	fullPath := path
+	if fullPath != "" {
+		fullPath += "/"
+	}
+	fullPath += prefix
+
+	names, err := b.store.listObjects(b.Name)
+	if err != nil {
+		return nil, err
+	}
+	list := []string{}
+	for _, name := range names {
+		if strings.Index(name, fullPath) == 0 {
+			list = append(list, name)
+		}
+	}
+	return list, nil
+}
+
+// List lists object names of a Bucket
+func (b *storeBucket) List(path, prefix string) ([]string, error) {
+	return b.filter(path, prefix)
+}
+
+// Browse walks through the objects in the Bucket and executes callback on each Object found
+func (b *storeBucket) Browse(path, prefix string, callback func(Object) error) error {
+	list, err := b.filter(path, prefix)
+	if err != nil {
+		return err
+	}
+	for _, name := range list {
+		o, err := b.GetObject(name)
+		if err != nil {
+			// Object may have been deleted in the meantime
+			continue
+		}
+		err = callback(o)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear empties a bucket
+func (b *storeBucket) Clear(path, prefix string) error {
+	list, err := b.filter(path, prefix)
+	if err != nil {
+		return err
+	}
+	for _, name := range list {
+		err = b.store.removeObject(b.Name, name)
+		if err != nil && err != errObjectNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateObject ...
+func (b *storeBucket) CreateObject(objectName string) (Object, error) {
+	return b.newObject(objectName)
+}
+
+// GetObject ...
+func (b *storeBucket) GetObject(objectName string) (Object, error) {
+	o, err := b.newObject(objectName)
+	if err != nil {
+		return nil, err
+	}
+	if !o.Stored() {
+		return nil, fmt.Errorf("not found")
+	}
+	return o, nil
+}
+
+// newObject returns a *storeObject, loaded from the store if the object exists
+func (b *storeBucket) newObject(objectName string) (*storeObject, error) {
+	o := &storeObject{
+		bucket:   b,
+		Name:     objectName,
+		Metadata: ObjectMetadata{},
+	}
+	err := o.Reload()
+	if err != nil && err != errObjectNotFound {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DeleteObject deletes an object from a bucket
+func (b *storeBucket) DeleteObject(objectName string) error {
+	return b.store.removeObject(b.Name, objectName)
+}
+
+// ReadObject ...
+func (b *storeBucket) ReadObject(objectName string, target io.Writer, from int64, to int64) (Object, error) {
+	o, err := b.newObject(objectName)
+	if err != nil {
+		return nil, err
+	}
+	err = o.Read(target, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// WriteObject ...
+func (b *storeBucket) WriteObject(objectName string, source io.Reader, sourceSize int64, metadata ObjectMetadata) (Object, error) {
+	o, err := b.newObject(objectName)
+	if err != nil {
+		return nil, err
+	}
+	o.AddMetadata(metadata)
+	err = o.Write(source, sourceSize)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// WriteMultiPartObject ...
+func (b *storeBucket) WriteMultiPartObject(
+	objectName string,
+	source io.Reader, sourceSize int64,
+	chunkSize int,
+	metadata ObjectMetadata,
+) (Object, error) {
+
+	o, err := b.newObject(objectName)
+	if err != nil {
+		return nil, err
+	}
+	o.AddMetadata(metadata)
+	err = o.WriteMultiPart(source, sourceSize, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// GetName returns the name of the Bucket
+func (b *storeBucket) GetName() string {
+	return b.Name
+}
+
+// GetCount returns the count of objects in the Bucket
+func (b *storeBucket) GetCount(path, prefix string) (int64, error) {
+	list, err := b.filter(path, prefix)
+	if err != nil {
+		return -1, err
+	}
+	return int64(len(list)), nil
+}
+
+// GetSize returns the total size of the Objects inside the Bucket
+func (b *storeBucket) GetSize(path, prefix string) (int64, string, error) {
+	list, err := b.filter(path, prefix)
+	if err != nil {
+		return -1, "", err
+	}
+	var totalSize int64
+	for _, name := range list {
+		so, err := b.store.readObject(b.Name, name)
+		if err != nil {
+			if err == errObjectNotFound {
+				continue
+			}
+			return -1, "", err
+		}
+		totalSize += int64(len(so.content))
+	}
+	return totalSize, humanReadableSize(totalSize), nil
+}
+
+// storeObject implements Object interface on top of a store
+type storeObject struct {
+	bucket *storeBucket
+	stored *storedObject
+
+	Name     string         `json:"name,omitempty"`
+	Metadata ObjectMetadata `json:"metadata,omitempty"`
+}
+
+// Stored return true if the object exists in Object Storage
+func (o *storeObject) Stored() bool {
+	return o.stored != nil
+}
+
+// Reload reloads the data of the Object from the store
+func (o *storeObject) Reload() error {
+	so, err := o.bucket.store.readObject(o.bucket.Name, o.Name)
+	if err != nil {
+		return err
+	}
+	o.stored = so
+	o.Metadata = so.metadata.Clone()
+	return nil
+}
+
+// Read reads the content of the object and writes it in 'target'
+func (o *storeObject) Read(target io.Writer, from, to int64) error {
+	if from > to {
+		return fmt.Errorf("invalid range: from is greater than to")
+	}
+
+	err := o.Reload()
+	if err != nil {
+		return err
+	}
+
+	content := o.stored.content
+	size := int64(len(content))
+	if from > size {
+		from = size
+	}
+	end := size
+	if to > 0 && to > from && to < size {
+		end = to
+	}
+	_, err = io.Copy(target, bytes.NewReader(content[from:end]))
+	return err
+}
+
+// Write the source to the object in the store
+func (o *storeObject) Write(source io.Reader, sourceSize int64) error {
+	content, err := ioutil.ReadAll(io.LimitReader(source, sourceSize))
+	if err != nil {
+		return err
+	}
+	so, err := o.bucket.store.writeObject(o.bucket.Name, o.Name, content, o.GetMetadata())
+	if err != nil {
+		return err
+	}
+	o.stored = so
+	return nil
+}
+
+// WriteMultiPart writes big data to Object, by parts (also called chunks)
+// Note: nothing to do with multi-chunk abilities of various object storage technologies
+func (o *storeObject) WriteMultiPart(source io.Reader, sourceSize int64, chunkSize int) error {
+	metadata := o.GetMetadata()
+	metadata["Split"] = o.Name
+
+	var chunkIndex int
+	remaining := sourceSize
+	for remaining > 0 {
+		if remaining < int64(chunkSize) {
+			chunkSize = int(remaining)
+		}
+		buf := make([]byte, chunkSize)
+		n, err := io.ReadFull(source, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		_, err = o.bucket.store.writeObject(o.bucket.Name, o.Name+strconv.Itoa(chunkIndex), buf[:n], metadata)
+		if err != nil {
+			return err
+		}
+		remaining -= int64(chunkSize)
+		chunkIndex++
+	}
+	return nil
+}
+
+// Delete deletes the object from the store
+func (o *storeObject) Delete() error {
+	err := o.bucket.store.removeObject(o.bucket.Name, o.Name)
+	if err != nil {
+		return err
+	}
+	o.stored = nil
+	return nil
+}
+
+// ForceAddMetadata overwrites the metadata entries of the object by the ones provided in parameter
+func (o *storeObject) ForceAddMetadata(newMetadata ObjectMetadata) {
+	for k, v := range newMetadata {
+		o.Metadata[k] = v
+	}
+}
+
+// AddMetadata adds missing entries in object metadata
+func (o *storeObject) AddMetadata(newMetadata ObjectMetadata) {
+	for k, v := range newMetadata {
+		if _, found := o.Metadata[k]; !found {
+			o.Metadata[k] = v
+		}
+	}
+}
+
+// ReplaceMetadata replaces object metadata with the ones provided in parameter
+func (o *storeObject) ReplaceMetadata(newMetadata ObjectMetadata) {
+	o.Metadata = newMetadata.Clone()
+}
+
+// GetID returns the ID of the object, which is its name for a store
+func (o *storeObject) GetID() string {
+	if o.stored != nil {
+		return o.Name
+	}
+	return ""
+}
+
+// GetName returns the name of the object
+func (o *storeObject) GetName() string {
+	return o.Name
+}
+
+// GetLastUpdate returns the date of last update
+func (o *storeObject) GetLastUpdate() (time.Time, error) {
+	if o.stored != nil {
+		return o.stored.lastMod, nil
+	}
+	return time.Now(), fmt.Errorf("object metadata not found")
+}
+
+// GetSize returns the size of the content of the object
+func (o *storeObject) GetSize() int64 {
+	if o.stored != nil {
+		return int64(len(o.stored.content))
+	}
+	return -1
+}
+
+// GetETag returns the value of the ETag (md5sum of the content)
+func (o *storeObject) GetETag() string {
+	if o.stored != nil {
+		return o.stored.etag()
+	}
+	return ""
+}
+
+// GetMetadata returns the metadata of the object
+func (o *storeObject) GetMetadata() ObjectMetadata {
+	return o.Metadata.Clone()
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objectstorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStoreLocation(t *testing.T, location Location) {
+	found, err := location.FindBucket("metadata")
+	require.Nil(t, err)
+	assert.False(t, found)
+
+	bucket, err := location.CreateBucket("metadata")
+	require.Nil(t, err)
+	_, err = location.CreateBucket("metadata")
+	assert.NotNil(t, err)
+
+	found, err = location.FindBucket("metadata")
+	require.Nil(t, err)
+	assert.True(t, found)
+	buckets, err := location.ListBuckets("meta")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"metadata"}, buckets)
+
+	content := "some content"
+	_, err = bucket.WriteObject("hosts/byID/1", strings.NewReader(content), int64(len(content)), ObjectMetadata{"Type": "host"})
+	require.Nil(t, err)
+	_, err = location.WriteObject("metadata", "hosts/byName/one", strings.NewReader(content), int64(len(content)), nil)
+	require.Nil(t, err)
+	_, err = location.WriteObject("metadata", "networks/byID/1", strings.NewReader(content), int64(len(content)), nil)
+	require.Nil(t, err)
+
+	list, err := bucket.List("hosts", "")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"hosts/byID/1", "hosts/byName/one"}, list)
+	list, err = location.ListObjects("metadata", "hosts/byID", "")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"hosts/byID/1"}, list)
+	count, err := bucket.GetCount("", "")
+	require.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+
+	var buffer bytes.Buffer
+	o, err := bucket.ReadObject("hosts/byID/1", &buffer, 0, 0)
+	require.Nil(t, err)
+	assert.Equal(t, content, buffer.String())
+	assert.Equal(t, "host", o.GetMetadata()["Type"])
+	assert.Equal(t, int64(len(content)), o.GetSize())
+	assert.NotEmpty(t, o.GetETag())
+
+	buffer.Reset()
+	err = location.ReadObject("metadata", "hosts/byID/1", &buffer, 5, 12)
+	require.Nil(t, err)
+	assert.Equal(t, "content", buffer.String())
+
+	_, err = bucket.GetObject("hosts/byID/2")
+	assert.NotNil(t, err)
+	err = bucket.DeleteObject("hosts/byID/2")
+	assert.NotNil(t, err)
+
+	err = location.DeleteBucket("metadata")
+	assert.NotNil(t, err)
+	err = bucket.DeleteObject("hosts/byID/1")
+	require.Nil(t, err)
+	err = location.ClearBucket("metadata", "", "")
+	require.Nil(t, err)
+	count, err = bucket.GetCount("", "")
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+	err = location.DeleteBucket("metadata")
+	require.Nil(t, err)
+}
+
+func TestMemoryLocation(t *testing.T) {
+	location, err := NewLocation(Config{Type: MemoryType, Path: "TestMemoryLocation"})
+	require.Nil(t, err)
+	testStoreLocation(t, location)
+
+	// Locations with the same name share their content
+	location, err = NewLocation(Config{Type: MemoryType, Path: "TestMemoryLocationShared"})
+	require.Nil(t, err)
+	_, err = location.CreateBucket("shared")
+	require.Nil(t, err)
+	other, err := NewLocation(Config{Type: MemoryType, Path: "TestMemoryLocationShared"})
+	require.Nil(t, err)
+	found, err := other.FindBucket("shared")
+	require.Nil(t, err)
+	assert.True(t, found)
+}
+
+func TestLocalLocation(t *testing.T) {
+	_, err := NewLocation(Config{Type: LocalType})
+	assert.NotNil(t, err)
+
+	root, err := ioutil.TempDir("", "objectstorage")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	location, err := NewLocation(Config{Type: LocalType, Path: root})
+	require.Nil(t, err)
+	testStoreLocation(t, location)
+
+	_, err = location.CreateBucket("../escape")
+	assert.NotNil(t, err)
+	bucket, err := location.CreateBucket("metadata")
+	require.Nil(t, err)
+	_, err = bucket.WriteObject("../escape", strings.NewReader("x"), 1, nil)
+	assert.NotNil(t, err)
+
+	// Content survives a new Location on the same folder
+	_, err = bucket.WriteObject("hosts/byID/1", strings.NewReader("x"), 1, ObjectMetadata{"Type": "host"})
+	require.Nil(t, err)
+	location, err = NewLocation(Config{Type: LocalType, Path: root})
+	require.Nil(t, err)
+	o, err := location.GetObject("metadata", "hosts/byID/1")
+	require.Nil(t, err)
+	assert.Equal(t, "host", o.GetMetadata()["Type"])
+}
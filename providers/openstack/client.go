@@ -18,6 +18,8 @@ package openstack
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	gc "github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
@@ -27,9 +29,11 @@ import (
 
 	"net/http"
 
+	"github.com/CS-SI/SafeScale/providers"
 	"github.com/CS-SI/SafeScale/providers/api"
 	"github.com/CS-SI/SafeScale/providers/metadata"
 	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/model/enums/FloatingIPModel"
 	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeSpeed"
 )
 
@@ -106,6 +110,8 @@ type CfgOptions struct {
 	// MetadataBucket contains the name of the bucket storing metadata
 	MetadataBucket string
 	DefaultImage   string
+	// ImageFilters contains the regular expressions of the names of the images hidden when not listing all images
+	ImageFilters []string
 }
 
 // ProviderErrorToString creates an error string from openstack api error
@@ -197,6 +203,14 @@ func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 	if cfg.MetadataBucket == "" {
 		cfg.MetadataBucket = metadata.BuildMetadataBucketName(opts.Username)
 	}
+	var imageFilters []*regexp.Regexp
+	for _, f := range cfg.ImageFilters {
+		re, err := regexp.Compile(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image filter '%s': %s", f, err.Error())
+		}
+		imageFilters = append(imageFilters, re)
+	}
 
 	// Openstack client
 	pClient, err := openstack.AuthenticatedClient(gcOpts)
@@ -225,9 +239,12 @@ func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 	}
 
 	// Get Identity from network service
-	nID, err := networks.IDFromName(network, cfg.ProviderNetwork)
-	if err != nil {
-		return nil, fmt.Errorf("%s", ProviderErrorToString(err))
+	var nID string
+	if cfg.ProviderNetwork != "" {
+		nID, err = networks.IDFromName(network, cfg.ProviderNetwork)
+		if err != nil {
+			return nil, fmt.Errorf("%s", ProviderErrorToString(err))
+		}
 	}
 	// volume API
 	volume, err := openstack.NewBlockStorageV1(pClient, gc.EndpointOpts{
@@ -245,6 +262,7 @@ func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
 		Network:           network,
 		Volume:            volume,
 		ProviderNetworkID: nID,
+		imageFilters:      imageFilters,
 	}
 
 	err = clt.initDefaultSecurityGroup()
@@ -270,6 +288,8 @@ type Client struct {
 
 	SecurityGroup     *secgroups.SecurityGroup
 	ProviderNetworkID string
+
+	imageFilters []*regexp.Regexp
 }
 
 // getDefaultSecurityGroup returns the default security group
@@ -418,45 +438,165 @@ func (client *Client) initDefaultSecurityGroup() error {
 }
 
 // Build build a new Client from configuration parameter
+// Everything specific to the OpenStack cloud is read from the tenant, so any OpenStack cloud, including private
+// ones, can be used without dedicated driver
 func (client *Client) Build(params map[string]interface{}) (api.ClientAPI, error) {
-	tenantName, _ := params["name"].(string)
-
 	identity, _ := params["identity"].(map[string]interface{})
 	compute, _ := params["compute"].(map[string]interface{})
-	// network, _ := params["network"].(map[string]interface{})
+	network, _ := params["network"].(map[string]interface{})
 
 	identityEndpoint, _ := identity["Endpoint"].(string)
+	if identityEndpoint == "" {
+		return nil, fmt.Errorf("missing 'Endpoint' in identity section")
+	}
 	username, _ := identity["Username"].(string)
+	userID, _ := identity["UserID"].(string)
 	password, _ := identity["Password"].(string)
+	apiKey, _ := identity["APIKey"].(string)
+	domainID, _ := identity["DomainID"].(string)
+	domainName, _ := identity["DomainName"].(string)
+	tenantID, _ := identity["TenantID"].(string)
+	if tenantID == "" {
+		tenantID, _ = compute["ProjectID"].(string)
+	}
+	tenantName, _ := identity["TenantName"].(string)
+	if tenantName == "" {
+		tenantName, _ = compute["ProjectName"].(string)
+	}
 
 	region, _ := compute["Region"].(string)
-	floatingIPPool, _ := compute["FloatingIPPool"].(string)
-	defaultImage, _ := compute["DefaultImage"]
+	defaultImage, _ := compute["DefaultImage"].(string)
+	imageFilters, err := toStringList(compute["ImageFilters"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'ImageFilters': %s", err.Error())
+	}
+	// Without volume types, the volumes get the default type of the cloud
+	volumeSpeeds := map[string]VolumeSpeed.Enum{}
+	if anon, ok := compute["VolumeSpeeds"]; ok {
+		types, ok := anon.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid 'VolumeSpeeds', must be a table of volume speeds indexed by volume type")
+		}
+		for t, anon := range types {
+			name, _ := anon.(string)
+			speed, ok := volumeSpeedNames[strings.ToUpper(name)]
+			if !ok {
+				return nil, fmt.Errorf("invalid speed '%v' of volume type '%s', must be COLD, HDD or SSD", anon, t)
+			}
+			volumeSpeeds[t] = speed
+		}
+	}
+
+	providerNetwork := "public"
+	if anon, ok := network["ProviderNetwork"]; ok {
+		providerNetwork, _ = anon.(string)
+	}
+	floatingIPPool, _ := network["FloatingIPPool"].(string)
+	if floatingIPPool == "" {
+		floatingIPPool = providerNetwork
+	}
+	useFloatingIP, err := toBool(network["UseFloatingIP"], true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'UseFloatingIP': %s", err.Error())
+	}
+	useLayer3Networking, err := toBool(network["UseLayer3Networking"], true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'UseLayer3Networking': %s", err.Error())
+	}
+	autoHostNetworkInterfaces, err := toBool(network["AutoHostNetworkInterfaces"], true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'AutoHostNetworkInterfaces': %s", err.Error())
+	}
+	dnsList, err := toStringList(network["DNSList"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'DNSList': %s", err.Error())
+	}
+	if (useFloatingIP || useLayer3Networking) && providerNetwork == "" {
+		return nil, fmt.Errorf("a provider network is needed to use floating IPs or layer 3 networking")
+	}
+	if useFloatingIP && !useLayer3Networking {
+		return nil, fmt.Errorf("floating IPs can't be used without layer 3 networking")
+	}
+
+	metadataBucket := tenantID
+	if metadataBucket == "" {
+		metadataBucket = tenantName
+	}
+	if metadataBucket != "" {
+		metadataBucket = metadata.BuildMetadataBucketName(metadataBucket)
+	}
 
 	return AuthenticatedClient(
 		AuthOptions{
 			IdentityEndpoint: identityEndpoint,
 			Username:         username,
+			UserID:           userID,
 			Password:         password,
+			APIKey:           apiKey,
+			DomainID:         domainID,
+			DomainName:       domainName,
+			TenantID:         tenantID,
 			TenantName:       tenantName,
 			Region:           region,
 			FloatingIPPool:   floatingIPPool,
+			AllowReauth:      true,
 		},
 		CfgOptions{
-			ProviderNetwork:           "public",
-			UseFloatingIP:             true,
-			UseLayer3Networking:       true,
-			AutoHostNetworkInterfaces: true,
-			VolumeSpeeds: map[string]VolumeSpeed.Enum{
-				"standard":   VolumeSpeed.COLD,
-				"performant": VolumeSpeed.HDD,
-			},
-			DNSList:      []string{"185.23.94.244", "185.23.94.244"},
-			DefaultImage: defaultImage.(string),
+			ProviderNetwork:           providerNetwork,
+			UseFloatingIP:             useFloatingIP,
+			UseLayer3Networking:       useLayer3Networking,
+			AutoHostNetworkInterfaces: autoHostNetworkInterfaces,
+			VolumeSpeeds:              volumeSpeeds,
+			DNSList:                   dnsList,
+			MetadataBucket:            metadataBucket,
+			DefaultImage:              defaultImage,
+			ImageFilters:              imageFilters,
 		},
 	)
 }
 
+// volumeSpeedNames maps the names of the volume speeds used in tenant configuration
+var volumeSpeedNames = map[string]VolumeSpeed.Enum{
+	"COLD": VolumeSpeed.COLD,
+	"HDD":  VolumeSpeed.HDD,
+	"SSD":  VolumeSpeed.SSD,
+}
+
+// toBool converts a value read from configuration to bool, defaultValue if missing
+func toBool(anon interface{}, defaultValue bool) (bool, error) {
+	switch v := anon.(type) {
+	case nil:
+		return defaultValue, nil
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(v) {
+		case "true", "yes", "1":
+			return true, nil
+		case "false", "no", "0":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("'%v' is not a boolean", anon)
+}
+
+// toStringList converts a value read from configuration to a list of strings, nil if missing
+func toStringList(anon interface{}) ([]string, error) {
+	switch v := anon.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		var list []string
+		for _, e := range v {
+			list = append(list, fmt.Sprint(e))
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("'%v' is not a list", anon)
+}
+
 // GetAuthOpts returns the auth options
 func (client *Client) GetAuthOpts() (model.Config, error) {
 	cfg := model.ConfigMap{}
@@ -482,3 +622,33 @@ func (client *Client) GetCfgOpts() (model.Config, error) {
 
 	return cfg, nil
 }
+
+// GetCapabilities returns what the provider supports
+func (client *Client) GetCapabilities() model.Capabilities {
+	capabilities := model.Capabilities{
+		PrivateNetworks: true,
+		ObjectStorage:   "swift",
+	}
+	for _, speed := range []VolumeSpeed.Enum{VolumeSpeed.COLD, VolumeSpeed.HDD, VolumeSpeed.SSD} {
+		for _, s := range client.Cfg.VolumeSpeeds {
+			if s == speed {
+				capabilities.VolumeSpeeds = append(capabilities.VolumeSpeeds, speed)
+				break
+			}
+		}
+	}
+	// Without volume type, the volumes have the default type, seen as HDD (see getVolumeSpeed)
+	if len(capabilities.VolumeSpeeds) == 0 {
+		capabilities.VolumeSpeeds = []VolumeSpeed.Enum{VolumeSpeed.HDD}
+	}
+	if client.Cfg.UseFloatingIP {
+		capabilities.FloatingIP = FloatingIPModel.FLOATING
+	} else if client.ProviderNetworkID != "" {
+		capabilities.FloatingIP = FloatingIPModel.DIRECT
+	}
+	return capabilities
+}
+
+func init() {
+	providers.Register("openstack", &Client{})
+}
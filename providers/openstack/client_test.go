@@ -127,6 +127,12 @@ func Test_VolumeAttachment(t *testing.T) {
 	tt.VolumeAttachment(t)
 }
 
+func Test_SecurityGroups(t *testing.T) {
+	tt, err := getTester()
+	require.Nil(t, err)
+	tt.SecurityGroups(t)
+}
+
 func Test_Containers(t *testing.T) {
 	tt, err := getTester()
 	require.Nil(t, err)
@@ -138,3 +144,62 @@ func Test_Objects(t *testing.T) {
 	require.Nil(t, err)
 	tt.Objects(t)
 }
+
+func Test_Build(t *testing.T) {
+	identity := map[string]interface{}{
+		"Endpoint": "http://localhost:5000/v3",
+		"Username": "user",
+		"Password": "password",
+	}
+
+	_, err := (&openstack.Client{}).Build(map[string]interface{}{
+		"identity": map[string]interface{}{},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Endpoint")
+
+	_, err = (&openstack.Client{}).Build(map[string]interface{}{
+		"identity": identity,
+		"compute": map[string]interface{}{
+			"VolumeSpeeds": map[string]interface{}{"fast": "NVME"},
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fast")
+
+	_, err = (&openstack.Client{}).Build(map[string]interface{}{
+		"identity": identity,
+		"compute": map[string]interface{}{
+			"ImageFilters": []interface{}{"(windows"},
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "image filter")
+
+	_, err = (&openstack.Client{}).Build(map[string]interface{}{
+		"identity": identity,
+		"network": map[string]interface{}{
+			"UseFloatingIP": "maybe",
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "UseFloatingIP")
+
+	_, err = (&openstack.Client{}).Build(map[string]interface{}{
+		"identity": identity,
+		"network": map[string]interface{}{
+			"ProviderNetwork": "",
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "provider network")
+
+	_, err = (&openstack.Client{}).Build(map[string]interface{}{
+		"identity": identity,
+		"network": map[string]interface{}{
+			"UseLayer3Networking": false,
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "layer 3")
+}
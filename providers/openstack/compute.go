@@ -40,6 +40,7 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
 	"github.com/gophercloud/gophercloud/pagination"
 
+	imgfilters "github.com/CS-SI/SafeScale/providers/filters/images"
 	"github.com/CS-SI/SafeScale/providers/model"
 	"github.com/CS-SI/SafeScale/providers/model/enums/HostProperty"
 	"github.com/CS-SI/SafeScale/providers/model/enums/HostState"
@@ -101,7 +102,21 @@ func (client *Client) ListImages(all bool) ([]model.Image, error) {
 		}
 		// log.Debugf("Image list empty !")
 	}
-	return imgList, nil
+	if all || len(client.imageFilters) == 0 {
+		return imgList, nil
+	}
+	imageFilter := imgfilters.NewFilter(client.isFilteredImage).Not()
+	return imgfilters.FilterImages(imgList, imageFilter), nil
+}
+
+// isFilteredImage returns true if the name of the image matches one of the image filters of the tenant
+func (client *Client) isFilteredImage(image model.Image) bool {
+	for _, re := range client.imageFilters {
+		if re.MatchString(image.Name) {
+			return true
+		}
+	}
+	return false
 }
 
 // GetImage returns the Image referenced by id
@@ -844,6 +859,16 @@ func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error)
 		}
 	}
 
+	// The host generates its SSH host key and prints it on its console, where it is read to authenticate the host
+	host.HostKey, err = userdata.WaitHostKey(func() (string, error) {
+		return servers.ShowConsoleOutput(client.Compute, host.ID, servers.ShowConsoleOutputOpts{}).Extract()
+	}, 10*time.Minute)
+	if err != nil {
+		msg := fmt.Sprintf(msgFail, err.Error())
+		log.Debugf(msg)
+		return nil, fmt.Errorf(msg)
+	}
+
 	log.Infoln(msgSuccess)
 	return host, nil
 }
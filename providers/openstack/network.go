@@ -321,6 +321,7 @@ func (client *Client) CreateGateway(req model.GatewayRequest) (*model.Host, erro
 		TemplateID:   req.TemplateID,
 		Networks:     []*model.Network{req.Network},
 		PublicIP:     true,
+		UserData:     req.UserData,
 	}
 	host, err := client.CreateHost(hostReq)
 	if err != nil {
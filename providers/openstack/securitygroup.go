@@ -0,0 +1,181 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	gc "github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/secgroups"
+	"github.com/gophercloud/gophercloud/pagination"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// toModelSecurityGroupRule converts a rule returned by the OpenStack driver into model.SecurityGroupRule
+func toModelSecurityGroupRule(rule secgroups.Rule) model.SecurityGroupRule {
+	return model.SecurityGroupRule{
+		ID:       rule.ID,
+		Protocol: strings.ToLower(rule.IPProtocol),
+		FromPort: rule.FromPort,
+		ToPort:   rule.ToPort,
+		CIDR:     rule.IPRange.CIDR,
+	}
+}
+
+// toModelSecurityGroup converts a security group returned by the OpenStack driver into model.SecurityGroup
+func toModelSecurityGroup(sg *secgroups.SecurityGroup) *model.SecurityGroup {
+	group := model.SecurityGroup{
+		ID:          sg.ID,
+		Name:        sg.Name,
+		Description: sg.Description,
+	}
+	for _, rule := range sg.Rules {
+		group.Rules = append(group.Rules, toModelSecurityGroupRule(rule))
+	}
+	return &group
+}
+
+// CreateSecurityGroup creates a security group, without rule
+func (client *Client) CreateSecurityGroup(request model.SecurityGroupRequest) (*model.SecurityGroup, error) {
+	description := request.Description
+	if description == "" {
+		description = fmt.Sprintf("Security group %s", request.Name)
+	}
+	sg, err := secgroups.Create(client.Compute, secgroups.CreateOpts{
+		Name:        request.Name,
+		Description: description,
+	}).Extract()
+	if err != nil {
+		log.Debugf("Error creating security group: creation invocation: %+v", err)
+		return nil, errors.Wrap(err, fmt.Sprintf("Error creating security group '%s': %s", request.Name, ProviderErrorToString(err)))
+	}
+	group := toModelSecurityGroup(sg)
+	group.NetworkID = request.NetworkID
+	return group, nil
+}
+
+// GetSecurityGroup returns the security group identified by id, with its rules
+func (client *Client) GetSecurityGroup(id string) (*model.SecurityGroup, error) {
+	sg, err := secgroups.Get(client.Compute, id).Extract()
+	if err != nil {
+		switch err.(type) {
+		case gc.ErrDefault404:
+			return nil, nil
+		}
+		log.Debugf("Error getting security group: get invocation: %+v", err)
+		return nil, errors.Wrap(err, fmt.Sprintf("Error getting security group: %s", ProviderErrorToString(err)))
+	}
+	return toModelSecurityGroup(sg), nil
+}
+
+// ListSecurityGroups lists available security groups
+// The default security group of SafeScale isn't listed
+func (client *Client) ListSecurityGroups() ([]model.SecurityGroup, error) {
+	var groups []model.SecurityGroup
+	err := secgroups.List(client.Compute).EachPage(func(page pagination.Page) (bool, error) {
+		list, err := secgroups.ExtractSecurityGroups(page)
+		if err != nil {
+			return false, err
+		}
+		for i := range list {
+			if list[i].Name == defaultSecurityGroup {
+				continue
+			}
+			groups = append(groups, *toModelSecurityGroup(&list[i]))
+		}
+		return true, nil
+	})
+	if err != nil {
+		log.Debugf("Error listing security groups: list invocation: %+v", err)
+		return nil, errors.Wrap(err, fmt.Sprintf("Error listing security groups: %s", ProviderErrorToString(err)))
+	}
+	return groups, nil
+}
+
+// DeleteSecurityGroup deletes the security group identified by id
+func (client *Client) DeleteSecurityGroup(id string) error {
+	err := secgroups.Delete(client.Compute, id).ExtractErr()
+	if err != nil {
+		log.Debugf("Error deleting security group: delete invocation: %+v", err)
+		return errors.Wrap(err, fmt.Sprintf("Error deleting security group: %s", ProviderErrorToString(err)))
+	}
+	return nil
+}
+
+// AddRule adds a rule allowing incoming traffic to the security group identified by groupID
+func (client *Client) AddRule(groupID string, rule model.SecurityGroupRule) (*model.SecurityGroupRule, error) {
+	err := rule.Validate()
+	if err != nil {
+		return nil, err
+	}
+	r, err := secgroups.CreateRule(client.Compute, secgroups.CreateRuleOpts{
+		ParentGroupID: groupID,
+		FromPort:      rule.FromPort,
+		ToPort:        rule.ToPort,
+		IPProtocol:    strings.ToUpper(rule.Protocol),
+		CIDR:          rule.CIDR,
+	}).Extract()
+	if err != nil {
+		log.Debugf("Error adding security group rule: rule creation invocation: %+v", err)
+		return nil, errors.Wrap(err, fmt.Sprintf("Error adding rule to security group: %s", ProviderErrorToString(err)))
+	}
+	added := toModelSecurityGroupRule(*r)
+	return &added, nil
+}
+
+// DeleteRule deletes the rule identified by ruleID from the security group identified by groupID
+func (client *Client) DeleteRule(groupID string, ruleID string) error {
+	err := secgroups.DeleteRule(client.Compute, ruleID).ExtractErr()
+	if err != nil {
+		log.Debugf("Error deleting security group rule: delete invocation: %+v", err)
+		return errors.Wrap(err, fmt.Sprintf("Error deleting rule '%s' of security group '%s': %s", ruleID, groupID, ProviderErrorToString(err)))
+	}
+	return nil
+}
+
+// BindToHost binds the security group identified by groupID to the host identified by hostID
+func (client *Client) BindToHost(groupID string, hostID string) error {
+	sg, err := secgroups.Get(client.Compute, groupID).Extract()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Error binding security group to host: %s", ProviderErrorToString(err)))
+	}
+	err = secgroups.AddServer(client.Compute, hostID, sg.Name).ExtractErr()
+	if err != nil {
+		log.Debugf("Error binding security group to host: add server invocation: %+v", err)
+		return errors.Wrap(err, fmt.Sprintf("Error binding security group '%s' to host '%s': %s", sg.Name, hostID, ProviderErrorToString(err)))
+	}
+	return nil
+}
+
+// UnbindFromHost unbinds the security group identified by groupID from the host identified by hostID
+func (client *Client) UnbindFromHost(groupID string, hostID string) error {
+	sg, err := secgroups.Get(client.Compute, groupID).Extract()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Error unbinding security group from host: %s", ProviderErrorToString(err)))
+	}
+	err = secgroups.RemoveServer(client.Compute, hostID, sg.Name).ExtractErr()
+	if err != nil {
+		log.Debugf("Error unbinding security group from host: remove server invocation: %+v", err)
+		return errors.Wrap(err, fmt.Sprintf("Error unbinding security group '%s' from host '%s': %s", sg.Name, hostID, ProviderErrorToString(err)))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// A VIP is a Neutron port without device, reserving an IP address in the network; the hosts allowed to hold
+// the VIP have this address in the allowed address pairs of their own port, the move of the address between
+// them being done from inside the hosts (with VRRP for example)
+
+// CreateVIP creates a private virtual IP in the network identified by networkID
+func (client *Client) CreateVIP(networkID string, name string) (*model.VIP, error) {
+	asu := true
+	port, err := ports.Create(client.Network, ports.CreateOpts{
+		NetworkID:    networkID,
+		Name:         name,
+		AdminStateUp: &asu,
+	}).Extract()
+	if err != nil {
+		log.Debugf("Error creating VIP: port creation invocation: %+v", err)
+		return nil, errors.Wrap(err, fmt.Sprintf("Error creating VIP '%s': %s", name, ProviderErrorToString(err)))
+	}
+	if len(port.FixedIPs) == 0 {
+		derr := ports.Delete(client.Network, port.ID).ExtractErr()
+		if derr != nil {
+			log.Errorf("Failed to delete port of VIP '%s': %v", name, derr)
+		}
+		return nil, fmt.Errorf("Error creating VIP '%s': no IP address allocated", name)
+	}
+	return &model.VIP{
+		ID:        port.ID,
+		Name:      name,
+		NetworkID: networkID,
+		PrivateIP: port.FixedIPs[0].IPAddress,
+	}, nil
+}
+
+// getHostPort returns the port of the host identified by hostID in the network identified by networkID
+func (client *Client) getHostPort(hostID string, networkID string) (*ports.Port, error) {
+	pages, err := ports.List(client.Network, ports.ListOpts{
+		DeviceID:  hostID,
+		NetworkID: networkID,
+	}).AllPages()
+	if err != nil {
+		return nil, err
+	}
+	list, err := ports.ExtractPorts(pages)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("host '%s' isn't connected to network '%s'", hostID, networkID)
+	}
+	return &list[0], nil
+}
+
+// BindHostToVIP allows the host identified by hostID to hold the VIP
+func (client *Client) BindHostToVIP(vip *model.VIP, hostID string) error {
+	port, err := client.getHostPort(hostID, vip.NetworkID)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Error binding host '%s' to VIP '%s': %s", hostID, vip.Name, ProviderErrorToString(err)))
+	}
+	pairs := port.AllowedAddressPairs
+	for _, p := range pairs {
+		if p.IPAddress == vip.PrivateIP {
+			return nil
+		}
+	}
+	pairs = append(pairs, ports.AddressPair{IPAddress: vip.PrivateIP})
+	_, err = ports.Update(client.Network, port.ID, ports.UpdateOpts{AllowedAddressPairs: &pairs}).Extract()
+	if err != nil {
+		log.Debugf("Error binding host to VIP: port update invocation: %+v", err)
+		return errors.Wrap(err, fmt.Sprintf("Error binding host '%s' to VIP '%s': %s", hostID, vip.Name, ProviderErrorToString(err)))
+	}
+	return nil
+}
+
+// UnbindHostFromVIP forbids the host identified by hostID to hold the VIP
+func (client *Client) UnbindHostFromVIP(vip *model.VIP, hostID string) error {
+	port, err := client.getHostPort(hostID, vip.NetworkID)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Error unbinding host '%s' from VIP '%s': %s", hostID, vip.Name, ProviderErrorToString(err)))
+	}
+	pairs := []ports.AddressPair{}
+	for _, p := range port.AllowedAddressPairs {
+		if p.IPAddress != vip.PrivateIP {
+			pairs = append(pairs, p)
+		}
+	}
+	if len(pairs) == len(port.AllowedAddressPairs) {
+		return nil
+	}
+	_, err = ports.Update(client.Network, port.ID, ports.UpdateOpts{AllowedAddressPairs: &pairs}).Extract()
+	if err != nil {
+		log.Debugf("Error unbinding host from VIP: port update invocation: %+v", err)
+		return errors.Wrap(err, fmt.Sprintf("Error unbinding host '%s' from VIP '%s': %s", hostID, vip.Name, ProviderErrorToString(err)))
+	}
+	return nil
+}
+
+// DeleteVIP deletes the VIP
+func (client *Client) DeleteVIP(vip *model.VIP) error {
+	for _, h := range vip.Hosts {
+		err := client.UnbindHostFromVIP(vip, h)
+		if err != nil {
+			return err
+		}
+	}
+	err := ports.Delete(client.Network, vip.ID).ExtractErr()
+	if err != nil {
+		log.Debugf("Error deleting VIP: port deletion invocation: %+v", err)
+		return errors.Wrap(err, fmt.Sprintf("Error deleting VIP '%s': %s", vip.Name, ProviderErrorToString(err)))
+	}
+	return nil
+}
@@ -110,6 +110,11 @@ func (client *Client) GetCfgOpts() (model.Config, error) {
 	return client.feclt.GetCfgOpts()
 }
 
+// GetCapabilities returns what the provider supports
+func (client *Client) GetCapabilities() model.Capabilities {
+	return client.feclt.GetCapabilities()
+}
+
 // init registers the opentelekom provider
 func init() {
 	providers.Register("opentelekom", &Client{})
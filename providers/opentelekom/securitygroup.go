@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentelekom
+
+import (
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// CreateSecurityGroup creates a security group, without rule
+func (client *Client) CreateSecurityGroup(request model.SecurityGroupRequest) (*model.SecurityGroup, error) {
+	return client.feclt.CreateSecurityGroup(request)
+}
+
+// GetSecurityGroup returns the security group identified by id, with its rules
+func (client *Client) GetSecurityGroup(id string) (*model.SecurityGroup, error) {
+	return client.feclt.GetSecurityGroup(id)
+}
+
+// ListSecurityGroups lists available security groups
+func (client *Client) ListSecurityGroups() ([]model.SecurityGroup, error) {
+	return client.feclt.ListSecurityGroups()
+}
+
+// DeleteSecurityGroup deletes the security group identified by id
+func (client *Client) DeleteSecurityGroup(id string) error {
+	return client.feclt.DeleteSecurityGroup(id)
+}
+
+// AddRule adds a rule allowing incoming traffic to the security group identified by groupID
+func (client *Client) AddRule(groupID string, rule model.SecurityGroupRule) (*model.SecurityGroupRule, error) {
+	return client.feclt.AddRule(groupID, rule)
+}
+
+// DeleteRule deletes the rule identified by ruleID from the security group identified by groupID
+func (client *Client) DeleteRule(groupID string, ruleID string) error {
+	return client.feclt.DeleteRule(groupID, ruleID)
+}
+
+// BindToHost binds the security group identified by groupID to the host identified by hostID
+func (client *Client) BindToHost(groupID string, hostID string) error {
+	return client.feclt.BindToHost(groupID, hostID)
+}
+
+// UnbindFromHost unbinds the security group identified by groupID from the host identified by hostID
+func (client *Client) UnbindFromHost(groupID string, hostID string) error {
+	return client.feclt.UnbindFromHost(groupID, hostID)
+}
@@ -163,6 +163,13 @@ func (client *Client) GetAuthOpts() (model.Config, error) {
 	return client.osclt.GetAuthOpts()
 }
 
+// GetCapabilities returns what the provider supports, GPU being offered by the templates of gpuMap
+func (client *Client) GetCapabilities() model.Capabilities {
+	capabilities := client.osclt.GetCapabilities()
+	capabilities.GPU = true
+	return capabilities
+}
+
 func init() {
 	providers.Register("ovh", &Client{})
 }
@@ -197,6 +197,12 @@ func Test_VolumeAttachment(t *testing.T) {
 	cli.VolumeAttachment(t)
 }
 
+func Test_SecurityGroups(t *testing.T) {
+	cli, err := getClient()
+	require.Nil(t, err)
+	cli.SecurityGroups(t)
+}
+
 func Test_Containers(t *testing.T) {
 	cli, err := getClient()
 	require.Nil(t, err)
@@ -44,6 +44,11 @@ type Service struct {
 	api.ClientAPI
 	ObjectStorage  objectstorage.Location
 	MetadataBucket objectstorage.Bucket
+	// MetadataKeys contains the passphrases used to encrypt metadata; the first one encrypts,
+	// the others are only used to decrypt objects not yet re-encrypted after a key rotation
+	MetadataKeys []string
+	// UserData contains the default cloud-init parts of the tenant, added before the ones of the requests
+	UserData []string
 }
 
 // // FromClient contructs a Service instance from a ClientAPI
@@ -433,6 +438,7 @@ func (svc *Service) CreateHostWithKeyPair(request model.HostRequest) (*model.Hos
 		Networks:       request.Networks,
 		DefaultGateway: request.DefaultGateway,
 		TemplateID:     request.TemplateID,
+		UserData:       request.UserData,
 	}
 	host, err := svc.CreateHost(hostReq)
 	if err != nil {
@@ -441,6 +447,36 @@ func (svc *Service) CreateHostWithKeyPair(request model.HostRequest) (*model.Hos
 	return host, kp, nil
 }
 
+// CreateHost creates an host, with the default cloud-init parts of the tenant
+func (svc *Service) CreateHost(request model.HostRequest) (*model.Host, error) {
+	request.UserData = svc.withDefaultUserData(request.UserData)
+	return svc.ClientAPI.CreateHost(request)
+}
+
+// CreateGateway creates a gateway, with the default cloud-init parts of the tenant
+func (svc *Service) CreateGateway(request model.GatewayRequest) (*model.Host, error) {
+	request.UserData = svc.withDefaultUserData(request.UserData)
+	return svc.ClientAPI.CreateGateway(request)
+}
+
+// withDefaultUserData returns the default cloud-init parts of the tenant followed by parts
+func (svc *Service) withDefaultUserData(parts []string) []string {
+	if len(svc.UserData) == 0 {
+		return parts
+	}
+	return append(append([]string{}, svc.UserData...), parts...)
+}
+
+// GetCapabilities returns what the provider supports, the Object Storage being the one configured for the tenant
+func (svc *Service) GetCapabilities() model.Capabilities {
+	capabilities := svc.ClientAPI.GetCapabilities()
+	capabilities.ObjectStorage = ""
+	if svc.ObjectStorage != nil {
+		capabilities.ObjectStorage = svc.ObjectStorage.GetType()
+	}
+	return capabilities
+}
+
 // ListHostsByName list hosts by name
 func (svc *Service) ListHostsByName() (map[string]*model.Host, error) {
 	hosts, err := svc.ListHosts()
@@ -35,10 +35,13 @@ import (
 	"github.com/CS-SI/SafeScale/providers/model/enums/VolumeState"
 	"github.com/CS-SI/SafeScale/providers/objectstorage"
 
+	_ "github.com/CS-SI/SafeScale/providers/aws"            // Imported to initialize tenant aws
 	_ "github.com/CS-SI/SafeScale/providers/cloudferro"     // Imported to initialize tenant ovh
 	_ "github.com/CS-SI/SafeScale/providers/cloudwatt"      // Imported to initialize tenant cloudwatt
+	_ "github.com/CS-SI/SafeScale/providers/fake"           // Imported to initialize tenant fake
 	_ "github.com/CS-SI/SafeScale/providers/flexibleengine" // Imported to initialize tenant flexibleengine
 	_ "github.com/CS-SI/SafeScale/providers/opentelekom"    // Imported to initialize tenant opentelekoms
+	_ "github.com/CS-SI/SafeScale/providers/openstack"      // Imported to initialize tenant openstack
 	_ "github.com/CS-SI/SafeScale/providers/ovh"            // Imported to initialize tenant ovh
 )
 
@@ -512,6 +515,50 @@ func (tester *ClientTester) Volume(t *testing.T) {
 
 }
 
+//SecurityGroups test
+func (tester *ClientTester) SecurityGroups(t *testing.T) {
+	lst, err := tester.Service.ListSecurityGroups()
+	require.Nil(t, err)
+	nbGroups := len(lst)
+
+	sg, err := tester.Service.CreateSecurityGroup(model.SecurityGroupRequest{
+		Name: "test_securitygroup",
+	})
+	require.Nil(t, err)
+	defer tester.Service.DeleteSecurityGroup(sg.ID)
+	assert.Equal(t, "test_securitygroup", sg.Name)
+
+	rule, err := tester.Service.AddRule(sg.ID, model.SecurityGroupRule{
+		Protocol: "tcp",
+		FromPort: 8080,
+		ToPort:   8081,
+		CIDR:     "10.0.0.0/8",
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "tcp", rule.Protocol)
+	assert.Equal(t, 8080, rule.FromPort)
+	assert.Equal(t, 8081, rule.ToPort)
+	assert.Equal(t, "10.0.0.0/8", rule.CIDR)
+	_, err = tester.Service.AddRule(sg.ID, model.SecurityGroupRule{Protocol: "sctp", FromPort: 1, ToPort: 1})
+	assert.NotNil(t, err)
+
+	sg2, err := tester.Service.GetSecurityGroup(sg.ID)
+	require.Nil(t, err)
+	require.NotNil(t, sg2)
+	assert.Equal(t, sg.Name, sg2.Name)
+	assert.Equal(t, []model.SecurityGroupRule{*rule}, sg2.Rules)
+
+	lst, err = tester.Service.ListSecurityGroups()
+	assert.Nil(t, err)
+	assert.Equal(t, nbGroups+1, len(lst))
+
+	err = tester.Service.DeleteRule(sg.ID, rule.ID)
+	assert.Nil(t, err)
+	sg2, err = tester.Service.GetSecurityGroup(sg.ID)
+	require.Nil(t, err)
+	assert.Empty(t, sg2.Rules)
+}
+
 //VolumeAttachment test
 func (tester *ClientTester) VolumeAttachment(t *testing.T) {
 	// TODO: handle kp delete
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package userdata
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/CS-SI/SafeScale/utils/retry"
+)
+
+const (
+	// HostKeyBegin marks the beginning of the public SSH host key printed on the console by the user data
+	HostKeyBegin = "-----BEGIN SAFESCALE HOST KEY-----"
+	// HostKeyEnd marks the end of the public SSH host key printed on the console by the user data
+	HostKeyEnd = "-----END SAFESCALE HOST KEY-----"
+)
+
+// ParseHostKey returns the public SSH host key (authorized_keys format) printed by the user data between HostKeyBegin
+// and HostKeyEnd in the console output of a host; if the key is printed several times, the last one is returned
+func ParseHostKey(output string) (string, error) {
+	end := strings.LastIndex(output, HostKeyEnd)
+	if end < 0 {
+		return "", fmt.Errorf("no SSH host key in console output")
+	}
+	begin := strings.LastIndex(output[:end], HostKeyBegin)
+	if begin < 0 {
+		return "", fmt.Errorf("no SSH host key in console output")
+	}
+	// The console may mix kernel messages with the key, the first line holding a valid key is used
+	for _, line := range strings.Split(output[begin+len(HostKeyBegin):end], "\n") {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(line)))
+		if err == nil {
+			return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))), nil
+		}
+	}
+	return "", fmt.Errorf("invalid SSH host key in console output")
+}
+
+// WaitHostKey reads the console output of a host with 'consoleOutput' until it contains the public SSH host key
+// generated on the host by the user data, and returns this key
+func WaitHostKey(consoleOutput func() (string, error), timeout time.Duration) (string, error) {
+	var hostKey string
+	err := retry.WhileUnsuccessfulDelay5Seconds(
+		func() error {
+			output, err := consoleOutput()
+			if err != nil {
+				return err
+			}
+			hostKey, err = ParseHostKey(output)
+			return err
+		},
+		timeout,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to read SSH host key on the console of the host: %s", err.Error())
+	}
+	return hostKey, nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package userdata_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers/userdata"
+)
+
+const (
+	testHostKey  = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGvlS1ExJywC7i9x5AQnmmW8MKQKOYh5U2QAbm0/gYCy"
+	otherHostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAICvYp8TFjv+Ma97UV+MBakqqn9R5hkSCDFD70gojOvDd"
+)
+
+func printed(key string) string {
+	return userdata.HostKeyBegin + "\r\n" + key + " root@host\r\n" + userdata.HostKeyEnd + "\r\n"
+}
+
+func TestParseHostKey(t *testing.T) {
+	_, err := userdata.ParseHostKey("[    0.000000] Linux version 4.15.0\n")
+	assert.Error(t, err)
+	_, err = userdata.ParseHostKey(userdata.HostKeyBegin + "\n" + testHostKey)
+	assert.Error(t, err)
+	_, err = userdata.ParseHostKey(userdata.HostKeyBegin + "\nssh-ed25519 AAAA\n" + userdata.HostKeyEnd + "\n")
+	assert.Error(t, err)
+
+	key, err := userdata.ParseHostKey("[    2.123456] random: crng init done\n" + printed(testHostKey) + "login:")
+	require.NoError(t, err)
+	assert.Equal(t, testHostKey, key)
+
+	// Kernel messages between the markers are skipped
+	key, err = userdata.ParseHostKey(userdata.HostKeyBegin + "\n[   12.345678] cloud-init[1234]: ok\n" + testHostKey + "\n" + userdata.HostKeyEnd)
+	require.NoError(t, err)
+	assert.Equal(t, testHostKey, key)
+
+	// The last key printed wins
+	key, err = userdata.ParseHostKey(printed(otherHostKey) + printed(testHostKey))
+	require.NoError(t, err)
+	assert.Equal(t, testHostKey, key)
+}
+
+func TestHostKeyPrinted(t *testing.T) {
+	script, err := ioutil.ReadFile("scripts/userdata.sh")
+	require.NoError(t, err)
+	assert.Contains(t, string(script), userdata.HostKeyBegin)
+	assert.Contains(t, string(script), userdata.HostKeyEnd)
+	assert.NotContains(t, string(script), ".HostKey")
+}
+
+func TestWaitHostKey(t *testing.T) {
+	calls := 0
+	key, err := userdata.WaitHostKey(func() (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errors.New("console not available yet")
+		}
+		return printed(testHostKey), nil
+	}, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, testHostKey, key)
+	assert.Equal(t, 2, calls)
+
+	_, err = userdata.WaitHostKey(func() (string, error) {
+		return "booting...", nil
+	}, time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// The custom parts are added to the SafeScale script in a MIME multipart document. cloud-init runs the shell
+// scripts in the alphabetical order of their file names, after having applied the cloud-config documents:
+// the SafeScale script comes first, then the shell parts in the order of the request, and the last part marks
+// the host as ready and reboots it (done by the SafeScale script itself when there is no custom part). The last
+// part does it only if the SafeScale script succeeded, which it tells by writing /var/tmp/user_data.safescale.
+
+// partType describes a type of cloud-init part, recognized by the beginning of its content
+type partType struct {
+	prefix      string
+	contentType string
+	extension   string
+}
+
+// partTypes are the types of the custom parts
+var partTypes = []partType{
+	{prefix: "#cloud-config", contentType: "text/cloud-config", extension: "yaml"},
+	{prefix: "#!", contentType: "text/x-shellscript", extension: "sh"},
+}
+
+// finalPart is the last part of the user data when there are custom parts
+const finalPart = `#!/bin/bash
+if [ ! -f /var/tmp/user_data.safescale ]; then
+    echo "SafeScale script failed, host not marked as ready" >>/var/tmp/user_data.log
+    exit 1
+fi
+echo "$(. /etc/os-release && echo ${ID}),$(date +%Y/%m/%d-%H:%M:%S)" >/var/tmp/user_data.done
+systemctl reboot
+exit 0
+`
+
+// getPartType returns the type of the cloud-init part
+func getPartType(part string) (*partType, error) {
+	content := strings.TrimLeft(part, " \t\r\n")
+	for i, t := range partTypes {
+		if strings.HasPrefix(content, t.prefix) {
+			return &partTypes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported user data part, must start with '#cloud-config' or '#!'")
+}
+
+// CheckParts checks the custom parts are cloud-config documents or shell scripts
+func CheckParts(parts []string) error {
+	for i, p := range parts {
+		_, err := getPartType(p)
+		if err != nil {
+			return fmt.Errorf("invalid user data part #%d: %s", i+1, err.Error())
+		}
+	}
+	return nil
+}
+
+// Combine merges the SafeScale script and the custom parts in a MIME multipart document handled by cloud-init
+func Combine(script []byte, parts []string) ([]byte, error) {
+	buffer := bytes.NewBufferString("")
+	writer := multipart.NewWriter(buffer)
+	fmt.Fprintf(buffer, "Content-Type: multipart/mixed; boundary=\"%s\"\r\nMIME-Version: 1.0\r\n\r\n", writer.Boundary())
+
+	err := addPart(writer, "text/x-shellscript", "00-safescale.sh", script)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range parts {
+		t, err := getPartType(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user data part #%d: %s", i+1, err.Error())
+		}
+		content := strings.TrimLeft(p, " \t\r\n")
+		err = addPart(writer, t.contentType, fmt.Sprintf("part-%02d.%s", i+1, t.extension), []byte(content))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = addPart(writer, "text/x-shellscript", "zz-safescale-done.sh", []byte(finalPart))
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// addPart adds a part to the MIME multipart document
+func addPart(writer *multipart.Writer, contentType string, filename string, content []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=\"utf-8\"")
+	header.Set("MIME-Version", "1.0")
+	header.Set("Content-Transfer-Encoding", "8bit")
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
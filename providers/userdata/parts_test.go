@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package userdata_test
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers/userdata"
+)
+
+func TestCheckParts(t *testing.T) {
+	assert.NoError(t, userdata.CheckParts(nil))
+	assert.NoError(t, userdata.CheckParts([]string{
+		"#cloud-config\npackages:\n  - htop\n",
+		"\n#!/bin/sh\necho hello\n",
+	}))
+	err := userdata.CheckParts([]string{"#!/bin/bash\n", "packages:\n  - htop\n"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "#2")
+}
+
+func TestCombine(t *testing.T) {
+	script := []byte("#!/bin/bash\necho safescale\n")
+	content, err := userdata.Combine(script, []string{
+		"#cloud-config\npackages:\n  - htop\n",
+		"#!/bin/sh\necho hello\n",
+	})
+	require.NoError(t, err)
+
+	// Reads the document as cloud-init does: headers, then the parts
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(content)))
+	header, err := reader.ReadMIMEHeader()
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	parts := multipart.NewReader(reader.R, params["boundary"])
+	expected := []struct {
+		contentType string
+		filename    string
+		content     string
+	}{
+		{"text/x-shellscript", "00-safescale.sh", string(script)},
+		{"text/cloud-config", "part-01.yaml", "#cloud-config\npackages:\n  - htop\n"},
+		{"text/x-shellscript", "part-02.sh", "#!/bin/sh\necho hello\n"},
+		{"text/x-shellscript", "zz-safescale-done.sh", ""},
+	}
+	for _, e := range expected {
+		p, err := parts.NextPart()
+		require.NoError(t, err)
+		contentType, _, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		assert.Equal(t, e.contentType, contentType)
+		assert.Equal(t, e.filename, p.FileName())
+		data, err := ioutil.ReadAll(p)
+		require.NoError(t, err)
+		if e.content != "" {
+			assert.Equal(t, e.content, string(data))
+		} else {
+			assert.Contains(t, string(data), "/var/tmp/user_data.done")
+			// The host isn't marked as ready if the SafeScale script failed
+			assert.Contains(t, string(data), "if [ ! -f /var/tmp/user_data.safescale ]")
+		}
+	}
+	_, err = parts.NextPart()
+	assert.Error(t, err)
+
+	_, err = userdata.Combine(script, []string{"packages:\n  - htop\n"})
+	assert.Error(t, err)
+}
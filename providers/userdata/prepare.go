@@ -55,11 +55,14 @@ type userData struct {
 	Password string
 	// HostName contains the name wanted as host name (default == name of the Cloud resource)
 	HostName string
+	// CustomParts, if set to true, leaves marking the host as ready and rebooting it to the last part of the user data
+	CustomParts bool
 }
 
 var userdataTemplate *template.Template
 
-// Prepare prepares the initial configuration script executed by cloud compute resource
+// Prepare prepares the initial configuration script executed by cloud compute resource; if the request contains
+// custom cloud-init parts, the script and the parts are merged in a MIME multipart document
 func Prepare(
 	client api.ClientAPI, request model.HostRequest, kp *model.KeyPair, cidr string,
 ) ([]byte, error) {
@@ -138,13 +141,17 @@ func Prepare(
 
 		//HostName:   request.Name,
 	}
+	data.CustomParts = len(request.UserData) > 0
 
 	dataBuffer := bytes.NewBufferString("")
 	err = userdataTemplate.Execute(dataBuffer, data)
 	if err != nil {
 		return nil, err
 	}
-	return dataBuffer.Bytes(), nil
+	if !data.CustomParts {
+		return dataBuffer.Bytes(), nil
+	}
+	return Combine(dataBuffer.Bytes(), request.UserData)
 }
 
 func initUserdataTemplate() error {
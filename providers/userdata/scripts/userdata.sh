@@ -87,6 +87,21 @@ sfSaveIptablesRules() {
    esac
 }
 
+# Replaces the SSH host keys of the image by a new one generated on the host, and prints its public part on the console
+# between markers, where SafeScale reads it to authenticate the host
+generate_host_key() {
+    echo "Generating SSH host key..."
+    rm -f /etc/ssh/ssh_host_*key /etc/ssh/ssh_host_*key.pub
+    ssh-keygen -q -t rsa -b 4096 -N '' -f /etc/ssh/ssh_host_rsa_key || return 1
+    systemctl restart sshd || systemctl restart ssh
+    local key=$(cut -d' ' -f1,2 /etc/ssh/ssh_host_rsa_key.pub)
+    for console in /dev/console /dev/ttyS0; do
+        [ -w $console ] && printf '%s\n%s\n%s\n' "-----BEGIN SAFESCALE HOST KEY-----" "$key" "-----END SAFESCALE HOST KEY-----" >$console
+    done
+    echo done
+    return 0
+}
+
 create_user() {
     echo "Creating user {{ .User }}..."
     useradd {{ .User }} --home-dir /home/{{ .User }} --shell /bin/bash --comment "" --create-home
@@ -464,6 +479,7 @@ case $LINUX_KIND in
         export DEBIAN_FRONTEND=noninteractive
         systemctl stop apt-daily.service &>/dev/null
         systemctl kill --kill-who=all apt-daily.service &>/dev/null
+        generate_host_key
         create_user
         {{- if .ConfIF }}
         systemctl status systemd-networkd &>/dev/null && configure_network_netplan || configure_network_debian
@@ -477,6 +493,7 @@ case $LINUX_KIND in
         ;;
 
     redhat|centos)
+        generate_host_key
         create_user
         {{- if .ConfIF }}
         configure_network_redhat
@@ -497,6 +514,11 @@ esac
 install_packages
 lspci | grep -i nvidia &>/dev/null && install_drivers_nvidia
 
+{{- if not .CustomParts }}
 echo "${LINUX_KIND},$(date +%Y/%m/%d-%H:%M:%S)" >/var/tmp/user_data.done
 systemctl reboot
+{{- else }}
+# The last part of the user data marks the host as ready only if this script succeeded
+echo "${LINUX_KIND},$(date +%Y/%m/%d-%H:%M:%S)" >/var/tmp/user_data.safescale
+{{- end }}
 exit 0
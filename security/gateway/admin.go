@@ -0,0 +1,465 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+//adminPath is the path prefix of the administration API, which can't be used as service name
+const adminPath = "/admin/"
+
+//Administration API routes:
+//  POST                /admin/init
+//  GET, POST           /admin/services
+//  GET, PUT, DELETE    /admin/services/<service>
+//  GET, POST           /admin/services/<service>/roles
+//  GET, DELETE         /admin/services/<service>/roles/<role>
+//  GET, POST           /admin/services/<service>/roles/<role>/permissions
+//  DELETE              /admin/services/<service>/roles/<role>/permissions/<id>
+//  GET                 /admin/services/<service>/limits
+//  PUT, DELETE         /admin/services/<service>/limits/<email|*>
+//  GET, POST           /admin/users
+//  GET, DELETE         /admin/users/<email>
+//  PUT, DELETE         /admin/users/<email>/roles/<service>/<role>
+//  GET                 /admin/audit?email=&service=&decision=&since=&until=&limit=
+
+//ServiceRequest is the body of the creation and the update of a service
+type ServiceRequest struct {
+	Name    string `json:"name,omitempty"`
+	BaseURL string `json:"base_url"`
+}
+
+//RoleRequest is the body of the creation of a role
+type RoleRequest struct {
+	Name string `json:"name"`
+}
+
+//AccessPermissionRequest is the body of the creation of an access permission
+type AccessPermissionRequest struct {
+	Action          string `json:"action"`
+	ResourcePattern string `json:"resource_pattern"`
+}
+
+//RateLimitRequest is the body of the definition of a rate limit
+type RateLimitRequest struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+	DailyQuota        int     `json:"daily_quota"`
+}
+
+//UserRequest is the body of the creation of a user
+type UserRequest struct {
+	Email string `json:"email"`
+}
+
+//ErrorResponse is the body of the response of a failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+//adminAPI serves the administration API of the services, roles, access permissions and users of the gateway
+type adminAPI struct {
+	da    *model.DataAccess
+	cache *permissionCache
+	//token is the static token allowed to use the API, if not empty
+	token string
+	//users are the emails of the authenticated users allowed to use the API
+	users map[string]bool
+	//authenticate returns the email of the user owning the OpenID token, nil if authentication is disabled
+	authenticate func(token string) (string, int)
+	//audit is the audit sink of the gateway, nil if audit is disabled
+	audit AuditSink
+}
+
+func newAdminAPI(da *model.DataAccess, cache *permissionCache, token string, users []string, authenticate func(string) (string, int)) *adminAPI {
+	api := adminAPI{
+		da:           da,
+		cache:        cache,
+		token:        token,
+		users:        map[string]bool{},
+		authenticate: authenticate,
+	}
+	for _, u := range users {
+		api.users[u] = true
+	}
+	return &api
+}
+
+//enabled returns true if someone is allowed to use the API
+func (a *adminAPI) enabled() bool {
+	return a.token != "" || (a.authenticate != nil && len(a.users) > 0)
+}
+
+//authorized checks the token of the request is the static token or belongs to an administrator, and returns
+//the HTTP status to respond if not
+func (a *adminAPI) authorized(r *http.Request) (bool, int) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return false, http.StatusUnauthorized
+	}
+	if a.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1 {
+		return true, http.StatusOK
+	}
+	if a.authenticate == nil || len(a.users) == 0 {
+		return false, http.StatusUnauthorized
+	}
+	email, status := a.authenticate(token)
+	if status != http.StatusOK {
+		return false, http.StatusUnauthorized
+	}
+	if !a.users[email] {
+		return false, http.StatusForbidden
+	}
+	return true, http.StatusOK
+}
+
+func (a *adminAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ok, status := a.authorized(r); !ok {
+		writeError(w, status, errors.New(http.StatusText(status)))
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, adminPath), "/")
+	var route []string
+	if path != "" {
+		route = strings.Split(path, "/")
+	}
+	switch {
+	case len(route) == 1 && route[0] == "init":
+		a.init(w, r)
+	case len(route) >= 1 && route[0] == "services":
+		a.serveServices(w, r, route[1:])
+	case len(route) >= 1 && route[0] == "users":
+		a.serveUsers(w, r, route[1:])
+	case len(route) == 1 && route[0] == "audit":
+		a.queryAudit(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown route '%s'", r.URL.Path))
+	}
+}
+
+func (a *adminAPI) init(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	err := a.da.Migrate()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	a.cache.invalidateAll()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminAPI) serveServices(w http.ResponseWriter, r *http.Request, route []string) {
+	switch {
+	case len(route) == 0 && r.Method == http.MethodGet:
+		services, err := a.da.ListServices()
+		writeResult(w, http.StatusOK, services, err)
+	case len(route) == 0 && r.Method == http.MethodPost:
+		var req ServiceRequest
+		if !readRequest(w, r, &req) {
+			return
+		}
+		err := validateService(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		srv, err := a.da.CreateService(req.Name, req.BaseURL)
+		a.cache.invalidateService(req.Name)
+		writeResult(w, http.StatusCreated, srv, err)
+	case len(route) == 1 && r.Method == http.MethodGet:
+		srv, err := a.da.GetService(route[0])
+		writeResult(w, http.StatusOK, srv, err)
+	case len(route) == 1 && r.Method == http.MethodPut:
+		var req ServiceRequest
+		if !readRequest(w, r, &req) {
+			return
+		}
+		req.Name = route[0]
+		err := validateService(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		srv, err := a.da.UpdateService(req.Name, req.BaseURL)
+		a.cache.invalidateService(req.Name)
+		writeResult(w, http.StatusOK, srv, err)
+	case len(route) == 1 && r.Method == http.MethodDelete:
+		err := a.da.DeleteService(route[0])
+		a.cache.invalidateService(route[0])
+		writeResult(w, http.StatusNoContent, nil, err)
+	case len(route) >= 2 && route[1] == "roles":
+		a.serveRoles(w, r, route[0], route[2:])
+	case len(route) >= 2 && route[1] == "limits":
+		a.serveRateLimits(w, r, route[0], route[2:])
+	case len(route) <= 1:
+		methodNotAllowed(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown route '%s'", r.URL.Path))
+	}
+}
+
+func (a *adminAPI) serveRoles(w http.ResponseWriter, r *http.Request, service string, route []string) {
+	switch {
+	case len(route) == 0 && r.Method == http.MethodGet:
+		roles, err := a.da.ListRoles(service)
+		writeResult(w, http.StatusOK, roles, err)
+	case len(route) == 0 && r.Method == http.MethodPost:
+		var req RoleRequest
+		if !readRequest(w, r, &req) {
+			return
+		}
+		if req.Name == "" || strings.Contains(req.Name, "/") {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid role name '%s'", req.Name))
+			return
+		}
+		role, err := a.da.CreateRole(service, req.Name)
+		writeResult(w, http.StatusCreated, role, err)
+	case len(route) == 1 && r.Method == http.MethodGet:
+		role, err := a.da.GetRole(service, route[0])
+		writeResult(w, http.StatusOK, role, err)
+	case len(route) == 1 && r.Method == http.MethodDelete:
+		err := a.da.DeleteRole(service, route[0])
+		a.cache.invalidateService(service)
+		writeResult(w, http.StatusNoContent, nil, err)
+	case len(route) >= 2 && route[1] == "permissions":
+		a.servePermissions(w, r, service, route[0], route[2:])
+	case len(route) <= 1:
+		methodNotAllowed(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown route '%s'", r.URL.Path))
+	}
+}
+
+func (a *adminAPI) servePermissions(w http.ResponseWriter, r *http.Request, service, role string, route []string) {
+	switch {
+	case len(route) == 0 && r.Method == http.MethodGet:
+		res, err := a.da.GetRole(service, role)
+		var permissions []model.AccessPermission
+		if err == nil {
+			permissions = res.AccessPermissions
+			if permissions == nil {
+				permissions = []model.AccessPermission{}
+			}
+		}
+		writeResult(w, http.StatusOK, permissions, err)
+	case len(route) == 0 && r.Method == http.MethodPost:
+		var req AccessPermissionRequest
+		if !readRequest(w, r, &req) {
+			return
+		}
+		req.Action = strings.ToUpper(req.Action)
+		if req.Action == "" || strings.ContainsAny(req.Action, " \t/") {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid action '%s'", req.Action))
+			return
+		}
+		_, err := glob.Compile(req.ResourcePattern)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid resource pattern '%s': %v", req.ResourcePattern, err))
+			return
+		}
+		permission, err := a.da.AddAccessPermission(service, role, req.Action, req.ResourcePattern)
+		a.cache.invalidateService(service)
+		writeResult(w, http.StatusCreated, permission, err)
+	case len(route) == 1 && r.Method == http.MethodDelete:
+		id, err := strconv.ParseUint(route[0], 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid access permission ID '%s'", route[0]))
+			return
+		}
+		err = a.da.DeleteAccessPermission(service, role, uint(id))
+		a.cache.invalidateService(service)
+		writeResult(w, http.StatusNoContent, nil, err)
+	case len(route) <= 1:
+		methodNotAllowed(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown route '%s'", r.URL.Path))
+	}
+}
+
+func (a *adminAPI) serveRateLimits(w http.ResponseWriter, r *http.Request, service string, route []string) {
+	switch {
+	case len(route) == 0 && r.Method == http.MethodGet:
+		limits, err := a.da.ListRateLimits(service)
+		writeResult(w, http.StatusOK, limits, err)
+	case len(route) == 1 && r.Method == http.MethodPut:
+		var req RateLimitRequest
+		if !readRequest(w, r, &req) {
+			return
+		}
+		limit := model.RateLimit{RequestsPerSecond: req.RequestsPerSecond, Burst: req.Burst, DailyQuota: req.DailyQuota}
+		err := limit.Validate()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid rate limit: %v", err))
+			return
+		}
+		result, err := a.da.SetRateLimit(service, route[0], req.RequestsPerSecond, req.Burst, req.DailyQuota)
+		a.invalidateRateLimit(service, route[0])
+		writeResult(w, http.StatusOK, result, err)
+	case len(route) == 1 && r.Method == http.MethodDelete:
+		err := a.da.DeleteRateLimit(service, route[0])
+		a.invalidateRateLimit(service, route[0])
+		writeResult(w, http.StatusNoContent, nil, err)
+	case len(route) <= 1:
+		methodNotAllowed(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown route '%s'", r.URL.Path))
+	}
+}
+
+//invalidateRateLimit removes from the cache the rate limits impacted by the change of the one of email
+func (a *adminAPI) invalidateRateLimit(service, email string) {
+	if email == model.AnyUser {
+		a.cache.invalidateService(service)
+	} else {
+		a.cache.invalidateUser(email)
+	}
+}
+
+func (a *adminAPI) serveUsers(w http.ResponseWriter, r *http.Request, route []string) {
+	switch {
+	case len(route) == 0 && r.Method == http.MethodGet:
+		users, err := a.da.ListUsers()
+		writeResult(w, http.StatusOK, users, err)
+	case len(route) == 0 && r.Method == http.MethodPost:
+		var req UserRequest
+		if !readRequest(w, r, &req) {
+			return
+		}
+		if req.Email == "" || strings.Contains(req.Email, "/") {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid user email '%s'", req.Email))
+			return
+		}
+		user, err := a.da.CreateUser(req.Email)
+		writeResult(w, http.StatusCreated, user, err)
+	case len(route) == 1 && r.Method == http.MethodGet:
+		user, err := a.da.GetUser(route[0])
+		writeResult(w, http.StatusOK, user, err)
+	case len(route) == 1 && r.Method == http.MethodDelete:
+		err := a.da.DeleteUser(route[0])
+		a.cache.invalidateUser(route[0])
+		writeResult(w, http.StatusNoContent, nil, err)
+	case len(route) == 4 && route[1] == "roles" && r.Method == http.MethodPut:
+		err := a.da.AddUserRole(route[0], route[2], route[3])
+		a.cache.invalidateUser(route[0])
+		writeResult(w, http.StatusNoContent, nil, err)
+	case len(route) == 4 && route[1] == "roles" && r.Method == http.MethodDelete:
+		err := a.da.RemoveUserRole(route[0], route[2], route[3])
+		a.cache.invalidateUser(route[0])
+		writeResult(w, http.StatusNoContent, nil, err)
+	case len(route) <= 1 || (len(route) == 4 && route[1] == "roles"):
+		methodNotAllowed(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown route '%s'", r.URL.Path))
+	}
+}
+
+func (a *adminAPI) queryAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+	querier, ok := a.audit.(AuditQuerier)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("the audit sink of the gateway can't be queried"))
+		return
+	}
+	q := r.URL.Query()
+	filter := AuditFilter{
+		Email:    q.Get("email"),
+		Service:  q.Get("service"),
+		Decision: q.Get("decision"),
+	}
+	var err error
+	if v := q.Get("since"); v != "" {
+		filter.Since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid start time '%s': %v", v, err))
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		filter.Until, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid end time '%s': %v", v, err))
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit '%s'", v))
+			return
+		}
+	}
+	records, err := querier.Query(filter)
+	writeResult(w, http.StatusOK, records, err)
+}
+
+//validateService checks the name and the base URL of a service
+func validateService(req ServiceRequest) error {
+	if req.Name == "" || strings.Contains(req.Name, "/") || req.Name == strings.Trim(adminPath, "/") {
+		return fmt.Errorf("invalid service name '%s'", req.Name)
+	}
+	u, err := url.Parse(req.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid base URL '%s' of service '%s'", req.BaseURL, req.Name)
+	}
+	return nil
+}
+
+//readRequest decodes the JSON body of the request in v, responding an error if it fails
+func readRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+//writeResult responds v encoded in JSON with status, or err with the HTTP status corresponding to its type
+func writeResult(w http.ResponseWriter, status int, v interface{}, err error) {
+	if err != nil {
+		switch err.(type) {
+		case model.ErrNotFound:
+			writeError(w, http.StatusNotFound, err)
+		case model.ErrAlreadyExists:
+			writeError(w, http.StatusConflict, err)
+		default:
+			writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()})
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed on '%s'", r.Method, r.URL.Path))
+}
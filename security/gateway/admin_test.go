@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+func adminRequest(t *testing.T, srv *httptest.Server, token, method, path string, body interface{}, result interface{}) int {
+	var buf bytes.Buffer
+	if body != nil {
+		require.Nil(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req, err := http.NewRequest(method, srv.URL+path, &buf)
+	require.Nil(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	if result != nil && resp.StatusCode < 300 {
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(result))
+	}
+	return resp.StatusCode
+}
+
+func TestAdminAPI_Authorization(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	authenticate := func(token string) (string, int) {
+		if token == "admin-oidc-token" {
+			return "admin@c-s.fr", http.StatusOK
+		}
+		if token == "user-oidc-token" {
+			return "user@c-s.fr", http.StatusOK
+		}
+		return "", http.StatusForbidden
+	}
+	api := newAdminAPI(da, newPermissionCache(da, time.Minute), "secret", []string{"admin@c-s.fr"}, authenticate)
+	require.True(t, api.enabled())
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, srv, "", "GET", "/admin/services", nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, srv, "wrong", "GET", "/admin/services", nil, nil))
+	assert.Equal(t, http.StatusForbidden, adminRequest(t, srv, "user-oidc-token", "GET", "/admin/services", nil, nil))
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "admin-oidc-token", "GET", "/admin/services", nil, nil))
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/services", nil, nil))
+
+	assert.False(t, newAdminAPI(da, nil, "", []string{"admin@c-s.fr"}, nil).enabled())
+}
+
+func TestAdminAPI_Services(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	srv := httptest.NewServer(newAdminAPI(da, newPermissionCache(da, time.Minute), "secret", nil, nil))
+	defer srv.Close()
+
+	var services []model.Service
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/services", nil, &services))
+	require.Len(t, services, 1)
+	assert.Equal(t, "srv1", services[0].Name)
+
+	var service model.Service
+	assert.Equal(t, http.StatusCreated, adminRequest(t, srv, "secret", "POST", "/admin/services", ServiceRequest{Name: "srv2", BaseURL: "http://srv2:8080"}, &service))
+	assert.Equal(t, "http://srv2:8080", service.BaseURL)
+	assert.Equal(t, http.StatusConflict, adminRequest(t, srv, "secret", "POST", "/admin/services", ServiceRequest{Name: "srv2", BaseURL: "http://srv2"}, nil))
+	assert.Equal(t, http.StatusBadRequest, adminRequest(t, srv, "secret", "POST", "/admin/services", ServiceRequest{Name: "admin", BaseURL: "http://admin"}, nil))
+	assert.Equal(t, http.StatusBadRequest, adminRequest(t, srv, "secret", "POST", "/admin/services", ServiceRequest{Name: "srv3", BaseURL: "srv3"}, nil))
+
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "PUT", "/admin/services/srv2", ServiceRequest{BaseURL: "http://srv2:9090"}, &service))
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/services/srv2", nil, &service))
+	assert.Equal(t, "http://srv2:9090", service.BaseURL)
+
+	assert.Equal(t, http.StatusNoContent, adminRequest(t, srv, "secret", "DELETE", "/admin/services/srv1", nil, nil))
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, srv, "secret", "GET", "/admin/services/srv1", nil, nil))
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, srv, "secret", "DELETE", "/admin/services/srv1", nil, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, adminRequest(t, srv, "secret", "PATCH", "/admin/services", nil, nil))
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, srv, "secret", "GET", "/admin/unknown", nil, nil))
+}
+
+func TestAdminAPI_Permissions(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	cache := newPermissionCache(da, time.Minute)
+	srv := httptest.NewServer(newAdminAPI(da, cache, "secret", nil, nil))
+	defer srv.Close()
+
+	var role model.Role
+	assert.Equal(t, http.StatusCreated, adminRequest(t, srv, "secret", "POST", "/admin/services/srv1/roles", RoleRequest{Name: "ADMIN"}, &role))
+	assert.Equal(t, http.StatusConflict, adminRequest(t, srv, "secret", "POST", "/admin/services/srv1/roles", RoleRequest{Name: "ADMIN"}, nil))
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, srv, "secret", "POST", "/admin/services/srv2/roles", RoleRequest{Name: "ADMIN"}, nil))
+
+	var permission model.AccessPermission
+	assert.Equal(t, http.StatusCreated, adminRequest(t, srv, "secret", "POST", "/admin/services/srv1/roles/ADMIN/permissions", AccessPermissionRequest{Action: "post", ResourcePattern: "admin/*"}, &permission))
+	assert.Equal(t, "POST", permission.Action)
+	assert.Equal(t, http.StatusBadRequest, adminRequest(t, srv, "secret", "POST", "/admin/services/srv1/roles/ADMIN/permissions", AccessPermissionRequest{Action: "GET", ResourcePattern: "admin/[*"}, nil))
+
+	var user model.User
+	assert.Equal(t, http.StatusCreated, adminRequest(t, srv, "secret", "POST", "/admin/users", UserRequest{Email: "admin@c-s.fr"}, &user))
+	assert.Empty(t, mustPermissions(t, cache, "admin@c-s.fr", "srv1"))
+	assert.Equal(t, http.StatusNoContent, adminRequest(t, srv, "secret", "PUT", "/admin/users/admin@c-s.fr/roles/srv1/ADMIN", nil, nil))
+	// The permissions of the user are invalidated in the cache
+	permissions := mustPermissions(t, cache, "admin@c-s.fr", "srv1")
+	require.Len(t, permissions, 1)
+	assert.True(t, permissions[0].allows("admin/users", "POST"))
+
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/users/admin@c-s.fr", nil, &user))
+	require.Len(t, user.Roles, 1)
+	assert.Equal(t, "ADMIN", user.Roles[0].Name)
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/services/srv1/roles/ADMIN", nil, &role))
+	require.Len(t, role.Users, 1)
+	require.Len(t, role.AccessPermissions, 1)
+
+	path := "/admin/services/srv1/roles/ADMIN/permissions/" + strconv.FormatUint(uint64(permission.ID), 10)
+	assert.Equal(t, http.StatusNoContent, adminRequest(t, srv, "secret", "DELETE", path, nil, nil))
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, srv, "secret", "DELETE", path, nil, nil))
+	assert.Empty(t, mustPermissions(t, cache, "admin@c-s.fr", "srv1"))
+
+	assert.Equal(t, http.StatusNoContent, adminRequest(t, srv, "secret", "DELETE", "/admin/services/srv1/roles/USER", nil, nil))
+	assert.Empty(t, mustPermissions(t, cache, "user@c-s.fr", "srv1"))
+	assert.Equal(t, http.StatusNoContent, adminRequest(t, srv, "secret", "DELETE", "/admin/users/admin@c-s.fr/roles/srv1/ADMIN", nil, nil))
+	assert.Equal(t, http.StatusNoContent, adminRequest(t, srv, "secret", "DELETE", "/admin/users/admin@c-s.fr", nil, nil))
+	var users []model.User
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/users", nil, &users))
+	require.Len(t, users, 1)
+	assert.Equal(t, "user@c-s.fr", users[0].Email)
+}
@@ -0,0 +1,246 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+//Decisions of the gateway recorded in the audit log
+const (
+	//AuditAllowed the request has been forwarded to the service
+	AuditAllowed = "allowed"
+	//AuditUnauthenticated the request has no valid token
+	AuditUnauthenticated = "unauthenticated"
+	//AuditDenied the user isn't allowed to access the resource
+	AuditDenied = "denied"
+	//AuditNoRoute the service is unknown
+	AuditNoRoute = "no_route"
+	//AuditRateLimited the rate limit or the daily quota of the user is exceeded
+	AuditRateLimited = "rate_limited"
+	//AuditUnavailable the security database can't be read
+	AuditUnavailable = "unavailable"
+)
+
+//AuditRecord is the audit record of a request received by the gateway
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Email      string    `json:"email,omitempty"`
+	Service    string    `json:"service"`
+	Resource   string    `json:"resource"`
+	Method     string    `json:"method"`
+	Decision   string    `json:"decision"`
+	//Status is the HTTP status responded by the service, or by the gateway if the request isn't forwarded
+	Status int `json:"status"`
+	//LatencyMS is the time spent to respond, in milliseconds
+	LatencyMS int64 `json:"latency_ms"`
+}
+
+//AuditFilter selects audit records
+type AuditFilter struct {
+	Email    string
+	Service  string
+	Decision string
+	Since    time.Time
+	Until    time.Time
+	//Limit is the maximum number of records returned, the most recent ones (no limit if <= 0)
+	Limit int
+}
+
+//Match returns true if the record is selected by the filter
+func (f AuditFilter) Match(r AuditRecord) bool {
+	return (f.Email == "" || r.Email == f.Email) &&
+		(f.Service == "" || r.Service == f.Service) &&
+		(f.Decision == "" || r.Decision == f.Decision) &&
+		(f.Since.IsZero() || !r.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || r.Time.Before(f.Until))
+}
+
+//AuditSink writes the audit records
+type AuditSink interface {
+	Write(record AuditRecord) error
+	Close() error
+}
+
+//AuditQuerier is implemented by the audit sinks able to read back the records
+type AuditQuerier interface {
+	Query(filter AuditFilter) ([]AuditRecord, error)
+}
+
+//NewAuditSink creates the audit sink of kind "file" (JSON lines written in the file at address), "syslog"
+//(local syslog, or remote syslog server at address if not empty) or "udp" (JSON datagrams sent to address, a
+//Logstash UDP input for example); no sink is returned if kind is empty
+func NewAuditSink(kind, address string) (AuditSink, error) {
+	var sink AuditSink
+	var err error
+	switch kind {
+	case "":
+		return nil, nil
+	case "file":
+		sink, err = newFileAuditSink(address)
+	case "syslog":
+		sink, err = newSyslogAuditSink(address)
+	case "udp":
+		sink, err = newUDPAuditSink(address)
+	default:
+		return nil, fmt.Errorf("unknown audit sink '%s'", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s audit sink: %v", kind, err)
+	}
+	return sink, nil
+}
+
+//fileAuditSink writes the audit records in a file, one JSON object by line
+type fileAuditSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func newFileAuditSink(path string) (*fileAuditSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("missing path of audit file")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileAuditSink{path: path, file: f}, nil
+}
+
+func (s *fileAuditSink) Write(record AuditRecord) error {
+	out, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(out, '\n'))
+	return err
+}
+
+func (s *fileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+//Query reads the file and returns the records selected by filter, from the oldest to the most recent
+func (s *fileAuditSink) Query(filter AuditFilter) ([]AuditRecord, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := []AuditRecord{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r AuditRecord
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			continue
+		}
+		if !filter.Match(r) {
+			continue
+		}
+		records = append(records, r)
+		if filter.Limit > 0 && len(records) > 2*filter.Limit {
+			records = append([]AuditRecord{}, records[len(records)-filter.Limit:]...)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records, nil
+}
+
+//syslogAuditSink sends the audit records in JSON to syslog
+type syslogAuditSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogAuditSink(address string) (*syslogAuditSink, error) {
+	network := ""
+	if address != "" {
+		network = "udp"
+	}
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_AUTH, "safe-securityd")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogAuditSink{writer: w}, nil
+}
+
+func (s *syslogAuditSink) Write(record AuditRecord) error {
+	out, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.writer.Info(string(out))
+}
+
+func (s *syslogAuditSink) Close() error {
+	return s.writer.Close()
+}
+
+//udpAuditSink sends each audit record in a JSON datagram
+type udpAuditSink struct {
+	conn net.Conn
+}
+
+func newUDPAuditSink(address string) (*udpAuditSink, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &udpAuditSink{conn: conn}, nil
+}
+
+func (s *udpAuditSink) Write(record AuditRecord) error {
+	out, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Write(append(out, '\n'))
+	return err
+}
+
+func (s *udpAuditSink) Close() error {
+	return s.conn.Close()
+}
+
+//statusRecorder keeps the status responded to a request
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
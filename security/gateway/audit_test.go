@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAuditSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safe-security")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	sink, err := NewAuditSink("file", filepath.Join(dir, "audit.log"))
+	require.Nil(t, err)
+	defer sink.Close()
+	start := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		record := AuditRecord{
+			Time:     start.Add(time.Duration(i) * time.Minute),
+			Email:    "user@c-s.fr",
+			Service:  "srv1",
+			Resource: "data",
+			Method:   "GET",
+			Decision: AuditAllowed,
+			Status:   http.StatusOK,
+		}
+		if i%2 == 1 {
+			record.Email = "other@c-s.fr"
+			record.Decision = AuditDenied
+			record.Status = http.StatusUnauthorized
+		}
+		require.Nil(t, sink.Write(record))
+	}
+
+	querier, ok := sink.(AuditQuerier)
+	require.True(t, ok)
+	records, err := querier.Query(AuditFilter{})
+	require.Nil(t, err)
+	assert.Len(t, records, 10)
+
+	records, err = querier.Query(AuditFilter{Email: "other@c-s.fr", Limit: 2})
+	require.Nil(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, start.Add(7*time.Minute), records[0].Time.UTC())
+	assert.Equal(t, start.Add(9*time.Minute), records[1].Time.UTC())
+	assert.Equal(t, AuditDenied, records[1].Decision)
+
+	records, err = querier.Query(AuditFilter{Decision: AuditAllowed, Since: start.Add(2 * time.Minute), Until: start.Add(6 * time.Minute)})
+	require.Nil(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, start.Add(2*time.Minute), records[0].Time.UTC())
+	assert.Equal(t, start.Add(4*time.Minute), records[1].Time.UTC())
+}
+
+func TestUDPAuditSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	sink, err := NewAuditSink("udp", conn.LocalAddr().String())
+	require.Nil(t, err)
+	defer sink.Close()
+	_, ok := sink.(AuditQuerier)
+	assert.False(t, ok)
+	require.Nil(t, sink.Write(AuditRecord{Service: "srv1", Decision: AuditNoRoute}))
+
+	buf := make([]byte, 1024)
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.Nil(t, err)
+	assert.Contains(t, string(buf[:n]), `"decision":"no_route"`)
+
+	_, err = NewAuditSink("kafka", "")
+	assert.NotNil(t, err)
+	sink, err = NewAuditSink("", "")
+	assert.Nil(t, err)
+	assert.Nil(t, sink)
+}
+
+func TestAdminAPI_Audit(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	api := newAdminAPI(da, newPermissionCache(da, time.Minute), "secret", nil, nil)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	assert.Equal(t, http.StatusNotImplemented, adminRequest(t, srv, "secret", "GET", "/admin/audit", nil, nil))
+
+	dir, err := ioutil.TempDir("", "safe-security")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	api.audit, err = NewAuditSink("file", filepath.Join(dir, "audit.log"))
+	require.Nil(t, err)
+	defer api.audit.Close()
+	now := time.Now()
+	require.Nil(t, api.audit.Write(AuditRecord{Time: now.Add(-2 * time.Hour), Email: "user@c-s.fr", Service: "srv1", Decision: AuditAllowed}))
+	require.Nil(t, api.audit.Write(AuditRecord{Time: now, Email: "user@c-s.fr", Service: "srv2", Decision: AuditAllowed}))
+
+	var records []AuditRecord
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/audit?email=user@c-s.fr", nil, &records))
+	assert.Len(t, records, 2)
+	since := now.Add(-time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/audit?since="+since, nil, &records))
+	require.Len(t, records, 1)
+	assert.Equal(t, "srv2", records[0].Service)
+	assert.Equal(t, http.StatusBadRequest, adminRequest(t, srv, "secret", "GET", "/admin/audit?since=yesterday", nil, nil))
+}
@@ -0,0 +1,212 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+//maxCacheEntries is the size of a cache map from which expired entries are purged
+const maxCacheEntries = 10000
+
+type serviceEntry struct {
+	service *model.Service //nil if the service doesn't exist
+	expires time.Time
+}
+
+type compiledPermission struct {
+	resource glob.Glob
+	action   string
+}
+
+//allows returns true if the permission allows method on resource
+func (p compiledPermission) allows(resource, method string) bool {
+	return p.resource.Match(resource) && (p.action == method || p.action == "ALL")
+}
+
+type permissionKey struct {
+	email   string
+	service string
+}
+
+type permissionsEntry struct {
+	permissions []compiledPermission
+	expires     time.Time
+}
+
+type rateLimitEntry struct {
+	limit   *model.RateLimit //nil if no rate limit applies
+	expires time.Time
+}
+
+//permissionCache caches for ttl the services, the compiled access permissions and the rate limits of the users
+//read from the database
+type permissionCache struct {
+	da  *model.DataAccess
+	ttl time.Duration
+
+	mu          sync.RWMutex
+	services    map[string]serviceEntry
+	permissions map[permissionKey]permissionsEntry
+	rateLimits  map[permissionKey]rateLimitEntry
+}
+
+//newPermissionCache creates a permission cache reading da; entries are not cached if ttl <= 0
+func newPermissionCache(da *model.DataAccess, ttl time.Duration) *permissionCache {
+	return &permissionCache{
+		da:          da,
+		ttl:         ttl,
+		services:    map[string]serviceEntry{},
+		permissions: map[permissionKey]permissionsEntry{},
+		rateLimits:  map[permissionKey]rateLimitEntry{},
+	}
+}
+
+//getService returns the service named name, nil if it doesn't exist
+//The errors of the database are returned and not cached
+func (c *permissionCache) getService(name string) (*model.Service, error) {
+	now := time.Now()
+	c.mu.RLock()
+	e, ok := c.services[name]
+	c.mu.RUnlock()
+	if ok && now.Before(e.expires) {
+		return e.service, nil
+	}
+
+	srv, err := c.da.GetServiceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if c.ttl <= 0 {
+		return srv, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.services) >= maxCacheEntries {
+		for k, v := range c.services {
+			if !now.Before(v.expires) {
+				delete(c.services, k)
+			}
+		}
+	}
+	c.services[name] = serviceEntry{service: srv, expires: now.Add(c.ttl)}
+	return srv, nil
+}
+
+//getPermissions returns the compiled access permissions of the user identified by email towards the service
+//Permissions with an invalid resource pattern are ignored; the errors of the database are returned and not cached
+func (c *permissionCache) getPermissions(email, service string) ([]compiledPermission, error) {
+	now := time.Now()
+	key := permissionKey{email: email, service: service}
+	c.mu.RLock()
+	e, ok := c.permissions[key]
+	c.mu.RUnlock()
+	if ok && now.Before(e.expires) {
+		return e.permissions, nil
+	}
+
+	permissions, err := c.da.GetUserAccessPermissionsByService(email, service)
+	if err != nil {
+		return nil, err
+	}
+	compiled := []compiledPermission{}
+	for _, permission := range permissions {
+		g, err := glob.Compile(permission.ResourcePattern)
+		if err != nil {
+			continue
+		}
+		compiled = append(compiled, compiledPermission{resource: g, action: permission.Action})
+	}
+	if c.ttl <= 0 {
+		return compiled, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.permissions) >= maxCacheEntries {
+		for k, v := range c.permissions {
+			if !now.Before(v.expires) {
+				delete(c.permissions, k)
+			}
+		}
+	}
+	c.permissions[key] = permissionsEntry{permissions: compiled, expires: now.Add(c.ttl)}
+	return compiled, nil
+}
+
+//getRateLimit returns the rate limit of the user identified by email towards the service, nil if none applies
+//The errors of the database are returned and not cached
+func (c *permissionCache) getRateLimit(email, service string) (*model.RateLimit, error) {
+	now := time.Now()
+	key := permissionKey{email: email, service: service}
+	c.mu.RLock()
+	e, ok := c.rateLimits[key]
+	c.mu.RUnlock()
+	if ok && now.Before(e.expires) {
+		return e.limit, nil
+	}
+
+	limit, err := c.da.GetRateLimit(email, service)
+	if err != nil {
+		return nil, err
+	}
+	if c.ttl <= 0 {
+		return limit, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.rateLimits) >= maxCacheEntries {
+		for k, v := range c.rateLimits {
+			if !now.Before(v.expires) {
+				delete(c.rateLimits, k)
+			}
+		}
+	}
+	c.rateLimits[key] = rateLimitEntry{limit: limit, expires: now.Add(c.ttl)}
+	return limit, nil
+}
+
+//invalidateService removes from the cache the service named name, and the permissions and rate limits of the
+//users towards it
+func (c *permissionCache) invalidateService(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.services, name)
+	for k := range c.permissions {
+		if k.service == name {
+			delete(c.permissions, k)
+		}
+	}
+	for k := range c.rateLimits {
+		if k.service == name {
+			delete(c.rateLimits, k)
+		}
+	}
+}
+
+//invalidateUser removes from the cache the permissions and the rate limits of the user identified by email
+func (c *permissionCache) invalidateUser(email string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.permissions {
+		if k.email == email {
+			delete(c.permissions, k)
+		}
+	}
+	for k := range c.rateLimits {
+		if k.email == email {
+			delete(c.rateLimits, k)
+		}
+	}
+}
+
+//invalidateAll empties the cache, to use when a change impacts several users or services (a role for example)
+func (c *permissionCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services = map[string]serviceEntry{}
+	c.permissions = map[permissionKey]permissionsEntry{}
+	c.rateLimits = map[permissionKey]rateLimitEntry{}
+}
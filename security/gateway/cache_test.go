@@ -0,0 +1,182 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+func newTestDataAccess(t *testing.T) (*model.DataAccess, func()) {
+	dir, err := ioutil.TempDir("", "safe-security")
+	require.Nil(t, err)
+	da := model.NewDataAccess("sqlite3", filepath.Join(dir, "security.db"))
+	db, err := da.Pool()
+	require.Nil(t, err)
+	require.Nil(t, da.Migrate())
+
+	srv := model.Service{Name: "srv1", BaseURL: "http://srv1"}
+	require.Nil(t, db.Create(&srv).Error)
+	usr := model.User{Email: "user@c-s.fr"}
+	require.Nil(t, db.Create(&usr).Error)
+	perm1 := model.AccessPermission{Action: "GET", ResourcePattern: "public/*"}
+	require.Nil(t, db.Create(&perm1).Error)
+	perm2 := model.AccessPermission{Action: "ALL", ResourcePattern: "user/*"}
+	require.Nil(t, db.Create(&perm2).Error)
+	role := model.Role{Name: "USER"}
+	require.Nil(t, db.Create(&role).Error)
+	require.Nil(t, db.Model(&role).Association("AccessPermissions").Append(perm1, perm2).Error)
+	require.Nil(t, db.Model(&srv).Association("Roles").Append(role).Error)
+	require.Nil(t, db.Model(&usr).Association("Roles").Append(role).Error)
+
+	return da, func() {
+		da.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+//mustService returns the service named name read through the cache, failing the test on error
+func mustService(t *testing.T, cache *permissionCache, name string) *model.Service {
+	srv, err := cache.getService(name)
+	require.Nil(t, err)
+	return srv
+}
+
+//mustPermissions returns the permissions of the user read through the cache, failing the test on error
+func mustPermissions(t *testing.T, cache *permissionCache, email, service string) []compiledPermission {
+	permissions, err := cache.getPermissions(email, service)
+	require.Nil(t, err)
+	return permissions
+}
+
+func TestPermissionCache_Allows(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	cache := newPermissionCache(da, time.Minute)
+
+	require.NotNil(t, mustService(t, cache, "srv1"))
+	assert.Equal(t, "http://srv1", mustService(t, cache, "srv1").BaseURL)
+	assert.Nil(t, mustService(t, cache, "srv2"))
+
+	permissions := mustPermissions(t, cache, "user@c-s.fr", "srv1")
+	require.Len(t, permissions, 2)
+	allows := func(resource, method string) bool {
+		for _, p := range permissions {
+			if p.allows(resource, method) {
+				return true
+			}
+		}
+		return false
+	}
+	assert.True(t, allows("public/doc", "GET"))
+	assert.False(t, allows("public/doc", "POST"))
+	assert.True(t, allows("user/data", "DELETE"))
+	// ALL only applies to the resources matching the pattern
+	assert.False(t, allows("admin/data", "GET"))
+	assert.Empty(t, mustPermissions(t, cache, "other@c-s.fr", "srv1"))
+}
+
+func TestPermissionCache_Invalidate(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	cache := newPermissionCache(da, time.Minute)
+	db, err := da.Pool()
+	require.Nil(t, err)
+
+	assert.Nil(t, mustService(t, cache, "srv2"))
+	require.Nil(t, db.Create(&model.Service{Name: "srv2", BaseURL: "http://srv2"}).Error)
+	// The missing service is cached until invalidation
+	assert.Nil(t, mustService(t, cache, "srv2"))
+	cache.invalidateService("srv2")
+	assert.NotNil(t, mustService(t, cache, "srv2"))
+
+	require.Len(t, mustPermissions(t, cache, "user@c-s.fr", "srv1"), 2)
+	require.Nil(t, db.Where("action = ?", "ALL").Delete(model.AccessPermission{}).Error)
+	assert.Len(t, mustPermissions(t, cache, "user@c-s.fr", "srv1"), 2)
+	cache.invalidateUser("user@c-s.fr")
+	assert.Len(t, mustPermissions(t, cache, "user@c-s.fr", "srv1"), 1)
+
+	assert.Equal(t, "http://srv1", mustService(t, cache, "srv1").BaseURL)
+	require.Nil(t, db.Model(&model.Service{}).Where("name = ?", "srv1").Update("base_url", "http://srv1bis").Error)
+	assert.Equal(t, "http://srv1", mustService(t, cache, "srv1").BaseURL)
+	cache.invalidateAll()
+	assert.Equal(t, "http://srv1bis", mustService(t, cache, "srv1").BaseURL)
+}
+
+func TestPermissionCache_Expiration(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	cache := newPermissionCache(da, 10*time.Millisecond)
+	db, err := da.Pool()
+	require.Nil(t, err)
+
+	assert.Nil(t, mustService(t, cache, "srv2"))
+	require.Nil(t, db.Create(&model.Service{Name: "srv2", BaseURL: "http://srv2"}).Error)
+	time.Sleep(20 * time.Millisecond)
+	assert.NotNil(t, mustService(t, cache, "srv2"))
+}
+
+func TestPermissionCache_Errors(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	cache := newPermissionCache(da, time.Minute)
+	db, err := da.Pool()
+	require.Nil(t, err)
+
+	// The errors of the database are returned, not cached as a missing service or as no permission
+	require.Nil(t, db.DropTable(&model.Service{}).Error)
+	_, err = cache.getService("srv1")
+	assert.NotNil(t, err)
+	_, err = cache.getPermissions("user@c-s.fr", "srv1")
+	assert.NotNil(t, err)
+	require.Nil(t, db.AutoMigrate(&model.Service{}).Error)
+	require.Nil(t, db.Create(&model.Service{Name: "srv1", BaseURL: "http://srv1"}).Error)
+	assert.NotNil(t, mustService(t, cache, "srv1"))
+
+	require.Nil(t, db.DropTable(&model.User{}).Error)
+	_, err = cache.getPermissions("user@c-s.fr", "srv1")
+	assert.NotNil(t, err)
+	require.Nil(t, db.AutoMigrate(&model.User{}).Error)
+	assert.Empty(t, mustPermissions(t, cache, "user@c-s.fr", "srv1"))
+}
+
+func TestAuthorize(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	cache = newPermissionCache(da, time.Minute)
+	defer func() { cache = nil }()
+
+	cases := []struct {
+		email, service, resource, method string
+		allowed                          bool
+	}{
+		{"user@c-s.fr", "srv1", "public/doc", "GET", true},
+		{"user@c-s.fr", "srv1", "public/doc", "POST", false},
+		{"user@c-s.fr", "srv1", "user/data", "DELETE", true},
+		// ALL doesn't allow the resources not matching the pattern of the permission (it allowed any resource
+		// before, whatever the pattern)
+		{"user@c-s.fr", "srv1", "admin/data", "GET", false},
+		{"user@c-s.fr", "srv1", "admin/data", "ALL", false},
+		{"user@c-s.fr", "srv2", "user/data", "GET", false},
+		{"other@c-s.fr", "srv1", "public/doc", "GET", false},
+	}
+	for _, c := range cases {
+		allowed, err := authorize(c.email, c.service, c.resource, c.method)
+		require.Nil(t, err)
+		assert.Equal(t, c.allowed, allowed, "%+v", c)
+	}
+
+	// The user is neither allowed nor denied when the database fails
+	db, err := da.Pool()
+	require.Nil(t, err)
+	require.Nil(t, db.DropTable(&model.User{}).Error)
+	cache.invalidateAll()
+	_, err = authorize("user@c-s.fr", "srv1", "public/doc", "GET")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/CS-SI/SafeScale/security/gateway"
+	clitools "github.com/CS-SI/SafeScale/utils"
+)
+
+//safe-security audit --user user@c-s.fr --service my_service --since 24h --limit 50
+
+//AuditCmd queries the audit log of the gateway
+var AuditCmd = cli.Command{
+	Name:  "audit",
+	Usage: "Query the audit log of the requests received by the gateway (needs a file audit sink)",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "user",
+			Usage: "Select the requests of the user with email `EMAIL`",
+		},
+		cli.StringFlag{
+			Name:  "service",
+			Usage: "Select the requests to the service `NAME`",
+		},
+		cli.StringFlag{
+			Name:  "decision",
+			Usage: "Select the requests with decision `DECISION` (allowed, unauthenticated, denied, rate_limited, no_route or unavailable)",
+		},
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "Select the requests received since `TIME` (RFC3339 time, or duration before now like 2h)",
+		},
+		cli.StringFlag{
+			Name:  "until",
+			Usage: "Select the requests received before `TIME` (RFC3339 time, or duration before now like 2h)",
+		},
+		cli.IntFlag{
+			Name:  "limit",
+			Value: 100,
+			Usage: "Return at most the `COUNT` most recent requests (0 for no limit)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		query := url.Values{}
+		for _, f := range []string{"service", "decision"} {
+			if v := c.String(f); v != "" {
+				query.Set(f, v)
+			}
+		}
+		if v := c.String("user"); v != "" {
+			query.Set("email", v)
+		}
+		for _, f := range []string{"since", "until"} {
+			if v := c.String(f); v != "" {
+				t, err := parseTime(v)
+				if err != nil {
+					return clitools.ExitOnInvalidOption(fmt.Sprintf("Invalid option --%s: %v", f, err))
+				}
+				query.Set(f, t.Format(time.RFC3339))
+			}
+		}
+		query.Set("limit", strconv.Itoa(c.Int("limit")))
+
+		var records []gateway.AuditRecord
+		err := newAdminClient(c).doWithQuery("GET", query, nil, &records, "audit")
+		return output(records, err, "query audit log")
+	},
+}
+
+//parseTime parses a RFC3339 time, or a duration before now
+func parseTime(v string) (time.Time, error) {
+	d, err := time.ParseDuration(v)
+	if err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither a RFC3339 time nor a duration", v)
+	}
+	return t, nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/CS-SI/SafeScale/security/gateway"
+	clitools "github.com/CS-SI/SafeScale/utils"
+	"github.com/CS-SI/SafeScale/utils/enums/ExitCode"
+)
+
+//Environment variables giving the default values of the global flags
+const (
+	GatewayEnvVar = "SAFESCALE_SECURITY_GATEWAY"
+	TokenEnvVar   = "SAFESCALE_SECURITY_TOKEN"
+)
+
+//GlobalFlags are the flags used to reach the administration API of the gateway
+var GlobalFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "gateway, g",
+		Usage:  "Administrate the security gateway at `URL`",
+		Value:  "https://localhost:443",
+		EnvVar: GatewayEnvVar,
+	},
+	cli.StringFlag{
+		Name:   "token",
+		Usage:  "Authenticate with `TOKEN` (admin token of the gateway or OpenID token of an administrator)",
+		EnvVar: TokenEnvVar,
+	},
+	cli.BoolFlag{
+		Name:  "insecure, k",
+		Usage: "Don't verify the certificate of the gateway",
+	},
+}
+
+//apiError is an error responded by the administration API
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e apiError) Error() string {
+	return e.message
+}
+
+//adminClient calls the administration API of the gateway
+type adminClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newAdminClient(c *cli.Context) *adminClient {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: c.GlobalBool("insecure")},
+	}
+	return &adminClient{
+		baseURL: strings.TrimSuffix(c.GlobalString("gateway"), "/"),
+		token:   c.GlobalString("token"),
+		client:  &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+//do sends the request to the route made of the path elements, with in encoded in JSON as body, and decodes the
+//response in out if not nil
+func (ac *adminClient) do(method string, in interface{}, out interface{}, path ...string) error {
+	return ac.doWithQuery(method, nil, in, out, path...)
+}
+
+//doWithQuery does the same as do, adding the query parameters to the route
+func (ac *adminClient) doWithQuery(method string, query url.Values, in interface{}, out interface{}, path ...string) error {
+	elements := []string{}
+	for _, p := range path {
+		elements = append(elements, url.PathEscape(p))
+	}
+	var body bytes.Buffer
+	if in != nil {
+		err := json.NewEncoder(&body).Encode(in)
+		if err != nil {
+			return err
+		}
+	}
+	route := ac.baseURL + "/admin/" + strings.Join(elements, "/")
+	if len(query) > 0 {
+		route += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, route, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if ac.token != "" {
+		req.Header.Set("Authorization", "Bearer "+ac.token)
+	}
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var e gateway.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return apiError{status: resp.StatusCode, message: e.Error}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+//checkArgs checks the command has been called with the arguments described by usage
+func checkArgs(c *cli.Context, usage ...string) error {
+	if c.NArg() != len(usage) {
+		fmt.Fprintf(os.Stderr, "Missing mandatory argument %s\n", strings.Join(usage, " "))
+		_ = cli.ShowSubcommandHelp(c)
+		return clitools.ExitOnInvalidArgument()
+	}
+	return nil
+}
+
+//output prints the result of a command in JSON, or the error with the corresponding exit code
+func output(result interface{}, err error, action string) error {
+	if err != nil {
+		msg := fmt.Sprintf("Failed to %s: %v", action, err)
+		if e, ok := err.(apiError); ok {
+			switch e.status {
+			case http.StatusNotFound:
+				return clitools.ExitOnErrorWithMessage(ExitCode.NotFound, msg)
+			case http.StatusConflict:
+				return clitools.ExitOnErrorWithMessage(ExitCode.Duplicate, msg)
+			case http.StatusBadRequest:
+				return clitools.ExitOnErrorWithMessage(ExitCode.InvalidArgument, msg)
+			}
+		}
+		return clitools.ExitOnRPC(msg)
+	}
+	if result != nil {
+		out, _ := json.Marshal(result)
+		fmt.Println(string(out))
+	}
+	return nil
+}
@@ -1,3 +1,17 @@
 package cmd
 
+import (
+	"github.com/urfave/cli"
+)
+
 //safe-security init
+
+//InitCmd initializes the database of the gateway
+var InitCmd = cli.Command{
+	Name:  "init",
+	Usage: "Create the missing tables in the database of the gateway, keeping its content",
+	Action: func(c *cli.Context) error {
+		err := newAdminClient(c).do("POST", nil, nil, "init")
+		return output(nil, err, "initialize the database")
+	},
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"math"
+
+	"github.com/urfave/cli"
+
+	"github.com/CS-SI/SafeScale/security/gateway"
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+//safe-security limit set my_service user@c-s.fr --rate 10 --burst 20 --quota 10000
+//safe-security limit set my_service '*' --rate 5
+//safe-security limit remove my_service user@c-s.fr
+//safe-security limit list my_service
+
+//LimitCmd rate limit command
+var LimitCmd = cli.Command{
+	Name:  "limit",
+	Usage: "limit COMMAND",
+	Subcommands: []cli.Command{
+		limitSet,
+		limitRemove,
+		limitList,
+	},
+}
+
+var limitSet = cli.Command{
+	Name:      "set",
+	Usage:     "Set the rate limit and the daily quota of a user ('*' for any user without its own limit) towards a service",
+	ArgsUsage: "<service_name> <user_email|*>",
+	Flags: []cli.Flag{
+		cli.Float64Flag{
+			Name:  "rate",
+			Usage: "Allow `COUNT` requests by second (0 for no rate limit), counted by each instance of the gateway",
+		},
+		cli.IntFlag{
+			Name:  "burst",
+			Usage: "Allow `COUNT` requests at once (defaults to the rate)",
+		},
+		cli.IntFlag{
+			Name:  "quota",
+			Usage: "Allow `COUNT` requests by day (UTC), counted by all the instances of the gateway (0 for no daily quota)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<user_email|*>"); err != nil {
+			return err
+		}
+		req := gateway.RateLimitRequest{
+			RequestsPerSecond: c.Float64("rate"),
+			Burst:             c.Int("burst"),
+			DailyQuota:        c.Int("quota"),
+		}
+		if req.Burst == 0 && req.RequestsPerSecond > 0 {
+			req.Burst = int(math.Ceil(req.RequestsPerSecond))
+		}
+		var limit model.RateLimit
+		err := newAdminClient(c).do("PUT", req, &limit, "services", c.Args().Get(0), "limits", c.Args().Get(1))
+		return output(limit, err, "set rate limit")
+	},
+}
+
+var limitRemove = cli.Command{
+	Name:      "remove",
+	Aliases:   []string{"rm", "delete"},
+	Usage:     "Remove the rate limit of a user ('*' for any user) towards a service",
+	ArgsUsage: "<service_name> <user_email|*>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<user_email|*>"); err != nil {
+			return err
+		}
+		err := newAdminClient(c).do("DELETE", nil, nil, "services", c.Args().Get(0), "limits", c.Args().Get(1))
+		return output(nil, err, "remove rate limit")
+	},
+}
+
+var limitList = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "List the rate limits of a service",
+	ArgsUsage: "<service_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>"); err != nil {
+			return err
+		}
+		var limits []model.RateLimit
+		err := newAdminClient(c).do("GET", nil, &limits, "services", c.Args().First(), "limits")
+		return output(limits, err, "list rate limits")
+	},
+}
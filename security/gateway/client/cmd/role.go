@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"github.com/urfave/cli"
+
+	"github.com/CS-SI/SafeScale/security/gateway"
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+//safe-security role add my_service my_role
+//safe-security role remove my_service my_role
+//safe-security role inspect my_service my_role
+//safe-security role list my_service
+//safe-security role permission add my_service my_role GET "api/*"
+//safe-security role permission remove my_service my_role 3
+//safe-security role permission list my_service my_role
+
+//RoleCmd role command
+var RoleCmd = cli.Command{
+	Name:  "role",
+	Usage: "role COMMAND",
+	Subcommands: []cli.Command{
+		roleAdd,
+		roleRemove,
+		roleInspect,
+		roleList,
+		rolePermission,
+	},
+}
+
+var roleAdd = cli.Command{
+	Name:      "add",
+	Aliases:   []string{"create"},
+	Usage:     "Add a role to a service",
+	ArgsUsage: "<service_name> <role_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<role_name>"); err != nil {
+			return err
+		}
+		var role model.Role
+		err := newAdminClient(c).do("POST", gateway.RoleRequest{Name: c.Args().Get(1)}, &role, "services", c.Args().Get(0), "roles")
+		return output(role, err, "add role")
+	},
+}
+
+var roleRemove = cli.Command{
+	Name:      "remove",
+	Aliases:   []string{"rm", "delete"},
+	Usage:     "Remove a role of a service, with its access permissions",
+	ArgsUsage: "<service_name> <role_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<role_name>"); err != nil {
+			return err
+		}
+		err := newAdminClient(c).do("DELETE", nil, nil, "services", c.Args().Get(0), "roles", c.Args().Get(1))
+		return output(nil, err, "remove role")
+	},
+}
+
+var roleInspect = cli.Command{
+	Name:      "inspect",
+	Aliases:   []string{"show"},
+	Usage:     "Inspect a role of a service, with its access permissions and its users",
+	ArgsUsage: "<service_name> <role_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<role_name>"); err != nil {
+			return err
+		}
+		var role model.Role
+		err := newAdminClient(c).do("GET", nil, &role, "services", c.Args().Get(0), "roles", c.Args().Get(1))
+		return output(role, err, "inspect role")
+	},
+}
+
+var roleList = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "List the roles of a service",
+	ArgsUsage: "<service_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>"); err != nil {
+			return err
+		}
+		var roles []model.Role
+		err := newAdminClient(c).do("GET", nil, &roles, "services", c.Args().First(), "roles")
+		return output(roles, err, "list roles")
+	},
+}
+
+var rolePermission = cli.Command{
+	Name:    "permission",
+	Aliases: []string{"perm"},
+	Usage:   "permission COMMAND",
+	Subcommands: []cli.Command{
+		permissionAdd,
+		permissionRemove,
+		permissionList,
+	},
+}
+
+var permissionAdd = cli.Command{
+	Name:      "add",
+	Aliases:   []string{"create"},
+	Usage:     "Allow a role to do an action (HTTP method, or ALL) on the resources of the service matching a pattern",
+	ArgsUsage: "<service_name> <role_name> <action> <resource_pattern>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<role_name>", "<action>", "<resource_pattern>"); err != nil {
+			return err
+		}
+		req := gateway.AccessPermissionRequest{Action: c.Args().Get(2), ResourcePattern: c.Args().Get(3)}
+		var permission model.AccessPermission
+		err := newAdminClient(c).do("POST", req, &permission, "services", c.Args().Get(0), "roles", c.Args().Get(1), "permissions")
+		return output(permission, err, "add access permission")
+	},
+}
+
+var permissionRemove = cli.Command{
+	Name:      "remove",
+	Aliases:   []string{"rm", "delete"},
+	Usage:     "Remove an access permission of a role",
+	ArgsUsage: "<service_name> <role_name> <permission_ID>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<role_name>", "<permission_ID>"); err != nil {
+			return err
+		}
+		err := newAdminClient(c).do("DELETE", nil, nil, "services", c.Args().Get(0), "roles", c.Args().Get(1), "permissions", c.Args().Get(2))
+		return output(nil, err, "remove access permission")
+	},
+}
+
+var permissionList = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "List the access permissions of a role",
+	ArgsUsage: "<service_name> <role_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<role_name>"); err != nil {
+			return err
+		}
+		var permissions []model.AccessPermission
+		err := newAdminClient(c).do("GET", nil, &permissions, "services", c.Args().Get(0), "roles", c.Args().Get(1), "permissions")
+		return output(permissions, err, "list access permissions")
+	},
+}
@@ -1,6 +1,98 @@
 package cmd
 
+import (
+	"github.com/urfave/cli"
+
+	"github.com/CS-SI/SafeScale/security/gateway"
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
 //safe-security service add my_service http://192.168.0.2:8888
+//safe-security service update my_service http://192.168.0.3:8888
 //safe-security service remove my_service
 //safe-security service inspect my_service
 //safe-security service list
+
+//ServiceCmd service command
+var ServiceCmd = cli.Command{
+	Name:  "service",
+	Usage: "service COMMAND",
+	Subcommands: []cli.Command{
+		serviceAdd,
+		serviceUpdate,
+		serviceRemove,
+		serviceInspect,
+		serviceList,
+	},
+}
+
+var serviceAdd = cli.Command{
+	Name:      "add",
+	Aliases:   []string{"create"},
+	Usage:     "Protect a service with the gateway",
+	ArgsUsage: "<service_name> <service_URL>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<service_URL>"); err != nil {
+			return err
+		}
+		req := gateway.ServiceRequest{Name: c.Args().Get(0), BaseURL: c.Args().Get(1)}
+		var srv model.Service
+		err := newAdminClient(c).do("POST", req, &srv, "services")
+		return output(srv, err, "add service")
+	},
+}
+
+var serviceUpdate = cli.Command{
+	Name:      "update",
+	Usage:     "Change the URL of a service",
+	ArgsUsage: "<service_name> <service_URL>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>", "<service_URL>"); err != nil {
+			return err
+		}
+		req := gateway.ServiceRequest{BaseURL: c.Args().Get(1)}
+		var srv model.Service
+		err := newAdminClient(c).do("PUT", req, &srv, "services", c.Args().Get(0))
+		return output(srv, err, "update service")
+	},
+}
+
+var serviceRemove = cli.Command{
+	Name:      "remove",
+	Aliases:   []string{"rm", "delete"},
+	Usage:     "Remove a service with its roles",
+	ArgsUsage: "<service_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>"); err != nil {
+			return err
+		}
+		err := newAdminClient(c).do("DELETE", nil, nil, "services", c.Args().First())
+		return output(nil, err, "remove service")
+	},
+}
+
+var serviceInspect = cli.Command{
+	Name:      "inspect",
+	Aliases:   []string{"show"},
+	Usage:     "Inspect a service, with its roles and their access permissions",
+	ArgsUsage: "<service_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<service_name>"); err != nil {
+			return err
+		}
+		var srv model.Service
+		err := newAdminClient(c).do("GET", nil, &srv, "services", c.Args().First())
+		return output(srv, err, "inspect service")
+	},
+}
+
+var serviceList = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List the services protected by the gateway",
+	Action: func(c *cli.Context) error {
+		var services []model.Service
+		err := newAdminClient(c).do("GET", nil, &services, "services")
+		return output(services, err, "list services")
+	},
+}
@@ -1,3 +1,110 @@
 package cmd
 
-//safe-security user add
+import (
+	"github.com/urfave/cli"
+
+	"github.com/CS-SI/SafeScale/security/gateway"
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+//safe-security user add user@c-s.fr
+//safe-security user remove user@c-s.fr
+//safe-security user inspect user@c-s.fr
+//safe-security user list
+//safe-security user grant user@c-s.fr my_service my_role
+//safe-security user revoke user@c-s.fr my_service my_role
+
+//UserCmd user command
+var UserCmd = cli.Command{
+	Name:  "user",
+	Usage: "user COMMAND",
+	Subcommands: []cli.Command{
+		userAdd,
+		userRemove,
+		userInspect,
+		userList,
+		userGrant,
+		userRevoke,
+	},
+}
+
+var userAdd = cli.Command{
+	Name:      "add",
+	Aliases:   []string{"create"},
+	Usage:     "Add a user, identified by the email of its OpenID account",
+	ArgsUsage: "<user_email>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<user_email>"); err != nil {
+			return err
+		}
+		var user model.User
+		err := newAdminClient(c).do("POST", gateway.UserRequest{Email: c.Args().First()}, &user, "users")
+		return output(user, err, "add user")
+	},
+}
+
+var userRemove = cli.Command{
+	Name:      "remove",
+	Aliases:   []string{"rm", "delete"},
+	Usage:     "Remove a user",
+	ArgsUsage: "<user_email>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<user_email>"); err != nil {
+			return err
+		}
+		err := newAdminClient(c).do("DELETE", nil, nil, "users", c.Args().First())
+		return output(nil, err, "remove user")
+	},
+}
+
+var userInspect = cli.Command{
+	Name:      "inspect",
+	Aliases:   []string{"show"},
+	Usage:     "Inspect a user, with its roles",
+	ArgsUsage: "<user_email>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<user_email>"); err != nil {
+			return err
+		}
+		var user model.User
+		err := newAdminClient(c).do("GET", nil, &user, "users", c.Args().First())
+		return output(user, err, "inspect user")
+	},
+}
+
+var userList = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List the users",
+	Action: func(c *cli.Context) error {
+		var users []model.User
+		err := newAdminClient(c).do("GET", nil, &users, "users")
+		return output(users, err, "list users")
+	},
+}
+
+var userGrant = cli.Command{
+	Name:      "grant",
+	Usage:     "Grant a role of a service to a user",
+	ArgsUsage: "<user_email> <service_name> <role_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<user_email>", "<service_name>", "<role_name>"); err != nil {
+			return err
+		}
+		err := newAdminClient(c).do("PUT", nil, nil, "users", c.Args().Get(0), "roles", c.Args().Get(1), c.Args().Get(2))
+		return output(nil, err, "grant role")
+	},
+}
+
+var userRevoke = cli.Command{
+	Name:      "revoke",
+	Usage:     "Revoke a role of a service from a user",
+	ArgsUsage: "<user_email> <service_name> <role_name>",
+	Action: func(c *cli.Context) error {
+		if err := checkArgs(c, "<user_email>", "<service_name>", "<role_name>"); err != nil {
+			return err
+		}
+		err := newAdminClient(c).do("DELETE", nil, nil, "users", c.Args().Get(0), "roles", c.Args().Get(1), c.Args().Get(2))
+		return output(nil, err, "revoke role")
+	},
+}
@@ -1,5 +1,39 @@
 package main
 
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/urfave/cli"
+
+	"github.com/CS-SI/SafeScale/security/gateway/client/cmd"
+)
+
 func main() {
+	app := cli.NewApp()
+	app.Name = "safe-security"
+	app.Usage = "safe-security COMMAND"
+	app.Authors = []cli.Author{
+		cli.Author{
+			Name:  "CS-SI",
+			Email: "safescale@c-s.fr",
+		},
+	}
+	app.EnableBashCompletion = true
+	app.Flags = cmd.GlobalFlags
+	app.Commands = []cli.Command{
+		cmd.AuditCmd,
+		cmd.InitCmd,
+		cmd.LimitCmd,
+		cmd.ServiceCmd,
+		cmd.RoleCmd,
+		cmd.UserCmd,
+	}
+	sort.Sort(cli.CommandsByName(app.Commands))
 
+	err := app.Run(os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
@@ -7,9 +7,10 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/gobwas/glob"
 	"github.com/gorilla/websocket"
 
 	"github.com/CS-SI/SafeScale/security/model"
@@ -26,6 +27,9 @@ var cfg *proxyConfig
 var config oauth2.Config
 var state = uuid.Must(uuid.NewV4()).String()
 var upgrader = websocket.Upgrader{} // use default options
+var cache *permissionCache
+var auditor AuditSink
+var limiter *rateLimiter
 
 type requestInfo struct {
 	service  string
@@ -79,40 +83,52 @@ func authenticate(token string) (string, int) {
 	return claims.Email, http.StatusOK
 }
 
-func authorize(email, service, resource, method string) bool {
+//authorize returns true if the user identified by email is allowed to apply method on the resource of the service
+//A permission only applies to the resources matching its pattern, its action being the method or ALL
+func authorize(email, service, resource, method string) (bool, error) {
 
-	da := model.NewDataAccess(cfg.DatabaseDialect, cfg.DatabaseDSN)
-	srv := da.GetServiceByName(service)
+	srv, err := cache.getService(service)
+	if err != nil {
+		return false, err
+	}
 	if srv == nil {
-		return false
+		return false, nil
 	}
 
-	permissions := da.GetUserAccessPermissionsByService(email, service)
-
+	permissions, err := cache.getPermissions(email, service)
+	if err != nil {
+		return false, err
+	}
 	for _, permission := range permissions {
-		pattern := permission.ResourcePattern
-		g, err := glob.Compile(pattern)
-		if err != nil {
-			continue
-		}
-		if g.Match(resource) && (permission.Action == method) || (permission.Action == "ALL") {
-			return true
+		if permission.allows(resource, method) {
+			return true, nil
 		}
 	}
-	return false
+	return false, nil
 
 }
 
+//getServiceURL returns the URL of the resource of the service, nil if the service doesn't exist
 func getServiceURL(service, resource string) (*url.URL, error) {
-	da := model.NewDataAccess(cfg.DatabaseDialect, cfg.DatabaseDSN)
-	srv := da.GetServiceByName(service)
+	srv, err := cache.getService(service)
+	if err != nil {
+		return nil, err
+	}
 	if srv == nil {
-		return nil, fmt.Errorf("No route define to serve resource %s from service %s", service, resource)
+		return nil, nil
 	}
 	surl := strings.Join([]string{srv.BaseURL, resource}, "/")
 	return url.Parse(surl)
 }
 
+//unavailable responds 503 when the database can't be read, the decision being unknown
+func unavailable(w http.ResponseWriter, record *AuditRecord, err error) {
+	log.Printf("Failed to read the security database: %v", err)
+	record.Decision = AuditUnavailable
+	record.Status = http.StatusServiceUnavailable
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
 func httpForward(w http.ResponseWriter, req *http.Request, url *url.URL) {
 	// create the reverse proxy
 	proxy := httputil.NewSingleHostReverseProxy(url)
@@ -133,45 +149,118 @@ func httpForward(w http.ResponseWriter, req *http.Request, url *url.URL) {
 
 }
 
+//newAuditRecord starts the audit record of the request
+func newAuditRecord(r *http.Request, info requestInfo) *AuditRecord {
+	return &AuditRecord{
+		Time:       time.Now(),
+		RemoteAddr: r.RemoteAddr,
+		Service:    info.service,
+		Resource:   info.resource,
+		Method:     info.method,
+	}
+}
+
+//audit writes the audit record of a request once responded
+func audit(record *AuditRecord) {
+	if auditor == nil {
+		return
+	}
+	record.LatencyMS = int64(time.Since(record.Time) / time.Millisecond)
+	err := auditor.Write(*record)
+	if err != nil {
+		log.Printf("Failed to write audit record: %v", err)
+	}
+}
+
 //httpProxyFunc forward authorized request to pr++++++++++++otected service
 func httpProxyFunc(w http.ResponseWriter, r *http.Request) {
 
 	info := parseRequest(r)
+	record := newAuditRecord(r, info)
+	defer audit(record)
 
-	if !authorizedAndAuthenticated(w, r, info, "WS") {
+	if !authorizedAndAuthenticated(w, r, info, record) {
+		return
+	}
+	if rateLimited(w, info, record) {
 		return
 	}
 
 	url, err := getServiceURL(info.service, info.resource)
 	if err != nil {
+		unavailable(w, record, err)
+		return
+	}
+	if url == nil {
+		record.Decision = AuditNoRoute
+		record.Status = http.StatusBadGateway
 		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
 		return
 	}
 
-	httpForward(w, r, url)
+	recorder := &statusRecorder{ResponseWriter: w}
+	httpForward(recorder, r, url)
+	record.Status = recorder.status
 
 }
 
-func authorizedAndAuthenticated(w http.ResponseWriter, r *http.Request, info requestInfo, method string) bool {
+//authorizedAndAuthenticated checks the request is authenticated and authorized, filling the decision of the
+//audit record, and responds the error if not
+func authorizedAndAuthenticated(w http.ResponseWriter, r *http.Request, info requestInfo, record *AuditRecord) bool {
 	if cfg.AuthenticationEnabled() {
 		email, status := authenticate(info.token)
 
 		if status == http.StatusTemporaryRedirect {
+			record.Decision = AuditUnauthenticated
+			record.Status = http.StatusFound
 			http.Redirect(w, r, config.AuthCodeURL(state), http.StatusFound)
 			return false
 		}
 		if status != http.StatusOK {
+			record.Decision = AuditUnauthenticated
+			record.Status = status
 			http.Error(w, http.StatusText(status), status)
 			return false
 		}
+		record.Email = email
 
-		ok := authorize(email, info.service, info.resource, info.method)
+		ok, err := authorize(email, info.service, info.resource, info.method)
+		if err != nil {
+			unavailable(w, record, err)
+			return false
+		}
 		if !ok {
+			record.Decision = AuditDenied
+			record.Status = http.StatusUnauthorized
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return false
 		}
 	}
 
+	record.Decision = AuditAllowed
+	return true
+}
+
+//rateLimited counts the request in the rate limit of the user towards the service, and responds 429 with the
+//time to wait if the rate limit is exceeded, or 503 if the database can't be read
+func rateLimited(w http.ResponseWriter, info requestInfo, record *AuditRecord) bool {
+	limit, err := cache.getRateLimit(record.Email, info.service)
+	if err != nil {
+		unavailable(w, record, err)
+		return true
+	}
+	ok, wait, err := limiter.allow(record.Email, info.service, limit, time.Now())
+	if err != nil {
+		unavailable(w, record, err)
+		return true
+	}
+	if ok {
+		return false
+	}
+	record.Decision = AuditRateLimited
+	record.Status = http.StatusTooManyRequests
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter(wait)))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
 	return true
 }
 
@@ -192,23 +281,48 @@ func fowardWSMessages(from *websocket.Conn, to *websocket.Conn) {
 
 func wsProxyFunc(w http.ResponseWriter, r *http.Request) {
 	info := parseRequest(r)
-	if !authorizedAndAuthenticated(w, r, info, "WS") {
+	record := newAuditRecord(r, info)
+	defer audit(record)
+	if !authorizedAndAuthenticated(w, r, info, record) {
+		return
+	}
+	if rateLimited(w, info, record) {
 		return
 	}
 
 	cOrig, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		record.Status = http.StatusBadRequest
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
 	url, err := getServiceURL(info.service, info.resource)
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		log.Printf("Failed to read the security database: %v", err)
+		record.Decision = AuditUnavailable
+		record.Status = http.StatusServiceUnavailable
+		cOrig.Close()
+		return
+	}
+	if url == nil {
+		record.Decision = AuditNoRoute
+		record.Status = http.StatusBadGateway
+		cOrig.Close()
 		return
 	}
 	header := http.Header{}
-	cDest, _, err := websocket.DefaultDialer.Dial(url.String(), header)
+	cDest, resp, err := websocket.DefaultDialer.Dial(url.String(), header)
+	if resp != nil {
+		record.Status = resp.StatusCode
+	}
+	if err != nil {
+		if resp == nil {
+			record.Status = http.StatusBadGateway
+		}
+		cOrig.Close()
+		return
+	}
 
 	go fowardWSMessages(cOrig, cDest)
 	go fowardWSMessages(cDest, cOrig)
@@ -235,6 +349,14 @@ type proxyConfig struct {
 	PrivateKey         string
 	DatabaseDialect    string
 	DatabaseDSN        string
+	MaxOpenConns       int
+	MaxIdleConns       int
+	ConnMaxLifetime    time.Duration
+	CacheTTL           time.Duration
+	AdminToken         string
+	AdminUsers         []string
+	AuditSink          string
+	AuditAddress       string
 }
 
 func loadConfig() *proxyConfig {
@@ -246,6 +368,10 @@ func loadConfig() *proxyConfig {
 	if err != nil {                         // Handle errors reading the config file
 		log.Fatal(fmt.Errorf("Fatal error reading config file: %s", err))
 	}
+	viper.SetDefault("database.max_open_conns", model.DefaultMaxOpenConns)
+	viper.SetDefault("database.max_idle_conns", model.DefaultMaxIdleConns)
+	viper.SetDefault("database.conn_max_lifetime", model.DefaultConnMaxLifetime)
+	viper.SetDefault("cache.ttl", 30*time.Second) // 0 disables the cache
 
 	cfg := proxyConfig{
 		OpenIDURL:          viper.GetString("openid-provider.URL"),
@@ -254,6 +380,14 @@ func loadConfig() *proxyConfig {
 		OpenIDRedirectURL:  viper.GetString("openid-provider.redirect_url"),
 		DatabaseDialect:    viper.GetString("database.dialect"),
 		DatabaseDSN:        viper.GetString("database.dsn"),
+		MaxOpenConns:       viper.GetInt("database.max_open_conns"),
+		MaxIdleConns:       viper.GetInt("database.max_idle_conns"),
+		ConnMaxLifetime:    viper.GetDuration("database.conn_max_lifetime"),
+		CacheTTL:           viper.GetDuration("cache.ttl"),
+		AdminToken:         viper.GetString("admin.token"),
+		AdminUsers:         viper.GetStringSlice("admin.users"),
+		AuditSink:          viper.GetString("audit.sink"),
+		AuditAddress:       viper.GetString("audit.address"),
 		Certificate:        viper.GetString("encryption.certificate"),
 		PrivateKey:         viper.GetString("encryption.private_key"),
 	}
@@ -273,6 +407,26 @@ func Start(bindingURL string) {
 
 	cfg = loadConfig()
 
+	da := model.NewDataAccess(cfg.DatabaseDialect, cfg.DatabaseDSN)
+	da.SetPoolLimits(cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime)
+	defer da.Close()
+	cache = newPermissionCache(da, cfg.CacheTTL)
+	limiter = newRateLimiter(da)
+	err := da.Migrate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	auditor, err = NewAuditSink(cfg.AuditSink, cfg.AuditAddress)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if auditor != nil {
+		defer auditor.Close()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", proxify())
+
 	provider, err := oidc.NewProvider(ctx, cfg.OpenIDURL)
 
 	if err != nil {
@@ -293,7 +447,17 @@ func Start(bindingURL string) {
 
 	//http.Handle("/", proxify(addCORS()))
 
-	err = http.ListenAndServeTLS(bindingURL, cfg.Certificate, cfg.PrivateKey, proxify())
+	var adminAuthenticate func(string) (string, int)
+	if cfg.AuthenticationEnabled() {
+		adminAuthenticate = authenticate
+	}
+	admin := newAdminAPI(da, cache, cfg.AdminToken, cfg.AdminUsers, adminAuthenticate)
+	admin.audit = auditor
+	if admin.enabled() {
+		mux.Handle(adminPath, admin)
+	}
+
+	err = http.ListenAndServeTLS(bindingURL, cfg.Certificate, cfg.PrivateKey, mux)
 	if err != nil {
 		log.Fatal("ListenAndServe: ", err)
 	}
@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+//bucket is the token bucket of the requests of a user to a service
+type bucket struct {
+	limit  model.RateLimit //limit the bucket has been filled with, reset if it changes
+	tokens float64
+	last   time.Time
+}
+
+//rateLimiter applies the rate limits of the users to the services
+//The rates are counted in memory, by each instance of the gateway, while the daily quotas are counted in the
+//database, shared by all the instances
+type rateLimiter struct {
+	da *model.DataAccess
+
+	mu      sync.Mutex
+	buckets map[permissionKey]*bucket
+}
+
+//newRateLimiter creates a rate limiter counting the daily quotas in da
+func newRateLimiter(da *model.DataAccess) *rateLimiter {
+	return &rateLimiter{da: da, buckets: map[permissionKey]*bucket{}}
+}
+
+//allow counts a request of the user identified by email to the service at now if limit allows it, and returns
+//the time to wait before the next request if not
+func (rl *rateLimiter) allow(email, service string, limit *model.RateLimit, now time.Time) (bool, time.Duration, error) {
+	if limit == nil || (limit.RequestsPerSecond <= 0 && limit.DailyQuota <= 0) {
+		return true, 0, nil
+	}
+
+	key := permissionKey{email: email, service: service}
+	if limit.RequestsPerSecond > 0 {
+		ok, wait := rl.take(key, limit, now)
+		if !ok {
+			return false, wait, nil
+		}
+	}
+	if limit.DailyQuota > 0 {
+		// The quota is counted out of the lock, not to serialize the requests of every user on the database
+		day := now.UTC().Truncate(24 * time.Hour)
+		ok, err := rl.da.ConsumeQuota(limit.ServiceID, email, day, limit.DailyQuota)
+		if err != nil || !ok {
+			if limit.RequestsPerSecond > 0 {
+				rl.giveBack(key, limit)
+			}
+			if err != nil {
+				return false, 0, err
+			}
+			return false, day.Add(24 * time.Hour).Sub(now), nil
+		}
+	}
+	return true, 0, nil
+}
+
+//take takes a token from the bucket of key at now, and returns the time to wait before the next token if empty
+func (rl *rateLimiter) take(key permissionKey, limit *model.RateLimit, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b, ok := rl.buckets[key]
+	if !ok || b.limit != *limit {
+		if len(rl.buckets) >= maxCacheEntries {
+			rl.purge(now)
+		}
+		b = &bucket{limit: *limit, tokens: float64(limit.Burst), last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.RequestsPerSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / limit.RequestsPerSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+//giveBack gives back to the bucket of key the token taken by a request finally rejected
+func (rl *rateLimiter) giveBack(key permissionKey, limit *model.RateLimit) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b, ok := rl.buckets[key]
+	if ok && b.limit == *limit {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	}
+}
+
+//purge removes the buckets full, which are the same as new ones
+func (rl *rateLimiter) purge(now time.Time) {
+	for k, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.RequestsPerSecond >= float64(b.limit.Burst) {
+			delete(rl.buckets, k)
+		}
+	}
+}
+
+//retryAfter returns the value of the Retry-After header for a wait, in seconds rounded up
+func retryAfter(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
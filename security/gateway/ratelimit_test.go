@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/security/model"
+)
+
+//mustRateLimit returns the rate limit of the user read through the cache, failing the test on error
+func mustRateLimit(t *testing.T, cache *permissionCache, email, service string) *model.RateLimit {
+	limit, err := cache.getRateLimit(email, service)
+	require.Nil(t, err)
+	return limit
+}
+
+//allowed counts a request with the rate limiter, failing the test on error
+func allowed(t *testing.T, rl *rateLimiter, email, service string, limit *model.RateLimit, now time.Time) bool {
+	ok, _, err := rl.allow(email, service, limit, now)
+	require.Nil(t, err)
+	return ok
+}
+
+func TestRateLimiter_Rate(t *testing.T) {
+	rl := newRateLimiter(nil)
+	limit := &model.RateLimit{RequestsPerSecond: 2, Burst: 3}
+	now := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, allowed(t, rl, "user@c-s.fr", "srv1", limit, now))
+	}
+	ok, wait, err := rl.allow("user@c-s.fr", "srv1", limit, now)
+	require.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	assert.Equal(t, 1, retryAfter(wait))
+	// The buckets are per user and per service
+	assert.True(t, allowed(t, rl, "other@c-s.fr", "srv1", limit, now))
+	assert.True(t, allowed(t, rl, "user@c-s.fr", "srv2", limit, now))
+
+	assert.True(t, allowed(t, rl, "user@c-s.fr", "srv1", limit, now.Add(500*time.Millisecond)))
+	assert.False(t, allowed(t, rl, "user@c-s.fr", "srv1", limit, now.Add(500*time.Millisecond)))
+
+	assert.True(t, allowed(t, rl, "user@c-s.fr", "srv1", nil, now))
+}
+
+func TestRateLimiter_Quota(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	// Two instances of the gateway share the daily counts
+	rl1 := newRateLimiter(da)
+	rl2 := newRateLimiter(da)
+	srv := mustService(t, newPermissionCache(da, 0), "srv1")
+	limit := &model.RateLimit{ServiceID: srv.ID, DailyQuota: 2}
+	now := time.Date(2018, 10, 1, 23, 0, 0, 0, time.UTC)
+
+	assert.True(t, allowed(t, rl1, "user@c-s.fr", "srv1", limit, now))
+	assert.True(t, allowed(t, rl2, "user@c-s.fr", "srv1", limit, now))
+	ok, wait, err := rl1.allow("user@c-s.fr", "srv1", limit, now)
+	require.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, time.Hour, wait)
+	assert.False(t, allowed(t, rl2, "user@c-s.fr", "srv1", limit, now))
+	// The counts are per user
+	assert.True(t, allowed(t, rl2, "other@c-s.fr", "srv1", limit, now))
+
+	// The count is kept when the limit changes
+	limit = &model.RateLimit{ServiceID: srv.ID, DailyQuota: 3}
+	assert.True(t, allowed(t, rl1, "user@c-s.fr", "srv1", limit, now))
+	assert.False(t, allowed(t, rl1, "user@c-s.fr", "srv1", limit, now))
+
+	assert.True(t, allowed(t, rl1, "user@c-s.fr", "srv1", limit, now.Add(time.Hour)))
+
+	// A request exceeding the rate isn't counted in the quota (one request already counted this day)
+	limit = &model.RateLimit{ServiceID: srv.ID, RequestsPerSecond: 1, Burst: 1, DailyQuota: 3}
+	now = now.Add(time.Hour)
+	assert.True(t, allowed(t, rl1, "user@c-s.fr", "srv1", limit, now))
+	assert.False(t, allowed(t, rl1, "user@c-s.fr", "srv1", limit, now))
+	assert.True(t, allowed(t, rl1, "user@c-s.fr", "srv1", limit, now.Add(time.Second)))
+	assert.False(t, allowed(t, rl1, "user@c-s.fr", "srv1", limit, now.Add(2*time.Second)))
+	// The token taken by a request rejected by the quota is given back
+	assert.Equal(t, 1.0, rl1.buckets[permissionKey{email: "user@c-s.fr", service: "srv1"}].tokens)
+
+	// The errors of the database are returned
+	db, err := da.Pool()
+	require.Nil(t, err)
+	require.Nil(t, db.DropTable(&model.QuotaUsage{}).Error)
+	_, _, err = rl1.allow("user@c-s.fr", "srv1", limit, now.Add(24*time.Hour))
+	assert.NotNil(t, err)
+}
+
+func TestPermissionCache_RateLimitErrors(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	cache := newPermissionCache(da, time.Minute)
+	_, err := da.SetRateLimit("srv1", model.AnyUser, 5, 5, 0)
+	require.Nil(t, err)
+	db, err := da.Pool()
+	require.Nil(t, err)
+
+	require.Nil(t, db.DropTable(&model.RateLimit{}).Error)
+	_, err = cache.getRateLimit("user@c-s.fr", "srv1")
+	assert.NotNil(t, err)
+	require.Nil(t, db.AutoMigrate(&model.RateLimit{}).Error)
+	// The failure isn't cached as no rate limit
+	assert.Nil(t, mustRateLimit(t, cache, "user@c-s.fr", "srv1"))
+	_, err = da.SetRateLimit("srv1", model.AnyUser, 5, 5, 0)
+	require.Nil(t, err)
+	cache.invalidateService("srv1")
+	assert.NotNil(t, mustRateLimit(t, cache, "user@c-s.fr", "srv1"))
+	assert.Nil(t, mustRateLimit(t, cache, "user@c-s.fr", "srv2"))
+}
+
+func TestAdminAPI_RateLimits(t *testing.T) {
+	da, clean := newTestDataAccess(t)
+	defer clean()
+	cache := newPermissionCache(da, time.Minute)
+	srv := httptest.NewServer(newAdminAPI(da, cache, "secret", nil, nil))
+	defer srv.Close()
+
+	assert.Nil(t, mustRateLimit(t, cache, "user@c-s.fr", "srv1"))
+	var limit model.RateLimit
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "PUT", "/admin/services/srv1/limits/*", RateLimitRequest{RequestsPerSecond: 5, Burst: 5}, &limit))
+	assert.Equal(t, model.AnyUser, limit.Email)
+	require.NotNil(t, mustRateLimit(t, cache, "user@c-s.fr", "srv1"))
+	assert.Equal(t, 5.0, mustRateLimit(t, cache, "user@c-s.fr", "srv1").RequestsPerSecond)
+
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "PUT", "/admin/services/srv1/limits/user@c-s.fr", RateLimitRequest{DailyQuota: 1000}, &limit))
+	require.NotNil(t, mustRateLimit(t, cache, "user@c-s.fr", "srv1"))
+	assert.Equal(t, 1000, mustRateLimit(t, cache, "user@c-s.fr", "srv1").DailyQuota)
+	assert.Equal(t, 5.0, mustRateLimit(t, cache, "other@c-s.fr", "srv1").RequestsPerSecond)
+	assert.Equal(t, http.StatusBadRequest, adminRequest(t, srv, "secret", "PUT", "/admin/services/srv1/limits/user@c-s.fr", RateLimitRequest{RequestsPerSecond: 5}, nil))
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, srv, "secret", "PUT", "/admin/services/srv2/limits/user@c-s.fr", RateLimitRequest{DailyQuota: 1000}, nil))
+
+	var limits []model.RateLimit
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "secret", "GET", "/admin/services/srv1/limits", nil, &limits))
+	assert.Len(t, limits, 2)
+
+	assert.Equal(t, http.StatusNoContent, adminRequest(t, srv, "secret", "DELETE", "/admin/services/srv1/limits/*", nil, nil))
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, srv, "secret", "DELETE", "/admin/services/srv1/limits/*", nil, nil))
+	assert.Nil(t, mustRateLimit(t, cache, "other@c-s.fr", "srv1"))
+	assert.NotNil(t, mustRateLimit(t, cache, "user@c-s.fr", "srv1"))
+}
@@ -0,0 +1,387 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+//ErrNotFound is returned when an object doesn't exist in the database
+type ErrNotFound struct {
+	Kind string
+	Name string
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("%s '%s' not found", e.Kind, e.Name)
+}
+
+//ErrAlreadyExists is returned when an object to create already exists in the database
+type ErrAlreadyExists struct {
+	Kind string
+	Name string
+}
+
+func (e ErrAlreadyExists) Error() string {
+	return fmt.Sprintf("%s '%s' already exists", e.Kind, e.Name)
+}
+
+//ListServices lists the services
+func (da *DataAccess) ListServices() ([]Service, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	services := []Service{}
+	err = db.Order("name").Find(&services).Error
+	return services, err
+}
+
+//GetService get service by name, with its roles and their access permissions
+func (da *DataAccess) GetService(name string) (*Service, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	return getService(db.Preload("Roles.AccessPermissions"), name)
+}
+
+//CreateService creates the service name, reachable at baseURL
+func (da *DataAccess) CreateService(name, baseURL string) (*Service, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	_, err = getService(db, name)
+	if err == nil {
+		return nil, ErrAlreadyExists{Kind: "service", Name: name}
+	}
+	if _, ok := err.(ErrNotFound); !ok {
+		return nil, err
+	}
+	srv := Service{Name: name, BaseURL: baseURL}
+	err = db.Create(&srv).Error
+	if err != nil {
+		return nil, err
+	}
+	return &srv, nil
+}
+
+//UpdateService changes the base URL of the service name
+func (da *DataAccess) UpdateService(name, baseURL string) (*Service, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := getService(db, name)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Model(srv).Update("base_url", baseURL).Error
+	if err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+//DeleteService deletes the service name with its roles and their access permissions
+func (da *DataAccess) DeleteService(name string) error {
+	db, err := da.Pool()
+	if err != nil {
+		return err
+	}
+	srv, err := getService(db, name)
+	if err != nil {
+		return err
+	}
+	var roles []Role
+	err = db.Where(&Role{ServiceID: srv.ID}).Find(&roles).Error
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	for i := range roles {
+		err = deleteRole(tx, &roles[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Where(&RateLimit{ServiceID: srv.ID}).Delete(RateLimit{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Where(&QuotaUsage{ServiceID: srv.ID}).Delete(QuotaUsage{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Delete(srv).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+//ListRoles lists the roles of the service, with their access permissions
+func (da *DataAccess) ListRoles(service string) ([]Role, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := getService(db, service)
+	if err != nil {
+		return nil, err
+	}
+	roles := []Role{}
+	err = db.Where(&Role{ServiceID: srv.ID}).Order("name").Preload("AccessPermissions").Find(&roles).Error
+	return roles, err
+}
+
+//GetRole get the role of the service by name, with its access permissions and its users
+func (da *DataAccess) GetRole(service, name string) (*Role, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	return getRole(db.Preload("AccessPermissions").Preload("Users"), service, name)
+}
+
+//CreateRole creates the role name of the service
+func (da *DataAccess) CreateRole(service, name string) (*Role, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := getService(db, service)
+	if err != nil {
+		return nil, err
+	}
+	_, err = getRole(db, service, name)
+	if err == nil {
+		return nil, ErrAlreadyExists{Kind: "role", Name: service + "/" + name}
+	}
+	if _, ok := err.(ErrNotFound); !ok {
+		return nil, err
+	}
+	role := Role{Name: name, ServiceID: srv.ID}
+	err = db.Create(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+//DeleteRole deletes the role name of the service with its access permissions
+func (da *DataAccess) DeleteRole(service, name string) error {
+	db, err := da.Pool()
+	if err != nil {
+		return err
+	}
+	role, err := getRole(db, service, name)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	err = deleteRole(tx, role)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+//AddAccessPermission allows the role of the service to do action on the resources matching pattern
+func (da *DataAccess) AddAccessPermission(service, role, action, pattern string) (*AccessPermission, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	r, err := getRole(db, service, role)
+	if err != nil {
+		return nil, err
+	}
+	permission := AccessPermission{RoleID: r.ID, Action: action, ResourcePattern: pattern}
+	err = db.Create(&permission).Error
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+//DeleteAccessPermission deletes the access permission identified by id of the role of the service
+func (da *DataAccess) DeleteAccessPermission(service, role string, id uint) error {
+	db, err := da.Pool()
+	if err != nil {
+		return err
+	}
+	r, err := getRole(db, service, role)
+	if err != nil {
+		return err
+	}
+	if id == 0 {
+		return ErrNotFound{Kind: "access permission", Name: "0"}
+	}
+	res := db.Where(&AccessPermission{ID: id, RoleID: r.ID}).Delete(AccessPermission{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound{Kind: "access permission", Name: fmt.Sprintf("%d", id)}
+	}
+	return nil
+}
+
+//ListUsers lists the users
+func (da *DataAccess) ListUsers() ([]User, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	users := []User{}
+	err = db.Order("email").Find(&users).Error
+	return users, err
+}
+
+//GetUser get user by email, with its roles
+func (da *DataAccess) GetUser(email string) (*User, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	return getUser(db.Preload("Roles"), email)
+}
+
+//CreateUser creates the user identified by email
+func (da *DataAccess) CreateUser(email string) (*User, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	_, err = getUser(db, email)
+	if err == nil {
+		return nil, ErrAlreadyExists{Kind: "user", Name: email}
+	}
+	if _, ok := err.(ErrNotFound); !ok {
+		return nil, err
+	}
+	user := User{Email: email}
+	err = db.Create(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+//DeleteUser deletes the user identified by email
+func (da *DataAccess) DeleteUser(email string) error {
+	db, err := da.Pool()
+	if err != nil {
+		return err
+	}
+	user, err := getUser(db, email)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	err = tx.Model(user).Association("Roles").Clear().Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Delete(user).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+//AddUserRole grants the role of the service to the user identified by email
+func (da *DataAccess) AddUserRole(email, service, role string) error {
+	db, err := da.Pool()
+	if err != nil {
+		return err
+	}
+	user, err := getUser(db, email)
+	if err != nil {
+		return err
+	}
+	r, err := getRole(db, service, role)
+	if err != nil {
+		return err
+	}
+	return db.Model(user).Association("Roles").Append(r).Error
+}
+
+//RemoveUserRole revokes the role of the service from the user identified by email
+func (da *DataAccess) RemoveUserRole(email, service, role string) error {
+	db, err := da.Pool()
+	if err != nil {
+		return err
+	}
+	user, err := getUser(db, email)
+	if err != nil {
+		return err
+	}
+	r, err := getRole(db, service, role)
+	if err != nil {
+		return err
+	}
+	return db.Model(user).Association("Roles").Delete(r).Error
+}
+
+func getService(db *gorm.DB, name string) (*Service, error) {
+	var srv Service
+	res := db.Where(&Service{Name: name}).Take(&srv)
+	if res.RecordNotFound() {
+		return nil, ErrNotFound{Kind: "service", Name: name}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &srv, nil
+}
+
+func getRole(db *gorm.DB, service, name string) (*Role, error) {
+	srv, err := getService(db.New(), service)
+	if err != nil {
+		return nil, err
+	}
+	var role Role
+	res := db.Where(&Role{Name: name, ServiceID: srv.ID}).Take(&role)
+	if res.RecordNotFound() {
+		return nil, ErrNotFound{Kind: "role", Name: service + "/" + name}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &role, nil
+}
+
+func getUser(db *gorm.DB, email string) (*User, error) {
+	var user User
+	res := db.Where(&User{Email: email}).Take(&user)
+	if res.RecordNotFound() {
+		return nil, ErrNotFound{Kind: "user", Name: email}
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return &user, nil
+}
+
+//deleteRole deletes the role, its access permissions and its bindings to the users
+func deleteRole(tx *gorm.DB, role *Role) error {
+	err := tx.Where(&AccessPermission{RoleID: role.ID}).Delete(AccessPermission{}).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(role).Association("Users").Clear().Error
+	if err != nil {
+		return err
+	}
+	return tx.Delete(role).Error
+}
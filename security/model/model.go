@@ -1,6 +1,9 @@
 package model
 
 import (
+	"sync"
+	"time"
+
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mssql"    //Import gorm mssql driver
 	_ "github.com/jinzhu/gorm/dialects/mysql"    //Import gorm mysql driver
@@ -10,48 +13,82 @@ import (
 
 //Service is a resource secured by the Gateway
 type Service struct {
-	ID      uint   `gorm:"primary_key; AUTO_INCREMENT"`
-	Name    string `gorm:"unique_index"`
-	BaseURL string
-	Roles   []Role
+	ID      uint   `gorm:"primary_key; AUTO_INCREMENT" json:"id"`
+	Name    string `gorm:"unique_index" json:"name"`
+	BaseURL string `json:"base_url"`
+	Roles   []Role `json:"roles,omitempty"`
 }
 
 //Role define a role relative to a service
 type Role struct {
-	ID                uint `gorm:"primary_key; AUTO_INCREMENT"`
-	Name              string
-	ServiceID         uint
-	AccessPermissions []AccessPermission
-	Users             []User `gorm:"many2many:user_roles;" `
+	ID                uint               `gorm:"primary_key; AUTO_INCREMENT" json:"id"`
+	Name              string             `json:"name"`
+	ServiceID         uint               `json:"service_id"`
+	AccessPermissions []AccessPermission `json:"access_permissions,omitempty"`
+	Users             []User             `gorm:"many2many:user_roles;" json:"users,omitempty"`
 }
 
 //AccessPermission defines access pemission of a role towards a resource offered by a service
 type AccessPermission struct {
-	ID              uint   `gorm:"primary_key; AUTO_INCREMENT"`
-	ResourcePattern string `gorm:"not nul"`
-	Action          string `gorm:"not nul"`
-	RoleID          uint
+	ID              uint   `gorm:"primary_key; AUTO_INCREMENT" json:"id"`
+	ResourcePattern string `gorm:"not nul" json:"resource_pattern"`
+	Action          string `gorm:"not nul" json:"action"`
+	RoleID          uint   `json:"role_id"`
 }
 
 //User defines a user
 type User struct {
-	ID    uint   `gorm:"primary_key; AUTO_INCREMENT"`
-	Email string `gorm:"unique_index"`
-	Roles []Role `gorm:"many2many:user_roles;"`
+	ID    uint   `gorm:"primary_key; AUTO_INCREMENT" json:"id"`
+	Email string `gorm:"unique_index" json:"email"`
+	Roles []Role `gorm:"many2many:user_roles;" json:"roles,omitempty"`
 }
 
+//Default limits of the connection pool of a DataAccess
+const (
+	DefaultMaxOpenConns    = 20
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 30 * time.Minute
+)
+
 //DataAccess wraps access to the database and implements utility requests
 type DataAccess struct {
 	dialect string
 	dsn     string
+
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+
+	mu   sync.Mutex
+	pool *gorm.DB
 }
 
 //NewDataAccess creates a new DataAccess
 func NewDataAccess(dialect, dsn string) *DataAccess {
-	return &DataAccess{dialect: dialect, dsn: dsn}
+	return &DataAccess{
+		dialect:         dialect,
+		dsn:             dsn,
+		maxOpenConns:    DefaultMaxOpenConns,
+		maxIdleConns:    DefaultMaxIdleConns,
+		connMaxLifetime: DefaultConnMaxLifetime,
+	}
 }
 
-//Get returns database access
+//SetPoolLimits sets the limits of the connection pool; values <= 0 keep the current ones
+//Must be called before the first use of the pool
+func (da *DataAccess) SetPoolLimits(maxOpenConns, maxIdleConns int, connMaxLifetime time.Duration) {
+	if maxOpenConns > 0 {
+		da.maxOpenConns = maxOpenConns
+	}
+	if maxIdleConns > 0 {
+		da.maxIdleConns = maxIdleConns
+	}
+	if connMaxLifetime > 0 {
+		da.connMaxLifetime = connMaxLifetime
+	}
+}
+
+//Get returns a new database access, which must be closed by the caller
 func (da *DataAccess) Get() *gorm.DB {
 	db, err := gorm.Open(da.dialect, da.dsn)
 	if err != nil {
@@ -60,51 +97,104 @@ func (da *DataAccess) Get() *gorm.DB {
 	return db
 }
 
+//Pool returns the database access shared by the requests of the DataAccess, opened at first call
+//The database access must not be closed by the caller, but with DataAccess.Close
+func (da *DataAccess) Pool() (*gorm.DB, error) {
+	da.mu.Lock()
+	defer da.mu.Unlock()
+	if da.pool == nil {
+		db, err := gorm.Open(da.dialect, da.dsn)
+		if err != nil {
+			return nil, err
+		}
+		db.DB().SetMaxOpenConns(da.maxOpenConns)
+		db.DB().SetMaxIdleConns(da.maxIdleConns)
+		db.DB().SetConnMaxLifetime(da.connMaxLifetime)
+		da.pool = db
+	}
+	return da.pool, nil
+}
+
+//Close closes the database access shared by the requests of the DataAccess
+func (da *DataAccess) Close() error {
+	da.mu.Lock()
+	defer da.mu.Unlock()
+	if da.pool == nil {
+		return nil
+	}
+	err := da.pool.Close()
+	da.pool = nil
+	return err
+}
+
 //GetUserAccessPermissionsByService get user access permission by service
-func (da *DataAccess) GetUserAccessPermissionsByService(email, serviceName string) []AccessPermission {
-	db := da.Get()
-	defer db.Close()
+//A user or a service not found has no access permission; the errors of the database are returned
+func (da *DataAccess) GetUserAccessPermissionsByService(email, serviceName string) ([]AccessPermission, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
 	var service Service
-	if db.Where(&Service{Name: serviceName}).Take(&service).Error != nil {
-		return nil
+	res := db.Where(&Service{Name: serviceName}).Take(&service)
+	if res.RecordNotFound() {
+		return []AccessPermission{}, nil
+	}
+	if res.Error != nil {
+		return nil, res.Error
 	}
 	var user User
-	if db.Where(&User{Email: email}).Take(&user).Error != nil {
-		return nil
+	res = db.Where(&User{Email: email}).Take(&user)
+	if res.RecordNotFound() {
+		return []AccessPermission{}, nil
+	}
+	if res.Error != nil {
+		return nil, res.Error
 	}
 	var roles []Role
-	if db.Model(&user).Where(&Role{ServiceID: service.ID}).Preload("AccessPermissions").Related(&roles, "Roles").Error != nil {
-		return nil
+	err = db.Model(&user).Where(&Role{ServiceID: service.ID}).Preload("AccessPermissions").Related(&roles, "Roles").Error
+	if err != nil {
+		return nil, err
 	}
-	var permissions []AccessPermission
+	permissions := []AccessPermission{}
 	for _, role := range roles {
 		permissions = append(permissions, role.AccessPermissions...)
 	}
-	return permissions
+	return permissions, nil
 }
 
-//GetServiceByName get service by name
-func (da *DataAccess) GetServiceByName(name string) *Service {
-	db := da.Get()
-	defer db.Close()
-	var srv Service
-	if db.Where(&Service{Name: name}).Take(&srv).Error != nil {
-		return nil
+//GetServiceByName get service by name, nil if not found; the errors of the database are returned
+func (da *DataAccess) GetServiceByName(name string) (*Service, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
 	}
-	return &srv
+	srv, err := getService(db, name)
+	if _, ok := err.(ErrNotFound); ok {
+		return nil, nil
+	}
+	return srv, err
 }
 
 //Init initialize the database: drop tables if exists and create new empty ones
 func (da *DataAccess) Init() error {
 	db := da.Get()
 	defer db.Close()
-	err := db.DropTableIfExists(&Service{}, &Role{}, &AccessPermission{}, &User{}).Error
+	err := db.DropTableIfExists(&Service{}, &Role{}, &AccessPermission{}, &User{}, &RateLimit{}, &QuotaUsage{}).Error
 	if err != nil {
 		return err
 	}
-	err = db.AutoMigrate(&Service{}, &Role{}, &AccessPermission{}, &User{}).Error
+	err = db.AutoMigrate(&Service{}, &Role{}, &AccessPermission{}, &User{}, &RateLimit{}, &QuotaUsage{}).Error
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+//Migrate creates the missing tables and columns of the database, keeping its content
+func (da *DataAccess) Migrate() error {
+	db, err := da.Pool()
+	if err != nil {
+		return err
+	}
+	return db.AutoMigrate(&Service{}, &Role{}, &AccessPermission{}, &User{}, &RateLimit{}, &QuotaUsage{}).Error
+}
@@ -52,7 +52,8 @@ func TestModel(t *testing.T) {
 	var roles []model.Role
 	assert.Nil(t, db.Model(&usr1).Where(&model.Role{ServiceID: srv1.ID}).Preload("AccessPermissions").Related(&roles, "Roles").Error)
 	assert.Equal(t, 1, len(roles))
-	perms := da.GetUserAccessPermissionsByService("user@c-s.fr", "srv1")
+	perms, err := da.GetUserAccessPermissionsByService("user@c-s.fr", "srv1")
+	assert.Nil(t, err)
 	fmt.Println(perms)
 
 }
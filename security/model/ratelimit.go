@@ -0,0 +1,191 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+//AnyUser is the email of the rate limit applied to the users of a service without rate limit of their own
+const AnyUser = "*"
+
+//RateLimit defines the rate limit and the daily quota of the requests of a user to a service
+type RateLimit struct {
+	ID        uint   `gorm:"primary_key; AUTO_INCREMENT" json:"id"`
+	ServiceID uint   `gorm:"unique_index:idx_rate_limit" json:"service_id"`
+	Email     string `gorm:"unique_index:idx_rate_limit" json:"email"`
+	//RequestsPerSecond is the rate at which the requests are allowed, without limit if 0
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	//Burst is the number of requests allowed at once
+	Burst int `json:"burst"`
+	//DailyQuota is the number of requests allowed by day (UTC), without limit if 0
+	DailyQuota int `json:"daily_quota"`
+}
+
+//quotaDayFormat is the format of the day of a quota usage
+const quotaDayFormat = "2006-01-02"
+
+//QuotaUsage counts the requests of a user to a service during a day (UTC), to apply the daily quota of the user
+type QuotaUsage struct {
+	ID        uint   `gorm:"primary_key; AUTO_INCREMENT" json:"id"`
+	ServiceID uint   `gorm:"unique_index:idx_quota_usage" json:"service_id"`
+	Email     string `gorm:"unique_index:idx_quota_usage" json:"email"`
+	Day       string `gorm:"unique_index:idx_quota_usage" json:"day"`
+	Requests  int    `json:"requests"`
+}
+
+//Validate checks the values of the rate limit
+func (l *RateLimit) Validate() error {
+	if l.RequestsPerSecond < 0 || l.Burst < 0 || l.DailyQuota < 0 {
+		return fmt.Errorf("rate, burst and daily quota can't be negative")
+	}
+	if l.RequestsPerSecond > 0 && l.Burst == 0 {
+		return fmt.Errorf("burst must be at least 1 with a rate")
+	}
+	return nil
+}
+
+//GetRateLimit get the rate limit of the user identified by email towards the service, the one of any user if the
+//user has none, nil if none applies; the errors of the database are returned
+func (da *DataAccess) GetRateLimit(email, serviceName string) (*RateLimit, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := getService(db, serviceName)
+	if _, ok := err.(ErrNotFound); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var limits []RateLimit
+	err = db.Where("service_id = ? AND email IN (?)", srv.ID, []string{email, AnyUser}).Find(&limits).Error
+	if err != nil {
+		return nil, err
+	}
+	var result *RateLimit
+	for i := range limits {
+		if limits[i].Email == email {
+			return &limits[i], nil
+		}
+		result = &limits[i]
+	}
+	return result, nil
+}
+
+//ConsumeQuota counts a request of the user identified by email to the service during day (UTC) if less than quota
+//requests have been counted, and returns false if the quota is exhausted
+//The count is stored in the database, shared by all the instances of the gateway
+func (da *DataAccess) ConsumeQuota(serviceID uint, email string, day time.Time, quota int) (bool, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return false, err
+	}
+	usage := QuotaUsage{ServiceID: serviceID, Email: email, Day: day.UTC().Format(quotaDayFormat)}
+	consume := func() (bool, error) {
+		res := db.Model(&QuotaUsage{}).
+			Where("service_id = ? AND email = ? AND day = ? AND requests < ?", usage.ServiceID, usage.Email, usage.Day, quota).
+			UpdateColumn("requests", gorm.Expr("requests + 1"))
+		return res.RowsAffected > 0, res.Error
+	}
+	ok, err := consume()
+	if err != nil || ok {
+		return ok, err
+	}
+
+	// Quota exhausted, or first request of the day
+	res := db.Where(&usage).Take(&QuotaUsage{})
+	if res.Error == nil {
+		return false, nil
+	}
+	if !res.RecordNotFound() {
+		return false, res.Error
+	}
+	if quota <= 0 {
+		return false, nil
+	}
+	err = db.Where("service_id = ? AND email = ? AND day < ?", usage.ServiceID, usage.Email, usage.Day).Delete(QuotaUsage{}).Error
+	if err != nil {
+		return false, err
+	}
+	usage.Requests = 1
+	err = db.Create(&usage).Error
+	if err != nil {
+		// Created meanwhile by another instance
+		return consume()
+	}
+	return true, nil
+}
+
+//ListRateLimits lists the rate limits of the service
+func (da *DataAccess) ListRateLimits(service string) ([]RateLimit, error) {
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := getService(db, service)
+	if err != nil {
+		return nil, err
+	}
+	limits := []RateLimit{}
+	err = db.Where(&RateLimit{ServiceID: srv.ID}).Order("email").Find(&limits).Error
+	return limits, err
+}
+
+//SetRateLimit creates or replaces the rate limit of the user identified by email (AnyUser for any user) towards
+//the service
+func (da *DataAccess) SetRateLimit(service, email string, requestsPerSecond float64, burst int, dailyQuota int) (*RateLimit, error) {
+	if email == "" {
+		return nil, fmt.Errorf("missing email of the rate limit")
+	}
+	db, err := da.Pool()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := getService(db, service)
+	if err != nil {
+		return nil, err
+	}
+	limit := RateLimit{ServiceID: srv.ID, Email: email}
+	err = db.Where(&limit).FirstOrInit(&limit).Error
+	if err != nil {
+		return nil, err
+	}
+	limit.RequestsPerSecond = requestsPerSecond
+	limit.Burst = burst
+	limit.DailyQuota = dailyQuota
+	err = limit.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = db.Save(&limit).Error
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+//DeleteRateLimit deletes the rate limit of the user identified by email (AnyUser for any user) towards the service
+func (da *DataAccess) DeleteRateLimit(service, email string) error {
+	if email == "" {
+		return fmt.Errorf("missing email of the rate limit")
+	}
+	db, err := da.Pool()
+	if err != nil {
+		return err
+	}
+	srv, err := getService(db, service)
+	if err != nil {
+		return err
+	}
+	res := db.Where(&RateLimit{ServiceID: srv.ID, Email: email}).Delete(RateLimit{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound{Kind: "rate limit", Name: service + "/" + email}
+	}
+	return nil
+}
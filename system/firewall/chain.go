@@ -30,6 +30,11 @@ type Chain struct {
 	lock   sync.RWMutex
 }
 
+// PolicyName returns the iptables policy of the chain, empty if not set
+func (c *Chain) PolicyName() string {
+	return policyTargets[c.Policy]
+}
+
 // Add appends a rule at the end in the chain
 func (c *Chain) Add(rule Rule) *Chain {
 	c.lock.Lock()
@@ -46,12 +51,50 @@ func (c *Chain) Insert(rule Rule) *Chain {
 	return c
 }
 
-// Remove a rule from the chain
+// Remove a rule from the chain (every occurrence of the rule is removed)
 func (c *Chain) Remove(rule Rule) *Chain {
+	c.lock.Lock()
+	rules := c.Rules[:0]
+	for _, r := range c.Rules {
+		if r != rule {
+			rules = append(rules, r)
+		}
+	}
+	c.Rules = rules
+	c.lock.Unlock()
 	return c
 }
 
-// RemoveIndex removes a rule by its index in the chain
+// RemoveIndex removes a rule by its index in the chain (starting from 0); does nothing if there is no rule at this index
 func (c *Chain) RemoveIndex(idx uint) *Chain {
+	c.lock.Lock()
+	if idx < uint(len(c.Rules)) {
+		c.Rules = append(c.Rules[:idx], c.Rules[idx+1:]...)
+	}
+	c.lock.Unlock()
 	return c
 }
+
+// InsertIndex adds a rule at the index 'idx' in the chain (starting from 0); the rule is appended if idx is beyond
+// the end of the chain
+func (c *Chain) InsertIndex(idx uint, rule Rule) *Chain {
+	c.lock.Lock()
+	if idx >= uint(len(c.Rules)) {
+		c.Rules = append(c.Rules, rule)
+	} else {
+		c.Rules = append(c.Rules[:idx], append([]Rule{rule}, c.Rules[idx:]...)...)
+	}
+	c.lock.Unlock()
+	return c
+}
+
+// render returns the rules of the chain in iptables-save format
+func (c *Chain) render() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lines := make([]string, 0, len(c.Rules))
+	for _, r := range c.Rules {
+		lines = append(lines, r.render(c.Name))
+	}
+	return lines
+}
@@ -18,15 +18,59 @@ package firewall
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/CS-SI/SafeScale/system"
 )
 
+// rollbackDelay is the delay after which ToSystem restores the previous rules if the host can't be reached anymore
+// with the new ones; it must be longer than the timeout of a SSH connection
+const rollbackDelay = 90 * time.Second
+
+// tablesOrder is the order of the tables in iptables-save format
+var tablesOrder = []string{"raw", "mangle", "nat", "filter", "security"}
+
+const (
+	// applyScript applies the rules (1st parameter) and schedules their rollback after a delay (2nd parameter, in
+	// seconds), to be cancelled by confirmScript
+	applyScript = `set -e
+if [ -f /var/tmp/safescale-firewall.rollback ] && kill -0 $(cat /var/tmp/safescale-firewall.rollback) 2>/dev/null; then
+    echo "another change of the firewall is in progress" >&2
+    exit 1
+fi
+cat >/var/tmp/safescale-firewall.rules <<'SAFESCALE_FIREWALL_RULES'
+%s
+SAFESCALE_FIREWALL_RULES
+iptables-restore --test </var/tmp/safescale-firewall.rules
+iptables-save >/var/tmp/safescale-firewall.backup
+nohup sh -c 'sleep %d; iptables-restore </var/tmp/safescale-firewall.backup' >/dev/null 2>&1 &
+echo $! >/var/tmp/safescale-firewall.rollback
+iptables-restore </var/tmp/safescale-firewall.rules
+`
+	// confirmScript cancels the rollback scheduled by applyScript and saves the rules to be restored at boot
+	confirmScript = `set -e
+kill $(cat /var/tmp/safescale-firewall.rollback)
+rm -f /var/tmp/safescale-firewall.rollback
+if [ -d /etc/iptables ]; then
+    iptables-save >/etc/iptables/rules.v4
+elif [ -d /etc/sysconfig ]; then
+    iptables-save >/etc/sysconfig/iptables
+fi
+`
+	// rollbackScript restores immediately the rules saved by applyScript
+	rollbackScript = `kill $(cat /var/tmp/safescale-firewall.rollback) 2>/dev/null
+rm -f /var/tmp/safescale-firewall.rollback
+iptables-restore </var/tmp/safescale-firewall.backup
+`
+)
+
 // Firewall implements a Firewall based on iptables
 type Firewall struct {
 	// Tables contains all the tables of the Firewall
 	Tables map[string]*Table
+	ssh    *system.SSHConfig
 }
 
 // NewFirewall creates a new instance of Firewall and returns a pointer to it
@@ -56,13 +100,101 @@ func NewFirewall(ssh *system.SSHConfig) *Firewall {
 				Chains: nil,
 			},
 		},
+		ssh: ssh,
 	}
 	return &fw
 }
 
 // FromSystem loads the current Firewall configuration from system
 func (fw *Firewall) FromSystem() error {
-	return fmt.Errorf("not yet implemented")
+	out, err := fw.run("iptables-save")
+	if err != nil {
+		return fmt.Errorf("failed to read firewall rules: %s", err.Error())
+	}
+	return fw.Parse(out)
+}
+
+// Parse loads the Firewall configuration from the output of iptables-save
+func (fw *Firewall) Parse(content string) error {
+	tables := map[string]*Table{}
+	var table *Table
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "*"):
+			if table != nil {
+				return fmt.Errorf("line %d: table '%s' not committed", i+1, table.Name)
+			}
+			table = &Table{Name: line[1:], Chains: map[string]*Chain{}}
+			tables[table.Name] = table
+		case table == nil:
+			return fmt.Errorf("line %d: no table declared", i+1)
+		case line == "COMMIT":
+			table = nil
+		case strings.HasPrefix(line, ":"):
+			fields := strings.Fields(line[1:])
+			if len(fields) < 2 {
+				return fmt.Errorf("line %d: invalid chain declaration", i+1)
+			}
+			policy, ok := ParsePolicy(fields[1])
+			if !ok {
+				return fmt.Errorf("line %d: invalid policy '%s'", i+1, fields[1])
+			}
+			table.NewChain(fields[0], policy)
+		default:
+			args, err := splitArgs(line)
+			if err != nil {
+				return fmt.Errorf("line %d: %s", i+1, err.Error())
+			}
+			if len(args) < 2 || (args[0] != "-A" && args[0] != "--append") || args[1] == "" {
+				return fmt.Errorf("line %d: invalid rule", i+1)
+			}
+			chain, err := table.Chain(args[1])
+			if err != nil {
+				return fmt.Errorf("line %d: %s", i+1, err.Error())
+			}
+			rule, err := parseRule(args[2:])
+			if err != nil {
+				return fmt.Errorf("line %d: %s", i+1, err.Error())
+			}
+			chain.Add(*rule)
+		}
+	}
+	if table != nil {
+		return fmt.Errorf("table '%s' not committed", table.Name)
+	}
+	fw.Tables = tables
+	return nil
+}
+
+// TableNames returns the names of the tables of the Firewall, in the order of iptables-save
+func (fw *Firewall) TableNames() []string {
+	names := []string{}
+	for _, name := range tablesOrder {
+		if _, ok := fw.Tables[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var others []string
+	for name := range fw.Tables {
+		if _, ok := builtinChains[name]; !ok {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// Render returns the Firewall configuration in the format of iptables-save
+func (fw *Firewall) Render() string {
+	var lines []string
+	for _, name := range fw.TableNames() {
+		lines = append(lines, fw.Tables[name].render()...)
+	}
+	return strings.Join(lines, "\n") + "\n"
 }
 
 // Table returns the Table instance corresponding to table 'name'
@@ -76,7 +208,57 @@ func (fw *Firewall) Table(name string) (*Table, error) {
 	return table, nil
 }
 
-// ToSystem applies the firewall rules to the host
+// ToSystem applies the firewall rules to the host, replacing the rules of the tables of the Firewall (the other
+// tables are left untouched). The rules are applied atomically by iptables-restore; if the host doesn't accept SSH
+// connections anymore with the new rules, the previous ones are restored.
 func (fw *Firewall) ToSystem() error {
-	return fmt.Errorf("not yet implemented")
+	for _, table := range fw.Tables {
+		for _, chain := range table.Chains {
+			for i, rule := range chain.Rules {
+				err := rule.Validate()
+				if err != nil {
+					return fmt.Errorf("invalid rule #%d of chain '%s' of table '%s': %s", i+1, chain.Name, table.Name, err.Error())
+				}
+			}
+		}
+	}
+
+	_, err := fw.run(fmt.Sprintf(applyScript, strings.TrimSuffix(fw.Render(), "\n"), int(rollbackDelay.Seconds())))
+	if err != nil {
+		return fmt.Errorf("failed to apply firewall rules: %s", err.Error())
+	}
+
+	// A new connection is needed to check the new rules, the connections already established may still be accepted
+	err = fw.ssh.CheckConnection()
+	if err != nil {
+		_, rollbackErr := fw.run(rollbackScript)
+		if rollbackErr != nil {
+			return fmt.Errorf("host can't be reached with the new firewall rules, the previous rules will be restored in %v: %s", rollbackDelay, err.Error())
+		}
+		return fmt.Errorf("host can't be reached with the new firewall rules, the previous rules have been restored: %s", err.Error())
+	}
+	_, err = fw.run(confirmScript)
+	if err != nil {
+		return fmt.Errorf("failed to confirm the new firewall rules, the previous rules will be restored in %v: %s", rollbackDelay, err.Error())
+	}
+	return nil
+}
+
+// run runs a script with sudo on the host and returns its output
+func (fw *Firewall) run(script string) (string, error) {
+	if fw.ssh == nil {
+		return "", fmt.Errorf("no SSH configuration to reach the host")
+	}
+	cmd, err := fw.ssh.SudoCommand(script)
+	if err != nil {
+		return "", err
+	}
+	retcode, stdout, stderr, err := cmd.Run()
+	if err != nil {
+		return "", err
+	}
+	if retcode != 0 {
+		return "", fmt.Errorf("%s (retcode %d)", strings.TrimSpace(stderr), retcode)
+	}
+	return stdout, nil
 }
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/system/firewall/Action"
+	"github.com/CS-SI/SafeScale/system/firewall/Policy"
+)
+
+const iptablesSave = `# Generated by iptables-save v1.6.1 on Mon Oct  8 10:12:31 2018
+*nat
+:PREROUTING ACCEPT [12:720]
+:INPUT ACCEPT [3:180]
+:OUTPUT ACCEPT [45:3120]
+:POSTROUTING ACCEPT [0:0]
+-A POSTROUTING -s 192.168.0.0/24 -o eth0 -j MASQUERADE
+COMMIT
+# Completed on Mon Oct  8 10:12:31 2018
+# Generated by iptables-save v1.6.1 on Mon Oct  8 10:12:31 2018
+*filter
+:INPUT DROP [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [1043:154307]
+:SAFESCALE - [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+-A INPUT -s 10.0.0.0/8 -p udp -m udp --sport 53 --dport 1024:65535 -j ACCEPT
+-A INPUT -p icmp -m comment --comment "allow \"ping\"" -j ACCEPT
+-A INPUT ! -s 192.168.0.0/24 -j SAFESCALE
+-A SAFESCALE -p tcp -j REJECT --reject-with tcp-reset
+COMMIT
+# Completed on Mon Oct  8 10:12:31 2018
+`
+
+func TestParse(t *testing.T) {
+	fw := NewFirewall(nil)
+	require.Nil(t, fw.Parse(iptablesSave))
+	require.Len(t, fw.Tables, 2)
+
+	filter, err := fw.Table("filter")
+	require.Nil(t, err)
+	input, err := filter.Chain("INPUT")
+	require.Nil(t, err)
+	assert.Equal(t, Policy.Drop, input.Policy)
+	require.Len(t, input.Rules, 6)
+	assert.Equal(t, Rule{Action: Action.Accept, InInterface: "lo"}, input.Rules[0])
+	assert.Equal(t, Rule{Action: Action.Accept, Matches: "-m conntrack --ctstate RELATED,ESTABLISHED"}, input.Rules[1])
+	assert.Equal(t, Rule{Action: Action.Accept, Protocol: "tcp", ToPort: "22"}, input.Rules[2])
+	assert.Equal(t, Rule{Action: Action.Accept, Protocol: "udp", From: "10.0.0.0/8", FromPort: "53", ToPort: "1024:65535"}, input.Rules[3])
+	assert.Equal(t, Rule{Action: Action.Accept, Protocol: "icmp", Matches: `-m comment --comment "allow \"ping\""`}, input.Rules[4])
+	assert.Equal(t, Rule{Target: "SAFESCALE", Matches: "! -s 192.168.0.0/24"}, input.Rules[5])
+
+	custom, err := filter.Chain("SAFESCALE")
+	require.Nil(t, err)
+	assert.Equal(t, Policy.Enum(0), custom.Policy)
+	assert.Equal(t, []Rule{{Action: Action.Reject, Protocol: "tcp", TargetOptions: "--reject-with tcp-reset"}}, custom.Rules)
+
+	nat, err := fw.Table("nat")
+	require.Nil(t, err)
+	postrouting, err := nat.Chain("POSTROUTING")
+	require.Nil(t, err)
+	assert.Equal(t, []Rule{{Target: "MASQUERADE", From: "192.168.0.0/24", OutInterface: "eth0"}}, postrouting.Rules)
+
+	err = fw.Parse("*filter\n-A INPUT -j ACCEPT\nCOMMIT\n")
+	assert.NotNil(t, err)
+	err = fw.Parse("*filter\n:INPUT ACCEPT [0:0]\n-A INPUT -p tcp\nCOMMIT\n")
+	assert.NotNil(t, err)
+	err = fw.Parse("*filter\n:INPUT ACCEPT [0:0]\n")
+	assert.NotNil(t, err)
+}
+
+func TestRender(t *testing.T) {
+	fw := NewFirewall(nil)
+	require.Nil(t, fw.Parse(iptablesSave))
+	rendered := fw.Render()
+	assert.Equal(t, `*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+-A POSTROUTING -s 192.168.0.0/24 -o eth0 -j MASQUERADE
+COMMIT
+*filter
+:INPUT DROP [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:SAFESCALE - [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+-A INPUT -s 10.0.0.0/8 -p udp -m udp --sport 53 --dport 1024:65535 -j ACCEPT
+-A INPUT -p icmp -m comment --comment "allow \"ping\"" -j ACCEPT
+-A INPUT ! -s 192.168.0.0/24 -j SAFESCALE
+-A SAFESCALE -p tcp -j REJECT --reject-with tcp-reset
+COMMIT
+`, rendered)
+
+	// The rendered rules give back the same model
+	other := NewFirewall(nil)
+	require.Nil(t, other.Parse(rendered))
+	assert.Equal(t, rendered, other.Render())
+}
+
+func TestChain(t *testing.T) {
+	table := &Table{Name: "filter"}
+	chain := table.NewChain("INPUT", Policy.Accept)
+	ssh := Rule{Action: Action.Accept, Protocol: "tcp", ToPort: "22"}
+	http := Rule{Action: Action.Accept, Protocol: "tcp", ToPort: "80"}
+	drop := Rule{Action: Action.Drop}
+
+	chain.Add(http).Add(drop).Insert(ssh)
+	assert.Equal(t, []Rule{ssh, http, drop}, chain.Rules)
+	chain.RemoveIndex(1)
+	assert.Equal(t, []Rule{ssh, drop}, chain.Rules)
+	chain.RemoveIndex(5)
+	assert.Equal(t, []Rule{ssh, drop}, chain.Rules)
+	chain.InsertIndex(1, http)
+	assert.Equal(t, []Rule{ssh, http, drop}, chain.Rules)
+	chain.Remove(http)
+	assert.Equal(t, []Rule{ssh, drop}, chain.Rules)
+	assert.Equal(t, "-A INPUT -j DROP", drop.render("INPUT"))
+	invalid := Rule{Action: Action.Accept, ToPort: "22"}
+	assert.NotNil(t, invalid.Validate())
+}
@@ -17,15 +17,234 @@
 package firewall
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
+
 	"github.com/CS-SI/SafeScale/system/firewall/Action"
 )
 
 // Rule defines a firewall rule
 type Rule struct {
-	Action   Action.Enum
-	Protocol string
-	From     string
-	FromPort string
-	To       string
-	ToPort   string
+	Action Action.Enum
+	// Target is the target of the rule when it's not an Action (user-defined chain, or target extension like MASQUERADE)
+	Target string
+	// TargetOptions contains the options of the target, in iptables syntax (ex: "--reject-with icmp-port-unreachable")
+	TargetOptions string
+	Protocol      string
+	From          string
+	FromPort      string
+	To            string
+	ToPort        string
+	InInterface   string
+	OutInterface  string
+	// Matches contains the other match options of the rule, in iptables syntax (ex: "-m conntrack --ctstate RELATED,ESTABLISHED")
+	Matches string
+}
+
+// actionTargets contains the iptables targets corresponding to the actions
+var actionTargets = map[Action.Enum]string{
+	Action.Accept: "ACCEPT",
+	Action.Drop:   "DROP",
+	Action.Reject: "REJECT",
+	Action.Queue:  "QUEUE",
+	Action.Return: "RETURN",
+}
+
+// ParseAction returns the action corresponding to an iptables target (case insensitive)
+func ParseAction(target string) (Action.Enum, bool) {
+	target = strings.ToUpper(target)
+	for action, t := range actionTargets {
+		if t == target {
+			return action, true
+		}
+	}
+	return 0, false
+}
+
+// TargetName returns the iptables target of the rule
+func (r *Rule) TargetName() string {
+	if target, ok := actionTargets[r.Action]; ok {
+		return target
+	}
+	return r.Target
+}
+
+// Validate checks the rule can be applied
+func (r *Rule) Validate() error {
+	if r.TargetName() == "" {
+		return fmt.Errorf("rule has neither action nor target")
+	}
+	if (r.FromPort != "" || r.ToPort != "") && r.Protocol == "" {
+		return fmt.Errorf("a protocol is needed to filter on ports")
+	}
+	return nil
+}
+
+// render returns the rule in iptables-save format
+func (r *Rule) render(chain string) string {
+	args := []string{"-A", chain}
+	if r.From != "" {
+		args = append(args, "-s", r.From)
+	}
+	if r.To != "" {
+		args = append(args, "-d", r.To)
+	}
+	if r.InInterface != "" {
+		args = append(args, "-i", r.InInterface)
+	}
+	if r.OutInterface != "" {
+		args = append(args, "-o", r.OutInterface)
+	}
+	if r.Protocol != "" {
+		args = append(args, "-p", r.Protocol)
+		if r.FromPort != "" || r.ToPort != "" {
+			args = append(args, "-m", r.Protocol)
+		}
+	}
+	if r.FromPort != "" {
+		args = append(args, "--sport", r.FromPort)
+	}
+	if r.ToPort != "" {
+		args = append(args, "--dport", r.ToPort)
+	}
+	line := joinArgs(args)
+	if r.Matches != "" {
+		line += " " + r.Matches
+	}
+	line += " -j " + quoteArg(r.TargetName())
+	if r.TargetOptions != "" {
+		line += " " + r.TargetOptions
+	}
+	return line
+}
+
+// parseRule parses the arguments of a rule in iptables-save format, after "-A <chain>"
+func parseRule(args []string) (*Rule, error) {
+	rule := Rule{}
+	var matches, targetOptions []string
+	inTarget := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if inTarget {
+			targetOptions = append(targetOptions, arg)
+			continue
+		}
+
+		value := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value of option '%s'", arg)
+			}
+			i++
+			return args[i], nil
+		}
+		var err error
+		switch arg {
+		case "!":
+			// Negations are kept as is, with the negated option and its value
+			if i+2 >= len(args) {
+				return nil, fmt.Errorf("invalid negation")
+			}
+			matches = append(matches, args[i:i+3]...)
+			i += 2
+		case "-s", "--source":
+			rule.From, err = value()
+		case "-d", "--destination":
+			rule.To, err = value()
+		case "-i", "--in-interface":
+			rule.InInterface, err = value()
+		case "-o", "--out-interface":
+			rule.OutInterface, err = value()
+		case "-p", "--protocol":
+			rule.Protocol, err = value()
+		case "--sport", "--source-port":
+			rule.FromPort, err = value()
+		case "--dport", "--destination-port":
+			rule.ToPort, err = value()
+		case "-m", "--match":
+			var match string
+			match, err = value()
+			// The match of the protocol is implicit
+			if err == nil && match != rule.Protocol {
+				matches = append(matches, arg, match)
+			}
+		case "-j", "--jump":
+			var target string
+			target, err = value()
+			if err == nil {
+				if action, ok := ParseAction(target); ok {
+					rule.Action = action
+				} else {
+					rule.Target = target
+				}
+				inTarget = true
+			}
+		default:
+			matches = append(matches, arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	rule.Matches = joinArgs(matches)
+	rule.TargetOptions = joinArgs(targetOptions)
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// splitArgs splits a line of iptables-save output in arguments, handling double quotes
+func splitArgs(line string) ([]string, error) {
+	var (
+		args    []string
+		current bytes.Buffer
+		inArg   bool
+		quoted  bool
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(line):
+			i++
+			current.WriteByte(line[i])
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// quoteArg quotes an argument containing spaces, the way iptables-save does
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"\\") {
+		return arg
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + replacer.Replace(arg) + `"`
+}
+
+// joinArgs joins arguments, quoting them if needed
+func joinArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, quoteArg(arg))
+	}
+	return strings.Join(quoted, " ")
 }
@@ -18,6 +18,8 @@ package firewall
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/CS-SI/SafeScale/system/firewall/Policy"
@@ -30,13 +32,58 @@ type Table struct {
 	lock   sync.RWMutex
 }
 
+// builtinChains contains the built-in chains of the tables, in the order of iptables-save
+var builtinChains = map[string][]string{
+	"filter":   {"INPUT", "FORWARD", "OUTPUT"},
+	"nat":      {"PREROUTING", "INPUT", "OUTPUT", "POSTROUTING"},
+	"mangle":   {"PREROUTING", "INPUT", "FORWARD", "OUTPUT", "POSTROUTING"},
+	"raw":      {"PREROUTING", "OUTPUT"},
+	"security": {"INPUT", "FORWARD", "OUTPUT"},
+}
+
+// policyTargets contains the iptables policies corresponding to the chain policies
+var policyTargets = map[Policy.Enum]string{
+	Policy.Accept: "ACCEPT",
+	Policy.Drop:   "DROP",
+}
+
+// ParsePolicy returns the chain policy corresponding to an iptables policy (case insensitive); "-" corresponds to
+// the absence of policy of user-defined chains
+func ParsePolicy(policy string) (Policy.Enum, bool) {
+	if policy == "-" {
+		return 0, true
+	}
+	switch strings.ToUpper(policy) {
+	case "ACCEPT":
+		return Policy.Accept, true
+	case "DROP":
+		return Policy.Drop, true
+	}
+	return 0, false
+}
+
+// IsBuiltin tells if the chain named 'name' is a built-in chain of the table
+func (t *Table) IsBuiltin(name string) bool {
+	for _, n := range builtinChains[t.Name] {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 // NewChain creates a new
 func (t *Table) NewChain(name string, policy Policy.Enum) *Chain {
 	chain := Chain{
 		Name:   name,
 		Policy: policy,
 	}
+	t.lock.Lock()
+	if t.Chains == nil {
+		t.Chains = map[string]*Chain{}
+	}
 	t.Chains[name] = &chain
+	t.lock.Unlock()
 	return &chain
 }
 
@@ -47,8 +94,52 @@ func (t *Table) Chain(name string) (*Chain, error) {
 	}
 	var chain *Chain
 	var found bool
+	t.lock.RLock()
+	defer t.lock.RUnlock()
 	if chain, found = t.Chains[name]; !found {
 		return nil, fmt.Errorf("table '%s' doesn't contain a chain '%s'", t.Name, name)
 	}
 	return chain, nil
 }
+
+// ChainNames returns the names of the chains of the table, built-in chains first (even if not defined in the table)
+func (t *Table) ChainNames() []string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	names := append([]string{}, builtinChains[t.Name]...)
+	var others []string
+	for name := range t.Chains {
+		if !t.IsBuiltin(name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// render returns the table in iptables-save format; the built-in chains without policy accept the packets
+func (t *Table) render() []string {
+	names := t.ChainNames()
+
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	lines := []string{"*" + t.Name}
+	for _, name := range names {
+		// Only the built-in chains have a policy
+		policy := "-"
+		if t.IsBuiltin(name) {
+			policy = "ACCEPT"
+			if chain, ok := t.Chains[name]; ok && chain.PolicyName() != "" {
+				policy = chain.PolicyName()
+			}
+		}
+		lines = append(lines, fmt.Sprintf(":%s %s [0:0]", name, policy))
+	}
+	for _, name := range names {
+		if chain, ok := t.Chains[name]; ok {
+			lines = append(lines, chain.render()...)
+		}
+	}
+	return append(lines, "COMMIT")
+}
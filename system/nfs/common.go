@@ -19,9 +19,7 @@ package nfs
 import (
 	"bytes"
 	"fmt"
-	"os/exec"
 	"strings"
-	"syscall"
 	"text/template"
 	"time"
 
@@ -162,10 +160,8 @@ func executeScript(sshconfig system.SSHConfig, name string, data map[string]inte
 			stderr = ""
 			retcode = 0
 			if err != nil {
-				if ee, ok := err.(*exec.ExitError); ok {
-					if status, ok := ee.Sys().(syscall.WaitStatus); ok {
-						retcode = status.ExitStatus()
-					}
+				if ee, ok := err.(*system.SSHExitError); ok {
+					retcode = ee.ExitStatus()
 					stderr = string(ee.Stderr)
 				}
 			}
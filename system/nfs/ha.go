@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nfs
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// A highly available share is a GlusterFS volume replicated between several servers, each of them mounting
+// the volume and exporting it with NFS under the same FSID; keepalived moves a VIP between the servers,
+// following the NFS service, and the clients mount the share from the VIP
+
+// BrickPath returns the path of the brick of the GlusterFS volume on a server
+func BrickPath(volume string) string {
+	return "/srv/safescale/bricks/" + volume
+}
+
+// VRRPRouterID returns the VRRP router ID to use for vip, which must be unique in the network
+// The last byte of the IP address is used, unique in networks up to /24
+func VRRPRouterID(vip string) (int, error) {
+	ip := net.ParseIP(vip).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("invalid VIP '%s': not an IPv4 address", vip)
+	}
+	id := int(ip[3])
+	if id == 0 {
+		id = 255
+	}
+	return id, nil
+}
+
+// InstallHA installs and configures on the remote host the services needed by the highly available shares
+func (s *Server) InstallHA() error {
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_server_ha_install.sh", map[string]interface{}{})
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to install high availability of nfs server")
+}
+
+// CreateReplicatedVolume creates a GlusterFS volume replicated between the remote host, reachable by its peers
+// at localIP, and the hosts reachable at peers
+func (s *Server) CreateReplicatedVolume(volume string, localIP string, peers []string) error {
+	bricks := []string{localIP + ":" + BrickPath(volume)}
+	for _, p := range peers {
+		bricks = append(bricks, p+":"+BrickPath(volume))
+	}
+	data := map[string]interface{}{
+		"Volume":   volume,
+		"Peers":    peers,
+		"Replicas": len(bricks),
+		"Bricks":   strings.Join(bricks, " "),
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "glusterfs_volume_create.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to create replicated volume")
+}
+
+// AddReplicatedShare mounts the GlusterFS volume in path and exports it by NFS with the FSID fsid, which must
+// be the same on every server of the share
+// acl contains the ACLs of the export in exports syntax, separated by spaces (everyone allowed if empty)
+func (s *Server) AddReplicatedShare(volume string, path string, acl string, fsid string) error {
+	err := checkExport(path, acl)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"Volume": volume,
+		"Path":   path,
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "glusterfs_volume_mount.sh", data)
+	err = handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to mount replicated volume")
+	if err != nil {
+		return err
+	}
+
+	data = map[string]interface{}{
+		"Path":         path,
+		"AccessRights": acl,
+		"FSID":         fsid,
+		"Update":       false,
+	}
+	retcode, stdout, stderr, err = executeScript(*s.SshConfig, "nfs_server_path_export.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to export a shared directory")
+}
+
+// SetVIP configures the VRRP instance name, moving vip between the remote host, reachable at localIP, and the
+// hosts reachable at peers; the VIP is held by the master while its NFS service runs
+func (s *Server) SetVIP(name string, vip string, localIP string, peers []string, master bool) error {
+	routerID, err := VRRPRouterID(vip)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"Name":     name,
+		"VIP":      vip,
+		"RouterID": routerID,
+		"LocalIP":  localIP,
+		"Peers":    peers,
+		"Master":   master,
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_server_ha_vip.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to configure VIP")
+}
+
+// RemoveReplicatedShare unconfigures the highly available share exporting path on the remote host, and deletes
+// the GlusterFS volume if deleteVolume is true
+func (s *Server) RemoveReplicatedShare(name string, volume string, path string, deleteVolume bool) error {
+	err := checkPath(path)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"Name":         name,
+		"Volume":       volume,
+		"Path":         path,
+		"DeleteVolume": deleteVolume,
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_server_ha_share_remove.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to remove highly available share")
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVRRPRouterID(t *testing.T) {
+	id, err := VRRPRouterID("192.168.1.42")
+	require.Nil(t, err)
+	assert.Equal(t, 42, id)
+
+	// 0 isn't a valid router ID
+	id, err = VRRPRouterID("10.0.1.0")
+	require.Nil(t, err)
+	assert.Equal(t, 255, id)
+
+	_, err = VRRPRouterID("fd00::1")
+	assert.NotNil(t, err)
+	_, err = VRRPRouterID("vip")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,58 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# glusterfs_volume_create.sh
+#
+# Creates and starts a GlusterFS volume replicated between the local host and its peers
+
+set -u -o pipefail
+
+function print_error {
+    read line file <<<$(caller)
+    echo "An error occurred in line $line of file $file:" "{"`sed "${line}q;d" "$file"`"}" >&2
+}
+trap print_error ERR
+
+function dns_fallback {
+    grep nameserver /etc/resolv.conf && return 0
+    echo -e "nameserver 1.1.1.1\n" > /tmp/resolv.conf
+    sudo cp /tmp/resolv.conf /etc/resolv.conf
+    return 0
+}
+
+dns_fallback
+
+{{- range .Peers }}
+gluster peer probe {{.}} || exit 1
+{{- end }}
+
+# Waits for the peers to be connected
+CONNECTED=0
+for i in $(seq 60); do
+    CONNECTED=$(gluster peer status | grep -c "(Connected)")
+    [ $CONNECTED -ge {{len .Peers}} ] && break
+    sleep 2
+done
+[ $CONNECTED -lt {{len .Peers}} ] && {
+    echo "Failed to connect to the GlusterFS peers" >&2
+    exit 1
+}
+
+# 'force' allows the bricks on the root filesystem; '--mode=script' acknowledges the risk of split-brain with 2 replicas
+gluster --mode=script volume create {{.Volume}} replica {{.Replicas}} transport tcp {{.Bricks}} force || exit 1
+# Reduces the time the clients wait for a failed replica
+gluster --mode=script volume set {{.Volume}} network.ping-timeout 5 || exit 1
+gluster --mode=script volume start {{.Volume}}
@@ -0,0 +1,86 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_server_ha_install.sh
+#
+# Installs and configures GlusterFS server and keepalived, needed by the highly available shares
+
+set -u -o pipefail
+
+function print_error {
+    read line file <<<$(caller)
+    echo "An error occurred in line $line of file $file:" "{"`sed "${line}q;d" "$file"`"}" >&2
+}
+trap print_error ERR
+
+function dns_fallback {
+    grep nameserver /etc/resolv.conf && return 0
+    echo -e "nameserver 1.1.1.1\n" > /tmp/resolv.conf
+    sudo cp /tmp/resolv.conf /etc/resolv.conf
+    return 0
+}
+
+dns_fallback
+
+{{.reserved_BashLibrary}}
+
+echo "Install GlusterFS server and keepalived"
+
+case $LINUX_KIND in
+    debian|ubuntu)
+        export DEBIAN_FRONTEND=noninteractive
+        sfWaitForApt && apt-get update && sfWaitForApt && apt-get install -qqy glusterfs-server glusterfs-client keepalived
+        ;;
+
+    rhel|centos)
+        yum makecache fast
+        yum install -y centos-release-gluster
+        yum install -y glusterfs-server glusterfs-fuse keepalived
+        ;;
+
+    *)
+        echo "Unsupported operating system '$LINUX_KIND'"
+        exit 1
+        ;;
+esac
+
+systemctl enable glusterd
+systemctl start glusterd
+
+# Contains the bricks of the GlusterFS volumes replicating the shares
+mkdir -p /srv/safescale/bricks
+
+# Each highly available share has its own VRRP instance in /etc/keepalived/conf.d; the VIP follows the NFS service
+mkdir -p /etc/keepalived/conf.d
+grep -q "^include /etc/keepalived/conf.d/" /etc/keepalived/keepalived.conf 2>/dev/null || {
+    cat >/etc/keepalived/keepalived.conf <<-'KEEPALIVEDEOF'
+global_defs {
+    enable_script_security
+    script_user root
+}
+
+vrrp_script safescale_check_nfs {
+    script "/usr/sbin/rpcinfo -t localhost nfs"
+    interval 2
+    fall 2
+    rise 2
+}
+
+include /etc/keepalived/conf.d/*.conf
+KEEPALIVEDEOF
+    systemctl enable keepalived
+    systemctl restart keepalived
+}
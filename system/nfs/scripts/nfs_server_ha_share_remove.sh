@@ -0,0 +1,63 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_server_ha_share_remove.sh
+#
+# Unconfigures a highly available share on one of its servers
+
+set -u -o pipefail
+
+function print_error {
+    read line file <<<$(caller)
+    echo "An error occurred in line $line of file $file:" "{"`sed "${line}q;d" "$file"`"}" >&2
+}
+trap print_error ERR
+
+function dns_fallback {
+    grep nameserver /etc/resolv.conf && return 0
+    echo -e "nameserver 1.1.1.1\n" > /tmp/resolv.conf
+    sudo cp /tmp/resolv.conf /etc/resolv.conf
+    return 0
+}
+
+# Removes the export of the path from /etc/exports; the path is compared as is, not as a regular expression
+function remove_export {
+    awk -v path="$1" '$1 != path' /etc/exports >/etc/exports.safescale && cat /etc/exports.safescale >/etc/exports
+    rm -f /etc/exports.safescale
+}
+
+dns_fallback
+
+# Releases the VIP
+rm -f /etc/keepalived/conf.d/{{.Name}}.conf
+systemctl reload keepalived
+
+# Unexports the share
+remove_export "{{.Path}}"
+exportfs -ra
+
+# Unmounts the GlusterFS volume
+umount -l "{{.Path}}"
+awk -v path="{{.Path}}" '!($2 == path && $3 == "glusterfs")' /etc/fstab >/etc/fstab.safescale && cat /etc/fstab.safescale >/etc/fstab
+rm -f /etc/fstab.safescale
+
+{{- if .DeleteVolume }}
+
+# Deletes the GlusterFS volume
+gluster --mode=script volume stop {{.Volume}} force
+gluster --mode=script volume delete {{.Volume}} || exit 1
+{{- end }}
+rm -rf /srv/safescale/bricks/{{.Volume}}
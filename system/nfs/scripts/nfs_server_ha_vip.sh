@@ -0,0 +1,67 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_server_ha_vip.sh
+#
+# Configures the VRRP instance moving the VIP of a highly available share between its servers
+
+set -u -o pipefail
+
+function print_error {
+    read line file <<<$(caller)
+    echo "An error occurred in line $line of file $file:" "{"`sed "${line}q;d" "$file"`"}" >&2
+}
+trap print_error ERR
+
+function dns_fallback {
+    grep nameserver /etc/resolv.conf && return 0
+    echo -e "nameserver 1.1.1.1\n" > /tmp/resolv.conf
+    sudo cp /tmp/resolv.conf /etc/resolv.conf
+    return 0
+}
+
+dns_fallback
+
+INTERFACE=$(ip -o -4 addr show | awk '$4 ~ /^{{.LocalIP}}\// {print $2}' | head -n 1)
+[ -z "$INTERFACE" ] && {
+    echo "Failed to find the network interface owning '{{.LocalIP}}'" >&2
+    exit 1
+}
+
+# Unicast is used, multicast being generally not available in the clouds
+cat >/etc/keepalived/conf.d/{{.Name}}.conf <<-KEEPALIVEDEOF
+vrrp_instance {{.Name}} {
+    state {{ if .Master }}MASTER{{ else }}BACKUP{{ end }}
+    interface $INTERFACE
+    virtual_router_id {{.RouterID}}
+    priority {{ if .Master }}150{{ else }}100{{ end }}
+    advert_int 1
+    unicast_src_ip {{.LocalIP}}
+    unicast_peer {
+{{- range .Peers }}
+        {{.}}
+{{- end }}
+    }
+    virtual_ipaddress {
+        {{.VIP}}
+    }
+    track_script {
+        safescale_check_nfs
+    }
+}
+KEEPALIVEDEOF
+
+systemctl reload keepalived
@@ -33,54 +33,58 @@ function dns_fallback {
     return 0
 }
 
+# Removes the export of the path from /etc/exports; the path is compared as is, not as a regular expression
+function remove_export {
+    awk -v path="$1" '$1 != path' /etc/exports >/etc/exports.safescale && cat /etc/exports.safescale >/etc/exports
+    rm -f /etc/exports.safescale
+}
+
 dns_fallback
 
-# Determines the FSID value to use
-FSIDs=$(cat /etc/exports | sed -r 's/ /\n/g' | grep fsid= | sed -r 's/.+fsid=([[:alnum:]]+),.*/\1/g' | uniq | sort -n)
-LAST_FSID=$(echo "$FSIDs" | tail -n 1)
-if [ -z "$LAST_FSID" ]; then
-    FSID=1
-else
-    FSID=$((LAST_FSID + 1))
+EXPORTED=$(awk -v path="{{.Path}}" '$1 == path' /etc/exports)
+{{- if .Update }}
+[ -z "$EXPORTED" ] && {
+    echo "'{{.Path}}' isn't exported" >&2
+    exit 1
+}
+{{- else }}
+[ ! -z "$EXPORTED" ] && {
+    echo "'{{.Path}}' is already exported" >&2
+    exit 1
+}
+{{- end }}
+
+# Determines the FSID value to use: an updated export keeps its FSID, a new one takes the one given or the next
+# free numeric value
+FSID="{{.FSID}}"
+[ -z "$FSID" ] && FSID=$(echo "$EXPORTED" | grep -o 'fsid=[[:alnum:]-]*' | head -n 1 | cut -d= -f2 || true)
+if [ -z "$FSID" ]; then
+    LAST_FSID=$(grep -o 'fsid=[[:digit:]]*[,)]' /etc/exports | tr -d ',)' | cut -d= -f2 | sort -n | tail -n 1 || true)
+    if [ -z "$LAST_FSID" ]; then
+        FSID=1
+    else
+        FSID=$((LAST_FSID + 1))
+    fi
 fi
 
 # Adapts ACL
 ACCESS_RIGHTS="{{.AccessRights}}"
-FILTERED_ACCESS_RIGHTS=
 if [ -z "$ACCESS_RIGHTS" ]; then
     # No access rights, using default ones
     FILTERED_ACCESS_RIGHTS="*(rw,fsid=$FSID,sync,no_root_squash,no_subtree_check)"
 else
-    # Wants to ensure FSID is valid otherwise updates it
-    ACL=$(echo $ACCESS_RIGHTS | sed -r 's/\((.*)\)')
-    if [ ! -z "$ACL" ]; then
-        # If there is something between parenthesis, checks if there is some fsid directive, and check the values
-        # are not already used for other shares
-        ACL_FSIDs=$(echo $ACL | sed -r 's/ /\n/g' | grep fsid= | sed -r 's/.+fsid=([[:alnum:]]+),.*/\1/g' | uniq | sort -n)
-        for f in $ACL_FSIDs; do
-            echo $FSIDs | grep "^${f}$" && {
-                # FSID value is already used, updating the Access Rights to use the calculated new FSID
-                FILTERED_ACCESS_RIGHTS=$(echo $ACCESS_RIGHTS | sed -r 's/fsid=[[:numeric:]]*/fsid=$FSID/g')
-            }
-            break
-        done
-        if [ -z $FILTERED_ACCESS_RIGHTS ]; then
-            # No updated access rights, with something between parenthesis, adding fsid= directive
-            FILTERED_ACCESS_RIGHTS=$(echo $ACCESS_RIGHTS | sed -r 's/\)/,fsid=$FSID\)/g')
-        fi
-    else
-        # No updated access rights without anything between parenthesis, adding fsid= directive
-        FILTERED_ACCESS_RIGHTS=$(echo $ACCESS_RIGHTS | sed -r 's/\)/fsid=$FSID/g')
-    fi
+    # Replaces the fsid directive of each ACL, if any, by the FSID of the export
+    FILTERED_ACCESS_RIGHTS=$(echo "$ACCESS_RIGHTS" | sed -r -e 's/,fsid=[[:alnum:]]+//g' -e 's/\(fsid=[[:alnum:]]+,?/(/g' \
+                                                         -e 's/\(([^)]+)\)/(\1,fsid='$FSID')/g' -e 's/\(\)/(fsid='$FSID')/g')
 fi
-#VPL: case not managed: nothing between braces...
 
 # Create exported dir if necessary
 mkdir -p "{{.Path}}"
 chmod a+rwx "{{.Path}}"
 
 # Configures export
+remove_export "{{.Path}}"
 echo "{{.Path}} $FILTERED_ACCESS_RIGHTS" >>/etc/exports
 
-# Updates exports
-exportfs -a
+# Updates exports; the mounts of the clients still allowed are kept
+exportfs -ra
@@ -31,7 +31,13 @@ function dns_fallback {
     return 0
 }
 
+# Removes the export of the path from /etc/exports; the path is compared as is, not as a regular expression
+function remove_export {
+    awk -v path="$1" '$1 != path' /etc/exports >/etc/exports.safescale && cat /etc/exports.safescale >/etc/exports
+    rm -f /etc/exports.safescale
+}
+
 dns_fallback
 
-sed -i '\#^{{.Path}} #d' /etc/exports
+remove_export "{{.Path}}"
 exportfs -ar
@@ -71,17 +71,44 @@ func (s *Server) UnmountBlockDevice(device string) error {
 }
 
 // AddShare configures a local path to be exported by NFS
+// acl contains the ACLs of the export in exports syntax, separated by spaces (everyone allowed if empty)
 func (s *Server) AddShare(path string, acl string) error {
+	err := checkExport(path, acl)
+	if err != nil {
+		return err
+	}
 	data := map[string]interface{}{
 		"Path":         path,
 		"AccessRights": acl,
+		"FSID":         "",
+		"Update":       false,
 	}
 	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_server_path_export.sh", data)
 	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to export a shared directory")
 }
 
+// UpdateShare replaces the ACLs of a local path already exported by NFS, the clients keeping their mounts
+func (s *Server) UpdateShare(path string, acl string) error {
+	err := checkExport(path, acl)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"Path":         path,
+		"AccessRights": acl,
+		"FSID":         "",
+		"Update":       true,
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_server_path_export.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to update the export of a shared directory")
+}
+
 // RemoveShare stops export of a local mount point by NFS on the remote server
 func (s *Server) RemoveShare(path string) error {
+	err := checkPath(path)
+	if err != nil {
+		return err
+	}
 	data := map[string]interface{}{
 		"Path": path,
 	}
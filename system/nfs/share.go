@@ -19,12 +19,14 @@ package nfs
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/CS-SI/SafeScale/system/nfs/SecurityFlavor"
 )
 
+//ExportOptions contains the options of an export ACL
 type ExportOptions struct {
 	ReadOnly       bool
 	NoRootSquash   bool
@@ -34,10 +36,36 @@ type ExportOptions struct {
 	CrossMount     bool
 	NoSubtreeCheck bool
 	SetFSID        bool
-	AnonUID        int
-	AnonGID        int
+	// AnonUID and AnonGID, if not nil, contain the uid and gid of the anonymous account (root squashed)
+	AnonUID *int
+	AnonGID *int
 }
 
+var (
+	// hostPattern contains the characters allowed in the host of an ACL: IP addresses, CIDRs, host names,
+	// wildcards and netgroups; the ACLs are given to a shell script run as root, nothing else is accepted
+	hostPattern = regexp.MustCompile(`^[A-Za-z0-9.*?:/_@-]+$`)
+	// pathPattern contains the characters allowed in the path of a share, given to shell scripts run as root
+	pathPattern = regexp.MustCompile(`^/[A-Za-z0-9._/+-]*$`)
+	// fsidPattern contains the values of the fsid option replaced by the scripts
+	fsidPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+)
+
+// checkPath checks the path can be exported
+func checkPath(path string) error {
+	if path == "" {
+		return fmt.Errorf("invalid parameter: 'path' can't be empty")
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("invalid parameter: 'path' must be absolute")
+	}
+	if !pathPattern.MatchString(path) {
+		return fmt.Errorf("invalid parameter: 'path' may contain only letters, digits and '._/+-'")
+	}
+	return nil
+}
+
+//ExportAcl defines the access to a share granted to hosts
 type ExportAcl struct {
 	//Host contains the pattern of hosts authorized (cf. exports man page)
 	Host string
@@ -54,13 +82,21 @@ type Share struct {
 	ACLs   []ExportAcl
 }
 
+// checkExport checks the path and the ACLs (in exports syntax) given to the scripts exporting a share
+func checkExport(path string, acls string) error {
+	err := checkPath(path)
+	if err != nil {
+		return err
+	}
+	_, err = ParseExportAcls(acls)
+	return err
+}
+
 //NewShare creates a share struct corresponding to the export of path on server
 func NewShare(server Server, path string) (*Share, error) {
-	if path == "" {
-		return nil, fmt.Errorf("invalid parameter: 'path' can't be empty")
-	}
-	if !filepath.IsAbs(path) {
-		return nil, fmt.Errorf("invalid parameter: 'path' must be absolute")
+	err := checkPath(path)
+	if err != nil {
+		return nil, err
 	}
 	share := Share{
 		Server: &server,
@@ -78,63 +114,203 @@ func (s *Share) AddAcl(acl ExportAcl) {
 
 //Add configures and exports the share
 func (s *Share) Add() error {
-	var acls string
-	for _, a := range s.ACLs {
-		acl := a.Host + "("
-		if len(a.SecurityModes) > 0 {
-			acl += "sec="
-			for _, item := range a.SecurityModes {
-				acl += item.String()
-			}
-		} else {
-			acl += "sec=sys"
-		}
-		if a.Options.ReadOnly {
-			acl += ",ro"
-		} else {
-			acl += ",rw"
-		}
-		if a.Options.NoRootSquash {
-			acl += ",no_root_squash"
-		} else {
-			acl += ",root_squash"
-		}
-		if a.Options.NoHide && !a.Options.CrossMount {
-			acl += ",nohide"
-		}
-		if a.Options.CrossMount {
-			acl += ",crossmnt"
+	return s.Server.AddShare(s.Path, RenderExportAcls(s.ACLs))
+}
+
+//Update replaces the ACLs of the share already exported, without interrupting the export
+func (s *Share) Update() error {
+	return s.Server.UpdateShare(s.Path, RenderExportAcls(s.ACLs))
+}
+
+// securityFlavorNames contains the names of the security flavors in exports syntax
+var securityFlavorNames = map[SecurityFlavor.Enum]string{
+	SecurityFlavor.Sys:   "sys",
+	SecurityFlavor.Krb5:  "krb5",
+	SecurityFlavor.Krb5i: "krb5i",
+	SecurityFlavor.Krb5p: "krb5p",
+}
+
+//ParseSecurityFlavor returns the security flavor corresponding to its name in exports syntax (sys, krb5, krb5i or krb5p)
+func ParseSecurityFlavor(name string) (SecurityFlavor.Enum, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for flavor, n := range securityFlavorNames {
+		if n == name {
+			return flavor, nil
 		}
-		if a.Options.SetFSID {
-			acl += ",fsid=1"
+	}
+	return 0, fmt.Errorf("invalid security flavor '%s', must be sys, krb5, krb5i or krb5p", name)
+}
+
+//String returns the ACL in exports syntax (cf. exports man page), as "<host>(<options>)"
+func (a ExportAcl) String() string {
+	options := []string{}
+	if len(a.SecurityModes) > 0 {
+		modes := []string{}
+		for _, item := range a.SecurityModes {
+			modes = append(modes, securityFlavorNames[item])
 		}
-		if a.Options.Secure {
-			acl += ",secure"
+		options = append(options, "sec="+strings.Join(modes, ":"))
+	} else {
+		options = append(options, "sec=sys")
+	}
+	if a.Options.ReadOnly {
+		options = append(options, "ro")
+	} else {
+		options = append(options, "rw")
+	}
+	if a.Options.NoRootSquash {
+		options = append(options, "no_root_squash")
+	} else {
+		options = append(options, "root_squash")
+	}
+	if a.Options.NoHide && !a.Options.CrossMount {
+		options = append(options, "nohide")
+	}
+	if a.Options.CrossMount {
+		options = append(options, "crossmnt")
+	}
+	if a.Options.SetFSID {
+		options = append(options, "fsid=1")
+	}
+	if a.Options.Secure {
+		options = append(options, "secure")
+	}
+	if a.Options.Async {
+		options = append(options, "async")
+	} else {
+		options = append(options, "sync")
+	}
+	if a.Options.NoSubtreeCheck {
+		options = append(options, "no_subtree_check")
+	} else {
+		options = append(options, "subtree_check")
+	}
+	if a.Options.AnonUID != nil {
+		options = append(options, "anonuid="+strconv.Itoa(*a.Options.AnonUID))
+	}
+	if a.Options.AnonGID != nil {
+		options = append(options, "anongid="+strconv.Itoa(*a.Options.AnonGID))
+	}
+	return a.Host + "(" + strings.Join(options, ",") + ")"
+}
+
+//RenderExportAcls returns the ACLs in exports syntax, separated by spaces
+func RenderExportAcls(acls []ExportAcl) string {
+	rendered := []string{}
+	for _, a := range acls {
+		rendered = append(rendered, a.String())
+	}
+	return strings.Join(rendered, " ")
+}
+
+//ParseExportAcl parses an ACL in exports syntax, as "<host>(<options>)" or "<host>"
+//Host is an IP address, a CIDR, a host name or a pattern (cf. exports man page); the options not given take
+//their default values, read-write with root squashing, sec=sys, sync and no_subtree_check
+func ParseExportAcl(acl string) (*ExportAcl, error) {
+	acl = strings.TrimSpace(acl)
+	host := acl
+	var options []string
+	if pos := strings.Index(acl, "("); pos != -1 {
+		if !strings.HasSuffix(acl, ")") {
+			return nil, fmt.Errorf("invalid ACL '%s': unterminated options", acl)
 		}
-		if a.Options.Async {
-			acl += ",async"
-		} else {
-			acl += ",sync"
+		host = acl[:pos]
+		if list := acl[pos+1 : len(acl)-1]; list != "" {
+			options = strings.Split(list, ",")
 		}
-		if a.Options.NoSubtreeCheck {
-			acl += ",no_subtree_check"
-		} else {
-			acl += ",subtree_check"
+	}
+	if !hostPattern.MatchString(host) {
+		return nil, fmt.Errorf("invalid ACL '%s': invalid host", acl)
+	}
+
+	result := ExportAcl{
+		Host:    host,
+		Options: ExportOptions{NoSubtreeCheck: true},
+	}
+	for _, option := range options {
+		name, value := option, ""
+		if pos := strings.Index(option, "="); pos != -1 {
+			name, value = option[:pos], option[pos+1:]
 		}
-		if a.Options.AnonUID > 0 {
-			acl += ",anonuid=" + strconv.Itoa(a.Options.AnonUID)
+		var err error
+		switch name {
+		case "ro":
+			result.Options.ReadOnly = true
+		case "rw":
+			result.Options.ReadOnly = false
+		case "root_squash":
+			result.Options.NoRootSquash = false
+		case "no_root_squash":
+			result.Options.NoRootSquash = true
+		case "secure":
+			result.Options.Secure = true
+		case "insecure":
+			result.Options.Secure = false
+		case "async":
+			result.Options.Async = true
+		case "sync":
+			result.Options.Async = false
+		case "nohide":
+			result.Options.NoHide = true
+		case "hide":
+			result.Options.NoHide = false
+		case "crossmnt":
+			result.Options.CrossMount = true
+		case "subtree_check":
+			result.Options.NoSubtreeCheck = false
+		case "no_subtree_check":
+			result.Options.NoSubtreeCheck = true
+		case "fsid":
+			// The FSID of the export is managed by SafeScale
+			if !fsidPattern.MatchString(value) {
+				err = fmt.Errorf("invalid fsid")
+			}
+			result.Options.SetFSID = true
+		case "anonuid":
+			result.Options.AnonUID, err = parseID(value)
+		case "anongid":
+			result.Options.AnonGID, err = parseID(value)
+		case "sec":
+			result.SecurityModes = nil
+			for _, n := range strings.Split(value, ":") {
+				var flavor SecurityFlavor.Enum
+				flavor, err = ParseSecurityFlavor(n)
+				if err != nil {
+					break
+				}
+				result.SecurityModes = append(result.SecurityModes, flavor)
+			}
+		default:
+			err = fmt.Errorf("unknown option")
 		}
-		if a.Options.AnonGID > 0 {
-			acl += ",anongid=" + strconv.Itoa(a.Options.AnonGID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACL '%s': invalid option '%s': %s", acl, option, err.Error())
 		}
-		acl += ")"
+	}
+	return &result, nil
+}
 
-		acls += acl + " "
+// parseID parses the value of anonuid or anongid
+func parseID(value string) (*int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	if id < 0 {
+		return nil, fmt.Errorf("negative id")
 	}
-	data := map[string]interface{}{
-		"MountPoint":   s.Path,
-		"AccessRights": strings.TrimSpace(acls),
+	return &id, nil
+}
+
+//ParseExportAcls parses ACLs in exports syntax, separated by spaces
+func ParseExportAcls(acls string) ([]ExportAcl, error) {
+	result := []ExportAcl{}
+	for _, field := range strings.Fields(acls) {
+		acl, err := ParseExportAcl(field)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *acl)
 	}
-	retcode, stdout, stderr, err := executeScript(*s.Server.SshConfig, "nfs_server_path_export.sh", data)
-	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to export a shared directory")
+	return result, nil
 }
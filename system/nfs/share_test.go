@@ -0,0 +1,145 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/system/nfs/SecurityFlavor"
+)
+
+func TestParseExportAcl_Defaults(t *testing.T) {
+	acl, err := ParseExportAcl("10.0.0.0/24")
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.0/24", acl.Host)
+	assert.False(t, acl.Options.ReadOnly)
+	assert.False(t, acl.Options.NoRootSquash)
+	assert.True(t, acl.Options.NoSubtreeCheck)
+	assert.Equal(t, "10.0.0.0/24(sec=sys,rw,root_squash,sync,no_subtree_check)", acl.String())
+}
+
+func TestParseExportAcl_Options(t *testing.T) {
+	acl, err := ParseExportAcl("host1(ro,no_root_squash,sec=krb5i:krb5p,async,anonuid=1000,anongid=1001)")
+	require.Nil(t, err)
+	assert.Equal(t, "host1", acl.Host)
+	assert.True(t, acl.Options.ReadOnly)
+	assert.True(t, acl.Options.NoRootSquash)
+	assert.True(t, acl.Options.Async)
+	assert.Equal(t, []SecurityFlavor.Enum{SecurityFlavor.Krb5i, SecurityFlavor.Krb5p}, acl.SecurityModes)
+	require.NotNil(t, acl.Options.AnonUID)
+	assert.Equal(t, 1000, *acl.Options.AnonUID)
+	require.NotNil(t, acl.Options.AnonGID)
+	assert.Equal(t, 1001, *acl.Options.AnonGID)
+	assert.Equal(t, "host1(sec=krb5i:krb5p,ro,no_root_squash,async,no_subtree_check,anonuid=1000,anongid=1001)", acl.String())
+}
+
+func TestParseExportAcl_AnonRoot(t *testing.T) {
+	// anonuid=0 and anongid=0 map the anonymous account to root, they must be kept
+	acl, err := ParseExportAcl("10.0.0.0/24(anonuid=0,anongid=0)")
+	require.Nil(t, err)
+	require.NotNil(t, acl.Options.AnonUID)
+	assert.Equal(t, 0, *acl.Options.AnonUID)
+	assert.Equal(t, "10.0.0.0/24(sec=sys,rw,root_squash,sync,no_subtree_check,anonuid=0,anongid=0)", acl.String())
+
+	acl, err = ParseExportAcl("10.0.0.0/24")
+	require.Nil(t, err)
+	assert.Nil(t, acl.Options.AnonUID)
+	assert.Nil(t, acl.Options.AnonGID)
+}
+
+func TestParseExportAcl_Invalid(t *testing.T) {
+	invalids := []string{
+		"",
+		"(rw)",
+		"10.0.0.0/24(rw",
+		"10.0.0.0/24(rx)",
+		"10.0.0.0/24(sec=krb6)",
+		"10.0.0.0/24(anonuid=nobody)",
+		"10.0.0.0/24(anonuid=-1)",
+	}
+	for _, i := range invalids {
+		_, err := ParseExportAcl(i)
+		assert.NotNil(t, err, "ACL '%s' should be invalid", i)
+	}
+}
+
+func TestParseExportAcl_HostileHost(t *testing.T) {
+	// The ACLs end up in a shell script run as root
+	hostiles := []string{
+		"`reboot`",
+		"$(reboot)",
+		"${IFS}",
+		"host\"",
+		"host'",
+		"host;reboot",
+		"host|reboot",
+		"host&reboot",
+		"host>/etc/passwd",
+		"host\\",
+		"`reboot`(rw)",
+		"$(reboot)(ro)",
+		"host\"(rw)",
+		"host(fsid=`reboot`)",
+		"host(rw,fsid=$(reboot))",
+		"host(sec=sys:$(reboot))",
+		"host(anonuid=$(id))",
+	}
+	for _, h := range hostiles {
+		_, err := ParseExportAcl(h)
+		assert.NotNil(t, err, "ACL '%s' should be refused", h)
+		_, err = ParseExportAcls("10.0.0.0/24(rw) " + h)
+		assert.NotNil(t, err, "ACLs '%s' should be refused", h)
+	}
+	_, err := ParseExportAcl("host\nreboot")
+	assert.NotNil(t, err)
+
+	valids := []string{"*", "*.example.com", "host-1.example.com", "node?", "10.0.0.1", "10.0.0.0/24", "fe80::1", "2001:db8::/32", "@netgroup"}
+	for _, h := range valids {
+		acl, err := ParseExportAcl(h)
+		require.Nil(t, err, "ACL '%s' should be valid", h)
+		assert.Equal(t, h, acl.Host)
+	}
+}
+
+func TestCheckExport(t *testing.T) {
+	assert.Nil(t, checkExport("/shared/data", ""))
+	assert.Nil(t, checkExport("/shared/my-data_1.0+", "10.0.0.0/24(rw) *(ro)"))
+
+	for _, path := range []string{"", "shared", "/shared data", "/shared$(reboot)", "/shared`reboot`", "/shared\"", "/shared'", "/shared;reboot", "/shared#", "/shared\\"} {
+		assert.NotNil(t, checkExport(path, ""), "path '%s' should be refused", path)
+		_, err := NewShare(Server{}, path)
+		assert.NotNil(t, err, "path '%s' should be refused", path)
+	}
+	assert.NotNil(t, checkExport("/shared", "host;reboot(rw)"))
+}
+
+func TestParseExportAcls(t *testing.T) {
+	acls, err := ParseExportAcls("")
+	require.Nil(t, err)
+	assert.Empty(t, acls)
+	assert.Equal(t, "", RenderExportAcls(acls))
+
+	rendered := "10.0.0.0/24(sec=krb5p,rw,root_squash,sync,no_subtree_check) *(sec=sys,ro,root_squash,sync,no_subtree_check)"
+	acls, err = ParseExportAcls(rendered)
+	require.Nil(t, err)
+	require.Len(t, acls, 2)
+	assert.Equal(t, "*", acls[1].Host)
+	assert.Equal(t, rendered, RenderExportAcls(acls))
+}
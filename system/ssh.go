@@ -17,6 +17,7 @@
 package system
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
@@ -29,12 +30,13 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
-	"syscall"
-	"text/template"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -42,6 +44,7 @@ import (
 	"github.com/CS-SI/SafeScale/utils"
 	"github.com/CS-SI/SafeScale/utils/retry"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 var (
@@ -110,10 +113,12 @@ func IsSCPRetryable(code int) bool {
 
 // SSHConfig helper to manage ssh session
 type SSHConfig struct {
-	User          string
-	Host          string
-	PrivateKey    string
-	Port          int
+	User       string
+	Host       string
+	PrivateKey string
+	Port       int
+	// HostKey is the public SSH host key expected from Host (authorized_keys format); the host is not authenticated if empty
+	HostKey       string
 	LocalPort     int
 	GatewayConfig *SSHConfig
 	cmdTpl        string
@@ -121,10 +126,11 @@ type SSHConfig struct {
 
 // SSHTunnel a SSH tunnel
 type SSHTunnel struct {
-	port      int
-	cmd       *exec.Cmd
-	cmdString string
-	keyFile   *os.File
+	port           int
+	cmd            *exec.Cmd
+	cmdString      string
+	keyFile        *os.File
+	knownHostsFile *os.File
 }
 
 // SSHErrorString returns if possible the string corresponding to SSH execution
@@ -146,6 +152,9 @@ func SCPErrorString(retcode int) string {
 // Close closes ssh tunnel
 func (tunnel *SSHTunnel) Close() error {
 	defer utils.LazyRemove(tunnel.keyFile.Name())
+	if tunnel.knownHostsFile != nil {
+		defer utils.LazyRemove(tunnel.knownHostsFile.Name())
+	}
 
 	// Kills the process of the tunnel
 	err := tunnel.cmd.Process.Kill()
@@ -234,11 +243,25 @@ func createTunnel(cfg *SSHConfig) (*SSHTunnel, error) {
 	if localPort == 0 {
 		localPort, err = getFreePort()
 		if err != nil {
+			_ = utils.LazyRemove(f.Name())
 			return nil, err
 		}
 	}
 
-	options := "-q -oServerAliveInterval=60 -oStrictHostKeyChecking=no -oUserKnownHostsFile=/dev/null -oPubkeyAuthentication=yes -oPasswordAuthentication=no"
+	// The gateway is authenticated with its recorded host key, as for the connections of the pool
+	options := "-q -oServerAliveInterval=60 -oPubkeyAuthentication=yes -oPasswordAuthentication=no"
+	var knownHosts *os.File
+	if cfg.GatewayConfig.HostKey != "" {
+		knownHosts, err = createKnownHostsFile(cfg.GatewayConfig)
+		if err != nil {
+			_ = utils.LazyRemove(f.Name())
+			return nil, err
+		}
+		options += fmt.Sprintf(" -oStrictHostKeyChecking=yes -oUserKnownHostsFile=%s", knownHosts.Name())
+	} else {
+		log.Warnf("no SSH host key recorded for '%s', the gateway is not authenticated", cfg.GatewayConfig.Host)
+		options += " -oStrictHostKeyChecking=no -oUserKnownHostsFile=/dev/null"
+	}
 	cmdString := fmt.Sprintf("ssh -i %s -NL %d:%s:%d %s@%s %s -p %d",
 		f.Name(),
 		localPort,
@@ -253,6 +276,10 @@ func createTunnel(cfg *SSHConfig) (*SSHTunnel, error) {
 	err = cmd.Start()
 	//	err = cmd.Wait()
 	if err != nil {
+		_ = utils.LazyRemove(f.Name())
+		if knownHosts != nil {
+			_ = utils.LazyRemove(knownHosts.Name())
+		}
 		return nil, err
 	}
 
@@ -260,55 +287,127 @@ func createTunnel(cfg *SSHConfig) (*SSHTunnel, error) {
 		time.Sleep(10 * time.Millisecond)
 	}
 	return &SSHTunnel{
-		port:      localPort,
-		cmd:       cmd,
-		cmdString: cmdString,
-		keyFile:   f,
+		port:           localPort,
+		cmd:            cmd,
+		cmdString:      cmdString,
+		keyFile:        f,
+		knownHostsFile: knownHosts,
 	}, nil
 }
 
+// createKnownHostsFile creates a known_hosts file containing only the host key recorded for the host of cfg
+func createKnownHostsFile(cfg *SSHConfig) (*os.File, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key for '%s': %s", cfg.Host, err.Error())
+	}
+	host := cfg.Host
+	if cfg.Port != 0 && cfg.Port != 22 {
+		host = fmt.Sprintf("[%s]:%d", cfg.Host, cfg.Port)
+	}
+	return CreateTempFileFromString(fmt.Sprintf("%s %s", host, ssh.MarshalAuthorizedKey(key)), 0600)
+}
+
+// SSHExitError is the error returned when a remote command exits with a non-zero status.
+// As the ssh client does, a failure of the SSH connection is reported with the exit status 255.
+type SSHExitError struct {
+	status int
+	err    error
+	// Stderr holds the standard error output of the command, if not collected otherwise (see Output)
+	Stderr []byte
+}
+
+func newSSHConnectionError(err error) *SSHExitError {
+	return &SSHExitError{status: 255, err: err}
+}
+
+// Error returns the message of the error
+func (e *SSHExitError) Error() string {
+	return e.err.Error()
+}
+
+// ExitStatus returns the exit status of the remote command, 255 if the connection failed
+func (e *SSHExitError) ExitStatus() int {
+	return e.status
+}
+
+// toSSHExitError converts an error of a SSH session to *SSHExitError when it carries an exit status
+func toSSHExitError(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *SSHExitError:
+		return e
+	case *ssh.ExitError:
+		return &SSHExitError{status: e.ExitStatus(), err: e}
+	case *ssh.ExitMissingError:
+		return newSSHConnectionError(e)
+	}
+	return err
+}
+
 // SSHCommand defines a SSH command
+// The command is run in a session opened on a connection to the host shared with the other commands
+// (see connectionPool).
 type SSHCommand struct {
-	cmd     *exec.Cmd
-	tunnels []*SSHTunnel
-	keyFile *os.File
+	config    *SSHConfig
+	cmdString string
+	withSudo  bool
+	ctx       context.Context
+	conn      *sshConnection
+	session   *ssh.Session
+	started   bool
+	ended     bool
+	done      chan struct{}
 }
 
-func (c *SSHCommand) closeTunnels() error {
-	var err error
-	for _, t := range c.tunnels {
-		err = t.Close()
+// open opens the session of the command if not already done
+func (c *SSHCommand) open() error {
+	if c.session != nil {
+		return nil
+	}
+	if c.ended {
+		return fmt.Errorf("command already executed")
 	}
-	//Tunnels are imbricated only last error is significant
+	conn, session, err := connectionPool.newSession(c.config)
 	if err != nil {
-		log.Printf("closeTunnels: %s\n", reflect.TypeOf(err).String())
+		return err
 	}
-	return err
+	c.conn = conn
+	c.session = session
+	return nil
+}
+
+// remoteCommand returns the command executed by the session; the command itself is sent on the standard input
+// of the shell, which avoids any quoting issue and allows big scripts
+func (c *SSHCommand) remoteCommand() string {
+	if c.withSudo {
+		return "sudo bash"
+	}
+	return "bash"
 }
 
 // Wait waits for the command to exit and waits for any copying to stdin or copying from stdout or stderr to complete.
 // The command must have been started by Start.
 // The returned error is nil if the command runs, has no problems copying stdin, stdout, and stderr, and exits with a zero exit status.
-// If the command fails to run or doesn't complete successfully, the error is of type *ExitError. Other error types may be returned for I/O problems.
-// Wait also waits for the I/O loop copying from c.Stdin into the process's standard input to complete.
+// If the command fails to run or doesn't complete successfully, the error is of type *SSHExitError. Other error types may be returned for I/O problems.
 // Wait releases any resources associated with the cmd.
 func (c *SSHCommand) Wait() error {
-	err := c.cmd.Wait()
-	nerr := c.end()
-	if nerr != nil {
-		log.Warnf("Error waiting for command end: %v", nerr)
+	if !c.started {
+		return fmt.Errorf("command not started")
 	}
-	return err
-
+	err := c.session.Wait()
+	c.end()
+	return toSSHExitError(err)
 }
 
 // Kill kills SSHCommand process and releases any resources associated with the SSHCommand.
 func (c *SSHCommand) Kill() error {
-	err := c.cmd.Process.Kill()
-	nerr := c.end()
-	if nerr != nil {
-		log.Warnf("Error waiting for command end: %v", nerr)
+	if c.session == nil {
+		return fmt.Errorf("command not started")
 	}
+	err := c.session.Signal(ssh.SIGKILL)
+	c.end()
 	return err
 }
 
@@ -316,44 +415,75 @@ func (c *SSHCommand) Kill() error {
 // Wait will close the pipe after seeing the command exit, so most callers need not close the pipe themselves; however, an implication is that it is incorrect to call Wait before all reads from the pipe have completed.
 // For the same reason, it is incorrect to call Run when using StdoutPipe.
 func (c *SSHCommand) StdoutPipe() (io.ReadCloser, error) {
-	return c.cmd.StdoutPipe()
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	pipe, err := c.session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(pipe), nil
 }
 
 // StderrPipe returns a pipe that will be connected to the command's standard error when the command starts.
 // Wait will close the pipe after seeing the command exit, so most callers need not close the pipe themselves; however, an implication is that it is incorrect to call Wait before all reads from the pipe have completed. For the same reason, it is incorrect to use Run when using StderrPipe.
 func (c *SSHCommand) StderrPipe() (io.ReadCloser, error) {
-	return c.cmd.StderrPipe()
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	pipe, err := c.session.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(pipe), nil
 }
 
 // StdinPipe returns a pipe that will be connected to the command's standard input when the command starts.
 // The pipe will be closed automatically after Wait sees the command exit.
 // A caller need only call Close to force the pipe to close sooner.
 // For example, if the command being run will not exit until standard input is closed, the caller must close the pipe.
+// The standard input is used to send the command to the remote host, so it is only available when the command is empty.
 func (c *SSHCommand) StdinPipe() (io.WriteCloser, error) {
-	return c.cmd.StdinPipe()
+	if c.cmdString != "" {
+		return nil, fmt.Errorf("standard input is used to send the command")
+	}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	return c.session.StdinPipe()
 }
 
 // Output runs the command and returns its standard output.
-// Any returned error will usually be of type *ExitError.
-// If c.Stderr was nil, Output populates ExitError.Stderr.
+// Any returned error will usually be of type *SSHExitError.
+// If the standard error was not collected with StderrPipe, Output populates SSHExitError.Stderr.
 func (c *SSHCommand) Output() ([]byte, error) {
-	content, err := c.cmd.Output()
-	nerr := c.end()
-	if nerr != nil {
-		log.Warnf("Error waiting for command end: %v", nerr)
+	if err := c.open(); err != nil {
+		return nil, err
 	}
-	return content, err
+	var stdout, stderr bytes.Buffer
+	c.session.Stdout = &stdout
+	captureErr := c.session.Stderr == nil
+	if captureErr {
+		c.session.Stderr = &stderr
+	}
+	err := c.run()
+	if ee, ok := err.(*SSHExitError); ok && captureErr {
+		ee.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
 }
 
 // CombinedOutput runs the command and returns its combined standard
 // output and standard error.
 func (c *SSHCommand) CombinedOutput() ([]byte, error) {
-	content, err := c.cmd.CombinedOutput()
-	nerr := c.end()
-	if nerr != nil {
-		log.Warnf("Error waiting for command end: %v", nerr)
+	if err := c.open(); err != nil {
+		return nil, err
 	}
-	return content, err
+	var output syncBuffer
+	c.session.Stdout = &output
+	c.session.Stderr = &output
+	err := c.run()
+	return output.Bytes(), err
 }
 
 // Start starts the specified command but does not wait for it to complete.
@@ -361,7 +491,40 @@ func (c *SSHCommand) CombinedOutput() ([]byte, error) {
 // The Wait method will return the exit code and release associated resources
 // once the command exits.
 func (c *SSHCommand) Start() error {
-	return c.cmd.Start()
+	if err := c.open(); err != nil {
+		return err
+	}
+	if c.cmdString != "" {
+		c.session.Stdin = strings.NewReader(c.cmdString + "\n")
+	}
+	err := c.session.Start(c.remoteCommand())
+	if err != nil {
+		c.end()
+		return err
+	}
+	c.started = true
+	if c.ctx != nil {
+		c.done = make(chan struct{})
+		go func() {
+			select {
+			case <-c.ctx.Done():
+				if err := c.session.Signal(ssh.SIGKILL); err != nil {
+					log.Debugf("failed to kill remote command: %v", err)
+				}
+				_ = c.session.Close()
+			case <-c.done:
+			}
+		}()
+	}
+	return nil
+}
+
+// run starts the command and waits for it to complete
+func (c *SSHCommand) run() error {
+	if err := c.Start(); err != nil {
+		return toSSHExitError(err)
+	}
+	return c.Wait()
 }
 
 // Run starts the specified command and waits for it to complete.
@@ -370,59 +533,61 @@ func (c *SSHCommand) Start() error {
 // copying stdin, stdout, and stderr, and exits with a zero exit
 // status.
 //
-// If the command starts but does not complete successfully, the error is of
-// type *ExitError. Other error types may be returned for other situations.
+// If the command starts but does not complete successfully, the retcode is the exit status of
+// the command (255 if the SSH connection failed) and the error is nil. Other error types may be
+// returned for other situations.
 func (c *SSHCommand) Run() (int, string, string, error) {
-	//	err := c.cmd.Run()
-	//	c.end()
-	//	return err
-
-	// Set up the outputs (std and err)
-	stdOut, err := c.StdoutPipe()
-	if err != nil {
-		return 0, "", "", err
+	var stdout, stderr bytes.Buffer
+	err := c.open()
+	if err == nil {
+		c.session.Stdout = &stdout
+		c.session.Stderr = &stderr
+		err = c.run()
 	}
-	stderr, err := c.StderrPipe()
 	if err != nil {
+		if ee, ok := err.(*SSHExitError); ok {
+			return ee.ExitStatus(), stdout.String(), fmt.Sprint(stderr.String(), ee.Error()), nil
+		}
 		return 0, "", "", err
 	}
+	return 0, stdout.String(), stderr.String(), nil
+}
 
-	// Launch the command and wait for its execution
-	if err = c.Start(); err != nil {
-		return 0, "", "", err
+// end releases the session and the connection used by the command
+func (c *SSHCommand) end() {
+	if c.ended {
+		return
 	}
-
-	msgOut, _ := ioutil.ReadAll(stdOut)
-	msgErr, _ := ioutil.ReadAll(stderr)
-
-	err = c.Wait()
-	nerr := c.end()
-	if nerr != nil {
-		log.Warnf("Error waiting for command end: %v", nerr)
+	c.ended = true
+	if c.done != nil {
+		close(c.done)
 	}
-	if err != nil {
-		msgError, retCode, erro := ExtractRetCode(err)
-		if erro != nil {
-			return 0, "", "", err
+	if c.session != nil {
+		err := c.session.Close()
+		if err != nil && err != io.EOF {
+			log.Debugf("Error closing SSH session: %v", err)
 		}
-		return retCode, string(msgOut[:]), fmt.Sprint(string(msgErr[:]), msgError), nil
+		c.conn.release(false)
 	}
+}
 
-	return 0, string(msgOut[:]), string(msgErr[:]), nil
+// syncBuffer is a bytes.Buffer safe for concurrent writes, used to collect standard output and error together
+type syncBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
 
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buffer.Write(p)
 }
 
-func (c *SSHCommand) end() error {
-	err1 := c.closeTunnels()
-	err2 := utils.LazyRemove(c.keyFile.Name())
-	if err1 != nil {
-		log.Printf("closeTunnels() failed: %s\n", reflect.TypeOf(err1).String())
-		return fmt.Errorf("Unable to close ssh tunnels: %s", err1.Error())
-	}
-	if err2 != nil {
-		return fmt.Errorf("Unable to close ssh tunnels: %s", err2.Error())
-	}
-	return nil
+// Bytes returns the content of the buffer
+func (b *syncBuffer) Bytes() []byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buffer.Bytes()
 }
 
 func recCreateTunnels(ssh *SSHConfig, tunnels *[]*SSHTunnel) (*SSHTunnel, error) {
@@ -452,6 +617,8 @@ func recCreateTunnels(ssh *SSHConfig, tunnels *[]*SSHTunnel) (*SSHTunnel, error)
 
 }
 
+// CreateTunnels creates the tunnels (ssh processes) allowing to reach the host of ssh through its gateways
+// The tunnels outlive the process, which is used by 'broker ssh tunnel'; commands and copies don't use them.
 func (ssh *SSHConfig) CreateTunnels() ([]*SSHTunnel, *SSHConfig, error) {
 	var tunnels []*SSHTunnel
 	tunnel, err := recCreateTunnels(ssh, &tunnels)
@@ -476,35 +643,6 @@ func (ssh *SSHConfig) CreateTunnels() ([]*SSHTunnel, *SSHConfig, error) {
 	return tunnels, &sshConfig, nil
 }
 
-func createSSHCmd(sshConfig *SSHConfig, cmdString string, withSudo bool) (string, *os.File, error) {
-	f, err := CreateTempFileFromString(sshConfig.PrivateKey, 0400)
-	if err != nil {
-		return "", nil, fmt.Errorf("Unable to create temporary key file: %s", err.Error())
-	}
-	//defer os.Remove(f.Name())
-	options := "-q -oLogLevel=error -oStrictHostKeyChecking=no -oUserKnownHostsFile=/dev/null -oPubkeyAuthentication=yes -oPasswordAuthentication=no"
-
-	sshCmdString := fmt.Sprintf("ssh -i %s %s -p %d %s@%s",
-		f.Name(),
-		options,
-		sshConfig.Port,
-		sshConfig.User,
-		sshConfig.Host,
-	)
-
-	sudoOpt := ""
-	if withSudo {
-		// tty option is required for some command like ls
-		sudoOpt = " -t sudo"
-	}
-
-	if cmdString != "" {
-		sshCmdString = sshCmdString + fmt.Sprintf("%s bash <<'ENDSSH'\n%s\nENDSSH", sudoOpt, cmdString)
-	}
-	return sshCmdString, f, nil
-
-}
-
 // Command returns the cmd struct to execute cmdString remotely
 func (ssh *SSHConfig) Command(cmdString string) (*SSHCommand, error) {
 	return ssh.command(cmdString, false)
@@ -516,19 +654,11 @@ func (ssh *SSHConfig) SudoCommand(cmdString string) (*SSHCommand, error) {
 }
 
 func (ssh *SSHConfig) command(cmdString string, withSudo bool) (*SSHCommand, error) {
-	tunnels, sshConfig, err := ssh.CreateTunnels()
-	if err != nil {
-		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
-	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, withSudo)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
-	}
-	cmd := exec.Command("bash", "-c", sshCmdString)
+	config := *ssh
 	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: keyFile,
+		config:    &config,
+		cmdString: cmdString,
+		withSudo:  withSudo,
 	}
 	return &sshCommand, nil
 }
@@ -536,7 +666,20 @@ func (ssh *SSHConfig) command(cmdString string, withSudo bool) (*SSHCommand, err
 // WaitServerReady waits until the SSH server is ready
 // the 'timeout' parameter is in minutes
 func (ssh *SSHConfig) WaitServerReady(timeout time.Duration) error {
-	err := retry.WhileUnsuccessfulDelay5Seconds(
+	return ssh.WaitServerReadyUnless(timeout, nil)
+}
+
+// WaitServerReadyUnless waits until the SSH server is ready, giving up as soon as 'aborted' (if not nil) returns
+// an error, which is then returned
+func (ssh *SSHConfig) WaitServerReadyUnless(timeout time.Duration, aborted func() error) error {
+	arbiters := []retry.Arbiter{retry.Unsuccessful()}
+	if timeout > 0 {
+		arbiters = append(arbiters, retry.Timeout(timeout))
+	}
+	if aborted != nil {
+		arbiters = append(arbiters, retry.Abortable(aborted))
+	}
+	err := retry.Action(
 		func() error {
 			cmd, err := ssh.Command("sudo cat /var/tmp/user_data.done")
 			if err != nil {
@@ -555,9 +698,14 @@ func (ssh *SSHConfig) WaitServerReady(timeout time.Duration) error {
 			}
 			return nil
 		},
-		timeout,
+		retry.PrevailDone(arbiters...), retry.Constant(5*time.Second), nil, nil, nil,
 	)
 	if err != nil {
+		if aborted != nil {
+			if abortErr := aborted(); abortErr != nil {
+				return abortErr
+			}
+		}
 		originalErr := err
 		logCmd, err := ssh.Command("sudo cat /var/tmp/user_data.log")
 		if err != nil {
@@ -577,195 +725,314 @@ func (ssh *SSHConfig) WaitServerReady(timeout time.Duration) error {
 	return nil
 }
 
-// Copy copy a file/directory from/to local to/from remote
+// Copy copy a file from/to local to/from remote
+// The file is transferred with the scp protocol, the returned retcode follows the exit codes of scp
 func (ssh *SSHConfig) Copy(remotePath, localPath string, isUpload bool) (int, string, string, error) {
-	tunnels, sshConfig, err := ssh.CreateTunnels()
-	if err != nil {
-		return 0, "", "", fmt.Errorf("Unable to create tunnels : %s", err.Error())
+	return scpCopy(ssh, remotePath, localPath, isUpload)
+}
+
+// scpRemoteError is an error reported by the remote scp
+type scpRemoteError string
+
+func (e scpRemoteError) Error() string {
+	return string(e)
+}
+
+func scpCopy(cfg *SSHConfig, remotePath, localPath string, isUpload bool) (int, string, string, error) {
+	var (
+		file *os.File
+		info os.FileInfo
+		err  error
+	)
+	if isUpload {
+		file, err = os.Open(localPath)
+		if err != nil {
+			return 0, "", "", err
+		}
+		defer file.Close()
+		info, err = file.Stat()
+		if err != nil {
+			return 0, "", "", err
+		}
+		if !info.Mode().IsRegular() {
+			return 0, "", "", fmt.Errorf("'%s' is not a regular file", localPath)
+		}
 	}
 
-	identityfile, err := CreateTempFileFromString(sshConfig.PrivateKey, 0400)
+	conn, session, err := connectionPool.newSession(cfg)
 	if err != nil {
-		return 0, "", "", fmt.Errorf("Unable to create temporary key file: %s", err.Error())
+		if ee, ok := err.(*SSHExitError); ok {
+			// 4: Connecting to host failed
+			return 4, "", ee.Error(), nil
+		}
+		return 0, "", "", err
 	}
+	defer func() {
+		_ = session.Close()
+		conn.release(false)
+	}()
 
-	cmdTemplate, err := template.New("Command").Parse("scp -i {{.IdentityFile}} -P {{.Port}} {{.Options}} {{if .IsUpload}}{{.LocalPath}} {{.User}}@{{.Host}}:{{.RemotePath}}{{else}}{{.User}}@{{.Host}}:{{.RemotePath}} {{.LocalPath}}{{end}}")
+	stdin, err := session.StdinPipe()
 	if err != nil {
-		return 0, "", "", fmt.Errorf("Error parsing command template: %s", err.Error())
+		return 0, "", "", err
 	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return 0, "", "", err
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
 
-	var copyCommand bytes.Buffer
-	if err := cmdTemplate.Execute(&copyCommand, struct {
-		IdentityFile string
-		Port         int
-		Options      string
-		User         string
-		Host         string
-		RemotePath   string
-		LocalPath    string
-		IsUpload     bool
-	}{
-		IdentityFile: identityfile.Name(),
-		Port:         sshConfig.Port,
-		Options:      "-q -oLogLevel=error -oStrictHostKeyChecking=no -oUserKnownHostsFile=/dev/null -oPubkeyAuthentication=yes -oPasswordAuthentication=no",
-		User:         sshConfig.User,
-		Host:         sshConfig.Host,
-		RemotePath:   remotePath,
-		LocalPath:    localPath,
-		IsUpload:     isUpload,
-	}); err != nil {
-		return 0, "", "", fmt.Errorf("Error executing template: %s", err.Error())
+	command := "scp -f " + remotePath
+	if isUpload {
+		command = "scp -t " + remotePath
+	}
+	err = session.Start(command)
+	if err != nil {
+		return 0, "", "", err
+	}
+	reader := bufio.NewReader(stdout)
+	if isUpload {
+		err = scpSend(stdin, reader, file, info)
+	} else {
+		err = scpReceive(stdin, reader, localPath)
 	}
+	_ = stdin.Close()
+	werr := toSSHExitError(session.Wait())
 
-	sshCmdString := copyCommand.String()
-	cmd := exec.Command("bash", "-c", sshCmdString)
-	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: identityfile,
+	if rerr, ok := err.(scpRemoteError); ok {
+		// 1: General error in file copy
+		return 1, "", fmt.Sprint(stderr.String(), rerr.Error()), nil
+	}
+	if ee, ok := werr.(*SSHExitError); ok {
+		retcode := ee.ExitStatus()
+		if retcode == 255 {
+			// 5: Connection broken
+			retcode = 5
+		}
+		return retcode, "", fmt.Sprint(stderr.String(), ee.Error()), nil
 	}
+	if werr != nil {
+		return 0, "", "", werr
+	}
+	if err != nil {
+		return 0, "", "", err
+	}
+	return 0, "", stderr.String(), nil
+}
 
-	return sshCommand.Run()
+// scpAck reads the acknowledgment of the remote scp
+func scpAck(r *bufio.Reader) error {
+	code, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if code == 0 {
+		return nil
+	}
+	msg, _ := r.ReadString('\n')
+	return scpRemoteError(strings.TrimSpace(msg))
 }
 
-// Exec executes the cmd using ssh
-func (ssh *SSHConfig) Exec(cmdString string) error {
-	tunnels, sshConfig, err := ssh.CreateTunnels()
+// scpSend sends the content of file to the remote scp (started with -t)
+func scpSend(w io.Writer, r *bufio.Reader, file *os.File, info os.FileInfo) error {
+	err := scpAck(r)
 	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				log.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
-		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		return err
 	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, false)
+	_, err = fmt.Fprintf(w, "C%04o %d %s\n", info.Mode().Perm(), info.Size(), filepath.Base(file.Name()))
 	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				log.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
-		}
-		if keyFile != nil {
-			nerr := utils.LazyRemove(keyFile.Name())
-			if nerr != nil {
-				log.Warnf("Error removing file %v", nerr)
-			}
-		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		return err
 	}
-	bash, err := exec.LookPath("bash")
+	err = scpAck(r)
 	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				log.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
-		}
-		if keyFile != nil {
-			nerr := utils.LazyRemove(keyFile.Name())
-			if nerr != nil {
-				log.Warnf("Error removing file %v", nerr)
-			}
-		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		return err
 	}
-	var args []string
-	if cmdString == "" {
-		args = []string{sshCmdString}
-	} else {
-		args = []string{"-c", sshCmdString}
+	n, err := io.Copy(w, file)
+	if err != nil {
+		return err
 	}
-	err = syscall.Exec(bash, args, nil)
-	nerr := utils.LazyRemove(keyFile.Name())
-	if nerr != nil {
+	if n != info.Size() {
+		return fmt.Errorf("'%s' changed during copy", file.Name())
 	}
-	return err
+	_, err = w.Write([]byte{0})
+	if err != nil {
+		return err
+	}
+	return scpAck(r)
 }
 
-// Enter Enter to interactive shell
-func (ssh *SSHConfig) Enter() error {
-	tunnels, sshConfig, err := ssh.CreateTunnels()
+// scpReceive receives a file from the remote scp (started with -f) and writes it to localPath
+// if localPath is a directory, the file is written in it with its remote name
+func scpReceive(w io.Writer, r *bufio.Reader, localPath string) error {
+	_, err := w.Write([]byte{0})
 	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				log.Warnf("Error closing ssh tunnel: %v", nerr)
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return fmt.Errorf("unexpected empty scp message")
+		}
+		switch line[0] {
+		case 1, 2:
+			return scpRemoteError(line[1:])
+		case 'T':
+			// Times of the file, not kept
+			_, err = w.Write([]byte{0})
+			if err != nil {
+				return err
 			}
+			continue
+		case 'C':
+		default:
+			return fmt.Errorf("unexpected scp message '%s'", line)
 		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
-	}
 
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, "", false)
-	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				log.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
+		fields := strings.SplitN(line[1:], " ", 3)
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid scp message '%s'", line)
 		}
-		if keyFile != nil {
-			nerr := utils.LazyRemove(keyFile.Name())
-			if nerr != nil {
-				log.Warnf("Error removing file %v", nerr)
-			}
+		mode, err := strconv.ParseUint(fields[0], 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid mode in scp message '%s'", line)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid size in scp message '%s'", line)
+		}
+		path := localPath
+		if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+			path = filepath.Join(localPath, filepath.Base(fields[2]))
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(mode))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte{0})
+		if err == nil {
+			_, err = io.CopyN(file, r, size)
+		}
+		cerr := file.Close()
+		if err != nil {
+			return err
 		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		if cerr != nil {
+			return cerr
+		}
+		err = scpAck(r)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte{0})
+		return err
 	}
+}
+
+// Exec executes the cmd using ssh, attached to the standard input and outputs of the process
+// If cmdString is empty, an interactive shell is started.
+func (ssh *SSHConfig) Exec(cmdString string) error {
+	return runInteractive(ssh, cmdString)
+}
+
+// Enter Enter to interactive shell
+func (ssh *SSHConfig) Enter() error {
+	return runInteractive(ssh, "")
+}
 
-	bash, err := exec.LookPath("bash")
+// runInteractive runs cmdString (or a shell if empty) attached to the standard input and outputs of the process,
+// with a pseudo-terminal if the standard input is a terminal
+func runInteractive(cfg *SSHConfig, cmdString string) error {
+	conn, session, err := connectionPool.newSession(cfg)
 	if err != nil {
-		for _, t := range tunnels {
-			nerr := t.Close()
-			if nerr != nil {
-				log.Warnf("Error closing ssh tunnel: %v", nerr)
-			}
+		return err
+	}
+	defer func() {
+		_ = session.Close()
+		conn.release(false)
+	}()
+
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return err
 		}
-		if keyFile != nil {
-			nerr := utils.LazyRemove(keyFile.Name())
-			if nerr != nil {
-				log.Warnf("Error removing file %v", nerr)
-			}
+		defer func() {
+			_ = terminal.Restore(fd, state)
+		}()
+
+		width, height, err := terminal.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
 		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		term := os.Getenv("TERM")
+		if term == "" {
+			term = "xterm"
+		}
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		err = session.RequestPty(term, height, width, modes)
+		if err != nil {
+			return err
+		}
+
+		// Forwards the changes of the size of the terminal
+		resize := make(chan os.Signal, 1)
+		done := make(chan struct{})
+		notifyResize(resize)
+		defer func() {
+			signal.Stop(resize)
+			close(done)
+		}()
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				case <-resize:
+					if width, height, err := terminal.GetSize(fd); err == nil {
+						_ = session.WindowChange(height, width)
+					}
+				}
+			}
+		}()
 	}
 
-	proc := exec.Command(bash, "-c", sshCmdString)
-	proc.Stdin = os.Stdin
-	proc.Stdout = os.Stdout
-	proc.Stderr = os.Stderr
-	err = proc.Run()
-	nerr := utils.LazyRemove(keyFile.Name())
-	if nerr != nil {
-		log.Warnf("Error removing file %v", nerr)
+	if cmdString == "" {
+		err = session.Shell()
+	} else {
+		err = session.Start(cmdString)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return toSSHExitError(session.Wait())
 }
 
 // CommandContext is like Command but includes a context.
 //
-// The provided context is used to kill the process (by calling
-// os.Process.Kill) if the context becomes done before the command
-// completes on its own.
+// The provided context is used to kill the remote command (and to close
+// its session) if the context becomes done before the command completes
+// on its own.
 func (ssh *SSHConfig) CommandContext(ctx context.Context, cmdString string) (*SSHCommand, error) {
-	tunnels, sshConfig, err := ssh.CreateTunnels()
+	sshCommand, err := ssh.command(cmdString, false)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
-	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, false)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
-	}
-
-	cmd := exec.CommandContext(ctx, "bash", "-c", sshCmdString)
-	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: keyFile,
+		return nil, err
 	}
-	return &sshCommand, nil
+	sshCommand.ctx = ctx
+	return sshCommand, nil
 }
 
 // CreateKeyPair creates a key pair
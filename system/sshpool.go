@@ -0,0 +1,459 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// errHostKeyScanned stops the handshake once the host key is received (see ScanHostKey)
+	errHostKeyScanned = errors.New("host key scanned")
+)
+
+const (
+	// sshMaxSessionsPerConnection stays under the default MaxSessions (10) of OpenSSH servers
+	sshMaxSessionsPerConnection = 8
+	// sshConnectTimeout is the maximum duration to establish a SSH connection (TCP connection and handshake)
+	sshConnectTimeout = 30 * time.Second
+	// sshKeepAliveInterval is the interval between 2 keepalive requests on an open connection
+	sshKeepAliveInterval = 60 * time.Second
+	// sshIdleTimeout is the duration after which a connection without session is closed
+	sshIdleTimeout = 2 * time.Minute
+)
+
+// sshConnection is a SSH connection to a host, shared by the sessions opened to this host and by the connections
+// to other hosts using it as gateway
+type sshConnection struct {
+	pool    *sshPool
+	key     string
+	client  *ssh.Client
+	gateway *sshConnection
+	// sessions is the number of sessions in use
+	sessions int
+	// forwards is the number of connections to other hosts established through this one
+	forwards int
+	idle     *time.Timer
+	closed   bool
+	done     chan struct{}
+}
+
+// sshPool keeps the SSH connections open to be reused by the next commands
+type sshPool struct {
+	lock        sync.Mutex
+	connections map[string][]*sshConnection
+	dialing     map[string]*sync.Mutex
+}
+
+// connectionPool is the pool of the SSH connections of the process
+var connectionPool = newSSHPool()
+
+func newSSHPool() *sshPool {
+	return &sshPool{
+		connections: map[string][]*sshConnection{},
+		dialing:     map[string]*sync.Mutex{},
+	}
+}
+
+// poolKey identifies the connections which can be shared: same user, host, port, key and gateways
+func (cfg *SSHConfig) poolKey() string {
+	hash := sha256.Sum256([]byte(cfg.PrivateKey))
+	hostKey := sha256.Sum256([]byte(cfg.HostKey))
+	key := fmt.Sprintf("%s@%s:%d/%s/%s", cfg.User, cfg.Host, cfg.Port, hex.EncodeToString(hash[:8]), hex.EncodeToString(hostKey[:8]))
+	if cfg.GatewayConfig != nil {
+		key += "|" + cfg.GatewayConfig.poolKey()
+	}
+	return key
+}
+
+// clientConfig returns the configuration of the SSH client for cfg
+// The host key presented by the host must be cfg.HostKey, otherwise the connection fails with *SSHHostKeyError.
+func (cfg *SSHConfig) clientConfig() (*ssh.ClientConfig, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(cfg.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key for '%s@%s': %s", cfg.User, cfg.Host, err.Error())
+	}
+	config := &ssh.ClientConfig{
+		User:    cfg.User,
+		Auth:    []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		Timeout: sshConnectTimeout,
+	}
+	if cfg.HostKey == "" {
+		log.Warnf("SSH host key of '%s' unknown, the host is not authenticated (see 'broker host rekey')", cfg.Host)
+		config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		return config, nil
+	}
+	expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH host key for '%s': %s", cfg.Host, err.Error())
+	}
+	config.HostKeyAlgorithms = hostKeyAlgorithms(expected)
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if !bytes.Equal(key.Marshal(), expected.Marshal()) {
+			return &SSHHostKeyError{
+				Host:        cfg.Host,
+				Expected:    ssh.FingerprintSHA256(expected),
+				Fingerprint: ssh.FingerprintSHA256(key),
+			}
+		}
+		return nil
+	}
+	return config, nil
+}
+
+// hostKeyAlgorithms returns the algorithms to negotiate to receive the host key of the type of key
+func hostKeyAlgorithms(key ssh.PublicKey) []string {
+	if key.Type() == ssh.KeyAlgoRSA {
+		// RSA keys may be used with SHA-2 signatures (required by recent servers) or SHA-1
+		return []string{"rsa-sha2-512", "rsa-sha2-256", ssh.KeyAlgoRSA}
+	}
+	return []string{key.Type()}
+}
+
+// SSHHostKeyError is returned when a host presents a SSH host key different from the one expected
+type SSHHostKeyError struct {
+	Host        string
+	Expected    string
+	Fingerprint string
+}
+
+// Error returns the message of the error
+func (e *SSHHostKeyError) Error() string {
+	return fmt.Sprintf("SSH host key of '%s' has changed (expected %s, got %s): someone could be eavesdropping (man-in-the-middle attack); if the change is legitimate, accept the new key with 'broker host rekey'",
+		e.Host, e.Expected, e.Fingerprint)
+}
+
+// ScanHostKey returns the SSH host key presented by the host of cfg (authorized_keys format), connecting through
+// the gateways of cfg (which are authenticated); the host itself is not authenticated.
+func (cfg *SSHConfig) ScanHostKey() (string, error) {
+	var (
+		lock    sync.Mutex
+		scanned ssh.PublicKey
+	)
+	config := &ssh.ClientConfig{
+		User:    cfg.User,
+		Timeout: sshConnectTimeout,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			lock.Lock()
+			defer lock.Unlock()
+			scanned = key
+			// Stops the handshake, authentication is not needed
+			return errHostKeyScanned
+		},
+	}
+	if cfg.HostKey != "" {
+		if expected, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey)); err == nil {
+			config.HostKeyAlgorithms = hostKeyAlgorithms(expected)
+		}
+	}
+	conn, gateway, err := connectionPool.connect(cfg)
+	if err != nil {
+		return "", err
+	}
+	if gateway != nil {
+		defer gateway.release(true)
+	}
+	_ = conn.SetDeadline(time.Now().Add(sshConnectTimeout))
+	clientConn, _, _, err := ssh.NewClientConn(conn, conn.RemoteAddr().String(), config)
+	if err == nil {
+		_ = clientConn.Close()
+	} else {
+		_ = conn.Close()
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if scanned == nil {
+		if err == nil {
+			err = fmt.Errorf("no host key received")
+		}
+		return "", newSSHConnectionError(fmt.Errorf("failed to get SSH host key of '%s': %s", cfg.Host, err.Error()))
+	}
+	return string(ssh.MarshalAuthorizedKey(scanned)), nil
+}
+
+// CheckConnection opens a new SSH connection to the host of cfg, instead of using the connections already open, to
+// check the host still accepts connections (after a change of its firewall for example)
+func (cfg *SSHConfig) CheckConnection() error {
+	c, err := connectionPool.dial(cfg, cfg.poolKey())
+	if err != nil {
+		return err
+	}
+	c.discard()
+	return nil
+}
+
+// HostKeyFingerprint returns the SHA256 fingerprint of a SSH host key in authorized_keys format, as displayed by ssh
+func HostKeyFingerprint(hostKey string) (string, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintSHA256(key), nil
+}
+
+// keyLock returns the mutex serializing the connections to the same host, preventing concurrent commands
+// to open a connection each
+func (p *sshPool) keyLock(key string) *sync.Mutex {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	lock, ok := p.dialing[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.dialing[key] = lock
+	}
+	return lock
+}
+
+// acquire returns a connection to the host of cfg, opening it if needed
+// if 'forward' is true, the connection is used to reach another host, otherwise it is used to open a session
+func (p *sshPool) acquire(cfg *SSHConfig, forward bool) (*sshConnection, error) {
+	key := cfg.poolKey()
+	lock := p.keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	p.lock.Lock()
+	for _, c := range p.connections[key] {
+		if forward || c.sessions < sshMaxSessionsPerConnection {
+			c.use(forward)
+			p.lock.Unlock()
+			return c, nil
+		}
+	}
+	p.lock.Unlock()
+
+	c, err := p.dial(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	c.use(forward)
+	p.connections[key] = append(p.connections[key], c)
+	p.lock.Unlock()
+	return c, nil
+}
+
+// dial opens a connection to the host of cfg, through its gateways if any
+func (p *sshPool) dial(cfg *SSHConfig, key string) (*sshConnection, error) {
+	clientConfig, err := cfg.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	// The error of the host key verification is wrapped by the handshake, keeps it to return it as is
+	var hostKeyErr *SSHHostKeyError
+	if callback := clientConfig.HostKeyCallback; callback != nil {
+		clientConfig.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			err := callback(hostname, remote, key)
+			if e, ok := err.(*SSHHostKeyError); ok {
+				hostKeyErr = e
+			}
+			return err
+		}
+	}
+
+	conn, gateway, err := p.connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	addr := conn.RemoteAddr().String()
+
+	// Not supported by connections through a gateway, the handshake is then bounded by the keepalive of the gateway
+	_ = conn.SetDeadline(time.Now().Add(sshConnectTimeout))
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	if err != nil {
+		_ = conn.Close()
+		if gateway != nil {
+			gateway.release(true)
+		}
+		if hostKeyErr != nil {
+			// Not a connection failure, retrying is useless
+			return nil, hostKeyErr
+		}
+		return nil, newSSHConnectionError(fmt.Errorf("failed to establish SSH connection to '%s': %s", addr, err.Error()))
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	c := &sshConnection{
+		pool:    p,
+		key:     key,
+		client:  ssh.NewClient(clientConn, chans, reqs),
+		gateway: gateway,
+		done:    make(chan struct{}),
+	}
+	go c.monitor()
+	return c, nil
+}
+
+// connect opens a TCP connection to the host of cfg, through its gateways if any
+// The connection to the gateway, if any, is returned and must be released when the TCP connection is closed.
+func (p *sshPool) connect(cfg *SSHConfig) (net.Conn, *sshConnection, error) {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	if cfg.GatewayConfig == nil {
+		conn, err := net.DialTimeout("tcp", addr, sshConnectTimeout)
+		if err != nil {
+			return nil, nil, newSSHConnectionError(fmt.Errorf("failed to connect to '%s': %s", addr, err.Error()))
+		}
+		return conn, nil, nil
+	}
+
+	gateway, err := p.acquire(cfg.GatewayConfig, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := gateway.client.Dial("tcp", addr)
+	if err != nil {
+		gateway.release(true)
+		return nil, nil, newSSHConnectionError(fmt.Errorf("failed to connect to '%s' through gateway '%s': %s", addr, cfg.GatewayConfig.Host, err.Error()))
+	}
+	return conn, gateway, nil
+}
+
+// newSession opens a session on a connection to the host of cfg
+// A connection found broken is discarded and a new one is opened.
+func (p *sshPool) newSession(cfg *SSHConfig) (*sshConnection, *ssh.Session, error) {
+	var lastErr error
+	for i := 0; i < 2; i++ {
+		c, err := p.acquire(cfg, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		session, err := c.client.NewSession()
+		if err == nil {
+			return c, session, nil
+		}
+		log.Debugf("failed to open SSH session on '%s', reconnecting: %s", cfg.Host, err.Error())
+		c.release(false)
+		c.discard()
+		lastErr = err
+	}
+	return nil, nil, newSSHConnectionError(fmt.Errorf("failed to open SSH session on '%s': %s", cfg.Host, lastErr.Error()))
+}
+
+// use registers a new user of the connection; must be called with the pool locked
+func (c *sshConnection) use(forward bool) {
+	if forward {
+		c.forwards++
+	} else {
+		c.sessions++
+	}
+	if c.idle != nil {
+		c.idle.Stop()
+		c.idle = nil
+	}
+}
+
+// release unregisters a user of the connection, which is closed after sshIdleTimeout without user
+func (c *sshConnection) release(forward bool) {
+	c.pool.lock.Lock()
+	defer c.pool.lock.Unlock()
+
+	if forward {
+		c.forwards--
+	} else {
+		c.sessions--
+	}
+	if c.sessions == 0 && c.forwards == 0 && !c.closed {
+		c.idle = time.AfterFunc(sshIdleTimeout, c.expire)
+	}
+}
+
+// expire closes the connection if it is still unused
+func (c *sshConnection) expire() {
+	c.shutdown(true)
+}
+
+// discard removes the connection from the pool and closes it
+func (c *sshConnection) discard() {
+	c.shutdown(false)
+}
+
+// shutdown removes the connection from the pool and closes it; if 'idleOnly' is set, the connection is kept
+// when used again in the meantime. The use of the connection is checked in the same critical section as its
+// removal, so acquire() can't pick a connection being closed.
+func (c *sshConnection) shutdown(idleOnly bool) {
+	p := c.pool
+	p.lock.Lock()
+	if c.closed || (idleOnly && (c.sessions != 0 || c.forwards != 0)) {
+		p.lock.Unlock()
+		return
+	}
+	c.closed = true
+	if c.idle != nil {
+		c.idle.Stop()
+		c.idle = nil
+	}
+	list := p.connections[c.key]
+	for i, item := range list {
+		if item == c {
+			p.connections[c.key] = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(p.connections[c.key]) == 0 {
+		delete(p.connections, c.key)
+	}
+	p.lock.Unlock()
+
+	close(c.done)
+	err := c.client.Close()
+	if err != nil {
+		log.Debugf("failed to close SSH connection '%s': %s", c.key, err.Error())
+	}
+	if c.gateway != nil {
+		c.gateway.release(true)
+	}
+}
+
+// monitor discards the connection when it is closed by the remote host, or when it doesn't answer keepalive requests
+func (c *sshConnection) monitor() {
+	closed := make(chan struct{})
+	go func() {
+		_ = c.client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(sshKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-closed:
+			c.discard()
+			return
+		case <-ticker.C:
+			_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
+			if err != nil {
+				log.Debugf("SSH connection '%s' lost: %s", c.key, err.Error())
+				c.discard()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,442 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is a SSH server running the commands locally
+type testSSHServer struct {
+	listener    net.Listener
+	config      *ssh.ServerConfig
+	hostKey     string
+	lock        sync.Mutex
+	connections int
+}
+
+func newTestSSHServer(t *testing.T, clientKey string) *testSSHServer {
+	_, hostKey, err := CreateKeyPair()
+	require.Nil(t, err)
+	hostSigner, err := ssh.ParsePrivateKey(hostKey)
+	require.Nil(t, err)
+	clientSigner, err := ssh.ParsePrivateKey([]byte(clientKey))
+	require.Nil(t, err)
+	authorized := string(clientSigner.PublicKey().Marshal())
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == authorized {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	s := &testSSHServer{
+		listener: listener,
+		config:   config,
+		hostKey:  string(ssh.MarshalAuthorizedKey(hostSigner.PublicKey())),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *testSSHServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testSSHServer) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connections
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.connections++
+		s.lock.Unlock()
+		go func() {
+			_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+			if err != nil {
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			for newChannel := range chans {
+				switch newChannel.ChannelType() {
+				case "session":
+					go s.session(newChannel)
+				case "direct-tcpip":
+					go s.forward(newChannel)
+				default:
+					_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+				}
+			}
+		}()
+	}
+}
+
+func (s *testSSHServer) session(newChannel ssh.NewChannel) {
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	for req := range reqs {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		_ = ssh.Unmarshal(req.Payload, &payload)
+		_ = req.Reply(true, nil)
+
+		cmd := exec.Command("sh", "-c", payload.Command)
+		cmd.Stdin = channel
+		cmd.Stdout = channel
+		cmd.Stderr = channel.Stderr()
+		status := 0
+		if err := cmd.Run(); err != nil {
+			status = 1
+			if ee, ok := err.(*exec.ExitError); ok {
+				status = ee.Sys().(syscall.WaitStatus).ExitStatus()
+			}
+		}
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
+
+func (s *testSSHServer) forward(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	_ = ssh.Unmarshal(newChannel.ExtraData(), &payload)
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		_ = target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(target, channel)
+		_ = target.Close()
+	}()
+	_, _ = io.Copy(channel, target)
+	_ = channel.Close()
+}
+
+func TestSSHCommand(t *testing.T) {
+	_, key, err := CreateKeyPair()
+	require.Nil(t, err)
+	server := newTestSSHServer(t, string(key))
+	defer server.listener.Close()
+
+	cfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: server.port(), PrivateKey: string(key)}
+
+	cmd, err := cfg.Command("echo hello\necho error >&2\nexit 3")
+	require.Nil(t, err)
+	retcode, stdout, stderr, err := cmd.Run()
+	require.Nil(t, err)
+	assert.Equal(t, 3, retcode)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Contains(t, stderr, "error")
+
+	// CheckConnection opens a new connection
+	connections := server.count()
+	require.Nil(t, cfg.CheckConnection())
+	assert.Equal(t, connections+1, server.count())
+
+	cmd, err = cfg.Command("echo hello")
+	require.Nil(t, err)
+	out, err := cmd.Output()
+	require.Nil(t, err)
+	assert.Equal(t, "hello\n", string(out))
+
+	cmd, err = cfg.Command("echo failure >&2; exit 2")
+	require.Nil(t, err)
+	_, err = cmd.Output()
+	require.NotNil(t, err)
+	msg, retcode, err := ExtractRetCode(err)
+	require.Nil(t, err, msg)
+	assert.Equal(t, 2, retcode)
+
+	// Concurrent commands share the connections, up to sshMaxSessionsPerConnection sessions each
+	var wg sync.WaitGroup
+	for i := 0; i < 2*sshMaxSessionsPerConnection; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd, err := cfg.Command(fmt.Sprintf("sleep 0.2; echo %d", i))
+			assert.Nil(t, err)
+			retcode, stdout, _, err := cmd.Run()
+			assert.Nil(t, err)
+			assert.Equal(t, 0, retcode)
+			assert.Equal(t, fmt.Sprintf("%d\n", i), stdout)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, connections+2, server.count())
+}
+
+func TestSSHCommandThroughGateway(t *testing.T) {
+	_, key, err := CreateKeyPair()
+	require.Nil(t, err)
+	gateway := newTestSSHServer(t, string(key))
+	defer gateway.listener.Close()
+	host := newTestSSHServer(t, string(key))
+	defer host.listener.Close()
+
+	gatewayCfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: gateway.port(), PrivateKey: string(key)}
+	hostCfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: host.port(), PrivateKey: string(key), GatewayConfig: gatewayCfg}
+	for i := 0; i < 3; i++ {
+		cmd, err := hostCfg.Command("echo hello")
+		require.Nil(t, err)
+		out, err := cmd.Output()
+		require.Nil(t, err)
+		assert.Equal(t, "hello\n", string(out))
+	}
+	assert.Equal(t, 1, gateway.count())
+	assert.Equal(t, 1, host.count())
+}
+
+func TestSSHConnectionFailure(t *testing.T) {
+	_, key, err := CreateKeyPair()
+	require.Nil(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+
+	cfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: port, PrivateKey: string(key)}
+	cmd, err := cfg.Command("echo hello")
+	require.Nil(t, err)
+	retcode, _, _, err := cmd.Run()
+	require.Nil(t, err)
+	assert.Equal(t, 255, retcode)
+	err = cfg.CheckConnection()
+	require.NotNil(t, err)
+	msg, retcode, err := ExtractRetCode(err)
+	require.Nil(t, err, msg)
+	assert.Equal(t, 255, retcode)
+
+	f, err := CreateTempFileFromString("content", 0600)
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	retcode, _, _, err = cfg.Copy(f.Name(), f.Name(), true)
+	require.Nil(t, err)
+	assert.True(t, IsSCPRetryable(retcode))
+}
+
+func TestSSHCopy(t *testing.T) {
+	if _, err := exec.LookPath("scp"); err != nil {
+		t.Skip("scp not available")
+	}
+	_, key, err := CreateKeyPair()
+	require.Nil(t, err)
+	server := newTestSSHServer(t, string(key))
+	defer server.listener.Close()
+	cfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: server.port(), PrivateKey: string(key)}
+
+	dir, err := ioutil.TempDir("", "sshcopy")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	require.Nil(t, ioutil.WriteFile(source, []byte("content"), 0640))
+
+	retcode, _, stderr, err := cfg.Copy(filepath.Join(dir, "remote"), source, true)
+	require.Nil(t, err)
+	require.Equal(t, 0, retcode, stderr)
+	content, err := ioutil.ReadFile(filepath.Join(dir, "remote"))
+	require.Nil(t, err)
+	assert.Equal(t, "content", string(content))
+
+	retcode, _, stderr, err = cfg.Copy(filepath.Join(dir, "remote"), filepath.Join(dir, "local"), false)
+	require.Nil(t, err)
+	require.Equal(t, 0, retcode, stderr)
+	content, err = ioutil.ReadFile(filepath.Join(dir, "local"))
+	require.Nil(t, err)
+	assert.Equal(t, "content", string(content))
+
+	retcode, _, _, err = cfg.Copy(filepath.Join(dir, "missing"), filepath.Join(dir, "local"), false)
+	require.Nil(t, err)
+	assert.NotEqual(t, 0, retcode)
+
+	assert.Equal(t, 1, server.count())
+}
+
+func TestSSHHostKey(t *testing.T) {
+	_, key, err := CreateKeyPair()
+	require.Nil(t, err)
+	gateway := newTestSSHServer(t, string(key))
+	defer gateway.listener.Close()
+	host := newTestSSHServer(t, string(key))
+	defer host.listener.Close()
+
+	gatewayCfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: gateway.port(), PrivateKey: string(key), HostKey: gateway.hostKey}
+	hostCfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: host.port(), PrivateKey: string(key), HostKey: host.hostKey, GatewayConfig: gatewayCfg}
+	cmd, err := hostCfg.Command("echo hello")
+	require.Nil(t, err)
+	out, err := cmd.Output()
+	require.Nil(t, err)
+	assert.Equal(t, "hello\n", string(out))
+
+	// Host key changed
+	changedCfg := *hostCfg
+	changedCfg.HostKey = gateway.hostKey
+	cmd, err = changedCfg.Command("echo hello")
+	require.Nil(t, err)
+	_, _, _, err = cmd.Run()
+	require.NotNil(t, err)
+	hostKeyErr, ok := err.(*SSHHostKeyError)
+	require.True(t, ok, err.Error())
+	expected, err := HostKeyFingerprint(gateway.hostKey)
+	require.Nil(t, err)
+	fingerprint, err := HostKeyFingerprint(host.hostKey)
+	require.Nil(t, err)
+	assert.Equal(t, expected, hostKeyErr.Expected)
+	assert.Equal(t, fingerprint, hostKeyErr.Fingerprint)
+
+	// Gateway key changed
+	changedGatewayCfg := *gatewayCfg
+	changedGatewayCfg.HostKey = host.hostKey
+	changedCfg.HostKey = host.hostKey
+	changedCfg.GatewayConfig = &changedGatewayCfg
+	cmd, err = changedCfg.Command("echo hello")
+	require.Nil(t, err)
+	_, err = cmd.Output()
+	_, ok = err.(*SSHHostKeyError)
+	assert.True(t, ok)
+
+	// The new key is read without authenticating the host
+	_, err = changedCfg.ScanHostKey()
+	assert.NotNil(t, err)
+	changedCfg.GatewayConfig = gatewayCfg
+	changedCfg.HostKey = gateway.hostKey
+	scanned, err := changedCfg.ScanHostKey()
+	require.Nil(t, err)
+	assert.Equal(t, host.hostKey, scanned)
+}
+
+func TestSSHConnectionExpiry(t *testing.T) {
+	_, key, err := CreateKeyPair()
+	require.Nil(t, err)
+	server := newTestSSHServer(t, string(key))
+	defer server.listener.Close()
+
+	cfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: server.port(), PrivateKey: string(key)}
+	c, err := connectionPool.acquire(cfg, false)
+	require.Nil(t, err)
+	c.release(false)
+
+	// The connection is used again when its idle timer fires: it is kept
+	again, err := connectionPool.acquire(cfg, false)
+	require.Nil(t, err)
+	require.True(t, again == c)
+	c.expire()
+	connectionPool.lock.Lock()
+	assert.False(t, c.closed)
+	assert.Contains(t, connectionPool.connections[c.key], c)
+	connectionPool.lock.Unlock()
+	session, err := c.client.NewSession()
+	require.Nil(t, err)
+	_ = session.Close()
+
+	// Unused, it is closed and removed from the pool
+	c.release(false)
+	c.expire()
+	connectionPool.lock.Lock()
+	assert.True(t, c.closed)
+	assert.NotContains(t, connectionPool.connections[c.key], c)
+	connectionPool.lock.Unlock()
+	c, err = connectionPool.acquire(cfg, false)
+	require.Nil(t, err)
+	assert.False(t, c.closed)
+	c.release(false)
+}
+
+func TestSSHTunnel(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh client not available")
+	}
+	_, key, err := CreateKeyPair()
+	require.Nil(t, err)
+	gateway := newTestSSHServer(t, string(key))
+	defer gateway.listener.Close()
+	host := newTestSSHServer(t, string(key))
+	defer host.listener.Close()
+
+	gatewayCfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: gateway.port(), PrivateKey: string(key), HostKey: gateway.hostKey}
+	hostCfg := &SSHConfig{User: "test", Host: "127.0.0.1", Port: host.port(), PrivateKey: string(key), HostKey: host.hostKey, GatewayConfig: gatewayCfg}
+	tunnels, cfg, err := hostCfg.CreateTunnels()
+	require.Nil(t, err)
+	require.NotEmpty(t, tunnels)
+	cmd, err := (&SSHConfig{User: "test", Host: cfg.Host, Port: cfg.Port, PrivateKey: string(key), HostKey: host.hostKey}).Command("echo hello")
+	require.Nil(t, err)
+	out, err := cmd.Output()
+	require.Nil(t, err)
+	assert.Equal(t, "hello\n", string(out))
+	for _, tunnel := range tunnels {
+		assert.Nil(t, tunnel.Close())
+		_, err = os.Stat(tunnel.knownHostsFile.Name())
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// The gateway presenting another key than the recorded one is refused
+	changedGatewayCfg := *gatewayCfg
+	changedGatewayCfg.HostKey = host.hostKey
+	changedCfg := *hostCfg
+	changedCfg.GatewayConfig = &changedGatewayCfg
+	tunnels, cfg, err = changedCfg.CreateTunnels()
+	require.Nil(t, err)
+	defer func() {
+		for _, tunnel := range tunnels {
+			_ = tunnel.Close()
+		}
+	}()
+	assert.NotNil(t, (&SSHConfig{User: "test", Host: cfg.Host, Port: cfg.Port, PrivateKey: string(key)}).CheckConnection())
+}
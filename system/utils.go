@@ -75,5 +75,8 @@ func ExtractRetCode(err error) (string, int, error) {
 		msg = ee.Error()
 		return msg, retCode, nil
 	}
+	if ee, ok := err.(*SSHExitError); ok {
+		return ee.Error(), ee.ExitStatus(), nil
+	}
 	return msg, retCode, fmt.Errorf("Error is not an 'ExitError'")
 }
@@ -1,40 +1,187 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
 package metadata
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 )
 
+// Encrypted metadata objects are stored in this format (version 1):
+//
+//   | magic "SSMK" (4 bytes) | format version (1 byte) | key ID (8 bytes) | nonce (12 bytes) | AES-256-GCM sealed content |
+//
+// The header and the path of the object in the bucket are authenticated with the content: an object altered,
+// or copied to another path, fails to decrypt.
+
+const (
+	cryptMagic                    = "SSMK"
+	cryptFormatVersion       byte = 1
+	cryptKeyIDSize                = 8
+	cryptHeaderSize               = len(cryptMagic) + 1 + cryptKeyIDSize
+	cryptKeyDerivationPrefix      = "safescale-metadata-key:"
+)
+
 var (
-	doEncrypt = false
+	// errNotEncrypted is returned when decrypting a content which has not been encrypted by a known format
+	errNotEncrypted = errors.New("content is not encrypted")
 )
 
-// See alternate IV creation from ciphertext below
-//var iv = []byte{35, 46, 57, 24, 85, 35, 24, 74, 87, 35, 88, 98, 66, 32, 14, 05}
+// cryptKey is a key able to encrypt and decrypt metadata
+type cryptKey struct {
+	id   []byte
+	aead cipher.AEAD
+	// passphrase is kept to decrypt content encrypted by previous releases (AES-CFB, passphrase used as AES key)
+	passphrase []byte
+}
 
-// encrypt ...
-func encrypt(key, text []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+// newCryptKey derives an AES-256 key from the passphrase
+func newCryptKey(passphrase string) (*cryptKey, error) {
+	derived := sha256.Sum256([]byte(cryptKeyDerivationPrefix + passphrase))
+	block, err := aes.NewCipher(derived[:])
 	if err != nil {
 		return nil, err
 	}
-	b := base64.StdEncoding.EncodeToString(text)
-	ciphertext := make([]byte, aes.BlockSize+len(b))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
 		return nil, err
 	}
-	cfb := cipher.NewCFBEncrypter(block, iv)
-	cfb.XORKeyStream(ciphertext[aes.BlockSize:], []byte(b))
-	return ciphertext, nil
+	id := sha256.Sum256(derived[:])
+	return &cryptKey{
+		id:         id[:cryptKeyIDSize],
+		aead:       aead,
+		passphrase: []byte(passphrase),
+	}, nil
+}
+
+// Keyring contains the keys used to encrypt metadata.
+// The first key (the primary key) encrypts; every key of the keyring can decrypt, which allows to
+// keep reading the objects not yet re-encrypted during a key rotation.
+type Keyring struct {
+	keys []*cryptKey
+}
+
+// NewKeyring creates a keyring from passphrases; the first one becomes the primary key
+func NewKeyring(passphrases ...string) (*Keyring, error) {
+	k := &Keyring{}
+	for _, passphrase := range passphrases {
+		if passphrase == "" {
+			return nil, fmt.Errorf("invalid empty metadata key")
+		}
+		key, err := newCryptKey(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		k.keys = append(k.keys, key)
+	}
+	if len(k.keys) == 0 {
+		return nil, fmt.Errorf("no metadata key")
+	}
+	return k, nil
+}
+
+// PrimaryKeyID returns the ID of the key used to encrypt
+func (k *Keyring) PrimaryKeyID() string {
+	return hex.EncodeToString(k.keys[0].id)
+}
+
+// find returns the key of the keyring with this ID
+func (k *Keyring) find(id []byte) *cryptKey {
+	for _, key := range k.keys {
+		if bytes.Equal(key.id, id) {
+			return key
+		}
+	}
+	return nil
+}
+
+// encrypt encrypts the content of the object 'path' with the primary key
+func (k *Keyring) encrypt(path string, text []byte) ([]byte, error) {
+	key := k.keys[0]
+	header := make([]byte, 0, cryptHeaderSize)
+	header = append(header, cryptMagic...)
+	header = append(header, cryptFormatVersion)
+	header = append(header, key.id...)
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	data := append(header, nonce...)
+	return key.aead.Seal(data, nonce, text, cryptAdditionalData(header, path)), nil
+}
+
+// decrypt decrypts the content of the object 'path' with the key it has been encrypted with
+// Returns errNotEncrypted if the content is not encrypted in a known format
+func (k *Keyring) decrypt(path string, data []byte) ([]byte, error) {
+	id, version, ok := cryptHeader(data)
+	if !ok {
+		for _, key := range k.keys {
+			if text, err := legacyDecrypt(key.passphrase, data); err == nil {
+				return text, nil
+			}
+		}
+		return nil, errNotEncrypted
+	}
+	if version != cryptFormatVersion {
+		return nil, fmt.Errorf("unsupported encryption format version %d", version)
+	}
+	key := k.find(id)
+	if key == nil {
+		return nil, fmt.Errorf("content encrypted with unknown key '%s'", hex.EncodeToString(id))
+	}
+	header := data[:cryptHeaderSize]
+	data = data[cryptHeaderSize:]
+	nonceSize := key.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	text, err := key.aead.Open(nil, data[:nonceSize], data[nonceSize:], cryptAdditionalData(header, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt content: integrity check failed")
+	}
+	return text, nil
+}
+
+// cryptHeader returns the key ID and the format version of an encrypted content
+// ok is false if the content doesn't start with an encryption header
+func cryptHeader(data []byte) (id []byte, version byte, ok bool) {
+	if len(data) < cryptHeaderSize || string(data[:len(cryptMagic)]) != cryptMagic {
+		return nil, 0, false
+	}
+	return data[len(cryptMagic)+1 : cryptHeaderSize], data[len(cryptMagic)], true
+}
+
+// cryptAdditionalData returns the data authenticated with the content of the object 'path'
+func cryptAdditionalData(header []byte, path string) []byte {
+	return append(append([]byte{}, header...), path...)
 }
 
-// decrypt decrypts a byte slice previously encrypted with encrypt()
-func decrypt(key, text []byte) ([]byte, error) {
+// legacyDecrypt decrypts a byte slice encrypted by previous releases (AES-CFB of the base64 content,
+// without integrity check)
+func legacyDecrypt(key, text []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -43,10 +190,10 @@ func decrypt(key, text []byte) ([]byte, error) {
 		return nil, errors.New("ciphertext too short")
 	}
 	iv := text[:aes.BlockSize]
-	text = text[aes.BlockSize:]
+	buf := make([]byte, len(text)-aes.BlockSize)
 	cfb := cipher.NewCFBDecrypter(block, iv)
-	cfb.XORKeyStream(text, text)
-	data, err := base64.StdEncoding.DecodeString(string(text))
+	cfb.XORKeyStream(buf, text[aes.BlockSize:])
+	data, err := base64.StdEncoding.DecodeString(string(buf))
 	if err != nil {
 		return nil, err
 	}
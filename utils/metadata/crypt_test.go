@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	_, err := NewKeyring()
+	assert.NotNil(t, err)
+	_, err = NewKeyring("")
+	assert.NotNil(t, err)
+
+	content := []byte(`{"name":"host"}`)
+	old, err := NewKeyring("old secret")
+	require.Nil(t, err)
+	data, err := old.encrypt("hosts/byName/host", content)
+	require.Nil(t, err)
+	id, version, ok := cryptHeader(data)
+	require.True(t, ok)
+	assert.Equal(t, cryptFormatVersion, version)
+	assert.Equal(t, old.PrimaryKeyID(), hex.EncodeToString(id))
+
+	text, err := old.decrypt("hosts/byName/host", data)
+	require.Nil(t, err)
+	assert.Equal(t, content, text)
+
+	// Content moved to another path
+	_, err = old.decrypt("hosts/byName/other", data)
+	assert.NotNil(t, err)
+
+	// Content altered
+	altered := append([]byte{}, data...)
+	altered[len(altered)-1] ^= 1
+	_, err = old.decrypt("hosts/byName/host", altered)
+	assert.NotNil(t, err)
+
+	// During rotation, the new primary key encrypts and the old one still decrypts
+	rotating, err := NewKeyring("new secret", "old secret")
+	require.Nil(t, err)
+	assert.NotEqual(t, old.PrimaryKeyID(), rotating.PrimaryKeyID())
+	text, err = rotating.decrypt("hosts/byName/host", data)
+	require.Nil(t, err)
+	assert.Equal(t, content, text)
+	data, err = rotating.encrypt("hosts/byName/host", content)
+	require.Nil(t, err)
+	_, err = old.decrypt("hosts/byName/host", data)
+	assert.NotNil(t, err)
+
+	// Content not encrypted
+	_, err = rotating.decrypt("hosts/byName/host", content)
+	assert.Equal(t, errNotEncrypted, err)
+}
+
+func TestKeyringLegacy(t *testing.T) {
+	passphrase := "0123456789abcdef"
+	content := []byte(`{"name":"host"}`)
+
+	// Encrypts as done by previous releases
+	block, err := aes.NewCipher([]byte(passphrase))
+	require.Nil(t, err)
+	b := base64.StdEncoding.EncodeToString(content)
+	data := make([]byte, aes.BlockSize+len(b))
+	cipher.NewCFBEncrypter(block, data[:aes.BlockSize]).XORKeyStream(data[aes.BlockSize:], []byte(b))
+
+	keyring, err := NewKeyring("new secret", passphrase)
+	require.Nil(t, err)
+	text, err := keyring.decrypt("hosts/byName/host", data)
+	require.Nil(t, err)
+	assert.Equal(t, content, text)
+}
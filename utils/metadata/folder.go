@@ -42,10 +42,12 @@ func InitializeBucket(location objectstorage.Location) error {
 //Folder describes a metadata folder
 type Folder struct {
 	//path contains the base path where to read/write record in Object Storage
-	path     string
-	service  *providers.Service
-	crypt    bool
-	cryptKey []byte
+	path    string
+	service *providers.Service
+	// keyring contains the keys used to encrypt the metadata (nil if metadata are not encrypted)
+	keyring *Keyring
+	// historySize is the number of revisions kept for each entry (0 disables history)
+	historySize int
 }
 
 // FolderDecoderCallback is the prototype of the function that will decode data read from Metadata
@@ -60,19 +62,47 @@ func NewFolder(svc *providers.Service, path string) *Folder {
 	if err != nil {
 		panic(fmt.Sprintf("config options are not available! %s", err.Error()))
 	}
-	cryptKey, crypt := cfg.Get("MetadataKey")
+	keys := svc.MetadataKeys
+	if len(keys) == 0 {
+		if anon, found := cfg.Get("MetadataKey"); found {
+			keys = []string{anon.(string)}
+		}
+	}
 	f := &Folder{
 		path:    strings.Trim(path, "/"),
 		service: svc,
 		// bucketName: name.(string),
-		crypt: crypt,
+		historySize: DefaultHistorySize,
 	}
-	if crypt {
-		f.cryptKey = []byte(cryptKey.(string))
+	if len(keys) > 0 {
+		f.keyring, err = NewKeyring(keys...)
+		if err != nil {
+			panic(fmt.Sprintf("invalid metadata keys! %s", err.Error()))
+		}
 	}
 	return f
 }
 
+// encode encrypts the content of the object 'path' if the metadata are encrypted
+func (f *Folder) encode(path string, content []byte) ([]byte, error) {
+	if f.keyring == nil {
+		return content, nil
+	}
+	return f.keyring.encrypt(path, content)
+}
+
+// decode decrypts the content of the object 'path' if the metadata are encrypted
+func (f *Folder) decode(path string, data []byte) ([]byte, error) {
+	if f.keyring == nil {
+		return data, nil
+	}
+	content, err := f.keyring.decrypt(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt metadata '%s': %s", path, err.Error())
+	}
+	return content, nil
+}
+
 // GetService returns the service used by the folder
 func (f *Folder) GetService() *providers.Service {
 	return f.service
@@ -128,7 +158,16 @@ func (f *Folder) Search(path string, name string) (bool, error) {
 }
 
 // Delete removes metadata passed as parameter
+// If history is enabled, the deleted content is kept as last revision of the entry
 func (f *Folder) Delete(path string, name string) error {
+	if f.historySize > 0 {
+		_, err := f.Read(path, name, func(data []byte) error {
+			return f.addRevision(path, name, data, true)
+		})
+		if err != nil {
+			log.Warnf("failed to save revision of '%s' before deletion: %s", f.absolutePath(path, name), err.Error())
+		}
+	}
 	err := f.service.MetadataBucket.DeleteObject(f.absolutePath(path, name))
 	if err != nil {
 		return fmt.Errorf("failed to remove metadata in Object Storage: %s", err.Error())
@@ -152,12 +191,9 @@ func (f *Folder) Read(path string, name string, callback FolderDecoderCallback)
 		if err != nil {
 			return false, err
 		}
-		data := buffer.Bytes()
-		if f.crypt {
-			data, err = decrypt(f.cryptKey, data)
-			if err != nil {
-				return false, err
-			}
+		data, err := f.decode(f.absolutePath(path, name), buffer.Bytes())
+		if err != nil {
+			return false, err
 		}
 		return true, callback(data)
 	}
@@ -166,28 +202,28 @@ func (f *Folder) Read(path string, name string, callback FolderDecoderCallback)
 
 // Write writes the content in Object Storage
 func (f *Folder) Write(path string, name string, content []byte) error {
-	var (
-		data []byte
-		err  error
-	)
-
-	if f.crypt {
-		data, err = encrypt(f.cryptKey, content)
-		if err != nil {
-			return err
-		}
-	} else {
-		data = content
+	data, err := f.encode(f.absolutePath(path, name), content)
+	if err != nil {
+		return err
 	}
 
 	source := bytes.NewBuffer(data)
 	_, err = f.service.MetadataBucket.WriteObject(f.absolutePath(path, name), source, int64(source.Len()), nil)
-	return err
+	if err != nil {
+		return err
+	}
+	if f.historySize > 0 {
+		err = f.addRevision(path, name, content, false)
+		if err != nil {
+			log.Warnf("failed to save revision of '%s': %s", f.absolutePath(path, name), err.Error())
+		}
+	}
+	return nil
 }
 
 // Browse browses the content of a specific path in Metadata and executes 'cb' on each entry
 func (f *Folder) Browse(path string, callback FolderDecoderCallback) error {
-	list, err := f.service.MetadataBucket.List(f.absolutePath(path), objectstorage.NoPrefix)
+	list, err := f.service.MetadataBucket.List(strings.Trim(f.absolutePath(path), "/"), objectstorage.NoPrefix)
 	if err != nil {
 		log.Errorf("Error browsing metadata: listing objects: %+v", err)
 		return err
@@ -200,12 +236,9 @@ func (f *Folder) Browse(path string, callback FolderDecoderCallback) error {
 			log.Errorf("Error browsing metadata: reading from buffer: %+v", err)
 			return err
 		}
-		data := buffer.Bytes()
-		if f.crypt {
-			data, err = decrypt(f.cryptKey, data)
-			if err != nil {
-				return err
-			}
+		data, err := f.decode(i, buffer.Bytes())
+		if err != nil {
+			return err
 		}
 		err = callback(data)
 		if err != nil {
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers/objectstorage"
+)
+
+const (
+	// historyFolderName is the folder of the metadata bucket containing the revisions of the entries
+	historyFolderName = "history"
+)
+
+var (
+	// DefaultHistorySize is the number of revisions kept for each metadata entry
+	DefaultHistorySize = 10
+
+	// Author identifies who writes metadata in the revisions; defaults to <user>@<hostname>
+	Author = defaultAuthor()
+
+	// historyPruneInterval is the number of revisions added to an entry by the process between 2 prunings of its
+	// history; an entry may so keep up to DefaultHistorySize+historyPruneInterval-1 revisions between 2 prunings
+	historyPruneInterval = 5
+
+	// historyWrites counts the revisions added by the process, by entry, to know when to prune
+	historyWrites     = map[string]int{}
+	historyWritesLock sync.Mutex
+)
+
+// defaultAuthor returns <user>@<hostname> of the current process
+func defaultAuthor() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	hostname, _ := os.Hostname()
+	return username + "@" + hostname
+}
+
+// Revision is a state of a metadata entry, saved by each write or delete
+type Revision struct {
+	// ID identifies the revision; revisions IDs of an entry sort in chronological order
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    string    `json:"author"`
+	// Deleted tells the revision has been saved by the deletion of the entry; Data contains the deleted content
+	Deleted bool   `json:"deleted,omitempty"`
+	Size    int    `json:"size"`
+	Data    []byte `json:"data,omitempty"`
+}
+
+// historyPath returns the folder of the bucket containing the revisions of the entry 'name' in subfolder 'path'
+func (f *Folder) historyPath(path string, name string) string {
+	return historyFolderName + "/" + strings.Trim(f.absolutePath(path, name), "/")
+}
+
+// addRevision saves a new revision of an entry, then removes the revisions exceeding the history size
+// (only every historyPruneInterval revisions added by the process, to avoid listing the history at each write)
+func (f *Folder) addRevision(path string, name string, content []byte, deleted bool) error {
+	now := time.Now()
+	rev := Revision{
+		ID:        fmt.Sprintf("%019d", now.UnixNano()),
+		Timestamp: now,
+		Author:    Author,
+		Deleted:   deleted,
+		Size:      len(content),
+		Data:      content,
+	}
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	prefix := f.historyPath(path, name)
+	data, err = f.encode(prefix+"/"+rev.ID, data)
+	if err != nil {
+		return err
+	}
+	source := bytes.NewBuffer(data)
+	_, err = f.service.MetadataBucket.WriteObject(prefix+"/"+rev.ID, source, int64(source.Len()), nil)
+	if err != nil {
+		return err
+	}
+
+	// The first revision added by the process prunes too, to clean up after the processes which stopped in between
+	historyWritesLock.Lock()
+	count := historyWrites[prefix]
+	historyWrites[prefix] = (count + 1) % historyPruneInterval
+	historyWritesLock.Unlock()
+	if count != 0 {
+		return nil
+	}
+	return f.pruneRevisions(path, name)
+}
+
+// pruneRevisions removes the oldest revisions of an entry exceeding the history size
+func (f *Folder) pruneRevisions(path string, name string) error {
+	list, err := f.listRevisions(path, name)
+	if err != nil {
+		return err
+	}
+	prefix := f.historyPath(path, name)
+	for len(list) > f.historySize {
+		err = f.service.MetadataBucket.DeleteObject(prefix + "/" + list[0])
+		if err != nil {
+			return err
+		}
+		list = list[1:]
+	}
+	return nil
+}
+
+// listRevisions returns the IDs of the revisions of an entry, oldest first
+func (f *Folder) listRevisions(path string, name string) ([]string, error) {
+	prefix := f.historyPath(path, name)
+	list, err := f.service.MetadataBucket.List(prefix, objectstorage.NoPrefix)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, item := range list {
+		if strings.HasPrefix(item, prefix+"/") {
+			ids = append(ids, strings.TrimPrefix(item, prefix+"/"))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// readRevision reads a revision of an entry
+func (f *Folder) readRevision(path string, name string, id string) (*Revision, error) {
+	var buffer bytes.Buffer
+	key := f.historyPath(path, name) + "/" + id
+	_, err := f.service.MetadataBucket.ReadObject(key, &buffer, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("revision '%s' of '%s' not found", id, f.absolutePath(path, name))
+	}
+	data, err := f.decode(key, buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	rev := Revision{}
+	err = json.Unmarshal(data, &rev)
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// History returns the revisions of the entry 'name' in subfolder 'path', oldest first, without their content
+func (f *Folder) History(path string, name string) ([]*Revision, error) {
+	ids, err := f.listRevisions(path, name)
+	if err != nil {
+		return nil, err
+	}
+	list := []*Revision{}
+	for _, id := range ids {
+		rev, err := f.readRevision(path, name, id)
+		if err != nil {
+			log.Warnf("failed to read revision '%s' of '%s': %s", id, f.absolutePath(path, name), err.Error())
+			continue
+		}
+		rev.Data = nil
+		list = append(list, rev)
+	}
+	return list, nil
+}
+
+// ReadRevision loads the content of a revision of the entry 'name' in subfolder 'path' and passes it to callback
+func (f *Folder) ReadRevision(path string, name string, id string, callback FolderDecoderCallback) error {
+	rev, err := f.readRevision(path, name, id)
+	if err != nil {
+		return err
+	}
+	return callback(rev.Data)
+}
+
+// rawData is a model.Serializable keeping the content as is, used to restore an entry without decoding it
+type rawData []byte
+
+// Serialize ...
+func (r *rawData) Serialize() ([]byte, error) {
+	return *r, nil
+}
+
+// Deserialize ...
+func (r *rawData) Deserialize(buf []byte) error {
+	*r = buf
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers/model"
+)
+
+// readEntry returns the current content of an entry of the folder
+func readEntry(t *testing.T, f *Folder, name string) string {
+	var content string
+	found, err := f.Read(".", name, func(buf []byte) error {
+		content = string(buf)
+		return nil
+	})
+	require.Nil(t, err)
+	require.True(t, found)
+	return content
+}
+
+func TestHistoryOrdering(t *testing.T) {
+	defer fastLeases()()
+	f := NewFolder(newTestService(t), "hosts/byName")
+
+	for i := 1; i <= 3; i++ {
+		require.Nil(t, f.Write(".", "host", []byte(fmt.Sprintf("v%d", i))))
+	}
+	require.Nil(t, f.Delete(".", "host"))
+
+	history, err := f.History(".", "host")
+	require.Nil(t, err)
+	require.Len(t, history, 4)
+	for i, rev := range history {
+		assert.Equal(t, Author, rev.Author)
+		assert.Nil(t, rev.Data)
+		if i > 0 {
+			assert.True(t, history[i-1].ID < rev.ID)
+			assert.False(t, rev.Timestamp.Before(history[i-1].Timestamp))
+		}
+	}
+	assert.False(t, history[2].Deleted)
+	assert.True(t, history[3].Deleted)
+
+	// The revision saved by the deletion keeps the deleted content
+	var content string
+	require.Nil(t, f.ReadRevision(".", "host", history[3].ID, func(buf []byte) error {
+		content = string(buf)
+		return nil
+	}))
+	assert.Equal(t, "v3", content)
+	assert.NotNil(t, f.ReadRevision(".", "host", "0", func(buf []byte) error { return nil }))
+}
+
+func TestHistoryPruning(t *testing.T) {
+	defer fastLeases()()
+	defer func(interval int) { historyPruneInterval = interval }(historyPruneInterval)
+	historyWritesLock.Lock()
+	historyWrites = map[string]int{}
+	historyWritesLock.Unlock()
+	f := NewFolder(newTestService(t), "hosts/byName")
+	f.historySize = 3
+
+	// Pruning at each write keeps exactly the last revisions
+	historyPruneInterval = 1
+	for i := 1; i <= 6; i++ {
+		require.Nil(t, f.Write(".", "host", []byte(fmt.Sprintf("v%d", i))))
+	}
+	ids, err := f.listRevisions(".", "host")
+	require.Nil(t, err)
+	require.Len(t, ids, 3)
+	var content string
+	require.Nil(t, f.ReadRevision(".", "host", ids[0], func(buf []byte) error {
+		content = string(buf)
+		return nil
+	}))
+	assert.Equal(t, "v4", content)
+
+	// Otherwise the history is pruned at the first write of the process, then every historyPruneInterval writes
+	historyPruneInterval = 4
+	for i := 1; i <= 4; i++ {
+		require.Nil(t, f.Write(".", "other", []byte(fmt.Sprintf("v%d", i))))
+	}
+	ids, err = f.listRevisions(".", "other")
+	require.Nil(t, err)
+	assert.Len(t, ids, 4)
+	require.Nil(t, f.Write(".", "other", []byte("v5")))
+	ids, err = f.listRevisions(".", "other")
+	require.Nil(t, err)
+	assert.Len(t, ids, 3)
+
+	// Without history, no revision is kept
+	locks := newLocksFolder(f.GetService())
+	require.Nil(t, locks.Write(".", "host", []byte("lock")))
+	ids, err = locks.listRevisions(".", "host")
+	require.Nil(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestRestoreFrom(t *testing.T) {
+	defer fastLeases()()
+	item := NewItem(newTestService(t), "hosts/byName")
+
+	for _, content := range []string{`{"name":"v1"}`, `{"name":"v2"}`} {
+		data := rawData(content)
+		require.Nil(t, item.Carry(&data).Write("host"))
+	}
+	history, err := item.HistoryFrom(".", "host")
+	require.Nil(t, err)
+	require.Len(t, history, 2)
+
+	require.Nil(t, item.RestoreFrom(".", "host", history[0].ID))
+	assert.Equal(t, `{"name":"v1"}`, readEntry(t, item.folder, "host"))
+
+	// The restoration is recorded as a new revision
+	history, err = item.HistoryFrom(".", "host")
+	require.Nil(t, err)
+	require.Len(t, history, 3)
+
+	// A restored revision is loaded as payload of the item without decoding
+	err = item.ReadRevisionFrom(".", "host", history[1].ID, func(buf []byte) (model.Serializable, error) {
+		data := rawData(buf)
+		return &data, nil
+	})
+	require.Nil(t, err)
+	content, err := item.Get().(model.Serializable).Serialize()
+	require.Nil(t, err)
+	assert.Equal(t, `{"name":"v2"}`, string(content))
+
+	assert.NotNil(t, item.RestoreFrom(".", "host", "0"))
+}
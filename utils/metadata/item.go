@@ -17,8 +17,11 @@
 package metadata
 
 import (
+	"fmt"
 	"sync"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/CS-SI/SafeScale/providers"
 	"github.com/CS-SI/SafeScale/providers/api"
 	"github.com/CS-SI/SafeScale/providers/model"
@@ -30,6 +33,7 @@ type Item struct {
 	payload model.Serializable
 	folder  *Folder
 	lock    sync.Mutex
+	lease   *Lease
 }
 
 // ItemDecoderCallback ...
@@ -88,6 +92,13 @@ func (i *Item) DeleteFrom(path string, name string) error {
 		path = "."
 	}
 
+	if i.lease != nil {
+		err := i.lease.Check()
+		if err != nil {
+			return fmt.Errorf("failed to delete metadata '%s': %s", i.folder.absolutePath(path, name), err.Error())
+		}
+	}
+
 	if there, err := i.folder.Search(path, name); err != nil || !there {
 		if err != nil {
 			return err
@@ -128,12 +139,63 @@ func (i *Item) Read(name string, callback ItemDecoderCallback) (bool, error) {
 	return i.ReadFrom(".", name, callback)
 }
 
+// HistoryFrom returns the revisions of the entry 'name' in a subfolder, oldest first
+func (i *Item) HistoryFrom(path string, name string) ([]*Revision, error) {
+	return i.folder.History(path, name)
+}
+
+// ReadRevisionFrom loads a revision of the entry 'name' in a subfolder as payload of the item
+func (i *Item) ReadRevisionFrom(path string, name string, revision string, callback ItemDecoderCallback) error {
+	var data model.Serializable
+	err := i.folder.ReadRevision(path, name, revision, func(buf []byte) error {
+		var err error
+		data, err = callback(buf)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	i.payload = data
+	return nil
+}
+
+// RestoreFrom writes the content of a revision back into the entry 'name' in a subfolder
+// (the restoration is itself recorded as a new revision)
+func (i *Item) RestoreFrom(path string, name string, revision string) error {
+	err := i.ReadRevisionFrom(path, name, revision, func(buf []byte) (model.Serializable, error) {
+		data := rawData(buf)
+		return &data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return i.WriteInto(path, name)
+}
+
 // WriteInto saves the content of Item in a subfolder to the Object Storage
+// If the Item hasn't been acquired, the entry is locked during the write
 func (i *Item) WriteInto(path string, name string) error {
 	data, err := i.payload.Serialize()
 	if err != nil {
 		return err
 	}
+	if i.lease != nil {
+		err = i.lease.Check()
+		if err != nil {
+			return fmt.Errorf("failed to write metadata '%s': %s", i.folder.absolutePath(path, name), err.Error())
+		}
+	} else {
+		lease := NewLease(i.GetService(), i.folder.absolutePath(path, name))
+		err = lease.Acquire(DefaultLeaseTimeout)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := lease.Release(); err != nil {
+				log.Warnf("failed to release lock: %s", err.Error())
+			}
+		}()
+	}
 	return i.folder.Write(path, name, data)
 }
 
@@ -163,12 +225,33 @@ func (i *Item) Browse(callback func([]byte) error) error {
 	})
 }
 
-// Acquire waits until the write lock is available, then locks the metadata
-func (i *Item) Acquire() {
+// AcquireFrom waits until the write lock of the entry 'name' in subfolder 'path' is available, then locks it,
+// in the process and in the metadata bucket, so that other processes using the same metadata wait too
+func (i *Item) AcquireFrom(path string, name string) error {
 	i.lock.Lock()
+	lease := NewLease(i.GetService(), i.folder.absolutePath(path, name))
+	err := lease.Acquire(DefaultLeaseTimeout)
+	if err != nil {
+		i.lock.Unlock()
+		return err
+	}
+	i.lease = lease
+	return nil
+}
+
+// Acquire waits until the write lock of the entry 'name' is available, then locks it
+func (i *Item) Acquire(name string) error {
+	return i.AcquireFrom(".", name)
 }
 
 // Release unlocks the metadata
 func (i *Item) Release() {
+	if i.lease != nil {
+		err := i.lease.Release()
+		if err != nil {
+			log.Warnf("failed to release lock: %s", err.Error())
+		}
+		i.lease = nil
+	}
 	i.lock.Unlock()
 }
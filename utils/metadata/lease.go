@@ -0,0 +1,358 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+)
+
+const (
+	// locksFolderName is the folder of the metadata bucket containing the lock objects
+	locksFolderName = "locks"
+)
+
+var (
+	// DefaultLeaseDuration is the time a lease stays valid if its owner stops renewing it (crash, network failure, ...)
+	DefaultLeaseDuration = 30 * time.Second
+	// DefaultLeaseTimeout is the maximum time to wait for a lease held by someone else
+	DefaultLeaseTimeout = 2 * time.Minute
+
+	// leasePollInterval is the delay between 2 attempts to take a lease held by someone else
+	leasePollInterval = time.Second
+	// leaseSettleDelay is the delay let to concurrent writers before checking who won the lease
+	// (Object Storage doesn't provide conditional writes, last writer wins)
+	leaseSettleDelay = 200 * time.Millisecond
+)
+
+// LeaseInfo is the content of a lock object
+type LeaseInfo struct {
+	// Key is the path of the locked entry in the metadata bucket
+	Key string `json:"key"`
+	// Owner identifies the holder of the lease (unique per Lease instance)
+	Owner      string    `json:"owner"`
+	Hostname   string    `json:"hostname"`
+	PID        int       `json:"pid"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Expired tells if the owner of the lease didn't renew it in time
+func (li *LeaseInfo) Expired() bool {
+	return time.Now().After(li.ExpiresAt)
+}
+
+// Lease is a lock on an entry of the metadata, stored in the metadata bucket so that every process
+// using the same tenant sees it. The lease is renewed in background while held, and expires if
+// its owner disappears.
+//
+// Object Storage doesn't provide conditional writes, so the lease is advisory: 2 processes taking a free
+// lease at the same time are told apart by re-reading the lock object after leaseSettleDelay, which doesn't
+// protect against an Object Storage slower than that delay. To limit the damage, the owner string of the
+// lease (unique per Lease instance) is used as token: the writes protected by a lease call Check() first,
+// so a process whose lease has expired, been broken or been taken over doesn't overwrite the entry.
+type Lease struct {
+	folder   *Folder
+	key      string
+	owner    string
+	duration time.Duration
+
+	lock       sync.Mutex
+	acquiredAt time.Time
+	stop       chan struct{}
+	done       chan struct{}
+
+	// state protects validUntil and lost, updated by the renewal
+	state sync.Mutex
+	// validUntil is the expiry written by the last successful write of the lock object (zero if not held)
+	validUntil time.Time
+	// lost tells the lock object doesn't contain the token of the lease anymore
+	lost bool
+}
+
+// NewLease creates a lease on the metadata entry 'key' (path in the metadata bucket, ex: "clusters/mycluster")
+func NewLease(svc *providers.Service, key string) *Lease {
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	if id, err := uuid.NewV4(); err == nil {
+		owner += ":" + id.String()
+	}
+	return &Lease{
+		folder:   newLocksFolder(svc),
+		key:      strings.Trim(key, "/"),
+		owner:    owner,
+		duration: DefaultLeaseDuration,
+	}
+}
+
+// GetKey returns the path of the entry locked by the lease
+func (l *Lease) GetKey() string {
+	return l.key
+}
+
+// read returns the current content of the lock object, nil if there is none
+func (l *Lease) read() (*LeaseInfo, error) {
+	return readLeaseInfo(l.folder, l.key)
+}
+
+// write (over)writes the lock object with a new expiry, and returns this expiry
+func (l *Lease) write() (time.Time, error) {
+	now := time.Now()
+	info := LeaseInfo{
+		Key:        l.key,
+		Owner:      l.owner,
+		PID:        os.Getpid(),
+		AcquiredAt: l.acquiredAt,
+		ExpiresAt:  now.Add(l.duration),
+	}
+	if info.AcquiredAt.IsZero() {
+		info.AcquiredAt = now
+	}
+	info.Hostname, _ = os.Hostname()
+	content, err := json.Marshal(info)
+	if err != nil {
+		return time.Time{}, err
+	}
+	err = l.folder.Write(".", l.key, content)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ExpiresAt, nil
+}
+
+// setState records the validity of the lease
+func (l *Lease) setState(validUntil time.Time, lost bool) {
+	l.state.Lock()
+	defer l.state.Unlock()
+	l.validUntil = validUntil
+	l.lost = lost
+}
+
+// Acquire waits until the lease is free (or expired), then takes it; fails after 'timeout'
+func (l *Lease) Acquire(timeout time.Duration) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stop != nil {
+		return fmt.Errorf("lock '%s' already acquired", l.key)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := l.read()
+		if err != nil {
+			return fmt.Errorf("failed to read lock '%s': %s", l.key, err.Error())
+		}
+		if current == nil || current.Expired() || current.Owner == l.owner {
+			if current != nil && current.Owner != l.owner {
+				log.Warnf("lock '%s' held by '%s' expired at %s, taking it over", l.key, current.Owner, current.ExpiresAt.Format(time.RFC3339))
+			}
+			l.acquiredAt = time.Time{}
+			expiresAt, err := l.write()
+			if err != nil {
+				return fmt.Errorf("failed to write lock '%s': %s", l.key, err.Error())
+			}
+			// Lets concurrent writers finish, then checks who owns the lock
+			time.Sleep(leaseSettleDelay)
+			current, err = l.read()
+			if err != nil {
+				return fmt.Errorf("failed to read lock '%s': %s", l.key, err.Error())
+			}
+			if current != nil && current.Owner == l.owner {
+				l.acquiredAt = current.AcquiredAt
+				l.setState(expiresAt, false)
+				l.stop = make(chan struct{})
+				l.done = make(chan struct{})
+				go l.renew(l.stop, l.done)
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			if current != nil {
+				return fmt.Errorf("timeout waiting for lock '%s' held by '%s' (expires at %s)", l.key, current.Owner, current.ExpiresAt.Format(time.RFC3339))
+			}
+			return fmt.Errorf("timeout waiting for lock '%s'", l.key)
+		}
+		time.Sleep(leasePollInterval)
+	}
+}
+
+// renew extends the lease periodically until 'stop' is closed
+func (l *Lease) renew(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(l.duration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			current, err := l.read()
+			if err != nil {
+				log.Warnf("failed to renew lock '%s': %s", l.key, err.Error())
+				continue
+			}
+			if current == nil || current.Owner != l.owner {
+				log.Errorf("lock '%s' has been lost (broken or expired), stopping its renewal", l.key)
+				l.state.Lock()
+				l.lost = true
+				l.state.Unlock()
+				return
+			}
+			expiresAt, err := l.write()
+			if err != nil {
+				// The lease stays valid until the expiry written last; Check() fails after that
+				log.Warnf("failed to renew lock '%s': %s", l.key, err.Error())
+				continue
+			}
+			l.state.Lock()
+			l.validUntil = expiresAt
+			l.state.Unlock()
+		}
+	}
+}
+
+// Check verifies the lease is still held, before a write it protects: it fails if the lease hasn't been renewed
+// in time (a third of its duration is kept as margin against clock skew), or if the lock object doesn't contain
+// the token of the lease anymore (broken, or taken over after expiry)
+func (l *Lease) Check() error {
+	l.state.Lock()
+	validUntil, lost := l.validUntil, l.lost
+	l.state.Unlock()
+
+	if validUntil.IsZero() {
+		return fmt.Errorf("lock '%s' not acquired", l.key)
+	}
+	if lost {
+		return fmt.Errorf("lock '%s' has been lost (broken or expired)", l.key)
+	}
+	if time.Now().Add(l.duration / 3).After(validUntil) {
+		return fmt.Errorf("lock '%s' has not been renewed in time, it may be taken by someone else", l.key)
+	}
+	current, err := l.read()
+	if err != nil {
+		return fmt.Errorf("failed to read lock '%s': %s", l.key, err.Error())
+	}
+	if current == nil || current.Owner != l.owner {
+		l.state.Lock()
+		l.lost = true
+		l.state.Unlock()
+		return fmt.Errorf("lock '%s' has been lost (broken or expired)", l.key)
+	}
+	return nil
+}
+
+// Release stops the renewal of the lease and removes the lock object if still owned
+func (l *Lease) Release() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stop == nil {
+		return fmt.Errorf("lock '%s' not acquired", l.key)
+	}
+	close(l.stop)
+	<-l.done
+	l.stop = nil
+	l.done = nil
+	l.setState(time.Time{}, false)
+
+	current, err := l.read()
+	if err != nil {
+		return fmt.Errorf("failed to read lock '%s': %s", l.key, err.Error())
+	}
+	if current == nil || current.Owner != l.owner {
+		log.Warnf("lock '%s' has been lost before its release", l.key)
+		return nil
+	}
+	return l.folder.Delete(".", l.key)
+}
+
+// newLocksFolder returns the Folder containing the lock objects (without history)
+func newLocksFolder(svc *providers.Service) *Folder {
+	f := NewFolder(svc, locksFolderName)
+	f.historySize = 0
+	return f
+}
+
+// readLeaseInfo reads the lock object of 'key' in folder, returns nil if there is none
+func readLeaseInfo(folder *Folder, key string) (*LeaseInfo, error) {
+	var info *LeaseInfo
+	found, err := folder.Read(".", key, func(buf []byte) error {
+		info = &LeaseInfo{}
+		return json.Unmarshal(buf, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// ListLeases returns the locks currently present in the metadata bucket (including expired ones)
+func ListLeases(svc *providers.Service) ([]*LeaseInfo, error) {
+	list := []*LeaseInfo{}
+	err := newLocksFolder(svc).Browse(".", func(buf []byte) error {
+		info := LeaseInfo{}
+		err := json.Unmarshal(buf, &info)
+		if err != nil {
+			log.Warnf("invalid content in lock object: %s", err.Error())
+			return nil
+		}
+		list = append(list, &info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// InspectLease returns the lock of the metadata entry 'key', nil if the entry isn't locked
+func InspectLease(svc *providers.Service, key string) (*LeaseInfo, error) {
+	return readLeaseInfo(newLocksFolder(svc), strings.Trim(key, "/"))
+}
+
+// BreakLease removes the lock of the metadata entry 'key'
+// If the lock is still valid, 'force' is needed; its owner will notice the loss at next renewal
+func BreakLease(svc *providers.Service, key string, force bool) error {
+	key = strings.Trim(key, "/")
+	folder := newLocksFolder(svc)
+	info, err := readLeaseInfo(folder, key)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return fmt.Errorf("no lock on '%s'", key)
+	}
+	if !info.Expired() && !force {
+		return fmt.Errorf("lock on '%s' is held by '%s' until %s; use force to break it anyway", key, info.Owner, info.ExpiresAt.Format(time.RFC3339))
+	}
+	log.Warnf("breaking lock on '%s' held by '%s'", key, info.Owner)
+	return folder.Delete(".", key)
+}
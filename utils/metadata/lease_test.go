@@ -0,0 +1,217 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/model"
+	"github.com/CS-SI/SafeScale/providers/objectstorage"
+)
+
+// testClient is the driver of the tests; only the config options are used by the metadata
+type testClient struct {
+	api.ClientAPI
+}
+
+// GetCfgOpts returns empty config options (metadata not encrypted)
+func (testClient) GetCfgOpts() (model.Config, error) {
+	return model.ConfigMap{}, nil
+}
+
+// newTestService returns a service storing its metadata in memory, in a store of its own for the test
+func newTestService(t *testing.T) *providers.Service {
+	name := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	location, err := objectstorage.NewLocation(objectstorage.Config{Type: objectstorage.MemoryType, Path: name})
+	require.Nil(t, err)
+	bucket, err := location.CreateBucket("metadata")
+	require.Nil(t, err)
+	return &providers.Service{
+		ClientAPI:      testClient{},
+		ObjectStorage:  location,
+		MetadataBucket: bucket,
+	}
+}
+
+// fastLeases shortens the delays of the leases for the test; the returned function restores them
+func fastLeases() func() {
+	poll, settle := leasePollInterval, leaseSettleDelay
+	leasePollInterval, leaseSettleDelay = 10*time.Millisecond, 10*time.Millisecond
+	return func() {
+		leasePollInterval, leaseSettleDelay = poll, settle
+	}
+}
+
+func newTestLease(svc *providers.Service, duration time.Duration) *Lease {
+	l := NewLease(svc, "/hosts/byName/host/")
+	l.duration = duration
+	return l
+}
+
+func TestLeaseContention(t *testing.T) {
+	defer fastLeases()()
+	svc := newTestService(t)
+
+	first := newTestLease(svc, time.Minute)
+	require.Nil(t, first.Acquire(time.Second))
+	assert.NotNil(t, first.Acquire(time.Second))
+	assert.Nil(t, first.Check())
+
+	info, err := InspectLease(svc, "hosts/byName/host")
+	require.Nil(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, first.owner, info.Owner)
+	assert.False(t, info.Expired())
+
+	second := newTestLease(svc, time.Minute)
+	assert.NotNil(t, second.Acquire(50*time.Millisecond))
+	assert.NotNil(t, second.Check())
+
+	require.Nil(t, first.Release())
+	assert.NotNil(t, first.Check())
+	assert.NotNil(t, first.Release())
+	require.Nil(t, second.Acquire(time.Second))
+	require.Nil(t, second.Release())
+	info, err = InspectLease(svc, "hosts/byName/host")
+	require.Nil(t, err)
+	assert.Nil(t, info)
+}
+
+func TestLeaseExclusion(t *testing.T) {
+	defer fastLeases()()
+	svc := newTestService(t)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		holders int
+		maximum int
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := newTestLease(svc, time.Minute)
+			if !assert.Nil(t, l.Acquire(10*time.Second)) {
+				return
+			}
+			mu.Lock()
+			holders++
+			if holders > maximum {
+				maximum = holders
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			assert.Nil(t, l.Release())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, maximum)
+}
+
+func TestLeaseExpiryAndTakeover(t *testing.T) {
+	defer fastLeases()()
+	svc := newTestService(t)
+
+	// The owner disappears without releasing its lease (renewal stopped)
+	crashed := newTestLease(svc, 300*time.Millisecond)
+	require.Nil(t, crashed.Acquire(time.Second))
+	close(crashed.stop)
+	<-crashed.done
+
+	// The lease isn't renewed anymore: its owner can't use it when it's about to expire
+	time.Sleep(250 * time.Millisecond)
+	assert.NotNil(t, crashed.Check())
+
+	// Someone else waits for the expiry, then takes the lease over
+	other := newTestLease(svc, time.Minute)
+	require.Nil(t, other.Acquire(5*time.Second))
+	info, err := InspectLease(svc, "hosts/byName/host")
+	require.Nil(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, other.owner, info.Owner)
+	assert.NotNil(t, crashed.Check())
+	require.Nil(t, other.Release())
+}
+
+func TestLeaseRenewal(t *testing.T) {
+	defer fastLeases()()
+	svc := newTestService(t)
+
+	l := newTestLease(svc, 600*time.Millisecond)
+	require.Nil(t, l.Acquire(time.Second))
+	time.Sleep(1500 * time.Millisecond)
+	assert.Nil(t, l.Check())
+	info, err := InspectLease(svc, "hosts/byName/host")
+	require.Nil(t, err)
+	require.NotNil(t, info)
+	assert.False(t, info.Expired())
+	require.Nil(t, l.Release())
+}
+
+func TestBreakLease(t *testing.T) {
+	defer fastLeases()()
+	svc := newTestService(t)
+
+	assert.NotNil(t, BreakLease(svc, "hosts/byName/host", false))
+
+	l := newTestLease(svc, time.Minute)
+	require.Nil(t, l.Acquire(time.Second))
+	leases, err := ListLeases(svc)
+	require.Nil(t, err)
+	require.Len(t, leases, 1)
+	assert.Equal(t, "hosts/byName/host", leases[0].Key)
+
+	// A valid lease is broken only by force; its owner can't write under it anymore
+	assert.NotNil(t, BreakLease(svc, "hosts/byName/host", false))
+	require.Nil(t, BreakLease(svc, "/hosts/byName/host", true))
+	assert.NotNil(t, l.Check())
+	leases, err = ListLeases(svc)
+	require.Nil(t, err)
+	assert.Empty(t, leases)
+	assert.Nil(t, l.Release())
+}
+
+func TestItemWriteChecksLease(t *testing.T) {
+	defer fastLeases()()
+	svc := newTestService(t)
+
+	item := NewItem(svc, "hosts/byName")
+	data := rawData(`{"name":"host"}`)
+	item.Carry(&data)
+	require.Nil(t, item.Write("host"))
+
+	require.Nil(t, item.Acquire("host"))
+	require.Nil(t, item.Write("host"))
+	require.Nil(t, BreakLease(svc, "hosts/byName/host", true))
+	assert.NotNil(t, item.Write("host"))
+	assert.NotNil(t, item.Delete("host"))
+	item.Release()
+
+	require.Nil(t, item.Delete("host"))
+}
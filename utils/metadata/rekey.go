@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/objectstorage"
+)
+
+// RekeyReport summarizes the re-encryption of the metadata bucket
+type RekeyReport struct {
+	// KeyID is the ID of the primary key, used to re-encrypt
+	KeyID string `json:"key_id"`
+	// Total is the number of objects examined (locks excluded)
+	Total int `json:"total"`
+	// Rekeyed is the number of objects re-encrypted (or to re-encrypt, in dry run)
+	Rekeyed int `json:"rekeyed"`
+	// Skipped is the number of objects already encrypted with the primary key
+	Skipped int `json:"skipped"`
+	// Failures contains a message for each object that couldn't be re-encrypted
+	Failures []string `json:"failures,omitempty"`
+}
+
+// Rekey re-encrypts with the primary key every object of the metadata bucket encrypted with another key of the keyring.
+// The objects not encrypted are encrypted if 'fromPlaintext' is set, reported as failures otherwise.
+// If 'dryRun' is set, nothing is written.
+// Rekey can be interrupted and run again: the objects already encrypted with the primary key are skipped.
+func Rekey(svc *providers.Service, fromPlaintext bool, dryRun bool) (*RekeyReport, error) {
+	f := NewFolder(svc, "")
+	if f.keyring == nil {
+		return nil, fmt.Errorf("metadata are not encrypted: setting 'CryptKey' is missing in 'metadata' section of tenant")
+	}
+
+	list, err := svc.MetadataBucket.List("", objectstorage.NoPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RekeyReport{KeyID: f.keyring.PrimaryKeyID()}
+	for _, key := range list {
+		// Locks are short-lived and rewritten by their owner, they don't need to be rekeyed
+		if strings.HasPrefix(key, locksFolderName+"/") {
+			continue
+		}
+		report.Total++
+		done, err := f.rekeyObject(key, fromPlaintext, dryRun)
+		if err != nil {
+			log.Errorf("failed to rekey metadata '%s': %s", key, err.Error())
+			report.Failures = append(report.Failures, fmt.Sprintf("%s: %s", key, err.Error()))
+			continue
+		}
+		if done {
+			report.Rekeyed++
+		} else {
+			report.Skipped++
+		}
+	}
+	return report, nil
+}
+
+// rekeyObject re-encrypts the object 'key' with the primary key; returns false if it was already encrypted with it
+func (f *Folder) rekeyObject(key string, fromPlaintext bool, dryRun bool) (bool, error) {
+	// Revisions are never updated, the other entries may be updated concurrently and need to be locked
+	if !dryRun && !strings.HasPrefix(key, historyFolderName+"/") {
+		lease := NewLease(f.service, key)
+		err := lease.Acquire(DefaultLeaseTimeout)
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			if err := lease.Release(); err != nil {
+				log.Warnf("failed to release lock of '%s': %s", key, err.Error())
+			}
+		}()
+	}
+
+	var buffer bytes.Buffer
+	_, err := f.service.MetadataBucket.ReadObject(key, &buffer, 0, 0)
+	if err != nil {
+		return false, err
+	}
+	data := buffer.Bytes()
+	if id, _, ok := cryptHeader(data); ok && hex.EncodeToString(id) == f.keyring.PrimaryKeyID() {
+		// Verifies the object is sound before skipping it
+		_, err = f.keyring.decrypt(key, data)
+		return false, err
+	}
+	content, err := f.keyring.decrypt(key, data)
+	if err != nil {
+		if err != errNotEncrypted || !fromPlaintext {
+			return false, err
+		}
+		content = data
+	}
+	if dryRun {
+		return true, nil
+	}
+	data, err = f.keyring.encrypt(key, content)
+	if err != nil {
+		return false, err
+	}
+	source := bytes.NewBuffer(data)
+	_, err = f.service.MetadataBucket.WriteObject(key, source, int64(source.Len()), nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
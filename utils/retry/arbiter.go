@@ -119,6 +119,19 @@ func Timeout(limit time.Duration) Arbiter {
 	}
 }
 
+// Abortable returns Abort with the error returned by 'aborted' when it tells to stop, Retry otherwise.
+func Abortable(aborted func() error) Arbiter {
+	return func(t Try) (Verdict.Enum, error) {
+		if err := aborted(); err != nil {
+			return Verdict.Abort, err
+		}
+		if t.Err != nil {
+			return Verdict.Retry, nil
+		}
+		return Verdict.Done, nil
+	}
+}
+
 // Max errors after a limited number of tries
 func Max(limit uint) Arbiter {
 	return func(t Try) (Verdict.Enum, error) {